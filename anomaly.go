@@ -0,0 +1,285 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// AnomalyConfig is a structure containing the configuration of the
+// anomaly detection of a KES server.
+//
+// A KES server watches how identities use keys and raises an alert
+// if the usage looks suspicious - for example when the credentials
+// of an identity are abused to decrypt many objects. Alerts are
+// logged as audit events with level warning and, if configured,
+// sent to a webhook.
+//
+// Each KES server only watches the requests it handles. Hence,
+// within a cluster, the servers detect anomalies independently.
+type AnomalyConfig struct {
+	// DecryptRate is the max. number of decrypt operations per
+	// minute an identity may perform with a single key. An alert
+	// is raised once an identity exceeds it. If 0, decrypt rates
+	// are not watched.
+	DecryptRate int
+
+	// DormantPeriod is the time after which an unused key is
+	// considered dormant. An alert is raised when a dormant key
+	// is used again. Keys that have not been used since the KES
+	// server has been started are dormant once the server has
+	// been running for the DormantPeriod. If 0, dormant keys are
+	// not watched.
+	DormantPeriod time.Duration
+
+	// Webhook is an optional HTTP(S) URL that receives an alert,
+	// as JSON object, via a POST request whenever an anomaly is
+	// detected.
+	Webhook string
+
+	// Client is the HTTP client used to send alerts to the
+	// webhook. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Types of anomalies.
+const (
+	anomalyDecryptRate = "decrypt_rate" // An identity exceeded the decrypt rate of a key
+	anomalyDormantKey  = "dormant_key"  // A dormant key has been used again
+)
+
+// anomalyAlert is the JSON object sent to the anomaly webhook.
+type anomalyAlert struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Message  string    `json:"message"`
+	Identity string    `json:"identity"`
+	IP       string    `json:"ip,omitempty"`
+	Enclave  string    `json:"enclave,omitempty"`
+	Key      string    `json:"key"`
+}
+
+// anomalyKey identifies a key within an enclave.
+type anomalyKey struct {
+	Enclave, Name string
+}
+
+// anomalyUser identifies the use of a key by an identity.
+type anomalyUser struct {
+	anomalyKey
+	Identity kes.Identity
+}
+
+// anomalyDetector detects suspicious key usage. It counts the
+// decrypt operations of identities per key within one minute
+// windows and tracks when keys have been used last.
+type anomalyDetector struct {
+	decryptRate   int
+	dormantPeriod time.Duration
+	webhook       string
+	client        *http.Client
+	startTime     time.Time
+
+	mu       sync.Mutex
+	window   time.Time // Start of the current decrypt rate window
+	decrypts map[anomalyUser]int
+	lastUsed map[anomalyKey]time.Time
+}
+
+// newAnomalyDetector returns a new anomalyDetector for the given
+// config. It returns nil if conf is nil.
+func newAnomalyDetector(conf *AnomalyConfig) *anomalyDetector {
+	if conf == nil {
+		return nil
+	}
+	client := conf.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &anomalyDetector{
+		decryptRate:   conf.DecryptRate,
+		dormantPeriod: conf.DormantPeriod,
+		webhook:       conf.Webhook,
+		client:        client,
+		startTime:     time.Now(),
+		decrypts:      map[anomalyUser]int{},
+		lastUsed:      map[anomalyKey]time.Time{},
+	}
+}
+
+// Observe records that the identity of the request has used the key
+// named by the request resource and returns the types of detected
+// anomalies, if any. If decrypt is true, the use counts towards the
+// decrypt rate of the identity.
+//
+// An anomaly is reported only once: a decrypt rate once per window
+// and a dormant key only when it gets used for the first time.
+func (d *anomalyDetector) Observe(req *api.Request, decrypt bool, now time.Time) []string {
+	key := anomalyKey{Enclave: req.Enclave, Name: req.Resource}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var anomalies []string
+	if d.dormantPeriod > 0 {
+		lastUsed, ok := d.lastUsed[key]
+		if !ok {
+			lastUsed = d.startTime
+		}
+		if now.Sub(lastUsed) >= d.dormantPeriod {
+			anomalies = append(anomalies, anomalyDormantKey)
+		}
+		d.lastUsed[key] = now
+	}
+	if decrypt && d.decryptRate > 0 {
+		if window := now.Truncate(time.Minute); !window.Equal(d.window) {
+			d.window = window
+			clear(d.decrypts)
+		}
+
+		user := anomalyUser{anomalyKey: key, Identity: req.Identity}
+		d.decrypts[user]++
+		if d.decrypts[user] == d.decryptRate+1 {
+			anomalies = append(anomalies, anomalyDecryptRate)
+		}
+	}
+	return anomalies
+}
+
+// Alert logs an audit event with level warning for the anomaly and
+// sends an alert to the webhook, if any. It does not wait for the
+// webhook to respond.
+func (d *anomalyDetector) Alert(req *api.Request, anomaly string, audit *auditLogger, log *slog.Logger) {
+	var msg string
+	switch anomaly {
+	case anomalyDecryptRate:
+		msg = fmt.Sprintf("identity exceeded decrypt rate of %d/min for key '%s'", d.decryptRate, req.Resource)
+	case anomalyDormantKey:
+		msg = fmt.Sprintf("dormant key '%s' has been used after more than %v", req.Resource, d.dormantPeriod)
+	}
+	audit.Warn(msg, http.StatusOK, req)
+
+	if d.webhook == "" {
+		return
+	}
+	alert := anomalyAlert{
+		Time:     time.Now().UTC(),
+		Type:     anomaly,
+		Message:  msg,
+		Identity: req.Identity.String(),
+		Enclave:  req.Enclave,
+		Key:      req.Resource,
+	}
+	if addr, err := netip.ParseAddrPort(req.RemoteAddr); err == nil {
+		alert.IP = addr.Addr().String()
+	}
+	go d.send(alert, log)
+}
+
+// send sends the alert to the webhook.
+func (d *anomalyDetector) send(alert anomalyAlert, log *slog.Logger) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Warn(fmt.Sprintf("failed to send anomaly alert: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhook, bytes.NewReader(body))
+	if err != nil {
+		log.Warn(fmt.Sprintf("failed to send anomaly alert: %v", err))
+		return
+	}
+	req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		log.Warn(fmt.Sprintf("failed to send anomaly alert: %v", err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Warn(fmt.Sprintf("failed to send anomaly alert: %s", resp.Status))
+	}
+}
+
+// detectAnomalies returns a Handler that wraps h and passes all
+// successful requests to the anomaly detector of the server, if
+// any. The requested resource must be a key name. If decrypt is
+// true, the requests count towards the decrypt rate.
+func (s *Server) detectAnomalies(decrypt bool, h api.Handler) api.Handler {
+	return api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		state := s.state.Load()
+		if state.Anomalies == nil {
+			h.ServeAPI(resp, req)
+			return
+		}
+
+		rw := &statusResponseWriter{ResponseWriter: resp.ResponseWriter}
+		resp.ResponseWriter = rw
+		h.ServeAPI(resp, req)
+
+		if rw.status != http.StatusOK {
+			return
+		}
+		for _, anomaly := range state.Anomalies.Observe(req, decrypt, time.Now()) {
+			state.Anomalies.Alert(req, anomaly, state.Audit, state.Log)
+		}
+	})
+}
+
+// statusResponseWriter is a http.ResponseWriter that records
+// the response status code.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+}
+
+var (
+	_ http.ResponseWriter = (*statusResponseWriter)(nil)
+	_ http.Flusher        = (*statusResponseWriter)(nil)
+)
+
+// WriteHeader sends the response header with the status code.
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the data as part of the response body. It sends
+// the response header with 200 OK, if not sent already.
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,132 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	const DormantPeriod = time.Hour
+	d := newAnomalyDetector(&AnomalyConfig{
+		DecryptRate:   2,
+		DormantPeriod: DormantPeriod,
+	})
+	start := d.startTime
+
+	alice := &api.Request{Identity: "alice", Resource: "my-key"}
+	bob := &api.Request{Identity: "bob", Resource: "my-key"}
+	for i, test := range []struct {
+		Req       *api.Request
+		Decrypt   bool
+		Now       time.Time
+		Anomalies []string
+	}{
+		{Req: alice, Decrypt: true, Now: start},                                                                 // 0
+		{Req: alice, Decrypt: true, Now: start},                                                                 // 1
+		{Req: alice, Decrypt: true, Now: start, Anomalies: []string{anomalyDecryptRate}},                        // 2
+		{Req: alice, Decrypt: true, Now: start},                                                                 // 3: only alerted once
+		{Req: bob, Decrypt: true, Now: start},                                                                   // 4: counted per identity
+		{Req: alice, Decrypt: false, Now: start},                                                                // 5: not a decrypt
+		{Req: alice, Decrypt: true, Now: start.Add(time.Minute)},                                                // 6: new window
+		{Req: alice, Decrypt: false, Now: start.Add(2 * DormantPeriod), Anomalies: []string{anomalyDormantKey}}, // 7
+		{Req: alice, Decrypt: false, Now: start.Add(2 * DormantPeriod)},                                         // 8: no longer dormant
+		{Req: &api.Request{Identity: "alice", Resource: "other-key"}, Now: start.Add(DormantPeriod), Anomalies: []string{anomalyDormantKey}}, // 9: unused since start
+	} {
+		if anomalies := d.Observe(test.Req, test.Decrypt, test.Now); !slices.Equal(anomalies, test.Anomalies) {
+			t.Fatalf("Test %d: got anomalies '%v' - want '%v'", i, anomalies, test.Anomalies)
+		}
+	}
+}
+
+func TestAnomalyWebhook(t *testing.T) {
+	ctx := testContext(t)
+
+	alerts := make(chan anomalyAlert, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert anomalyAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	audit := &recordAudit{}
+	srv, url := startServer(ctx, &Config{
+		AuditLog: audit,
+		Anomalies: &AnomalyConfig{
+			DecryptRate: 1,
+			Webhook:     webhook.URL,
+		},
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	dek, err := client.GenerateKey(ctx, "my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = client.Decrypt(ctx, "my-key", dek.Ciphertext, nil); err != nil {
+			t.Fatalf("Failed to decrypt data key: %v", err)
+		}
+	}
+
+	select {
+	case alert := <-alerts:
+		if alert.Type != anomalyDecryptRate || alert.Key != "my-key" || alert.Identity != defaultIdentity {
+			t.Fatalf("Invalid alert: got '%+v'", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No alert has been sent to webhook")
+	}
+	if records := audit.Records(slog.LevelWarn); len(records) != 1 {
+		t.Fatalf("Got '%d' warning audit records - want '%d'", len(records), 1)
+	}
+}
+
+// recordAudit is an AuditHandler that keeps all records.
+type recordAudit struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (*recordAudit) Enabled(context.Context, slog.Level) bool { return true }
+
+func (a *recordAudit) Handle(_ context.Context, r AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.records = append(a.records, r)
+	return nil
+}
+
+// Records returns all records with the given level.
+func (a *recordAudit) Records(level slog.Level) []AuditRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var records []AuditRecord
+	for _, r := range a.records {
+		if r.Level == level {
+			records = append(records, r)
+		}
+	}
+	return records
+}
@@ -7,8 +7,14 @@ package kes
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"log/slog"
+	"net/http"
 	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/minio/kes/internal/api"
@@ -77,6 +83,17 @@ type AuditHandler interface {
 	Handle(context.Context, AuditRecord) error
 }
 
+// An AuditFlusher is an AuditHandler that buffers AuditRecords
+// before writing them to its destination, like a remote log
+// sink. The Server calls Flush when shutting down, once all
+// requests have finished, such that no AuditRecords get lost.
+type AuditFlusher interface {
+	AuditHandler
+
+	// Flush writes any buffered AuditRecords.
+	Flush() error
+}
+
 // AuditLogHandler is an AuditHandler adapter that wraps
 // an slog.Handler. It converts AuditRecords to slog.Records
 // and passes them to the slog.Handler. An AuditLogHandler
@@ -115,6 +132,182 @@ func (a *AuditLogHandler) Handle(ctx context.Context, r AuditRecord) error {
 	return a.Handler.Handle(ctx, rec)
 }
 
+// MultiAuditHandler returns an AuditHandler that passes
+// AuditRecords to all handlers. It is enabled if any of the
+// handlers is enabled.
+//
+// The returned AuditHandler implements AuditFlusher and io.Closer.
+// It flushes resp. closes all handlers that implement AuditFlusher
+// resp. io.Closer.
+func MultiAuditHandler(handlers ...AuditHandler) AuditHandler {
+	return multiAuditHandler(slices.Clone(handlers))
+}
+
+type multiAuditHandler []AuditHandler
+
+func (m multiAuditHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiAuditHandler) Handle(ctx context.Context, r AuditRecord) error {
+	var err error
+	for _, h := range m {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if hErr := h.Handle(ctx, r); err == nil {
+			err = hErr
+		}
+	}
+	return err
+}
+
+func (m multiAuditHandler) Flush() error {
+	var err error
+	for _, h := range m {
+		if f, ok := h.(AuditFlusher); ok {
+			if fErr := f.Flush(); err == nil {
+				err = fErr
+			}
+		}
+	}
+	return err
+}
+
+func (m multiAuditHandler) Close() error {
+	var err error
+	for _, h := range m {
+		if c, ok := h.(io.Closer); ok {
+			if cErr := c.Close(); err == nil {
+				err = cErr
+			}
+		}
+	}
+	return err
+}
+
+// MinIOAuditHandler is an AuditHandler that writes AuditRecords
+// as JSON objects, one per line, using the schema of MinIO's audit
+// webhook. It allows consuming KES and MinIO audit events with the
+// same ingestion pipeline.
+//
+// Use NewMinIOAuditHandler to create a MinIOAuditHandler.
+type MinIOAuditHandler struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+}
+
+// NewMinIOAuditHandler returns a new MinIOAuditHandler that writes
+// AuditRecords with a level of at least level to w. If level is nil,
+// slog.LevelInfo is used.
+func NewMinIOAuditHandler(w io.Writer, level slog.Leveler) *MinIOAuditHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &MinIOAuditHandler{
+		w:     w,
+		level: level,
+	}
+}
+
+// Enabled reports whether the MinIOAuditHandler handles records
+// at the given level.
+func (a *MinIOAuditHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= a.level.Level()
+}
+
+// Handle converts the AuditRecord to a MinIO audit entry and
+// writes it as single line of JSON.
+func (a *MinIOAuditHandler) Handle(_ context.Context, r AuditRecord) error {
+	b, err := json.Marshal(minioAuditEntry(&r))
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(b)
+	return err
+}
+
+// minioAuditEntry returns the AuditRecord in the JSON schema
+// of MinIO audit webhook entries.
+func minioAuditEntry(r *AuditRecord) any {
+	type API struct {
+		Name               string `json:"name,omitempty"`
+		Object             string `json:"object,omitempty"`
+		Status             string `json:"status,omitempty"`
+		StatusCode         int    `json:"statusCode,omitempty"`
+		InputBytes         int64  `json:"rx"`
+		OutputBytes        int64  `json:"tx"`
+		TimeToResponse     string `json:"timeToResponse,omitempty"`
+		TimeToResponseInNS string `json:"timeToResponseInNS,omitempty"`
+	}
+	type Entry struct {
+		Version    string         `json:"version"`
+		Time       time.Time      `json:"time"`
+		Event      string         `json:"event"`
+		Trigger    string         `json:"trigger"`
+		API        API            `json:"api"`
+		RemoteHost string         `json:"remotehost,omitempty"`
+		ReqPath    string         `json:"requestPath,omitempty"`
+		AccessKey  string         `json:"accessKey,omitempty"`
+		Tags       map[string]any `json:"tags,omitempty"`
+	}
+
+	name, object := auditAPIName(r.Path)
+	entry := Entry{
+		Version: "1",
+		Time:    r.Time.UTC(),
+		Event:   r.Message,
+		Trigger: "incoming",
+		API: API{
+			Name:       name,
+			Object:     object,
+			Status:     http.StatusText(r.StatusCode),
+			StatusCode: r.StatusCode,
+		},
+		ReqPath:   r.Path,
+		AccessKey: r.Identity.String(),
+		Tags: map[string]any{
+			"source": "kes",
+			"level":  r.Level.String(),
+			"method": r.Method,
+		},
+	}
+	if r.ResponseTime > 0 {
+		entry.API.TimeToResponse = r.ResponseTime.String()
+		entry.API.TimeToResponseInNS = strconv.FormatInt(r.ResponseTime.Nanoseconds(), 10)
+	}
+	if r.RemoteIP.IsValid() {
+		entry.RemoteHost = r.RemoteIP.String()
+	}
+	if !r.RemoteIP.IsValid() && r.Identity.IsUnknown() {
+		entry.Trigger = "internal" // Initiated by the server itself
+	}
+	return entry
+}
+
+// auditAPIName splits the API path into the API name and the
+// resource. For example, the path "/v1/key/create/my-key" becomes
+// the API name "key/create" with the resource "my-key".
+func auditAPIName(path string) (name, resource string) {
+	name = strings.TrimPrefix(path, "/v1/")
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		if j := strings.IndexByte(name[i+1:], '/'); j >= 0 {
+			name, resource = name[:i+1+j], name[i+1+j+1:]
+		}
+	}
+	return name, resource
+}
+
 // An auditLogger records information about a request/response
 // handled by the Server.
 //
@@ -143,19 +336,30 @@ func newAuditLogger(h AuditHandler, level slog.Leveler) *auditLogger {
 // Log emits an audit record with the current time, log message,
 // response status code and request information.
 func (a *auditLogger) Log(msg string, statusCode int, req *api.Request) {
-	const Level = slog.LevelInfo
-	if Level < a.level.Level() {
+	a.logRequest(slog.LevelInfo, msg, statusCode, req)
+}
+
+// Warn behaves like Log but emits an audit record with level
+// warning, for example when a request looks suspicious.
+func (a *auditLogger) Warn(msg string, statusCode int, req *api.Request) {
+	a.logRequest(slog.LevelWarn, msg, statusCode, req)
+}
+
+// logRequest emits an audit record with the given level, current
+// time, log message, response status code and request information.
+func (a *auditLogger) logRequest(level slog.Level, msg string, statusCode int, req *api.Request) {
+	if level < a.level.Level() {
 		return
 	}
 
-	hEnabled, oEnabled := a.h.Enabled(req.Context(), Level), a.out.Num() > 0
+	hEnabled, oEnabled := a.h.Enabled(req.Context(), level), a.out.Num() > 0
 	if !hEnabled && !oEnabled {
 		return
 	}
 
 	now := time.Now()
 	remoteIP, _ := netip.ParseAddrPort(req.RemoteAddr)
-	r := AuditRecord{
+	a.log(req.Context(), AuditRecord{
 		Time:         time.Now(),
 		Method:       req.Method,
 		Path:         req.URL.Path,
@@ -163,20 +367,66 @@ func (a *auditLogger) Log(msg string, statusCode int, req *api.Request) {
 		RemoteIP:     remoteIP.Addr(),
 		StatusCode:   statusCode,
 		ResponseTime: now.Sub(req.Received),
-		Level:        Level,
+		Level:        level,
 		Message:      msg,
+	}, hEnabled, oEnabled)
+}
+
+// LogEvent emits an audit record for an operation initiated by
+// the server itself, like a scheduled key rotation, instead of
+// a client request. Such records have no remote IP address and
+// no identity.
+//
+// The method and path describe the API that corresponds to the
+// operation.
+func (a *auditLogger) LogEvent(ctx context.Context, msg, method, path string, statusCode int) {
+	const Level = slog.LevelInfo
+	if Level < a.level.Level() {
+		return
+	}
+
+	hEnabled, oEnabled := a.h.Enabled(ctx, Level), a.out.Num() > 0
+	if !hEnabled && !oEnabled {
+		return
+	}
+
+	a.log(ctx, AuditRecord{
+		Time:       time.Now(),
+		Method:     method,
+		Path:       path,
+		StatusCode: statusCode,
+		Level:      Level,
+		Message:    msg,
+	}, hEnabled, oEnabled)
+}
+
+// Flush flushes the AuditHandler if it implements AuditFlusher.
+func (a *auditLogger) Flush() error {
+	if f, ok := a.h.(AuditFlusher); ok {
+		return f.Flush()
 	}
+	return nil
+}
+
+// log passes r to the AuditHandler, if hEnabled, and sends it to
+// all clients subscribed to the AuditLog API, if oEnabled.
+func (a *auditLogger) log(ctx context.Context, r AuditRecord, hEnabled, oEnabled bool) {
 	if hEnabled {
-		a.h.Handle(req.Context(), r)
+		a.h.Handle(ctx, r)
 	}
 
 	if !oEnabled {
 		return
 	}
+
+	var ip string
+	if r.RemoteIP.IsValid() {
+		ip = r.RemoteIP.String()
+	}
 	json.NewEncoder(a.out).Encode(api.AuditLogEvent{
 		Time: r.Time,
 		Request: api.AuditLogRequest{
-			IP:       r.RemoteIP.String(),
+			IP:       ip,
 			APIPath:  r.Path,
 			Identity: r.Identity.String(),
 		},
@@ -0,0 +1,64 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestMinIOAuditHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewMinIOAuditHandler(&buf, slog.LevelInfo)
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("Handler is enabled for records below its level")
+	}
+
+	err := h.Handle(context.Background(), AuditRecord{
+		Time:         time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Method:       http.MethodPut,
+		Path:         "/v1/key/create/my-key",
+		Identity:     "my-identity",
+		RemoteIP:     netip.MustParseAddr("10.1.2.3"),
+		StatusCode:   http.StatusOK,
+		ResponseTime: 3 * time.Millisecond,
+		Level:        slog.LevelInfo,
+		Message:      "secret key 'my-key' created",
+	})
+	if err != nil {
+		t.Fatalf("Failed to handle audit record: %v", err)
+	}
+
+	var entry struct {
+		Version string `json:"version"`
+		Trigger string `json:"trigger"`
+		API     struct {
+			Name           string `json:"name"`
+			Object         string `json:"object"`
+			StatusCode     int    `json:"statusCode"`
+			TimeToResponse string `json:"timeToResponse"`
+		} `json:"api"`
+		RemoteHost string `json:"remotehost"`
+		AccessKey  string `json:"accessKey"`
+	}
+	if err = json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse audit entry: %v", err)
+	}
+	if entry.Version != "1" || entry.Trigger != "incoming" {
+		t.Fatalf("Invalid audit entry: got version '%s' and trigger '%s'", entry.Version, entry.Trigger)
+	}
+	if entry.API.Name != "key/create" || entry.API.Object != "my-key" || entry.API.StatusCode != http.StatusOK || entry.API.TimeToResponse != "3ms" {
+		t.Fatalf("Invalid audit entry API: %+v", entry.API)
+	}
+	if entry.RemoteHost != "10.1.2.3" || entry.AccessKey != "my-identity" {
+		t.Fatalf("Invalid audit entry: got remote host '%s' and access key '%s'", entry.RemoteHost, entry.AccessKey)
+	}
+}
@@ -6,7 +6,6 @@ package kes
 
 import (
 	"crypto/sha256"
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
@@ -14,6 +13,7 @@ import (
 	"sync/atomic"
 
 	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -27,6 +27,26 @@ import (
 // later is the case if none of the policy's deny rules and at least
 // one of the policy's allow rules apply. Otherwise, the request is
 // rejected.
+//
+// Requests rejected by the identity's policy, or of identities
+// without a policy, are accepted if the identity has been granted
+// the operation on the addressed key. Key grants only apply to
+// cryptographic operations, like encrypting or decrypting, on
+// that particular key.
+//
+// If an external authorization endpoint is configured, requests
+// that pass the identity's policy or a key grant, or of identities
+// without a policy, must also be allowed by the endpoint.
+//
+// Clients with a verified X.509-SVID whose identity is not assigned
+// to a policy are identified by their SPIFFE ID instead. Policies
+// may assign SPIFFE IDs, or SPIFFE ID patterns ending with '*', like
+// "spiffe://example.org/ns/minio/*".
+//
+// If revocation checking is configured, requests of clients with
+// a revoked certificate are rejected, even for the admin identity.
+// Unless revocation checking fails open, requests are also rejected
+// if the revocation status cannot be determined.
 type verifyIdentity atomic.Pointer[serverState]
 
 // Authenticate verifies that the request is either sent by the
@@ -34,31 +54,86 @@ type verifyIdentity atomic.Pointer[serverState]
 // Otherwise, it returns an error.
 func (v *verifyIdentity) Authenticate(req *http.Request) (*api.Request, api.Error) {
 	s := (*atomic.Pointer[serverState])(v).Load()
-	identity, err := identifyRequest(req.TLS)
+	identity, err := identifyRequest(req, s.UnixIdentities)
 	if err != nil {
 		s.Log.DebugContext(req.Context(), err.Error(), "req", req)
 		return nil, err
 	}
+
+	// Requests may address an enclave. The admin has access to
+	// all enclaves while all other identities must be assigned
+	// to a policy within the enclave.
+	identities := s.Identities
+	name := req.Header.Get(headers.KesEnclave)
+	if name != "" {
+		enclave, ok := s.Enclaves[name]
+		if !ok {
+			return nil, kes.ErrEnclaveNotFound
+		}
+		identities = enclave.Identities
+	}
+	if s.Revocation != nil {
+		revoked, err := s.Revocation.Revoked(req)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+			if !s.Revocation.FailOpen() {
+				return nil, kes.ErrNotAllowed
+			}
+		}
+		if revoked {
+			s.Log.DebugContext(req.Context(), "access denied: client certificate has been revoked", "req", req)
+			return nil, kes.ErrNotAllowed
+		}
+	}
 	if identity == s.Admin {
 		return &api.Request{
 			Request:  req,
 			Identity: identity,
+			Enclave:  name,
 		}, nil
 	}
 
-	policy, ok := s.Identities[identity]
+	// Clients without an assigned identity may be admitted by the
+	// SPIFFE ID of their X.509-SVID. Then, the SPIFFE ID becomes the
+	// request identity.
+	policy, ok := identities[identity]
+	if _, isAWS := awsIdentity(req); !ok && !isAWS {
+		if id, isSVID := spiffeID(req); isSVID {
+			identity = id
+			policy, ok = lookupIdentity(identities, identity)
+		}
+	}
+	var denied string
 	if !ok {
-		s.Log.DebugContext(req.Context(), "access denied: identity not found", "req", req)
-		return nil, kes.ErrNotAllowed
+		denied = "access denied: identity not found"
+	} else if err := policy.Verify(req); err != nil {
+		denied = fmt.Sprintf("access denied: rejected by policy '%s'", policy.Name)
 	}
-	if err := policy.Verify(req); err != nil {
-		s.Log.DebugContext(req.Context(), fmt.Sprintf("access denied: rejected by policy '%s'", policy.Name), "req", req)
-		return nil, kes.ErrNotAllowed
+	if denied != "" {
+		granted, err := s.keyGranted(req, identity, name)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		}
+		if !granted && (ok || s.Authz == nil) {
+			s.Log.DebugContext(req.Context(), denied, "req", req)
+			return nil, kes.ErrNotAllowed
+		}
+	}
+	if s.Authz != nil {
+		allow, err := s.Authz.Authorize(req, identity, name)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), err.Error(), "req", req)
+		}
+		if !allow {
+			s.Log.DebugContext(req.Context(), "access denied: rejected by external authorization", "req", req)
+			return nil, kes.ErrNotAllowed
+		}
 	}
 
 	return &api.Request{
 		Request:  req,
 		Identity: identity,
+		Enclave:  name,
 	}, nil
 }
 
@@ -70,14 +145,26 @@ func (v *verifyIdentity) Authenticate(req *http.Request) (*api.Request, api.Erro
 type insecureIdentifyOnly struct{}
 
 func (insecureIdentifyOnly) Authenticate(req *http.Request) (*api.Request, api.Error) {
-	identity, _ := identifyRequest(req.TLS)
+	identity, _ := identifyRequest(req, nil)
 	return &api.Request{
 		Request:  req,
 		Identity: identity,
 	}, nil
 }
 
-func identifyRequest(state *tls.ConnectionState) (kes.Identity, api.Error) {
+func identifyRequest(req *http.Request, local map[uint32]kes.Identity) (kes.Identity, api.Error) {
+	if identity, ok := awsIdentity(req); ok {
+		return identity, nil
+	}
+	if uid, ok := req.Context().Value(peerUIDKey{}).(uint32); ok {
+		identity, ok := local[uid]
+		if !ok {
+			return "", kes.ErrNotAllowed
+		}
+		return identity, nil
+	}
+
+	state := req.TLS
 	if state == nil {
 		return "", api.NewError(http.StatusBadRequest, "insecure connection: TLS is required")
 	}
@@ -169,3 +256,30 @@ func validPattern(s string) bool {
 	}
 	return true
 }
+
+// validPolicyPattern reports whether s is a valid API path pattern
+// of a policy allow or deny rule.
+//
+// Valid patterns start with a '/' and contain at most one '*' that
+// must be the last character. For example, "/v1/key/*" matches all
+// key APIs while "/v1/key/delete/prod-*" matches deleting all keys
+// starting with "prod-". An asterisk in any other position would be
+// treated as literal character and, therefore, never match.
+func validPolicyPattern(s string) bool {
+	const MaxLength = 256 // Some arbitrary but reasonable limit
+
+	if len(s) < 2 || len(s) > MaxLength || s[0] != '/' {
+		return false
+	}
+
+	n := len(s) - 1
+	for i, r := range s {
+		switch {
+		case r == '*' && i != n:
+			return false
+		case r <= ' ' || r == 0x7f:
+			return false
+		}
+	}
+	return true
+}
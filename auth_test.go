@@ -5,8 +5,11 @@
 package kes
 
 import (
+	"errors"
 	"strings"
 	"testing"
+
+	"github.com/minio/kms-go/kes"
 )
 
 func TestValidName(t *testing.T) {
@@ -27,6 +30,50 @@ func TestValidPattern(t *testing.T) {
 	}
 }
 
+func TestValidPolicyPattern(t *testing.T) {
+	t.Parallel()
+	for i, test := range validPolicyPatternTests {
+		if valid := validPolicyPattern(test.Pattern); valid != !test.ShouldFail {
+			t.Errorf("Test %d: got 'valid=%v' - want 'fail=%v' for pattern '%s'", i, valid, test.ShouldFail, test.Pattern)
+		}
+	}
+}
+
+func TestPolicyDenyPrecedence(t *testing.T) {
+	ctx := testContext(t)
+
+	_, url := startServer(ctx, &Config{
+		Admin: "disabled",
+		Policies: map[string]Policy{
+			"my-policy": {
+				Allow: map[string]kes.Rule{
+					"/v1/key/*": {},
+				},
+				Deny: map[string]kes.Rule{
+					"/v1/key/delete/prod-*": {},
+				},
+				Identities: []kes.Identity{defaultIdentity},
+			},
+		},
+	})
+	client := defaultClient(url)
+
+	for _, name := range []string{"dev-key", "prod-key"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+	if err := client.DeleteKey(ctx, "dev-key"); err != nil {
+		t.Fatalf("Failed to delete key '%s': %v", "dev-key", err)
+	}
+	if err := client.DeleteKey(ctx, "prod-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Deleting key: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if _, err := client.DescribeKey(ctx, "prod-key"); err != nil {
+		t.Fatalf("Failed to describe key '%s': %v", "prod-key", err)
+	}
+}
+
 func BenchmarkValidName(b *testing.B) {
 	const (
 		EmptyName   = ""
@@ -123,4 +170,24 @@ var (
 		{Pattern: "Εmacs", ShouldFail: true},                 // 16 - greek Ε
 		{Pattern: strings.Repeat("a", 81), ShouldFail: true}, // 17
 	}
+
+	validPolicyPatternTests = []struct {
+		Pattern    string
+		ShouldFail bool
+	}{
+		{Pattern: "/v1/status"},            // 0
+		{Pattern: "/v1/key/*"},             // 1
+		{Pattern: "/v1/key/create/my-key"}, // 2
+		{Pattern: "/v1/key/delete/prod-*"}, // 3
+		{Pattern: "/*"},                    // 4
+
+		{Pattern: "", ShouldFail: true},                             // 5
+		{Pattern: "/", ShouldFail: true},                            // 6
+		{Pattern: "*", ShouldFail: true},                            // 7
+		{Pattern: "v1/key/*", ShouldFail: true},                     // 8
+		{Pattern: "/v1/key/*/prod-*", ShouldFail: true},             // 9
+		{Pattern: "/v1/*/create/my-key", ShouldFail: true},          // 10
+		{Pattern: "/v1/key/create/my key", ShouldFail: true},        // 11
+		{Pattern: "/" + strings.Repeat("a", 256), ShouldFail: true}, // 12
+	}
 )
@@ -0,0 +1,236 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// AuthzFormat is the request format of an external authorization
+// endpoint.
+type AuthzFormat int
+
+// Supported external authorization request formats.
+const (
+	// AuthzOPA sends authorization requests to an Open Policy Agent
+	// (OPA) REST API data endpoint, like "/v1/data/kes/allow". The
+	// request context is sent as OPA input and the decision is the
+	// boolean result of the queried document:
+	//
+	//	Request:  {"input": {"identity": "...", "path": "...", ...}}
+	//	Response: {"result": true}
+	AuthzOPA AuthzFormat = iota
+
+	// AuthzWebhook sends the request context as JSON object to a
+	// generic webhook. The decision is the webhook's "allow" field:
+	//
+	//	Request:  {"identity": "...", "path": "...", ...}
+	//	Response: {"allow": true}
+	AuthzWebhook
+)
+
+// AuthzConfig is a structure containing the configuration of an
+// external authorization endpoint.
+//
+// If configured, a KES server delegates authorization decisions
+// for all identities except the admin to the endpoint. Requests
+// of identities with an assigned policy must pass both, the policy
+// and the external authorization. Requests of identities without
+// a policy are authorized by the endpoint alone.
+type AuthzConfig struct {
+	// Endpoint is the HTTP(S) URL of the authorization endpoint.
+	Endpoint string
+
+	// Format is the request format of the endpoint.
+	Format AuthzFormat
+
+	// Client is the HTTP client used to send authorization
+	// requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout is the max. time a KES server waits for an
+	// authorization decision. If zero, it defaults to 5s.
+	Timeout time.Duration
+
+	// FailOpen controls whether requests are allowed or denied
+	// if the endpoint is not reachable or does not respond with
+	// a valid decision. By default, such requests are denied.
+	FailOpen bool
+
+	// CacheExpiry is the time a KES server caches authorization
+	// decisions. If zero, decisions are not cached.
+	CacheExpiry time.Duration
+}
+
+// authzMaxCacheSize is the max. number of cached
+// authorization decisions.
+const authzMaxCacheSize = 10000
+
+// authzInput is the request context sent to an external
+// authorization endpoint.
+type authzInput struct {
+	Identity  kes.Identity `json:"identity"`
+	Enclave   string       `json:"enclave,omitempty"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	Operation string       `json:"operation"`
+	Resource  string       `json:"resource,omitempty"`
+	RemoteIP  string       `json:"remote_ip,omitempty"`
+}
+
+// authorizer delegates authorization decisions to an
+// external endpoint and caches them.
+type authorizer struct {
+	conf AuthzConfig
+
+	mu    sync.Mutex
+	cache map[authzInput]authzDecision
+}
+
+// authzDecision is a cached authorization decision.
+type authzDecision struct {
+	Allow     bool
+	ExpiresAt time.Time
+}
+
+// newAuthorizer returns a new authorizer for the config.
+// It returns nil if conf is nil.
+func newAuthorizer(conf *AuthzConfig) *authorizer {
+	if conf == nil {
+		return nil
+	}
+	a := &authorizer{
+		conf:  *conf,
+		cache: map[authzInput]authzDecision{},
+	}
+	if a.conf.Client == nil {
+		a.conf.Client = http.DefaultClient
+	}
+	if a.conf.Timeout == 0 {
+		a.conf.Timeout = 5 * time.Second
+	}
+	return a
+}
+
+// Authorize reports whether the identity is allowed to perform
+// the request within the enclave. It returns an error describing
+// why the endpoint failed to make a decision, if any. In such a
+// case, the decision depends on whether the authorizer fails open
+// or closed.
+func (a *authorizer) Authorize(req *http.Request, identity kes.Identity, enclave string) (bool, error) {
+	operation, resource := auditAPIName(req.URL.Path)
+	input := authzInput{
+		Identity:  identity,
+		Enclave:   enclave,
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Operation: operation,
+		Resource:  resource,
+	}
+	if addr, err := netip.ParseAddrPort(req.RemoteAddr); err == nil {
+		input.RemoteIP = addr.Addr().String()
+	}
+
+	now := time.Now()
+	if a.conf.CacheExpiry > 0 {
+		a.mu.Lock()
+		decision, ok := a.cache[input]
+		a.mu.Unlock()
+		if ok && now.Before(decision.ExpiresAt) {
+			return decision.Allow, nil
+		}
+	}
+
+	allow, err := a.query(req.Context(), &input)
+	if err != nil {
+		return a.conf.FailOpen, err
+	}
+	if a.conf.CacheExpiry > 0 {
+		a.mu.Lock()
+		if len(a.cache) >= authzMaxCacheSize {
+			for key, decision := range a.cache {
+				if now.After(decision.ExpiresAt) {
+					delete(a.cache, key)
+				}
+			}
+			if len(a.cache) >= authzMaxCacheSize {
+				clear(a.cache)
+			}
+		}
+		a.cache[input] = authzDecision{
+			Allow:     allow,
+			ExpiresAt: now.Add(a.conf.CacheExpiry),
+		}
+		a.mu.Unlock()
+	}
+	return allow, nil
+}
+
+// query sends the authorization request to the endpoint and
+// returns its decision.
+func (a *authorizer) query(ctx context.Context, input *authzInput) (bool, error) {
+	var body any = input
+	if a.conf.Format == AuthzOPA {
+		body = struct {
+			Input *authzInput `json:"input"`
+		}{Input: input}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.conf.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.conf.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.conf.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("kes: authorization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("kes: authorization request failed: %s", resp.Status)
+	}
+
+	const MaxSize = 1 << 20
+	var decision struct {
+		Result *bool `json:"result"` // OPA
+		Allow  *bool `json:"allow"`  // Webhook
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxSize)).Decode(&decision); err != nil {
+		return false, fmt.Errorf("kes: invalid authorization response: %v", err)
+	}
+
+	switch {
+	case a.conf.Format == AuthzOPA && decision.Result == nil:
+		// OPA omits the result if the queried document
+		// is undefined, e.g. because no rule matched.
+		return false, nil
+	case a.conf.Format == AuthzOPA:
+		return *decision.Result, nil
+	case decision.Allow == nil:
+		return false, errors.New("kes: invalid authorization response: no decision")
+	default:
+		return *decision.Allow, nil
+	}
+}
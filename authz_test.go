@@ -0,0 +1,89 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestAuthz(t *testing.T) {
+	ctx := testContext(t)
+
+	var requests atomic.Int64
+	opa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		var body struct {
+			Input authzInput `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		allow := body.Input.Operation == "key/create" && strings.HasPrefix(body.Input.Resource, "allowed-")
+		json.NewEncoder(w).Encode(map[string]bool{"result": allow})
+	}))
+	defer opa.Close()
+
+	// Disable the admin such that the client's identity, which has
+	// no policy, is authorized by the external endpoint alone.
+	_, url := startServer(ctx, &Config{
+		Admin: "disabled",
+		Authz: &AuthzConfig{
+			Endpoint:    opa.URL + "/v1/data/kes/allow",
+			Format:      AuthzOPA,
+			CacheExpiry: time.Minute,
+		},
+	})
+	client := defaultClient(url)
+
+	if err := client.CreateKey(ctx, "allowed-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if err := client.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("Invalid number of authorization requests: got '%d' - want '%d'", n, 2)
+	}
+}
+
+func TestAuthzFailOpen(t *testing.T) {
+	endpoint := httptest.NewServer(http.NotFoundHandler())
+	endpoint.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/key/create/my-key", nil)
+	for i, test := range []struct {
+		FailOpen bool
+	}{
+		{FailOpen: false},
+		{FailOpen: true},
+	} {
+		a := newAuthorizer(&AuthzConfig{
+			Endpoint: endpoint.URL,
+			Format:   AuthzWebhook,
+			FailOpen: test.FailOpen,
+		})
+		allow, err := a.Authorize(req, "my-identity", "")
+		if err == nil {
+			t.Fatalf("Test %d: authorization succeeded with unreachable endpoint", i)
+		}
+		if allow != test.FailOpen {
+			t.Fatalf("Test %d: got decision '%v' - want '%v'", i, allow, test.FailOpen)
+		}
+	}
+}
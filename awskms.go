@@ -0,0 +1,455 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/sigv4"
+	"github.com/minio/kms-go/kes"
+)
+
+const (
+	// awsKMSMaxBody is the max. size of AWS KMS request bodies.
+	awsKMSMaxBody = 1 << 20
+
+	// awsKMSAccountID is the AWS account ID of key ARNs returned
+	// by the AWS KMS API. KES has no AWS accounts.
+	awsKMSAccountID = "000000000000"
+
+	// awsKMSAlgorithm is the only encryption algorithm
+	// supported by the AWS KMS API.
+	awsKMSAlgorithm = "SYMMETRIC_DEFAULT"
+
+	// awsKMSBlobVersion is the version of the ciphertext
+	// blobs returned by the AWS KMS API.
+	awsKMSBlobVersion = 1
+)
+
+// awsIdentityKey is the context key of the identity of an
+// AWS KMS API request authenticated via its AWS signature.
+type awsIdentityKey struct{}
+
+// awsKMSHandler returns an HTTP handler serving a subset of the
+// AWS KMS API: Encrypt, Decrypt, GenerateDataKey and DescribeKey.
+//
+// Requests are authenticated by their AWS Signature Version 4
+// and served by the corresponding KES API on behalf of the KES
+// identity of the request's AWS access key. Hence, they are
+// authorized, rate limited and audited like any other request.
+//
+// AWS KMS key IDs, aliases and ARNs refer to the KES key with
+// the same name. For example, "my-key", "alias/my-key" and
+// "arn:aws:kms:us-east-1:000000000000:key/my-key" all refer to
+// the KES key "my-key".
+func (s *Server) awsKMSHandler(conf *AWSKMSConfig) http.Handler {
+	lookup := func(accessKey string) (string, bool) {
+		cred, ok := conf.Credentials[accessKey]
+		return cred.SecretKey, ok
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAWSError(w, awsError(http.StatusMethodNotAllowed, "UnsupportedOperationException", "method not allowed"))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, awsKMSMaxBody+1))
+		if err != nil {
+			writeAWSError(w, awsError(http.StatusBadRequest, "ValidationException", "failed to read request body"))
+			return
+		}
+		if len(body) > awsKMSMaxBody {
+			writeAWSError(w, awsError(http.StatusRequestEntityTooLarge, "ValidationException", "request body too large"))
+			return
+		}
+
+		// The operation and the body encoding must be signed since
+		// requests are dispatched on them.
+		accessKey, err := sigv4.Verify(r, body, conf.Region, "kms", lookup, time.Now(), "X-Amz-Target", "Content-Type")
+		if err != nil {
+			s.state.Load().Log.DebugContext(r.Context(), fmt.Sprintf("kes: AWS KMS request rejected: %v", err), "remote", r.RemoteAddr)
+			writeAWSError(w, awsError(http.StatusBadRequest, "InvalidSignatureException", "invalid request signature"))
+			return
+		}
+		ctx := context.WithValue(r.Context(), awsIdentityKey{}, conf.Credentials[accessKey].Identity)
+
+		var resp any
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case "TrentService.Encrypt":
+			resp, err = s.awsEncrypt(ctx, r, conf.Region, body)
+		case "TrentService.Decrypt":
+			resp, err = s.awsDecrypt(ctx, r, conf.Region, body)
+		case "TrentService.GenerateDataKey":
+			resp, err = s.awsGenerateDataKey(ctx, r, conf.Region, body)
+		case "TrentService.DescribeKey":
+			resp, err = s.awsDescribeKey(ctx, r, conf.Region, body)
+		default:
+			err = awsError(http.StatusBadRequest, "UnknownOperationException", fmt.Sprintf("operation '%s' is not supported", target))
+		}
+		if err != nil {
+			writeAWSError(w, err)
+			return
+		}
+
+		w.Header().Set(headers.ContentType, "application/x-amz-json-1.1")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+func (s *Server) awsEncrypt(ctx context.Context, r *http.Request, region string, body []byte) (any, error) {
+	var req struct {
+		KeyID               string            `json:"KeyId"`
+		Plaintext           []byte            `json:"Plaintext"`
+		EncryptionContext   map[string]string `json:"EncryptionContext"`
+		EncryptionAlgorithm string            `json:"EncryptionAlgorithm"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "invalid request body")
+	}
+	if req.EncryptionAlgorithm != "" && req.EncryptionAlgorithm != awsKMSAlgorithm {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", fmt.Sprintf("encryption algorithm '%s' is not supported", req.EncryptionAlgorithm))
+	}
+	name, err := awsKeyName(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	associatedData, err := awsEncryptionContext(req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp api.EncryptKeyResponse
+	if err = s.awsCall(ctx, r, http.MethodPut, api.PathKeyEncrypt+name, api.EncryptKeyRequest{
+		Plaintext: req.Plaintext,
+		Context:   associatedData,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return struct {
+		CiphertextBlob      []byte `json:"CiphertextBlob"`
+		KeyID               string `json:"KeyId"`
+		EncryptionAlgorithm string `json:"EncryptionAlgorithm"`
+	}{
+		CiphertextBlob:      awsCiphertextBlob(name, resp.Ciphertext),
+		KeyID:               awsKeyARN(region, name),
+		EncryptionAlgorithm: awsKMSAlgorithm,
+	}, nil
+}
+
+func (s *Server) awsDecrypt(ctx context.Context, r *http.Request, region string, body []byte) (any, error) {
+	var req struct {
+		KeyID               string            `json:"KeyId"`
+		CiphertextBlob      []byte            `json:"CiphertextBlob"`
+		EncryptionContext   map[string]string `json:"EncryptionContext"`
+		EncryptionAlgorithm string            `json:"EncryptionAlgorithm"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "invalid request body")
+	}
+	if req.EncryptionAlgorithm != "" && req.EncryptionAlgorithm != awsKMSAlgorithm {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", fmt.Sprintf("encryption algorithm '%s' is not supported", req.EncryptionAlgorithm))
+	}
+	name, ciphertext, err := parseAWSCiphertextBlob(req.CiphertextBlob)
+	if err != nil {
+		return nil, err
+	}
+	if req.KeyID != "" {
+		keyName, err := awsKeyName(req.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		if keyName != name {
+			return nil, awsError(http.StatusBadRequest, "IncorrectKeyException", "ciphertext has not been encrypted with the specified key")
+		}
+	}
+	associatedData, err := awsEncryptionContext(req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp api.DecryptKeyResponse
+	if err = s.awsCall(ctx, r, http.MethodPut, api.PathKeyDecrypt+name, api.DecryptKeyRequest{
+		Ciphertext: ciphertext,
+		Context:    associatedData,
+	}, &resp); err != nil {
+		var aErr *awsKMSError
+		if errors.As(err, &aErr) && aErr.Type == "ValidationException" {
+			aErr.Type = "InvalidCiphertextException"
+		}
+		return nil, err
+	}
+	return struct {
+		KeyID               string `json:"KeyId"`
+		Plaintext           []byte `json:"Plaintext"`
+		EncryptionAlgorithm string `json:"EncryptionAlgorithm"`
+	}{
+		KeyID:               awsKeyARN(region, name),
+		Plaintext:           resp.Plaintext,
+		EncryptionAlgorithm: awsKMSAlgorithm,
+	}, nil
+}
+
+func (s *Server) awsGenerateDataKey(ctx context.Context, r *http.Request, region string, body []byte) (any, error) {
+	var req struct {
+		KeyID             string            `json:"KeyId"`
+		KeySpec           string            `json:"KeySpec"`
+		NumberOfBytes     int               `json:"NumberOfBytes"`
+		EncryptionContext map[string]string `json:"EncryptionContext"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "invalid request body")
+	}
+	if req.KeySpec != "" && req.KeySpec != "AES_256" {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", fmt.Sprintf("key spec '%s' is not supported", req.KeySpec))
+	}
+	if req.NumberOfBytes != 0 && req.NumberOfBytes != 32 {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "only 32 byte data keys are supported")
+	}
+	name, err := awsKeyName(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	associatedData, err := awsEncryptionContext(req.EncryptionContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp api.GenerateKeyResponse
+	if err = s.awsCall(ctx, r, http.MethodPut, api.PathKeyGenerate+name, api.GenerateKeyRequest{
+		Context: associatedData,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+		Plaintext      []byte `json:"Plaintext"`
+		KeyID          string `json:"KeyId"`
+	}{
+		CiphertextBlob: awsCiphertextBlob(name, resp.Ciphertext),
+		Plaintext:      resp.Plaintext,
+		KeyID:          awsKeyARN(region, name),
+	}, nil
+}
+
+func (s *Server) awsDescribeKey(ctx context.Context, r *http.Request, region string, body []byte) (any, error) {
+	var req struct {
+		KeyID string `json:"KeyId"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "invalid request body")
+	}
+	name, err := awsKeyName(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp api.DescribeKeyResponse
+	if err = s.awsCall(ctx, r, http.MethodGet, api.PathKeyDescribe+name, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	type KeyMetadata struct {
+		AWSAccountID         string   `json:"AWSAccountId"`
+		KeyID                string   `json:"KeyId"`
+		Arn                  string   `json:"Arn"`
+		CreationDate         float64  `json:"CreationDate"`
+		Enabled              bool     `json:"Enabled"`
+		KeyState             string   `json:"KeyState"`
+		KeyUsage             string   `json:"KeyUsage"`
+		KeySpec              string   `json:"KeySpec"`
+		KeyManager           string   `json:"KeyManager"`
+		Origin               string   `json:"Origin"`
+		EncryptionAlgorithms []string `json:"EncryptionAlgorithms"`
+	}
+	return struct {
+		KeyMetadata KeyMetadata `json:"KeyMetadata"`
+	}{
+		KeyMetadata: KeyMetadata{
+			AWSAccountID:         awsKMSAccountID,
+			KeyID:                name,
+			Arn:                  awsKeyARN(region, name),
+			CreationDate:         float64(resp.CreatedAt.UnixMilli()) / 1000,
+			Enabled:              true,
+			KeyState:             "Enabled",
+			KeyUsage:             "ENCRYPT_DECRYPT",
+			KeySpec:              awsKMSAlgorithm,
+			KeyManager:           "CUSTOMER",
+			Origin:               "EXTERNAL",
+			EncryptionAlgorithms: []string{awsKMSAlgorithm},
+		},
+	}, nil
+}
+
+// awsCall serves an AWS KMS request by calling the KES API with
+// the given method and path on behalf of the identity in ctx.
+// If body is not nil, it is sent as JSON request body. If resp
+// is not nil, the JSON response body is decoded into it.
+func (s *Server) awsCall(ctx context.Context, r *http.Request, method, path string, body, resp any) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return awsError(http.StatusInternalServerError, "KMSInternalException", "failed to encode request")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(reqBody))
+	if err != nil {
+		return awsError(http.StatusBadRequest, "ValidationException", fmt.Sprintf("invalid request: %v", err))
+	}
+	req.RequestURI = path
+	req.TLS = r.TLS
+	req.RemoteAddr = r.RemoteAddr
+	if body != nil {
+		req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+	}
+
+	w := &bufferedResponseWriter{header: http.Header{}}
+	s.handler.Load().ServeHTTP(w, req)
+
+	if code := w.statusCode(); code != http.StatusOK {
+		return awsErrorFromStatus(code, w.message())
+	}
+	if resp != nil {
+		if err = json.Unmarshal(w.body.Bytes(), resp); err != nil {
+			return awsError(http.StatusInternalServerError, "KMSInternalException", "failed to decode response")
+		}
+	}
+	return nil
+}
+
+// awsKeyName returns the KES key name of an AWS KMS key ID, alias,
+// key ARN or alias ARN.
+func awsKeyName(keyID string) (string, error) {
+	name := keyID
+	if strings.HasPrefix(name, "arn:") {
+		parts := strings.SplitN(name, ":", 6)
+		if len(parts) != 6 || parts[2] != "kms" {
+			return "", awsError(http.StatusBadRequest, "NotFoundException", fmt.Sprintf("invalid key ARN '%s'", keyID))
+		}
+		name = strings.TrimPrefix(parts[5], "key/")
+	}
+	name = strings.TrimPrefix(name, "alias/")
+	if !validName(name) {
+		return "", awsError(http.StatusBadRequest, "NotFoundException", fmt.Sprintf("invalid key ID '%s'", keyID))
+	}
+	return name, nil
+}
+
+// awsKeyARN returns the AWS KMS key ARN of the KES key.
+func awsKeyARN(region, name string) string {
+	return "arn:aws:kms:" + region + ":" + awsKMSAccountID + ":key/" + name
+}
+
+// awsEncryptionContext returns the AWS KMS encryption context
+// as associated data. JSON-encoding a map sorts its keys. Hence,
+// the associated data does not depend on the order of the keys.
+func awsEncryptionContext(encryptionContext map[string]string) ([]byte, error) {
+	if len(encryptionContext) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(encryptionContext)
+	if err != nil {
+		return nil, awsError(http.StatusBadRequest, "ValidationException", "invalid encryption context")
+	}
+	return b, nil
+}
+
+// awsCiphertextBlob returns an AWS KMS ciphertext blob. Like AWS
+// KMS, it contains the key name such that clients can decrypt it
+// without specifying the key.
+//
+// The blob format is: version (1 byte) | len(name) (1 byte) | name | ciphertext
+func awsCiphertextBlob(name string, ciphertext []byte) []byte {
+	blob := make([]byte, 0, 2+len(name)+len(ciphertext))
+	blob = append(blob, awsKMSBlobVersion, byte(len(name)))
+	blob = append(blob, name...)
+	return append(blob, ciphertext...)
+}
+
+// parseAWSCiphertextBlob parses an AWS KMS ciphertext blob and
+// returns the key name and the KES ciphertext.
+func parseAWSCiphertextBlob(blob []byte) (string, []byte, error) {
+	invalid := awsError(http.StatusBadRequest, "InvalidCiphertextException", "invalid ciphertext blob")
+	if len(blob) < 2 || blob[0] != awsKMSBlobVersion {
+		return "", nil, invalid
+	}
+	n := int(blob[1])
+	if len(blob) < 2+n {
+		return "", nil, invalid
+	}
+	name := string(blob[2 : 2+n])
+	if !validName(name) {
+		return "", nil, invalid
+	}
+	return name, blob[2+n:], nil
+}
+
+// awsKMSError is an AWS KMS API error.
+type awsKMSError struct {
+	Status  int
+	Type    string
+	Message string
+}
+
+func awsError(status int, typ, msg string) *awsKMSError {
+	return &awsKMSError{Status: status, Type: typ, Message: msg}
+}
+
+func (e *awsKMSError) Error() string { return e.Type + ": " + e.Message }
+
+// awsErrorFromStatus returns the AWS KMS error corresponding
+// to a KES API response with the given status code.
+func awsErrorFromStatus(code int, msg string) *awsKMSError {
+	switch code {
+	case http.StatusBadRequest:
+		return awsError(http.StatusBadRequest, "ValidationException", msg)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return awsError(http.StatusBadRequest, "AccessDeniedException", msg)
+	case http.StatusNotFound:
+		return awsError(http.StatusBadRequest, "NotFoundException", msg)
+	case http.StatusTooManyRequests:
+		return awsError(http.StatusBadRequest, "ThrottlingException", msg)
+	case http.StatusGatewayTimeout:
+		return awsError(http.StatusServiceUnavailable, "DependencyTimeoutException", msg)
+	default:
+		return awsError(http.StatusInternalServerError, "KMSInternalException", msg)
+	}
+}
+
+// writeAWSError writes err as AWS KMS JSON error response.
+func writeAWSError(w http.ResponseWriter, err error) {
+	var aErr *awsKMSError
+	if !errors.As(err, &aErr) {
+		aErr = awsError(http.StatusInternalServerError, "KMSInternalException", err.Error())
+	}
+	w.Header().Set(headers.ContentType, "application/x-amz-json-1.1")
+	w.WriteHeader(aErr.Status)
+	json.NewEncoder(w).Encode(struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}{
+		Type:    aErr.Type,
+		Message: aErr.Message,
+	})
+}
+
+// awsIdentity returns the identity of an AWS KMS request
+// authenticated via its AWS signature, if any.
+func awsIdentity(req *http.Request) (kes.Identity, bool) {
+	identity, ok := req.Context().Value(awsIdentityKey{}).(kes.Identity)
+	return identity, ok
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/sigv4"
+)
+
+func TestAWSKMS(t *testing.T) {
+	t.Parallel()
+
+	const (
+		Region    = "us-east-1"
+		AccessKey = "AKIDEXAMPLE"
+		SecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+		KeyName   = "my-key"
+	)
+
+	ln := newLocalListener()
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{
+		AWSKMS: &AWSKMSConfig{
+			Address: addr,
+			Region:  Region,
+			Credentials: map[string]AWSCredential{
+				AccessKey:  {SecretKey: SecretKey, Identity: defaultIdentity},
+				"AKIDAPP1": {SecretKey: SecretKey, Identity: "my-app"},
+			},
+		},
+	})
+	defer srv.Close()
+
+	if err := defaultClient(url).CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", KeyName, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: defaultClientTLSConfig()}}
+	call := func(accessKey, operation string, body, resp any) (int, string) {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+addr+"/", bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+operation)
+		sigv4.Sign(req, b, sigv4.Credentials{AccessKey: accessKey, SecretKey: SecretKey}, Region, "kms", time.Now())
+
+		var res *http.Response
+		for i := 0; i < 100; i++ { // The AWS KMS listener is started asynchronously
+			if res, err = client.Do(req); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+			req.Body, _ = req.GetBody()
+		}
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			var e struct {
+				Type string `json:"__type"`
+			}
+			json.NewDecoder(res.Body).Decode(&e)
+			return res.StatusCode, e.Type
+		}
+		if err = json.NewDecoder(res.Body).Decode(resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return res.StatusCode, ""
+	}
+
+	var dataKey struct {
+		CiphertextBlob []byte
+		Plaintext      []byte
+		KeyID          string `json:"KeyId"`
+	}
+	if status, typ := call(AccessKey, "GenerateDataKey", map[string]any{
+		"KeyId":             "alias/" + KeyName,
+		"KeySpec":           "AES_256",
+		"EncryptionContext": map[string]string{"bucket": "my-bucket"},
+	}, &dataKey); status != http.StatusOK {
+		t.Fatalf("Failed to generate data key: %d %s", status, typ)
+	}
+	if len(dataKey.Plaintext) != 32 {
+		t.Fatalf("Invalid data key length: got '%d' - want '%d'", len(dataKey.Plaintext), 32)
+	}
+	if want := awsKeyARN(Region, KeyName); dataKey.KeyID != want {
+		t.Fatalf("Invalid key ID: got '%s' - want '%s'", dataKey.KeyID, want)
+	}
+
+	var decrypt struct {
+		Plaintext []byte
+	}
+	if status, typ := call(AccessKey, "Decrypt", map[string]any{
+		"CiphertextBlob":    dataKey.CiphertextBlob,
+		"EncryptionContext": map[string]string{"bucket": "my-bucket"},
+	}, &decrypt); status != http.StatusOK {
+		t.Fatalf("Failed to decrypt data key: %d %s", status, typ)
+	}
+	if !bytes.Equal(decrypt.Plaintext, dataKey.Plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%x' - want '%x'", decrypt.Plaintext, dataKey.Plaintext)
+	}
+
+	var encrypt struct {
+		CiphertextBlob []byte
+	}
+	if status, typ := call(AccessKey, "Encrypt", map[string]any{
+		"KeyId":     awsKeyARN(Region, KeyName),
+		"Plaintext": []byte("Hello World"),
+	}, &encrypt); status != http.StatusOK {
+		t.Fatalf("Failed to encrypt plaintext: %d %s", status, typ)
+	}
+
+	var describe struct {
+		KeyMetadata struct {
+			KeyID string `json:"KeyId"`
+		}
+	}
+	if status, typ := call(AccessKey, "DescribeKey", map[string]any{"KeyId": KeyName}, &describe); status != http.StatusOK {
+		t.Fatalf("Failed to describe key: %d %s", status, typ)
+	}
+	if describe.KeyMetadata.KeyID != KeyName {
+		t.Fatalf("Invalid key ID: got '%s' - want '%s'", describe.KeyMetadata.KeyID, KeyName)
+	}
+
+	for i, test := range []struct {
+		AccessKey string
+		Operation string
+		Body      any
+		Type      string
+	}{
+		{AccessKey: AccessKey, Operation: "Decrypt", Body: map[string]any{"CiphertextBlob": encrypt.CiphertextBlob, "EncryptionContext": map[string]string{"a": "b"}}, Type: "InvalidCiphertextException"},
+		{AccessKey: AccessKey, Operation: "Decrypt", Body: map[string]any{"CiphertextBlob": encrypt.CiphertextBlob, "KeyId": "other-key"}, Type: "IncorrectKeyException"},
+		{AccessKey: AccessKey, Operation: "Decrypt", Body: map[string]any{"CiphertextBlob": []byte("invalid")}, Type: "InvalidCiphertextException"},
+		{AccessKey: AccessKey, Operation: "DescribeKey", Body: map[string]any{"KeyId": "non-existing"}, Type: "NotFoundException"},
+		{AccessKey: AccessKey, Operation: "GenerateDataKey", Body: map[string]any{"KeyId": KeyName, "KeySpec": "AES_128"}, Type: "ValidationException"},
+		{AccessKey: AccessKey, Operation: "CreateKey", Body: map[string]any{}, Type: "UnknownOperationException"},
+		{AccessKey: "AKIDAPP1", Operation: "DescribeKey", Body: map[string]any{"KeyId": KeyName}, Type: "AccessDeniedException"},
+		{AccessKey: "AKIDUNKNOWN", Operation: "DescribeKey", Body: map[string]any{"KeyId": KeyName}, Type: "InvalidSignatureException"},
+	} {
+		if _, typ := call(test.AccessKey, test.Operation, test.Body, nil); typ != test.Type {
+			t.Fatalf("Test %d: got error type '%s' - want '%s'", i, typ, test.Type)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// CAConfig is a structure containing the configuration of
+// a certificate authority (CA) profile.
+//
+// A CA profile issues short-lived X.509 certificates for
+// certificate requests (CSRs) signed by a KES key. The CA
+// certificate is self-signed by the same key. Each key version
+// has its own CA certificate such that certificates issued
+// before a key rotation remain verifiable.
+//
+// Certificates are only issued if all requested DNS names
+// and IP addresses are allowed by the profile.
+type CAConfig struct {
+	// Key is the name of the Ed25519 or ECDSA-P256 key,
+	// of the default enclave, that signs certificates.
+	Key string
+
+	// Subject is the common name of the CA certificate.
+	// If empty, defaults to the profile name.
+	Subject string
+
+	// DNSNames are the DNS names certificates may be issued
+	// for. A name may start with a "*." wildcard that matches
+	// exactly one DNS label, e.g. "*.svc.cluster.local".
+	DNSNames []string
+
+	// IPRanges are the IP address ranges certificates may be
+	// issued for.
+	IPRanges []netip.Prefix
+
+	// MaxTTL is the max. validity period of issued certificates.
+	// It is also the validity period of certificates requested
+	// without a TTL. If 0, it defaults to 24 hours.
+	MaxTTL time.Duration
+}
+
+const (
+	// defaultCAMaxTTL is the default max. validity period of
+	// certificates issued by a CA profile.
+	defaultCAMaxTTL = 24 * time.Hour
+
+	// caValidity is the validity period of CA certificates,
+	// starting when the key version has been created.
+	caValidity = 10 * 365 * 24 * time.Hour
+)
+
+// caCertificate is a HandlerFunc that returns the CA certificates of
+// a CA profile. It returns the CA certificate of the latest key version
+// and a bundle with the CA certificates of all key versions, that
+// clients should trust to verify certificates issued before a key
+// rotation.
+func (s *Server) caCertificate(resp *api.Response, req *api.Request) {
+	profile, ok := s.state.Load().CA[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "CA profile '%s' does not exist", req.Resource)
+		return
+	}
+	// Publishing CA certificates does not count as using the key.
+	key, err := s.state.Load().Keys.get(req.Context(), profile.Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+
+	var certificate, bundle []byte
+	for _, version := range key.Versions {
+		if !version.Key.Type().IsSigning() {
+			resp.Failr(crypto.ErrNoSigning)
+			return
+		}
+		ca, err := caCertificate(req.Resource, profile, version)
+		if err != nil {
+			s.fail(resp, req, err, http.StatusInternalServerError, "failed to create CA certificate")
+			return
+		}
+		certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+		bundle = append(bundle, certificate...)
+	}
+	api.ReplyWith(resp, http.StatusOK, api.CACertificateResponse{
+		Certificate: string(certificate),
+		Bundle:      string(bundle),
+	})
+}
+
+// caProfileKey returns the name of the key of the
+// CA profile with the given name, if it exists.
+func caProfileKey(state *serverState, name string) (string, bool) {
+	profile, ok := state.CA[name]
+	return profile.Key, ok
+}
+
+// caSign is a HandlerFunc that issues a certificate for a certificate
+// request (CSR) signed by the latest version of the CA profile's key.
+// The key must be allowed to sign.
+//
+// The CSR must only contain DNS names and IP addresses allowed by the
+// profile. A non-empty CSR common name must be an allowed DNS name.
+// Issued certificates are valid for TLS server and client authentication.
+// Their serial numbers are audited.
+func (s *Server) caSign(resp *api.Response, req *api.Request) {
+	profile, ok := s.state.Load().CA[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "CA profile '%s' does not exist", req.Resource)
+		return
+	}
+
+	var body api.SignCertificateRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	maxTTL := profile.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = defaultCAMaxTTL
+	}
+	ttl := maxTTL
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			resp.Failf(http.StatusBadRequest, "certificate TTL '%s' is invalid", body.TTL)
+			return
+		}
+		if ttl > maxTTL {
+			resp.Failf(http.StatusBadRequest, "certificate TTL '%v' exceeds max. TTL '%v'", ttl, maxTTL)
+			return
+		}
+	}
+
+	block, _ := pem.Decode([]byte(body.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		resp.Fail(http.StatusBadRequest, "invalid certificate request: not a PEM encoded CSR")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid certificate request: %v", err)
+		return
+	}
+	if err = csr.CheckSignature(); err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid certificate request: invalid signature")
+		return
+	}
+	if err = verifyCSR(profile, csr); err != nil {
+		resp.Failf(http.StatusForbidden, "certificate request not allowed: %v", err)
+		return
+	}
+
+	key, err := s.state.Load().Keys.Get(req.Context(), profile.Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	latest := key.Latest()
+	signer, err := latest.Key.Signer()
+	if err != nil {
+		resp.Failr(crypto.ErrNoSigning)
+		return
+	}
+	ca, err := caCertificate(req.Resource, profile, latest)
+	if err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to create CA certificate")
+		return
+	}
+
+	serial := make([]byte, 16)
+	if _, err = rand.Read(serial); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to generate serial number")
+		return
+	}
+	serial[0] &= 0x7f // Serial numbers must be positive
+
+	now := time.Now().UTC().Truncate(time.Second)
+	notAfter := now.Add(ttl)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+	keyUsage := x509.KeyUsageDigitalSignature
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(serial),
+		Subject:               pkix.Name{CommonName: csr.Subject.CommonName},
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		NotBefore:             now,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, ca, csr.PublicKey, signer)
+	if err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to issue certificate")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("certificate '%x' issued by CA profile '%s'", serial, req.Resource),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.SignCertificateResponse{
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		CA:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+		Serial:      hex.EncodeToString(serial),
+		ExpiresAt:   notAfter,
+	})
+}
+
+// caCertificate returns the self-signed CA certificate of the
+// CA profile for the given key version.
+//
+// The certificate is derived from the key version. Its serial
+// number is computed from the public key and its validity period
+// starts when the key version has been created. Hence, the same
+// key version always has the same CA certificate, up to the
+// signature for non-deterministic signature schemes.
+func caCertificate(name string, profile CAConfig, version crypto.KeyVersion) (*x509.Certificate, error) {
+	signer, err := version.Key.Signer()
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := version.Key.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(publicKey)
+	serial := sum[:16]
+	serial[0] &= 0x7f // Serial numbers must be positive
+
+	subject := profile.Subject
+	if subject == "" {
+		subject = name
+	}
+	notBefore := version.CreatedAt.UTC().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(serial),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(raw)
+}
+
+// verifyCSR returns an error if the certificate request
+// contains subject names not allowed by the CA profile.
+func verifyCSR(profile CAConfig, csr *x509.CertificateRequest) error {
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return fmt.Errorf("email addresses and URIs are not supported")
+	}
+	if len(csr.DNSNames) == 0 && len(csr.IPAddresses) == 0 && csr.Subject.CommonName == "" {
+		return fmt.Errorf("no DNS names or IP addresses")
+	}
+
+	names := csr.DNSNames
+	if csr.Subject.CommonName != "" {
+		names = append([]string{csr.Subject.CommonName}, names...)
+	}
+	for _, name := range names {
+		allowed := false
+		for _, pattern := range profile.DNSNames {
+			if matchDNSName(pattern, name) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("DNS name '%s' is not allowed", name)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			return fmt.Errorf("IP address '%v' is invalid", ip)
+		}
+		addr = addr.Unmap()
+
+		allowed := false
+		for _, prefix := range profile.IPRanges {
+			if prefix.Contains(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("IP address '%v' is not allowed", addr)
+		}
+	}
+	return nil
+}
+
+// matchDNSName reports whether the DNS name matches the
+// pattern. A pattern starting with "*." matches any name
+// with exactly one additional DNS label.
+func matchDNSName(pattern, name string) bool {
+	pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		label, ok := strings.CutSuffix(name, suffix)
+		return ok && label != "" && label != "*" && !strings.Contains(label, ".")
+	}
+	return pattern == name
+}
@@ -0,0 +1,26 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package v1alpha1 contains the kes.min.io/v1alpha1 API types
+// managed by the KES operator.
+//
+// +kubebuilder:object:generate=true
+// +groupName=kes.min.io
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group and version of the KES operator API.
+	GroupVersion = schema.GroupVersion{Group: "kes.min.io", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the KES operator API types.
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the KES operator API types to a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
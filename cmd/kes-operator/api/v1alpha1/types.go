@@ -0,0 +1,316 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionReady is the condition type reported by all
+// KES operator resources once they have been reconciled.
+const ConditionReady = "Ready"
+
+// DeletionPolicy controls what happens to a key at the
+// KES server when the corresponding KESKey is deleted.
+//
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the key at the KES server.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+
+	// DeletionPolicyDelete deletes the key from the KES server.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// KESServerSpec is the desired state of a KES server deployment.
+type KESServerSpec struct {
+	// Image is the KES container image.
+	//
+	// +kubebuilder:default="minio/kes:latest"
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Replicas is the number of KES server pods.
+	//
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// TLS configures the server certificate.
+	//
+	// +optional
+	TLS TLSSpec `json:"tls,omitempty"`
+
+	// KeyStore is the keystore section of the KES config file,
+	// for example {"vault": {"endpoint": "https://vault:8200", ...}}.
+	// Credentials should not be placed here but passed through
+	// Env and referenced as ${VAR} within the keystore config.
+	//
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	KeyStore *apiextensionsv1.JSON `json:"keystore"`
+
+	// Env are additional environment variables of the KES
+	// container, usually references to keystore credentials.
+	//
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Resources are the compute resources of the KES container.
+	//
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// TLSSpec configures the TLS certificate of a KES server.
+type TLSSpec struct {
+	// SecretName is the name of a kubernetes.io/tls Secret
+	// containing the server certificate, e.g. one issued by
+	// cert-manager. The Secret should contain the issuing CA
+	// as ca.crt such that the operator can verify the server.
+	//
+	// If empty, the operator issues a self-signed certificate
+	// and stores it in the Secret <name>-tls.
+	//
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// KESServerStatus is the observed state of a KES server deployment.
+type KESServerStatus struct {
+	// ObservedGeneration is the generation last reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Endpoint is the in-cluster URL of the KES server.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// AdminSecret is the name of the Secret containing the
+	// admin API key used by the operator.
+	AdminSecret string `json:"adminSecret,omitempty"`
+
+	// TLSSecret is the name of the Secret containing the
+	// server certificate.
+	TLSSecret string `json:"tlsSecret,omitempty"`
+
+	// ConfigHash is the hash of the rendered KES config file.
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// Policies are the names of the KESPolicies applied
+	// to the server config.
+	Policies []string `json:"policies,omitempty"`
+
+	// ReadyReplicas is the number of ready KES server pods.
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Conditions describe the current state of the server.
+	//
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// KESServer is a KES server deployment managed by the operator.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.status.endpoint`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type KESServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KESServerSpec   `json:"spec"`
+	Status KESServerStatus `json:"status,omitempty"`
+}
+
+// KESServerList is a list of KESServers.
+//
+// +kubebuilder:object:root=true
+type KESServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KESServer `json:"items"`
+}
+
+// KESKeySpec is the desired state of a key at a KES server.
+type KESKeySpec struct {
+	// ServerRef is the KESServer, in the same namespace,
+	// that holds the key.
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+
+	// Name is the name of the key at the KES server.
+	// Defaults to the name of the KESKey.
+	//
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="name is immutable"
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Cipher is the key algorithm. If empty, the server
+	// chooses the algorithm.
+	//
+	// +kubebuilder:validation:Enum=AES256;ChaCha20;AES256-SIV;Ed25519;ECDSA-P256;ECIES-X25519;ECIES-P256
+	// +optional
+	Cipher string `json:"cipher,omitempty"`
+
+	// Tags are the key tags. Tags are set when the key
+	// is created and cannot be changed afterwards.
+	//
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Operations restrict the operations the key can be
+	// used for. If empty, all operations are allowed.
+	// Operations are set when the key is created and
+	// cannot be changed afterwards.
+	//
+	// +optional
+	Operations []string `json:"operations,omitempty"`
+
+	// Protected enables deletion protection for the key.
+	//
+	// +optional
+	Protected bool `json:"protected,omitempty"`
+
+	// DeletionPolicy controls whether the key is deleted
+	// from the KES server once the KESKey is deleted.
+	//
+	// +kubebuilder:default=Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// KESKeyStatus is the observed state of a key at a KES server.
+type KESKeyStatus struct {
+	// ObservedGeneration is the generation last reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Algorithm is the key algorithm.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Versions is the number of key versions.
+	Versions int `json:"versions,omitempty"`
+
+	// CreatedAt is the point in time when the key was created.
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+
+	// Conditions describe the current state of the key.
+	//
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// KESKey is a key at a KES server.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.serverRef.name`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Algorithm",type=string,JSONPath=`.status.algorithm`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type KESKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KESKeySpec   `json:"spec"`
+	Status KESKeyStatus `json:"status,omitempty"`
+}
+
+// KeyName returns the name of the key at the KES server.
+func (k *KESKey) KeyName() string {
+	if k.Spec.Name != "" {
+		return k.Spec.Name
+	}
+	return k.Name
+}
+
+// KESKeyList is a list of KESKeys.
+//
+// +kubebuilder:object:root=true
+type KESKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KESKey `json:"items"`
+}
+
+// KESPolicySpec is a KES policy applied to a KES server.
+// The policy name within the server config is the name
+// of the KESPolicy.
+type KESPolicySpec struct {
+	// ServerRef is the KESServer, in the same namespace,
+	// the policy is applied to.
+	ServerRef corev1.LocalObjectReference `json:"serverRef"`
+
+	// Allow is the list of API paths the policy allows,
+	// e.g. /v1/key/generate/my-app-*.
+	//
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny is the list of API paths the policy denies.
+	// Deny rules take precedence over allow rules.
+	//
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+
+	// Identities are the identities, i.e. hashes of client
+	// certificate public keys, the policy is assigned to.
+	//
+	// +optional
+	Identities []string `json:"identities,omitempty"`
+}
+
+// KESPolicyStatus is the observed state of a KES policy.
+type KESPolicyStatus struct {
+	// ObservedGeneration is the generation last applied
+	// to the server config.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the policy.
+	//
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// KESPolicy is a KES policy applied to a KES server.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type=string,JSONPath=`.spec.serverRef.name`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type KESPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KESPolicySpec   `json:"spec"`
+	Status KESPolicyStatus `json:"status,omitempty"`
+}
+
+// KESPolicyList is a list of KESPolicies.
+//
+// +kubebuilder:object:root=true
+type KESPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KESPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(
+		&KESServer{}, &KESServerList{},
+		&KESKey{}, &KESKeyList{},
+		&KESPolicy{}, &KESPolicyList{},
+	)
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// kesClient is a minimal client for the KES key API.
+//
+// The operator does not depend on the KES server module.
+// Hence, the request and response types are defined here
+// and must match the KES API.
+type kesClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+// kesError is an error response returned by a KES server.
+type kesError struct {
+	Code    int
+	Message string
+}
+
+func (e *kesError) Error() string {
+	return fmt.Sprintf("kes: %s (%d)", e.Message, e.Code)
+}
+
+// isStatus reports whether err is a KES error response with
+// the given HTTP status code.
+func isStatus(err error, code int) bool {
+	var e *kesError
+	return errors.As(err, &e) && e.Code == code
+}
+
+type createKeyRequest struct {
+	Cipher     string            `json:"cipher,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+}
+
+type describeKeyResponse struct {
+	Name       string            `json:"name"`
+	Algorithm  string            `json:"algorithm,omitempty"`
+	CreatedAt  time.Time         `json:"created_at,omitempty"`
+	Versions   int               `json:"versions,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Protected  bool              `json:"protected,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+}
+
+// newKESClient returns a client that authenticates with the
+// given API key and verifies the server certificate using
+// the given root CAs.
+func newKESClient(endpoint string, apiKey kes.APIKey, rootCAs *x509.CertPool) (*kesClient, error) {
+	cert, err := kes.GenerateCertificate(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+	}
+	return &kesClient{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}, nil
+}
+
+// DescribeKey returns metadata about the key.
+func (c *kesClient) DescribeKey(ctx context.Context, name string) (*describeKeyResponse, error) {
+	var resp describeKeyResponse
+	if err := c.send(ctx, http.MethodGet, "/v1/key/describe/"+url.PathEscape(name), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateKey creates a new key.
+func (c *kesClient) CreateKey(ctx context.Context, name string, req *createKeyRequest) error {
+	return c.send(ctx, http.MethodPut, "/v1/key/create/"+url.PathEscape(name), req, nil)
+}
+
+// DeleteKey deletes the key.
+func (c *kesClient) DeleteKey(ctx context.Context, name string) error {
+	return c.send(ctx, http.MethodDelete, "/v1/key/delete/"+url.PathEscape(name), nil, nil)
+}
+
+// SetProtected enables or disables deletion protection for the key.
+func (c *kesClient) SetProtected(ctx context.Context, name string, protected bool) error {
+	path := "/v1/key/unprotect/"
+	if protected {
+		path = "/v1/key/protect/"
+	}
+	return c.send(ctx, http.MethodPut, path+url.PathEscape(name), nil, nil)
+}
+
+func (c *kesClient) send(ctx context.Context, method, path string, body, v any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, r)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	const MaxSize = 1 << 20
+	if resp.StatusCode/100 != 2 {
+		var response struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, MaxSize)).Decode(&response); err != nil || response.Message == "" {
+			response.Message = http.StatusText(resp.StatusCode)
+		}
+		return &kesError{Code: resp.StatusCode, Message: response.Message}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, MaxSize)).Decode(v)
+}
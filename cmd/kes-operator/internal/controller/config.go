@@ -0,0 +1,173 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/minio/kes/cmd/kes-operator/api/v1alpha1"
+)
+
+const (
+	// kesPort is the port the KES server listens on.
+	kesPort = 7373
+
+	configDir  = "/etc/kes/config"
+	configFile = "config.yaml"
+	tlsDir     = "/etc/kes/tls"
+)
+
+type serverConfig struct {
+	Version  string                  `json:"version"`
+	Address  string                  `json:"address"`
+	Admin    adminConfig             `json:"admin"`
+	TLS      tlsConfig               `json:"tls"`
+	API      map[string]apiConfig    `json:"api,omitempty"`
+	Policy   map[string]policyConfig `json:"policy,omitempty"`
+	KeyStore json.RawMessage         `json:"keystore"`
+}
+
+type adminConfig struct {
+	Identity string `json:"identity"`
+}
+
+type tlsConfig struct {
+	Key  string `json:"key"`
+	Cert string `json:"cert"`
+}
+
+type apiConfig struct {
+	SkipAuth bool `json:"skip_auth"`
+}
+
+type policyConfig struct {
+	Allow      []string `json:"allow,omitempty"`
+	Deny       []string `json:"deny,omitempty"`
+	Identities []string `json:"identities,omitempty"`
+}
+
+// renderConfig renders the KES config file of the server
+// with the given admin identity and policies. It returns
+// the config file and its hash.
+//
+// The readiness API does not require authentication such
+// that the kubelet can probe the server without a client
+// certificate.
+func renderConfig(server *v1alpha1.KESServer, admin string, policies []v1alpha1.KESPolicy) ([]byte, string, error) {
+	if server.Spec.KeyStore == nil || len(server.Spec.KeyStore.Raw) == 0 {
+		return nil, "", errors.New("no keystore specified")
+	}
+
+	config := serverConfig{
+		Version: "v1",
+		Address: "0.0.0.0:" + strconv.Itoa(kesPort),
+		Admin:   adminConfig{Identity: admin},
+		TLS: tlsConfig{
+			Key:  tlsDir + "/tls.key",
+			Cert: tlsDir + "/tls.crt",
+		},
+		API: map[string]apiConfig{
+			"/v1/ready": {SkipAuth: true},
+		},
+		KeyStore: server.Spec.KeyStore.Raw,
+	}
+	if len(policies) > 0 {
+		config.Policy = make(map[string]policyConfig, len(policies))
+		for _, p := range policies {
+			config.Policy[p.Name] = policyConfig{
+				Allow:      p.Spec.Allow,
+				Deny:       p.Spec.Deny,
+				Identities: p.Spec.Identities,
+			}
+		}
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, "", err
+	}
+	if b, err = yaml.JSONToYAML(b); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(b)
+	return b, hex.EncodeToString(sum[:]), nil
+}
+
+// certificateRenewal is the remaining validity below
+// which self-signed server certificates are renewed.
+const certificateRenewal = 30 * 24 * time.Hour
+
+// issueCertificate issues a self-signed ECDSA P-256 server
+// certificate for the given DNS names, valid for one year.
+// It returns the PEM-encoded certificate and private key.
+func issueCertificate(dnsNames []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:              dnsNames,
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	return certPEM, keyPEM, nil
+}
+
+// needsRenewal reports whether the PEM-encoded certificate
+// is missing, invalid, expires soon or does not cover all
+// given DNS names.
+func needsRenewal(certPEM []byte, dnsNames []string, now time.Time) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	if now.Add(certificateRenewal).After(cert.NotAfter) {
+		return true
+	}
+	for _, name := range dnsNames {
+		if cert.VerifyHostname(name) != nil {
+			return true
+		}
+	}
+	return false
+}
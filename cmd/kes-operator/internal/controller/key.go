@@ -0,0 +1,265 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	"github.com/minio/kes/cmd/kes-operator/api/v1alpha1"
+)
+
+// keyFinalizer is added to KESKeys with the Delete deletion policy
+// such that the key gets deleted from the KES server first.
+const keyFinalizer = "kes.min.io/delete-key"
+
+// retryInterval is the interval in which keys are reconciled
+// again while their KES server is not available.
+const retryInterval = 30 * time.Second
+
+// KESKeyReconciler reconciles KESKeys against the KES server they
+// refer to, using the admin API key of the server.
+//
+// Keys are created if they do not exist and their deletion
+// protection is kept in sync. The cipher, tags and operations
+// of a key cannot be changed once it has been created. Hence,
+// any difference is reported as Drifted but not corrected.
+type KESKeyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Endpoint returns the URL of the KES server. If nil, the
+	// in-cluster Service endpoint is used. It can be set when
+	// the operator does not run within the cluster.
+	Endpoint func(*v1alpha1.KESServer) string
+}
+
+// +kubebuilder:rbac:groups=kes.min.io,resources=keskeys,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=kes.min.io,resources=keskeys/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kes.min.io,resources=keskeys/finalizers,verbs=update
+// +kubebuilder:rbac:groups=kes.min.io,resources=kesservers,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile reconciles the KESKey referenced by the request.
+func (r *KESKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var key v1alpha1.KESKey
+	if err := r.Get(ctx, req.NamespacedName, &key); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	deleting := !key.DeletionTimestamp.IsZero()
+	if deleting && !controllerutil.ContainsFinalizer(&key, keyFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	if !deleting {
+		var updated bool
+		if key.Spec.DeletionPolicy == v1alpha1.DeletionPolicyDelete {
+			updated = controllerutil.AddFinalizer(&key, keyFinalizer)
+		} else {
+			updated = controllerutil.RemoveFinalizer(&key, keyFinalizer)
+		}
+		if updated {
+			if err := r.Update(ctx, &key); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	var server v1alpha1.KESServer
+	if err := r.Get(ctx, client.ObjectKey{Namespace: key.Namespace, Name: key.Spec.ServerRef.Name}, &server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if deleting {
+			// Without a server there is no key to delete.
+			controllerutil.RemoveFinalizer(&key, keyFinalizer)
+			return ctrl.Result{}, r.Update(ctx, &key)
+		}
+		msg := fmt.Sprintf("KESServer '%s' not found", key.Spec.ServerRef.Name)
+		return ctrl.Result{RequeueAfter: retryInterval}, r.setStatus(ctx, &key, metav1.ConditionFalse, "ServerNotFound", msg)
+	}
+
+	kc, err := r.kesClient(ctx, &server)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: retryInterval}, r.setStatus(ctx, &key, metav1.ConditionFalse, "ServerUnavailable", err.Error())
+	}
+	defer kc.client.CloseIdleConnections()
+
+	if deleting {
+		return r.delete(ctx, kc, &key)
+	}
+
+	name := key.KeyName()
+	info, err := kc.DescribeKey(ctx, name)
+	if isStatus(err, http.StatusNotFound) {
+		err = kc.CreateKey(ctx, name, &createKeyRequest{
+			Cipher:     key.Spec.Cipher,
+			Tags:       key.Spec.Tags,
+			Operations: key.Spec.Operations,
+		})
+		if err != nil && !isStatus(err, http.StatusConflict) {
+			return ctrl.Result{}, r.fail(ctx, &key, "CreateFailed", err)
+		}
+		info, err = kc.DescribeKey(ctx, name)
+	}
+	if err != nil {
+		return ctrl.Result{}, r.fail(ctx, &key, "DescribeFailed", err)
+	}
+	if info.Protected != key.Spec.Protected {
+		if err = kc.SetProtected(ctx, name, key.Spec.Protected); err != nil {
+			return ctrl.Result{}, r.fail(ctx, &key, "ProtectFailed", err)
+		}
+		info.Protected = key.Spec.Protected
+	}
+
+	key.Status.Algorithm = info.Algorithm
+	key.Status.Versions = info.Versions
+	key.Status.CreatedAt = nil
+	if !info.CreatedAt.IsZero() {
+		key.Status.CreatedAt = &metav1.Time{Time: info.CreatedAt}
+	}
+	if drift := keyDrift(&key, info); drift != "" {
+		return ctrl.Result{}, r.setStatus(ctx, &key, metav1.ConditionFalse, "Drifted", drift)
+	}
+	return ctrl.Result{}, r.setStatus(ctx, &key, metav1.ConditionTrue, "Reconciled", fmt.Sprintf("key '%s' is in sync", name))
+}
+
+// SetupWithManager registers the reconciler with the manager.
+func (r *KESKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.KESKey{}).
+		Watches(&v1alpha1.KESServer{}, handler.EnqueueRequestsFromMapFunc(r.mapServerToKeys)).
+		Complete(r)
+}
+
+// delete deletes the key from the KES server and removes the
+// finalizer. A key that is protected at the KES server is only
+// unprotected and deleted if the KESKey is not protected. Otherwise,
+// the deletion is blocked until the KESKey is no longer protected.
+func (r *KESKeyReconciler) delete(ctx context.Context, kc *kesClient, key *v1alpha1.KESKey) (ctrl.Result, error) {
+	name := key.KeyName()
+	info, err := kc.DescribeKey(ctx, name)
+	switch {
+	case isStatus(err, http.StatusNotFound):
+	case err != nil:
+		return ctrl.Result{}, r.fail(ctx, key, "DeleteFailed", err)
+	case info.Protected && key.Spec.Protected:
+		msg := fmt.Sprintf("key '%s' is protected; set spec.protected to false to delete it", name)
+		return ctrl.Result{}, r.setStatus(ctx, key, metav1.ConditionFalse, "DeletionBlocked", msg)
+	default:
+		if info.Protected {
+			if err = kc.SetProtected(ctx, name, false); err != nil {
+				return ctrl.Result{}, r.fail(ctx, key, "DeleteFailed", err)
+			}
+		}
+		if err = kc.DeleteKey(ctx, name); err != nil && !isStatus(err, http.StatusNotFound) {
+			return ctrl.Result{}, r.fail(ctx, key, "DeleteFailed", err)
+		}
+	}
+	controllerutil.RemoveFinalizer(key, keyFinalizer)
+	return ctrl.Result{}, r.Update(ctx, key)
+}
+
+// kesClient returns a client for the KES server that authenticates
+// with the admin API key of the server. The server certificate is
+// verified with the CA of the server's TLS Secret, or the system
+// root CAs if the Secret contains no CA.
+func (r *KESKeyReconciler) kesClient(ctx context.Context, server *v1alpha1.KESServer) (*kesClient, error) {
+	var admin corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: server.Namespace, Name: adminSecretName(server)}, &admin); err != nil {
+		return nil, err
+	}
+	apiKey, err := kes.ParseAPIKey(string(admin.Data[apiKeyField]))
+	if err != nil {
+		return nil, fmt.Errorf("secret '%s' contains no valid API key: %v", admin.Name, err)
+	}
+
+	var secret corev1.Secret
+	if err = r.Get(ctx, client.ObjectKey{Namespace: server.Namespace, Name: tlsSecretName(server)}, &secret); err != nil {
+		return nil, err
+	}
+	var rootCAs *x509.CertPool
+	if ca := secret.Data[caField]; len(ca) > 0 {
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("secret '%s' contains no valid CA certificate", secret.Name)
+		}
+	}
+
+	endpoint := serverEndpoint(server)
+	if r.Endpoint != nil {
+		endpoint = r.Endpoint(server)
+	}
+	return newKESClient(endpoint, apiKey, rootCAs)
+}
+
+// setStatus sets the Ready condition of the key and updates its status.
+func (r *KESKeyReconciler) setStatus(ctx context.Context, key *v1alpha1.KESKey, status metav1.ConditionStatus, reason, message string) error {
+	key.Status.ObservedGeneration = key.Generation
+	setCondition(&key.Status.Conditions, key.Generation, status, reason, message)
+	return r.Status().Update(ctx, key)
+}
+
+// fail reports the error as Ready condition of the key
+// and returns it such that the request gets retried.
+func (r *KESKeyReconciler) fail(ctx context.Context, key *v1alpha1.KESKey, reason string, err error) error {
+	if uErr := r.setStatus(ctx, key, metav1.ConditionFalse, reason, err.Error()); uErr != nil {
+		return errors.Join(err, uErr)
+	}
+	return err
+}
+
+// mapServerToKeys returns a request for every KESKey referring
+// to the server such that keys are reconciled once the server
+// becomes available.
+func (r *KESKeyReconciler) mapServerToKeys(ctx context.Context, obj client.Object) []ctrl.Request {
+	var list v1alpha1.KESKeyList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list KESKeys")
+		return nil
+	}
+	var requests []ctrl.Request
+	for _, key := range list.Items {
+		if key.Spec.ServerRef.Name == obj.GetName() {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&key)})
+		}
+	}
+	return requests
+}
+
+// keyDrift returns a description of the differences between
+// the KESKey and the key at the KES server that cannot be
+// reconciled, or the empty string if there are none.
+func keyDrift(key *v1alpha1.KESKey, info *describeKeyResponse) string {
+	if key.Spec.Cipher != "" && info.Algorithm != "" && key.Spec.Cipher != info.Algorithm {
+		return fmt.Sprintf("key algorithm is '%s' but '%s' is specified", info.Algorithm, key.Spec.Cipher)
+	}
+	if !maps.Equal(key.Spec.Tags, info.Tags) {
+		return "key tags differ from the specified tags and cannot be changed"
+	}
+	want, have := slices.Clone(key.Spec.Operations), slices.Clone(info.Operations)
+	slices.Sort(want)
+	slices.Sort(have)
+	if !slices.Equal(want, have) {
+		return "key operations differ from the specified operations and cannot be changed"
+	}
+	return ""
+}
@@ -0,0 +1,331 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/minio/kes/cmd/kes-operator/api/v1alpha1"
+)
+
+func TestKeyReconcile(t *testing.T) {
+	ctx := testContext(t)
+	server := newTestServer("kes")
+	key := &v1alpha1.KESKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app-key", Namespace: testNamespace},
+		Spec: v1alpha1.KESKeySpec{
+			ServerRef:      corev1.LocalObjectReference{Name: "kes"},
+			Name:           "my-app",
+			Cipher:         "AES256",
+			Tags:           map[string]string{"env": "prod"},
+			Operations:     []string{"generate", "decrypt"},
+			Protected:      true,
+			DeletionPolicy: v1alpha1.DeletionPolicyDelete,
+		},
+	}
+	c := newFakeClient(t, server, key)
+	kes := newFakeKES(t, ctx, c, server)
+	r := &KESKeyReconciler{Client: c, Scheme: c.Scheme(), Endpoint: func(*v1alpha1.KESServer) string { return kes.URL }}
+
+	reconcile(t, ctx, r, key)
+	get(t, ctx, c, "my-app-key", key)
+	if cond := meta.FindStatusCondition(key.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Fatalf("key is not ready: %+v", cond)
+	}
+	if key.Status.Algorithm != "AES256" || key.Status.Versions != 1 || key.Status.CreatedAt == nil {
+		t.Fatalf("invalid key status: %+v", key.Status)
+	}
+	if !strings.Contains(strings.Join(key.Finalizers, ","), keyFinalizer) {
+		t.Fatalf("finalizer not added: %v", key.Finalizers)
+	}
+	stored := kes.Key("my-app")
+	if stored == nil {
+		t.Fatal("key not created")
+	}
+	if !stored.Protected || stored.Tags["env"] != "prod" || len(stored.Operations) != 2 {
+		t.Fatalf("key created with invalid metadata: %+v", stored)
+	}
+
+	// The key is already in sync. Hence, it must not be created again.
+	reconcile(t, ctx, r, key)
+	if n := kes.Creates(); n != 1 {
+		t.Fatalf("key created %d times", n)
+	}
+
+	// Deleting a protected KESKey must be blocked.
+	get(t, ctx, c, "my-app-key", key)
+	if err := c.Delete(ctx, key); err != nil {
+		t.Fatalf("failed to delete KESKey: %v", err)
+	}
+	reconcile(t, ctx, r, key)
+	get(t, ctx, c, "my-app-key", key)
+	if cond := meta.FindStatusCondition(key.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Reason != "DeletionBlocked" {
+		t.Fatalf("deletion of protected key not blocked: %+v", cond)
+	}
+	if kes.Key("my-app") == nil {
+		t.Fatal("protected key has been deleted")
+	}
+
+	// Once the KESKey is no longer protected, the key gets unprotected and deleted.
+	key.Spec.Protected = false
+	if err := c.Update(ctx, key); err != nil {
+		t.Fatalf("failed to update KESKey: %v", err)
+	}
+	reconcile(t, ctx, r, key)
+	if kes.Key("my-app") != nil {
+		t.Fatal("key has not been deleted")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(key), key); !apierrors.IsNotFound(err) {
+		t.Fatalf("KESKey has not been removed: %v", err)
+	}
+}
+
+func TestKeyReconcileProtection(t *testing.T) {
+	ctx := testContext(t)
+	server := newTestServer("kes")
+	key := &v1alpha1.KESKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-key", Namespace: testNamespace},
+		Spec: v1alpha1.KESKeySpec{
+			ServerRef: corev1.LocalObjectReference{Name: "kes"},
+		},
+	}
+	c := newFakeClient(t, server, key)
+	kes := newFakeKES(t, ctx, c, server)
+	r := &KESKeyReconciler{Client: c, Scheme: c.Scheme(), Endpoint: func(*v1alpha1.KESServer) string { return kes.URL }}
+
+	reconcile(t, ctx, r, key)
+	if stored := kes.Key("my-key"); stored == nil || stored.Protected {
+		t.Fatalf("key not created or protected: %+v", stored)
+	}
+
+	get(t, ctx, c, "my-key", key)
+	key.Spec.Protected = true
+	if err := c.Update(ctx, key); err != nil {
+		t.Fatalf("failed to update KESKey: %v", err)
+	}
+	reconcile(t, ctx, r, key)
+	if stored := kes.Key("my-key"); !stored.Protected {
+		t.Fatal("key has not been protected")
+	}
+
+	get(t, ctx, c, "my-key", key)
+	key.Spec.Protected = false
+	if err := c.Update(ctx, key); err != nil {
+		t.Fatalf("failed to update KESKey: %v", err)
+	}
+	reconcile(t, ctx, r, key)
+	if stored := kes.Key("my-key"); stored.Protected {
+		t.Fatal("key protection has not been removed")
+	}
+
+	// The default deletion policy retains the key.
+	get(t, ctx, c, "my-key", key)
+	if len(key.Finalizers) != 0 {
+		t.Fatalf("finalizer added for retained key: %v", key.Finalizers)
+	}
+	if err := c.Delete(ctx, key); err != nil {
+		t.Fatalf("failed to delete KESKey: %v", err)
+	}
+	if kes.Key("my-key") == nil {
+		t.Fatal("retained key has been deleted")
+	}
+}
+
+func TestKeyReconcileDrift(t *testing.T) {
+	ctx := testContext(t)
+	server := newTestServer("kes")
+	key := &v1alpha1.KESKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-key", Namespace: testNamespace},
+		Spec: v1alpha1.KESKeySpec{
+			ServerRef:  corev1.LocalObjectReference{Name: "kes"},
+			Operations: []string{"encrypt"},
+		},
+	}
+	c := newFakeClient(t, server, key)
+	kes := newFakeKES(t, ctx, c, server)
+	kes.keys["my-key"] = &fakeKey{Algorithm: "AES256", Operations: []string{"decrypt"}}
+	r := &KESKeyReconciler{Client: c, Scheme: c.Scheme(), Endpoint: func(*v1alpha1.KESServer) string { return kes.URL }}
+
+	reconcile(t, ctx, r, key)
+	get(t, ctx, c, "my-key", key)
+	if cond := meta.FindStatusCondition(key.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Reason != "Drifted" {
+		t.Fatalf("drift not reported: %+v", cond)
+	}
+	if ops := kes.Key("my-key").Operations; len(ops) != 1 || ops[0] != "decrypt" {
+		t.Fatalf("existing key has been modified: %v", ops)
+	}
+}
+
+func TestKeyReconcileServerNotFound(t *testing.T) {
+	ctx := testContext(t)
+	key := &v1alpha1.KESKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-key", Namespace: testNamespace},
+		Spec: v1alpha1.KESKeySpec{
+			ServerRef: corev1.LocalObjectReference{Name: "kes"},
+		},
+	}
+	c := newFakeClient(t, key)
+	r := &KESKeyReconciler{Client: c, Scheme: c.Scheme()}
+
+	if result := reconcile(t, ctx, r, key); result.RequeueAfter == 0 {
+		t.Fatal("key without server is not retried")
+	}
+	get(t, ctx, c, "my-key", key)
+	if cond := meta.FindStatusCondition(key.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Reason != "ServerNotFound" {
+		t.Fatalf("missing server not reported: %+v", cond)
+	}
+}
+
+type fakeKey struct {
+	Algorithm  string            `json:"algorithm"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Versions   int               `json:"versions"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Protected  bool              `json:"protected,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+}
+
+// fakeKES is a TLS server implementing the subset of the KES
+// key API used by the operator. It only accepts requests from
+// the admin identity.
+type fakeKES struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	keys    map[string]*fakeKey
+	creates int
+}
+
+// newFakeKES starts a fake KES server for the KESServer. It
+// reconciles the KESServer to create the admin and TLS Secrets
+// and replaces the CA of the TLS Secret with the fake server's
+// certificate.
+func newFakeKES(t *testing.T, ctx context.Context, c client.Client, server *v1alpha1.KESServer) *fakeKES {
+	reconcile(t, ctx, &KESServerReconciler{Client: c, Scheme: c.Scheme()}, server)
+
+	var admin corev1.Secret
+	get(t, ctx, c, adminSecretName(server), &admin)
+	identity := string(admin.Data[identityField])
+
+	kes := &fakeKES{keys: map[string]*fakeKey{}}
+	kes.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			fail(w, http.StatusBadRequest, "no client certificate")
+			return
+		}
+		h := sha256.Sum256(r.TLS.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		if hex.EncodeToString(h[:]) != identity {
+			fail(w, http.StatusForbidden, "not authorized: insufficient permissions")
+			return
+		}
+		kes.serveHTTP(w, r)
+	}))
+	kes.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	kes.StartTLS()
+	t.Cleanup(kes.Close)
+
+	var secret corev1.Secret
+	get(t, ctx, c, tlsSecretName(server), &secret)
+	secret.Data[caField] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: kes.Certificate().Raw})
+	if err := c.Update(ctx, &secret); err != nil {
+		t.Fatalf("failed to update TLS secret: %v", err)
+	}
+	return kes
+}
+
+// Key returns a copy of the key or nil if it does not exist.
+func (k *fakeKES) Key(name string) *fakeKey {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	key, ok := k.keys[name]
+	if !ok {
+		return nil
+	}
+	c := *key
+	return &c
+}
+
+// Creates returns the number of created keys.
+func (k *fakeKES) Creates() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return k.creates
+}
+
+func (k *fakeKES) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	api, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/key/"), "/")
+	key, exists := k.keys[name]
+	switch {
+	case api == "create" && r.Method == http.MethodPut:
+		if exists {
+			fail(w, http.StatusConflict, "key already exists")
+			return
+		}
+		var req createKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fail(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Cipher == "" {
+			req.Cipher = "AES256"
+		}
+		k.keys[name] = &fakeKey{
+			Algorithm:  req.Cipher,
+			CreatedAt:  time.Now().UTC(),
+			Versions:   1,
+			Tags:       req.Tags,
+			Operations: req.Operations,
+		}
+		k.creates++
+	case !exists:
+		fail(w, http.StatusNotFound, "key does not exist")
+		return
+	case api == "describe" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(key)
+		return
+	case api == "delete" && r.Method == http.MethodDelete:
+		if key.Protected {
+			fail(w, http.StatusConflict, "key is protected against deletion")
+			return
+		}
+		delete(k.keys, name)
+	case api == "protect" && r.Method == http.MethodPut:
+		key.Protected = true
+	case api == "unprotect" && r.Method == http.MethodPut:
+		key.Protected = false
+	default:
+		fail(w, http.StatusNotImplemented, "not implemented")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func fail(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
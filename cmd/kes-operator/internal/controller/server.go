@@ -0,0 +1,472 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	"github.com/minio/kes/cmd/kes-operator/api/v1alpha1"
+)
+
+const (
+	// defaultImage is the KES container image used when
+	// a KESServer does not specify one.
+	defaultImage = "minio/kes:latest"
+
+	// apiKeyField is the Secret entry containing the admin API key.
+	apiKeyField = "api-key"
+
+	// identityField is the Secret entry containing the admin identity.
+	identityField = "identity"
+
+	// caField is the Secret entry containing the CA certificate
+	// that issued the server certificate.
+	caField = "ca.crt"
+
+	configHashAnnotation = "kes.min.io/config-hash"
+	tlsHashAnnotation    = "kes.min.io/tls-hash"
+)
+
+// KESServerReconciler reconciles KESServers. It manages the admin
+// API key, the server certificate, the config file, rendered from
+// the KESServer and all KESPolicies referencing it, as well as the
+// Deployment and Service of the KES server.
+type KESServerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=kes.min.io,resources=kesservers;kespolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kes.min.io,resources=kesservers/status;kespolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile reconciles the KESServer referenced by the request.
+func (r *KESServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var server v1alpha1.KESServer
+	if err := r.Get(ctx, req.NamespacedName, &server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil // All owned objects are garbage collected
+	}
+
+	apiKey, err := r.reconcileAdminSecret(ctx, &server)
+	if err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "AdminSecretFailed", err)
+	}
+	tlsSecret, err := r.reconcileTLSSecret(ctx, &server)
+	if err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "TLSSecretFailed", err)
+	}
+	policies, err := r.listPolicies(ctx, &server)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	config, configHash, err := renderConfig(&server, apiKey.Identity().String(), policies)
+	if err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "InvalidConfig", err)
+	}
+	if err = r.reconcileConfigMap(ctx, &server, config); err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "ConfigMapFailed", err)
+	}
+	tlsHash := sha256.Sum256(tlsSecret.Data[corev1.TLSCertKey])
+	deployment, err := r.reconcileDeployment(ctx, &server, tlsSecret.Name, configHash, hex.EncodeToString(tlsHash[:]))
+	if err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "DeploymentFailed", err)
+	}
+	if err = r.reconcileService(ctx, &server); err != nil {
+		return ctrl.Result{}, r.fail(ctx, &server, "ServiceFailed", err)
+	}
+	if err = r.updatePolicyStatus(ctx, &server, policies); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	server.Status.ObservedGeneration = server.Generation
+	server.Status.Endpoint = serverEndpoint(&server)
+	server.Status.AdminSecret = adminSecretName(&server)
+	server.Status.TLSSecret = tlsSecret.Name
+	server.Status.ConfigHash = configHash
+	server.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+	server.Status.Policies = server.Status.Policies[:0]
+	for _, p := range policies {
+		server.Status.Policies = append(server.Status.Policies, p.Name)
+	}
+
+	replicas := *deployment.Spec.Replicas
+	switch {
+	case deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.UpdatedReplicas < replicas:
+		setCondition(&server.Status.Conditions, server.Generation, metav1.ConditionFalse, "Progressing", "deployment rollout in progress")
+	case deployment.Status.ReadyReplicas < replicas:
+		setCondition(&server.Status.Conditions, server.Generation, metav1.ConditionFalse, "Progressing",
+			fmt.Sprintf("%d of %d replicas ready", deployment.Status.ReadyReplicas, replicas))
+	default:
+		setCondition(&server.Status.Conditions, server.Generation, metav1.ConditionTrue, "Available",
+			fmt.Sprintf("%d of %d replicas ready", deployment.Status.ReadyReplicas, replicas))
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, &server)
+}
+
+// SetupWithManager registers the reconciler with the manager.
+func (r *KESServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.KESServer{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&v1alpha1.KESPolicy{}, handler.EnqueueRequestsFromMapFunc(mapPolicyToServer)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.mapSecretToServers)).
+		Complete(r)
+}
+
+// reconcileAdminSecret ensures that the admin Secret of the server
+// contains a valid API key. A new API key is generated only if the
+// Secret does not exist or contains no valid API key.
+func (r *KESServerReconciler) reconcileAdminSecret(ctx context.Context, server *v1alpha1.KESServer) (kes.APIKey, error) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      adminSecretName(server),
+		Namespace: server.Namespace,
+	}}
+
+	var apiKey kes.APIKey
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		key, err := kes.ParseAPIKey(string(secret.Data[apiKeyField]))
+		if err != nil {
+			if key, err = kes.GenerateAPIKey(nil); err != nil {
+				return err
+			}
+		}
+		apiKey = key
+
+		secret.Labels = labels(server)
+		secret.Data = map[string][]byte{
+			apiKeyField:   []byte(key.String()),
+			identityField: []byte(key.Identity().String()),
+		}
+		return controllerutil.SetControllerReference(server, secret, r.Scheme)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// reconcileTLSSecret returns the Secret containing the server
+// certificate. If the KESServer does not reference a Secret, a
+// self-signed certificate is issued and renewed before it expires.
+func (r *KESServerReconciler) reconcileTLSSecret(ctx context.Context, server *v1alpha1.KESServer) (*corev1.Secret, error) {
+	if name := server.Spec.TLS.SecretName; name != "" {
+		var secret corev1.Secret
+		if err := r.Get(ctx, client.ObjectKey{Namespace: server.Namespace, Name: name}, &secret); err != nil {
+			return nil, err
+		}
+		if len(secret.Data[corev1.TLSCertKey]) == 0 || len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+			return nil, fmt.Errorf("secret '%s' contains no TLS certificate or private key", name)
+		}
+		return &secret, nil
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      tlsSecretName(server),
+		Namespace: server.Namespace,
+	}}
+	dnsNames := serviceDNSNames(server)
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if needsRenewal(secret.Data[corev1.TLSCertKey], dnsNames, time.Now()) {
+			cert, key, err := issueCertificate(dnsNames, time.Now())
+			if err != nil {
+				return err
+			}
+			secret.Data = map[string][]byte{
+				corev1.TLSCertKey:       cert,
+				corev1.TLSPrivateKeyKey: key,
+				caField:                 cert,
+			}
+		}
+		secret.Type = corev1.SecretTypeTLS
+		secret.Labels = labels(server)
+		return controllerutil.SetControllerReference(server, secret, r.Scheme)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// listPolicies returns all KESPolicies, sorted by name, that
+// reference the server and are not being deleted.
+func (r *KESServerReconciler) listPolicies(ctx context.Context, server *v1alpha1.KESServer) ([]v1alpha1.KESPolicy, error) {
+	var list v1alpha1.KESPolicyList
+	if err := r.List(ctx, &list, client.InNamespace(server.Namespace)); err != nil {
+		return nil, err
+	}
+	policies := slices.DeleteFunc(list.Items, func(p v1alpha1.KESPolicy) bool {
+		return p.Spec.ServerRef.Name != server.Name || !p.DeletionTimestamp.IsZero()
+	})
+	slices.SortFunc(policies, func(a, b v1alpha1.KESPolicy) int { return strings.Compare(a.Name, b.Name) })
+	return policies, nil
+}
+
+func (r *KESServerReconciler) reconcileConfigMap(ctx context.Context, server *v1alpha1.KESServer, config []byte) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      configMapName(server),
+		Namespace: server.Namespace,
+	}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Labels = labels(server)
+		configMap.Data = map[string]string{configFile: string(config)}
+		return controllerutil.SetControllerReference(server, configMap, r.Scheme)
+	})
+	return err
+}
+
+// reconcileDeployment ensures the KES Deployment. The config and
+// certificate hashes are added to the pod template such that the
+// pods get restarted once the config or the certificate changes.
+func (r *KESServerReconciler) reconcileDeployment(ctx context.Context, server *v1alpha1.KESServer, tlsSecret, configHash, tlsHash string) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:      server.Name,
+		Namespace: server.Namespace,
+	}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		replicas := int32(1)
+		if server.Spec.Replicas != nil {
+			replicas = *server.Spec.Replicas
+		}
+		image := server.Spec.Image
+		if image == "" {
+			image = defaultImage
+		}
+
+		deployment.Labels = labels(server)
+		deployment.Spec.Replicas = &replicas
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels(server)}
+
+		template := &deployment.Spec.Template
+		template.Labels = labels(server)
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[configHashAnnotation] = configHash
+		template.Annotations[tlsHashAnnotation] = tlsHash
+
+		// Modify the existing container, if any, to preserve
+		// fields defaulted by the API server. Otherwise, every
+		// reconcile would update the Deployment.
+		i := slices.IndexFunc(template.Spec.Containers, func(c corev1.Container) bool { return c.Name == "kes" })
+		if i < 0 {
+			template.Spec.Containers = []corev1.Container{{Name: "kes"}}
+			i = 0
+		}
+		container := &template.Spec.Containers[i]
+		container.Image = image
+		container.Args = []string{"server", "--config", configDir + "/" + configFile}
+		container.Env = server.Spec.Env
+		container.Resources = server.Spec.Resources
+		container.Ports = []corev1.ContainerPort{{
+			Name:          "https",
+			ContainerPort: kesPort,
+			Protocol:      corev1.ProtocolTCP,
+		}}
+		container.VolumeMounts = []corev1.VolumeMount{
+			{Name: "config", MountPath: configDir, ReadOnly: true},
+			{Name: "tls", MountPath: tlsDir, ReadOnly: true},
+		}
+		container.ReadinessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path:   "/v1/ready",
+					Port:   intstr.FromInt32(kesPort),
+					Scheme: corev1.URISchemeHTTPS,
+				},
+			},
+			TimeoutSeconds:   1,
+			PeriodSeconds:    10,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		}
+		container.LivenessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.FromInt32(kesPort),
+				},
+			},
+			InitialDelaySeconds: 10,
+			TimeoutSeconds:      1,
+			PeriodSeconds:       10,
+			SuccessThreshold:    1,
+			FailureThreshold:    3,
+		}
+
+		mode := int32(0o444)
+		template.Spec.Volumes = []corev1.Volume{
+			{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(server)},
+					DefaultMode:          &mode,
+				}},
+			},
+			{
+				Name: "tls",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName:  tlsSecret,
+					DefaultMode: &mode,
+				}},
+			},
+		}
+		return controllerutil.SetControllerReference(server, deployment, r.Scheme)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+func (r *KESServerReconciler) reconcileService(ctx context.Context, server *v1alpha1.KESServer) error {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:      server.Name,
+		Namespace: server.Namespace,
+	}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Labels = labels(server)
+		service.Spec.Selector = labels(server)
+		service.Spec.Ports = []corev1.ServicePort{{
+			Name:       "https",
+			Port:       kesPort,
+			TargetPort: intstr.FromInt32(kesPort),
+			Protocol:   corev1.ProtocolTCP,
+		}}
+		return controllerutil.SetControllerReference(server, service, r.Scheme)
+	})
+	return err
+}
+
+// updatePolicyStatus marks all policies as applied to the server config.
+func (r *KESServerReconciler) updatePolicyStatus(ctx context.Context, server *v1alpha1.KESServer, policies []v1alpha1.KESPolicy) error {
+	for i := range policies {
+		p := &policies[i]
+		if p.Status.ObservedGeneration == p.Generation && meta.IsStatusConditionTrue(p.Status.Conditions, v1alpha1.ConditionReady) {
+			continue
+		}
+		p.Status.ObservedGeneration = p.Generation
+		setCondition(&p.Status.Conditions, p.Generation, metav1.ConditionTrue, "Applied",
+			fmt.Sprintf("policy applied to KESServer '%s'", server.Name))
+		if err := r.Status().Update(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail reports the error as Ready condition of the server
+// and returns it such that the request gets retried.
+func (r *KESServerReconciler) fail(ctx context.Context, server *v1alpha1.KESServer, reason string, err error) error {
+	setCondition(&server.Status.Conditions, server.Generation, metav1.ConditionFalse, reason, err.Error())
+	if uErr := r.Status().Update(ctx, server); uErr != nil {
+		ctrl.LoggerFrom(ctx).Error(uErr, "failed to update KESServer status")
+	}
+	return err
+}
+
+// mapSecretToServers returns a request for the KESServer owning the
+// Secret or referencing it as TLS Secret.
+func (r *KESServerReconciler) mapSecretToServers(ctx context.Context, obj client.Object) []ctrl.Request {
+	if owner := metav1.GetControllerOf(obj); owner != nil {
+		if owner.APIVersion == v1alpha1.GroupVersion.String() && owner.Kind == "KESServer" {
+			return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name}}}
+		}
+		return nil
+	}
+
+	var list v1alpha1.KESServerList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list KESServers")
+		return nil
+	}
+	var requests []ctrl.Request
+	for _, server := range list.Items {
+		if server.Spec.TLS.SecretName == obj.GetName() {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&server)})
+		}
+	}
+	return requests
+}
+
+// mapPolicyToServer returns a request for the KESServer the policy refers to.
+func mapPolicyToServer(_ context.Context, obj client.Object) []ctrl.Request {
+	policy, ok := obj.(*v1alpha1.KESPolicy)
+	if !ok || policy.Spec.ServerRef.Name == "" {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{
+		Namespace: policy.Namespace,
+		Name:      policy.Spec.ServerRef.Name,
+	}}}
+}
+
+// setCondition sets the Ready condition.
+func setCondition(conditions *[]metav1.Condition, generation int64, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func labels(server *v1alpha1.KESServer) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "kes",
+		"app.kubernetes.io/instance":   server.Name,
+		"app.kubernetes.io/managed-by": "kes-operator",
+	}
+}
+
+func adminSecretName(server *v1alpha1.KESServer) string { return server.Name + "-admin" }
+
+func configMapName(server *v1alpha1.KESServer) string { return server.Name + "-config" }
+
+func tlsSecretName(server *v1alpha1.KESServer) string {
+	if server.Spec.TLS.SecretName != "" {
+		return server.Spec.TLS.SecretName
+	}
+	return server.Name + "-tls"
+}
+
+// serviceDNSNames returns the in-cluster DNS names of the KES Service.
+func serviceDNSNames(server *v1alpha1.KESServer) []string {
+	return []string{
+		server.Name,
+		server.Name + "." + server.Namespace,
+		server.Name + "." + server.Namespace + ".svc",
+		server.Name + "." + server.Namespace + ".svc.cluster.local",
+	}
+}
+
+// serverEndpoint returns the in-cluster URL of the KES server.
+func serverEndpoint(server *v1alpha1.KESServer) string {
+	return fmt.Sprintf("https://%s.%s.svc:%d", server.Name, server.Namespace, kesPort)
+}
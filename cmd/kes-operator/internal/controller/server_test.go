@@ -0,0 +1,295 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/minio/kes/cmd/kes-operator/api/v1alpha1"
+)
+
+const testNamespace = "kes"
+
+func TestServerReconcile(t *testing.T) {
+	ctx := testContext(t)
+	server := newTestServer("kes")
+	c := newFakeClient(t, server, &v1alpha1.KESPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: testNamespace, Generation: 1},
+		Spec: v1alpha1.KESPolicySpec{
+			ServerRef:  corev1.LocalObjectReference{Name: "kes"},
+			Allow:      []string{"/v1/key/generate/my-app-*", "/v1/key/decrypt/my-app-*"},
+			Identities: []string{"7ec8095a5308a535b72b35d7ccd4ce1a674e6a2ceb2d5d9b2bd3d6cf4c43c4f1"},
+		},
+	}, &v1alpha1.KESPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: testNamespace},
+		Spec: v1alpha1.KESPolicySpec{
+			ServerRef: corev1.LocalObjectReference{Name: "other-kes"},
+			Allow:     []string{"/v1/key/create/*"},
+		},
+	})
+	r := &KESServerReconciler{Client: c, Scheme: c.Scheme()}
+
+	reconcile(t, ctx, r, server)
+
+	var admin corev1.Secret
+	get(t, ctx, c, "kes-admin", &admin)
+	apiKey, err := kes.ParseAPIKey(string(admin.Data[apiKeyField]))
+	if err != nil {
+		t.Fatalf("admin secret contains no valid API key: %v", err)
+	}
+	if identity := string(admin.Data[identityField]); identity != apiKey.Identity().String() {
+		t.Fatalf("admin identity mismatch: got '%s' - want '%s'", identity, apiKey.Identity())
+	}
+
+	var tlsSecret corev1.Secret
+	get(t, ctx, c, "kes-tls", &tlsSecret)
+	if tlsSecret.Type != corev1.SecretTypeTLS {
+		t.Fatalf("invalid TLS secret type: got '%s' - want '%s'", tlsSecret.Type, corev1.SecretTypeTLS)
+	}
+	if needsRenewal(tlsSecret.Data[corev1.TLSCertKey], serviceDNSNames(server), time.Now()) {
+		t.Fatal("issued certificate is not valid for the service DNS names")
+	}
+
+	var configMap corev1.ConfigMap
+	get(t, ctx, c, "kes-config", &configMap)
+	var config serverConfig
+	if err = yaml.Unmarshal([]byte(configMap.Data[configFile]), &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if config.Admin.Identity != apiKey.Identity().String() {
+		t.Fatalf("config admin identity mismatch: got '%s' - want '%s'", config.Admin.Identity, apiKey.Identity())
+	}
+	if !config.API["/v1/ready"].SkipAuth {
+		t.Fatal("readiness API requires authentication")
+	}
+	if len(config.Policy) != 1 || len(config.Policy["my-app"].Allow) != 2 || len(config.Policy["my-app"].Identities) != 1 {
+		t.Fatalf("invalid policies: got %+v", config.Policy)
+	}
+	if !strings.Contains(string(config.KeyStore), `"fs"`) {
+		t.Fatalf("invalid keystore: got '%s'", config.KeyStore)
+	}
+
+	var deployment appsv1.Deployment
+	get(t, ctx, c, "kes", &deployment)
+	if *deployment.Spec.Replicas != 2 {
+		t.Fatalf("invalid replicas: got %d - want 2", *deployment.Spec.Replicas)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != defaultImage {
+		t.Fatalf("invalid image: got '%s' - want '%s'", image, defaultImage)
+	}
+	if owner := metav1.GetControllerOf(&deployment); owner == nil || owner.Name != "kes" {
+		t.Fatalf("deployment is not owned by the KESServer: %v", owner)
+	}
+	configHash := deployment.Spec.Template.Annotations[configHashAnnotation]
+
+	var service corev1.Service
+	get(t, ctx, c, "kes", &service)
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != kesPort {
+		t.Fatalf("invalid service ports: got %v", service.Spec.Ports)
+	}
+
+	var policy v1alpha1.KESPolicy
+	get(t, ctx, c, "my-app", &policy)
+	if !meta.IsStatusConditionTrue(policy.Status.Conditions, v1alpha1.ConditionReady) || policy.Status.ObservedGeneration != 1 {
+		t.Fatalf("policy not marked as applied: %+v", policy.Status)
+	}
+	get(t, ctx, c, "other", &policy)
+	if len(policy.Status.Conditions) != 0 {
+		t.Fatalf("policy of another server marked as applied: %+v", policy.Status)
+	}
+
+	get(t, ctx, c, "kes", server)
+	if server.Status.Endpoint != "https://kes.kes.svc:7373" || server.Status.ConfigHash != configHash {
+		t.Fatalf("invalid server status: %+v", server.Status)
+	}
+	if cond := meta.FindStatusCondition(server.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "Progressing" {
+		t.Fatalf("server without ready replicas is not progressing: %+v", cond)
+	}
+
+	// Reconciling again must neither rotate the admin API key
+	// nor the certificate nor restart the pods.
+	reconcile(t, ctx, r, server)
+	var admin2, tlsSecret2 corev1.Secret
+	get(t, ctx, c, "kes-admin", &admin2)
+	get(t, ctx, c, "kes-tls", &tlsSecret2)
+	if string(admin2.Data[apiKeyField]) != string(admin.Data[apiKeyField]) {
+		t.Fatal("admin API key has been rotated")
+	}
+	if string(tlsSecret2.Data[corev1.TLSCertKey]) != string(tlsSecret.Data[corev1.TLSCertKey]) {
+		t.Fatal("certificate has been re-issued")
+	}
+	get(t, ctx, c, "kes", &deployment)
+	if hash := deployment.Spec.Template.Annotations[configHashAnnotation]; hash != configHash {
+		t.Fatalf("config hash changed: got '%s' - want '%s'", hash, configHash)
+	}
+
+	// Removing a policy must change the config and restart the pods.
+	get(t, ctx, c, "my-app", &policy)
+	if err = c.Delete(ctx, &policy); err != nil {
+		t.Fatalf("failed to delete policy: %v", err)
+	}
+	reconcile(t, ctx, r, server)
+	get(t, ctx, c, "kes", &deployment)
+	if hash := deployment.Spec.Template.Annotations[configHashAnnotation]; hash == configHash {
+		t.Fatal("config hash did not change after removing a policy")
+	}
+	get(t, ctx, c, "kes-config", &configMap)
+	if strings.Contains(configMap.Data[configFile], "my-app") {
+		t.Fatalf("removed policy is still part of the config:\n%s", configMap.Data[configFile])
+	}
+}
+
+func TestServerReconcileTLSSecret(t *testing.T) {
+	ctx := testContext(t)
+	server := newTestServer("kes")
+	server.Spec.TLS.SecretName = "kes-cert"
+	c := newFakeClient(t, server)
+	r := &KESServerReconciler{Client: c, Scheme: c.Scheme()}
+
+	// A missing TLS secret must be reported.
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(server)}); err == nil {
+		t.Fatal("reconcile succeeded without TLS secret")
+	}
+	get(t, ctx, c, "kes", server)
+	if cond := meta.FindStatusCondition(server.Status.Conditions, v1alpha1.ConditionReady); cond == nil || cond.Reason != "TLSSecretFailed" {
+		t.Fatalf("missing TLS secret not reported: %+v", cond)
+	}
+
+	cert, key, err := issueCertificate([]string{"kes.example.com"}, time.Now())
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "kes-cert", Namespace: testNamespace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: cert, corev1.TLSPrivateKeyKey: key},
+	}
+	if err = c.Create(ctx, secret); err != nil {
+		t.Fatalf("failed to create TLS secret: %v", err)
+	}
+	if requests := r.mapSecretToServers(ctx, secret); len(requests) != 1 || requests[0].Name != "kes" {
+		t.Fatalf("TLS secret not mapped to server: %v", requests)
+	}
+	reconcile(t, ctx, r, server)
+
+	var deployment appsv1.Deployment
+	get(t, ctx, c, "kes", &deployment)
+	for _, v := range deployment.Spec.Template.Spec.Volumes {
+		if v.Name == "tls" && v.Secret.SecretName != "kes-cert" {
+			t.Fatalf("deployment does not use the TLS secret: got '%s'", v.Secret.SecretName)
+		}
+	}
+	var generated corev1.Secret
+	if err = c.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: "kes-tls"}, &generated); err == nil {
+		t.Fatal("self-signed certificate issued despite TLS secret")
+	}
+
+	// Rotating the certificate must restart the pods.
+	tlsHash := deployment.Spec.Template.Annotations[tlsHashAnnotation]
+	if cert, _, err = issueCertificate([]string{"kes.example.com"}, time.Now()); err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	secret.Data[corev1.TLSCertKey] = cert
+	if err = c.Update(ctx, secret); err != nil {
+		t.Fatalf("failed to update TLS secret: %v", err)
+	}
+	reconcile(t, ctx, r, server)
+	get(t, ctx, c, "kes", &deployment)
+	if deployment.Spec.Template.Annotations[tlsHashAnnotation] == tlsHash {
+		t.Fatal("TLS hash did not change after rotating the certificate")
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	now := time.Now()
+	names := []string{"kes", "kes.kes.svc"}
+	cert, _, err := issueCertificate(names, now)
+	if err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+
+	if needsRenewal(cert, names, now) {
+		t.Fatal("new certificate needs renewal")
+	}
+	if !needsRenewal(cert, names, now.Add(340*24*time.Hour)) {
+		t.Fatal("certificate expiring soon does not need renewal")
+	}
+	if !needsRenewal(cert, append(names, "kes.kes.svc.cluster.local"), now) {
+		t.Fatal("certificate not covering all DNS names does not need renewal")
+	}
+	if !needsRenewal(nil, names, now) {
+		t.Fatal("missing certificate does not need renewal")
+	}
+}
+
+func newTestServer(name string) *v1alpha1.KESServer {
+	replicas := int32(2)
+	return &v1alpha1.KESServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: v1alpha1.KESServerSpec{
+			Replicas: &replicas,
+			KeyStore: &apiextensionsv1.JSON{Raw: []byte(`{"fs":{"path":"/tmp/kes"}}`)},
+		},
+	}
+}
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1alpha1.KESServer{}, &v1alpha1.KESKey{}, &v1alpha1.KESPolicy{}).
+		WithObjects(objects...).
+		Build()
+}
+
+type reconciler interface {
+	Reconcile(context.Context, ctrl.Request) (ctrl.Result, error)
+}
+
+func reconcile(t *testing.T, ctx context.Context, r reconciler, obj client.Object) ctrl.Result {
+	t.Helper()
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	if err != nil {
+		t.Fatalf("failed to reconcile '%s': %v", obj.GetName(), err)
+	}
+	return result
+}
+
+func get(t *testing.T, ctx context.Context, c client.Client, name string, obj client.Object) {
+	t.Helper()
+
+	if err := c.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: name}, obj); err != nil {
+		t.Fatalf("failed to get '%s': %v", name, err)
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
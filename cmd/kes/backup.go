@@ -0,0 +1,334 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/backup"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/kesconf"
+	kesdk "github.com/minio/kms-go/kes"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+const backupCmdUsage = `Usage:
+    kes backup [options] --config <PATH> <ARCHIVE>
+
+Options:
+    --config <PATH>          Path to the KES server config file.
+    --include <PATTERN>      Only backup keys matching the pattern. May be
+                             specified multiple times. Defaults to all keys.
+    --exclude <PATTERN>      Skip keys matching the pattern. May be specified
+                             multiple times.
+    --passphrase-file <PATH> Read the archive passphrase from a file instead
+                             of prompting for it.
+    -f, --force              Overwrite an existing archive.
+
+    -h, --help               Print command line options.
+
+The archive is encrypted with a key derived from the passphrase.
+Keep the passphrase safe. Without it, the keys cannot be restored.
+
+Examples:
+    $ kes backup --config config.yml keys.backup
+    $ kes backup --config config.yml --include 'minio-*' keys.backup
+`
+
+func backupCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, backupCmdUsage) }
+
+	var (
+		configFlag     string
+		includeFlag    []string
+		excludeFlag    []string
+		passphraseFile string
+		forceFlag      bool
+	)
+	cmd.StringVar(&configFlag, "config", "", "Path to the KES server config file")
+	cmd.StringArrayVar(&includeFlag, "include", nil, "Only backup keys matching the pattern")
+	cmd.StringArrayVar(&excludeFlag, "exclude", nil, "Skip keys matching the pattern")
+	cmd.StringVar(&passphraseFile, "passphrase-file", "", "Read the archive passphrase from a file")
+	cmd.BoolVarP(&forceFlag, "force", "f", false, "Overwrite an existing archive")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes backup --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no archive specified. See 'kes backup --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes backup --help'")
+	case configFlag == "":
+		cli.Fatal("no config file specified. See 'kes backup --help'")
+	}
+	filter, err := newEntryFilter(includeFlag, excludeFlag)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes backup --help'", err)
+	}
+	archivePath := cmd.Arg(0)
+	if !forceFlag {
+		if _, err := os.Stat(archivePath); err == nil {
+			cli.Fatal("archive already exists. Use --force to overwrite it")
+		}
+	}
+	passphrase := readPassphrase(passphraseFile, true)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	store := connectKeyStore(ctx, configFlag)
+	defer store.Close()
+
+	archive := &backup.Archive{CreatedAt: time.Now().UTC()}
+	iter := &kesdk.ListIter[string]{
+		NextFunc: store.List,
+	}
+	for {
+		name, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cli.Fatalf("failed to list keys: %v", err)
+		}
+		if !filter.Match(name) {
+			continue
+		}
+
+		value, err := store.Get(ctx, name)
+		if err != nil {
+			cli.Fatalf("failed to read key %q: %v", name, err)
+		}
+		archive.Entries = append(archive.Entries, backup.Entry{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err = backup.Seal(&buf, archive, passphrase); err != nil {
+		cli.Fatalf("failed to encrypt archive: %v", err)
+	}
+	if err = os.WriteFile(archivePath, buf.Bytes(), 0o600); err != nil {
+		cli.Fatalf("failed to write archive: %v", err)
+	}
+	fmt.Printf("Backed up %d keys to '%s'\n", len(archive.Entries), archivePath)
+}
+
+const restoreCmdUsage = `Usage:
+    kes restore [options] --config <PATH> <ARCHIVE>
+
+Options:
+    --config <PATH>          Path to the KES server config file.
+    --include <PATTERN>      Only restore keys matching the pattern. May be
+                             specified multiple times. Defaults to all keys.
+    --exclude <PATTERN>      Skip keys matching the pattern. May be specified
+                             multiple times.
+    --passphrase-file <PATH> Read the archive passphrase from a file instead
+                             of prompting for it.
+    -f, --force              Restore keys even if a key with the same name
+                             exists. The existing keys will be deleted.
+    --merge                  Only restore keys that do not exist.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes restore --config config.yml keys.backup
+    $ kes restore --config config.yml --merge --exclude 'test-*' keys.backup
+`
+
+func restoreCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, restoreCmdUsage) }
+
+	var (
+		configFlag     string
+		includeFlag    []string
+		excludeFlag    []string
+		passphraseFile string
+		forceFlag      bool
+		mergeFlag      bool
+	)
+	cmd.StringVar(&configFlag, "config", "", "Path to the KES server config file")
+	cmd.StringArrayVar(&includeFlag, "include", nil, "Only restore keys matching the pattern")
+	cmd.StringArrayVar(&excludeFlag, "exclude", nil, "Skip keys matching the pattern")
+	cmd.StringVar(&passphraseFile, "passphrase-file", "", "Read the archive passphrase from a file")
+	cmd.BoolVarP(&forceFlag, "force", "f", false, "Overwrite existing keys")
+	cmd.BoolVar(&mergeFlag, "merge", false, "Only restore keys that do not exist")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes restore --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no archive specified. See 'kes restore --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes restore --help'")
+	case configFlag == "":
+		cli.Fatal("no config file specified. See 'kes restore --help'")
+	case forceFlag && mergeFlag:
+		cli.Fatal("'--force' and '--merge' flags are mutually exclusive")
+	}
+	filter, err := newEntryFilter(includeFlag, excludeFlag)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes restore --help'", err)
+	}
+
+	file, err := os.Open(cmd.Arg(0))
+	if err != nil {
+		cli.Fatalf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	passphrase := readPassphrase(passphraseFile, false)
+	archive, err := backup.Open(file, passphrase)
+	if err != nil {
+		cli.Fatalf("failed to decrypt archive: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	store := connectKeyStore(ctx, configFlag)
+	defer store.Close()
+
+	var count int
+	for _, entry := range archive.Entries {
+		if !filter.Match(entry.Name) {
+			continue
+		}
+
+		err := store.Create(ctx, entry.Name, entry.Value)
+		if mergeFlag && errors.Is(err, kesdk.ErrKeyExists) {
+			continue
+		}
+		if forceFlag && errors.Is(err, kesdk.ErrKeyExists) {
+			if err = store.Delete(ctx, entry.Name); err != nil {
+				cli.Fatalf("failed to delete key %q: %v", entry.Name, err)
+			}
+			err = store.Create(ctx, entry.Name, entry.Value)
+		}
+		if err != nil {
+			cli.Fatalf("failed to restore key %q: %v", entry.Name, err)
+		}
+		count++
+	}
+	fmt.Printf("Restored %d keys from archive created at %s\n", count, archive.CreatedAt.Format(time.RFC3339))
+}
+
+// entryFilter selects keystore entries by their names.
+type entryFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// newEntryFilter returns an entryFilter that matches all
+// names that match at least one include pattern but no
+// exclude pattern. Without include patterns, all names
+// not excluded are matched.
+func newEntryFilter(include, exclude []string) (*entryFilter, error) {
+	for _, pattern := range append(include, exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return &entryFilter{
+		Include: include,
+		Exclude: exclude,
+	}, nil
+}
+
+// Match reports whether the name is selected by the filter.
+func (f *entryFilter) Match(name string) bool {
+	for _, pattern := range f.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// connectKeyStore connects to the keystore of the KES config
+// file. On error, it aborts the program using cli.Fatal.
+func connectKeyStore(ctx context.Context, filename string) kes.KeyStore {
+	conf, err := kesconf.ReadFile(filename)
+	if err != nil {
+		cli.Fatalf("failed to read config file: %v", err)
+	}
+	if conf.KeyStore == nil {
+		cli.Fatal("no keystore specified in config file")
+	}
+	store, err := conf.KeyStore.Connect(ctx)
+	if err != nil {
+		cli.Fatalf("failed to connect to keystore: %v", err)
+	}
+	return store
+}
+
+// readPassphrase reads the archive passphrase from the file or,
+// if empty, prompts for it. If confirm is true, the passphrase
+// has to be entered twice. On error, it aborts the program.
+func readPassphrase(filename string, confirm bool) []byte {
+	if filename != "" {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			cli.Fatalf("failed to read passphrase: %v", err)
+		}
+		b = bytes.TrimRight(b, "\r\n")
+		if len(b) == 0 {
+			cli.Fatal("passphrase file is empty")
+		}
+		return b
+	}
+
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		cli.Fatal("no passphrase specified. Use --passphrase-file")
+	}
+	fmt.Fprint(os.Stderr, "Enter passphrase:")
+	passphrase, err := term.ReadPassword(int(os.Stderr.Fd()))
+	if err != nil {
+		cli.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr)
+	if len(passphrase) == 0 {
+		cli.Fatal("passphrase is empty")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase:")
+		p, err := term.ReadPassword(int(os.Stderr.Fd()))
+		if err != nil {
+			cli.Fatal(err)
+		}
+		fmt.Fprintln(os.Stderr)
+		if !bytes.Equal(passphrase, p) {
+			cli.Fatal("passphrases don't match")
+		}
+	}
+	return passphrase
+}
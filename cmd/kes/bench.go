@@ -0,0 +1,239 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kms-go/kes"
+	flag "github.com/spf13/pflag"
+)
+
+const benchCmdUsage = `Usage:
+    kes bench [options] <key>
+
+Options:
+    -c, --concurrency <n>    Number of concurrent clients. Defaults to 8.
+    -d, --duration <d>       Duration of the benchmark. Defaults to 10s.
+        --ops <list>         Comma-separated list of operations to run.
+                             Possible values: generate, encrypt, decrypt.
+                             Defaults to all operations.
+        --size <n>           Size of plaintexts in bytes. Defaults to 32.
+    -k, --insecure           Skip TLS certificate validation.
+        --color <when>       Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+The key is created if it does not exist.
+
+Examples:
+    $ kes bench my-key
+    $ kes bench -c 32 -d 1m --ops encrypt,decrypt my-key
+`
+
+func benchCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, benchCmdUsage) }
+
+	var (
+		concurrency        int
+		duration           time.Duration
+		opsFlag            []string
+		size               int
+		insecureSkipVerify bool
+		colorFlag          colorOption
+	)
+	cmd.IntVarP(&concurrency, "concurrency", "c", 8, "Number of concurrent clients")
+	cmd.DurationVarP(&duration, "duration", "d", 10*time.Second, "Duration of the benchmark")
+	cmd.StringSliceVar(&opsFlag, "ops", []string{"generate", "encrypt", "decrypt"}, "Operations to run")
+	cmd.IntVar(&size, "size", 32, "Size of plaintexts in bytes")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes bench --help'", err)
+	}
+
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no key name specified. See 'kes bench --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes bench --help'")
+	case concurrency <= 0:
+		cli.Fatal("concurrency must be positive. See 'kes bench --help'")
+	case duration <= 0:
+		cli.Fatal("duration must be positive. See 'kes bench --help'")
+	case size < 0:
+		cli.Fatal("size must not be negative. See 'kes bench --help'")
+	}
+	var ops []benchOp
+	for _, op := range opsFlag {
+		switch op = strings.TrimSpace(op); op {
+		case "generate", "encrypt", "decrypt":
+			if !slices.Contains(ops, benchOp(op)) {
+				ops = append(ops, benchOp(op))
+			}
+		default:
+			cli.Fatalf("invalid operation %q. See 'kes bench --help'", op)
+		}
+	}
+	name := cmd.Arg(0)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	if err := client.CreateKey(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyExists) {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to create key %q: %v", name, err)
+	}
+
+	plaintext := make([]byte, size)
+	if _, err := rand.Read(plaintext); err != nil {
+		cli.Fatal(err)
+	}
+	ciphertext, err := client.Encrypt(ctx, name, plaintext, nil)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to encrypt with key %q: %v", name, err)
+	}
+
+	run := func(ctx context.Context, op benchOp) error {
+		var err error
+		switch op {
+		case "generate":
+			_, err = client.GenerateKey(ctx, name, nil)
+		case "encrypt":
+			_, err = client.Encrypt(ctx, name, plaintext, nil)
+		case "decrypt":
+			_, err = client.Decrypt(ctx, name, ciphertext, nil)
+		}
+		return err
+	}
+
+	benchCtx, cancelBench := context.WithTimeout(ctx, duration)
+	defer cancelBench()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = map[benchOp]*benchResult{}
+	)
+	for _, op := range ops {
+		results[op] = &benchResult{}
+	}
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			local := map[benchOp]*benchResult{}
+			for _, op := range ops {
+				local[op] = &benchResult{}
+			}
+			for j := i; benchCtx.Err() == nil; j++ {
+				op := ops[j%len(ops)]
+
+				opStart := time.Now()
+				err := run(benchCtx, op)
+				latency := time.Since(opStart)
+				if benchCtx.Err() != nil {
+					break // Don't count requests aborted by the deadline
+				}
+				if err != nil {
+					local[op].Errors++
+					continue
+				}
+				local[op].Latencies = append(local[op].Latencies, latency)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for op, r := range local {
+				results[op].Errors += r.Errors
+				results[op].Latencies = append(results[op].Latencies, r.Latencies...)
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		os.Exit(1)
+	}
+
+	header := tui.NewStyle()
+	if colorFlag.Colorize() {
+		header = header.Faint(true).Underline(true).UnderlineSpaces(false)
+	}
+	fmt.Println(
+		header.Render(fmt.Sprintf("%-10s", "Operation")),
+		header.Render(fmt.Sprintf("%9s", "Requests")),
+		header.Render(fmt.Sprintf("%7s", "Errors")),
+		header.Render(fmt.Sprintf("%10s", "Req/s")),
+		header.Render(fmt.Sprintf("%9s", "p50")),
+		header.Render(fmt.Sprintf("%9s", "p90")),
+		header.Render(fmt.Sprintf("%9s", "p99")),
+		header.Render(fmt.Sprintf("%9s", "max")),
+	)
+	for _, op := range ops {
+		r := results[op]
+		slices.Sort(r.Latencies)
+		fmt.Println(
+			fmt.Sprintf("%-10s", op),
+			fmt.Sprintf("%9d", len(r.Latencies)),
+			fmt.Sprintf("%7d", r.Errors),
+			fmt.Sprintf("%10.1f", float64(len(r.Latencies))/elapsed.Seconds()),
+			fmt.Sprintf("%9s", r.Percentile(50)),
+			fmt.Sprintf("%9s", r.Percentile(90)),
+			fmt.Sprintf("%9s", r.Percentile(99)),
+			fmt.Sprintf("%9s", r.Percentile(100)),
+		)
+	}
+}
+
+// benchOp is a KES API operation run by the benchmark.
+type benchOp string
+
+// benchResult contains the latencies of all successful
+// requests of one benchmark operation and the number of
+// failed requests.
+type benchResult struct {
+	Latencies []time.Duration // sorted before computing percentiles
+	Errors    int
+}
+
+// Percentile returns the p-th percentile of the latencies,
+// rounded to microseconds. The latencies must be sorted.
+func (r *benchResult) Percentile(p int) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := (len(r.Latencies)*p+99)/100 - 1
+	return r.Latencies[max(0, i)].Round(time.Microsecond)
+}
@@ -0,0 +1,597 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/keystore/credhub"
+	"github.com/minio/kes/internal/shamir"
+	"github.com/minio/kes/kesconf"
+	kesdk "github.com/minio/kms-go/kes"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+const configCmdUsage = `Usage:
+    kes config <command>
+
+Commands:
+    encrypt                  Encrypt a config file value.
+    import                   Generate a config file from an existing keystore.
+    seal                     Generate unseal key shares for a sealed server.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func configCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, configCmdUsage) }
+
+	subCmds := commands{
+		"encrypt": encryptConfigCmd,
+		"import":  importConfigCmd,
+		"seal":    sealConfigCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes config --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a config command. See 'kes config --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const encryptConfigCmdUsage = `Usage:
+    kes config encrypt [options]
+
+Encrypts a value read from standard input with a password and
+prints it as encrypted config file value. The password is read
+from the KES_CONFIG_PASSWORD env. variable or, if not set, from
+the terminal.
+
+The KES server decrypts encrypted config values on startup. It
+reads the password from the KES_CONFIG_PASSWORD env. variable or
+prompts for it.
+
+The values of a sealed server are encrypted with the root key
+combined from the unseal key shares instead. They are decrypted
+once the server has been unsealed. See 'kes config seal --help'.
+
+Options:
+    --share <share>          An unseal key share. Specify at least threshold
+                             many shares to encrypt a value for a sealed server.
+    --auto <path>            Path to the config file of a sealed server. The
+                             root key is unwrapped with its auto unseal KMS.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ echo -n "$CREDHUB_CLIENT_KEY" | kes config encrypt
+    $ echo -n "$CREDHUB_CLIENT_KEY" | kes config encrypt --share <share-1> --share <share-2>
+    $ echo -n "$CREDHUB_CLIENT_KEY" | kes config encrypt --auto config.yml
+`
+
+func encryptConfigCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, encryptConfigCmdUsage) }
+
+	var (
+		sharesFlag []string
+		autoFlag   string
+	)
+	cmd.StringArrayVar(&sharesFlag, "share", nil, "An unseal key share")
+	cmd.StringVar(&autoFlag, "auto", "", "Path to the config file of a sealed server")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes config encrypt --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes config encrypt --help'")
+	}
+
+	value, err := io.ReadAll(io.LimitReader(os.Stdin, 1<<20))
+	if err != nil {
+		cli.Fatalf("failed to read value: %v", err)
+	}
+	if value = bytes.TrimRight(value, "\r\n"); len(value) == 0 {
+		cli.Fatal("no value provided on standard input")
+	}
+
+	if len(sharesFlag) > 0 && autoFlag != "" {
+		cli.Fatal("'--share' and '--auto' are mutually exclusive. See 'kes config encrypt --help'")
+	}
+
+	var password []byte
+	switch {
+	case autoFlag != "":
+		file, err := kesconf.ReadSealedFile(autoFlag)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if file == nil || file.Seal.Auto == nil {
+			cli.Fatalf("'%s' contains no auto unseal config", autoFlag)
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		rootKey, err := file.Seal.Auto.UnwrapRootKey(ctx)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		if err = file.Seal.Verify(rootKey); err != nil {
+			cli.Fatal(err)
+		}
+		password = []byte(kesconf.RootKeyPassword(rootKey))
+	case len(sharesFlag) > 0:
+		shares := make([][]byte, 0, len(sharesFlag))
+		for _, s := range sharesFlag {
+			share, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				cli.Fatalf("invalid unseal key share '%s'", s)
+			}
+			shares = append(shares, share)
+		}
+		rootKey, err := shamir.Combine(shares)
+		if err != nil {
+			cli.Fatalf("invalid unseal key shares: %v", err)
+		}
+		password = []byte(kesconf.RootKeyPassword(rootKey))
+	default:
+		password = []byte(os.Getenv(kesconf.EnvConfigPassword))
+		if len(password) == 0 {
+			password = readPassphrase("", true)
+		}
+	}
+	ciphertext, err := kesconf.EncryptValue(string(value), password)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	fmt.Println(kesconf.EncryptedTag, ciphertext)
+}
+
+const sealConfigCmdUsage = `Usage:
+    kes config seal [options]
+
+Generates a random root key for a sealed KES server, splits it into
+unseal key shares and prints them together with the 'seal' section
+of the server config file. Any threshold many shares reconstruct the
+root key. Hand out each share to a different operator and keep them
+secret. The root key itself is not printed.
+
+A sealed server only serves the version and unseal API until enough
+operators have submitted their unseal key share:
+
+    $ curl -X PUT https://<kes>:7373/v1/unseal -d '{"share":"<share>"}'
+
+Once unsealed, the server decrypts the encrypted values of its config
+file with the root key. Encrypt them with 'kes config encrypt --share'.
+
+With --auto, the root key is also wrapped by the KMS key specified in
+the 'seal.auto' section of the given config file. Then, the server
+unseals itself on startup and only requires the unseal key shares if
+the KMS is not available. Without unseal key shares, i.e. --shares 0,
+the server cannot be unsealed without the KMS.
+
+Options:
+    --shares <n>             Number of unseal key shares. Defaults to 5.
+    --threshold <m>          Number of unseal key shares required to unseal
+                             the server. Defaults to 3.
+    --auto <path>            Path to a config file with a 'seal.auto' section.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes config seal --shares 5 --threshold 3
+    $ kes config seal --auto config.yml --shares 0
+`
+
+func sealConfigCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, sealConfigCmdUsage) }
+
+	var (
+		sharesFlag    int
+		thresholdFlag int
+		autoFlag      string
+	)
+	cmd.IntVar(&sharesFlag, "shares", 5, "Number of unseal key shares")
+	cmd.IntVar(&thresholdFlag, "threshold", 3, "Number of unseal key shares required to unseal the server")
+	cmd.StringVar(&autoFlag, "auto", "", "Path to a config file with a 'seal.auto' section")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes config seal --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes config seal --help'")
+	}
+
+	if sharesFlag == 0 && autoFlag == "" {
+		cli.Fatal("no unseal key shares and no '--auto' config specified. See 'kes config seal --help'")
+	}
+	if sharesFlag == 0 {
+		thresholdFlag = 0
+	}
+
+	var auto *kesconf.AutoUnsealConfig
+	if autoFlag != "" {
+		var err error
+		if auto, err = kesconf.ReadAutoUnsealConfig(autoFlag); err != nil {
+			cli.Fatal(err)
+		}
+	}
+
+	rootKey := make([]byte, 32)
+	if _, err := rand.Read(rootKey); err != nil {
+		cli.Fatalf("failed to generate root key: %v", err)
+	}
+	var shares [][]byte
+	if sharesFlag > 0 {
+		var err error
+		if shares, err = shamir.Split(rootKey, sharesFlag, thresholdFlag); err != nil {
+			cli.Fatalf("failed to generate unseal key shares: %v", err)
+		}
+	}
+	var ciphertext []byte
+	if auto != nil {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		var err error
+		if ciphertext, err = auto.WrapRootKey(ctx, rootKey); err != nil {
+			cli.Fatal(err)
+		}
+	}
+	seal := kesconf.NewSealConfig(rootKey, thresholdFlag)
+
+	for i, share := range shares {
+		fmt.Printf("Unseal Key Share %d: %s\n", i+1, base64.StdEncoding.EncodeToString(share))
+	}
+	if len(shares) > 0 {
+		fmt.Println()
+	}
+	fmt.Println("seal:")
+	fmt.Printf("  threshold: %d\n", seal.Threshold)
+	fmt.Printf("  key_check: %s\n", hex.EncodeToString(seal.KeyCheck))
+	if len(shares) > 0 {
+		fmt.Println("  share_checks:")
+		for _, share := range shares {
+			fmt.Printf("  - %s\n", hex.EncodeToString(kesconf.ShareCheck(share)))
+		}
+		fmt.Println("  operators: [] # Identities, besides the admin, that may submit shares")
+	}
+	if auto != nil {
+		fmt.Println("  auto:")
+		fmt.Printf("    key: %s\n", auto.Key)
+		fmt.Printf("    ciphertext: %s\n", base64.StdEncoding.EncodeToString(ciphertext))
+		fmt.Println("    kms: # Same as in", autoFlag)
+	}
+}
+
+const importConfigCmdUsage = `Usage:
+    kes config import vault   --endpoint <url> [options]
+    kes config import credhub --endpoint <url> --namespace <path> [options]
+
+Connects to an existing Hashicorp Vault K/V engine or CredHub namespace,
+lists the stored keys and prints a starter KES server config file that
+uses it as keystore. Review the config, e.g. the TLS and policy sections,
+before starting a server with it.
+
+Secrets, like the Vault AppRole secret, are not written to the config
+file. Instead, the config file references them as env. variables.
+
+Options:
+    --endpoint <url>         The Vault or CredHub endpoint.
+    --namespace <name>       The Vault namespace or CredHub namespace path.
+    --engine <path>          The Vault K/V engine path. Defaults to 'kv'.
+    --version <v1|v2>        The Vault K/V engine version. Defaults to 'v1'.
+    --prefix <path>          The Vault K/V prefix of all keys.
+    --approle-id <id>        The Vault AppRole ID.
+    --approle-secret <s>     The Vault AppRole secret. Defaults to the value of
+                             $KES_VAULT_APPROLE_SECRET.
+    --k8s-role <role>        The Vault Kubernetes role. Uses the pod's service
+                             account token.
+
+    --key <path>             Path to a TLS private key for mTLS authentication.
+    --cert <path>            Path to a TLS certificate for mTLS authentication.
+    --ca <path>              Path to a CA certificate for verifying the server
+                             certificate.
+    -k, --insecure           Skip server certificate verification. CredHub only.
+
+    --keys                   Add the listed keys to the 'keys' section, such
+                             that the KES server expects them to exist.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ export KES_VAULT_APPROLE_SECRET=<secret>
+    $ kes config import vault --endpoint https://vault:8200 --prefix kes --approle-id <id> > config.yml
+
+    $ kes config import credhub --endpoint https://credhub:8844 --namespace /kes \
+        --cert client.crt --key client.key --keys > config.yml
+`
+
+func importConfigCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, importConfigCmdUsage) }
+
+	var (
+		endpointFlag      string
+		namespaceFlag     string
+		engineFlag        string
+		versionFlag       string
+		prefixFlag        string
+		appRoleIDFlag     string
+		appRoleSecretFlag string
+		k8sRoleFlag       string
+		keyFlag           string
+		certFlag          string
+		caFlag            string
+		insecureFlag      bool
+		keysFlag          bool
+	)
+	cmd.StringVar(&endpointFlag, "endpoint", "", "The Vault or CredHub endpoint")
+	cmd.StringVar(&namespaceFlag, "namespace", "", "The Vault namespace or CredHub namespace path")
+	cmd.StringVar(&engineFlag, "engine", "kv", "The Vault K/V engine path")
+	cmd.StringVar(&versionFlag, "version", "v1", "The Vault K/V engine version")
+	cmd.StringVar(&prefixFlag, "prefix", "", "The Vault K/V prefix")
+	cmd.StringVar(&appRoleIDFlag, "approle-id", "", "The Vault AppRole ID")
+	cmd.StringVar(&appRoleSecretFlag, "approle-secret", cli.Env(envVaultAppRoleSecret), "The Vault AppRole secret")
+	cmd.StringVar(&k8sRoleFlag, "k8s-role", "", "The Vault Kubernetes role")
+	cmd.StringVar(&keyFlag, "key", "", "Path to a TLS private key")
+	cmd.StringVar(&certFlag, "cert", "", "Path to a TLS certificate")
+	cmd.StringVar(&caFlag, "ca", "", "Path to a CA certificate")
+	cmd.BoolVarP(&insecureFlag, "insecure", "k", false, "Skip server certificate verification")
+	cmd.BoolVar(&keysFlag, "keys", false, "Add the listed keys to the config file")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes config import --help'", err)
+	}
+	if cmd.NArg() == 0 {
+		cli.Fatal("no keystore specified. See 'kes config import --help'")
+	}
+	if cmd.NArg() > 1 {
+		cli.Fatal("too many arguments. See 'kes config import --help'")
+	}
+	if endpointFlag == "" {
+		cli.Fatal("no endpoint specified. Use '--endpoint'")
+	}
+	if (keyFlag == "") != (certFlag == "") {
+		cli.Fatal("'--key' and '--cert' must be specified together")
+	}
+
+	var (
+		keystore kesconf.KeyStore
+		config   configWriter
+	)
+	switch kind := cmd.Arg(0); kind {
+	case "vault":
+		if insecureFlag {
+			cli.Fatal("'-k / --insecure' is not supported for Vault. Use '--ca'")
+		}
+		if (appRoleIDFlag == "") == (k8sRoleFlag == "") {
+			cli.Fatal("specify either '--approle-id' or '--k8s-role'")
+		}
+		if appRoleIDFlag != "" && appRoleSecretFlag == "" {
+			cli.Fatalf("no AppRole secret specified. Use '--approle-secret' or set $%s", envVaultAppRoleSecret)
+		}
+
+		vault := &kesconf.VaultKeyStore{
+			Endpoint:    endpointFlag,
+			Namespace:   namespaceFlag,
+			Engine:      engineFlag,
+			APIVersion:  versionFlag,
+			Prefix:      prefixFlag,
+			PrivateKey:  keyFlag,
+			Certificate: certFlag,
+			CAPath:      caFlag,
+		}
+		config.Keystore("vault")
+		config.Field(2, "endpoint", endpointFlag)
+		config.Field(2, "engine", engineFlag)
+		config.Field(2, "version", versionFlag)
+		config.Field(2, "namespace", namespaceFlag)
+		config.Field(2, "prefix", prefixFlag)
+		if appRoleIDFlag != "" {
+			vault.AppRole = &kesconf.VaultAppRoleAuth{ID: appRoleIDFlag, Secret: appRoleSecretFlag}
+			config.Section(2, "approle")
+			config.Field(3, "id", appRoleIDFlag)
+			config.EnvField(3, "secret", envVaultAppRoleSecret)
+		} else {
+			// Without JWT, the pod's service account token is used.
+			vault.Kubernetes = &kesconf.VaultKubernetesAuth{Role: k8sRoleFlag}
+			config.Section(2, "kubernetes")
+			config.Field(3, "role", k8sRoleFlag)
+		}
+		config.Section(2, "tls")
+		config.Field(3, "key", keyFlag)
+		config.Field(3, "cert", certFlag)
+		config.Field(3, "ca", caFlag)
+		keystore = vault
+	case "credhub":
+		if namespaceFlag == "" {
+			cli.Fatal("no CredHub namespace specified. Use '--namespace'")
+		}
+		if appRoleIDFlag != "" || k8sRoleFlag != "" {
+			cli.Fatal("'--approle-id' and '--k8s-role' are not supported for CredHub")
+		}
+		if caFlag == "" && !insecureFlag {
+			cli.Fatal("no CA certificate specified. Use '--ca' or '-k / --insecure'")
+		}
+
+		keystore = &kesconf.CredHubKeyStore{
+			Config: &credhub.Config{
+				BaseURL:                  endpointFlag,
+				Namespace:                namespaceFlag,
+				EnableMutualTLS:          certFlag != "",
+				ClientCertFilePath:       certFlag,
+				ClientKeyFilePath:        keyFlag,
+				ServerInsecureSkipVerify: insecureFlag,
+				ServerCaCertFilePath:     caFlag,
+			},
+		}
+		config.Keystore("credhub")
+		config.Field(2, "base_url", endpointFlag)
+		config.Field(2, "namespace", namespaceFlag)
+		config.Field(2, "enable_mutual_tls", certFlag != "")
+		config.Field(2, "client_cert_file_path", certFlag)
+		config.Field(2, "client_key_file_path", keyFlag)
+		config.Field(2, "server_insecure_skip_verify", insecureFlag)
+		config.Field(2, "server_ca_cert_file_path", caFlag)
+	default:
+		cli.Fatalf("keystore '%s' is not supported. Use 'vault' or 'credhub'", kind)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := keystore.Connect(ctx)
+	if err != nil {
+		cli.Fatalf("failed to connect to keystore: %v", err)
+	}
+	defer store.Close()
+
+	var names []string
+	iter := &kesdk.ListIter[string]{NextFunc: store.List}
+	for name, err := iter.Next(ctx); err != io.EOF; name, err = iter.Next(ctx) {
+		if err != nil {
+			cli.Fatalf("failed to list keys: %v", err)
+		}
+		if strings.HasPrefix(name, "-") {
+			continue // Skip entries used internally by KES, like secrets
+		}
+		names = append(names, name)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d keys at the %s keystore.\n", len(names), cmd.Arg(0))
+
+	if keysFlag {
+		config.Keys(names)
+	}
+	fmt.Print(config.String())
+}
+
+// envVaultAppRoleSecret is the env. variable referenced by
+// generated config files for the Vault AppRole secret.
+const envVaultAppRoleSecret = "KES_VAULT_APPROLE_SECRET"
+
+// configWriter generates a KES server config file. The generated
+// config contains the keystore section and placeholders for the
+// sections every config file requires.
+type configWriter struct {
+	keystore strings.Builder
+	keys     []string
+}
+
+// Keystore starts the keystore section of the given kind, e.g. 'vault'.
+func (w *configWriter) Keystore(kind string) {
+	w.keystore.WriteString("keystore:\n")
+	w.Section(1, kind)
+}
+
+// Section writes the key of a nested section at the given level.
+func (w *configWriter) Section(level int, key string) {
+	fmt.Fprintf(&w.keystore, "%s%s:\n", strings.Repeat("  ", level), key)
+}
+
+// Field writes a key-value pair at the given level.
+func (w *configWriter) Field(level int, key string, value any) {
+	if s, ok := value.(string); ok {
+		value = strconv.Quote(s)
+	}
+	fmt.Fprintf(&w.keystore, "%s%s: %v\n", strings.Repeat("  ", level), key, value)
+}
+
+// EnvField writes a key that references the given env. variable.
+func (w *configWriter) EnvField(level int, key, env string) {
+	fmt.Fprintf(&w.keystore, "%s%s: ${%s}\n", strings.Repeat("  ", level), key, env)
+}
+
+// Keys adds the key names to the keys section.
+func (w *configWriter) Keys(names []string) { w.keys = names }
+
+// String returns the generated config file.
+func (w *configWriter) String() string {
+	var b strings.Builder
+	b.WriteString("# Generated by 'kes config import'. Review the config before starting\n")
+	b.WriteString("# a KES server with it. See: https://github.com/minio/kes/blob/master/server-config.yaml\n")
+	b.WriteString("version: v1\n")
+	b.WriteString("address: 0.0.0.0:7373\n\n")
+	b.WriteString("admin:\n")
+	b.WriteString("  identity: disabled # Replace with the admin identity, e.g. 'kes identity of admin.crt'\n\n")
+	b.WriteString("tls:\n")
+	b.WriteString("  key: ./private.key\n")
+	b.WriteString("  cert: ./public.crt\n\n")
+	b.WriteString(w.keystore.String())
+	if len(w.keys) > 0 {
+		b.WriteString("\n# Keys found at the keystore. The KES server expects them to exist.\n")
+		b.WriteString("keys:\n")
+		for _, name := range w.keys {
+			fmt.Fprintf(&b, "  - name: %s\n", strconv.Quote(name))
+		}
+	}
+	return b.String()
+}
+
+// readConfigPassword prompts for the password of encrypted values
+// in the config file, if it contains any, and sets the env. variable
+// kesconf.EnvConfigPassword. It does nothing if the env. variable
+// is already set.
+func readConfigPassword(filename string) error {
+	if _, ok := os.LookupEnv(kesconf.EnvConfigPassword); ok {
+		return nil
+	}
+	config, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(config, []byte(kesconf.EncryptedTag)) {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return fmt.Errorf("config file contains encrypted values but no password specified. Set the env. variable '%s'", kesconf.EnvConfigPassword)
+	}
+
+	fmt.Fprint(os.Stderr, "Enter config password:")
+	password, err := term.ReadPassword(int(os.Stderr.Fd()))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	return os.Setenv(kesconf.EnvConfigPassword, string(password))
+}
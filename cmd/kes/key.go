@@ -35,6 +35,7 @@ Commands:
     encrypt                  Encrypt a message.
     decrypt                  Decrypt an encrypted message.
     dek                      Generate a new data encryption key.
+    hmac                     Compute the HMAC of a message.
 
 Options:
     -h, --help               Print command line options.
@@ -54,6 +55,7 @@ func keyCmd(args []string) {
 		"encrypt": encryptKeyCmd,
 		"decrypt": decryptKeyCmd,
 		"dek":     dekCmd,
+		"hmac":    hmacKeyCmd,
 	}
 
 	if len(args) < 2 {
@@ -612,3 +614,66 @@ func dekCmd(args []string) {
 		fmt.Printf(format, plaintext, ciphertext)
 	}
 }
+
+const hmacKeyCmdUsage = `Usage:
+    kes key hmac [options] <name> <message>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+    -e, --enclave <name>     Operate within the specified enclave.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes key hmac my-key "Hello World"
+`
+
+func hmacKeyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, hmacKeyCmdUsage) }
+
+	var (
+		insecureSkipVerify bool
+		enclaveName        string
+	)
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	cmd.StringVarP(&enclaveName, "enclave", "e", "", "Operate within the specified enclave")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes key hmac --help'", err)
+	}
+
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no key name specified. See 'kes key hmac --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no message specified. See 'kes key hmac --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes key hmac --help'")
+	}
+
+	name := cmd.Arg(0)
+	message := cmd.Arg(1)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+
+	client := newClient(config{
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	sum, err := client.HMAC(ctx, name, []byte(message))
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to compute HMAC: %v", err)
+	}
+
+	if cli.IsTerminal() {
+		fmt.Printf("\nhmac: %s\n", base64.StdEncoding.EncodeToString(sum))
+	} else {
+		fmt.Printf(`{"hmac":"%s"}`, base64.StdEncoding.EncodeToString(sum))
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	tui "github.com/charmbracelet/lipgloss"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/https"
+	"github.com/minio/kes/kesconf"
+	flag "github.com/spf13/pflag"
+)
+
+const keystoreCmdUsage = `Usage:
+    kes keystore <command>
+
+Commands:
+    ping                     Check the connection to a keystore.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func keystoreCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, keystoreCmdUsage) }
+
+	subCmds := commands{
+		"ping": pingKeyStoreCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes keystore --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a keystore command. See 'kes keystore --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const pingKeyStoreCmdUsage = `Usage:
+    kes keystore ping [options] --config <PATH>
+
+Options:
+    --config <PATH>          Path to the KES server config file.
+    --timeout <DURATION>     Abort the check after the given duration.
+                             Defaults to 10s.
+    --color <when>           Specify when to use colored output. The automatic
+                             mode only enables colors if an interactive terminal
+                             is detected - colors are automatically disabled if
+                             the output goes to a pipe.
+                             Possible values: *auto*, never, always.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes keystore ping --config config.yml
+`
+
+func pingKeyStoreCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, pingKeyStoreCmdUsage) }
+
+	var (
+		configFlag  string
+		timeoutFlag time.Duration
+		colorFlag   colorOption
+	)
+	cmd.StringVar(&configFlag, "config", "", "Path to the KES server config file")
+	cmd.DurationVar(&timeoutFlag, "timeout", 10*time.Second, "Abort the check after the given duration")
+	cmd.Var(&colorFlag, "color", "Specify when to use colored output")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes keystore ping --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes keystore ping --help'")
+	}
+	if configFlag == "" {
+		cli.Fatal("no config file specified. See 'kes keystore ping --help'")
+	}
+
+	faint := tui.NewStyle()
+	dotStyle := tui.NewStyle()
+	if colorFlag.Colorize() {
+		const ColorDot = tui.Color("#00f700")
+		faint = faint.Faint(true)
+		dotStyle = dotStyle.Foreground(ColorDot).Bold(true)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeoutFlag)
+	defer cancelTimeout()
+
+	file, err := kesconf.ReadFile(configFlag)
+	if err != nil {
+		cli.Fatalf("failed to read config file: %v", err)
+	}
+	if file.KeyStore == nil {
+		cli.Fatal("no keystore specified in config file")
+	}
+	endpoint, tlsConfig, err := keystoreTLSConfig(file.KeyStore)
+	if err != nil {
+		cli.Fatalf("invalid keystore config: %v", err)
+	}
+
+	if tlsConfig != nil {
+		conn, err := dialKeyStore(ctx, endpoint, tlsConfig)
+		if err != nil {
+			cli.Fatalf("failed to establish TLS connection to '%s': %v", endpoint, err)
+		}
+		state := conn.ConnectionState()
+		conn.Close()
+
+		fmt.Println(dotStyle.Render("●"), endpoint)
+		fmt.Println(
+			faint.Render(fmt.Sprintf("  %-8s", "TLS")),
+			tls.VersionName(state.Version),
+			tls.CipherSuiteName(state.CipherSuite),
+		)
+		for i, cert := range state.PeerCertificates {
+			fmt.Println(
+				faint.Render(fmt.Sprintf("%3s %-6d", "·", i)),
+				cert.Subject.String(),
+			)
+			fmt.Println(
+				faint.Render(fmt.Sprintf("  %-8s", "")),
+				faint.Render("Issuer "),
+				cert.Issuer.String(),
+			)
+			fmt.Println(
+				faint.Render(fmt.Sprintf("  %-8s", "")),
+				faint.Render("Expires"),
+				cert.NotAfter.Format(time.RFC3339),
+			)
+		}
+	}
+
+	store, err := file.KeyStore.Connect(ctx)
+	if err != nil {
+		cli.Fatalf("failed to connect to keystore: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Now()
+	state, err := store.Status(ctx)
+	if err != nil {
+		cli.Fatalf("keystore is not available: %v", err)
+	}
+	latency := time.Since(start)
+	if state.Latency == 0 {
+		state.Latency = latency
+	}
+
+	if tlsConfig == nil {
+		fmt.Println(dotStyle.Render("●"), "keystore")
+	}
+	fmt.Println(
+		faint.Render(fmt.Sprintf("  %-8s", "Status")),
+		"available",
+	)
+	fmt.Println(
+		faint.Render(fmt.Sprintf("  %-8s", "Latency")),
+		state.Latency.Round(time.Millisecond),
+	)
+}
+
+// keystoreTLSConfig returns the network endpoint of the keystore
+// and the TLS configuration used to connect to it. It returns a
+// nil TLS configuration if the keystore is not accessed over TLS.
+func keystoreTLSConfig(store kesconf.KeyStore) (string, *tls.Config, error) {
+	switch s := store.(type) {
+	case *kesconf.TimeoutKeyStore:
+		return keystoreTLSConfig(s.KeyStore)
+	case *kesconf.FSKeyStore:
+		return "", nil, nil
+	case *kesconf.VaultKeyStore:
+		conf, err := rootCAConfig(s.CAPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if s.Certificate != "" || s.PrivateKey != "" {
+			cert, err := https.CertificateFromFile(s.Certificate, s.PrivateKey, "")
+			if err != nil {
+				return "", nil, err
+			}
+			conf.Certificates = append(conf.Certificates, cert)
+		}
+		return s.Endpoint, conf, nil
+	case *kesconf.FortanixKeyStore:
+		conf, err := rootCAConfig(s.CAPath)
+		return s.Endpoint, conf, err
+	case *kesconf.KeySecureKeyStore:
+		conf, err := rootCAConfig(s.CAPath)
+		return s.Endpoint, conf, err
+	case *kesconf.EntrustKeyControlKeyStore:
+		conf, err := rootCAConfig(s.CAPath)
+		return s.Endpoint, conf, err
+	case *kesconf.KESKeyStore:
+		conf, err := rootCAConfig(s.CAPath)
+		if err != nil {
+			return "", nil, err
+		}
+		cert, err := https.CertificateFromFile(s.Certificate, s.PrivateKey, "")
+		if err != nil {
+			return "", nil, err
+		}
+		conf.Certificates = append(conf.Certificates, cert)
+		return s.Endpoints[0], conf, nil
+	case *kesconf.GCPSecretManagerKeyStore:
+		endpoint := s.Endpoint
+		if endpoint == "" {
+			endpoint = "secretmanager.googleapis.com:443"
+		}
+		return endpoint, &tls.Config{}, nil
+	case *kesconf.AWSSecretsManagerKeyStore:
+		return s.Endpoint, &tls.Config{}, nil
+	case *kesconf.AzureKeyVaultKeyStore:
+		return s.Endpoint, &tls.Config{}, nil
+	case *kesconf.CredHubKeyStore:
+		certs, err := s.Config.Validate()
+		if err != nil {
+			return "", nil, err
+		}
+		conf := &tls.Config{
+			InsecureSkipVerify: s.Config.ServerInsecureSkipVerify,
+		}
+		if !s.Config.ServerInsecureSkipVerify {
+			conf.RootCAs = x509.NewCertPool()
+			conf.RootCAs.AddCert(certs.ServerCaCert)
+		}
+		if s.Config.EnableMutualTLS {
+			conf.Certificates = []tls.Certificate{certs.ClientKeyPair}
+		}
+		return s.Config.BaseURL, conf, nil
+	default:
+		return "", nil, nil
+	}
+}
+
+// rootCAConfig returns a TLS configuration that verifies peer
+// certificates using the root CAs at caPath. If caPath is empty,
+// the system root CAs are used.
+func rootCAConfig(caPath string) (*tls.Config, error) {
+	if caPath == "" {
+		return &tls.Config{}, nil
+	}
+	rootCAs, err := https.CertPoolFromFile(caPath)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{RootCAs: rootCAs}, nil
+}
+
+// dialKeyStore establishes a TLS connection to the keystore
+// endpoint. The endpoint may either be an URL or a host with
+// an optional port. The port defaults to 443.
+func dialKeyStore(ctx context.Context, endpoint string, conf *tls.Config) (*tls.Conn, error) {
+	host := endpoint
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		host = u.Host
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+
+	dialer := &tls.Dialer{Config: conf}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*tls.Conn), nil
+}
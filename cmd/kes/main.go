@@ -41,8 +41,14 @@ Commands:
     log                      Print error and audit log events.
     status                   Print server status.
     metric                   Print server metrics.
+    bench                    Benchmark a KES server.
 
+    keystore                 Check keystore connectivity.
+    backup                   Backup keys to an encrypted archive.
+    restore                  Restore keys from an encrypted archive.
     migrate                  Migrate KMS data.
+    tpm                      Seal secrets with the TPM.
+    config                   Encrypt or generate config files.
     update                   Update KES binary.
 
 Options:
@@ -70,9 +76,15 @@ func main() {
 		"log":    logCmd,
 		"status": statusCmd,
 		"metric": metricCmd,
-
-		"migrate": migrate,
-		"update":  updateCmd,
+		"bench":  benchCmd,
+
+		"keystore": keystoreCmd,
+		"backup":   backupCmd,
+		"restore":  restoreCmd,
+		"migrate":  migrate,
+		"tpm":      tpmCmd,
+		"config":   configCmd,
+		"update":   updateCmd,
 	}
 
 	if len(os.Args) < 2 {
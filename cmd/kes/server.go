@@ -23,6 +23,7 @@ import (
 	"os/signal"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -30,6 +31,9 @@ import (
 	tui "github.com/charmbracelet/lipgloss"
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/fips"
+	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/secmem"
 	"github.com/minio/kes/internal/sys"
 	"github.com/minio/kes/kesconf"
 	kesdk "github.com/minio/kms-go/kes"
@@ -44,7 +48,14 @@ Options:
                              The default is '0.0.0.0:7373' which causes the server
                              to listen on all available network interfaces.
 
-    --config <file>          Path to the KES server config file.
+    --config <file>          Path to the KES server config file. Values of the
+                             config file can be overridden by 'KES_*' env.
+                             variables. If not specified, the server is
+                             configured by env. variables only.
+
+    --profile <name>         Apply the named profile of the config file. Its
+                             values override the values of the config file.
+                             Equivalent to setting 'KES_PROFILE'.
 
     --dev                    Start the KES server in development mode. The server
                              uses a volatile in-memory key store.
@@ -66,6 +77,14 @@ Examples:
 
   2. Start a new KES server with a confg file on '127.0.0.1:7000'.
      $ kes server --addr :7000 --config ./kes/config.yml
+
+  3. Start a new KES server with the 'prod' profile of a config file.
+     $ kes server --config ./kes/config.yml --profile prod
+
+  4. Start a new KES server configured by env. variables only.
+     $ export KES_ADMIN_IDENTITY=disabled KES_TLS_KEY=./private.key KES_TLS_CERT=./public.crt
+     $ export KES_KEYSTORE_FS_PATH=./keys
+     $ kes server
 `
 
 func serverCmd(args []string) {
@@ -75,17 +94,25 @@ func serverCmd(args []string) {
 	var (
 		addrFlag     string
 		configFlag   string
+		profileFlag  string
 		tlsKeyFlag   string
 		tlsCertFlag  string
 		mtlsAuthFlag string
 		devFlag      bool
+		faultFlag    string
 	)
 	cmd.StringVar(&addrFlag, "addr", "", "The address of the server")
-	cmd.StringVar(&configFlag, "config", "", "Path to the server configuration file")
+	cmd.StringVar(&configFlag, "config", "", "Path to the server configuration file (YAML, JSON or TOML)")
+	cmd.StringVar(&profileFlag, "profile", "", "Name of the config file profile to apply")
 	cmd.StringVar(&tlsKeyFlag, "key", "", "Path to the TLS private key")
 	cmd.StringVar(&tlsCertFlag, "cert", "", "Path to the TLS certificate")
 	cmd.StringVar(&mtlsAuthFlag, "auth", "", "Controls how the server handles mTLS authentication")
 	cmd.BoolVar(&devFlag, "dev", false, "Start the KES server in development mode")
+
+	// The fault injection flag is hidden since it is only used for
+	// testing how KES and its clients deal with failing keystores.
+	cmd.StringVar(&faultFlag, "fault-inject", "", "Inject faults into keystore operations")
+	cmd.MarkHidden("fault-inject")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			os.Exit(2)
@@ -107,6 +134,13 @@ func serverCmd(args []string) {
 	if cmd.NArg() > 0 {
 		cli.Fatal("too many arguments. See 'kes server --help'")
 	}
+	if profileFlag != "" {
+		// The profile is applied whenever the config file is
+		// read, e.g. on SIGHUP, and hence set as env. variable.
+		if err := os.Setenv(kesconf.EnvProfile, profileFlag); err != nil {
+			cli.Fatal(err)
+		}
+	}
 
 	if devFlag {
 		if addrFlag == "" {
@@ -122,17 +156,29 @@ func serverCmd(args []string) {
 		return
 	}
 
-	if err := startServer(addrFlag, configFlag); err != nil {
+	faults, err := parseFaults(faultFlag)
+	if err != nil {
+		cli.Fatalf("invalid '--fault-inject' flag: %v", err)
+	}
+	if faults != nil {
+		fmt.Fprintln(os.Stderr, warnPrefix, "injecting faults into keystore operations. Never use '--fault-inject' in production")
+	}
+	if err := startServer(addrFlag, configFlag, faults); err != nil {
 		cli.Fatal(err)
 	}
 }
 
-func startServer(addrFlag, configFlag string) error {
-	var memLocked bool
-	if runtime.GOOS == "linux" {
-		memLocked = mlockall() == nil
-		defer munlockall()
-	}
+func startServer(addrFlag, configFlag string, faults *kes.FaultInjector) error {
+	// Lock the memory before reading the config file
+	// since it may contain secrets, like API keys. Once
+	// the config has been read, the memory gets unlocked
+	// again if memory locking is turned off.
+	memLocked := secmem.LockAll() == nil
+	defer func() {
+		if memLocked {
+			secmem.UnlockAll()
+		}
+	}()
 
 	info, err := sys.ReadBinaryInfo()
 	if err != nil {
@@ -143,8 +189,38 @@ func startServer(addrFlag, configFlag string) error {
 	// local network interfaces. We may not know the
 	// server addr yet since a user may not specified
 	// one on the command line.
-	rawConfig, err := kesconf.ReadFile(configFlag)
-	if err != nil {
+	//
+	// A sealed server only reads the parts of the config
+	// file that do not contain encrypted values. The rest
+	// is read once the server has been unsealed.
+	var sealed *kesconf.File
+	if configFlag != "" {
+		if sealed, err = kesconf.ReadSealedFile(configFlag); err != nil {
+			return err
+		}
+		if sealed != nil && sealed.Seal.Auto != nil {
+			if err = autoUnseal(sealed.Seal); err == nil {
+				sealed = nil
+			} else if sealed.Seal.Threshold == 0 {
+				return err
+			} else {
+				warnPrefix := tui.NewStyle().Foreground(tui.Color("#ac0000")).Render("WARNING:")
+				fmt.Fprintln(os.Stderr, warnPrefix, err, "- waiting for unseal key shares")
+			}
+		}
+		if sealed == nil {
+			if err = readConfigPassword(configFlag); err != nil {
+				return err
+			}
+		}
+	}
+	rawConfig := sealed
+	if rawConfig == nil {
+		if rawConfig, err = kesconf.ReadFileWithEnv(configFlag); err != nil {
+			return err
+		}
+	}
+	if memLocked, err = protectMemory(rawConfig.Memory, memLocked); err != nil {
 		return err
 	}
 	switch {
@@ -174,23 +250,41 @@ func startServer(addrFlag, configFlag string) error {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	conf, err := rawConfig.Config(ctx)
-	if err != nil {
+	var conf *kes.Config
+	if sealed != nil {
+		tlsConf, err := sealed.TLSConfig()
+		if err != nil {
+			return err
+		}
+		conf = &kes.Config{
+			Admin: sealed.Admin,
+			FIPS:  sealed.FIPS,
+			TLS:   tlsConf,
+		}
+	} else if conf, err = rawConfig.Config(ctx); err != nil {
 		return err
 	}
-	defer conf.Keys.Close()
+	conf.FaultInjector = faults
 
+	// The server closes the keystore once it has been shut down.
 	srv := &kes.Server{}
+	if rawConfig.Shutdown != nil {
+		srv.ShutdownTimeout = rawConfig.Shutdown.Timeout
+	}
 	conf.Cache = configureCache(conf.Cache)
+	if conf.Cache.DEKSize > 0 && conf.Cache.DEKExpiry > 0 && !memLocked {
+		warnPrefix := tui.NewStyle().Foreground(tui.Color("#ac0000")).Render("WARNING:")
+		fmt.Fprintln(os.Stderr, warnPrefix, "data key cache is enabled but memory could not be locked. Cached data keys may be swapped to disk")
+	}
 	if rawConfig.Log != nil {
 		srv.ErrLevel.Set(rawConfig.Log.ErrLevel)
-		srv.AuditLevel.Set(rawConfig.Log.AuditLevel)
+		srv.AuditLevel.Set(auditLevel(rawConfig.Log))
 	}
 	sighup := make(chan os.Signal, 10)
 	signal.Notify(sighup, syscall.SIGHUP)
 	defer signal.Stop(sighup)
 
-	startupMessage := func(conf *kes.Config) *strings.Builder {
+	startupMessage := func(conf *kes.Config, logConf *kesconf.LogConfig) *strings.Builder {
 		blue := tui.NewStyle().Foreground(tui.Color("#268BD2"))
 		faint := tui.NewStyle().Faint(true)
 
@@ -200,7 +294,11 @@ func startServer(addrFlag, configFlag string) error {
 		fmt.Fprintf(buf, "%-33s %-23s %s\n", blue.Render("License"), "AGPLv3", faint.Render("https://www.gnu.org/licenses/agpl-3.0.html"))
 		fmt.Fprintf(buf, "%-33s %-12s 2015-%d  %s\n", blue.Render("Copyright"), "MinIO, Inc.", time.Now().Year(), faint.Render("https://min.io"))
 		fmt.Fprintln(buf)
-		fmt.Fprintf(buf, "%-33s %v\n", blue.Render("KMS"), conf.Keys)
+		if conf.Seal != nil {
+			fmt.Fprintf(buf, "%-33s <sealed>\n", blue.Render("KMS"))
+		} else {
+			fmt.Fprintf(buf, "%-33s %v\n", blue.Render("KMS"), conf.Keys)
+		}
 		fmt.Fprintf(buf, "%-33s · https://%s\n", blue.Render("API"), net.JoinHostPort(ifaceIPs[0].String(), port))
 		for _, ifaceIP := range ifaceIPs[1:] {
 			fmt.Fprintf(buf, "%-11s · https://%s\n", " ", net.JoinHostPort(ifaceIP.String(), port))
@@ -216,12 +314,25 @@ func startServer(addrFlag, configFlag string) error {
 			fmt.Fprintf(buf, "%-33s <disabled>\n", blue.Render("Admin"))
 		}
 		fmt.Fprintf(buf, "%-33s error=stderr level=%s\n", blue.Render("Logs"), srv.ErrLevel.Level())
-		if srv.AuditLevel.Level() <= slog.LevelInfo {
+		if logConf == nil && srv.AuditLevel.Level() <= slog.LevelInfo {
 			fmt.Fprintf(buf, "%-11s audit=stdout level=%s\n", " ", srv.AuditLevel.Level())
 		}
+		if logConf != nil && logConf.AuditLevel <= slog.LevelInfo {
+			fmt.Fprintf(buf, "%-11s audit=stdout level=%s\n", " ", logConf.AuditLevel)
+		}
+		if logConf != nil && logConf.AuditSyslog != nil {
+			fmt.Fprintf(buf, "%-11s audit=syslog addr=%s format=%s\n", " ", logConf.AuditSyslog.Address, logConf.AuditSyslog.Format)
+		}
 		if memLocked {
 			fmt.Fprintf(buf, "%-33s %s\n", blue.Render("MLock"), "enabled")
 		}
+		if conf.FIPS || fips.Enabled() {
+			if module := fips.Module(); module != "" {
+				fmt.Fprintf(buf, "%-33s enabled module=%s\n", blue.Render("FIPS"), module)
+			} else {
+				fmt.Fprintf(buf, "%-33s enabled %s\n", blue.Render("FIPS"), faint.Render("no FIPS 140 module"))
+			}
+		}
 		return buf
 	}
 
@@ -231,9 +342,13 @@ func startServer(addrFlag, configFlag string) error {
 			case <-ctx.Done():
 				return
 			case <-sighup:
+				if srv.Sealed() {
+					fmt.Fprintln(os.Stderr, "SIGHUP signal received. Server is sealed. Not reloading configuration.")
+					continue
+				}
 				fmt.Fprintln(os.Stderr, "SIGHUP signal received. Reloading configuration...")
 
-				file, err := kesconf.ReadFile(configFlag)
+				file, err := kesconf.ReadFileWithEnv(configFlag)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Failed to reload server config: %v\n", err)
 					continue
@@ -244,6 +359,7 @@ func startServer(addrFlag, configFlag string) error {
 					continue
 				}
 				config.Cache = configureCache(config.Cache)
+				config.FaultInjector = faults
 
 				closer, err := srv.Update(config)
 				if err != nil {
@@ -252,13 +368,13 @@ func startServer(addrFlag, configFlag string) error {
 				}
 				if file.Log != nil {
 					srv.ErrLevel.Set(file.Log.ErrLevel)
-					srv.AuditLevel.Set(file.Log.AuditLevel)
+					srv.AuditLevel.Set(auditLevel(file.Log))
 				}
 
 				if err = closer.Close(); err != nil {
 					fmt.Fprintf(os.Stderr, "Failed to close previous keystore connections: %v\n", err)
 				}
-				buf := startupMessage(config)
+				buf := startupMessage(config, file.Log)
 				fmt.Fprintln(buf)
 				fmt.Fprintln(buf, "=> Reloading configuration after SIGHUP signal completed.")
 				fmt.Println(buf.String())
@@ -275,7 +391,10 @@ func startServer(addrFlag, configFlag string) error {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				file, err := kesconf.ReadFile(configFlag)
+				if srv.Sealed() {
+					continue
+				}
+				file, err := kesconf.ReadFileWithEnv(configFlag)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Failed to reload TLS configuration: %v\n", err)
 					continue
@@ -292,9 +411,57 @@ func startServer(addrFlag, configFlag string) error {
 		}
 	}(ctx)
 
-	buf := startupMessage(conf)
+	if sealed != nil {
+		conf.Seal = &kes.SealConfig{
+			Threshold:   sealed.Seal.Threshold,
+			Operators:   sealed.Seal.Operators,
+			VerifyShare: sealed.Seal.VerifyShare,
+			Unseal: func(_ context.Context, rootKey []byte) (*kes.Config, error) {
+				if err := sealed.Seal.Verify(rootKey); err != nil {
+					return nil, err
+				}
+
+				// Encrypted config values are decrypted with the
+				// root key. It also remains the config password
+				// when the config gets reloaded later on.
+				os.Setenv(kesconf.EnvConfigPassword, kesconf.RootKeyPassword(rootKey))
+				file, err := kesconf.ReadFileWithEnv(configFlag)
+				if err != nil {
+					return nil, err
+				}
+				if memLocked, err = protectMemory(file.Memory, memLocked); err != nil {
+					return nil, err
+				}
+				config, err := file.Config(ctx)
+				if err != nil {
+					return nil, err
+				}
+				config.Cache = configureCache(config.Cache)
+				config.FaultInjector = faults
+				if file.Log != nil {
+					srv.ErrLevel.Set(file.Log.ErrLevel)
+					srv.AuditLevel.Set(auditLevel(file.Log))
+				}
+
+				buf := startupMessage(config, file.Log)
+				fmt.Fprintln(buf)
+				fmt.Fprintln(buf, "=> Server has been unsealed.")
+				fmt.Println(buf.String())
+				return config, nil
+			},
+		}
+	}
+
+	buf := startupMessage(conf, rawConfig.Log)
 	fmt.Fprintln(buf)
-	fmt.Fprintln(buf, "=> Server is up and running...")
+	switch {
+	case conf.Seal != nil:
+		fmt.Fprintf(buf, "=> Server is sealed. Waiting for %d unseal key shares at /v1/unseal...\n", conf.Seal.Threshold)
+	case conf.ReadReplica != nil:
+		fmt.Fprintln(buf, "=> Server is up and running as read replica...")
+	default:
+		fmt.Fprintln(buf, "=> Server is up and running...")
+	}
 	fmt.Println(buf.String())
 
 	if err = srv.ListenAndStart(ctx, addrFlag, conf); err != nil {
@@ -304,6 +471,54 @@ func startServer(addrFlag, configFlag string) error {
 	return nil
 }
 
+// autoUnseal unwraps the root key of a sealed server with the
+// external KMS and sets it as config password. Then, the config
+// file can be read as if the server was not sealed.
+func autoUnseal(seal *kesconf.SealConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rootKey, err := seal.Auto.UnwrapRootKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to auto-unseal server: %v", err)
+	}
+	defer secmem.Zero(rootKey)
+
+	if err = seal.Verify(rootKey); err != nil {
+		return fmt.Errorf("failed to auto-unseal server: %v", err)
+	}
+	return os.Setenv(kesconf.EnvConfigPassword, kesconf.RootKeyPassword(rootKey))
+}
+
+// protectMemory applies the memory protection config and reports
+// whether the memory is locked. It unlocks the memory if locking is
+// turned off and fails if locking is required but not possible.
+func protectMemory(conf *kesconf.MemoryConfig, locked bool) (bool, error) {
+	if conf == nil {
+		return locked, nil
+	}
+	switch conf.Lock {
+	case kesconf.MemoryLockOff:
+		if locked {
+			secmem.UnlockAll()
+		}
+		locked = false
+	case kesconf.MemoryLockOn:
+		if !locked {
+			if err := secmem.LockAll(); err != nil {
+				return false, fmt.Errorf("failed to lock memory: %v", err)
+			}
+			locked = true
+		}
+	}
+	if conf.DisableCoreDumps {
+		if err := secmem.DisableCoreDumps(); err != nil {
+			return locked, fmt.Errorf("failed to disable core dumps: %v", err)
+		}
+	}
+	return locked, nil
+}
+
 func startDevServer(addr string) error {
 	info, err := sys.ReadBinaryInfo()
 	if err != nil {
@@ -388,6 +603,17 @@ func startDevServer(addr string) error {
 	return nil
 }
 
+// auditLevel returns the server's audit log level. Audit events
+// are sent to a syslog server regardless of the STDOUT audit
+// level. Hence, the server must log audit events at least at
+// the info level when a syslog server is configured.
+func auditLevel(c *kesconf.LogConfig) slog.Level {
+	if c.AuditSyslog != nil && c.AuditLevel > slog.LevelInfo {
+		return slog.LevelInfo
+	}
+	return c.AuditLevel
+}
+
 // configureCache sets default values for each cache config option
 // as documented in: https://github.com/minio/kes/blob/master/server-config.yaml
 func configureCache(c *kes.CacheConfig) *kes.CacheConfig {
@@ -403,6 +629,62 @@ func configureCache(c *kes.CacheConfig) *kes.CacheConfig {
 	return c
 }
 
+// parseFaults parses the faults specified by the '--fault-inject'
+// flag. Faults are separated by ';' and consist of comma-separated
+// key=value pairs. For example:
+//
+//	ops=get+list,latency=200ms,error=unreachable,every=3
+//
+// The error is either one of "unreachable", "throttled" and "auth",
+// which are reported with the corresponding error code, or an
+// arbitrary error message. It returns nil if s is empty.
+func parseFaults(s string) (*kes.FaultInjector, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	faults := &kes.FaultInjector{}
+	for _, spec := range strings.Split(s, ";") {
+		var fault kes.Fault
+		for _, kv := range strings.Split(spec, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+			if !ok {
+				return nil, fmt.Errorf("fault '%s' is not a key=value pair", kv)
+			}
+
+			var err error
+			switch key {
+			case "ops":
+				fault.Operations = strings.Split(value, "+")
+			case "latency":
+				fault.Latency, err = time.ParseDuration(value)
+			case "every":
+				fault.Every, err = strconv.Atoi(value)
+			case "error":
+				switch value {
+				case "unreachable":
+					fault.Err = &keystore.ErrUnreachable{}
+				case "throttled":
+					fault.Err = &keystore.ErrThrottled{}
+				case "auth":
+					fault.Err = &keystore.ErrAuth{}
+				default:
+					fault.Err = errors.New(value)
+				}
+			default:
+				return nil, fmt.Errorf("unknown fault option '%s'", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid fault option '%s': %v", key, err)
+			}
+		}
+		if err := faults.Inject(fault); err != nil {
+			return nil, err
+		}
+	}
+	return faults, nil
+}
+
 // lookupInterfaceIPs returns a list of IP addrs for which a listener
 // listening on listenerIP is reachable. If listenerIP is not
 // unspecified (0.0.0.0) it returns []net.IP{listenerIP}.
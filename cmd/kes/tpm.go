@@ -0,0 +1,188 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/tpm"
+	flag "github.com/spf13/pflag"
+)
+
+const tpmCmdUsage = `Usage:
+    kes tpm <command>
+
+Commands:
+    seal                     Seal a secret with the TPM.
+    unseal                   Unseal a secret sealed with the TPM.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func tpmCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, tpmCmdUsage) }
+
+	subCmds := commands{
+		"seal":   sealTPMCmd,
+		"unseal": unsealTPMCmd,
+	}
+
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes tpm --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a tpm command. See 'kes tpm --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const sealTPMCmdUsage = `Usage:
+    kes tpm seal [options] <PATH>
+
+Seals a secret read from standard input with the TPM and writes
+the sealed secret to <PATH>. The sealed secret can be referenced
+in the KES server config file as: ${tpm:<PATH>}
+
+Options:
+    --device <PATH>          Path to the TPM device. Defaults to /dev/tpmrm0.
+    --pcrs <LIST>            Comma-separated list of PCRs the secret is bound to.
+                             Defaults to 0,7.
+    -f, --force              Overwrite an existing file.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ echo -n "$VAULT_SECRET_ID" | kes tpm seal --pcrs 0,2,4,7 vault-secret.tpm
+`
+
+func sealTPMCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, sealTPMCmdUsage) }
+
+	var (
+		deviceFlag string
+		pcrsFlag   string
+		forceFlag  bool
+	)
+	cmd.StringVar(&deviceFlag, "device", tpm.DefaultDevice, "Path to the TPM device")
+	cmd.StringVar(&pcrsFlag, "pcrs", "", "Comma-separated list of PCRs")
+	cmd.BoolVarP(&forceFlag, "force", "f", false, "Overwrite an existing file")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes tpm seal --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no file specified. See 'kes tpm seal --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes tpm seal --help'")
+	}
+
+	filename := cmd.Arg(0)
+	if !forceFlag {
+		if _, err := os.Stat(filename); err == nil {
+			cli.Fatalf("'%s' already exists. Use '--force' to overwrite it", filename)
+		}
+	}
+	pcrs, err := tpm.ParsePCRs(pcrsFlag)
+	if err != nil {
+		cli.Fatal(err)
+	}
+
+	secret, err := io.ReadAll(io.LimitReader(os.Stdin, 1<<20))
+	if err != nil {
+		cli.Fatalf("failed to read secret: %v", err)
+	}
+	if secret = bytes.TrimSpace(secret); len(secret) == 0 {
+		cli.Fatal("no secret provided on standard input")
+	}
+
+	rw, err := tpm.Open(deviceFlag)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer rw.Close()
+
+	sealed, err := tpm.Seal(rw, secret, pcrs)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	if err = os.WriteFile(filename, sealed, 0o600); err != nil {
+		cli.Fatal(err)
+	}
+}
+
+const unsealTPMCmdUsage = `Usage:
+    kes tpm unseal [options] <PATH>
+
+Unseals the secret in <PATH> with the TPM and writes it to
+standard output.
+
+Options:
+    --device <PATH>          Path to the TPM device. Defaults to /dev/tpmrm0.
+
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes tpm unseal vault-secret.tpm
+`
+
+func unsealTPMCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, unsealTPMCmdUsage) }
+
+	var deviceFlag string
+	cmd.StringVar(&deviceFlag, "device", tpm.DefaultDevice, "Path to the TPM device")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes tpm unseal --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no file specified. See 'kes tpm unseal --help'")
+	case cmd.NArg() > 1:
+		cli.Fatal("too many arguments. See 'kes tpm unseal --help'")
+	}
+
+	sealed, err := os.ReadFile(cmd.Arg(0))
+	if err != nil {
+		cli.Fatal(err)
+	}
+	rw, err := tpm.Open(deviceFlag)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	defer rw.Close()
+
+	secret, err := tpm.Unseal(rw, sealed)
+	if err != nil {
+		cli.Fatal(err)
+	}
+	fmt.Print(string(secret))
+}
@@ -7,9 +7,13 @@ package kes
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
 	"time"
 
+	"github.com/minio/kes/internal/fips"
+	"github.com/minio/kes/internal/shamir"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -31,6 +35,67 @@ type Config struct {
 	// at least tls.RequestClientCert.
 	TLS *tls.Config
 
+	// FIPS enables FIPS mode. Then, the server only uses FIPS
+	// approved TLS versions, cipher suites, curves and
+	// cryptographic algorithms. For example, it rejects
+	// ChaCha20-Poly1305 keys and, if the TLS config does not
+	// specify cipher suites or curves, only offers AES-GCM
+	// cipher suites and NIST curves.
+	//
+	// FIPS mode applies to the entire process and cannot be
+	// disabled once enabled. It is always enabled when built
+	// with the "fips" build tag.
+	FIPS bool
+
+	// UnixSocket, if not nil, makes the KES server accept requests
+	// from local processes via a Unix domain socket, in addition to
+	// HTTPS. Such requests are not sent via TLS. Instead, the server
+	// identifies the calling process by its user ID.
+	//
+	// Updating the config via Server.Update only changes the set of
+	// identities. The socket remains unchanged.
+	UnixSocket *UnixSocketConfig
+
+	// GRPC enables the KES gRPC API. gRPC requests are served on
+	// the same address as HTTPS requests and are handled by the
+	// corresponding HTTP API. The gRPC API is only available if
+	// the TLS config enables HTTP/2, i.e. its NextProtos contain
+	// "h2". See internal/api/kes.proto for the service definition.
+	GRPC bool
+
+	// KMIP, if not nil, makes the KES server accept KMIP requests
+	// on a separate address. KMIP connections use the same TLS
+	// config as HTTPS. KMIP operations are served by the
+	// corresponding HTTP API and keys are identified by their
+	// names.
+	//
+	// Updating the config via Server.Update does not change the
+	// KMIP address.
+	KMIP *KMIPConfig
+
+	// AWSKMS, if not nil, makes the KES server accept AWS KMS API
+	// requests on a separate address, such that applications using
+	// an AWS KMS SDK can use KES. It supports the Encrypt, Decrypt,
+	// GenerateDataKey and DescribeKey operations. Requests are
+	// authenticated via AWS signatures and served on behalf of the
+	// KES identity of the request's access key. AWS KMS connections
+	// use the same TLS config as HTTPS.
+	//
+	// Updating the config via Server.Update does not change the
+	// AWS KMS API config.
+	AWSKMS *AWSKMSConfig
+
+	// Authz, if not nil, makes the KES server delegate authorization
+	// decisions to an external endpoint, like Open Policy Agent. See
+	// AuthzConfig for how external decisions and policies interact.
+	Authz *AuthzConfig
+
+	// Revocation, if not nil, makes the KES server reject requests
+	// of clients with revoked certificates. It requires a TLS config
+	// that verifies client certificates. See RevocationConfig for
+	// how certificates are checked.
+	Revocation *RevocationConfig
+
 	// Cache specifies how long the KES server caches keys from the
 	// KeyStore. If nil, caching is disabled.
 	Cache *CacheConfig
@@ -40,8 +105,99 @@ type Config struct {
 	Policies map[string]Policy
 
 	// Keys is the KeyStore the KES server fetches keys from.
+	// It is not used, and may be nil, if Seal is set.
 	Keys KeyStore
 
+	// KeyStores is a set of named standby KeyStores. The admin
+	// can switch the server from Keys to one of them, without
+	// a restart, via the keystore switch API. For example, once
+	// all keys have been migrated to a new KeyStore.
+	KeyStores map[string]KeyStore
+
+	// SlowLog, if not nil, makes the KES server log and count
+	// operations of Keys and KeyStores that take longer than a
+	// latency threshold. See SlowLogConfig.
+	SlowLog *SlowLogConfig
+
+	// Anomalies, if not nil, makes the KES server raise alerts
+	// when identities use keys suspiciously. See AnomalyConfig.
+	Anomalies *AnomalyConfig
+
+	// Seal, if not nil, starts the KES server sealed. A sealed
+	// server only serves the version and unseal API until enough
+	// unseal key shares have been submitted. Then, it replaces
+	// this config with the one returned by SealConfig.Unseal.
+	//
+	// While sealed, the server uses no KeyStore. Server.Update
+	// fails until the server has been unsealed and a config
+	// passed to Server.Update must not be sealed.
+	Seal *SealConfig
+
+	// FaultInjector, if not nil, injects faults into all
+	// operations of Keys and KeyStores. It is intended for
+	// testing only. See FaultInjector.
+	FaultInjector *FaultInjector
+
+	// SoftDelete, if not nil, makes the KES server soft-delete
+	// keys. Deleted keys can be restored, via the key undelete
+	// API, until their retention period has elapsed.
+	SoftDelete *SoftDeleteConfig
+
+	// Rotation specifies which keys the KES server rotates
+	// automatically. It contains a set of key names or key
+	// name patterns, like "my-app-*", and the corresponding
+	// rotation configuration. If multiple patterns match a
+	// key name, the longest pattern applies.
+	//
+	// Keys stored on a KeyStore that does not implement
+	// MutableKeyStore cannot be rotated.
+	Rotation map[string]RotationConfig
+
+	// Enclaves is a set of enclaves. Each enclave is an isolated
+	// set of keys, secrets, policies and identities that clients
+	// address via the Kes-Enclave request header. Requests without
+	// such a header are served using Keys and Policies.
+	//
+	// The admin identity has access to all enclaves.
+	Enclaves map[string]EnclaveConfig
+
+	// Peers contains the KES servers sharing the KeyStores with
+	// this server. If set, the server notifies its peers whenever
+	// it creates, rotates or deletes a key such that they evict
+	// the key from their caches. If nil, peers only notice changed
+	// keys once their cache entries expire, unless the KeyStore
+	// implements WatchableKeyStore.
+	Peers *PeerConfig
+
+	// Replication, if not nil, makes the KES server replicate
+	// keys to other KES clusters, such that each cluster can
+	// decrypt data encrypted by the others. See ReplicationConfig.
+	Replication *ReplicationConfig
+
+	// ReadReplica, if not nil, makes the KES server a read
+	// replica that only serves operations reading keys from
+	// a read-only copy of the KeyStore. See ReadReplicaConfig.
+	//
+	// A read replica does not rotate keys automatically and
+	// cannot replicate keys to other KES clusters.
+	ReadReplica *ReadReplicaConfig
+
+	// JWKS, if not nil, makes the KES server publish the public
+	// keys of signing keys as JSON Web Key Set. See JWKSConfig.
+	JWKS *JWKSConfig
+
+	// CA contains a set of certificate authority (CA) profiles.
+	// Each profile issues X.509 certificates signed by a KES key
+	// and restricts which certificates may be issued. Profiles are
+	// addressed by name via the CA APIs. See CAConfig.
+	CA map[string]CAConfig
+
+	// SSHCA contains a set of SSH certificate authority profiles.
+	// Each profile issues OpenSSH user or host certificates signed
+	// by a KES key and restricts the principals and validity period
+	// of issued certificates. See SSHCAConfig.
+	SSHCA map[string]SSHCAConfig
+
 	// Routes allows customization of the KES server API routes. It
 	// contains a set of API route paths, for example "/v1/status",
 	// and the corresponding route configuration.
@@ -59,18 +215,48 @@ type Config struct {
 	// audit log events. If nil, defaults to a slog.TextHandler
 	// writing to os.Stdout. The server's audit log level is
 	// controlled by Server.AuditLevel.
+	//
+	// When Server.Update replaces the AuditLog and the previous
+	// handler implements io.Closer, the server flushes and closes
+	// the previous handler.
 	AuditLog AuditHandler
 }
 
 // Policy is a KES policy with associated identities.
 //
-// A policy contains a set of allow and deny rules.
+// A policy contains a set of allow and deny rules. Each rule is an
+// API path pattern, like "/v1/key/create/my-key*", that matches all
+// request URL paths with the prefix before the optional trailing '*'.
+//
+// Deny rules take precedence over allow rules. A request is allowed
+// if no deny rule but at least one allow rule matches its URL path.
+// Hence, deny rules can carve exceptions out of broader allow rules.
+// For example, allowing "/v1/key/*" but denying "/v1/key/delete/prod-*"
+// grants access to all key APIs except deleting keys starting with
+// "prod-".
 type Policy struct {
 	Allow map[string]kes.Rule // Set of allow rules
 
 	Deny map[string]kes.Rule // Set of deny rules
 
+	// Identities are the identities assigned to the policy. Next
+	// to certificate public key identities, policies may assign
+	// SPIFFE IDs, like "spiffe://example.org/ns/minio/sa/minio",
+	// or SPIFFE ID patterns ending with '*', like
+	// "spiffe://example.org/ns/minio/*". SPIFFE IDs are only
+	// admitted from X.509-SVIDs verified by the server's TLS
+	// config.
 	Identities []kes.Identity
+
+	// Rotation is the rotation configuration of all keys the
+	// policy allows to create. For example, an allow rule for
+	// "/v1/key/create/my-app-*" causes all keys matching the
+	// pattern "my-app-*" to be rotated automatically.
+	//
+	// If multiple policies apply to a key, the shortest rotation
+	// interval applies. Explicit rotation config, specified via
+	// Config.Rotation, takes precedence.
+	Rotation RotationConfig
 }
 
 // CacheConfig is a structure containing the KES server
@@ -104,6 +290,119 @@ type CacheConfig struct {
 	//
 	// Offline caching is disabled if ExpiryOffline <= 0.
 	ExpiryOffline time.Duration
+
+	// DEKSize is the max. number of decrypted data keys
+	// the KES server keeps in memory. Repeated decryption
+	// requests for the same ciphertext are served from the
+	// cache without decrypting the ciphertext again. Once
+	// the cache is full, the least recently used entry is
+	// evicted.
+	//
+	// The data key cache holds plaintext key material. It
+	// should only be enabled if the KES server locks its
+	// memory to prevent cached data keys from being swapped
+	// to disk. The Server does not lock memory itself. The
+	// 'kes server' command locks all memory on Linux. Other
+	// applications have to lock memory themselves, e.g. via
+	// mlockall(2).
+	//
+	// The data key cache is disabled if DEKSize <= 0 or
+	// DEKExpiry <= 0.
+	DEKSize int
+
+	// DEKExpiry controls how long a decrypted data key
+	// resides in the data key cache.
+	DEKExpiry time.Duration
+}
+
+// EnclaveConfig is a structure holding the configuration of an enclave.
+type EnclaveConfig struct {
+	// Keys is the KeyStore the enclave's keys and secrets are
+	// stored on. If nil, they are stored on the server's KeyStore
+	// within a separate namespace of the enclave.
+	Keys KeyStore
+
+	// Policies is a set of policies and identities of the enclave.
+	// They only grant access to the enclave's keys and secrets.
+	Policies map[string]Policy
+}
+
+// UnixSocketConfig is a structure holding the configuration of
+// a KES server's Unix domain socket.
+type UnixSocketConfig struct {
+	// Path is the file system path of the socket. An existing
+	// socket at the path is replaced.
+	Path string
+
+	// Identities maps user IDs of local processes to KES identities.
+	// Requests of processes running as a user not present in the map
+	// are rejected. Otherwise, they are authorized like requests of
+	// the mapped identity.
+	//
+	// The user ID of the calling process is obtained from the socket
+	// peer credentials (SO_PEERCRED). Hence, Unix domain sockets are
+	// only supported on Linux.
+	Identities map[uint32]kes.Identity
+}
+
+// KMIPConfig is a structure holding the configuration of
+// a KES server's KMIP API.
+type KMIPConfig struct {
+	// Address is the network address the KMIP API listens
+	// on. For example: "0.0.0.0:5696".
+	Address string
+}
+
+// AWSKMSConfig is a structure holding the configuration of
+// a KES server's AWS KMS API.
+type AWSKMSConfig struct {
+	// Address is the network address the AWS KMS API
+	// listens on. For example: "0.0.0.0:7374".
+	Address string
+
+	// Region is the AWS region clients send requests to,
+	// e.g. "us-east-1". Requests signed for any other
+	// region are rejected.
+	Region string
+
+	// Credentials maps AWS access keys to their secret
+	// keys and KES identities.
+	Credentials map[string]AWSCredential
+}
+
+// AWSCredential is an AWS secret key and the KES identity
+// requests signed with the secret key are served for.
+type AWSCredential struct {
+	SecretKey string
+	Identity  kes.Identity
+}
+
+// PeerConfig is a structure holding the configuration of
+// a KES server's peers.
+type PeerConfig struct {
+	// Endpoints are the HTTPS endpoints of the peers. For
+	// example: "https://kes-2.local:7373".
+	Endpoints []string
+
+	// TLS is the TLS client configuration used to connect
+	// to the peers. It should contain a client certificate
+	// with an identity that is allowed to access the peer
+	// notify API on all peers. Notifications about keys of
+	// an enclave are sent to that enclave. Hence, the
+	// identity should be the admin or be allowed to access
+	// the peer notify API within each enclave.
+	TLS *tls.Config
+}
+
+// RotationConfig is a structure holding key rotation configuration.
+type RotationConfig struct {
+	// Interval is the time period after which the KES server
+	// adds a new version to a key. The latest key version is
+	// used to encrypt new data while previous versions remain
+	// available for decryption.
+	//
+	// Automatic rotation is disabled if Interval <= 0.
+	Interval time.Duration
 }
 
 // RouteConfig is a structure holding API route configuration.
@@ -136,6 +435,22 @@ type RouteConfig struct {
 	// client auth type has been set  tls.RequireAnyClientCert
 	// or tls.RequireAndVerifyClientCert.
 	InsecureSkipAuth bool
+
+	// RateLimit is the max. number of requests per second the
+	// API route accepts from all clients combined. Requests
+	// exceeding the limit are rejected with HTTP 429 Too Many
+	// Requests.
+	//
+	// If <= 0, requests are not rate limited.
+	RateLimit float64
+
+	// IdentityRateLimit is the max. number of requests per
+	// second the API route accepts from a single identity.
+	// It prevents noisy clients from exhausting the RateLimit
+	// shared by all clients, and thereby, the KeyStore.
+	//
+	// If <= 0, requests are not rate limited per identity.
+	IdentityRateLimit float64
 }
 
 // verifyConfig reports whether the c is a valid Config
@@ -148,8 +463,185 @@ func verifyConfig(c *Config) error {
 	if c.TLS.ClientAuth == tls.NoClientCert {
 		return errors.New("kes: tls client auth must request client certificate")
 	}
-	if c.Keys == nil {
+	if c.FIPS || fips.Enabled() {
+		if err := verifyFIPSTLS(c.TLS); err != nil {
+			return err
+		}
+	}
+	if c.Keys == nil && c.Seal == nil {
 		return errors.New("kes: config contains no key store")
 	}
+	if c.Seal != nil {
+		if c.Seal.Threshold < 2 || c.Seal.Threshold > shamir.MaxShares {
+			return fmt.Errorf("kes: invalid unseal threshold '%d'", c.Seal.Threshold)
+		}
+		if c.Seal.Unseal == nil {
+			return errors.New("kes: seal config contains no unseal function")
+		}
+		for _, identity := range c.Seal.Operators {
+			if identity.IsUnknown() {
+				return errors.New("kes: seal config contains an empty unseal operator identity")
+			}
+		}
+	}
+	for name, store := range c.KeyStores {
+		if !validName(name) {
+			return fmt.Errorf("kes: key store name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if store == nil {
+			return fmt.Errorf("kes: key store '%s' is nil", name)
+		}
+	}
+	if c.UnixSocket != nil {
+		if c.UnixSocket.Path == "" {
+			return errors.New("kes: unix socket path is empty")
+		}
+		for uid, identity := range c.UnixSocket.Identities {
+			if identity.IsUnknown() || !validName(identity.String()) {
+				return fmt.Errorf("kes: invalid identity '%s' for unix user '%d'", identity, uid)
+			}
+		}
+	}
+	if c.KMIP != nil && c.KMIP.Address == "" {
+		return errors.New("kes: KMIP address is empty")
+	}
+	if c.AWSKMS != nil {
+		if c.AWSKMS.Address == "" {
+			return errors.New("kes: AWS KMS address is empty")
+		}
+		if c.AWSKMS.Region == "" {
+			return errors.New("kes: AWS KMS region is empty")
+		}
+		for accessKey, cred := range c.AWSKMS.Credentials {
+			if accessKey == "" || cred.SecretKey == "" {
+				return fmt.Errorf("kes: invalid AWS KMS credentials for access key '%s'", accessKey)
+			}
+			if cred.Identity.IsUnknown() || !validName(cred.Identity.String()) {
+				return fmt.Errorf("kes: invalid identity '%s' for AWS access key '%s'", cred.Identity, accessKey)
+			}
+		}
+	}
+	if c.Authz != nil {
+		if u, err := url.Parse(c.Authz.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("kes: invalid authorization endpoint '%s'", c.Authz.Endpoint)
+		}
+		if c.Authz.Format != AuthzOPA && c.Authz.Format != AuthzWebhook {
+			return fmt.Errorf("kes: invalid authorization format '%d'", c.Authz.Format)
+		}
+		if c.Authz.Timeout < 0 || c.Authz.CacheExpiry < 0 {
+			return errors.New("kes: invalid authorization timeout or cache expiry")
+		}
+	}
+	if c.Revocation != nil {
+		if c.TLS.ClientAuth != tls.VerifyClientCertIfGiven && c.TLS.ClientAuth != tls.RequireAndVerifyClientCert {
+			return errors.New("kes: certificate revocation checking requires verified client certificates")
+		}
+		if c.Revocation.CRLRefresh < 0 {
+			return fmt.Errorf("kes: invalid CRL refresh interval '%v'", c.Revocation.CRLRefresh)
+		}
+		if len(c.Revocation.CRLs) == 0 && !c.Revocation.OCSP {
+			return errors.New("kes: certificate revocation checking requires CRLs or OCSP")
+		}
+	}
+	if c.SlowLog != nil {
+		if c.SlowLog.Threshold < 0 {
+			return fmt.Errorf("kes: invalid slow log threshold '%v'", c.SlowLog.Threshold)
+		}
+		for op, threshold := range c.SlowLog.Operations {
+			if !validSlowOp(op) {
+				return fmt.Errorf("kes: invalid slow log operation '%s'", op)
+			}
+			if threshold < 0 {
+				return fmt.Errorf("kes: invalid slow log threshold '%v' for operation '%s'", threshold, op)
+			}
+		}
+	}
+	if c.Anomalies != nil {
+		if c.Anomalies.DecryptRate < 0 {
+			return fmt.Errorf("kes: invalid anomaly decrypt rate '%d'", c.Anomalies.DecryptRate)
+		}
+		if c.Anomalies.DormantPeriod < 0 {
+			return fmt.Errorf("kes: invalid anomaly dormant period '%v'", c.Anomalies.DormantPeriod)
+		}
+		if c.Anomalies.Webhook != "" {
+			if u, err := url.Parse(c.Anomalies.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("kes: invalid anomaly webhook '%s'", c.Anomalies.Webhook)
+			}
+		}
+	}
+	if c.JWKS != nil {
+		for _, name := range c.JWKS.Keys {
+			if !validName(name) {
+				return fmt.Errorf("kes: JWKS key name '%s' is empty, too long or contains invalid characters", name)
+			}
+		}
+	}
+	for name, profile := range c.CA {
+		if !validName(name) {
+			return fmt.Errorf("kes: CA profile name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if !validName(profile.Key) {
+			return fmt.Errorf("kes: CA key name '%s' of profile '%s' is empty, too long or contains invalid characters", profile.Key, name)
+		}
+		if profile.MaxTTL < 0 {
+			return fmt.Errorf("kes: invalid CA max. TTL '%v' for profile '%s'", profile.MaxTTL, name)
+		}
+		if len(profile.DNSNames) == 0 && len(profile.IPRanges) == 0 {
+			return fmt.Errorf("kes: CA profile '%s' allows neither DNS names nor IP addresses", name)
+		}
+	}
+	for name, profile := range c.SSHCA {
+		if !validName(name) {
+			return fmt.Errorf("kes: SSH CA profile name '%s' is empty, too long or contains invalid characters", name)
+		}
+		if !validName(profile.Key) {
+			return fmt.Errorf("kes: SSH CA key name '%s' of profile '%s' is empty, too long or contains invalid characters", profile.Key, name)
+		}
+		if profile.MaxTTL < 0 {
+			return fmt.Errorf("kes: invalid SSH CA max. TTL '%v' for profile '%s'", profile.MaxTTL, name)
+		}
+		if len(profile.Principals) == 0 {
+			return fmt.Errorf("kes: SSH CA profile '%s' allows no principals", name)
+		}
+	}
+	if c.SoftDelete != nil && c.SoftDelete.Retention <= 0 {
+		return fmt.Errorf("kes: invalid soft delete retention '%v'", c.SoftDelete.Retention)
+	}
+	if c.Peers != nil {
+		for _, endpoint := range c.Peers.Endpoints {
+			if u, err := url.Parse(endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("kes: invalid peer endpoint '%s'", endpoint)
+			}
+		}
+	}
+	if c.Replication != nil {
+		if c.Replication.Site == "" || !validName(c.Replication.Site) {
+			return fmt.Errorf("kes: replication site '%s' is empty, too long or is invalid", c.Replication.Site)
+		}
+		for _, endpoint := range c.Replication.Endpoints {
+			if u, err := url.Parse(endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("kes: invalid replication endpoint '%s'", endpoint)
+			}
+		}
+		if c.Replication.SyncInterval < 0 {
+			return fmt.Errorf("kes: invalid replication sync interval '%v'", c.Replication.SyncInterval)
+		}
+	}
+	if c.ReadReplica != nil {
+		if c.ReadReplica.SyncInterval < 0 {
+			return fmt.Errorf("kes: invalid read replica sync interval '%v'", c.ReadReplica.SyncInterval)
+		}
+		if c.Replication != nil {
+			return errors.New("kes: a read replica cannot replicate keys")
+		}
+	}
+	for pattern, rotation := range c.Rotation {
+		if pattern == "" || !validPattern(pattern) {
+			return fmt.Errorf("kes: key rotation pattern '%s' is empty, too long or is invalid", pattern)
+		}
+		if rotation.Interval < 0 {
+			return fmt.Errorf("kes: invalid key rotation interval '%v' for '%s'", rotation.Interval, pattern)
+		}
+	}
 	return nil
 }
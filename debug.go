@@ -0,0 +1,160 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kms-go/kes"
+)
+
+// Special profiles of the pprof API that are not runtime/pprof
+// profiles.
+const (
+	profileCPU   = "profile" // CPU profile over the requested duration
+	profileTrace = "trace"   // Execution trace over the requested duration
+)
+
+const (
+	defaultProfileDuration = 30 * time.Second
+	maxProfileDuration     = 60 * time.Second
+)
+
+// pprof is a HandlerFunc that sends runtime profiles in the pprof
+// format to the client. It is restricted to the admin identity since
+// profiles reveal internals of the server, like stack traces.
+//
+// The resource is the name of the profile, e.g. "heap" or "goroutine",
+// or "profile" and "trace" for a CPU profile and an execution trace.
+// The latter record the server for the duration of the "seconds" query
+// parameter. If the resource is empty, the list of available profiles
+// is sent.
+func (s *Server) pprof(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin || req.Enclave != "" {
+		resp.Failr(kes.ErrNotAllowed)
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	switch name := req.Resource; name {
+	case "":
+		names := []string{profileCPU, profileTrace}
+		for _, p := range pprof.Profiles() {
+			names = append(names, p.Name())
+		}
+		slices.Sort(names)
+
+		state.Audit.Log("listed runtime profiles", StatusOK, req)
+		api.ReplyWith(resp, StatusOK, names)
+	case profileCPU, profileTrace:
+		duration := defaultProfileDuration
+		if v := req.URL.Query().Get("seconds"); v != "" {
+			seconds, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || seconds == 0 || time.Duration(seconds)*time.Second > maxProfileDuration {
+				resp.Failf(http.StatusBadRequest, "invalid profile duration: must be between 1 and %d seconds", int(maxProfileDuration.Seconds()))
+				return
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+
+		// The profiler writes to the response as soon as recording starts.
+		// Hence, the headers have to be set before. Only one CPU profile and
+		// one execution trace can be recorded at the same time.
+		resp.Header().Set(headers.ContentType, headers.ContentTypeBinary)
+		resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		start, stop := pprof.StartCPUProfile, pprof.StopCPUProfile
+		if name == profileTrace {
+			start, stop = trace.Start, trace.Stop
+		}
+		if err := start(resp); err != nil {
+			resp.Header().Del(headers.ContentType)
+			resp.Header().Del("Content-Disposition")
+			resp.Failf(http.StatusConflict, "failed to start %s: %v", name, err)
+			return
+		}
+		state.Audit.Log(fmt.Sprintf("recording %s for %v", name, duration), StatusOK, req)
+
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+		}
+		stop()
+	default:
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			resp.Failf(http.StatusNotFound, "profile '%s' not found", name)
+			return
+		}
+		debug, _ := strconv.Atoi(req.URL.Query().Get("debug"))
+		if name == "heap" && req.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+
+		if debug > 0 {
+			resp.Header().Set(headers.ContentType, headers.ContentTypeText)
+		} else {
+			resp.Header().Set(headers.ContentType, headers.ContentTypeBinary)
+			resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		}
+		state.Audit.Log(fmt.Sprintf("sent %s profile", name), StatusOK, req)
+		resp.WriteHeader(StatusOK)
+		profile.WriteTo(resp, debug)
+	}
+}
+
+// runtimeStats is a HandlerFunc that sends runtime diagnostics,
+// like the number of goroutines, GC and cache statistics, to the
+// client. It is restricted to the admin identity.
+func (s *Server) runtimeStats(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if req.Identity != state.Admin || req.Enclave != "" {
+		resp.Failr(kes.ErrNotAllowed)
+		return
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	stats := api.RuntimeStatsResponse{
+		GoVersion:   runtime.Version(),
+		UpTime:      uint64(time.Since(state.StartTime).Round(time.Second).Seconds()),
+		CPUs:        runtime.NumCPU(),
+		UsableCPUs:  runtime.GOMAXPROCS(0),
+		Goroutines:  runtime.NumGoroutine(),
+		CgoCalls:    runtime.NumCgoCall(),
+		HeapAlloc:   memStats.HeapAlloc,
+		HeapInuse:   memStats.HeapInuse,
+		HeapObjects: memStats.HeapObjects,
+		StackAlloc:  memStats.StackSys,
+		Sys:         memStats.Sys,
+
+		GCCycles:    memStats.NumGC,
+		GCPauseTime: time.Duration(memStats.PauseTotalNs).Microseconds(),
+		GCCPU:       memStats.GCCPUFraction,
+
+		CachedKeys: len(state.Keys.cache.Keys()),
+	}
+	if memStats.LastGC > 0 {
+		stats.GCLast = time.Unix(0, int64(memStats.LastGC)).UTC()
+	}
+	if state.Keys.deks != nil {
+		stats.CachedDEKs = state.Keys.deks.Len()
+	}
+
+	state.Audit.Log("sent runtime stats", http.StatusOK, req)
+	api.ReplyWith(resp, http.StatusOK, stats)
+}
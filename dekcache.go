@@ -0,0 +1,177 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/secmem"
+)
+
+// dekCache is a bounded in-memory cache for decrypted data keys.
+// It maps the operation, key name, ciphertext and associated data
+// of a decrypt or unwrap request to the plaintext such that repeated
+// requests for the same ciphertext don't have to decrypt it again.
+//
+// Entries expire after a fixed period of time. Once the cache is
+// full, the least recently used entry gets evicted.
+//
+// Plaintexts are kept in secure buffers that are locked into RAM,
+// if possible, excluded from core dumps and zeroed once an entry
+// is evicted or expired.
+type dekCache struct {
+	size   int
+	expiry time.Duration
+
+	mu      sync.Mutex
+	lru     list.List // *dekEntry, most recently used at the front
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type dekEntry struct {
+	ID        [sha256.Size]byte
+	Name      string
+	Plaintext *secmem.Buffer
+	ExpiresAt time.Time
+}
+
+// newDEKCache returns a new dekCache holding at most size entries
+// for the given expiry. It returns nil if size or expiry is <= 0.
+func newDEKCache(size int, expiry time.Duration) *dekCache {
+	if size <= 0 || expiry <= 0 {
+		return nil
+	}
+	return &dekCache{
+		size:    size,
+		expiry:  expiry,
+		entries: make(map[[sha256.Size]byte]*list.Element, size),
+	}
+}
+
+// Get returns a copy of the cached plaintext for the given
+// entry ID, if present.
+func (c *dekCache) Get(id [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*dekEntry)
+	if time.Now().After(entry.ExpiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return slices.Clone(entry.Plaintext.Bytes()), true
+}
+
+// Add adds a copy of the plaintext decrypted with the named key
+// to the cache. The id must have been computed via dekID.
+func (c *dekCache) Add(id [sha256.Size]byte, name string, plaintext []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+
+	buf := secmem.New(len(plaintext))
+	copy(buf.Bytes(), plaintext)
+	c.entries[id] = c.lru.PushFront(&dekEntry{
+		ID:        id,
+		Name:      name,
+		Plaintext: buf,
+		ExpiresAt: time.Now().Add(c.expiry),
+	})
+}
+
+// DeleteKey removes all entries of the named key.
+func (c *dekCache) DeleteKey(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*dekEntry).Name == name {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// DeleteExpired removes all expired entries.
+func (c *dekCache) DeleteExpired() {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if now.After(elem.Value.(*dekEntry).ExpiresAt) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *dekCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// DeleteAll removes all entries.
+func (c *dekCache) DeleteAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; elem = c.lru.Front() {
+		c.remove(elem)
+	}
+}
+
+// remove removes the list element from the cache and destroys
+// its plaintext. The caller must hold the lock.
+func (c *dekCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*dekEntry)
+	delete(c.entries, entry.ID)
+	entry.Plaintext.Destroy()
+}
+
+// dekID returns a collision-resistant identifier for the combination
+// of operation, key name, ciphertext and associated data.
+func dekID(op, name string, ciphertext, associatedData []byte) [sha256.Size]byte {
+	h := sha256.New()
+	writeWithLength(h, []byte(op))
+	writeWithLength(h, []byte(name))
+	writeWithLength(h, ciphertext)
+	writeWithLength(h, associatedData)
+
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
+
+func writeWithLength(h hash.Hash, b []byte) {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(b)))
+	h.Write(n[:])
+	h.Write(b)
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDEKCache(t *testing.T) {
+	t.Parallel()
+
+	if c := newDEKCache(0, time.Minute); c != nil {
+		t.Fatal("Cache should be disabled for size 0")
+	}
+	if c := newDEKCache(2, 0); c != nil {
+		t.Fatal("Cache should be disabled for expiry 0")
+	}
+
+	c := newDEKCache(2, time.Minute)
+	var (
+		id1 = dekID("decrypt", "key-1", []byte("ciphertext-1"), nil)
+		id2 = dekID("decrypt", "key-1", []byte("ciphertext-2"), nil)
+		id3 = dekID("decrypt", "key-2", []byte("ciphertext-1"), nil)
+	)
+	if id1 == dekID("unwrap", "key-1", []byte("ciphertext-1"), nil) {
+		t.Fatal("Decrypt and unwrap entries must not share an ID")
+	}
+	if id1 == dekID("decrypt", "key-1", []byte("ciphertext-"), []byte("1")) {
+		t.Fatal("Ciphertext and associated data must not be ambiguous")
+	}
+
+	plaintext := []byte("plaintext-1")
+	c.Add(id1, "key-1", plaintext)
+	plaintext[0] = 'P' // The cache must hold its own copy
+	if p, ok := c.Get(id1); !ok || !bytes.Equal(p, []byte("plaintext-1")) {
+		t.Fatalf("Invalid cache entry: got '%s' - want '%s'", p, "plaintext-1")
+	}
+
+	// Adding a third entry evicts the least recently used one.
+	c.Add(id2, "key-1", []byte("plaintext-2"))
+	c.Get(id1)
+	c.Add(id3, "key-2", []byte("plaintext-3"))
+	if _, ok := c.Get(id2); ok {
+		t.Fatal("Least recently used entry has not been evicted")
+	}
+	if _, ok := c.Get(id1); !ok {
+		t.Fatal("Recently used entry has been evicted")
+	}
+
+	c.DeleteKey("key-1")
+	if _, ok := c.Get(id1); ok {
+		t.Fatal("Entry of deleted key is still cached")
+	}
+	if _, ok := c.Get(id3); !ok {
+		t.Fatal("Entry of other key has been removed")
+	}
+
+	c.DeleteAll()
+	if _, ok := c.Get(id3); ok {
+		t.Fatal("Entry has not been removed")
+	}
+}
+
+func TestDEKCacheExpiry(t *testing.T) {
+	t.Parallel()
+
+	c := newDEKCache(10, time.Millisecond)
+	id := dekID("decrypt", "my-key", []byte("ciphertext"), nil)
+	c.Add(id, "my-key", []byte("plaintext"))
+
+	entry := c.entries[id].Value.(*dekEntry)
+	time.Sleep(5 * time.Millisecond)
+	c.DeleteExpired()
+
+	if _, ok := c.Get(id); ok {
+		t.Fatal("Expired entry is still cached")
+	}
+	if entry.Plaintext.Len() != 0 {
+		t.Fatal("Plaintext of expired entry has not been destroyed")
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// enclave is an isolated set of keys, secrets, policies and
+// identities. Clients address an enclave by sending its name
+// as Kes-Enclave header. Requests without such a header are
+// served by the server's default enclave.
+type enclave struct {
+	Keys       *keyCache
+	Policies   map[string]*kes.Policy
+	Identities map[kes.Identity]identityEntry
+	Rotation   map[string]RotationConfig // Derived from the enclave's policies
+}
+
+// enclaveSet is a set of enclaves. Closing an enclaveSet
+// closes the key stores of all its enclaves.
+type enclaveSet map[string]*enclave
+
+// Close closes the key caches of all enclaves. It returns
+// the first error encountered, if any.
+func (e enclaveSet) Close() error {
+	var err error
+	for _, enclave := range e {
+		if enclave.Keys == nil {
+			continue
+		}
+		if cErr := enclave.Keys.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// initEnclaves returns a new enclaveSet with the policies
+// and identities of the enclave configs. The key caches of
+// the returned enclaves are nil. They are created once the
+// server state gets updated, via openEnclaves.
+func initEnclaves(enclaves map[string]EnclaveConfig) (enclaveSet, error) {
+	set := make(enclaveSet, len(enclaves))
+	for name, conf := range enclaves {
+		if !validName(name) {
+			return nil, fmt.Errorf("kes: enclave name '%s' is empty, too long or contains invalid characters", name)
+		}
+
+		policies, identities, err := initPolicies(conf.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("%v in enclave '%s'", err, name)
+		}
+		set[name] = &enclave{
+			Policies:   policies,
+			Identities: identities,
+			Rotation:   policyRotation(conf.Policies),
+		}
+	}
+	return set, nil
+}
+
+// openEnclaves creates the key caches of all enclaves of the state.
+// Enclaves without their own KeyStore store their entries on the
+// KeyStore of the state's default enclave within a separate namespace.
+func openEnclaves(state *serverState, conf *Config) {
+	for name, enclave := range state.Enclaves {
+		store := newSlowLogKeyStore(newFaultKeyStore(conf.Enclaves[name].Keys, conf.FaultInjector), conf.SlowLog, state.Log, state.Metrics)
+		if store == nil {
+			store = newEnclaveKeyStore(state.Keys.store, enclavePrefix(name))
+		}
+		enclave.Keys = newCache(store, conf.Cache)
+		enclave.Keys.softDeletes = state.SoftDelete
+	}
+}
+
+// enclave returns the enclave addressed by the request. It returns
+// the default enclave if the request does not specify an enclave.
+//
+// If the enclave does not exist, for example because it has been
+// removed after the request has been authenticated, all operations
+// on the returned enclave fail with kes.ErrEnclaveNotFound.
+func (s *serverState) enclave(req *api.Request) *enclave {
+	if req.Enclave == "" {
+		return &enclave{
+			Keys:       s.Keys,
+			Policies:   s.Policies,
+			Identities: s.Identities,
+			Rotation:   s.PolicyRotation,
+		}
+	}
+	if enclave, ok := s.Enclaves[req.Enclave]; ok {
+		return enclave
+	}
+	return &enclave{
+		Keys: &keyCache{store: missingEnclave{}, stop: func() {}},
+	}
+}
+
+// enclavePrefix returns the namespace prefix of all entries
+// of the named enclave on a shared KeyStore.
+//
+// Names of valid keys and secrets never start with a hyphen.
+// Hence, entries with a hyphen prefix are reserved for internal
+// use. The enclave name length makes the prefix unambiguous
+// even though enclave names may contain hyphens.
+func enclavePrefix(name string) string {
+	return "-enclave-" + strconv.Itoa(len(name)) + "-" + name + "-"
+}
+
+// newEnclaveKeyStore returns a KeyStore that stores its entries
+// on the given KeyStore within the namespace of the prefix. The
+// returned KeyStore implements WatchableKeyStore if and only if
+// the given KeyStore does.
+func newEnclaveKeyStore(store KeyStore, prefix string) KeyStore {
+	s := &enclaveKeyStore{
+		store:  store,
+		prefix: prefix,
+	}
+	if _, ok := store.(WatchableKeyStore); ok {
+		return &watchableEnclaveKeyStore{s}
+	}
+	return s
+}
+
+// asEnclaveKeyStore returns the KeyStore as enclaveKeyStore, if it
+// stores the entries of an enclave on a shared KeyStore.
+func asEnclaveKeyStore(store KeyStore) (*enclaveKeyStore, bool) {
+	switch s := store.(type) {
+	case *enclaveKeyStore:
+		return s, true
+	case *watchableEnclaveKeyStore:
+		return s.enclaveKeyStore, true
+	default:
+		return nil, false
+	}
+}
+
+// enclaveKeyStore is a KeyStore that stores the entries of an
+// enclave on a KeyStore shared with other enclaves. It prefixes
+// all entry names with the enclave's namespace.
+type enclaveKeyStore struct {
+	store  KeyStore
+	prefix string
+}
+
+// watchableEnclaveKeyStore is an enclaveKeyStore on top of a
+// shared WatchableKeyStore.
+type watchableEnclaveKeyStore struct {
+	*enclaveKeyStore
+}
+
+var _ WatchableKeyStore = (*watchableEnclaveKeyStore)(nil) // compiler check
+
+// Watch returns a channel that receives an event whenever an entry
+// within the enclave namespace, whose name starts with the given
+// prefix, is created, replaced or deleted. The enclave namespace is
+// removed from the event names.
+func (s *watchableEnclaveKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	events, err := s.store.(WatchableKeyStore).Watch(ctx, s.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan KeyStoreEvent, 64)
+	go func() {
+		defer close(ch)
+
+		for event := range events {
+			event.Name = strings.TrimPrefix(event.Name, s.prefix)
+			select {
+			case ch <- event:
+			default: // Drop the event, like the shared KeyStore, if the receiver does not keep up
+			}
+		}
+	}()
+	return ch, nil
+}
+
+var ( // compiler checks
+	_ ConditionalKeyStore = (*enclaveKeyStore)(nil)
+	_ DataKeyStore        = (*enclaveKeyStore)(nil)
+	_ ExpiringKeyStore    = (*enclaveKeyStore)(nil)
+	_ MetadataKeyStore    = (*enclaveKeyStore)(nil)
+	_ PaginatedKeyStore   = (*enclaveKeyStore)(nil)
+)
+
+// Status returns the current state of the shared KeyStore.
+func (s *enclaveKeyStore) Status(ctx context.Context) (KeyStoreState, error) {
+	return s.store.Status(ctx)
+}
+
+// Create creates a new entry within the enclave namespace.
+func (s *enclaveKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	return s.store.Create(ctx, s.prefix+name, value)
+}
+
+// CreateWithTTL creates a new entry within the enclave namespace
+// that expires after the given ttl. It creates the entry without
+// a TTL if the shared KeyStore does not implement ExpiringKeyStore.
+func (s *enclaveKeyStore) CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error {
+	return createWithTTL(ctx, s.store, s.prefix+name, value, ttl)
+}
+
+// Set replaces the value of an existing entry within the enclave
+// namespace. It fails if the shared KeyStore is not mutable.
+func (s *enclaveKeyStore) Set(ctx context.Context, name string, value []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	return store.Set(ctx, s.prefix+name, value)
+}
+
+// SetIf replaces the value of an existing entry within the enclave
+// namespace if its current value is equal to old. It replaces the
+// value unconditionally if the shared KeyStore does not implement
+// ConditionalKeyStore and fails if it is not mutable.
+func (s *enclaveKeyStore) SetIf(ctx context.Context, name string, value, old []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	return setIf(ctx, store, s.prefix+name, value, old)
+}
+
+// Metadata returns the metadata of the entry within the enclave
+// namespace. It returns an empty EntryMetadata if the shared
+// KeyStore does not implement MetadataKeyStore.
+func (s *enclaveKeyStore) Metadata(ctx context.Context, name string) (EntryMetadata, error) {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return EntryMetadata{}, nil
+	}
+	return store.Metadata(ctx, s.prefix+name)
+}
+
+// SetMetadata stores the metadata of the entry within the enclave
+// namespace. It does nothing if the shared KeyStore does not
+// implement MetadataKeyStore.
+func (s *enclaveKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return nil
+	}
+	return store.SetMetadata(ctx, s.prefix+name, metadata)
+}
+
+// CreateKey creates a new key within the enclave namespace
+// at the shared KeyStore. It fails if the shared KeyStore is
+// not a CryptoKeyStore.
+func (s *enclaveKeyStore) CreateKey(ctx context.Context, name string) error {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return errKeyMaterialNotSupported
+	}
+	return store.CreateKey(ctx, s.prefix+name)
+}
+
+// Encrypt encrypts the plaintext with the key within the enclave
+// namespace at the shared KeyStore. It fails if the shared KeyStore
+// is not a CryptoKeyStore.
+func (s *enclaveKeyStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	return store.Encrypt(ctx, s.prefix+name, plaintext, associatedData)
+}
+
+// GenerateKey generates a data key with the key within the enclave
+// namespace at the shared KeyStore. It fails if the shared KeyStore
+// is not a CryptoKeyStore.
+func (s *enclaveKeyStore) GenerateKey(ctx context.Context, name string, associatedData []byte) ([]byte, []byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, nil, errKeyMaterialNotSupported
+	}
+	return generateKey(ctx, store, s.prefix+name, associatedData)
+}
+
+// Decrypt decrypts the ciphertext with the key within the enclave
+// namespace at the shared KeyStore. It fails if the shared KeyStore
+// is not a CryptoKeyStore.
+func (s *enclaveKeyStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	return store.Decrypt(ctx, s.prefix+name, ciphertext, associatedData)
+}
+
+// Delete removes the entry from the enclave namespace.
+func (s *enclaveKeyStore) Delete(ctx context.Context, name string) error {
+	return s.store.Delete(ctx, s.prefix+name)
+}
+
+// Get returns the value of the entry within the enclave namespace.
+func (s *enclaveKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	return s.store.Get(ctx, s.prefix+name)
+}
+
+// List returns the first n entry names within the enclave namespace
+// that start with the given prefix.
+func (s *enclaveKeyStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	names, prefix, err := s.store.List(ctx, s.prefix+prefix, n)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range names {
+		names[i] = strings.TrimPrefix(names[i], s.prefix)
+	}
+	return names, strings.TrimPrefix(prefix, s.prefix), nil
+}
+
+// ListFrom behaves like List but continues the listing at the
+// given entry name.
+func (s *enclaveKeyStore) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
+	if continueAt != "" {
+		continueAt = s.prefix + continueAt
+	}
+	names, continueAt, err := listFrom(ctx, s.store, s.prefix+prefix, continueAt, n)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range names {
+		names[i] = strings.TrimPrefix(names[i], s.prefix)
+	}
+	return names, strings.TrimPrefix(continueAt, s.prefix), nil
+}
+
+// Close does nothing. The shared KeyStore is closed by the
+// server's default enclave.
+func (s *enclaveKeyStore) Close() error { return nil }
+
+// missingEnclave is a KeyStore of an enclave that does not exist.
+// All operations fail with kes.ErrEnclaveNotFound.
+type missingEnclave struct{}
+
+func (missingEnclave) Status(context.Context) (KeyStoreState, error) {
+	return KeyStoreState{}, kes.ErrEnclaveNotFound
+}
+
+func (missingEnclave) Create(context.Context, string, []byte) error {
+	return kes.ErrEnclaveNotFound
+}
+
+func (missingEnclave) Delete(context.Context, string) error {
+	return kes.ErrEnclaveNotFound
+}
+
+func (missingEnclave) Get(context.Context, string) ([]byte, error) {
+	return nil, kes.ErrEnclaveNotFound
+}
+
+func (missingEnclave) List(context.Context, string, int) ([]string, string, error) {
+	return nil, "", kes.ErrEnclaveNotFound
+}
+
+func (missingEnclave) Close() error { return nil }
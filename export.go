@@ -0,0 +1,161 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+const (
+	// exportAlgorithm is the algorithm used to encrypt exported
+	// keys. The encoded key is encrypted with a random AES-256-GCM
+	// key that is wrapped with the transport public key using
+	// RSA-OAEP with SHA-256.
+	exportAlgorithm = "RSAES_OAEP_SHA_256_AES_256_GCM"
+
+	// minExportKeySize is the minimal size of an RSA transport
+	// key in bits.
+	minExportKeySize = 2048
+)
+
+// parseTransportKey parses b as PKIX, ASN.1 DER encoded RSA public
+// key that is used to wrap exported keys.
+func parseTransportKey(b []byte) (*rsa.PublicKey, error) {
+	pub, err := x509.ParsePKIXPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("kes: transport key is not an RSA public key")
+	}
+	if key.N.BitLen() < minExportKeySize {
+		return nil, errors.New("kes: transport key is too small")
+	}
+	return key, nil
+}
+
+// wrapExport encrypts the plaintext under the transport public key.
+// It returns the wrapped data encryption key and the ciphertext, which
+// consists of the GCM nonce followed by the encrypted plaintext.
+//
+// The key name is used as OAEP label and as associated data such that
+// the exported key cannot be unwrapped under a different name.
+func wrapExport(publicKey *rsa.PublicKey, name string, plaintext []byte) (wrappedKey, ciphertext []byte, err error) {
+	var dek [32]byte
+	if _, err = rand.Read(dek[:]); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(dek[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	ciphertext = aead.Seal(nonce, nonce, plaintext, []byte(name))
+
+	wrappedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, dek[:], []byte(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return wrappedKey, ciphertext, nil
+}
+
+// unwrapExport decrypts a key exported by wrapExport using the
+// private transport key.
+func unwrapExport(privateKey *rsa.PrivateKey, name string, wrappedKey, ciphertext []byte) ([]byte, error) {
+	dek, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, wrappedKey, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("kes: invalid ciphertext")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(name))
+}
+
+// exportedKey is the plaintext of an exported key. Besides the key,
+// it contains the operations the key may be used for, the grants and
+// the deletion protection of the key. Otherwise, a restricted key
+// could be exported and imported again without any restrictions.
+type exportedKey struct {
+	Key        []byte                    `json:"key"`
+	Operations []string                  `json:"operations,omitempty"`
+	Grants     map[kes.Identity][]string `json:"grants,omitempty"`
+	Protected  bool                      `json:"protected,omitempty"`
+}
+
+// encodeExport returns the plaintext of the exported key with
+// the restrictions of the metadata.
+func encodeExport(key crypto.Key, m EntryMetadata) ([]byte, error) {
+	b, err := crypto.EncodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Zero(b)
+
+	return json.Marshal(exportedKey{
+		Key:        b,
+		Operations: m.Operations,
+		Grants:     m.Grants,
+		Protected:  m.Protected,
+	})
+}
+
+// parseExport parses the plaintext of an exported key. Keys
+// exported by previous KES servers consist of the encoded key
+// only and have no restrictions.
+func parseExport(b []byte) (crypto.Key, exportedKey, error) {
+	if !json.Valid(b) {
+		key, err := crypto.ParseKey(b)
+		return key, exportedKey{}, err
+	}
+
+	var export exportedKey
+	if err := json.Unmarshal(b, &export); err != nil {
+		return crypto.Key{}, exportedKey{}, err
+	}
+	defer secmem.Zero(export.Key)
+
+	key, err := crypto.ParseKey(export.Key)
+	if err != nil {
+		return crypto.Key{}, exportedKey{}, err
+	}
+	export.Key = nil
+	return key, export, nil
+}
+
+// Restricted reports whether the exported key has any restrictions
+// that have to be restored when importing it.
+func (k *exportedKey) Restricted() bool {
+	return len(k.Operations) > 0 || len(k.Grants) > 0 || k.Protected
+}
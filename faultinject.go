@@ -0,0 +1,352 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// A Fault describes a fault that a FaultInjector injects into
+// KeyStore operations.
+type Fault struct {
+	// Operations are the KeyStore operations the fault is injected
+	// into. The valid operations are the ones of SlowLogConfig. If
+	// empty, the fault is injected into all operations.
+	Operations []string
+
+	// Latency is added to every affected operation. An operation
+	// fails with the context error if its context is canceled
+	// while waiting.
+	Latency time.Duration
+
+	// Err, if not nil, is returned by affected operations instead
+	// of performing them.
+	Err error
+
+	// Every, if > 1, makes only every n-th affected operation fail
+	// with Err. For example, if Every is 3, the 3rd, 6th, ... operation
+	// fails while all others succeed. Counting is deterministic such
+	// that partial failures can be reproduced.
+	Every int
+}
+
+// FaultInjector injects faults, like latency and errors, into the
+// KeyStore operations of a KES server. It is intended for testing
+// how the KES server and its clients deal with slow or failing
+// KeyStores and must not be used in production.
+//
+// Its zero value injects no faults and is ready to use. It is safe
+// for concurrent use. Faults can be injected and removed while the
+// KES server is running.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults []Fault
+	counts []int // Number of affected operations per fault
+}
+
+// Inject adds the fault to the injected faults.
+func (f *FaultInjector) Inject(fault Fault) error {
+	for _, op := range fault.Operations {
+		if !validSlowOp(op) {
+			return fmt.Errorf("kes: invalid fault operation '%s'", op)
+		}
+	}
+	if fault.Latency < 0 {
+		return fmt.Errorf("kes: invalid fault latency '%v'", fault.Latency)
+	}
+	if fault.Every < 0 {
+		return fmt.Errorf("kes: invalid fault frequency '%d'", fault.Every)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = append(f.faults, fault)
+	f.counts = append(f.counts, 0)
+	return nil
+}
+
+// Reset removes all injected faults.
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults, f.counts = nil, nil
+}
+
+// Wrap returns a KeyStore that injects the faults of f into all
+// operations of store. Like the KeyStores of the KES server, it
+// implements all optional KeyStore interfaces.
+//
+// Wrap is used to test KeyStores outside a KES server. Within a
+// KES server, set Config.FaultInjector instead.
+func (f *FaultInjector) Wrap(store KeyStore) KeyStore {
+	return newFaultKeyStore(store, f)
+}
+
+// inject waits for the latency of all faults affecting the operation
+// and returns the error of the first failing one, if any.
+func (f *FaultInjector) inject(ctx context.Context, op string) error {
+	var (
+		latency time.Duration
+		err     error
+	)
+	f.mu.Lock()
+	for i, fault := range f.faults {
+		if len(fault.Operations) > 0 && !slices.Contains(fault.Operations, op) {
+			continue
+		}
+		f.counts[i]++
+
+		latency += fault.Latency
+		if err == nil && fault.Err != nil && (fault.Every <= 1 || f.counts[i]%fault.Every == 0) {
+			err = fault.Err
+		}
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// newFaultKeyStore returns a KeyStore that injects the faults of f
+// into the operations of store. It returns store if f is nil.
+func newFaultKeyStore(store KeyStore, f *FaultInjector) KeyStore {
+	if f == nil || store == nil {
+		return store
+	}
+	s := &faultKeyStore{
+		store:  store,
+		faults: f,
+	}
+	if _, ok := store.(WatchableKeyStore); ok {
+		return &watchableFaultKeyStore{s}
+	}
+	return s
+}
+
+// faultKeyStore is a KeyStore that injects faults into the
+// operations of another KeyStore.
+//
+// Like the KeyStore of an enclave, it implements all optional
+// KeyStore interfaces and falls back to the behavior of the KES
+// server if the wrapped KeyStore does not implement them.
+type faultKeyStore struct {
+	store  KeyStore
+	faults *FaultInjector
+}
+
+// watchableFaultKeyStore is a faultKeyStore on top of a
+// WatchableKeyStore.
+type watchableFaultKeyStore struct {
+	*faultKeyStore
+}
+
+var _ WatchableKeyStore = (*watchableFaultKeyStore)(nil) // compiler check
+
+// Watch returns a channel that receives an event whenever an entry,
+// whose name starts with the given prefix, is created, replaced or
+// deleted. No faults are injected into watching.
+func (s *watchableFaultKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	return s.store.(WatchableKeyStore).Watch(ctx, prefix)
+}
+
+var ( // compiler checks
+	_ ConditionalKeyStore = (*faultKeyStore)(nil)
+	_ DataKeyStore        = (*faultKeyStore)(nil)
+	_ ExpiringKeyStore    = (*faultKeyStore)(nil)
+	_ MetadataKeyStore    = (*faultKeyStore)(nil)
+	_ PaginatedKeyStore   = (*faultKeyStore)(nil)
+)
+
+// String returns the string representation of the wrapped KeyStore.
+func (s *faultKeyStore) String() string {
+	if store, ok := s.store.(fmt.Stringer); ok {
+		return store.String()
+	}
+	return keyStoreBackend(s.store)
+}
+
+// Status returns the current state of the KeyStore.
+func (s *faultKeyStore) Status(ctx context.Context) (KeyStoreState, error) {
+	if err := s.faults.inject(ctx, slowOpStatus); err != nil {
+		return KeyStoreState{}, err
+	}
+	return s.store.Status(ctx)
+}
+
+// Create creates a new entry at the KeyStore.
+func (s *faultKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	if err := s.faults.inject(ctx, slowOpCreate); err != nil {
+		return err
+	}
+	return s.store.Create(ctx, name, value)
+}
+
+// CreateWithTTL creates a new entry that expires after the given
+// ttl. It creates the entry without a TTL if the KeyStore does not
+// implement ExpiringKeyStore.
+func (s *faultKeyStore) CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error {
+	if err := s.faults.inject(ctx, slowOpCreate); err != nil {
+		return err
+	}
+	return createWithTTL(ctx, s.store, name, value, ttl)
+}
+
+// Set replaces the value of an existing entry. It fails if the
+// KeyStore is not mutable.
+func (s *faultKeyStore) Set(ctx context.Context, name string, value []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpSet); err != nil {
+		return err
+	}
+	return store.Set(ctx, name, value)
+}
+
+// SetIf replaces the value of an existing entry if its current
+// value is equal to old. It replaces the value unconditionally if
+// the KeyStore does not implement ConditionalKeyStore and fails if
+// it is not mutable.
+func (s *faultKeyStore) SetIf(ctx context.Context, name string, value, old []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpSet); err != nil {
+		return err
+	}
+	return setIf(ctx, store, name, value, old)
+}
+
+// Metadata returns the metadata of the entry. It returns an empty
+// EntryMetadata if the KeyStore does not implement MetadataKeyStore.
+func (s *faultKeyStore) Metadata(ctx context.Context, name string) (EntryMetadata, error) {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return EntryMetadata{}, nil
+	}
+	if err := s.faults.inject(ctx, slowOpMetadata); err != nil {
+		return EntryMetadata{}, err
+	}
+	return store.Metadata(ctx, name)
+}
+
+// SetMetadata stores the metadata of the entry. It does nothing if
+// the KeyStore does not implement MetadataKeyStore.
+func (s *faultKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return nil
+	}
+	if err := s.faults.inject(ctx, slowOpSetMetadata); err != nil {
+		return err
+	}
+	return store.SetMetadata(ctx, name, metadata)
+}
+
+// CreateKey creates a new key at the KeyStore. It fails if the
+// KeyStore is not a CryptoKeyStore.
+func (s *faultKeyStore) CreateKey(ctx context.Context, name string) error {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return errKeyMaterialNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpCreateKey); err != nil {
+		return err
+	}
+	return store.CreateKey(ctx, name)
+}
+
+// Encrypt encrypts the plaintext with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *faultKeyStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpEncrypt); err != nil {
+		return nil, err
+	}
+	return store.Encrypt(ctx, name, plaintext, associatedData)
+}
+
+// GenerateKey generates a data key with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *faultKeyStore) GenerateKey(ctx context.Context, name string, associatedData []byte) ([]byte, []byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, nil, errKeyMaterialNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpGenerateKey); err != nil {
+		return nil, nil, err
+	}
+	return generateKey(ctx, store, name, associatedData)
+}
+
+// Decrypt decrypts the ciphertext with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *faultKeyStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	if err := s.faults.inject(ctx, slowOpDecrypt); err != nil {
+		return nil, err
+	}
+	return store.Decrypt(ctx, name, ciphertext, associatedData)
+}
+
+// Delete removes the entry from the KeyStore.
+func (s *faultKeyStore) Delete(ctx context.Context, name string) error {
+	if err := s.faults.inject(ctx, slowOpDelete); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the value of the entry.
+func (s *faultKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := s.faults.inject(ctx, slowOpGet); err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, name)
+}
+
+// List returns the first n entry names that start with the
+// given prefix.
+func (s *faultKeyStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	if err := s.faults.inject(ctx, slowOpList); err != nil {
+		return nil, "", err
+	}
+	return s.store.List(ctx, prefix, n)
+}
+
+// ListFrom behaves like List but continues the listing at the
+// given entry name.
+func (s *faultKeyStore) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
+	if err := s.faults.inject(ctx, slowOpList); err != nil {
+		return nil, "", err
+	}
+	return listFrom(ctx, s.store, prefix, continueAt, n)
+}
+
+// Close closes the KeyStore.
+func (s *faultKeyStore) Close() error { return s.store.Close() }
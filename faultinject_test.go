@@ -0,0 +1,93 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestFaultInjector(t *testing.T) {
+	ctx := testContext(t)
+
+	faults := &FaultInjector{}
+	store := faults.Wrap(&MemKeyStore{})
+	if cryptoKeyStore(store) != nil {
+		t.Fatal("Fault injecting KeyStore must not be a CryptoKeyStore")
+	}
+	if err := store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+
+	// Every 2nd get fails while all other operations succeed.
+	errUnreachable := &keystore.ErrUnreachable{}
+	if err := faults.Inject(Fault{Operations: []string{slowOpGet}, Err: errUnreachable, Every: 2}); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		_, err := store.Get(ctx, "my-key")
+		if i%2 == 0 && !errors.Is(err, errUnreachable) {
+			t.Fatalf("Get %d: got '%v' - want '%v'", i, err, errUnreachable)
+		}
+		if i%2 != 0 && err != nil {
+			t.Fatalf("Get %d: failed to get entry: %v", i, err)
+		}
+	}
+	if _, err := store.Status(ctx); err != nil {
+		t.Fatalf("Failed to fetch status: %v", err)
+	}
+
+	// Latency is bounded by the context deadline.
+	faults.Reset()
+	if err := faults.Inject(Fault{Latency: time.Hour}); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := store.Get(tctx, "my-key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get with latency: got '%v' - want '%v'", err, context.DeadlineExceeded)
+	}
+
+	faults.Reset()
+	if _, err := store.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to get entry after reset: %v", err)
+	}
+	if err := faults.Inject(Fault{Operations: []string{"unknown"}}); err == nil {
+		t.Fatal("Injecting fault into unknown operation should have failed")
+	}
+}
+
+func TestFaultInjectorServer(t *testing.T) {
+	ctx := testContext(t)
+
+	faults := &FaultInjector{}
+	srv, url := startServer(ctx, &Config{
+		Keys:          &MemKeyStore{},
+		FaultInjector: faults,
+	})
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if err := faults.Inject(Fault{Operations: []string{slowOpCreate}, Err: &keystore.ErrThrottled{}, Every: 2}); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+	if err := client.CreateKey(ctx, "key-1"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "key-2"); err == nil {
+		t.Fatal("Creating key should have failed")
+	}
+	if err := client.CreateKey(ctx, "key-3"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := client.DescribeKey(ctx, "key-2"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Describing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/minio/kes/internal/fips"
+)
+
+// verifyFIPSTLS reports whether the TLS config only allows
+// FIPS approved TLS versions, cipher suites and curves and
+// whether all server certificates use FIPS approved keys.
+//
+// Empty cipher suite and curve preferences are valid since
+// the server replaces them with FIPS approved defaults.
+func verifyFIPSTLS(conf *tls.Config) error {
+	if conf.MinVersion != 0 && conf.MinVersion < tls.VersionTLS12 {
+		return errors.New("kes: fips: TLS versions before TLS 1.2 are not allowed")
+	}
+	approvedCiphers := fips.ApprovedTLSCiphers()
+	for _, id := range conf.CipherSuites {
+		if !slices.Contains(approvedCiphers, id) {
+			return fmt.Errorf("kes: fips: TLS cipher suite '%s' is not FIPS approved", tls.CipherSuiteName(id))
+		}
+	}
+	approvedCurves := fips.ApprovedTLSCurveIDs()
+	for _, id := range conf.CurvePreferences {
+		if !slices.Contains(approvedCurves, id) {
+			return fmt.Errorf("kes: fips: TLS curve '%v' is not FIPS approved", id)
+		}
+	}
+
+	for _, cert := range conf.Certificates {
+		leaf := cert.Leaf
+		if leaf == nil {
+			if len(cert.Certificate) == 0 {
+				continue
+			}
+			var err error
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return fmt.Errorf("kes: fips: failed to parse TLS certificate: %v", err)
+			}
+		}
+		switch key := leaf.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if key.N.BitLen() < 2048 {
+				return fmt.Errorf("kes: fips: TLS certificate RSA key size '%d' is not FIPS approved", key.N.BitLen())
+			}
+		case *ecdsa.PublicKey:
+			if key.Curve != elliptic.P256() && key.Curve != elliptic.P384() && key.Curve != elliptic.P521() {
+				return fmt.Errorf("kes: fips: TLS certificate curve '%s' is not FIPS approved", key.Curve.Params().Name)
+			}
+		default:
+			return fmt.Errorf("kes: fips: TLS certificate key algorithm '%v' is not FIPS approved", leaf.PublicKeyAlgorithm)
+		}
+	}
+	return nil
+}
+
+// fipsTLSConfig returns a copy of the TLS config. If FIPS mode
+// is enabled, the copy only uses FIPS approved cipher suites and
+// curves and requires at least TLS 1.2.
+//
+// Go does not support configuring TLS 1.3 cipher suites. Only
+// a FIPS module, like BoringCrypto, limits them to AES-GCM.
+func fipsTLSConfig(conf *tls.Config) *tls.Config {
+	conf = conf.Clone()
+	if !fips.Enabled() {
+		return conf
+	}
+	if conf.MinVersion < tls.VersionTLS12 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	if len(conf.CipherSuites) == 0 {
+		conf.CipherSuites = fips.ApprovedTLSCiphers()
+	}
+	if len(conf.CurvePreferences) == 0 {
+		conf.CurvePreferences = fips.ApprovedTLSCurveIDs()
+	}
+	return conf
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestVerifyFIPSTLS(t *testing.T) {
+	t.Parallel()
+
+	key, err := kes.ParseAPIKey(defaultAPIKey)
+	if err != nil {
+		t.Fatalf("Failed to parse API key: %v", err)
+	}
+	ed25519Cert, err := kes.GenerateCertificate(key)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	for i, test := range []struct {
+		Config     *tls.Config
+		ShouldFail bool
+	}{
+		{Config: &tls.Config{Certificates: []tls.Certificate{defaultServerCertificate()}}}, // 0
+		{Config: &tls.Config{ // 1
+			MinVersion:       tls.VersionTLS12,
+			CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+			CurvePreferences: []tls.CurveID{tls.CurveP384},
+		}},
+		{Config: &tls.Config{MinVersion: tls.VersionTLS11}, ShouldFail: true},                                              // 2
+		{Config: &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}}, ShouldFail: true}, // 3
+		{Config: &tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP256, tls.X25519}}, ShouldFail: true},                // 4
+		{Config: &tls.Config{Certificates: []tls.Certificate{defaultServerCertificate(), ed25519Cert}}, ShouldFail: true},  // 5
+	} {
+		err := verifyFIPSTLS(test.Config)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: verification should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: verification failed: %v", i, err)
+		}
+	}
+}
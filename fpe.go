@@ -0,0 +1,163 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// defaultFPEAlphabet is the alphabet used for format-preserving
+// encryption if the client only specifies a radix.
+const defaultFPEAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func (s *Server) encryptKeyFPE(resp *api.Response, req *api.Request) {
+	s.fpe(resp, req, true)
+}
+
+func (s *Server) decryptKeyFPE(resp *api.Response, req *api.Request) {
+	s.fpe(resp, req, false)
+}
+
+// fpe handles EncryptFPE and DecryptFPE requests. It converts
+// the value to numerals using the alphabet, encrypts or decrypts
+// them and converts the result back using the same alphabet.
+func (s *Server) fpe(resp *api.Response, req *api.Request, encrypt bool) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.FPERequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	alg, err := crypto.ParseFPEAlgorithm(body.Algorithm)
+	if err != nil {
+		resp.Failf(http.StatusNotAcceptable, "algorithm '%s' is not supported", body.Algorithm)
+		return
+	}
+	alphabet, err := parseFPEAlphabet(body.Alphabet, body.Radix)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid alphabet: %v", err)
+		return
+	}
+	numerals, err := alphabet.Numerals(body.Value)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid value: %v", err)
+		return
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+
+	if encrypt {
+		numerals, err = key.EncryptFPE(alg, len(alphabet), numerals, body.Tweak)
+	} else {
+		numerals, err = key.DecryptFPE(alg, len(alphabet), numerals, body.Tweak)
+	}
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		// Apart from key errors, FPE only fails for invalid
+		// inputs, like too short or too long values.
+		resp.Failf(http.StatusBadRequest, "%v", strings.TrimPrefix(err.Error(), "crypto: "))
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.FPEResponse{
+		Value: alphabet.String(numerals),
+	})
+}
+
+// fpeAlphabet is an alphabet of unique characters. The i-th
+// character represents the numeral i.
+type fpeAlphabet []rune
+
+// parseFPEAlphabet parses s as alphabet. If s is empty, it returns
+// the first radix characters of the default alphabet. If radix is
+// 0, it defaults to 10. Otherwise, the radix must match the size of
+// the alphabet.
+func parseFPEAlphabet(s string, radix int) (fpeAlphabet, error) {
+	if s == "" {
+		if radix == 0 {
+			radix = 10
+		}
+		if radix < crypto.MinFPERadix || radix > len(defaultFPEAlphabet) {
+			return nil, fmt.Errorf("radix '%d' requires an explicit alphabet", radix)
+		}
+		s = defaultFPEAlphabet[:radix]
+	}
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("alphabet is not valid UTF-8")
+	}
+
+	alphabet := fpeAlphabet([]rune(s))
+	if len(alphabet) < crypto.MinFPERadix || len(alphabet) > crypto.MaxFPERadix {
+		return nil, fmt.Errorf("alphabet must contain between %d and %d characters", crypto.MinFPERadix, crypto.MaxFPERadix)
+	}
+	if radix != 0 && radix != len(alphabet) {
+		return nil, fmt.Errorf("radix '%d' does not match alphabet size '%d'", radix, len(alphabet))
+	}
+
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if _, ok := seen[r]; ok {
+			return nil, fmt.Errorf("duplicate character '%c'", r)
+		}
+		seen[r] = struct{}{}
+	}
+	return alphabet, nil
+}
+
+// Numerals returns the numerals representing s. It returns an
+// error if s contains characters not present in the alphabet.
+func (a fpeAlphabet) Numerals(s string) ([]uint16, error) {
+	index := make(map[rune]uint16, len(a))
+	for i, r := range a {
+		index[r] = uint16(i)
+	}
+
+	numerals := make([]uint16, 0, len(s))
+	for _, r := range s {
+		n, ok := index[r]
+		if !ok {
+			return nil, fmt.Errorf("character '%c' is not part of the alphabet", r)
+		}
+		numerals = append(numerals, n)
+	}
+	return numerals, nil
+}
+
+// String returns the string represented by the numerals.
+func (a fpeAlphabet) String(numerals []uint16) string {
+	var sb strings.Builder
+	for _, n := range numerals {
+		sb.WriteRune(a[n])
+	}
+	return sb.String()
+}
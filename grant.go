@@ -0,0 +1,185 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// errGrantsNotSupported is returned when granting access to a key
+// of a KeyStore that does not implement MetadataKeyStore.
+var errGrantsNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support key grants")
+
+// keyGrantPaths maps the API paths that can be granted on a single
+// key to the operations an identity has to be granted. Re-encrypting
+// requires both, decrypt and encrypt.
+//
+// All other APIs, like describing, rotating or deleting keys, can
+// only be allowed by policies.
+var keyGrantPaths = map[string][]string{
+	api.PathKeyEncrypt:       {keyOpEncrypt},
+	api.PathKeyEncryptBatch:  {keyOpEncrypt},
+	api.PathKeyEncryptStream: {keyOpEncrypt},
+	api.PathKeyEncryptDet:    {keyOpEncrypt},
+	api.PathKeyDecrypt:       {keyOpDecrypt},
+	api.PathKeyDecryptBatch:  {keyOpDecrypt},
+	api.PathKeyDecryptStream: {keyOpDecrypt},
+	api.PathKeyDecryptDet:    {keyOpDecrypt},
+	api.PathKeyEncryptFPE:    {keyOpEncrypt},
+	api.PathKeyDecryptFPE:    {keyOpDecrypt},
+	api.PathKeyReencrypt:     {keyOpDecrypt, keyOpEncrypt},
+	api.PathKeyGenerate:      {keyOpGenerate},
+	api.PathKeyUnwrap:        {keyOpUnwrap},
+	api.PathKeyHMAC:          {keyOpHMAC},
+	api.PathKeyHMACVerify:    {keyOpHMAC},
+	api.PathKeySign:          {keyOpSign},
+	api.PathKeyVerify:        {keyOpSign},
+	api.PathKeyTimestamp:     {keyOpSign},
+	api.PathKeySignJWS:       {keyOpSign},
+	api.PathKeyEncryptJWE:    {keyOpEncrypt},
+	api.PathKeyDecryptJWE:    {keyOpDecrypt},
+	api.PathKeyDerive:        {keyOpDerive},
+}
+
+// SetGrant grants the identity the given operations on the key with
+// the given name. It replaces any operations granted before. If ops
+// is empty, the identity's grant is removed.
+//
+// It returns errGrantsNotSupported if the KeyStore does not implement
+// MetadataKeyStore and kes.ErrKeyNotFound if no such key exists.
+//
+// Since grants are cached alongside the key, SetGrant evicts the key
+// from the cache and notifies the peers such that revoked grants do
+// not apply anymore.
+func (c *keyCache) SetGrant(ctx context.Context, name string, identity kes.Identity, ops []string) error {
+	err := c.updateMetadata(ctx, name, errGrantsNotSupported, func(m *EntryMetadata) {
+		m.Grants = maps.Clone(m.Grants)
+		if len(ops) == 0 {
+			delete(m.Grants, identity)
+		} else {
+			if m.Grants == nil {
+				m.Grants = map[kes.Identity][]string{}
+			}
+			m.Grants[identity] = ops
+		}
+		if len(m.Grants) == 0 {
+			m.Grants = nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+	c.cache.Delete(name)
+	c.notifyPeers(EntryUpdated, name)
+	return nil
+}
+
+// granted reports whether the identity has been granted all of the
+// operations on the key with the given name. Keys of a KeyStore that
+// does not implement MetadataKeyStore cannot have grants.
+func (c *keyCache) granted(ctx context.Context, name string, identity kes.Identity, ops []string) (bool, error) {
+	if _, ok := c.store.(MetadataKeyStore); !ok || !c.SupportsMetadata() {
+		return false, nil
+	}
+	entry, err := c.entry(ctx, name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	granted, ok := entry.Grants[identity]
+	if !ok {
+		return false, nil
+	}
+	for _, op := range ops {
+		if !slices.Contains(granted, op) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// keyGranted reports whether the request is allowed by a grant of
+// the addressed key. It returns false if the request does not call
+// an API listed in keyGrantPaths.
+func (s *serverState) keyGranted(req *http.Request, identity kes.Identity, enclave string) (bool, error) {
+	for path, ops := range keyGrantPaths {
+		name, ok := strings.CutPrefix(req.URL.Path, path)
+		if !ok {
+			continue
+		}
+		if !validName(name) {
+			return false, nil
+		}
+		keys := s.Keys
+		if enclave != "" {
+			keys = s.Enclaves[enclave].Keys
+		}
+		return keys.granted(req.Context(), name, identity, ops)
+	}
+	return false, nil
+}
+
+// grantKey grants an identity operations on a single key. Granting
+// no operations removes the identity's grant.
+func (s *Server) grantKey(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.GrantKeyRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	identity := kes.Identity(body.Identity)
+	if identity.IsUnknown() {
+		resp.Fail(http.StatusBadRequest, "invalid grant: identity is empty")
+		return
+	}
+	if identity == s.state.Load().Admin {
+		resp.Fail(http.StatusBadRequest, "invalid grant: identity is the admin identity")
+		return
+	}
+	if err := validKeyOperations(body.Operations); err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid grant: "+err.Error())
+		return
+	}
+
+	if err := s.state.Load().enclave(req).Keys.SetGrant(req.Context(), req.Resource, identity, body.Operations); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to update key grants")
+		return
+	}
+
+	msg := fmt.Sprintf("identity '%s' granted %v on secret key '%s'", identity, body.Operations, req.Resource)
+	if len(body.Operations) == 0 {
+		msg = fmt.Sprintf("grant of identity '%s' on secret key '%s' removed", identity, req.Resource)
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(msg, StatusOK, req)
+	resp.Reply(StatusOK)
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/headers"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC metadata keys of the KES gRPC API.
+const (
+	grpcMethod  = "kes-method"  // HTTP method of the API, e.g. "PUT"
+	grpcPath    = "kes-path"    // HTTP path of the API, e.g. "/v1/key/create/my-key"
+	grpcEnclave = "kes-enclave" // Optional enclave, like the Kes-Enclave header
+	grpcStatus  = "kes-status"  // Trailer containing the HTTP status code
+)
+
+// grpcServiceDesc describes the KES gRPC API, as defined in
+// internal/api/kes.proto.
+//
+// Each call is served by the corresponding HTTP API. Hence, it is
+// authenticated, authorized, rate limited and audited the same way.
+// Clients specify the API via the kes-method and kes-path metadata
+// and send the request body as google.api.HttpBody. Responses are
+// returned as google.api.HttpBody. The Stream method returns one
+// message per flushed response chunk, e.g. one per log event.
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kes.v1.KES",
+	HandlerType: (*grpcHandler)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Call",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				in := new(httpbody.HttpBody)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(grpcHandler).Call(ctx, in)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				in := new(httpbody.HttpBody)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(grpcHandler).Stream(in, stream)
+			},
+		},
+	},
+	Metadata: "kes.proto",
+}
+
+// grpcHandler is the interface of the KES gRPC API.
+type grpcHandler interface {
+	Call(context.Context, *httpbody.HttpBody) (*httpbody.HttpBody, error)
+	Stream(*httpbody.HttpBody, grpc.ServerStream) error
+}
+
+// newGRPCServer returns a new gRPC server serving the KES
+// gRPC API using the given HTTP handler.
+//
+// The returned server is not used to accept connections.
+// Instead, gRPC requests are served via grpc.Server.ServeHTTP
+// over the HTTP/2 connections of the HTTPS server. Hence, the
+// gRPC API uses the same TLS config and address.
+func newGRPCServer(h http.Handler) *grpc.Server {
+	srv := grpc.NewServer()
+	srv.RegisterService(&grpcServiceDesc, grpcAPI{h: h})
+	return srv
+}
+
+// isGRPC reports whether r is a gRPC request.
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get(headers.ContentType), "application/grpc")
+}
+
+// grpcAPI implements the KES gRPC API by translating
+// gRPC calls into HTTP requests.
+type grpcAPI struct {
+	h http.Handler
+}
+
+func (g grpcAPI) Call(ctx context.Context, in *httpbody.HttpBody) (*httpbody.HttpBody, error) {
+	req, err := grpcRequest(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &bufferedResponseWriter{header: http.Header{}}
+	g.h.ServeHTTP(w, req)
+
+	grpc.SetTrailer(ctx, metadata.Pairs(grpcStatus, strconv.Itoa(w.statusCode())))
+	if err = w.err(); err != nil {
+		return nil, err
+	}
+	return &httpbody.HttpBody{
+		ContentType: w.header.Get(headers.ContentType),
+		Data:        w.body.Bytes(),
+	}, nil
+}
+
+func (g grpcAPI) Stream(in *httpbody.HttpBody, stream grpc.ServerStream) error {
+	req, err := grpcRequest(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	w := &bufferedResponseWriter{
+		header: http.Header{},
+		send: func(b []byte, contentType string) error {
+			return stream.SendMsg(&httpbody.HttpBody{
+				ContentType: contentType,
+				Data:        b,
+			})
+		},
+	}
+	g.h.ServeHTTP(w, req)
+	w.Flush()
+
+	stream.SetTrailer(metadata.Pairs(grpcStatus, strconv.Itoa(w.statusCode())))
+	if err = w.sendErr; err != nil {
+		return err
+	}
+	return w.err()
+}
+
+// grpcRequest returns a new HTTP request for the gRPC call.
+// The request carries the TLS connection state of the gRPC
+// connection such that the client is identified by its
+// TLS client certificate.
+func grpcRequest(ctx context.Context, in *httpbody.HttpBody) (*http.Request, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	method, path := grpcMetadata(md, grpcMethod), grpcMetadata(md, grpcPath)
+	if method == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing '%s' metadata", grpcMethod)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid '%s' metadata: '%s'", grpcPath, path)
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "insecure connection: TLS is required")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "insecure connection: TLS is required")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(in.GetData()))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	req.RequestURI = path
+	req.TLS = &info.State
+	if p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+	if contentType := in.GetContentType(); contentType != "" {
+		req.Header.Set(headers.ContentType, contentType)
+	}
+	if enclave := grpcMetadata(md, grpcEnclave); enclave != "" {
+		req.Header.Set(headers.KesEnclave, enclave)
+	}
+	return req, nil
+}
+
+// grpcMetadata returns the first value of the metadata key.
+func grpcMetadata(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// bufferedResponseWriter is an http.ResponseWriter that buffers the
+// response of an HTTP handler serving a gRPC or KMIP call.
+//
+// If send is not nil, it sends the buffered response body whenever
+// the handler flushes it. Otherwise, the response body is returned
+// once the handler returns.
+type bufferedResponseWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	deadline time.Time
+
+	send    func(b []byte, contentType string) error
+	sendErr error
+}
+
+var ( // compiler checks
+	_ http.ResponseWriter = (*bufferedResponseWriter)(nil)
+	_ http.Flusher        = (*bufferedResponseWriter)(nil)
+)
+
+func (w *bufferedResponseWriter) Header() http.Header { return w.header }
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if !w.deadline.IsZero() && time.Now().After(w.deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if w.sendErr != nil {
+		return 0, w.sendErr
+	}
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Flush sends the buffered response body if the
+// response is streamed and the request succeeded.
+func (w *bufferedResponseWriter) Flush() {
+	if w.send == nil || w.sendErr != nil || w.statusCode() != http.StatusOK || w.body.Len() == 0 {
+		return
+	}
+	w.sendErr = w.send(bytes.Clone(w.body.Bytes()), w.header.Get(headers.ContentType))
+	w.body.Reset()
+}
+
+// SetWriteDeadline sets the deadline after which writes fail.
+//
+// This method will be called by http.ResponseController.
+func (w *bufferedResponseWriter) SetWriteDeadline(deadline time.Time) error {
+	w.deadline = deadline
+	return nil
+}
+
+// statusCode returns the HTTP status code of the response.
+func (w *bufferedResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// err returns a gRPC status error if the HTTP handler
+// has not responded with 200 OK.
+func (w *bufferedResponseWriter) err() error {
+	code := w.statusCode()
+	if code == http.StatusOK {
+		return nil
+	}
+	return status.Error(grpcCode(code), w.message())
+}
+
+// message returns the error message of a failed response.
+func (w *bufferedResponseWriter) message() string {
+	msg := strings.TrimSpace(w.body.String())
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err == nil && resp.Message != "" {
+		msg = resp.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(w.statusCode())
+	}
+	return msg
+}
+
+// grpcCode returns the gRPC status code corresponding
+// to the HTTP status code.
+func grpcCode(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		if code >= 500 {
+			return codes.Internal
+		}
+		return codes.Unknown
+	}
+}
+
+// enableGRPC enables or disables the server's gRPC API.
+func (s *Server) enableGRPC(enable bool) {
+	if !enable {
+		s.grpc.Store(nil)
+		return
+	}
+	if s.grpc.Load() == nil {
+		s.grpc.Store(newGRPCServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.handler.Load().ServeHTTP(w, r)
+		})))
+	}
+}
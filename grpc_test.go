@@ -0,0 +1,95 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC(t *testing.T) {
+	t.Parallel()
+
+	const Name = "my-key"
+
+	ctx := testContext(t)
+	srv, url := startServer(ctx, &Config{GRPC: true})
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(url, "https://"), grpc.WithTransportCredentials(credentials.NewTLS(defaultClientTLSConfig())))
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client: %v", err)
+	}
+	defer conn.Close()
+
+	call := func(method, path string, body any) (*httpbody.HttpBody, metadata.MD, error) {
+		in := &httpbody.HttpBody{}
+		if body != nil {
+			if in.Data, err = json.Marshal(body); err != nil {
+				t.Fatalf("Failed to encode request body: %v", err)
+			}
+			in.ContentType = headers.ContentTypeJSON
+		}
+
+		var (
+			out     httpbody.HttpBody
+			trailer metadata.MD
+		)
+		ctx := metadata.AppendToOutgoingContext(ctx, grpcMethod, method, grpcPath, path)
+		err := conn.Invoke(ctx, "/kes.v1.KES/Call", in, &out, grpc.Trailer(&trailer))
+		return &out, trailer, err
+	}
+
+	if _, _, err = call(http.MethodPut, api.PathKeyCreate+Name, nil); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", Name, err)
+	}
+	_, trailer, err := call(http.MethodPut, api.PathKeyCreate+Name, nil)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Creating existing key: got '%v' - want code '%v'", err, codes.InvalidArgument)
+	}
+	if s := trailer.Get(grpcStatus); len(s) != 1 || s[0] != "400" {
+		t.Fatalf("Invalid status trailer: got '%v' - want '%v'", s, "400")
+	}
+
+	plaintext := []byte("Hello World")
+	out, _, err := call(http.MethodPut, api.PathKeyEncrypt+Name, api.EncryptKeyRequest{Plaintext: plaintext})
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+	var encResp api.EncryptKeyResponse
+	if err = json.Unmarshal(out.Data, &encResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	out, _, err = call(http.MethodPut, api.PathKeyDecrypt+Name, api.DecryptKeyRequest{Ciphertext: encResp.Ciphertext})
+	if err != nil {
+		t.Fatalf("Failed to decrypt ciphertext: %v", err)
+	}
+	var decResp api.DecryptKeyResponse
+	if err = json.Unmarshal(out.Data, &decResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !bytes.Equal(decResp.Plaintext, plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%s' - want '%s'", decResp.Plaintext, plaintext)
+	}
+
+	if _, _, err = call(http.MethodGet, api.PathKeyDescribe+"non-existing", nil); status.Code(err) != codes.NotFound {
+		t.Fatalf("Describing non-existing key: got '%v' - want code '%v'", err, codes.NotFound)
+	}
+	if _, _, err = call("", api.PathKeyDescribe+Name, nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Calling API without method: got '%v' - want code '%v'", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+const (
+	// importKeySize is the size of the RSA import wrapping key in bits.
+	importKeySize = 3072
+
+	// importKeyLifetime is the time period after which the server
+	// replaces its import wrapping key.
+	importKeyLifetime = 24 * time.Hour
+
+	// importAlgorithm is the algorithm clients have to use to encrypt
+	// key material with the import wrapping key.
+	importAlgorithm = "RSAES_OAEP_SHA_256"
+
+	// importKeyPrefix is the prefix of the KeyStore entries holding
+	// the import wrapping keys. Valid key names never start with a
+	// hyphen, and therefore, cannot collide with these entries.
+	importKeyPrefix = "-import-key-"
+
+	// importSealKeyName is the name of the KeyStore entry holding
+	// the key that seals the private keys of the import wrapping
+	// keys.
+	importSealKeyName = importKeyPrefix + "seal"
+)
+
+// importKeyring holds the RSA key pairs clients use to wrap key
+// material before importing it.
+//
+// Import keys are persisted at the KeyStore such that all KES
+// servers sharing the KeyStore use the same import key, and key
+// material wrapped before a restart can still be imported. Each
+// import key belongs to a fixed lifetime period. The first server
+// that needs the key of a period generates it and stores it. All
+// other servers load it from the KeyStore.
+//
+// Key material wrapped under the import key of the previous period
+// can still be imported such that a replacement does not break an
+// in-flight import.
+//
+// The private keys are sealed, i.e. encrypted, with a KES key stored
+// at the same KeyStore. Hence, they are protected like any other key
+// material at the KeyStore and never stored in plaintext.
+//
+// Each keyring belongs to one KeyStore. The zero value is ready to use.
+type importKeyring struct {
+	mu   sync.Mutex
+	seal *crypto.SecretKey    // Cached sealing key
+	keys map[int64]*importKey // Cached import keys by period
+}
+
+// An importKey is an RSA key pair with an expiry.
+type importKey struct {
+	Key       *rsa.PrivateKey
+	ExpiresAt time.Time
+}
+
+// PublicKey returns the PKIX, ASN.1 DER encoded public key.
+func (k *importKey) PublicKey() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(&k.Key.PublicKey)
+}
+
+// importKeyJSON is the JSON representation of an import key
+// stored at the KeyStore. The private key is PKCS #8 encoded and
+// sealed with the sealing key of the importKeyring.
+type importKeyJSON struct {
+	Key       []byte    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// sealImportKey encodes the import key and seals its private key
+// with the sealing key. The entry name is used as associated data.
+// Hence, a sealed import key can only be opened under the name of
+// its entry.
+func sealImportKey(seal crypto.SecretKey, name string, k *importKey) ([]byte, error) {
+	key, err := x509.MarshalPKCS8PrivateKey(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Zero(key)
+
+	sealed, err := seal.Encrypt(key, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(importKeyJSON{
+		Key:       sealed,
+		ExpiresAt: k.ExpiresAt,
+	})
+}
+
+// openImportKey parses the import key stored under the entry name
+// and opens its private key with the sealing key.
+//
+// Import keys stored before import keys have been sealed contain a
+// plaintext private key. They are accepted until they expire and
+// get deleted.
+func openImportKey(seal crypto.SecretKey, name string, b []byte) (*importKey, error) {
+	var v importKeyJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	plaintext, err := seal.Decrypt(v.Key, []byte(name))
+	if err != nil {
+		if _, pErr := x509.ParsePKCS8PrivateKey(v.Key); pErr != nil {
+			return nil, err
+		}
+		plaintext = v.Key // Unsealed import key
+	}
+	defer secmem.Zero(plaintext)
+
+	key, err := x509.ParsePKCS8PrivateKey(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("kes: import key is not an RSA private key")
+	}
+	return &importKey{Key: rsaKey, ExpiresAt: v.ExpiresAt}, nil
+}
+
+// importPeriod returns the import key lifetime period of t.
+func importPeriod(t time.Time) int64 {
+	return t.Unix() / int64(importKeyLifetime/time.Second)
+}
+
+// Current returns the import key of the current period. It
+// loads the key from the KeyStore or generates and stores a
+// new one if no other server has done so yet.
+func (r *importKeyring) Current(ctx context.Context, store KeyStore) (*importKey, error) {
+	return r.load(ctx, store, importPeriod(time.Now()), true)
+}
+
+// Unwrap decrypts the wrapped key material using RSA-OAEP with
+// SHA-256. The name of the imported key is used as OAEP label.
+// Hence, wrapped key material can only be imported under the name
+// it has been wrapped for.
+func (r *importKeyring) Unwrap(ctx context.Context, store KeyStore, name string, wrapped []byte) ([]byte, error) {
+	for _, k := range r.keysForUnwrap(ctx, store) {
+		if key, err := rsa.DecryptOAEP(sha256.New(), nil, k.Key, wrapped, []byte(name)); err == nil {
+			return key, nil
+		}
+	}
+	return nil, errors.New("kes: failed to unwrap key material")
+}
+
+// UnwrapExport decrypts a key exported by another KES server
+// with the import wrapping key as transport key. Like Unwrap, it
+// only succeeds if the key has been exported under the given name.
+func (r *importKeyring) UnwrapExport(ctx context.Context, store KeyStore, name string, wrappedKey, ciphertext []byte) ([]byte, error) {
+	for _, k := range r.keysForUnwrap(ctx, store) {
+		if key, err := unwrapExport(k.Key, name, wrappedKey, ciphertext); err == nil {
+			return key, nil
+		}
+	}
+	return nil, errors.New("kes: failed to unwrap exported key")
+}
+
+// keysForUnwrap returns the import keys of the current and the
+// previous period that exist at the KeyStore.
+func (r *importKeyring) keysForUnwrap(ctx context.Context, store KeyStore) []*importKey {
+	period := importPeriod(time.Now())
+
+	var keys []*importKey
+	for _, p := range []int64{period, period - 1} {
+		if k, err := r.load(ctx, store, p, false); err == nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// load returns the import key of the given period. If no such key
+// exists at the KeyStore, load generates and stores a new one if
+// create is true. Otherwise, it returns kes.ErrKeyNotFound.
+func (r *importKeyring) load(ctx context.Context, store KeyStore, period int64, create bool) (*importKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if k, ok := r.keys[period]; ok {
+		return k, nil
+	}
+
+	seal, err := r.sealKey(ctx, store, create)
+	if err != nil {
+		return nil, err
+	}
+
+	name := importKeyPrefix + strconv.FormatInt(period, 10)
+	b, err := store.Get(ctx, name)
+	if errors.Is(err, kes.ErrKeyNotFound) && create {
+		if b, err = r.generate(ctx, store, seal, period); errors.Is(err, kes.ErrKeyExists) {
+			b, err = store.Get(ctx, name) // Another server has created the key concurrently
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := openImportKey(seal, name, b)
+	if err != nil {
+		return nil, err
+	}
+	if r.keys == nil {
+		r.keys = map[int64]*importKey{}
+	}
+	r.keys[period] = k
+	for p := range r.keys {
+		if p < period-1 {
+			delete(r.keys, p)
+		}
+	}
+	return k, nil
+}
+
+// sealKey returns the key sealing the import keys. If no such
+// key exists at the KeyStore, sealKey generates and stores a new
+// one if create is true. Otherwise, it returns kes.ErrKeyNotFound.
+//
+// The sealing key is stored like any other KES key.
+func (r *importKeyring) sealKey(ctx context.Context, store KeyStore, create bool) (crypto.SecretKey, error) {
+	if r.seal != nil {
+		return *r.seal, nil
+	}
+
+	b, err := store.Get(ctx, importSealKeyName)
+	if errors.Is(err, kes.ErrKeyNotFound) && create {
+		var key crypto.SecretKey
+		if key, err = crypto.GenerateSecretKey(crypto.AES256, rand.Reader); err != nil {
+			return crypto.SecretKey{}, err
+		}
+		var hmac crypto.HMACKey
+		if hmac, err = crypto.GenerateHMACKey(crypto.SHA256, rand.Reader); err != nil {
+			return crypto.SecretKey{}, err
+		}
+		if b, err = crypto.EncodeKeyVersion(crypto.KeyVersion{Key: key, HMACKey: hmac, CreatedAt: time.Now().UTC()}); err != nil {
+			return crypto.SecretKey{}, err
+		}
+		if err = store.Create(ctx, importSealKeyName, b); errors.Is(err, kes.ErrKeyExists) {
+			b, err = store.Get(ctx, importSealKeyName) // Another server has created the key concurrently
+		}
+	}
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+	defer secmem.Zero(b)
+
+	key, err := crypto.ParseKey(b)
+	if err != nil {
+		return crypto.SecretKey{}, err
+	}
+	seal := key.Latest().Key
+	r.seal = &seal
+	return seal, nil
+}
+
+// generate generates a new import key for the given period and
+// stores it at the KeyStore. It returns kes.ErrKeyExists if the
+// import key has been created concurrently.
+//
+// Import keys of earlier periods can no longer be used. Hence,
+// generate removes them from the KeyStore on a best-effort basis.
+func (r *importKeyring) generate(ctx context.Context, store KeyStore, seal crypto.SecretKey, period int64) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, importKeySize)
+	if err != nil {
+		return nil, err
+	}
+	name := importKeyPrefix + strconv.FormatInt(period, 10)
+	b, err := sealImportKey(seal, name, &importKey{
+		Key:       key,
+		ExpiresAt: time.Unix((period+1)*int64(importKeyLifetime/time.Second), 0).UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = store.Create(ctx, name, b); err != nil {
+		return nil, err
+	}
+	_ = store.Delete(ctx, importKeyPrefix+strconv.FormatInt(period-2, 10))
+	return b, nil
+}
+
+// lowEntropy reports whether the key material is obviously
+// not randomly generated - for example, all zero bytes or a
+// repeating short pattern.
+//
+// It is not a statistical randomness test but detects common
+// mistakes, like importing an uninitialized buffer.
+func lowEntropy(key []byte) bool {
+	// A random 32 byte key contains less than 16 distinct byte
+	// values with a probability far below 2^-40.
+	const MinDistinct = 16
+
+	var seen [256]bool
+	distinct := 0
+	for _, b := range key {
+		if !seen[b] {
+			seen[b] = true
+			distinct++
+		}
+	}
+	return distinct < MinDistinct
+}
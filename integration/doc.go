@@ -0,0 +1,23 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package integration contains end-to-end tests that run MinIO
+// against a KES server and verify S3 SSE-KMS round trips. MinIO
+// is the primary consumer of KES. Hence, keystore changes, like
+// changes to the CredHub keystore, should pass these tests.
+//
+// The tests start an in-process KES server and a MinIO server
+// process. They are skipped unless a MinIO binary is specified:
+//
+//	go test ./integration -minio.binary=/path/to/minio
+//
+// By default, the KES server uses a filesystem keystore within
+// a temp. directory. Any other keystore can be tested by passing
+// a KES server config file:
+//
+//	go test ./integration -minio.binary=/path/to/minio -kes.config=credhub.yml
+//
+// Only the keystore section of the config file is used. The tests
+// create and delete keys with a random name prefix.
+package integration
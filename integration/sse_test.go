@@ -0,0 +1,313 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore/fs"
+	"github.com/minio/kes/internal/sigv4"
+	"github.com/minio/kes/kesconf"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+var (
+	minioBinary = flag.String("minio.binary", "", "Path to a MinIO server binary")
+	kesConfig   = flag.String("kes.config", "", "Path to a KES config file with the keystore under test")
+)
+
+const (
+	minioRootUser     = "minioadmin"
+	minioRootPassword = "minioadmin"
+	minioRegion       = "us-east-1"
+)
+
+func TestSSEKMS(t *testing.T) {
+	if *minioBinary == "" {
+		t.Skip("SSE-KMS integration tests disabled. Use -minio.binary=<FILE> to enable them")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	keyName := "minio-sse-" + randomSuffix(t)
+	kesEndpoint, kesClient := startKES(ctx, t, dir)
+
+	if err := kesClient.CreateKey(ctx, keyName); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", keyName, err)
+	}
+	defer kesClient.DeleteKey(context.Background(), keyName)
+
+	s3 := startMinIO(ctx, t, dir, kesEndpoint, keyName)
+
+	const Bucket = "sse-kms"
+	if status, _ := s3.do(ctx, http.MethodPut, "/"+Bucket, nil, nil); status != http.StatusOK {
+		t.Fatalf("Failed to create bucket: status '%d'", status)
+	}
+
+	object := make([]byte, 1<<20)
+	if _, err := rand.Read(object); err != nil {
+		t.Fatalf("Failed to generate object: %v", err)
+	}
+	encryptionContext := base64.StdEncoding.EncodeToString([]byte(`{"purpose":"integration-test"}`))
+	for i, test := range []struct {
+		Headers map[string]string
+	}{
+		{Headers: map[string]string{ // SSE-KMS with MinIO's default key
+			"X-Amz-Server-Side-Encryption": "aws:kms",
+		}},
+		{Headers: map[string]string{ // SSE-KMS with explicit key
+			"X-Amz-Server-Side-Encryption":                "aws:kms",
+			"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": keyName,
+		}},
+		{Headers: map[string]string{ // SSE-KMS with explicit key and encryption context
+			"X-Amz-Server-Side-Encryption":                "aws:kms",
+			"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": keyName,
+			"X-Amz-Server-Side-Encryption-Context":        encryptionContext,
+		}},
+	} {
+		path := fmt.Sprintf("/%s/object-%d", Bucket, i)
+		if status, _ := s3.do(ctx, http.MethodPut, path, test.Headers, object); status != http.StatusOK {
+			t.Fatalf("Test %d: failed to upload object: status '%d'", i, status)
+		}
+
+		status, resp := s3.do(ctx, http.MethodGet, path, nil, nil)
+		if status != http.StatusOK {
+			t.Fatalf("Test %d: failed to download object: status '%d'", i, status)
+		}
+		if sse := resp.Header.Get("X-Amz-Server-Side-Encryption"); sse != "aws:kms" {
+			t.Fatalf("Test %d: object is not encrypted with SSE-KMS: got '%s' - want '%s'", i, sse, "aws:kms")
+		}
+		if !bytes.Equal(resp.Body, object) {
+			t.Fatalf("Test %d: object content mismatch", i)
+		}
+	}
+}
+
+// startKES starts an in-process KES server using the keystore
+// under test. It returns the server endpoint and an admin client.
+func startKES(ctx context.Context, t *testing.T, dir string) (string, *kesdk.Client) {
+	var store kes.KeyStore
+	if *kesConfig != "" {
+		config, err := kesconf.ReadFile(*kesConfig)
+		if err != nil {
+			t.Fatalf("Failed to read KES config file: %v", err)
+		}
+		if store, err = config.KeyStore.Connect(ctx); err != nil {
+			t.Fatalf("Failed to connect to keystore: %v", err)
+		}
+	} else {
+		var err error
+		if store, err = fs.NewStore(filepath.Join(dir, "keys")); err != nil {
+			t.Fatalf("Failed to create keystore: %v", err)
+		}
+	}
+
+	serverCert := writeCertificate(t, dir, "kes-server", true)
+	clientCert := writeCertificate(t, dir, "minio", false)
+	identity := sha256.Sum256(clientCert.Leaf.RawSubjectPublicKeyInfo)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &kes.Server{}
+	go srv.Start(ctx, ln, &kes.Config{
+		Admin: kesdk.Identity(hex.EncodeToString(identity[:])),
+		TLS: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequestClientCert,
+			Certificates: []tls.Certificate{serverCert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		},
+		Cache: &kes.CacheConfig{
+			Expiry:       5 * time.Minute,
+			ExpiryUnused: 30 * time.Second,
+		},
+		Keys: store,
+	})
+	t.Cleanup(func() { srv.Close() })
+	for srv.Addr() == "" {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.Leaf)
+	endpoint := "https://" + ln.Addr().String()
+	return endpoint, kesdk.NewClientWithConfig(endpoint, &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      rootCAs,
+	})
+}
+
+// startMinIO starts a MinIO server process that uses the KES
+// server at kesEndpoint and returns an S3 client for it.
+func startMinIO(ctx context.Context, t *testing.T, dir, kesEndpoint, keyName string) *s3Client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cmd := exec.CommandContext(ctx, *minioBinary, "server", "--quiet", "--address", addr, filepath.Join(dir, "minio"))
+	cmd.Env = append(os.Environ(),
+		"MINIO_ROOT_USER="+minioRootUser,
+		"MINIO_ROOT_PASSWORD="+minioRootPassword,
+		"MINIO_KMS_KES_ENDPOINT="+kesEndpoint,
+		"MINIO_KMS_KES_CERT_FILE="+filepath.Join(dir, "minio.crt"),
+		"MINIO_KMS_KES_KEY_FILE="+filepath.Join(dir, "minio.key"),
+		"MINIO_KMS_KES_CAPATH="+filepath.Join(dir, "kes-server.crt"),
+		"MINIO_KMS_KES_KEY_NAME="+keyName,
+	)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err = cmd.Start(); err != nil {
+		t.Fatalf("Failed to start MinIO: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if t.Failed() {
+			t.Logf("MinIO output:\n%s", output.String())
+		}
+	})
+
+	client := &s3Client{endpoint: "http://" + addr}
+	for deadline := time.Now().Add(30 * time.Second); ; {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, client.endpoint+"/minio/health/ready", nil)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("MinIO is not ready after 30s")
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return client
+}
+
+// s3Client sends signed S3 requests to a MinIO server.
+type s3Client struct {
+	endpoint string
+}
+
+type s3Response struct {
+	Header http.Header
+	Body   []byte
+}
+
+func (c *s3Client) do(ctx context.Context, method, path string, headers map[string]string, body []byte) (int, *s3Response) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	sigv4.Sign(req, body, sigv4.Credentials{AccessKey: minioRootUser, SecretKey: minioRootPassword}, minioRegion, "s3", time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil
+	}
+	return resp.StatusCode, &s3Response{Header: resp.Header, Body: respBody}
+}
+
+// writeCertificate generates a self-signed certificate for
+// 127.0.0.1 and writes it as <name>.crt and <name>.key to dir.
+func writeCertificate(t *testing.T, dir, name string, isCA bool) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		t.Fatalf("Failed to generate serial number: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	privateKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKey})
+	if err = os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write private key: %v", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(raw); err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func randomSuffix(t *testing.T) string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		t.Fatalf("Failed to generate random name: %v", err)
+	}
+	return hex.EncodeToString(b[:])
+}
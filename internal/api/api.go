@@ -26,16 +26,61 @@ const (
 	PathReady    = "/v1/ready"
 	PathMetrics  = "/v1/metrics"
 	PathListAPIs = "/v1/api"
-
-	PathKeyCreate   = "/v1/key/create/"
-	PathKeyImport   = "/v1/key/import/"
-	PathKeyDescribe = "/v1/key/describe/"
-	PathKeyDelete   = "/v1/key/delete/"
-	PathKeyList     = "/v1/key/list/"
-	PathKeyGenerate = "/v1/key/generate/"
-	PathKeyEncrypt  = "/v1/key/encrypt/"
-	PathKeyDecrypt  = "/v1/key/decrypt/"
-	PathKeyHMAC     = "/v1/key/hmac/"
+	PathConfig   = "/v1/config"
+	PathUnseal   = "/v1/unseal"
+	PathRandom   = "/v1/random/"
+	PathJWKS     = "/.well-known/jwks.json"
+
+	PathKeyCreate        = "/v1/key/create/"
+	PathKeyImport        = "/v1/key/import/"
+	PathKeyImportParams  = "/v1/key/import-params"
+	PathKeyExport        = "/v1/key/export/"
+	PathKeyDescribe      = "/v1/key/describe/"
+	PathKeyDelete        = "/v1/key/delete/"
+	PathKeyUndelete      = "/v1/key/undelete/"
+	PathKeyProtect       = "/v1/key/protect/"
+	PathKeyUnprotect     = "/v1/key/unprotect/"
+	PathKeyGrant         = "/v1/key/grant/"
+	PathKeyList          = "/v1/key/list/"
+	PathKeyGenerate      = "/v1/key/generate/"
+	PathKeyEncrypt       = "/v1/key/encrypt/"
+	PathKeyDecrypt       = "/v1/key/decrypt/"
+	PathKeyReencrypt     = "/v1/key/reencrypt/"
+	PathKeyEncryptBatch  = "/v1/key/batch/encrypt/"
+	PathKeyDecryptBatch  = "/v1/key/batch/decrypt/"
+	PathKeyEncryptStream = "/v1/key/stream/encrypt/"
+	PathKeyDecryptStream = "/v1/key/stream/decrypt/"
+	PathKeyEncryptDet    = "/v1/key/deterministic/encrypt/"
+	PathKeyDecryptDet    = "/v1/key/deterministic/decrypt/"
+	PathKeyEncryptFPE    = "/v1/key/fpe/encrypt/"
+	PathKeyDecryptFPE    = "/v1/key/fpe/decrypt/"
+	PathKeyHMAC          = "/v1/key/hmac/"
+	PathKeyHMACVerify    = "/v1/key/hmac-verify/"
+	PathKeyDerive        = "/v1/key/derive/"
+	PathKeyRotate        = "/v1/key/rotate/"
+	PathKeyPublic        = "/v1/key/public/"
+	PathKeySign          = "/v1/key/sign/"
+	PathKeyVerify        = "/v1/key/verify/"
+	PathKeyTimestamp     = "/v1/key/timestamp/"
+	PathKeySignJWS       = "/v1/key/jws/sign/"
+	PathKeyEncryptJWE    = "/v1/key/jwe/encrypt/"
+	PathKeyDecryptJWE    = "/v1/key/jwe/decrypt/"
+	PathKeyUnwrap        = "/v1/key/unwrap/"
+
+	PathCACertificate = "/v1/ca/cert/"
+	PathCASign        = "/v1/ca/sign/"
+	PathSSHCA         = "/v1/ssh/ca/"
+	PathSSHSign       = "/v1/ssh/sign/"
+
+	PathTokenOpen   = "/v1/token/open"
+	PathTokenClose  = "/v1/token/close"
+	PathTokenSign   = "/v1/token/sign/"
+	PathTokenUnwrap = "/v1/token/unwrap/"
+
+	PathSecretCreate = "/v1/secret/create/"
+	PathSecretRead   = "/v1/secret/read/"
+	PathSecretDelete = "/v1/secret/delete/"
+	PathSecretList   = "/v1/secret/list/"
 
 	PathPolicyDescribe = "/v1/policy/describe/"
 	PathPolicyRead     = "/v1/policy/read/"
@@ -45,8 +90,18 @@ const (
 	PathIdentityList         = "/v1/identity/list/"
 	PathIdentitySelfDescribe = "/v1/identity/self/describe"
 
+	PathKeyStoreSwitch = "/v1/keystore/switch/"
+	PathKeyStorePurge  = "/v1/keystore/purge"
+	PathKeyStoreTest   = "/v1/keystore/test/"
+
+	PathPeerNotify = "/v1/peer/notify/"
+	PathReplicate  = "/v1/replicate/"
+
 	PathLogError = "/v1/log/error"
 	PathLogAudit = "/v1/log/audit"
+
+	PathDebugPprof   = "/v1/debug/pprof/"
+	PathDebugRuntime = "/v1/debug/runtime"
 )
 
 // Route represents an API route handling a client request.
@@ -184,6 +239,8 @@ type Request struct {
 
 	Identity kes.Identity
 
+	Enclave string // Empty for the default enclave
+
 	Resource string
 
 	Received time.Time
@@ -230,10 +287,21 @@ func (r *Response) Failf(code int, format string, v ...any) error {
 	return Failf(r, code, format, v...)
 }
 
+// FailCode is a shorthand for api.FailCode. FailCode responds to
+// the client with the given status code, error code and message.
+func (r *Response) FailCode(code int, errCode, msg string) error {
+	return FailCode(r, code, errCode, msg)
+}
+
 // Failr is a shorthand for api.Failr. Failr responds to the
 // client with err.
 func (r *Response) Failr(err Error) error { return Failr(r, err) }
 
+// Unwrap returns the underlying ResponseWriter.
+//
+// This method is mainly used in the context of ResponseController.
+func (r *Response) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
 // Reply sends just an HTTP status code to the client.
 // The response body is empty.
 func Reply(r *Response, code int) {
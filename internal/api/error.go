@@ -38,11 +38,27 @@ func Failf(r *Response, code int, format string, a ...any) error {
 // automatically based on the response content type. Handlers
 // should return after calling Fail.
 func Fail(r *Response, code int, msg string) error {
+	return FailCode(r, code, "", msg)
+}
+
+// FailCode responds to the client with the given status code,
+// error code and error message. The error code is a stable,
+// machine-readable identifier of the error's cause, like
+// "backend_unreachable", that clients can branch on. If the
+// error code is empty, FailCode behaves like Fail. Handlers
+// should return after calling FailCode.
+func FailCode(r *Response, code int, errCode, msg string) error {
 	var buf bytes.Buffer
 	buf.WriteString(`{"message":`)
 	if err := json.NewEncoder(&buf).Encode(msg); err != nil {
 		return err
 	}
+	if errCode != "" {
+		buf.WriteString(`,"code":`)
+		if err := json.NewEncoder(&buf).Encode(errCode); err != nil {
+			return err
+		}
+	}
 	buf.WriteByte('}')
 
 	r.Header().Set(headers.ContentType, headers.ContentTypeJSON)
@@ -4,10 +4,40 @@
 
 package api
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// CreateKeyRequest is the request sent by clients when calling the CreateKey API.
+// The request body is optional.
+type CreateKeyRequest struct {
+	Cipher     string            `json:"cipher"`     // optional, defaults to AES256 or ChaCha20
+	Tags       map[string]string `json:"tags"`       // optional, e.g. {"env": "prod"}
+	Operations []string          `json:"operations"` // optional, e.g. ["generate", "unwrap"]. If empty, all operations
+}
+
+// GrantKeyRequest is the request sent by clients when calling the GrantKey API.
+type GrantKeyRequest struct {
+	Identity   string   `json:"identity"`
+	Operations []string `json:"operations"` // If empty, the identity's grant is removed
+}
+
 // ImportKeyRequest is the request sent by clients when calling the ImportKey API.
 type ImportKeyRequest struct {
-	Bytes  []byte `json:"key"`
-	Cipher string `json:"cipher"`
+	Bytes        []byte `json:"key"`
+	WrappedBytes []byte `json:"wrapped_key"` // optional, key encrypted with the import wrapping key
+	Cipher       string `json:"cipher"`
+
+	// Export is an optional key exported by another KES server
+	// with the import wrapping key as transport key. All versions
+	// of the exported key are imported.
+	Export *ExportKeyResponse `json:"export,omitempty"`
+}
+
+// ExportKeyRequest is the request sent by clients when calling the ExportKey API.
+type ExportKeyRequest struct {
+	PublicKey []byte `json:"public_key"` // PKIX, ASN.1 DER encoded RSA public key
 }
 
 // EncryptKeyRequest is the request sent by clients when calling the EncryptKey API.
@@ -27,7 +57,160 @@ type DecryptKeyRequest struct {
 	Context    []byte `json:"context"` // optional
 }
 
+// EncryptKeyBatchRequest is the request sent by clients when calling the EncryptKeyBatch API.
+type EncryptKeyBatchRequest struct {
+	Items []EncryptKeyRequest `json:"items"`
+}
+
+// DecryptKeyBatchRequest is the request sent by clients when calling the DecryptKeyBatch API.
+type DecryptKeyBatchRequest struct {
+	Items []DecryptKeyRequest `json:"items"`
+}
+
+// ReencryptKeyRequest is the request sent by clients when calling the ReencryptKey API.
+type ReencryptKeyRequest struct {
+	Ciphertext []byte `json:"ciphertext"`
+	Context    []byte `json:"context"` // optional
+}
+
 // HMACRequest is the request sent by clients when calling the HMAC API.
 type HMACRequest struct {
 	Message []byte `json:"message"`
+	Hash    string `json:"hash"` // optional, defaults to SHA256
+}
+
+// VerifyHMACRequest is the request sent by clients when calling the VerifyHMAC API.
+type VerifyHMACRequest struct {
+	Message []byte `json:"message"`
+	Sum     []byte `json:"hmac"`
+	Hash    string `json:"hash"` // optional, defaults to SHA256
+}
+
+// DeriveKeyRequest is the request sent by clients when calling the DeriveKey API.
+type DeriveKeyRequest struct {
+	Name    string `json:"name"`
+	Context []byte `json:"context"` // optional
+	Length  int    `json:"length"`  // optional, defaults to 32 bytes
+	Version int    `json:"version"` // optional, defaults to the latest key version
+}
+
+// FPERequest is the request sent by clients when calling the EncryptFPE
+// or DecryptFPE API.
+type FPERequest struct {
+	Value     string `json:"value"`
+	Tweak     []byte `json:"tweak"`     // optional
+	Algorithm string `json:"algorithm"` // optional, "FF1" or "FF3-1". Defaults to FF1
+	Alphabet  string `json:"alphabet"`  // optional, defaults to the first radix characters of [0-9a-zA-Z]
+	Radix     int    `json:"radix"`     // optional, defaults to the alphabet size or 10
+}
+
+// SignRequest is the request sent by clients when calling the Sign API.
+type SignRequest struct {
+	Digest []byte `json:"digest"`
+}
+
+// TimestampRequest is the request sent by clients when calling the Timestamp API.
+type TimestampRequest struct {
+	Digest []byte `json:"digest"`
+	Hash   string `json:"hash"`  // optional, defaults to SHA256
+	Nonce  []byte `json:"nonce"` // optional
+}
+
+// SignJWSRequest is the request sent by clients when calling the SignJWS API.
+type SignJWSRequest struct {
+	Claims json.RawMessage `json:"claims"` // Must be a JSON object
+	Type   string          `json:"type"`   // optional, defaults to "JWT"
+}
+
+// EncryptJWERequest is the request sent by clients when calling the EncryptJWE API.
+type EncryptJWERequest struct {
+	Plaintext   []byte `json:"plaintext"`
+	Type        string `json:"type"`         // optional
+	ContentType string `json:"content_type"` // optional
+}
+
+// DecryptJWERequest is the request sent by clients when calling the DecryptJWE API.
+type DecryptJWERequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyRequest is the request sent by clients when calling the Verify API.
+type VerifyRequest struct {
+	Digest    []byte `json:"digest"`
+	Signature []byte `json:"signature"`
+}
+
+// OpenTokenSessionRequest is the request sent by clients when calling the
+// OpenTokenSession API. A session can only be used with the listed keys.
+type OpenTokenSessionRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// CloseTokenSessionRequest is the request sent by clients when calling the
+// CloseTokenSession API.
+type CloseTokenSessionRequest struct {
+	Session string `json:"session"`
+}
+
+// TokenSignRequest is the request sent by clients when calling the TokenSign API.
+type TokenSignRequest struct {
+	Session string `json:"session"`
+	Digest  []byte `json:"digest"`
+}
+
+// TokenUnwrapRequest is the request sent by clients when calling the TokenUnwrap API.
+type TokenUnwrapRequest struct {
+	Session    string `json:"session"`
+	Ciphertext []byte `json:"ciphertext"`
+	Context    []byte `json:"context"` // optional
+}
+
+// CreateSecretRequest is the request sent by clients when calling the CreateSecret API.
+type CreateSecretRequest struct {
+	Bytes []byte `json:"secret"`
+	Type  string `json:"type"` // optional, defaults to "generic"
+	TTL   string `json:"ttl"`  // optional, e.g. "1h". The secret expires after the TTL.
+}
+
+// SignCertificateRequest is the request sent by clients when calling the CASign API.
+type SignCertificateRequest struct {
+	CSR string `json:"csr"` // PEM encoded PKCS #10 certificate request
+	TTL string `json:"ttl"` // optional, e.g. "1h". Defaults to the profile's max. TTL.
+}
+
+// SignSSHRequest is the request sent by clients when calling the SSHSign API.
+type SignSSHRequest struct {
+	PublicKey  string   `json:"public_key"` // OpenSSH authorized_keys format
+	Principals []string `json:"principals"`
+	TTL        string   `json:"ttl"` // optional, e.g. "1h". Defaults to the profile's max. TTL.
+}
+
+// PurgeKeyStoreRequest is the request sent by clients when calling the PurgeKeyStore API.
+type PurgeKeyStoreRequest struct {
+	Patterns []string `json:"patterns"` // optional, defaults to all entries
+	DryRun   bool     `json:"dry_run"`
+}
+
+// UnsealRequest is the request sent by clients when calling the Unseal API.
+type UnsealRequest struct {
+	Share []byte `json:"share"` // optional, without a share only the unseal progress is returned
+}
+
+// PeerNotifyRequest is the request sent by KES servers when calling the PeerNotify API
+// of a peer to report that a key has been created, rotated or deleted. Like any other
+// request, it addresses an enclave via the Kes-Enclave header.
+type PeerNotifyRequest struct {
+	Event string `json:"event"` // "created", "updated" or "deleted"
+}
+
+// ReplicateRequest is the request sent by KES servers when calling the Replicate API
+// of a peer cluster to replicate a key that has been created, rotated or deleted. The
+// vector timestamp and the time of the last write decide which write wins when a key
+// has been modified on multiple clusters concurrently.
+type ReplicateRequest struct {
+	Site    string            `json:"site"`              // The site that wrote the key last
+	Clock   map[string]uint64 `json:"clock"`             // The vector timestamp: number of writes per site
+	Time    time.Time         `json:"time"`              // The time of the last write
+	Deleted bool              `json:"deleted,omitempty"` // Whether the key has been deleted
+	Key     []byte            `json:"key,omitempty"`     // The encoded key with all versions, if not deleted
 }
@@ -27,6 +27,75 @@ type StatusResponse struct {
 
 	KeyStoreLatency     int64 `json:"keystore_latency,omitempty"` // In microseconds
 	KeyStoreUnreachable bool  `json:"keystore_unreachable,omitempty"`
+
+	FIPS       bool   `json:"fips,omitempty"`        // FIPS mode is enabled
+	FIPSModule string `json:"fips_module,omitempty"` // FIPS 140 module, like "boringcrypto"
+}
+
+// RuntimeStatsResponse is the response sent to clients by the
+// runtime diagnostics API.
+type RuntimeStatsResponse struct {
+	GoVersion   string `json:"go_version"`
+	UpTime      uint64 `json:"uptime"` // in seconds
+	CPUs        int    `json:"num_cpu"`
+	UsableCPUs  int    `json:"num_cpu_used"`
+	Goroutines  int    `json:"num_goroutines"`
+	CgoCalls    int64  `json:"num_cgo_calls"`
+	HeapAlloc   uint64 `json:"mem_heap_used"`
+	HeapInuse   uint64 `json:"mem_heap_inuse"`
+	HeapObjects uint64 `json:"mem_heap_objects"`
+	StackAlloc  uint64 `json:"mem_stack_used"`
+	Sys         uint64 `json:"mem_sys"`
+
+	GCCycles    uint32    `json:"gc_cycles"`
+	GCPauseTime int64     `json:"gc_pause_time"` // in microseconds
+	GCLast      time.Time `json:"gc_last,omitempty"`
+	GCCPU       float64   `json:"gc_cpu_fraction"`
+
+	CachedKeys int `json:"cache_keys"`
+	CachedDEKs int `json:"cache_deks,omitempty"`
+}
+
+// ConfigResponse is the response sent to clients by the Config API.
+// It describes the effective server configuration. It never contains
+// credentials.
+type ConfigResponse struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Addr    string `json:"addr,omitempty"`
+	Admin   string `json:"admin"`
+	FIPS    bool   `json:"fips,omitempty"`
+
+	KeyStore        DescribeKeyStoreResponse            `json:"keystore"`
+	StandbyKeyStore map[string]DescribeKeyStoreResponse `json:"standby_keystores,omitempty"`
+
+	CacheExpiry         int64 `json:"cache_expiry,omitempty"`         // in seconds
+	CacheExpiryUnused   int64 `json:"cache_expiry_unused,omitempty"`  // in seconds
+	CacheExpiryOffline  int64 `json:"cache_expiry_offline,omitempty"` // in seconds
+	DEKCacheSize        int   `json:"dek_cache_size,omitempty"`
+	DEKCacheExpiry      int64 `json:"dek_cache_expiry,omitempty"`      // in seconds
+	SoftDeleteRetention int64 `json:"soft_delete_retention,omitempty"` // in seconds
+
+	Policies []string         `json:"policies,omitempty"`
+	Enclaves []string         `json:"enclaves,omitempty"`
+	Rotation map[string]int64 `json:"rotation,omitempty"` // Rotation interval in seconds by key name pattern
+
+	Peers      []string `json:"peers,omitempty"`
+	Replicas   []string `json:"replicas,omitempty"` // The endpoints of the peer clusters keys are replicated to
+	Site       string   `json:"site,omitempty"`     // The replication site of the server
+	Authz      string   `json:"authz,omitempty"`    // The external authorization endpoint
+	Revocation bool     `json:"revocation,omitempty"`
+
+	ReadReplica bool `json:"read_replica,omitempty"` // Whether the server is a read replica
+}
+
+// DescribeKeyStoreResponse describes a KeyStore. It is part of
+// a Config API response.
+type DescribeKeyStoreResponse struct {
+	Type        string `json:"type"`                  // The KeyStore backend, like "Hashicorp Vault"
+	Description string `json:"description,omitempty"` // The KeyStore backend and its endpoint
+	Version     string `json:"version,omitempty"`     // The backend version, if known
+	Unreachable bool   `json:"unreachable,omitempty"`
 }
 
 // DescribeRouteResponse describes a single API route. It is part of
@@ -43,10 +112,36 @@ type ListAPIsResponse []DescribeRouteResponse
 
 // DescribeKeyResponse is the response sent to clients by the DescribeKey API.
 type DescribeKeyResponse struct {
-	Name      string    `json:"name"`
-	Algorithm string    `json:"algorithm,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	CreatedBy string    `json:"created_by,omitempty"`
+	Name         string    `json:"name"`
+	Algorithm    string    `json:"algorithm,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	Versions     int       `json:"versions,omitempty"`
+	RotatedAt    time.Time `json:"rotated_at,omitempty"`
+	NextRotation time.Time `json:"next_rotation,omitempty"`
+	Uses         uint64    `json:"uses,omitempty"`
+	LastUsedAt   time.Time `json:"last_used_at,omitempty"`
+
+	Tags       map[string]string `json:"tags,omitempty"`
+	Protected  bool              `json:"protected,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+
+	Grants map[string][]string `json:"grants,omitempty"` // Operations granted to identities
+}
+
+// ImportParamsResponse is the response sent to clients by the ImportParams API.
+type ImportParamsResponse struct {
+	PublicKey []byte    `json:"public_key"` // PKIX, ASN.1 DER encoded
+	Algorithm string    `json:"algorithm"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExportKeyResponse is the response sent to clients by the ExportKey API.
+type ExportKeyResponse struct {
+	Algorithm  string `json:"algorithm"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+	Versions   int    `json:"versions"`
 }
 
 // ListKeysResponse is the response sent to clients by the ListKeys API.
@@ -71,11 +166,173 @@ type DecryptKeyResponse struct {
 	Plaintext []byte `json:"plaintext"`
 }
 
+// EncryptKeyBatchResponse is the response sent to clients by the EncryptKeyBatch API.
+// The i-th item is the ciphertext of the i-th plaintext of the request.
+type EncryptKeyBatchResponse struct {
+	Items []EncryptKeyResponse `json:"items"`
+}
+
+// DecryptKeyBatchResponse is the response sent to clients by the DecryptKeyBatch API.
+// The i-th item is the plaintext of the i-th ciphertext of the request.
+type DecryptKeyBatchResponse struct {
+	Items []DecryptKeyResponse `json:"items"`
+}
+
+// ReencryptKeyResponse is the response sent to clients by the ReencryptKey API.
+type ReencryptKeyResponse struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
 // HMACResponse is the response sent to clients by the HMAC API.
 type HMACResponse struct {
 	Sum []byte `json:"hmac"`
 }
 
+// VerifyHMACResponse is the response sent to clients by the VerifyHMAC API.
+type VerifyHMACResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// DeriveKeyResponse is the response sent to clients by the DeriveKey API.
+type DeriveKeyResponse struct {
+	Key     []byte `json:"key"`
+	Version int    `json:"version"` // The key version the key has been derived from
+}
+
+// FPEResponse is the response sent to clients by the EncryptFPE and
+// DecryptFPE API.
+type FPEResponse struct {
+	Value string `json:"value"`
+}
+
+// RandomResponse is the response sent to clients by the Random API.
+type RandomResponse struct {
+	Bytes []byte `json:"bytes"`
+}
+
+// PublicKeyResponse is the response sent to clients by the PublicKey API.
+type PublicKeyResponse struct {
+	PublicKey []byte `json:"public_key"` // PKIX, ASN.1 DER encoded
+	Algorithm string `json:"algorithm"`
+}
+
+// OpenTokenSessionResponse is the response sent to clients by the OpenTokenSession API.
+type OpenTokenSessionResponse struct {
+	Session   string    `json:"session"`
+	ExpiresAt time.Time `json:"expires_at"` // Extended whenever the session is used
+}
+
+// SignResponse is the response sent to clients by the Sign API.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// TimestampResponse is the response sent to clients by the Timestamp API.
+// The signature is a signature of the SHA-256 hash of the token, which
+// is a JSON encoded TimestampToken.
+type TimestampResponse struct {
+	Token     []byte `json:"token"`
+	Signature []byte `json:"signature"`
+}
+
+// TimestampToken is the content of a signed timestamp. It attests
+// that the digest existed at the given time.
+type TimestampToken struct {
+	Version   int       `json:"version"`
+	Key       string    `json:"key"`
+	Algorithm string    `json:"algorithm"`
+	Hash      string    `json:"hash"`
+	Digest    []byte    `json:"digest"`
+	Time      time.Time `json:"time"`
+	Serial    []byte    `json:"serial"`
+	Nonce     []byte    `json:"nonce,omitempty"`
+}
+
+// SignJWSResponse is the response sent to clients by the SignJWS API.
+// The token is a JWS in compact serialization. The key ID identifies
+// the key and key version as "<name>.<version>".
+type SignJWSResponse struct {
+	Token string `json:"token"`
+	KeyID string `json:"kid"`
+}
+
+// EncryptJWEResponse is the response sent to clients by the EncryptJWE API.
+// The token is a JWE in compact serialization.
+type EncryptJWEResponse struct {
+	Token string `json:"token"`
+}
+
+// DecryptJWEResponse is the response sent to clients by the DecryptJWE API.
+type DecryptJWEResponse struct {
+	Plaintext []byte `json:"plaintext"`
+}
+
+// JWKSResponse is the response sent to clients by the JWKS API.
+// It is a JSON Web Key Set as specified by RFC 7517.
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key. Its key ID identifies
+// the key and key version as "<name>.<version>".
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`           // base64url encoded
+	Y         string `json:"y,omitempty"` // base64url encoded, only for EC keys
+}
+
+// CACertificateResponse is the response sent to clients by the CACertificate API.
+type CACertificateResponse struct {
+	Certificate string `json:"certificate"` // PEM encoded CA certificate of the latest key version
+	Bundle      string `json:"bundle"`      // PEM encoded CA certificates of all key versions
+}
+
+// SignCertificateResponse is the response sent to clients by the CASign API.
+type SignCertificateResponse struct {
+	Certificate string    `json:"certificate"` // PEM encoded certificate
+	CA          string    `json:"ca"`          // PEM encoded CA certificate
+	Serial      string    `json:"serial"`      // Hex encoded serial number
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// SSHCAResponse is the response sent to clients by the SSHCA API.
+type SSHCAResponse struct {
+	PublicKey string `json:"public_key"` // CA public key of the latest key version, in authorized_keys format
+	Bundle    string `json:"bundle"`     // CA public keys of all key versions, one per line
+}
+
+// SignSSHResponse is the response sent to clients by the SSHSign API.
+type SignSSHResponse struct {
+	Certificate string    `json:"certificate"` // OpenSSH certificate in authorized_keys format
+	Serial      uint64    `json:"serial"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// VerifyResponse is the response sent to clients by the Verify API.
+type VerifyResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// ReadSecretResponse is the response sent to clients by the ReadSecret API.
+type ReadSecretResponse struct {
+	Name      string    `json:"name"`
+	Bytes     []byte    `json:"secret"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// ListSecretsResponse is the response sent to clients by the ListSecrets API.
+type ListSecretsResponse struct {
+	Names      []string `json:"names"`
+	ContinueAt string   `json:"continue_at,omitempty"`
+}
+
 // ReadPolicyResponse is the response sent to clients by the ReadPolicy API.
 type ReadPolicyResponse struct {
 	Name      string              `json:"name"`
@@ -122,6 +379,34 @@ type SelfDescribeIdentityResponse struct {
 	Policy *ReadPolicyResponse `json:"policy,omitempty"`
 }
 
+// PurgeKeyStoreResponse is the response sent to clients by the PurgeKeyStore API.
+type PurgeKeyStoreResponse struct {
+	Names  []string `json:"names"`
+	DryRun bool     `json:"dry_run"`
+}
+
+// TestKeyStoreResponse is the response sent to clients by the TestKeyStore API.
+type TestKeyStoreResponse struct {
+	KeyStore string             `json:"keystore"` // The KeyStore backend, like "Hashicorp Vault"
+	Success  bool               `json:"success"`
+	Steps    []KeyStoreTestStep `json:"steps"`
+}
+
+// KeyStoreTestStep is a single step of a KeyStore self-test. It is
+// part of a TestKeyStore API response.
+type KeyStoreTestStep struct {
+	Name    string `json:"name"`            // The step, like "create" or "get"
+	Latency int64  `json:"latency"`         // in microseconds
+	Error   string `json:"error,omitempty"` // Empty if the step succeeded
+}
+
+// UnsealResponse is the response sent to clients by the Unseal API.
+type UnsealResponse struct {
+	Sealed    bool `json:"sealed"`
+	Threshold int  `json:"threshold,omitempty"` // Number of unseal key shares required
+	Progress  int  `json:"progress,omitempty"`  // Number of unseal key shares submitted
+}
+
 // AuditLogEvent is sent to clients (as stream of events) when they subscribe to the AuditLog API.
 type AuditLogEvent struct {
 	Time     time.Time        `json:"time"`
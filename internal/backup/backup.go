@@ -0,0 +1,152 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package backup implements an encrypted archive format for
+// keystore entries.
+//
+// An archive consists of a plaintext header followed by the
+// encrypted entries. The header contains a format version,
+// the key derivation parameters and a random nonce:
+//
+//	"kes-backup" | version | salt | argon2 time | argon2 memory | argon2 threads | nonce
+//
+// The entries are encrypted with XChaCha20-Poly1305 using a key
+// derived from a passphrase via Argon2id. The header is passed
+// as associated data such that it cannot be modified without
+// invalidating the archive.
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ErrDecrypt is returned by Open when an archive cannot be
+// decrypted, either because the passphrase is wrong or the
+// archive has been modified.
+var ErrDecrypt = errors.New("backup: invalid passphrase or corrupted archive")
+
+// Entry is a keystore entry within an archive.
+type Entry struct {
+	Name  string `json:"name"`
+	Value []byte `json:"value"`
+}
+
+// Archive is a set of keystore entries.
+type Archive struct {
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"entries"`
+}
+
+const (
+	magic   = "kes-backup"
+	version = 1
+
+	saltSize   = 16
+	headerSize = len(magic) + 1 + saltSize + 4 + 4 + 1 + chacha20poly1305.NonceSizeX
+
+	// Argon2id parameters used for new archives.
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // in KiB
+	argon2Threads = 4
+
+	// maxArgon2Memory limits the memory an archive may request
+	// for key derivation to protect against malicious archives.
+	maxArgon2Memory = 4 * 1024 * 1024 // in KiB
+	maxArgon2Time   = 64
+)
+
+// Seal encrypts the archive with a key derived from the passphrase
+// and writes it to w.
+func Seal(w io.Writer, archive *Archive, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("backup: passphrase is empty")
+	}
+	plaintext, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+
+	var salt [saltSize]byte
+	if _, err = rand.Read(salt[:]); err != nil {
+		return err
+	}
+	header = append(header, salt[:]...)
+	header = binary.BigEndian.AppendUint32(header, argon2Time)
+	header = binary.BigEndian.AppendUint32(header, argon2Memory)
+	header = append(header, argon2Threads)
+
+	var nonce [chacha20poly1305.NonceSizeX]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	header = append(header, nonce[:]...)
+
+	key := argon2.IDKey(passphrase, salt[:], argon2Time, argon2Memory, argon2Threads, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(aead.Seal(nil, nonce[:], plaintext, header))
+	return err
+}
+
+// Open reads an encrypted archive from r and decrypts it with a
+// key derived from the passphrase. It returns ErrDecrypt if the
+// passphrase is wrong or the archive has been modified.
+func Open(r io.Reader, passphrase []byte) (*Archive, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < headerSize || !bytes.HasPrefix(b, []byte(magic)) {
+		return nil, errors.New("backup: not a KES backup archive")
+	}
+	header, ciphertext := b[:headerSize], b[headerSize:]
+
+	p := header[len(magic):]
+	if p[0] != version {
+		return nil, fmt.Errorf("backup: unsupported archive version %d", p[0])
+	}
+	p = p[1:]
+	salt, p := p[:saltSize], p[saltSize:]
+	time, p := binary.BigEndian.Uint32(p), p[4:]
+	memory, p := binary.BigEndian.Uint32(p), p[4:]
+	threads, nonce := p[0], p[1:]
+	if time == 0 || time > maxArgon2Time || memory == 0 || memory > maxArgon2Memory || threads == 0 {
+		return nil, errors.New("backup: invalid key derivation parameters")
+	}
+
+	key := argon2.IDKey(passphrase, salt, time, memory, threads, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var archive Archive
+	if err = json.Unmarshal(plaintext, &archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
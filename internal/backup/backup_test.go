@@ -0,0 +1,60 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSealOpen(t *testing.T) {
+	t.Parallel()
+
+	archive := &Archive{
+		CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Entries: []Entry{
+			{Name: "my-key", Value: []byte("my-value")},
+			{Name: "my-key-2", Value: []byte{0, 1, 2, 3}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Seal(&buf, archive, []byte("my-passphrase")); err != nil {
+		t.Fatalf("Failed to seal archive: %v", err)
+	}
+	sealed := buf.Bytes()
+
+	opened, err := Open(bytes.NewReader(sealed), []byte("my-passphrase"))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	if !opened.CreatedAt.Equal(archive.CreatedAt) {
+		t.Fatalf("Archive creation time mismatch: got '%v' - want '%v'", opened.CreatedAt, archive.CreatedAt)
+	}
+	if len(opened.Entries) != len(archive.Entries) {
+		t.Fatalf("Archive entries mismatch: got '%d' - want '%d'", len(opened.Entries), len(archive.Entries))
+	}
+	for i, entry := range opened.Entries {
+		if entry.Name != archive.Entries[i].Name || !bytes.Equal(entry.Value, archive.Entries[i].Value) {
+			t.Fatalf("Entry %d: got '%s' - want '%s'", i, entry.Name, archive.Entries[i].Name)
+		}
+	}
+
+	if _, err = Open(bytes.NewReader(sealed), []byte("wrong-passphrase")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Opened archive with wrong passphrase: got '%v' - want '%v'", err, ErrDecrypt)
+	}
+
+	modified := bytes.Clone(sealed)
+	modified[len(magic)+1] ^= 1 // Modify the salt
+	if _, err = Open(bytes.NewReader(modified), []byte("my-passphrase")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Opened modified archive: got '%v' - want '%v'", err, ErrDecrypt)
+	}
+
+	if err = Seal(&buf, archive, nil); err == nil {
+		t.Fatal("Sealed archive with empty passphrase")
+	}
+}
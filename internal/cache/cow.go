@@ -124,6 +124,66 @@ func (c *Cow[K, V]) Add(key K, value V) bool {
 	return true
 }
 
+// Replace replaces the value of an existing entry
+// and reports whether such an entry exists. It
+// does not add the value if no such entry exists.
+func (c *Cow[K, V]) Replace(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.ptr.Load()
+	if p == nil {
+		return false
+	}
+
+	r := *p
+	if _, ok := r[key]; !ok {
+		return false
+	}
+
+	w := make(map[K]V, len(r))
+	for k, v := range r {
+		w[k] = v
+	}
+	w[key] = value
+
+	c.ptr.Store(&w)
+	return true
+}
+
+// ReplaceFunc replaces the value of an existing entry
+// with the value returned by f if and only if f reports
+// true. It calls f with the current value while holding
+// the Cow's lock. ReplaceFunc reports whether the value
+// has been replaced.
+func (c *Cow[K, V]) ReplaceFunc(key K, f func(V) (V, bool)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.ptr.Load()
+	if p == nil {
+		return false
+	}
+
+	r := *p
+	v, ok := r[key]
+	if !ok {
+		return false
+	}
+	if v, ok = f(v); !ok {
+		return false
+	}
+
+	w := make(map[K]V, len(r))
+	for k, v := range r {
+		w[k] = v
+	}
+	w[key] = v
+
+	c.ptr.Store(&w)
+	return true
+}
+
 // Delete removes the given entry and reports
 // whether it was present.
 func (c *Cow[K, V]) Delete(key K) bool {
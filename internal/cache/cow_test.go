@@ -42,6 +42,36 @@ func TestCowZeroValue(t *testing.T) {
 			t.Fatal("Added the same key to an empty Cow twice")
 		}
 	})
+	t.Run("Replace", func(t *testing.T) {
+		var cow Cow[int, string]
+		if cow.Replace(0, "Hello") {
+			t.Fatal("Replaced value of empty Cow")
+		}
+		if _, ok := cow.Get(0); ok {
+			t.Fatal("Replace added value to empty Cow")
+		}
+	})
+	t.Run("ReplaceFunc", func(t *testing.T) {
+		var cow Cow[int, string]
+		if cow.ReplaceFunc(0, func(string) (string, bool) { return "Hello", true }) {
+			t.Fatal("Replaced value of empty Cow")
+		}
+	})
+}
+
+func TestCowReplaceFunc(t *testing.T) {
+	var cow Cow[int, string]
+	cow.Set(0, "Hello")
+
+	if cow.ReplaceFunc(0, func(v string) (string, bool) { return "World", v == "World" }) {
+		t.Fatal("Replaced value even though f returned false")
+	}
+	if !cow.ReplaceFunc(0, func(v string) (string, bool) { return "World", v == "Hello" }) {
+		t.Fatal("Failed to replace value")
+	}
+	if v, _ := cow.Get(0); v != "World" {
+		t.Fatalf("Invalid value: got '%s' - want '%s'", v, "World")
+	}
 }
 
 func TestCowCapacity(t *testing.T) {
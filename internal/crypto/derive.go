@@ -0,0 +1,70 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Limits of derived keys.
+const (
+	MinDerivedKeySize = 16  // The minimum size of a derived key in bytes
+	MaxDerivedKeySize = 64  // The maximum size of a derived key in bytes
+	MaxDeriveNameSize = 255 // The maximum size of a derived key name in bytes
+)
+
+// ErrNoDerivation is returned when a signing or wrapping key
+// is used for deriving keys.
+var ErrNoDerivation = kes.NewError(http.StatusConflict, "key does not support key derivation")
+
+// deriveLabel is the HKDF info prefix of derived keys. It separates
+// derived keys from other keys computed from the same secret key.
+const deriveLabel = "KES HKDF-SHA256 derive"
+
+// Derive derives a n bytes long key from the SecretKey using
+// HKDF-SHA256. The derived key is bound to the name and the
+// context. Deriving a key with the same name and context
+// always produces the same key. Different names or contexts
+// produce independent keys.
+//
+// Derived keys do not reveal the SecretKey or any other key
+// derived from it.
+func (s SecretKey) Derive(name string, context []byte, n int) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoDerivation
+	}
+	if name == "" {
+		return nil, errors.New("crypto: derived key name is empty")
+	}
+	if len(name) > MaxDeriveNameSize {
+		return nil, errors.New("crypto: derived key name is too long")
+	}
+	if n < MinDerivedKeySize || n > MaxDerivedKeySize {
+		return nil, errors.New("crypto: invalid derived key size")
+	}
+
+	// The name is length-prefixed such that no name and context
+	// pair produces the same info as another one.
+	info := make([]byte, 0, len(deriveLabel)+1+len(name)+len(context))
+	info = append(info, deriveLabel...)
+	info = append(info, byte(len(name)))
+	info = append(info, name...)
+	info = append(info, context...)
+
+	key := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, s.key[:], nil, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
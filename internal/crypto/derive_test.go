@@ -0,0 +1,113 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestSecretKeyDerive(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range deriveTests {
+		key, err := NewSecretKey(test.Type, mustDecodeHex(test.Key))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create key: %v", i, err)
+		}
+
+		derived, err := key.Derive(test.Name, []byte(test.Context), test.Size)
+		if err != nil {
+			t.Fatalf("Test %d: failed to derive key: %v", i, err)
+		}
+		if d := hex.EncodeToString(derived); d != test.Derived {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, d, test.Derived)
+		}
+	}
+}
+
+func TestSecretKeyDeriveIndependent(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// The name is length-prefixed. Hence, moving bytes
+	// between name and context produces a different key.
+	key1, err := key.Derive("bucket", []byte("-1"), 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	key2, err := key.Derive("bucket-1", nil, 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	if bytes.Equal(key1, key2) {
+		t.Fatal("Derived keys with different names are equal")
+	}
+}
+
+func TestSecretKeyDeriveInvalid(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = key.Derive("", nil, 32); err == nil {
+		t.Fatal("Derived key with empty name")
+	}
+	if _, err = key.Derive("my-key", nil, MinDerivedKeySize-1); err == nil {
+		t.Fatal("Derived key shorter than the min. size")
+	}
+	if _, err = key.Derive("my-key", nil, MaxDerivedKeySize+1); err == nil {
+		t.Fatal("Derived key larger than the max. size")
+	}
+
+	signingKey, err := GenerateSecretKey(Ed25519, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = signingKey.Derive("my-key", nil, 32); !errors.Is(err, ErrNoDerivation) {
+		t.Fatalf("Deriving with signing key: got '%v' - want '%v'", err, ErrNoDerivation)
+	}
+}
+
+var deriveTests = []struct {
+	Type    SecretKeyType
+	Key     string
+	Name    string
+	Context string
+	Size    int
+	Derived string
+}{
+	{
+		Type:    AES256,
+		Key:     "0000000000000000000000000000000000000000000000000000000000000000",
+		Name:    "bucket-1",
+		Size:    32,
+		Derived: "3ee266e133ad14e1527c2d686d41426827a23eb337be89eedcf45af9014bcb0b",
+	},
+	{
+		Type:    ChaCha20,
+		Key:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		Name:    "my-bucket.example.com",
+		Context: "tenant-1",
+		Size:    16,
+		Derived: "c5629cebdf87f02321cc051f4abf7560",
+	},
+	{
+		Type:    AES256,
+		Key:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		Name:    "my-bucket.example.com",
+		Context: "tenant-1",
+		Size:    64,
+		Derived: "c5629cebdf87f02321cc051f4abf7560809bb559ee9e7adc579c37f203f80aeac8e9ac339c574e9725db9987d512f91d62e321a2e5fe6d8055777d922589818e",
+	},
+}
@@ -0,0 +1,402 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/minio/kes/internal/secmem"
+)
+
+// FPEAlgorithm is a format-preserving encryption algorithm
+// as specified by NIST SP 800-38G Rev. 1.
+type FPEAlgorithm uint
+
+// Supported format-preserving encryption algorithms.
+const (
+	// FF1 is the FF1 algorithm. It accepts tweaks of any
+	// length up to MaxFF1TweakSize bytes.
+	FF1 FPEAlgorithm = iota + 1
+
+	// FF31 is the FF3-1 algorithm. It requires 7 bytes
+	// long tweaks.
+	FF31
+)
+
+// Limits of format-preserving encryption.
+const (
+	MinFPERadix     = 2
+	MaxFPERadix     = 1 << 16
+	MaxFF1Length    = 256 // The max. number of numerals encrypted by FF1
+	MaxFF1TweakSize = 256 // The max. size of an FF1 tweak in bytes
+	FF31TweakSize   = 7   // The size of an FF3-1 tweak in bytes
+)
+
+// ParseFPEAlgorithm parses s as format-preserving encryption
+// algorithm. It returns FF1 if s is empty.
+func ParseFPEAlgorithm(s string) (FPEAlgorithm, error) {
+	switch strings.ToUpper(s) {
+	case "", "FF1":
+		return FF1, nil
+	case "FF3-1", "FF31":
+		return FF31, nil
+	default:
+		return 0, fmt.Errorf("crypto: format-preserving encryption algorithm '%s' is not supported", s)
+	}
+}
+
+// String returns the string representation of the FPEAlgorithm.
+func (a FPEAlgorithm) String() string {
+	switch a {
+	case FF1:
+		return "FF1"
+	case FF31:
+		return "FF3-1"
+	default:
+		return "!INVALID:" + fmt.Sprint(uint(a))
+	}
+}
+
+// EncryptFPE encrypts the numerals with the first key version.
+// Format-preserving ciphertexts cannot carry the key version.
+// Hence, like EncryptDeterministic, it does not use the latest
+// version such that key rotation does not change ciphertexts.
+func (k *Key) EncryptFPE(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte) ([]uint16, error) {
+	return k.Versions[0].Key.EncryptFPE(alg, radix, numerals, tweak)
+}
+
+// DecryptFPE decrypts numerals produced by Key.EncryptFPE
+// with the first key version.
+func (k *Key) DecryptFPE(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte) ([]uint16, error) {
+	return k.Versions[0].Key.DecryptFPE(alg, radix, numerals, tweak)
+}
+
+// EncryptFPE encrypts the numerals, each smaller than radix, with
+// the format-preserving encryption algorithm and the tweak. The
+// ciphertext consists of as many numerals, with the same radix,
+// as the plaintext.
+//
+// Format-preserving encryption is deterministic. Encrypting the
+// same numerals with the same tweak produces the same ciphertext.
+func (s SecretKey) EncryptFPE(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte) ([]uint16, error) {
+	return s.fpe(alg, radix, numerals, tweak, true)
+}
+
+// DecryptFPE decrypts the numerals, produced by EncryptFPE, with
+// the format-preserving encryption algorithm and the tweak.
+func (s SecretKey) DecryptFPE(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte) ([]uint16, error) {
+	return s.fpe(alg, radix, numerals, tweak, false)
+}
+
+func (s SecretKey) fpe(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte, encrypt bool) ([]uint16, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+	if alg != FF1 && alg != FF31 {
+		return nil, errors.New("crypto: invalid format-preserving encryption algorithm")
+	}
+	if err := validFPEInput(alg, radix, numerals, tweak); err != nil {
+		return nil, err
+	}
+
+	// The AES keys used for FPE are derived from the secret key
+	// such that they are independent from the keys used for
+	// regular encryption.
+	prf := hmac.New(sha256.New, s.key[:])
+	prf.Write([]byte("FPE " + alg.String()))
+	key := prf.Sum(make([]byte, 0, prf.Size()))
+	defer secmem.Zero(key)
+
+	if alg == FF1 {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return ff1(block, radix, numerals, tweak, encrypt), nil
+	}
+
+	// FF3-1 uses the byte-reversed key for AES.
+	slices.Reverse(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The 56 bit FF3-1 tweak is split into two 32 bit halves,
+	// as specified by NIST SP 800-38G Rev. 1.
+	var tl, tr [4]byte
+	if len(tweak) == FF31TweakSize {
+		tl = [4]byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+		tr = [4]byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+	}
+	return ff3(block, radix, numerals, tl, tr, encrypt), nil
+}
+
+// validFPEInput returns an error if the radix, numerals or tweak
+// are not valid inputs for the format-preserving encryption
+// algorithm.
+func validFPEInput(alg FPEAlgorithm, radix int, numerals []uint16, tweak []byte) error {
+	if radix < MinFPERadix || radix > MaxFPERadix {
+		return fmt.Errorf("crypto: invalid radix '%d'", radix)
+	}
+	for _, n := range numerals {
+		if int(n) >= radix {
+			return fmt.Errorf("crypto: numeral '%d' is not smaller than radix '%d'", n, radix)
+		}
+	}
+
+	// The domain, radix^len, must contain at least one million
+	// values. Otherwise, an attacker can enumerate all plaintexts.
+	minLen, domain := 2, radix*radix
+	for ; domain < 1000000; domain *= radix {
+		minLen++
+	}
+	if len(numerals) < minLen {
+		return fmt.Errorf("crypto: input must contain at least %d numerals", minLen)
+	}
+
+	switch alg {
+	case FF1:
+		if len(numerals) > MaxFF1Length {
+			return fmt.Errorf("crypto: input must not contain more than %d numerals", MaxFF1Length)
+		}
+		if len(tweak) > MaxFF1TweakSize {
+			return fmt.Errorf("crypto: tweak must not be longer than %d bytes", MaxFF1TweakSize)
+		}
+	case FF31:
+		if maxLen := ff3MaxLength(radix); len(numerals) > maxLen {
+			return fmt.Errorf("crypto: input must not contain more than %d numerals", maxLen)
+		}
+		if len(tweak) != 0 && len(tweak) != FF31TweakSize {
+			return fmt.Errorf("crypto: tweak must be %d bytes long", FF31TweakSize)
+		}
+	}
+	return nil
+}
+
+// ff3MaxLength returns the max. number of numerals
+// FF3-1 can encrypt for the given radix:
+//
+//	2 * floor(log_radix(2^96))
+func ff3MaxLength(radix int) int {
+	limit := new(big.Int).Lsh(big.NewInt(1), 96)
+	r := big.NewInt(int64(radix))
+
+	n, v := 0, big.NewInt(1)
+	for {
+		v.Mul(v, r)
+		if v.Cmp(limit) > 0 {
+			return 2 * n
+		}
+		n++
+	}
+}
+
+// ff1 implements the FF1 algorithm of NIST SP 800-38G.
+func ff1(block cipher.Block, radix int, x []uint16, tweak []byte, encrypt bool) []uint16 {
+	var (
+		n = len(x)
+		u = n / 2
+		v = n - u
+		t = len(tweak)
+	)
+	a, b := slices.Clone(x[:u]), slices.Clone(x[u:])
+
+	r := big.NewInt(int64(radix))
+	modU := new(big.Int).Exp(r, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(r, big.NewInt(int64(v)), nil)
+
+	// b is the number of bytes required to encode radix^v - 1.
+	bLen := (new(big.Int).Sub(modV, big.NewInt(1)).BitLen() + 7) / 8
+	d := 4*((bLen+3)/4) + 4
+
+	p := [aes.BlockSize]byte{1, 2, 1, byte(radix >> 16), byte(radix >> 8), byte(radix), 10, byte(u)}
+	binary.BigEndian.PutUint32(p[8:], uint32(n))
+	binary.BigEndian.PutUint32(p[12:], uint32(t))
+
+	// Q = T || [0]^((-t-b-1) mod 16) || [i] || [NUM(B)]^b
+	pad := ((-(t + bLen + 1))%aes.BlockSize + aes.BlockSize) % aes.BlockSize
+	q := make([]byte, t+pad+1+bLen)
+	copy(q, tweak)
+
+	var (
+		mac = make([]byte, aes.BlockSize)
+		s   = make([]byte, ((d+aes.BlockSize-1)/aes.BlockSize)*aes.BlockSize)
+		y   = new(big.Int)
+		c   = new(big.Int)
+	)
+	for j := 0; j < 10; j++ {
+		i := j
+		if !encrypt {
+			i = 9 - j
+		}
+		q[t+pad] = byte(i)
+		if encrypt {
+			num(b, r).FillBytes(q[t+pad+1:])
+		} else {
+			num(a, r).FillBytes(q[t+pad+1:])
+		}
+
+		// R = PRF(P || Q), the CBC-MAC with a zero IV
+		clear(mac)
+		cbcMAC(block, mac, p[:])
+		cbcMAC(block, mac, q)
+
+		// S = R || CIPH(R ⊕ [1]^16) || CIPH(R ⊕ [2]^16) ...
+		copy(s, mac)
+		for k := 1; k*aes.BlockSize < d; k++ {
+			blk := s[k*aes.BlockSize : (k+1)*aes.BlockSize]
+			copy(blk, mac)
+			var ctr [aes.BlockSize]byte
+			binary.BigEndian.PutUint64(ctr[8:], uint64(k))
+			subtleXOR(blk, ctr[:])
+			block.Encrypt(blk, blk)
+		}
+		y.SetBytes(s[:d])
+
+		m, mod := u, modU
+		if i%2 == 1 {
+			m, mod = v, modV
+		}
+		if encrypt {
+			c.Add(num(a, r), y)
+			c.Mod(c, mod)
+			a, b = b, str(c, r, m)
+		} else {
+			c.Sub(num(b, r), y)
+			c.Mod(c, mod)
+			a, b = str(c, r, m), a
+		}
+	}
+	return append(a, b...)
+}
+
+// ff3 implements the FF3-1 algorithm of NIST SP 800-38G Rev. 1.
+// The block cipher must use the byte-reversed key.
+func ff3(block cipher.Block, radix int, x []uint16, tl, tr [4]byte, encrypt bool) []uint16 {
+	var (
+		n = len(x)
+		u = (n + 1) / 2
+		v = n - u
+	)
+	a, b := slices.Clone(x[:u]), slices.Clone(x[u:])
+
+	r := big.NewInt(int64(radix))
+	modU := new(big.Int).Exp(r, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(r, big.NewInt(int64(v)), nil)
+
+	var (
+		p [aes.BlockSize]byte
+		y = new(big.Int)
+		c = new(big.Int)
+	)
+	for j := 0; j < 8; j++ {
+		i := j
+		if !encrypt {
+			i = 7 - j
+		}
+		m, mod, w := u, modU, tr
+		if i%2 == 1 {
+			m, mod, w = v, modV, tl
+		}
+
+		// P = W ⊕ [i]^4 || [NUM(REV(B))]^12
+		copy(p[:4], w[:])
+		p[3] ^= byte(i)
+		if encrypt {
+			numRev(b, r).FillBytes(p[4:])
+		} else {
+			numRev(a, r).FillBytes(p[4:])
+		}
+
+		// S = REVB(CIPH(REVB(P)))
+		slices.Reverse(p[:])
+		block.Encrypt(p[:], p[:])
+		slices.Reverse(p[:])
+		y.SetBytes(p[:])
+
+		if encrypt {
+			c.Add(numRev(a, r), y)
+			c.Mod(c, mod)
+			a, b = b, strRev(c, r, m)
+		} else {
+			c.Sub(numRev(b, r), y)
+			c.Mod(c, mod)
+			a, b = strRev(c, r, m), a
+		}
+	}
+	return append(a, b...)
+}
+
+// cbcMAC updates the CBC-MAC state with data.
+// The data length must be a multiple of the block size.
+func cbcMAC(block cipher.Block, state, data []byte) {
+	for len(data) > 0 {
+		subtleXOR(state, data[:aes.BlockSize])
+		block.Encrypt(state, state)
+		data = data[aes.BlockSize:]
+	}
+}
+
+// subtleXOR sets dst to dst ⊕ src.
+func subtleXOR(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// num returns the number represented by the numerals with
+// the most significant numeral first.
+func num(x []uint16, radix *big.Int) *big.Int {
+	n, d := new(big.Int), new(big.Int)
+	for _, v := range x {
+		n.Mul(n, radix)
+		n.Add(n, d.SetUint64(uint64(v)))
+	}
+	return n
+}
+
+// numRev returns the number represented by the numerals with
+// the least significant numeral first.
+func numRev(x []uint16, radix *big.Int) *big.Int {
+	n, d := new(big.Int), new(big.Int)
+	for i := len(x) - 1; i >= 0; i-- {
+		n.Mul(n, radix)
+		n.Add(n, d.SetUint64(uint64(x[i])))
+	}
+	return n
+}
+
+// str returns the m numerals representing x with the
+// most significant numeral first.
+func str(x, radix *big.Int, m int) []uint16 {
+	x = new(big.Int).Set(x)
+	s, d := make([]uint16, m), new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		x.DivMod(x, radix, d)
+		s[i] = uint16(d.Uint64())
+	}
+	return s
+}
+
+// strRev returns the m numerals representing x with the
+// least significant numeral first.
+func strRev(x, radix *big.Int, m int) []uint16 {
+	s := str(x, radix, m)
+	slices.Reverse(s)
+	return s
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFF1(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range ff1Tests {
+		block, err := aes.NewCipher(mustDecodeHex(test.Key))
+		if err != nil {
+			t.Fatalf("Test %d: failed to create AES cipher: %v", i, err)
+		}
+
+		plaintext := fpeNumerals(test.Plaintext)
+		ciphertext := ff1(block, test.Radix, plaintext, mustDecodeHex(test.Tweak), true)
+		if c := fpeString(ciphertext); c != test.Ciphertext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, c, test.Ciphertext)
+		}
+		if p := fpeString(ff1(block, test.Radix, ciphertext, mustDecodeHex(test.Tweak), false)); p != test.Plaintext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, p, test.Plaintext)
+		}
+	}
+}
+
+func TestFF3(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range ff3Tests {
+		key := mustDecodeHex(test.Key)
+		slices.Reverse(key)
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create AES cipher: %v", i, err)
+		}
+
+		tweak := mustDecodeHex(test.Tweak)
+		tl, tr := [4]byte(tweak[:4]), [4]byte(tweak[4:])
+
+		plaintext := fpeNumerals(test.Plaintext)
+		ciphertext := ff3(block, test.Radix, plaintext, tl, tr, true)
+		if c := fpeString(ciphertext); c != test.Ciphertext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, c, test.Ciphertext)
+		}
+		if p := fpeString(ff3(block, test.Radix, ciphertext, tl, tr, false)); p != test.Plaintext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, p, test.Plaintext)
+		}
+	}
+}
+
+func TestSecretKeyFPE(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	for i, test := range secretKeyFPETests {
+		plaintext := fpeNumerals(test.Plaintext)
+		ciphertext, err := key.EncryptFPE(test.Algorithm, test.Radix, plaintext, []byte(test.Tweak))
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: encryption should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to encrypt: %v", i, err)
+		}
+		if test.ShouldFail {
+			continue
+		}
+		if len(ciphertext) != len(plaintext) {
+			t.Fatalf("Test %d: invalid ciphertext length: got '%d' - want '%d'", i, len(ciphertext), len(plaintext))
+		}
+
+		p, err := key.DecryptFPE(test.Algorithm, test.Radix, ciphertext, []byte(test.Tweak))
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt: %v", i, err)
+		}
+		if !slices.Equal(p, plaintext) {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, fpeString(p), test.Plaintext)
+		}
+	}
+
+	signingKey, err := GenerateSecretKey(Ed25519, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = signingKey.EncryptFPE(FF1, 10, fpeNumerals("0123456789"), nil); err != ErrNoEncryption {
+		t.Fatalf("Encrypting with signing key: got '%v' - want '%v'", err, ErrNoEncryption)
+	}
+}
+
+func TestFF3MaxLength(t *testing.T) {
+	t.Parallel()
+
+	for radix, maxLen := range map[int]int{2: 192, 10: 56, 26: 40, 36: 36, 1 << 16: 12} {
+		if n := ff3MaxLength(radix); n != maxLen {
+			t.Fatalf("Radix %d: got '%d' - want '%d'", radix, n, maxLen)
+		}
+	}
+}
+
+const fpeAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func fpeNumerals(s string) []uint16 {
+	n := make([]uint16, 0, len(s))
+	for _, r := range s {
+		n = append(n, uint16(strings.IndexRune(fpeAlphabet, r)))
+	}
+	return n
+}
+
+func fpeString(n []uint16) string {
+	var sb strings.Builder
+	for _, v := range n {
+		sb.WriteByte(fpeAlphabet[v])
+	}
+	return sb.String()
+}
+
+var secretKeyFPETests = []struct {
+	Algorithm  FPEAlgorithm
+	Radix      int
+	Plaintext  string
+	Tweak      string
+	ShouldFail bool
+}{
+	{Algorithm: FF1, Radix: 10, Plaintext: "4111111111111111"},                                       // 0
+	{Algorithm: FF1, Radix: 10, Plaintext: "4111111111111111", Tweak: "my-tweak"},                    // 1
+	{Algorithm: FF1, Radix: 36, Plaintext: "abc123xyz"},                                              // 2
+	{Algorithm: FF31, Radix: 10, Plaintext: "4111111111111111"},                                      // 3
+	{Algorithm: FF31, Radix: 10, Plaintext: "4111111111111111", Tweak: "7 bytes"},                    // 4
+	{Algorithm: FF1, Radix: 10, Plaintext: "12345", ShouldFail: true},                                // 5 - domain too small
+	{Algorithm: FF31, Radix: 10, Plaintext: "4111111111111111", Tweak: "8 bytes!", ShouldFail: true}, // 6
+	{Algorithm: FF31, Radix: 10, Plaintext: strings.Repeat("1", 57), ShouldFail: true},               // 7
+	{Algorithm: FF1, Radix: 10, Plaintext: "411111111111111a", ShouldFail: true},                     // 8 - numeral >= radix
+	{Algorithm: FF1, Radix: 1, Plaintext: "0000000000", ShouldFail: true},                            // 9
+}
+
+// NIST SP 800-38G sample vectors for FF1
+var ff1Tests = []struct {
+	Key        string
+	Radix      int
+	Plaintext  string
+	Tweak      string
+	Ciphertext string
+}{
+	{ // FF1-AES128 Sample #1
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Ciphertext: "2433477484",
+	},
+	{ // FF1-AES128 Sample #2
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Tweak:      "39383736353433323130",
+		Ciphertext: "6124200773",
+	},
+	{ // FF1-AES128 Sample #3
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      36,
+		Plaintext:  "0123456789abcdefghi",
+		Tweak:      "3737373770717273373737",
+		Ciphertext: "a9tv40mll9kdu509eum",
+	},
+	{ // FF1-AES256 Sample #7
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Ciphertext: "6657667009",
+	},
+	{ // FF1-AES256 Sample #8
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "0123456789",
+		Tweak:      "39383736353433323130",
+		Ciphertext: "1001623463",
+	},
+	{ // FF1-AES256 Sample #9
+		Key:        "2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      36,
+		Plaintext:  "0123456789abcdefghi",
+		Tweak:      "3737373770717273373737",
+		Ciphertext: "xs8a0azh2avyalyzuwd",
+	},
+}
+
+// NIST SP 800-38G sample vectors for FF3. FF3-1 only differs
+// in how the 56 bit tweak is split into its two halves.
+var ff3Tests = []struct {
+	Key        string
+	Radix      int
+	Plaintext  string
+	Tweak      string
+	Ciphertext string
+}{
+	{ // FF3-AES128 Sample #1
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "890121234567890000",
+		Tweak:      "D8E7920AFA330A73",
+		Ciphertext: "750918814058654607",
+	},
+	{ // FF3-AES128 Sample #2
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "890121234567890000",
+		Tweak:      "9A768A92F60E12D8",
+		Ciphertext: "018989839189395384",
+	},
+	{ // FF3-AES128 Sample #3
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "89012123456789000000789000000",
+		Tweak:      "D8E7920AFA330A73",
+		Ciphertext: "48598367162252569629397416226",
+	},
+	{ // FF3-AES128 Sample #4
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      10,
+		Plaintext:  "89012123456789000000789000000",
+		Tweak:      "0000000000000000",
+		Ciphertext: "34695224821734535122613701434",
+	},
+	{ // FF3-AES128 Sample #5
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		Radix:      26,
+		Plaintext:  "0123456789abcdefghi",
+		Tweak:      "9A768A92F60E12D8",
+		Ciphertext: "g2pk40i992fn20cjakb",
+	},
+	{ // FF3-AES256 Sample #11
+		Key:        "EF4359D8D580AA4F7F036D6F04FC6A942B7E151628AED2A6ABF7158809CF4F3C",
+		Radix:      10,
+		Plaintext:  "890121234567890000",
+		Tweak:      "D8E7920AFA330A73",
+		Ciphertext: "922011205562777495",
+	},
+}
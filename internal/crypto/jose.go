@@ -0,0 +1,140 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+// JOSE algorithm identifiers as specified by RFC 7518 and RFC 8037.
+const (
+	JWSEdDSA   = "EdDSA"   // Ed25519 signatures
+	JWSES256   = "ES256"   // ECDSA P-256 signatures with SHA-256
+	JWEDirect  = "dir"     // Direct encryption with a shared key
+	JWEA256GCM = "A256GCM" // AES-256-GCM content encryption
+)
+
+// jweIVSize is the size of the AES-GCM IV of a JWE.
+const jweIVSize = 12
+
+// JWSAlgorithm returns the JWS algorithm of the SecretKey.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func (s SecretKey) JWSAlgorithm() (string, error) {
+	switch s.cipher {
+	case Ed25519:
+		return JWSEdDSA, nil
+	case ECDSAP256:
+		return JWSES256, nil
+	default:
+		return "", ErrNoSigning
+	}
+}
+
+// SignJWS returns the JWS signature of the signing input, i.e.
+// the encoded header and payload of a JWS in compact serialization.
+// Unlike Sign, ECDSA signatures are not ASN.1 encoded but consist
+// of the 32 byte R and S values, as required by RFC 7518.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func (s SecretKey) SignJWS(signingInput []byte) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key")
+	}
+
+	switch s.cipher {
+	case Ed25519:
+		return ed25519.Sign(ed25519.NewKeyFromSeed(s.key[:]), signingInput), nil
+	case ECDSAP256:
+		digest := sha256.Sum256(signingInput)
+		r, rs, err := ecdsa.Sign(rand.Reader, s.ecdsaKey(), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		rs.FillBytes(signature[32:])
+		return signature, nil
+	default:
+		return nil, ErrNoSigning
+	}
+}
+
+// EncryptJWE encrypts the plaintext with AES-256-GCM and
+// authenticates the protected header, i.e. the encoded JWE
+// header, as specified by RFC 7516 for the "dir" algorithm.
+// It returns the IV, the ciphertext and the authentication tag.
+//
+// The AES key is derived from the SecretKey. Hence, only the
+// KES server can decrypt the JWE.
+func (s SecretKey) EncryptJWE(protected string, plaintext []byte) (iv, ciphertext, tag []byte, err error) {
+	aead, err := s.jweAEAD()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	iv = make([]byte, jweIVSize)
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, nil, nil, err
+	}
+	sealed := aead.Seal(nil, iv, plaintext, []byte(protected))
+	n := len(sealed) - aead.Overhead()
+	return iv, sealed[:n], sealed[n:], nil
+}
+
+// DecryptJWE decrypts a ciphertext produced by EncryptJWE and
+// verifies the protected header. It returns kes.ErrDecrypt if
+// the ciphertext or header has been modified.
+func (s SecretKey) DecryptJWE(protected string, iv, ciphertext, tag []byte) ([]byte, error) {
+	aead, err := s.jweAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != jweIVSize || len(tag) != aead.Overhead() {
+		return nil, kes.ErrDecrypt
+	}
+
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	sealed = append(sealed, tag...)
+	plaintext, err := aead.Open(sealed[:0], iv, sealed, []byte(protected))
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// jweAEAD returns the AES-256-GCM instance used for JWEs.
+// Its key is derived from the SecretKey such that it is
+// independent from the keys used for regular encryption.
+func (s SecretKey) jweAEAD() (cipher.AEAD, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key")
+	}
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+
+	prf := hmac.New(sha256.New, s.key[:])
+	prf.Write([]byte("JWE " + JWEA256GCM))
+	key := prf.Sum(make([]byte, 0, prf.Size()))
+	defer secmem.Zero(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
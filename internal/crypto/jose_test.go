@@ -0,0 +1,111 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestSecretKeySignJWS(t *testing.T) {
+	t.Parallel()
+
+	signingInput := []byte("eyJhbGciOiJFUzI1NiJ9.eyJzdWIiOiJtaW5pbyJ9")
+	for i, cipher := range []SecretKeyType{Ed25519, ECDSAP256} {
+		key, err := GenerateSecretKey(cipher, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to generate key: %v", i, err)
+		}
+		signature, err := key.SignJWS(signingInput)
+		if err != nil {
+			t.Fatalf("Test %d: failed to sign: %v", i, err)
+		}
+
+		der, err := key.PublicKey()
+		if err != nil {
+			t.Fatalf("Test %d: failed to get public key: %v", i, err)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			t.Fatalf("Test %d: failed to parse public key: %v", i, err)
+		}
+
+		switch publicKey := publicKey.(type) {
+		case ed25519.PublicKey:
+			if !ed25519.Verify(publicKey, signingInput, signature) {
+				t.Fatalf("Test %d: invalid EdDSA signature", i)
+			}
+		case *ecdsa.PublicKey:
+			if len(signature) != 64 {
+				t.Fatalf("Test %d: invalid ES256 signature size: got '%d' - want '%d'", i, len(signature), 64)
+			}
+			digest := sha256.Sum256(signingInput)
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+			if !ecdsa.Verify(publicKey, digest[:], r, s) {
+				t.Fatalf("Test %d: invalid ES256 signature", i)
+			}
+		default:
+			t.Fatalf("Test %d: unexpected public key type '%T'", i, publicKey)
+		}
+	}
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = key.SignJWS(signingInput); !errors.Is(err, ErrNoSigning) {
+		t.Fatalf("Signing with non-signing key: got '%v' - want '%v'", err, ErrNoSigning)
+	}
+}
+
+func TestSecretKeyEncryptJWE(t *testing.T) {
+	t.Parallel()
+
+	const Protected = "eyJhbGciOiJkaXIiLCJlbmMiOiJBMjU2R0NNIn0"
+	plaintext := []byte("Hello World")
+	for i, cipher := range []SecretKeyType{AES256, ChaCha20} {
+		key, err := GenerateSecretKey(cipher, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to generate key: %v", i, err)
+		}
+		iv, ciphertext, tag, err := key.EncryptJWE(Protected, plaintext)
+		if err != nil {
+			t.Fatalf("Test %d: failed to encrypt: %v", i, err)
+		}
+		if len(iv) != 12 || len(tag) != 16 || len(ciphertext) != len(plaintext) {
+			t.Fatalf("Test %d: invalid JWE sizes: iv '%d', ciphertext '%d', tag '%d'", i, len(iv), len(ciphertext), len(tag))
+		}
+
+		decrypted, err := key.DecryptJWE(Protected, iv, ciphertext, tag)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt: %v", i, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, decrypted, plaintext)
+		}
+		if _, err = key.DecryptJWE(Protected+"x", iv, ciphertext, tag); !errors.Is(err, kes.ErrDecrypt) {
+			t.Fatalf("Test %d: decrypting with modified header: got '%v' - want '%v'", i, err, kes.ErrDecrypt)
+		}
+		if _, err = key.DecryptJWE(Protected, iv, ciphertext, tag[:8]); !errors.Is(err, kes.ErrDecrypt) {
+			t.Fatalf("Test %d: decrypting with truncated tag: got '%v' - want '%v'", i, err, kes.ErrDecrypt)
+		}
+	}
+
+	key, err := GenerateSecretKey(Ed25519, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, _, _, err = key.EncryptJWE(Protected, plaintext); !errors.Is(err, ErrNoEncryption) {
+		t.Fatalf("Encrypting with signing key: got '%v' - want '%v'", err, ErrNoEncryption)
+	}
+}
@@ -5,16 +5,19 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	gohash "hash"
 	"io"
 	"slices"
 	"strconv"
@@ -22,6 +25,7 @@ import (
 
 	"github.com/minio/kes/internal/fips"
 	pb "github.com/minio/kes/internal/protobuf"
+	"github.com/minio/kes/internal/secmem"
 	"github.com/minio/kms-go/kes"
 	"golang.org/x/crypto/chacha20"
 	"golang.org/x/crypto/chacha20poly1305"
@@ -42,8 +46,68 @@ const (
 
 	// ChaCha20 represents the ChaCha20-Poly1305 secret key type.
 	ChaCha20
+
+	// AES256SIV represents the AES-256-SIV secret key type. AES-SIV
+	// is nonce-misuse resistant but not approved by FIPS 140-3.
+	AES256SIV
+
+	// Ed25519 represents an Ed25519 private key used for signing.
+	// The secret key is the 32 byte private key seed.
+	Ed25519
+
+	// ECDSAP256 represents an ECDSA private key on the NIST P-256
+	// curve used for signing. The secret key is the 32 byte private
+	// scalar.
+	ECDSAP256
+
+	// X25519 represents an X25519 private key used for unwrapping
+	// data encrypted to the corresponding public key using ECIES.
+	X25519
+
+	// ECIESP256 represents an ECDH private key on the NIST P-256
+	// curve used for unwrapping data encrypted to the corresponding
+	// public key using ECIES.
+	ECIESP256
 )
 
+// IsSigning reports whether the secret key type is an asymmetric
+// signing key type. Signing keys cannot be used for encryption.
+func (s SecretKeyType) IsSigning() bool { return s == Ed25519 || s == ECDSAP256 }
+
+// IsWrapping reports whether the secret key type is an asymmetric
+// key wrapping type. Wrapping keys cannot be used for symmetric
+// encryption.
+func (s SecretKeyType) IsWrapping() bool { return s == X25519 || s == ECIESP256 }
+
+// FIPSApproved reports whether the secret key type only uses
+// algorithms approved by FIPS 140-3. ChaCha20-Poly1305, AES-SIV
+// and X25519 are not approved. ECIES on P-256 combines ECDH with
+// HKDF and AES-GCM, which are all approved.
+func (s SecretKeyType) FIPSApproved() bool {
+	switch s {
+	case AES256, Ed25519, ECDSAP256, ECIESP256:
+		return true
+	default:
+		return false
+	}
+}
+
+// valid reports whether s is a known secret key type.
+func (s SecretKeyType) valid() bool { return s >= AES256 && s <= ECIESP256 }
+
+// ParseHash parses s as Hash string representation and returns
+// an error if s is not a valid representation.
+func ParseHash(s string) (Hash, error) {
+	switch s {
+	case "SHA256", "HMAC-SHA256":
+		return SHA256, nil
+	case "SHA512", "HMAC-SHA512":
+		return SHA512, nil
+	default:
+		return 0, fmt.Errorf("crypto: hash function '%s' is not supported", s)
+	}
+}
+
 // ParseSecretKeyType parse s as SecretKeyType string representation
 // and returns an error if s is not a valid representation.
 func ParseSecretKeyType(s string) (SecretKeyType, error) {
@@ -52,6 +116,16 @@ func ParseSecretKeyType(s string) (SecretKeyType, error) {
 		return AES256, nil
 	case "ChaCha20", "XCHACHA20-POLY1305":
 		return ChaCha20, nil
+	case "AES256-SIV":
+		return AES256SIV, nil
+	case "Ed25519":
+		return Ed25519, nil
+	case "ECDSA-P256":
+		return ECDSAP256, nil
+	case "ECIES-X25519":
+		return X25519, nil
+	case "ECIES-P256":
+		return ECIESP256, nil
 	default:
 		return 0, fmt.Errorf("crypto: secret key type '%s' is not supported", s)
 	}
@@ -64,6 +138,16 @@ func (s SecretKeyType) String() string {
 		return "AES256"
 	case ChaCha20:
 		return "ChaCha20"
+	case AES256SIV:
+		return "AES256-SIV"
+	case Ed25519:
+		return "Ed25519"
+	case ECDSAP256:
+		return "ECDSA-P256"
+	case X25519:
+		return "ECIES-X25519"
+	case ECIESP256:
+		return "ECIES-P256"
 	default:
 		return "!INVALID:" + strconv.Itoa(int(s))
 	}
@@ -73,6 +157,9 @@ func (s SecretKeyType) String() string {
 const (
 	// SHA256 represents the SHA-256 hash function.
 	SHA256 Hash = iota + 1
+
+	// SHA512 represents the SHA-512 hash function.
+	SHA512
 )
 
 // Hash identifies a cryptographic hash function.
@@ -83,6 +170,8 @@ func (h Hash) String() string {
 	switch h {
 	case SHA256:
 		return "SHA256"
+	case SHA512:
+		return "SHA512"
 	default:
 		return "!INVALID:" + strconv.Itoa(int(h))
 	}
@@ -97,6 +186,7 @@ func EncodeKeyVersion(key KeyVersion) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer secmem.Zero(proto)
 
 	b := make([]byte, base64.StdEncoding.EncodedLen(len(proto)))
 	base64.StdEncoding.Encode(b, proto)
@@ -139,10 +229,11 @@ func ParseKeyVersion(b []byte) (KeyVersion, error) {
 		}, nil
 	}
 
-	raw, err := base64.StdEncoding.DecodeString(string(b))
+	raw, err := decodeBase64(b)
 	if err != nil {
 		return KeyVersion{}, err
 	}
+	defer secmem.Zero(raw)
 
 	var key KeyVersion
 	if err := pb.Unmarshal(raw, &key); err != nil {
@@ -151,6 +242,216 @@ func ParseKeyVersion(b []byte) (KeyVersion, error) {
 	return key, nil
 }
 
+// keyPrefix is the prefix of encoded keys that contain more than
+// a single key version. It allows distinguishing versioned keys
+// from (legacy) encoded key versions.
+const keyPrefix = "v2:"
+
+// EncodeKey returns the base64-encoded binary representation of
+// a key.
+//
+// Keys with a single version are encoded as KeyVersion such that
+// servers not aware of key versions can still parse them.
+func EncodeKey(key Key) ([]byte, error) {
+	if len(key.Versions) == 0 {
+		return nil, errors.New("crypto: key contains no key version")
+	}
+	if len(key.Versions) == 1 {
+		return EncodeKeyVersion(key.Versions[0])
+	}
+
+	proto, err := pb.Marshal(&key)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Zero(proto)
+
+	b := make([]byte, len(keyPrefix)+base64.StdEncoding.EncodedLen(len(proto)))
+	copy(b, keyPrefix)
+	base64.StdEncoding.Encode(b[len(keyPrefix):], proto)
+	return b, nil
+}
+
+// ParseKey parses b as Key. It accepts encoded keys as well as
+// encoded key versions.
+func ParseKey(b []byte) (Key, error) {
+	if !bytes.HasPrefix(b, []byte(keyPrefix)) {
+		version, err := ParseKeyVersion(b)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Versions: []KeyVersion{version}}, nil
+	}
+
+	raw, err := decodeBase64(b[len(keyPrefix):])
+	if err != nil {
+		return Key{}, err
+	}
+	defer secmem.Zero(raw)
+
+	var key Key
+	if err := pb.Unmarshal(raw, &key); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// decodeBase64 decodes the base64-encoded b. Unlike
+// base64.StdEncoding.DecodeString, it does not copy b into
+// a string that cannot be zeroed.
+func decodeBase64(b []byte) ([]byte, error) {
+	raw := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+	n, err := base64.StdEncoding.Decode(raw, b)
+	if err != nil {
+		secmem.Zero(raw)
+		return nil, err
+	}
+	return raw[:n], nil
+}
+
+// Key is a versioned secret key. New key versions are added
+// when the key gets rotated.
+//
+// The latest version is used to encrypt new data while any
+// version can be used to decrypt existing ciphertexts.
+type Key struct {
+	Versions []KeyVersion // Key versions, sorted from oldest to latest
+}
+
+// Latest returns the latest key version.
+func (k *Key) Latest() KeyVersion { return k.Versions[len(k.Versions)-1] }
+
+// Rotate returns a new Key with an additional version using
+// the latest version's cipher.
+//
+// The new version keeps the latest version's HMAC key, if any,
+// since HMAC checksums do not refer to a particular key version
+// and must remain verifiable after a rotation.
+//
+// If random is nil the standard library crypto/rand.Reader is used.
+func (k *Key) Rotate(random io.Reader, createdBy kes.Identity) (Key, error) {
+	latest := k.Latest()
+	key, err := GenerateSecretKey(latest.Key.Type(), random)
+	if err != nil {
+		return Key{}, err
+	}
+	hmac := latest.HMACKey
+	if !latest.HasHMACKey() {
+		if hmac, err = GenerateHMACKey(SHA256, random); err != nil {
+			return Key{}, err
+		}
+	}
+
+	versions := make([]KeyVersion, 0, len(k.Versions)+1)
+	versions = append(versions, k.Versions...)
+	versions = append(versions, KeyVersion{
+		Key:       key,
+		HMACKey:   hmac,
+		CreatedAt: time.Now().UTC(),
+		CreatedBy: createdBy,
+	})
+	return Key{Versions: versions}, nil
+}
+
+// Decrypt decrypts and authenticates the ciphertext and
+// authenticates the associatedData using the latest key
+// version first and then all previous versions.
+//
+// It returns kes.ErrDecrypt if no key version can decrypt
+// the ciphertext.
+func (k *Key) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	for i := len(k.Versions) - 1; i >= 0; i-- {
+		// Decryption happens in-place and AEAD implementations
+		// clear the plaintext buffer on authentication failure.
+		// Hence, we must not pass the ciphertext itself unless
+		// it's the last version we try.
+		b := ciphertext
+		if i > 0 {
+			b = slices.Clone(ciphertext)
+		}
+
+		plaintext, err := k.Versions[i].Key.Decrypt(b, associatedData)
+		if err == nil {
+			return plaintext, nil
+		}
+		if !errors.Is(err, kes.ErrDecrypt) {
+			return nil, err
+		}
+	}
+	return nil, kes.ErrDecrypt
+}
+
+// EncryptDeterministic encrypts the plaintext deterministically
+// with the first key version. Unlike Encrypt, it does not use the
+// latest version such that equal plaintexts keep producing equal
+// ciphertexts after the key has been rotated.
+func (k *Key) EncryptDeterministic(plaintext, associatedData []byte) ([]byte, error) {
+	return k.Versions[0].Key.EncryptDeterministic(plaintext, associatedData)
+}
+
+// DecryptDeterministic decrypts a ciphertext produced by
+// Key.EncryptDeterministic. It tries all key versions,
+// starting with the latest.
+func (k *Key) DecryptDeterministic(ciphertext, associatedData []byte) ([]byte, error) {
+	for i := len(k.Versions) - 1; i >= 0; i-- {
+		plaintext, err := k.Versions[i].Key.DecryptDeterministic(ciphertext, associatedData)
+		if err == nil {
+			return plaintext, nil
+		}
+		if !errors.Is(err, kes.ErrDecrypt) {
+			return nil, err
+		}
+	}
+	return nil, kes.ErrDecrypt
+}
+
+// Unwrap decrypts a ciphertext wrapped for the public key of
+// one of the key versions. It tries all key versions, starting
+// with the latest.
+func (k *Key) Unwrap(ciphertext, associatedData []byte) ([]byte, error) {
+	for i := len(k.Versions) - 1; i >= 0; i-- {
+		plaintext, err := k.Versions[i].Key.Unwrap(ciphertext, associatedData)
+		if err == nil {
+			return plaintext, nil
+		}
+		if !errors.Is(err, kes.ErrDecrypt) {
+			return nil, err
+		}
+	}
+	return nil, kes.ErrDecrypt
+}
+
+// MarshalPB converts the Key into its protobuf representation.
+func (k *Key) MarshalPB(v *pb.Key) error {
+	v.Versions = make([]*pb.KeyVersion, 0, len(k.Versions))
+	for i := range k.Versions {
+		version := &pb.KeyVersion{}
+		if err := k.Versions[i].MarshalPB(version); err != nil {
+			return err
+		}
+		v.Versions = append(v.Versions, version)
+	}
+	return nil
+}
+
+// UnmarshalPB initializes the Key from its protobuf representation.
+func (k *Key) UnmarshalPB(v *pb.Key) error {
+	if len(v.Versions) == 0 {
+		return errors.New("crypto: key contains no key version")
+	}
+
+	versions := make([]KeyVersion, 0, len(v.Versions))
+	for _, version := range v.Versions {
+		var kv KeyVersion
+		if err := kv.UnmarshalPB(version); err != nil {
+			return err
+		}
+		versions = append(versions, kv)
+	}
+	k.Versions = versions
+	return nil
+}
+
 // KeyVersion represents a version of a secret key.
 type KeyVersion struct {
 	Key       SecretKey    // The secret key
@@ -208,6 +509,9 @@ func NewSecretKey(cipher SecretKeyType, key []byte) (SecretKey, error) {
 	if n := len(key); n != SecretKeySize {
 		return SecretKey{}, fmt.Errorf("crypto: invalid key length '%d' for '%s'", n, cipher)
 	}
+	if (cipher == ECDSAP256 || cipher == ECIESP256) && !validP256Scalar(key) {
+		return SecretKey{}, errors.New("crypto: invalid P-256 private key")
+	}
 
 	return SecretKey{
 		cipher:      cipher,
@@ -226,8 +530,16 @@ func GenerateSecretKey(cipher SecretKeyType, random io.Reader) (SecretKey, error
 	}
 
 	var bytes [SecretKeySize]byte
-	if _, err := io.ReadFull(random, bytes[:]); err != nil {
-		return SecretKey{}, err
+	for {
+		if _, err := io.ReadFull(random, bytes[:]); err != nil {
+			return SecretKey{}, err
+		}
+		// Not every 32 byte value is a valid P-256 private
+		// scalar. Hence, we retry in the unlikely case that
+		// we generated an invalid one.
+		if (cipher != ECDSAP256 && cipher != ECIESP256) || validP256Scalar(bytes[:]) {
+			break
+		}
 	}
 	return NewSecretKey(cipher, bytes[:])
 }
@@ -263,7 +575,10 @@ func (s SecretKey) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
 	if !s.initialized {
 		panic("crypto: usage of empty or uninitialized secret key")
 	}
-	if fips.Enabled {
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+	if fips.Enabled() {
 		if s.cipher != AES256 {
 			return nil, errors.New("crypto: cipher not available in FIPS mode")
 		}
@@ -273,6 +588,16 @@ func (s SecretKey) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
 	if _, err := rand.Read(random[:]); err != nil {
 		return nil, err
 	}
+	if s.cipher == AES256SIV {
+		siv, err := s.siv(sivRandomized)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext := make([]byte, 0, len(plaintext)+s.Overhead())
+		ciphertext = siv.Seal(ciphertext, plaintext, associatedData, random[:])
+		ciphertext = append(ciphertext, random[:]...)
+		return ciphertext, nil
+	}
 	iv, nonce := random[:16], random[16:]
 
 	var aead cipher.AEAD
@@ -283,6 +608,7 @@ func (s SecretKey) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
 		key := prf.Sum(make([]byte, 0, prf.Size()))
 
 		block, err := aes.NewCipher(key)
+		secmem.Zero(key)
 		if err != nil {
 			return nil, err
 		}
@@ -296,6 +622,7 @@ func (s SecretKey) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
 			return nil, err
 		}
 		c, err := chacha20poly1305.New(key)
+		secmem.Zero(key)
 		if err != nil {
 			return nil, err
 		}
@@ -319,7 +646,10 @@ func (s SecretKey) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
 	if !s.initialized {
 		panic("crypto: usage of empty or uninitialized secret key detected")
 	}
-	if fips.Enabled {
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+	if fips.Enabled() {
 		if s.cipher != AES256 {
 			return nil, errors.New("crypto: cipher not available in FIPS mode")
 		}
@@ -330,6 +660,13 @@ func (s SecretKey) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
 		return nil, kes.ErrDecrypt
 	}
 	ciphertext, random := ciphertext[:len(ciphertext)-randSize], ciphertext[len(ciphertext)-randSize:]
+	if s.cipher == AES256SIV {
+		siv, err := s.siv(sivRandomized)
+		if err != nil {
+			return nil, err
+		}
+		return siv.Open(nil, ciphertext, associatedData, random)
+	}
 	iv, nonce := random[:16], random[16:]
 
 	var aead cipher.AEAD
@@ -340,6 +677,7 @@ func (s SecretKey) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
 		key := prf.Sum(make([]byte, 0, prf.Size()))
 
 		block, err := aes.NewCipher(key)
+		secmem.Zero(key)
 		if err != nil {
 			return nil, err
 		}
@@ -353,6 +691,7 @@ func (s SecretKey) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
 			return nil, err
 		}
 		c, err := chacha20poly1305.New(key)
+		secmem.Zero(key)
 		if err != nil {
 			return nil, err
 		}
@@ -368,12 +707,85 @@ func (s SecretKey) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// EncryptDeterministic encrypts and authenticates the plaintext
+// and authenticates the associatedData using AES-SIV without a
+// random nonce. Encrypting the same plaintext and associatedData
+// twice produces the same ciphertext.
+//
+// Deterministic ciphertexts reveal whether two plaintexts are
+// equal. Hence, it should only be used when equality checks on
+// ciphertexts are required, for example for indexing.
+//
+// It supports all secret key types but is not available in
+// FIPS mode.
+func (s SecretKey) EncryptDeterministic(plaintext, associatedData []byte) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key")
+	}
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+	if fips.Enabled() {
+		return nil, errors.New("crypto: deterministic encryption not available in FIPS mode")
+	}
+
+	siv, err := s.siv(sivDeterministic)
+	if err != nil {
+		return nil, err
+	}
+	return siv.Seal(make([]byte, 0, sivTagSize+len(plaintext)), plaintext, associatedData), nil
+}
+
+// DecryptDeterministic decrypts and authenticates a ciphertext
+// produced by EncryptDeterministic and authenticates the
+// associatedData.
+func (s SecretKey) DecryptDeterministic(ciphertext, associatedData []byte) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+	if s.cipher.IsSigning() || s.cipher.IsWrapping() {
+		return nil, ErrNoEncryption
+	}
+	if fips.Enabled() {
+		return nil, errors.New("crypto: deterministic encryption not available in FIPS mode")
+	}
+
+	siv, err := s.siv(sivDeterministic)
+	if err != nil {
+		return nil, err
+	}
+	return siv.Open(nil, ciphertext, associatedData)
+}
+
+// Domain separation labels for deriving AES-SIV keys.
+const (
+	sivRandomized    = "AES-SIV"
+	sivDeterministic = "AES-SIV deterministic"
+)
+
+// siv returns an AES-SIV instance for the SecretKey. The
+// S2V and CTR keys are derived from the secret key and the
+// domain using HMAC-SHA256 such that they are independent.
+func (s SecretKey) siv(domain string) (*siv, error) {
+	prf := hmac.New(sha256.New, s.key[:])
+	prf.Write([]byte(domain + " S2V"))
+	macKey := prf.Sum(make([]byte, 0, prf.Size()))
+
+	prf.Reset()
+	prf.Write([]byte(domain + " CTR"))
+	ctrKey := prf.Sum(make([]byte, 0, prf.Size()))
+	defer secmem.Zero(macKey)
+	defer secmem.Zero(ctrKey)
+
+	return newSIV(macKey, ctrKey)
+}
+
 // MarshalPB converts the SecretKey into its protobuf representation.
 func (s *SecretKey) MarshalPB(v *pb.SecretKey) error {
 	if !s.initialized {
 		return errors.New("crypto: secret key is not initialized")
 	}
-	if s.cipher != AES256 && s.cipher != ChaCha20 {
+	if !s.cipher.valid() {
 		return errors.New("crypto: invalid secret key type '" + strconv.Itoa(int(s.cipher)) + "'")
 	}
 
@@ -387,7 +799,7 @@ func (s *SecretKey) UnmarshalPB(v *pb.SecretKey) error {
 	if n := len(v.Key); n != SecretKeySize {
 		return errors.New("crypto: invalid secret key length '" + strconv.Itoa(n) + "'")
 	}
-	if t := SecretKeyType(v.Type); t != AES256 && t != ChaCha20 {
+	if t := SecretKeyType(v.Type); !t.valid() {
 		return errors.New("crypto: invalid secret key type '" + strconv.Itoa(int(s.cipher)) + "'")
 	}
 
@@ -454,6 +866,26 @@ func (k *HMACKey) Sum(msg []byte) []byte {
 	}
 }
 
+// SumWith computes and returns the HMAC checksum of msg using
+// the given hash function instead of the HMACKey's hash function.
+func (k *HMACKey) SumWith(hash Hash, msg []byte) ([]byte, error) {
+	if !k.initialized {
+		panic("crypto: usage of empty or uninitialized HMAC key detected")
+	}
+
+	var mac gohash.Hash
+	switch hash {
+	case SHA256:
+		mac = hmac.New(sha256.New, k.key[:])
+	case SHA512:
+		mac = hmac.New(sha512.New, k.key[:])
+	default:
+		return nil, fmt.Errorf("crypto: hash function '%s' is not supported", hash)
+	}
+	mac.Write(msg)
+	return mac.Sum(make([]byte, 0, mac.Size())), nil
+}
+
 // Equal reports whether mac1 and mac2 are equal without
 // leaking any timing information.
 func (k *HMACKey) Equal(mac1, mac2 []byte) bool {
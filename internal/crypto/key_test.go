@@ -5,9 +5,14 @@
 package crypto
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/minio/kms-go/kes"
 )
 
 func TestEncodeKeyVersion(t *testing.T) {
@@ -35,6 +40,69 @@ func TestEncodeKeyVersion(t *testing.T) {
 	}
 }
 
+func TestEncodeKey(t *testing.T) {
+	t.Parallel()
+
+	key := Key{Versions: []KeyVersion{encodeSecretKeyVersionTests[0].Key}}
+	for i := 0; i < 3; i++ {
+		b, err := EncodeKey(key)
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode key: %v", i, err)
+		}
+		k, err := ParseKey(b)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decode encoded key: %v", i, err)
+		}
+		if !slices.Equal(k.Versions, key.Versions) {
+			t.Fatalf("Test %d: got '%+v' - want '%+v'", i, k, key)
+		}
+		if len(key.Versions) == 1 {
+			if _, err = ParseKeyVersion(b); err != nil {
+				t.Fatalf("Test %d: failed to decode single-version key as key version: %v", i, err)
+			}
+		}
+
+		if key, err = key.Rotate(nil, "my-identity"); err != nil {
+			t.Fatalf("Test %d: failed to rotate key: %v", i, err)
+		}
+	}
+}
+
+func TestKeyDecrypt(t *testing.T) {
+	t.Parallel()
+
+	plaintext := []byte("Hello World")
+	key := Key{Versions: []KeyVersion{encodeSecretKeyVersionTests[0].Key}}
+	ciphertext, err := key.Latest().Key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+
+	rotated, err := key.Rotate(nil, "my-identity")
+	if err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if rotated.Latest().HMACKey != key.Latest().HMACKey {
+		t.Fatal("Rotation changed HMAC key")
+	}
+
+	p, err := rotated.Decrypt(ciphertext, nil)
+	if err != nil {
+		t.Fatalf("Failed to decrypt ciphertext of previous key version: %v", err)
+	}
+	if !bytes.Equal(p, plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%s' - want '%s'", p, plaintext)
+	}
+
+	ciphertext, err = rotated.Latest().Key.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+	if _, err = key.Decrypt(ciphertext, nil); !errors.Is(err, kes.ErrDecrypt) {
+		t.Fatalf("Decrypting ciphertext of newer key version: got '%v' - want '%v'", err, kes.ErrDecrypt)
+	}
+}
+
 func TestSecretKeyEncrypt(t *testing.T) {
 	t.Parallel()
 
@@ -57,6 +125,41 @@ func TestSecretKeyEncrypt(t *testing.T) {
 	}
 }
 
+func TestSecretKeyEncryptDeterministic(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range secretKeyEncryptTests {
+		plaintext := mustDecodeB64(test.Plaintext)
+		associatedData := mustDecodeB64(test.AssociatedData)
+
+		c1, err := test.Key.EncryptDeterministic(plaintext, associatedData)
+		if err != nil {
+			t.Fatalf("Test %d: failed to encrypt plaintext: %v", i, err)
+		}
+		c2, err := test.Key.EncryptDeterministic(plaintext, associatedData)
+		if err != nil {
+			t.Fatalf("Test %d: failed to encrypt plaintext: %v", i, err)
+		}
+		if !bytes.Equal(c1, c2) {
+			t.Fatalf("Test %d: ciphertexts of same plaintext differ", i)
+		}
+		if c, err := test.Key.Encrypt(plaintext, associatedData); err == nil && bytes.Equal(c, c1) {
+			t.Fatalf("Test %d: deterministic and randomized ciphertexts are equal", i)
+		}
+
+		p, err := test.Key.DecryptDeterministic(c1, associatedData)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt ciphertext: %v", i, err)
+		}
+		if p := base64.StdEncoding.EncodeToString(p); p != test.Plaintext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, p, test.Plaintext)
+		}
+		if _, err = test.Key.DecryptDeterministic(c1, []byte("invalid")); !errors.Is(err, kes.ErrDecrypt) {
+			t.Fatalf("Test %d: decrypting with invalid associated data: got '%v' - want '%v'", i, err, kes.ErrDecrypt)
+		}
+	}
+}
+
 func TestSecretKeyDecrypt(t *testing.T) {
 	t.Parallel()
 
@@ -105,6 +208,27 @@ func TestParseKeyVersion(t *testing.T) {
 	}
 }
 
+func TestSecretKeyTypeFIPSApproved(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		Type     SecretKeyType
+		Approved bool
+	}{
+		{Type: AES256, Approved: true},
+		{Type: ChaCha20, Approved: false},
+		{Type: AES256SIV, Approved: false},
+		{Type: Ed25519, Approved: true},
+		{Type: ECDSAP256, Approved: true},
+		{Type: X25519, Approved: false},
+		{Type: ECIESP256, Approved: true},
+	} {
+		if approved := test.Type.FIPSApproved(); approved != test.Approved {
+			t.Fatalf("%s: got 'approved=%v' - want 'approved=%v'", test.Type, approved, test.Approved)
+		}
+	}
+}
+
 var encodeSecretKeyVersionTests = []struct {
 	Key        KeyVersion
 	ShouldFail bool
@@ -158,6 +282,14 @@ var secretKeyEncryptTests = []struct {
 		Plaintext:      "CLcJoykFCWZDkEIiUq9bJRqwCwW9ZDvdgu8EMA==",
 		AssociatedData: "AAAAAAAAAAAAAAAAAAAAAA==",
 	},
+	{ // 4
+		Key:            mustSecretKey(AES256SIV, "dDHbTWgo+Yh3u804SYB5OyVMy6RiLeJYBQth1f6KlEU="),
+		Plaintext:      "CLcJoykFCWZDkEIiUq9bJRqwCwW9ZDvdgu8EMA==",
+		AssociatedData: "AAAAAAAAAAAAAAAAAAAAAA==",
+	},
+	{ // 5
+		Key: mustSecretKey(AES256SIV, "dDHbTWgo+Yh3u804SYB5OyVMy6RiLeJYBQth1f6KlEU="),
+	},
 }
 
 var secretKeyDecryptTests = []struct {
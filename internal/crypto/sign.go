@@ -0,0 +1,131 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"net/http"
+
+	"github.com/minio/kms-go/kes"
+)
+
+var (
+	// ErrNoEncryption is returned when a signing key is used
+	// for encryption or decryption.
+	ErrNoEncryption = kes.NewError(http.StatusConflict, "key does not support encryption")
+
+	// ErrNoSigning is returned when a non-signing key is used
+	// for computing or verifying signatures.
+	ErrNoSigning = kes.NewError(http.StatusConflict, "key does not support signing")
+)
+
+// Sign returns a signature of the digest. For Ed25519 keys, the
+// digest is signed as message. For ECDSA P-256 keys, the digest
+// should be a SHA-256 hash and the signature is ASN.1 encoded.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func (s SecretKey) Sign(digest []byte) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key")
+	}
+
+	switch s.cipher {
+	case Ed25519:
+		return ed25519.Sign(ed25519.NewKeyFromSeed(s.key[:]), digest), nil
+	case ECDSAP256:
+		return ecdsa.SignASN1(rand.Reader, s.ecdsaKey(), digest)
+	default:
+		return nil, ErrNoSigning
+	}
+}
+
+// Verify reports whether signature is a valid signature of
+// the digest.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func (s SecretKey) Verify(digest, signature []byte) (bool, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+
+	switch s.cipher {
+	case Ed25519:
+		publicKey := ed25519.NewKeyFromSeed(s.key[:]).Public().(ed25519.PublicKey)
+		return ed25519.Verify(publicKey, digest, signature), nil
+	case ECDSAP256:
+		return ecdsa.VerifyASN1(&s.ecdsaKey().PublicKey, digest, signature), nil
+	default:
+		return false, ErrNoSigning
+	}
+}
+
+// PublicKey returns the PKIX, ASN.1 DER encoded public key of
+// a signing or wrapping key.
+//
+// It returns ErrNoSigning if the SecretKey is not an asymmetric key.
+func (s SecretKey) PublicKey() ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+
+	switch s.cipher {
+	case Ed25519:
+		return x509.MarshalPKIXPublicKey(ed25519.NewKeyFromSeed(s.key[:]).Public())
+	case ECDSAP256:
+		return x509.MarshalPKIXPublicKey(&s.ecdsaKey().PublicKey)
+	case X25519, ECIESP256:
+		key, err := s.ecdhKey()
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKIXPublicKey(key.PublicKey())
+	default:
+		return nil, ErrNoSigning
+	}
+}
+
+// Signer returns a crypto.Signer for the SecretKey, e.g. to sign
+// X.509 certificates. The returned Signer must not be retained
+// since it contains the private key.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func (s SecretKey) Signer() (gocrypto.Signer, error) {
+	if !s.initialized {
+		return nil, ErrNoSigning
+	}
+
+	switch s.cipher {
+	case Ed25519:
+		return ed25519.NewKeyFromSeed(s.key[:]), nil
+	case ECDSAP256:
+		return s.ecdsaKey(), nil
+	default:
+		return nil, ErrNoSigning
+	}
+}
+
+// ecdsaKey returns the ECDSA P-256 private key for the
+// SecretKey's private scalar.
+func (s SecretKey) ecdsaKey() *ecdsa.PrivateKey {
+	curve := elliptic.P256()
+	x, y := curve.ScalarBaseMult(s.key[:])
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		D:         new(big.Int).SetBytes(s.key[:]),
+	}
+}
+
+// validP256Scalar reports whether b is a valid P-256 private
+// scalar, i.e. 0 < b < N.
+func validP256Scalar(b []byte) bool {
+	d := new(big.Int).SetBytes(b)
+	return d.Sign() > 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
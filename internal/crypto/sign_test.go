@@ -0,0 +1,103 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"testing"
+)
+
+func TestSecretKeySign(t *testing.T) {
+	t.Parallel()
+
+	digest := sha256.Sum256([]byte("Hello World"))
+	for i, cipher := range []SecretKeyType{Ed25519, ECDSAP256} {
+		key, err := GenerateSecretKey(cipher, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to generate key: %v", i, err)
+		}
+
+		signature, err := key.Sign(digest[:])
+		if err != nil {
+			t.Fatalf("Test %d: failed to sign digest: %v", i, err)
+		}
+		if ok, err := key.Verify(digest[:], signature); err != nil || !ok {
+			t.Fatalf("Test %d: failed to verify signature: %v", i, err)
+		}
+		if ok, _ := key.Verify(digest[1:], signature); ok {
+			t.Fatalf("Test %d: verified signature of a different digest", i)
+		}
+
+		raw, err := key.PublicKey()
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode public key: %v", i, err)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(raw)
+		if err != nil {
+			t.Fatalf("Test %d: failed to parse public key: %v", i, err)
+		}
+		var ok bool
+		switch pub := publicKey.(type) {
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(pub, digest[:], signature)
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(pub, digest[:], signature)
+		}
+		if !ok {
+			t.Fatalf("Test %d: public key does not verify signature", i)
+		}
+
+		if _, err = key.Encrypt(digest[:], nil); !errors.Is(err, ErrNoEncryption) {
+			t.Fatalf("Test %d: encrypting with signing key: got '%v' - want '%v'", i, err, ErrNoEncryption)
+		}
+	}
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = key.Sign(digest[:]); !errors.Is(err, ErrNoSigning) {
+		t.Fatalf("Signing with encryption key: got '%v' - want '%v'", err, ErrNoSigning)
+	}
+}
+
+func TestSecretKeySigner(t *testing.T) {
+	t.Parallel()
+
+	for i, cipher := range []SecretKeyType{Ed25519, ECDSAP256} {
+		key, err := GenerateSecretKey(cipher, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to generate key: %v", i, err)
+		}
+		signer, err := key.Signer()
+		if err != nil {
+			t.Fatalf("Test %d: failed to create signer: %v", i, err)
+		}
+		raw, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode public key: %v", i, err)
+		}
+		publicKey, err := key.PublicKey()
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode public key: %v", i, err)
+		}
+		if !bytes.Equal(raw, publicKey) {
+			t.Fatalf("Test %d: signer public key does not match key's public key", i)
+		}
+	}
+
+	key, err := GenerateSecretKey(AES256, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err = key.Signer(); !errors.Is(err, ErrNoSigning) {
+		t.Fatalf("Creating signer for non-signing key: got '%v' - want '%v'", err, ErrNoSigning)
+	}
+}
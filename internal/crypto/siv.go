@@ -0,0 +1,156 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// sivTagSize is the size of the synthetic IV in bytes.
+const sivTagSize = aes.BlockSize
+
+// siv implements the AES-SIV deterministic authenticated encryption
+// scheme as specified by RFC 5297.
+//
+// AES-SIV is nonce-misuse resistant. Encrypting the same plaintext
+// with the same associated data twice produces the same ciphertext.
+// Randomized encryption can be achieved by passing a random nonce
+// as one of the associated data components.
+type siv struct {
+	mac cipher.Block // Used to compute the synthetic IV via S2V
+	ctr cipher.Block // Used to encrypt the plaintext in CTR mode
+
+	k1, k2 [aes.BlockSize]byte // CMAC subkeys
+}
+
+// newSIV returns a new AES-SIV instance using macKey for computing
+// the synthetic IV and ctrKey for encryption. Both keys must be
+// valid AES keys of the same length.
+func newSIV(macKey, ctrKey []byte) (*siv, error) {
+	mac, err := aes.NewCipher(macKey)
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(ctrKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &siv{mac: mac, ctr: ctr}
+	mac.Encrypt(s.k1[:], s.k1[:])
+	s.k1 = dbl(s.k1)
+	s.k2 = dbl(s.k1)
+	return s, nil
+}
+
+// Seal encrypts and authenticates the plaintext, authenticates the
+// additionalData and appends the result to dst. The result consists
+// of the synthetic IV followed by the encrypted plaintext.
+func (s *siv) Seal(dst, plaintext []byte, additionalData ...[]byte) []byte {
+	v := s.s2v(plaintext, additionalData...)
+
+	ret := extend(dst, sivTagSize+len(plaintext))
+	out := ret[len(dst):]
+	copy(out, v[:])
+	s.xorKeyStream(out[sivTagSize:], plaintext, v)
+	return ret
+}
+
+// Open decrypts and authenticates the ciphertext, authenticates the
+// additionalData and, if successful, appends the plaintext to dst.
+func (s *siv) Open(dst, ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(ciphertext) < sivTagSize {
+		return nil, kes.ErrDecrypt
+	}
+	var v [sivTagSize]byte
+	copy(v[:], ciphertext)
+	ciphertext = ciphertext[sivTagSize:]
+
+	ret := extend(dst, len(ciphertext))
+	out := ret[len(dst):]
+	s.xorKeyStream(out, ciphertext, v)
+
+	t := s.s2v(out, additionalData...)
+	if subtle.ConstantTimeCompare(t[:], v[:]) != 1 {
+		clear(out)
+		return nil, kes.ErrDecrypt
+	}
+	return ret, nil
+}
+
+// xorKeyStream XORs src with the CTR key stream derived from
+// the synthetic IV v and writes the result to dst.
+func (s *siv) xorKeyStream(dst, src []byte, v [sivTagSize]byte) {
+	// RFC 5297 clears the 31st and 63rd bit (counting from the right)
+	// such that implementations can use 32 or 64 bit counters.
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+	cipher.NewCTR(s.ctr, v[:]).XORKeyStream(dst, src)
+}
+
+// s2v implements the S2V pseudo-random function of RFC 5297.
+func (s *siv) s2v(plaintext []byte, additionalData ...[]byte) [aes.BlockSize]byte {
+	var d [aes.BlockSize]byte
+	d = s.cmac(d[:])
+	for _, ad := range additionalData {
+		d = dbl(d)
+		xorBlock(&d, s.cmac(ad))
+	}
+
+	if len(plaintext) >= aes.BlockSize {
+		t := make([]byte, len(plaintext))
+		copy(t, plaintext)
+		subtle.XORBytes(t[len(t)-aes.BlockSize:], t[len(t)-aes.BlockSize:], d[:])
+		return s.cmac(t)
+	}
+
+	d = dbl(d)
+	var p [aes.BlockSize]byte
+	copy(p[:], plaintext)
+	p[len(plaintext)] = 0x80
+	xorBlock(&d, p)
+	return s.cmac(d[:])
+}
+
+// cmac computes the AES-CMAC of msg as specified by RFC 4493.
+func (s *siv) cmac(msg []byte) [aes.BlockSize]byte {
+	var x [aes.BlockSize]byte
+	for len(msg) > aes.BlockSize {
+		subtle.XORBytes(x[:], x[:], msg[:aes.BlockSize])
+		s.mac.Encrypt(x[:], x[:])
+		msg = msg[aes.BlockSize:]
+	}
+
+	var last [aes.BlockSize]byte
+	copy(last[:], msg)
+	if len(msg) == aes.BlockSize {
+		xorBlock(&last, s.k1)
+	} else {
+		last[len(msg)] = 0x80
+		xorBlock(&last, s.k2)
+	}
+	xorBlock(&x, last)
+	s.mac.Encrypt(x[:], x[:])
+	return x
+}
+
+// dbl multiplies b by x in GF(2^128).
+func dbl(b [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var r [aes.BlockSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		r[i] = b[i]<<1 | b[i+1]>>7
+	}
+	r[aes.BlockSize-1] = b[aes.BlockSize-1]<<1 ^ 0x87*carry
+	return r
+}
+
+func xorBlock(dst *[aes.BlockSize]byte, src [aes.BlockSize]byte) {
+	subtle.XORBytes(dst[:], dst[:], src[:])
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSIV(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range sivTests {
+		key := mustDecodeHex(test.Key)
+		s, err := newSIV(key[:len(key)/2], key[len(key)/2:])
+		if err != nil {
+			t.Fatalf("Test %d: failed to create AES-SIV: %v", i, err)
+		}
+
+		additionalData := make([][]byte, 0, len(test.AdditionalData))
+		for _, ad := range test.AdditionalData {
+			additionalData = append(additionalData, mustDecodeHex(ad))
+		}
+		plaintext := mustDecodeHex(test.Plaintext)
+
+		ciphertext := s.Seal(nil, plaintext, additionalData...)
+		if c := hex.EncodeToString(ciphertext); c != test.Ciphertext {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, c, test.Ciphertext)
+		}
+
+		p, err := s.Open(nil, ciphertext, additionalData...)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt ciphertext: %v", i, err)
+		}
+		if !bytes.Equal(p, plaintext) {
+			t.Fatalf("Test %d: got '%x' - want '%x'", i, p, plaintext)
+		}
+
+		ciphertext[len(ciphertext)-1] ^= 1
+		if _, err = s.Open(nil, ciphertext, additionalData...); err == nil {
+			t.Fatalf("Test %d: decrypted modified ciphertext successfully", i)
+		}
+	}
+}
+
+// sivTests contains the test vectors of RFC 5297 Appendix A.
+var sivTests = []struct {
+	Key            string
+	AdditionalData []string
+	Plaintext      string
+	Ciphertext     string
+}{
+	{ // 0 - A.1 Deterministic Authenticated Encryption Example
+		Key:            "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+		AdditionalData: []string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+		Plaintext:      "112233445566778899aabbccddee",
+		Ciphertext:     "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c",
+	},
+	{ // 1 - A.2 Nonce-Based Authenticated Encryption Example
+		Key: "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+		AdditionalData: []string{
+			"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+			"102030405060708090a0",
+			"09f911029d74e35bd84156c5635688c0",
+		},
+		Plaintext:  "7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+		Ciphertext: "7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+	},
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+// StreamKeySize is the size of the data key used to encrypt
+// a stream.
+const StreamKeySize = 32
+
+const (
+	streamVersion   = 1
+	streamChunkSize = 64 * 1024 // Max. plaintext size of one stream package
+	streamMaxKeyLen = 4 * 1024  // Max. size of the encrypted data key
+
+	streamFinal = 1 << 31 // Package header flag marking the last package
+)
+
+// An encrypted stream consists of a header followed by a sequence
+// of packages. The header contains the stream version and the data
+// key encrypted with a KES key:
+//
+//	version (1 byte) | key length (2 bytes) | encrypted data key
+//
+// Each package contains up to 64 KiB of plaintext encrypted with
+// the data key using AES-256-GCM:
+//
+//	header (4 bytes) | ciphertext | tag (16 bytes)
+//
+// The package header contains the plaintext length and a flag that
+// marks the last package. It is authenticated as associated data.
+// The nonce of a package is its sequence number. Hence, packages
+// cannot be reordered, removed or appended without being detected.
+// Nonces are never reused since each stream has its own data key.
+
+// EncryptStream encrypts the plaintext read from r with the data key
+// and writes the encrypted stream to w. The sealedKey is the data key
+// encrypted with a KES key and gets stored in the stream header.
+func EncryptStream(w io.Writer, r io.Reader, dataKey, sealedKey []byte) error {
+	if len(sealedKey) > streamMaxKeyLen {
+		return errors.New("crypto: encrypted data key is too large")
+	}
+	aead, err := newStreamAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, 3+len(sealedKey))
+	header = append(header, streamVersion)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(sealedKey)))
+	header = append(header, sealedKey...)
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+
+	var (
+		src   = bufio.NewReaderSize(r, streamChunkSize)
+		buf   = make([]byte, 4+streamChunkSize+aead.Overhead())
+		nonce [12]byte
+	)
+	defer secmem.Zero(buf)
+	for seq := uint64(0); ; seq++ {
+		n, err := io.ReadFull(src, buf[4:4+streamChunkSize])
+		final := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !final {
+			return err
+		}
+		if !final {
+			// Peek ahead to detect whether this is the last
+			// package. Otherwise, a stream whose size is a
+			// multiple of the chunk size would end without
+			// a final package.
+			if _, err = src.Peek(1); errors.Is(err, io.EOF) {
+				final = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		h := uint32(n)
+		if final {
+			h |= streamFinal
+		}
+		binary.BigEndian.PutUint32(buf[:4], h)
+		binary.BigEndian.PutUint64(nonce[4:], seq)
+		ciphertext := aead.Seal(buf[4:4], nonce[:], buf[4:4+n], buf[:4])
+		if _, err = w.Write(buf[:4+len(ciphertext)]); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// ReadStreamHeader reads the header of an encrypted stream from r
+// and returns the encrypted data key. It returns kes.ErrDecrypt if
+// the header is malformed.
+func ReadStreamHeader(r io.Reader) ([]byte, error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, kes.ErrDecrypt
+		}
+		return nil, err
+	}
+	if header[0] != streamVersion {
+		return nil, kes.ErrDecrypt
+	}
+
+	n := binary.BigEndian.Uint16(header[1:])
+	if n == 0 || n > streamMaxKeyLen {
+		return nil, kes.ErrDecrypt
+	}
+	sealedKey := make([]byte, n)
+	if _, err := io.ReadFull(r, sealedKey); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, kes.ErrDecrypt
+		}
+		return nil, err
+	}
+	return sealedKey, nil
+}
+
+// DecryptStream decrypts the packages of an encrypted stream read
+// from r with the data key and writes the plaintext to w. The stream
+// header must have been read by ReadStreamHeader before.
+//
+// DecryptStream only writes authentic plaintext to w. However, it
+// may write some plaintext before detecting that the stream has
+// been truncated or modified. In such a case, it returns
+// kes.ErrDecrypt.
+func DecryptStream(w io.Writer, r io.Reader, dataKey []byte) error {
+	aead, err := newStreamAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	var (
+		buf   = make([]byte, 4+streamChunkSize+aead.Overhead())
+		nonce [12]byte
+	)
+	defer secmem.Zero(buf)
+	for seq := uint64(0); ; seq++ {
+		if _, err = io.ReadFull(r, buf[:4]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return kes.ErrDecrypt // Stream ends without final package
+			}
+			return err
+		}
+
+		h := binary.BigEndian.Uint32(buf[:4])
+		final, n := h&streamFinal != 0, int(h&^streamFinal)
+		if n > streamChunkSize {
+			return kes.ErrDecrypt
+		}
+		ciphertext := buf[4 : 4+n+aead.Overhead()]
+		if _, err = io.ReadFull(r, ciphertext); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return kes.ErrDecrypt
+			}
+			return err
+		}
+
+		binary.BigEndian.PutUint64(nonce[4:], seq)
+		plaintext, err := aead.Open(ciphertext[:0], nonce[:], ciphertext, buf[:4])
+		if err != nil {
+			return kes.ErrDecrypt
+		}
+		if _, err = w.Write(plaintext); err != nil {
+			return err
+		}
+		if final {
+			// No data must follow the final package.
+			var b [1]byte
+			if n, _ := io.ReadFull(r, b[:]); n > 0 {
+				return kes.ErrDecrypt
+			}
+			return nil
+		}
+	}
+}
+
+// newStreamAEAD returns the AES-256-GCM AEAD for the stream data key.
+func newStreamAEAD(dataKey []byte) (cipher.AEAD, error) {
+	if len(dataKey) != StreamKeySize {
+		return nil, errors.New("crypto: invalid stream data key size")
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
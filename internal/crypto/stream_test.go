@@ -0,0 +1,79 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestEncryptDecryptStream(t *testing.T) {
+	t.Parallel()
+
+	dataKey := make([]byte, StreamKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	sealedKey := []byte("sealed data key")
+
+	for i, size := range streamSizeTests {
+		plaintext := make([]byte, size)
+		if _, err := rand.Read(plaintext); err != nil {
+			t.Fatalf("Test %d: failed to generate plaintext: %v", i, err)
+		}
+
+		var stream bytes.Buffer
+		if err := EncryptStream(&stream, bytes.NewReader(plaintext), dataKey, sealedKey); err != nil {
+			t.Fatalf("Test %d: failed to encrypt stream: %v", i, err)
+		}
+		ciphertext := stream.Bytes()
+
+		r := bytes.NewReader(ciphertext)
+		key, err := ReadStreamHeader(r)
+		if err != nil {
+			t.Fatalf("Test %d: failed to read stream header: %v", i, err)
+		}
+		if !bytes.Equal(key, sealedKey) {
+			t.Fatalf("Test %d: sealed key mismatch: got '%s' - want '%s'", i, key, sealedKey)
+		}
+
+		var p bytes.Buffer
+		if err = DecryptStream(&p, r, dataKey); err != nil {
+			t.Fatalf("Test %d: failed to decrypt stream: %v", i, err)
+		}
+		if !bytes.Equal(p.Bytes(), plaintext) {
+			t.Fatalf("Test %d: plaintext mismatch", i)
+		}
+
+		// Truncating or extending the stream must be detected.
+		truncated := bytes.NewReader(ciphertext[:len(ciphertext)-1])
+		if _, err = ReadStreamHeader(truncated); err != nil {
+			t.Fatalf("Test %d: failed to read stream header: %v", i, err)
+		}
+		if err = DecryptStream(&p, truncated, dataKey); !errors.Is(err, kes.ErrDecrypt) {
+			t.Fatalf("Test %d: decrypting truncated stream: got '%v' - want '%v'", i, err, kes.ErrDecrypt)
+		}
+		extended := bytes.NewReader(append(ciphertext, 0))
+		if _, err = ReadStreamHeader(extended); err != nil {
+			t.Fatalf("Test %d: failed to read stream header: %v", i, err)
+		}
+		if err = DecryptStream(&p, extended, dataKey); !errors.Is(err, kes.ErrDecrypt) {
+			t.Fatalf("Test %d: decrypting extended stream: got '%v' - want '%v'", i, err, kes.ErrDecrypt)
+		}
+	}
+}
+
+var streamSizeTests = []int{
+	0,
+	1,
+	streamChunkSize - 1,
+	streamChunkSize,
+	streamChunkSize + 1,
+	3 * streamChunkSize,
+}
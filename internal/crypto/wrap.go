@@ -0,0 +1,128 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrNoWrapping is returned when a non-wrapping key is used
+// for unwrapping data.
+var ErrNoWrapping = kes.NewError(http.StatusConflict, "key does not support unwrapping")
+
+// eciesInfo is the HKDF info used to derive the ECIES
+// encryption key.
+const eciesInfo = "KES ECIES AES-256-GCM"
+
+// Wrap encrypts the plaintext to the public key using ECIES and
+// authenticates the associatedData.
+//
+// The ciphertext consists of the ephemeral public key followed by
+// the AES-256-GCM encrypted plaintext. The AES key is derived from
+// the ECDH shared secret using HKDF-SHA256 with the ephemeral and
+// the recipient public key as salt. Since every AES key is used
+// only once, the GCM nonce is all zeros.
+func Wrap(publicKey *ecdh.PublicKey, plaintext, associatedData []byte) ([]byte, error) {
+	ephemeral, err := publicKey.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralKey := ephemeral.PublicKey().Bytes()
+	aead, err := eciesAEAD(secret, ephemeralKey, publicKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, 0, len(ephemeralKey)+len(plaintext)+aead.Overhead())
+	ciphertext = append(ciphertext, ephemeralKey...)
+	return aead.Seal(ciphertext, make([]byte, aead.NonceSize()), plaintext, associatedData), nil
+}
+
+// Unwrap decrypts a ciphertext produced by Wrap for the SecretKey's
+// public key and authenticates the associatedData.
+//
+// It returns ErrNoWrapping if the SecretKey is not a wrapping key.
+func (s SecretKey) Unwrap(ciphertext, associatedData []byte) ([]byte, error) {
+	if !s.initialized {
+		panic("crypto: usage of empty or uninitialized secret key detected")
+	}
+	if !s.cipher.IsWrapping() {
+		return nil, ErrNoWrapping
+	}
+
+	privateKey, err := s.ecdhKey()
+	if err != nil {
+		return nil, err
+	}
+	n := len(privateKey.PublicKey().Bytes())
+	if len(ciphertext) < n {
+		return nil, kes.ErrDecrypt
+	}
+	ephemeral, err := privateKey.Curve().NewPublicKey(ciphertext[:n])
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	secret, err := privateKey.ECDH(ephemeral)
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+
+	aead, err := eciesAEAD(secret, ciphertext[:n], privateKey.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[n:], associatedData)
+	if err != nil {
+		return nil, kes.ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// ecdhKey returns the ECDH private key for the SecretKey.
+func (s SecretKey) ecdhKey() (*ecdh.PrivateKey, error) {
+	switch s.cipher {
+	case X25519:
+		return ecdh.X25519().NewPrivateKey(s.key[:])
+	case ECIESP256:
+		return ecdh.P256().NewPrivateKey(s.key[:])
+	default:
+		return nil, errors.New("crypto: secret key is not an ECDH key")
+	}
+}
+
+// eciesAEAD returns the AES-256-GCM AEAD used to encrypt and decrypt
+// ECIES ciphertexts. The AES key is derived from the ECDH shared secret
+// using HKDF-SHA256. The ephemeral and the recipient public key are used
+// as salt such that the derived key is bound to both parties.
+func eciesAEAD(secret, ephemeralKey, publicKey []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralKey)+len(publicKey))
+	salt = append(salt, ephemeralKey...)
+	salt = append(salt, publicKey...)
+
+	var key [32]byte
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(eciesInfo)), key[:]); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -4,29 +4,51 @@
 
 package fips
 
-import "crypto/tls"
+import (
+	"crypto/tls"
+	"sync/atomic"
+)
 
-// Enabled indicates whether cryptographic primitives,
-// like AES or SHA-256, are implemented using a FIPS 140
-// certified module.
+// Enabled reports whether FIPS mode is enabled. Then, no
+// non-NIST/FIPS approved primitives must be used.
 //
-// If FIPS-140 is enabled no non-NIST/FIPS approved
-// primitives must be used.
-const Enabled = enabled
+// FIPS mode is always enabled when built with the "fips"
+// build tag. Otherwise, it can be enabled at runtime via
+// Enable.
+func Enabled() bool { return enabled || mode.Load() }
+
+// Enable enables FIPS mode for the remaining lifetime
+// of the process. FIPS mode cannot be disabled again.
+func Enable() { mode.Store(true) }
+
+var mode atomic.Bool
+
+// Module returns the name of the FIPS 140 validated
+// cryptographic module implementing cryptographic
+// primitives, like AES or SHA-256. It returns "boringcrypto"
+// if built with GOEXPERIMENT=boringcrypto and "go" if the
+// Go Cryptographic Module runs in FIPS 140-3 mode, e.g. due
+// to GODEBUG=fips140=on. Otherwise, it returns an empty string.
+//
+// If FIPS mode is enabled but Module returns an empty string,
+// only FIPS approved primitives are used but they are not
+// implemented by a validated module.
+func Module() string {
+	switch {
+	case boringEnabled():
+		return "boringcrypto"
+	case goFIPS140Enabled():
+		return "go"
+	default:
+		return ""
+	}
+}
 
 // TLSCiphers returns a list of supported TLS transport
 // cipher suite IDs.
 func TLSCiphers() []uint16 {
-	if Enabled {
-		return []uint16{
-			tls.TLS_AES_128_GCM_SHA256, // TLS 1.3
-			tls.TLS_AES_256_GCM_SHA384,
-
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // TLS 1.2
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-		}
+	if Enabled() {
+		return ApprovedTLSCiphers()
 	}
 	return []uint16{
 		tls.TLS_AES_128_GCM_SHA256, // TLS 1.3
@@ -42,15 +64,26 @@ func TLSCiphers() []uint16 {
 	}
 }
 
+// ApprovedTLSCiphers returns a list of FIPS approved TLS
+// transport cipher suite IDs, regardless of whether FIPS
+// mode is enabled.
+func ApprovedTLSCiphers() []uint16 {
+	return []uint16{
+		tls.TLS_AES_128_GCM_SHA256, // TLS 1.3
+		tls.TLS_AES_256_GCM_SHA384,
+
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // TLS 1.2
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+}
+
 // TLSCurveIDs returns a list of supported elliptic curve IDs
 // in preference order.
 func TLSCurveIDs() []tls.CurveID {
-	if Enabled {
-		return []tls.CurveID{
-			tls.CurveP256,
-			tls.CurveP384, // Contant time since Go 1.18
-			tls.CurveP521, // Constat time since Go 1.18
-		}
+	if Enabled() {
+		return ApprovedTLSCurveIDs()
 	}
 	return []tls.CurveID{
 		tls.X25519,
@@ -59,3 +92,14 @@ func TLSCurveIDs() []tls.CurveID {
 		tls.CurveP521, // Contant time since Go 1.18
 	}
 }
+
+// ApprovedTLSCurveIDs returns a list of FIPS approved
+// elliptic curve IDs in preference order, regardless of
+// whether FIPS mode is enabled.
+func ApprovedTLSCurveIDs() []tls.CurveID {
+	return []tls.CurveID{
+		tls.CurveP256,
+		tls.CurveP384, // Contant time since Go 1.18
+		tls.CurveP521, // Constat time since Go 1.18
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+
+package fips
+
+import "crypto/fips140"
+
+func goFIPS140Enabled() bool { return fips140.Enabled() }
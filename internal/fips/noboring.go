@@ -0,0 +1,9 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !boringcrypto
+
+package fips
+
+func boringEnabled() bool { return false }
@@ -18,6 +18,7 @@ const (
 	ContentType      = "Content-Type"      // RFC 2616
 	ContentLength    = "Content-Length"    // RFC 2616
 	ETag             = "ETag"              // RFC 2616
+	RetryAfter       = "Retry-After"       // RFC 2616
 	TransferEncoding = "Transfer-Encoding" // RFC 2616
 )
 
@@ -30,6 +31,10 @@ const (
 	XFrameOptions = "X-Frame-Options" // Non-standard
 )
 
+// KesEnclave is the HTTP header clients use to address
+// a KES enclave.
+const KesEnclave = "Kes-Enclave"
+
 // Commonly used HTTP content type values.
 const (
 	ContentTypeBinary    = "application/octet-stream"
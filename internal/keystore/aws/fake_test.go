@@ -0,0 +1,197 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/keystoretest"
+)
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(&fakeSecretsManager{})
+	defer srv.Close()
+
+	keystoretest.TestKeyStore(t, func(ctx context.Context) (kes.KeyStore, error) {
+		return Connect(ctx, &Config{
+			Addr:   srv.URL,
+			Region: "us-east-1",
+			Login: Credentials{
+				AccessKey: "access-key",
+				SecretKey: "secret-key",
+			},
+		})
+	})
+}
+
+func TestAssumeRole(t *testing.T) {
+	sts := httptest.NewServer(&fakeSTS{RoleARN: "arn:aws:iam::123456789012:role/kes", ExternalID: "external-id"})
+	defer sts.Close()
+	srv := httptest.NewServer(&fakeSecretsManager{AccessKey: fakeSTSAccessKey})
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Addr:   srv.URL,
+		Region: "us-east-1",
+		Login: Credentials{
+			AccessKey: "access-key",
+			SecretKey: "secret-key",
+		},
+		AssumeRole: &AssumeRole{
+			RoleARN:    "arn:aws:iam::123456789012:role/kes",
+			ExternalID: "external-id",
+			Region:     "eu-central-1",
+			Endpoint:   sts.URL,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create key with assumed role: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to read key with assumed role: %v", err)
+	}
+}
+
+// fakeSTSAccessKey is the access key of the
+// credentials returned by fakeSTS.
+const fakeSTSAccessKey = "ASIAFAKEACCESSKEY"
+
+// fakeSTS is an AWS STS that implements AssumeRole.
+type fakeSTS struct {
+	RoleARN    string
+	ExternalID string
+}
+
+func (s *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRole" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("RoleArn") != s.RoleARN || r.Form.Get("ExternalId") != s.ExternalID || r.Form.Get("RoleSessionName") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>%s</AccessKeyId>
+      <SecretAccessKey>secret-key</SecretAccessKey>
+      <SessionToken>session-token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>%s/kes</Arn>
+      <AssumedRoleId>AROAFAKE:kes</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, fakeSTSAccessKey, time.Now().Add(time.Hour).UTC().Format(time.RFC3339), s.RoleARN)
+}
+
+// fakeSecretsManager is an in-memory AWS SecretsManager. It
+// implements the subset of the JSON API used by the Store.
+type fakeSecretsManager struct {
+	AccessKey string // If set, requests must be signed with this access key
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (s *fakeSecretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.secrets == nil {
+		s.secrets = map[string]string{}
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusOK) // Status checks
+		return
+	}
+	if s.AccessKey != "" && !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.AccessKey+"/") {
+		writeError(w, "UnrecognizedClientException", "invalid credentials")
+		return
+	}
+
+	var req struct {
+		Name         string
+		SecretId     string
+		SecretString string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "InvalidRequestException", err.Error())
+		return
+	}
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "secretsmanager.") {
+	case "CreateSecret":
+		if _, ok := s.secrets[req.Name]; ok {
+			writeError(w, "ResourceExistsException", "secret already exists")
+			return
+		}
+		s.secrets[req.Name] = req.SecretString
+		writeJSON(w, map[string]any{"Name": req.Name})
+	case "PutSecretValue":
+		if _, ok := s.secrets[req.SecretId]; !ok {
+			writeError(w, "ResourceNotFoundException", "secret not found")
+			return
+		}
+		s.secrets[req.SecretId] = req.SecretString
+		writeJSON(w, map[string]any{"Name": req.SecretId})
+	case "GetSecretValue":
+		value, ok := s.secrets[req.SecretId]
+		if !ok {
+			writeError(w, "ResourceNotFoundException", "secret not found")
+			return
+		}
+		writeJSON(w, map[string]any{"Name": req.SecretId, "SecretString": value})
+	case "DeleteSecret":
+		if _, ok := s.secrets[req.SecretId]; !ok {
+			writeError(w, "ResourceNotFoundException", "secret not found")
+			return
+		}
+		delete(s.secrets, req.SecretId)
+		writeJSON(w, map[string]any{"Name": req.SecretId})
+	case "ListSecrets":
+		names := make([]string, 0, len(s.secrets))
+		for name := range s.secrets {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		list := make([]map[string]any, 0, len(names))
+		for _, name := range names {
+			list = append(list, map[string]any{"Name": name})
+		}
+		writeJSON(w, map[string]any{"SecretList": list})
+	default:
+		writeError(w, "InvalidActionException", "unsupported action")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{"__type": code, "message": message})
+}
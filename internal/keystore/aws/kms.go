@@ -0,0 +1,344 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// KMSConfig is a structure containing configuration
+// options for connecting to AWS KMS.
+type KMSConfig struct {
+	// Addr is the HTTP address of AWS KMS. In general,
+	// the address has the following form:
+	//  kms[-fips].<region>.amazonaws.com
+	Addr string
+
+	// Region is the AWS region.
+	Region string
+
+	// AliasPrefix is an optional prefix of all KMS key aliases
+	// managed by KES. The alias of a key is:
+	//  alias/<AliasPrefix><name>
+	AliasPrefix string
+
+	// Grants are created on each new KMS key. They allow other
+	// AWS principals to use the KMS keys created by KES.
+	Grants []Grant
+
+	// DeletionWindow is the number of days, between 7 and 30,
+	// after which AWS KMS deletes a KMS key once KES deleted it.
+	// If zero, the AWS default of 30 days is used.
+	DeletionWindow int
+
+	// Login contains the AWS credentials (access/secret key).
+	Login Credentials
+
+	// AssumeRole, if set, makes the KMSStore assume an IAM role
+	// using the Login credentials.
+	AssumeRole *AssumeRole
+}
+
+// Grant allows an AWS principal to perform a set of operations
+// with a KMS key.
+//
+// Ref: https://docs.aws.amazon.com/kms/latest/developerguide/grants.html
+type Grant struct {
+	// GranteePrincipal is the ARN of the AWS principal,
+	// for example an IAM role.
+	GranteePrincipal string
+
+	// Operations are the KMS operations granted to the
+	// principal. For example: "Decrypt", "GenerateDataKey".
+	Operations []string
+}
+
+// ConnectKMS establishes and returns a KMSStore connected
+// to AWS KMS using the given config.
+func ConnectKMS(ctx context.Context, config *KMSConfig) (*KMSStore, error) {
+	if config.DeletionWindow != 0 && (config.DeletionWindow < 7 || config.DeletionWindow > 30) {
+		return nil, fmt.Errorf("aws: invalid deletion window '%d': must be between 7 and 30 days", config.DeletionWindow)
+	}
+	for _, grant := range config.Grants {
+		if grant.GranteePrincipal == "" {
+			return nil, errors.New("aws: invalid grant: no grantee principal specified")
+		}
+		if len(grant.Operations) == 0 {
+			return nil, fmt.Errorf("aws: invalid grant for '%s': no operations specified", grant.GranteePrincipal)
+		}
+	}
+
+	session, clientConfig, err := newSession(config.Addr, config.Region, config.Login, config.AssumeRole)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KMSStore{
+		config: *config,
+		client: kms.New(session, clientConfig),
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KMSStore is an AWS KMS key store. It stores no key material.
+// Instead, each key is a symmetric KMS key, referenced by its
+// alias, and AWS KMS generates, encrypts and decrypts data keys.
+// Hence, key material never leaves AWS KMS.
+//
+// A KMSStore cannot store arbitrary values, like secrets. Its
+// Create and Get methods always fail.
+type KMSStore struct {
+	config KMSConfig
+	client *kms.KMS
+}
+
+var _ kes.DataKeyStore = (*KMSStore)(nil) // compiler check
+
+var errKMSValues = errors.New("aws: KMS key store cannot store values")
+
+func (s *KMSStore) String() string { return "AWS KMS: " + s.config.Addr }
+
+// Status returns the current state of AWS KMS. In particular,
+// whether it is reachable and the network latency.
+func (s *KMSStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.client.Endpoint, nil)
+	if err != nil {
+		return kes.KeyStoreState{}, err
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	defer resp.Body.Close()
+
+	return kes.KeyStoreState{
+		Latency: time.Since(start),
+	}, nil
+}
+
+// Create always fails since a KMSStore does not store values.
+// Use CreateKey to create a KMS key.
+func (*KMSStore) Create(context.Context, string, []byte) error { return errKMSValues }
+
+// Get always fails since a KMSStore does not store values.
+func (*KMSStore) Get(context.Context, string) ([]byte, error) { return nil, errKMSValues }
+
+// CreateKey creates a new symmetric KMS key with an alias for the
+// given name and creates all configured grants on the new KMS key.
+// It returns kes.ErrKeyExists if the alias exists already.
+func (s *KMSStore) CreateKey(ctx context.Context, name string) error {
+	// Check the alias first to not create KMS keys in vain.
+	_, err := s.client.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(s.alias(name)),
+	})
+	switch {
+	case err == nil:
+		return kesdk.ErrKeyExists
+	case !isAWSError(err, kms.ErrCodeNotFoundException):
+		return kmsError("create", name, err)
+	}
+
+	key, err := s.client.CreateKeyWithContext(ctx, &kms.CreateKeyInput{
+		Description: aws.String("KES key: " + name),
+		KeySpec:     aws.String(kms.KeySpecSymmetricDefault),
+		KeyUsage:    aws.String(kms.KeyUsageTypeEncryptDecrypt),
+	})
+	if err != nil {
+		return kmsError("create", name, err)
+	}
+	keyID := key.KeyMetadata.KeyId
+
+	for _, grant := range s.config.Grants {
+		if _, err = s.client.CreateGrantWithContext(ctx, &kms.CreateGrantInput{
+			KeyId:            keyID,
+			GranteePrincipal: aws.String(grant.GranteePrincipal),
+			Operations:       aws.StringSlice(grant.Operations),
+		}); err != nil {
+			_ = s.scheduleKeyDeletion(ctx, keyID)
+			return kmsError("create", name, err)
+		}
+	}
+
+	if _, err = s.client.CreateAliasWithContext(ctx, &kms.CreateAliasInput{
+		AliasName:   aws.String(s.alias(name)),
+		TargetKeyId: keyID,
+	}); err != nil {
+		// The alias may have been created concurrently, for example
+		// by another KES server. The new KMS key is not used then.
+		_ = s.scheduleKeyDeletion(ctx, keyID)
+		if isAWSError(err, kms.ErrCodeAlreadyExistsException) {
+			return kesdk.ErrKeyExists
+		}
+		return kmsError("create", name, err)
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the KMS key with the given
+// name. It returns kes.ErrKeyNotFound if no such key exists.
+func (s *KMSStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	resp, err := s.client.EncryptWithContext(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(s.alias(name)),
+		Plaintext:         plaintext,
+		EncryptionContext: encryptionContext(associatedData),
+	})
+	if err != nil {
+		return nil, kmsError("encrypt with", name, err)
+	}
+	return resp.CiphertextBlob, nil
+}
+
+// GenerateKey generates a new 256 bit data key with AWS KMS. The
+// data key is encrypted with the KMS key with the given name. It
+// returns kes.ErrKeyNotFound if no such key exists.
+func (s *KMSStore) GenerateKey(ctx context.Context, name string, associatedData []byte) ([]byte, []byte, error) {
+	resp, err := s.client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(s.alias(name)),
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: encryptionContext(associatedData),
+	})
+	if err != nil {
+		return nil, nil, kmsError("generate data key with", name, err)
+	}
+	return resp.Plaintext, resp.CiphertextBlob, nil
+}
+
+// Decrypt decrypts the ciphertext with the KMS key with the given
+// name. It returns kes.ErrKeyNotFound if no such key exists.
+func (s *KMSStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	resp, err := s.client.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(s.alias(name)),
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: encryptionContext(associatedData),
+	})
+	if err != nil {
+		if isAWSError(err, kms.ErrCodeInvalidCiphertextException) || isAWSError(err, kms.ErrCodeIncorrectKeyException) {
+			return nil, kesdk.ErrDecrypt
+		}
+		return nil, kmsError("decrypt with", name, err)
+	}
+	return resp.Plaintext, nil
+}
+
+// Delete deletes the alias of the KMS key with the given name and
+// schedules the deletion of the KMS key. AWS KMS deletes the key
+// once the deletion window has passed. Until then, the deletion
+// can be canceled.
+func (s *KMSStore) Delete(ctx context.Context, name string) error {
+	key, err := s.client.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(s.alias(name)),
+	})
+	if err != nil {
+		return kmsError("delete", name, err)
+	}
+	if _, err = s.client.DeleteAliasWithContext(ctx, &kms.DeleteAliasInput{
+		AliasName: aws.String(s.alias(name)),
+	}); err != nil {
+		return kmsError("delete", name, err)
+	}
+	if err = s.scheduleKeyDeletion(ctx, key.KeyMetadata.KeyId); err != nil {
+		return kmsError("delete", name, err)
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+func (s *KMSStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	aliasPrefix := s.alias("")
+
+	var names []string
+	err := s.client.ListAliasesPagesWithContext(ctx, &kms.ListAliasesInput{}, func(page *kms.ListAliasesOutput, lastPage bool) bool {
+		for _, alias := range page.Aliases {
+			if name := aws.StringValue(alias.AliasName); strings.HasPrefix(name, aliasPrefix) && alias.TargetKeyId != nil {
+				names = append(names, strings.TrimPrefix(name, aliasPrefix))
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("aws: failed to list keys: %v", err)
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the KMSStore.
+func (s *KMSStore) Close() error { return nil }
+
+// alias returns the KMS key alias for the given key name.
+func (s *KMSStore) alias(name string) string {
+	return "alias/" + s.config.AliasPrefix + name
+}
+
+// scheduleKeyDeletion schedules the deletion of the KMS key.
+func (s *KMSStore) scheduleKeyDeletion(ctx context.Context, keyID *string) error {
+	input := &kms.ScheduleKeyDeletionInput{
+		KeyId: keyID,
+	}
+	if s.config.DeletionWindow > 0 {
+		input.PendingWindowInDays = aws.Int64(int64(s.config.DeletionWindow))
+	}
+	_, err := s.client.ScheduleKeyDeletionWithContext(ctx, input)
+	return err
+}
+
+// encryptionContext returns the AWS KMS encryption context
+// for the associated data. AWS KMS binds a ciphertext to
+// its encryption context, similar to associated data.
+//
+// Ref: https://docs.aws.amazon.com/kms/latest/developerguide/concepts.html#encrypt_context
+func encryptionContext(associatedData []byte) map[string]*string {
+	if len(associatedData) == 0 {
+		return nil
+	}
+	return map[string]*string{
+		"kes:context": aws.String(base64.StdEncoding.EncodeToString(associatedData)),
+	}
+}
+
+// isAWSError reports whether err is an AWS error with the given code.
+func isAWSError(err error, code string) bool {
+	var aErr awserr.Error
+	return errors.As(err, &aErr) && aErr.Code() == code
+}
+
+// kmsError converts err into kes.ErrKeyNotFound if the KMS key
+// does not exist. Otherwise, it returns an error describing
+// the failed operation.
+func kmsError(op, name string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if isAWSError(err, kms.ErrCodeNotFoundException) {
+		return kesdk.ErrKeyNotFound
+	}
+	return fmt.Errorf("aws: failed to %s '%s': %v", op, name, err)
+}
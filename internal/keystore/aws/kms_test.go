@@ -0,0 +1,213 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestKMSStore(t *testing.T) {
+	kms := &fakeKMS{}
+	srv := httptest.NewServer(kms)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := ConnectKMS(ctx, &KMSConfig{
+		Addr:        srv.URL,
+		Region:      "us-east-1",
+		AliasPrefix: "kes/",
+		Grants: []Grant{
+			{GranteePrincipal: "arn:aws:iam::123456789012:role/minio", Operations: []string{"Decrypt"}},
+		},
+		Login: Credentials{
+			AccessKey: "access-key",
+			SecretKey: "secret-key",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.CreateKey(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if len(kms.grants) != 1 || kms.grants[0] != "arn:aws:iam::123456789012:role/minio" {
+		t.Fatalf("Invalid grants: got '%v'", kms.grants)
+	}
+
+	plaintext, ciphertext, err := store.GenerateKey(ctx, "my-key", []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	decrypted, err := store.Decrypt(ctx, "my-key", ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key: %v", err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Fatal("Decrypted data key does not match generated data key")
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("other context")); !errors.Is(err, kesdk.ErrDecrypt) {
+		t.Fatalf("Decrypting with wrong context: got '%v' - want '%v'", err, kesdk.ErrDecrypt)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Listing keys: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = store.Encrypt(ctx, "my-key", []byte("plaintext"), nil); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create deleted key again: %v", err)
+	}
+}
+
+// fakeKMS is an in-memory AWS KMS. It implements the subset
+// of the JSON API used by the KMSStore. Its "ciphertexts" are
+// not encrypted.
+type fakeKMS struct {
+	mu      sync.Mutex
+	keys    map[string]bool   // key ID -> scheduled for deletion
+	aliases map[string]string // alias -> key ID
+	grants  []string          // grantee principals
+}
+
+type fakeKMSCiphertext struct {
+	KeyID     string
+	Plaintext []byte
+	Context   map[string]string
+}
+
+func (k *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys == nil {
+		k.keys, k.aliases = map[string]bool{}, map[string]string{}
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusOK) // Status checks
+		return
+	}
+
+	var req struct {
+		KeyId             string
+		AliasName         string
+		TargetKeyId       string
+		GranteePrincipal  string
+		Plaintext         []byte
+		CiphertextBlob    []byte
+		EncryptionContext map[string]string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "ValidationException", err.Error())
+		return
+	}
+
+	// resolve returns the ID of the key referenced by its ID or alias.
+	resolve := func(id string) (string, bool) {
+		if strings.HasPrefix(id, "alias/") {
+			id = k.aliases[id]
+		}
+		deleted, ok := k.keys[id]
+		return id, ok && !deleted
+	}
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "TrentService.") {
+	case "CreateKey":
+		id := fmt.Sprintf("key-%d", len(k.keys)+1)
+		k.keys[id] = false
+		writeJSON(w, map[string]any{"KeyMetadata": map[string]any{"KeyId": id}})
+	case "DescribeKey":
+		id, ok := resolve(req.KeyId)
+		if !ok {
+			writeError(w, "NotFoundException", "key not found")
+			return
+		}
+		writeJSON(w, map[string]any{"KeyMetadata": map[string]any{"KeyId": id}})
+	case "CreateGrant":
+		if _, ok := resolve(req.KeyId); !ok {
+			writeError(w, "NotFoundException", "key not found")
+			return
+		}
+		k.grants = append(k.grants, req.GranteePrincipal)
+		writeJSON(w, map[string]any{"GrantId": "grant"})
+	case "CreateAlias":
+		if _, ok := k.aliases[req.AliasName]; ok {
+			writeError(w, "AlreadyExistsException", "alias exists")
+			return
+		}
+		k.aliases[req.AliasName] = req.TargetKeyId
+		writeJSON(w, map[string]any{})
+	case "DeleteAlias":
+		if _, ok := k.aliases[req.AliasName]; !ok {
+			writeError(w, "NotFoundException", "alias not found")
+			return
+		}
+		delete(k.aliases, req.AliasName)
+		writeJSON(w, map[string]any{})
+	case "ScheduleKeyDeletion":
+		if _, ok := resolve(req.KeyId); !ok {
+			writeError(w, "NotFoundException", "key not found")
+			return
+		}
+		k.keys[req.KeyId] = true
+		writeJSON(w, map[string]any{"KeyId": req.KeyId})
+	case "ListAliases":
+		aliases := make([]map[string]any, 0, len(k.aliases))
+		for alias, id := range k.aliases {
+			aliases = append(aliases, map[string]any{"AliasName": alias, "TargetKeyId": id})
+		}
+		writeJSON(w, map[string]any{"Aliases": aliases, "Truncated": false})
+	case "Encrypt", "GenerateDataKey":
+		id, ok := resolve(req.KeyId)
+		if !ok {
+			writeError(w, "NotFoundException", "key not found")
+			return
+		}
+		plaintext := req.Plaintext
+		if plaintext == nil {
+			plaintext = bytes.Repeat([]byte{byte(len(k.keys))}, 32)
+		}
+		ciphertext, _ := json.Marshal(fakeKMSCiphertext{KeyID: id, Plaintext: plaintext, Context: req.EncryptionContext})
+		writeJSON(w, map[string]any{"KeyId": id, "CiphertextBlob": ciphertext, "Plaintext": plaintext})
+	case "Decrypt":
+		id, ok := resolve(req.KeyId)
+		if !ok {
+			writeError(w, "NotFoundException", "key not found")
+			return
+		}
+		var ciphertext fakeKMSCiphertext
+		if err := json.Unmarshal(req.CiphertextBlob, &ciphertext); err != nil || ciphertext.KeyID != id || fmt.Sprint(ciphertext.Context) != fmt.Sprint(req.EncryptionContext) {
+			writeError(w, "InvalidCiphertextException", "invalid ciphertext")
+			return
+		}
+		writeJSON(w, map[string]any{"KeyId": id, "Plaintext": ciphertext.Plaintext})
+	default:
+		writeError(w, "InvalidActionException", "unsupported action")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/minio/kes"
@@ -50,17 +51,67 @@ type Config struct {
 
 	// Login contains the AWS credentials (access/secret key).
 	Login Credentials
+
+	// AssumeRole, if set, makes the Store assume an IAM role
+	// using the Login credentials. It is used to access the
+	// SecretsManager of another AWS account, for example a
+	// centrally owned security account.
+	AssumeRole *AssumeRole
+}
+
+// AssumeRole contains the IAM role assumed via AWS STS.
+type AssumeRole struct {
+	// RoleARN is the ARN of the IAM role. For example:
+	//  arn:aws:iam::<account-id>:role/<role-name>
+	RoleARN string
+
+	// ExternalID is an optional unique identifier that the
+	// role's trust policy may require for cross-account access.
+	ExternalID string
+
+	// SessionName is the name of the role session. It is
+	// logged by AWS CloudTrail. If empty, defaults to "kes".
+	SessionName string
+
+	// Region is an optional AWS region of the STS endpoint.
+	// If empty, defaults to the SecretsManager region.
+	Region string
+
+	// Endpoint is an optional HTTP address of the STS
+	// endpoint. If empty, the SDK default is used.
+	Endpoint string
 }
 
 // Connect establishes and returns a Conn to a AWS SecretManager
 // using the given config.
 func Connect(ctx context.Context, config *Config) (*Store, error) {
+	session, clientConfig, err := newSession(config.Addr, config.Region, config.Login, config.AssumeRole)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Store{
+		config: *config,
+		client: secretsmanager.New(session, clientConfig),
+	}
+	if _, err = c.Status(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newSession returns a new AWS session and the configuration
+// of a service client for the given endpoint.
+//
+// The endpoint is set for the service client only. Otherwise,
+// the STS client would use it as well when assuming a role.
+func newSession(endpoint, region string, login Credentials, role *AssumeRole) (*session.Session, *aws.Config, error) {
 	credentials := credentials.NewStaticCredentials(
-		config.Login.AccessKey,
-		config.Login.SecretKey,
-		config.Login.SessionToken,
+		login.AccessKey,
+		login.SecretKey,
+		login.SessionToken,
 	)
-	if config.Login.AccessKey == "" && config.Login.SecretKey == "" && config.Login.SessionToken == "" {
+	if login.AccessKey == "" && login.SecretKey == "" && login.SessionToken == "" {
 		// If all login credentials (access key, secret key and session token) are empty
 		// we pass no (not empty) credentials to the AWS SDK. The SDK will try to fetch
 		// the credentials from:
@@ -75,24 +126,47 @@ func Connect(ctx context.Context, config *Config) (*Store, error) {
 
 	session, err := session.NewSessionWithOptions(session.Options{
 		Config: aws.Config{
-			Endpoint:    aws.String(config.Addr),
-			Region:      aws.String(config.Region),
+			Region:      aws.String(region),
 			Credentials: credentials,
 		},
 		SharedConfigState: session.SharedConfigDisable,
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	c := &Store{
-		config: *config,
-		client: secretsmanager.New(session),
+	clientConfig := &aws.Config{
+		Endpoint: aws.String(endpoint),
 	}
-	if _, err = c.Status(ctx); err != nil {
-		return nil, err
+	if role != nil {
+		if role.RoleARN == "" {
+			return nil, nil, errors.New("aws: no role ARN specified")
+		}
+		clientConfig.Credentials = assumeRole(session, role)
 	}
-	return c, nil
+	return session, clientConfig, nil
+}
+
+// assumeRole returns credentials of the IAM role that are requested
+// from AWS STS using the session's credentials. The credentials are
+// refreshed automatically before they expire.
+func assumeRole(session *session.Session, role *AssumeRole) *credentials.Credentials {
+	stsConfig := &aws.Config{}
+	if role.Region != "" {
+		stsConfig.Region = aws.String(role.Region)
+	}
+	if role.Endpoint != "" {
+		stsConfig.Endpoint = aws.String(role.Endpoint)
+	}
+	return stscreds.NewCredentials(session.Copy(stsConfig), role.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = "kes"
+		if role.SessionName != "" {
+			p.RoleSessionName = role.SessionName
+		}
+		if role.ExternalID != "" {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+	})
 }
 
 // Store is an AWS SecretsManager secret store.
@@ -152,15 +226,26 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	return nil
 }
 
-// Set stores the given key-value pair at the AWS SecretsManager
-// if and only if it doesn't exists. If such an entry already exists
-// it returns kes.ErrKeyExists.
-//
-// If the SecretsManager.KMSKeyID is set AWS will use this key ID to
-// encrypt the values. Otherwise, AWS will use the default key ID for
-// encrypting secrets at the AWS SecretsManager.
+// Set replaces the value of an existing entry at the AWS
+// SecretsManager by adding a new secret version. It returns
+// kes.ErrKeyNotFound if no such entry exists.
 func (s *Store) Set(ctx context.Context, name string, value []byte) error {
-	return s.Create(ctx, name, value)
+	_, err := s.client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(string(value)),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if err, ok := err.(awserr.Error); ok {
+			if err.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+				return kesdk.ErrKeyNotFound
+			}
+		}
+		return fmt.Errorf("aws: failed to update '%s': %v", name, err)
+	}
+	return nil
 }
 
 // Get returns the value associated with the given key.
@@ -7,6 +7,8 @@ package azure
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 )
@@ -41,3 +43,26 @@ func transportErrToStatus(err error) (status, error) {
 	}
 	return status{}, err
 }
+
+// responseStatusCode returns the HTTP status code of the KeyVault
+// response if err is a response error. Otherwise, it returns 0.
+// In contrast to transportErrToStatus, it does not consume the
+// response body.
+func responseStatusCode(err error) int {
+	var rerr *azcore.ResponseError
+	if errors.As(err, &rerr) {
+		return rerr.StatusCode
+	}
+	return 0
+}
+
+// isPurgeForbidden reports whether KeyVault refused to purge a
+// deleted object. Either, since the client is not allowed to purge
+// objects or since purge protection is enabled. In both cases, the
+// object remains (soft) deleted until its retention period expires.
+func isPurgeForbidden(stat status) bool {
+	if stat.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return stat.ErrorCode == "ForbiddenByPolicy" || strings.Contains(strings.ToLower(stat.Message), "purge protection")
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/minio/kes"
+	"github.com/minio/kes/keystoretest"
+)
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewTLSServer(&fakeKeyVault{})
+	defer srv.Close()
+
+	keystoretest.TestKeyStore(t, func(context.Context) (kes.KeyStore, error) {
+		azsecretsClient, err := azsecrets.NewClient(srv.URL, fakeCredential{}, &azsecrets.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: srv.Client(),
+			},
+			DisableChallengeResourceVerification: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Set creates a new secret version but Get returns the
+		// first one. Hence, the Store is not a MutableKeyStore
+		// and we hide its Set method.
+		return struct{ kes.KeyStore }{
+			&Store{
+				endpoint:   srv.URL,
+				client:     client{azsecretsClient: azsecretsClient},
+				httpClient: srv.Client(),
+			},
+		}, nil
+	})
+}
+
+// fakeCredential is an azcore.TokenCredential that returns a
+// static access token.
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     "fake-token",
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+// fakeKeyVault is an in-memory Azure KeyVault. It implements
+// the subset of the secrets REST API used by the Store. Deleted
+// secrets are purged immediately.
+type fakeKeyVault struct {
+	mu      sync.Mutex
+	secrets map[string][]fakeSecretVersion
+	version int
+}
+
+type fakeSecretVersion struct {
+	Version   string
+	Value     string
+	CreatedAt time.Time
+}
+
+func (v *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.secrets == nil {
+		v.secrets = map[string][]fakeSecretVersion{}
+	}
+	if r.URL.Path == "/" {
+		w.WriteHeader(http.StatusOK) // Status checks
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer fake-token" {
+		w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://vault.azure.net"`)
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "missing access token")
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(segments) == 1 && segments[0] == "secrets":
+		var items []any
+		for name, versions := range v.secrets {
+			items = append(items, v.properties(r, name, versions[len(versions)-1]))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": items})
+
+	case r.Method == http.MethodPut && len(segments) == 2 && segments[0] == "secrets":
+		var req struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BadParameter", err.Error())
+			return
+		}
+		v.version++
+		version := fakeSecretVersion{
+			Version:   strconv.Itoa(v.version),
+			Value:     req.Value,
+			CreatedAt: time.Now(),
+		}
+		v.secrets[segments[1]] = append(v.secrets[segments[1]], version)
+		writeJSON(w, http.StatusOK, v.bundle(r, segments[1], version))
+
+	case r.Method == http.MethodGet && len(segments) == 3 && segments[0] == "secrets" && segments[2] == "versions":
+		items := []any{}
+		for _, version := range v.secrets[segments[1]] {
+			items = append(items, v.properties(r, segments[1], version))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": items})
+
+	case r.Method == http.MethodGet && (len(segments) == 2 || len(segments) == 3) && segments[0] == "secrets":
+		versions, ok := v.secrets[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SecretNotFound", "secret not found")
+			return
+		}
+		version := versions[len(versions)-1]
+		if len(segments) == 3 && segments[2] != "" {
+			var found bool
+			for _, version = range versions {
+				if found = version.Version == segments[2]; found {
+					break
+				}
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "SecretNotFound", "secret version not found")
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, v.bundle(r, segments[1], version))
+
+	case r.Method == http.MethodDelete && len(segments) == 2 && segments[0] == "secrets":
+		versions, ok := v.secrets[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SecretNotFound", "secret not found")
+			return
+		}
+		delete(v.secrets, segments[1])
+		writeJSON(w, http.StatusOK, v.bundle(r, segments[1], versions[len(versions)-1]))
+
+	case r.Method == http.MethodDelete && len(segments) == 2 && segments[0] == "deletedsecrets":
+		writeError(w, http.StatusNotFound, "SecretNotFound", "deleted secret not found")
+
+	default:
+		writeError(w, http.StatusBadRequest, "BadParameter", "unsupported operation")
+	}
+}
+
+func (v *fakeKeyVault) properties(r *http.Request, name string, version fakeSecretVersion) map[string]any {
+	return map[string]any{
+		"id": "https://" + r.Host + "/secrets/" + name + "/" + version.Version,
+		"attributes": map[string]any{
+			"enabled": true,
+			"created": version.CreatedAt.Unix(),
+			"updated": version.CreatedAt.Unix(),
+		},
+	}
+}
+
+func (v *fakeKeyVault) bundle(r *http.Request, name string, version fakeSecretVersion) map[string]any {
+	bundle := v.properties(r, name, version)
+	bundle["value"] = version.Value
+	return bundle
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, errCode, msg string) {
+	resp := errorResponse{}
+	resp.Error.Code = errCode
+	resp.Error.Message = msg
+	resp.Error.Inner.Code = errCode
+	writeJSON(w, code, resp)
+}
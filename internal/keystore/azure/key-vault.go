@@ -39,8 +39,9 @@ type ManagedIdentity struct {
 
 // Store is an Azure KeyVault secret store.
 type Store struct {
-	endpoint string
-	client   client
+	endpoint   string
+	client     client
+	httpClient *http.Client // Used for status checks
 }
 
 func (s *Store) String() string { return "Azure KeyVault: " + s.endpoint }
@@ -53,13 +54,19 @@ const (
 // Status returns the current state of the Azure KeyVault instance.
 // In particular, whether it is reachable and the network latency.
 func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint, nil)
+	return endpointStatus(ctx, s.httpClient, s.endpoint)
+}
+
+// endpointStatus returns the state of the Azure service at the
+// given endpoint.
+func endpointStatus(ctx context.Context, client *http.Client, endpoint string) (kes.KeyStoreState, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return kes.KeyStoreState{}, err
 	}
 
 	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
 	}
@@ -114,13 +121,16 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		return fmt.Errorf("azure: failed to create '%s': %v", name, err)
 	}
 	if stat.StatusCode == http.StatusConflict && (stat.ErrorCode == "ObjectIsDeletedButRecoverable" || stat.ErrorCode == "ObjectIsBeingDeleted") {
-		stat, err = s.purgeWithRetry(ctx, name, 25)
+		stat, err = purgeWithRetry(ctx, name, 25, s.client.PurgeSecret)
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			return err
 		}
 		if err != nil {
 			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted secret: %v", name, err)
 		}
+		if isPurgeForbidden(stat) {
+			return fmt.Errorf("azure: failed to create '%s': key has been deleted but cannot be purged: %s. Either recover '%s' or wait until its retention period has expired", name, stat.Message, name)
+		}
 		if stat.StatusCode != http.StatusOK {
 			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted secret: %s (%s)", name, stat.Message, stat.ErrorCode)
 		}
@@ -189,6 +199,11 @@ func (s *Store) Set(ctx context.Context, name string, value []byte) error {
 // and purge the secret. Further, a subsequent Create operation
 // will also try to purge the secret.
 //
+// If KeyVault refuses to purge the deleted secret, for example
+// since purge protection is enabled, Delete succeeds nevertheless.
+// The secret remains soft deleted until its retention period has
+// expired and a secret with the same name cannot be created before.
+//
 // Since KeyVault only supports two-steps deletes, KES cannot
 // guarantee that a Delete operation has atomic semantics.
 func (s *Store) Delete(ctx context.Context, name string) error {
@@ -221,7 +236,7 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 		return fmt.Errorf("azure: failed to delete '%s': %s (%s)", name, stat.Message, stat.ErrorCode)
 	}
 
-	stat, err = s.purgeWithRetry(ctx, name, 10)
+	stat, err = purgeWithRetry(ctx, name, 10, s.client.PurgeSecret)
 	if err != nil {
 		return err
 	}
@@ -231,6 +246,8 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 		return nil
 	case stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted":
 		return nil
+	case isPurgeForbidden(stat):
+		return nil
 	default:
 		return fmt.Errorf("azure: failed to delete '%s': failed to purge deleted secret: %s (%s)", name, stat.Message, stat.ErrorCode)
 	}
@@ -331,10 +348,14 @@ func ConnectWithCredentials(endpoint string, cred azcore.TokenCredential) (*Stor
 		client: client{
 			azsecretsClient: azsecretsClient,
 		},
+		httpClient: http.DefaultClient,
 	}, nil
 }
 
-func (s *Store) purgeWithRetry(ctx context.Context, name string, retries int) (status, error) {
+// purgeWithRetry purges the deleted object with the given name. It
+// retries purging the object up to retries times while KeyVault is
+// still deleting it.
+func purgeWithRetry(ctx context.Context, name string, retries int, purge func(context.Context, string) (status, error)) (status, error) {
 	// Now, the key either does not exist, is being deleted or
 	// has been deleted. If the key does not exist then purging
 	// it will result in a 404 NotFound.
@@ -346,7 +367,7 @@ func (s *Store) purgeWithRetry(ctx context.Context, name string, retries int) (s
 	var stat status
 	var err error
 	for i := 0; i < retries; i++ {
-		stat, err = s.client.PurgeSecret(ctx, name)
+		stat, err = purge(ctx, name)
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			return stat, err
 		}
@@ -356,7 +377,7 @@ func (s *Store) purgeWithRetry(ctx context.Context, name string, retries int) (s
 		switch {
 		case stat.StatusCode == http.StatusOK || stat.StatusCode == http.StatusNotFound:
 			return stat, nil
-		case stat.StatusCode == http.StatusForbidden && stat.ErrorCode == "ForbiddenByPolicy":
+		case isPurgeForbidden(stat):
 			return stat, nil
 		case stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted":
 			time.Sleep(delay + time.Duration(rand.Int63n(jitter.Milliseconds()))*time.Millisecond)
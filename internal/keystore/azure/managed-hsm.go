@@ -0,0 +1,304 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// HSMStore is an Azure Managed HSM key store. It stores no key
+// material. Instead, each key is an AES-256 key within the Managed
+// HSM pool and the HSM encrypts and decrypts all data keys. Hence,
+// key material never leaves the HSM.
+//
+// A HSMStore cannot store arbitrary values, like secrets. Its
+// Create and Get methods always fail.
+type HSMStore struct {
+	endpoint   string
+	client     *azkeys.Client
+	httpClient *http.Client // Used for status checks
+}
+
+var _ kes.CryptoKeyStore = (*HSMStore)(nil) // compiler check
+
+// ConnectManagedHSM returns a HSMStore for the Azure Managed HSM
+// pool at the given endpoint. The credential is used to obtain and
+// refresh access tokens. For example, a managed identity credential
+// fetches new tokens from the Azure instance metadata service (IMDS)
+// before the current one expires.
+func ConnectManagedHSM(endpoint string, cred azcore.TokenCredential) (*HSMStore, error) {
+	client, err := azkeys.NewClient(endpoint, cred, &azkeys.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries:    7,
+				RetryDelay:    200 * time.Millisecond,
+				MaxRetryDelay: 800 * time.Millisecond,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create keys client: %v", err)
+	}
+	return &HSMStore{
+		endpoint:   endpoint,
+		client:     client,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+var errHSMValues = errors.New("azure: managed HSM key store cannot store values")
+
+func (s *HSMStore) String() string { return "Azure Managed HSM: " + s.endpoint }
+
+// Status returns the current state of the Azure Managed HSM pool.
+// In particular, whether it is reachable and the network latency.
+func (s *HSMStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	return endpointStatus(ctx, s.httpClient, s.endpoint)
+}
+
+// Create always fails since a HSMStore does not store values.
+// Use CreateKey to create a HSM key.
+func (*HSMStore) Create(context.Context, string, []byte) error { return errHSMValues }
+
+// Get always fails since a HSMStore does not store values.
+func (*HSMStore) Get(context.Context, string) ([]byte, error) { return nil, errHSMValues }
+
+// CreateKey creates a new AES-256 HSM key with the given name. It
+// returns kes.ErrKeyExists if such a key exists already.
+//
+// Like Store.Create, CreateKey is not atomic. Further, if a key with
+// the same name has been deleted but not purged, CreateKey tries to
+// purge it first. It fails if the deleted key cannot be purged, for
+// example since purge protection is enabled.
+func (s *HSMStore) CreateKey(ctx context.Context, name string) error {
+	_, err := s.client.GetKey(ctx, name, "", nil)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err == nil {
+		return kesdk.ErrKeyExists
+	}
+	if responseStatusCode(err) != http.StatusNotFound {
+		return fmt.Errorf("azure: failed to create '%s': failed to check whether '%s' already exists: %v", name, name, err)
+	}
+
+	stat, err := s.createKey(ctx, name)
+	if err != nil {
+		return err
+	}
+	if stat.StatusCode == http.StatusConflict && (stat.ErrorCode == "ObjectIsDeletedButRecoverable" || stat.ErrorCode == "ObjectIsBeingDeleted") {
+		stat, err = purgeWithRetry(ctx, name, 25, s.purgeKey)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted key: %v", name, err)
+		}
+		if isPurgeForbidden(stat) {
+			return fmt.Errorf("azure: failed to create '%s': key has been deleted but cannot be purged: %s. Either recover '%s' or wait until its retention period has expired", name, stat.Message, name)
+		}
+		if stat.StatusCode != http.StatusOK {
+			return fmt.Errorf("azure: failed to create '%s': failed to purge deleted key: %s (%s)", name, stat.Message, stat.ErrorCode)
+		}
+
+		for i := 0; i < 7; i++ {
+			if stat, err = s.createKey(ctx, name); err != nil {
+				return err
+			}
+			if stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted" {
+				time.Sleep(delay + time.Duration(rand.Int63n(jitter.Milliseconds()))*time.Millisecond)
+				continue
+			}
+			break
+		}
+	}
+	if stat.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure: failed to create '%s': %s (%s)", name, stat.Message, stat.ErrorCode)
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the HSM key with the given
+// name using AES-256-GCM. The associated data is authenticated but
+// not encrypted. It returns kes.ErrKeyNotFound if no such key exists.
+func (s *HSMStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	resp, err := s.client.Encrypt(ctx, name, "", azkeys.KeyOperationParameters{
+		Algorithm:                   to.Ptr(azkeys.EncryptionAlgorithmA256GCM),
+		Value:                       plaintext,
+		AdditionalAuthenticatedData: associatedData,
+	}, nil)
+	if err != nil {
+		return nil, s.keyError("encrypt with", name, err)
+	}
+	if resp.KID == nil {
+		return nil, fmt.Errorf("azure: failed to encrypt with '%s': no key version in response", name)
+	}
+	return json.Marshal(hsmCiphertext{
+		Version:    resp.KID.Version(),
+		IV:         resp.IV,
+		Tag:        resp.AuthenticationTag,
+		Ciphertext: resp.Result,
+	})
+}
+
+// Decrypt decrypts the ciphertext with the HSM key with the given
+// name. It returns kes.ErrKeyNotFound if no such key exists and
+// kes.ErrDecrypt if the ciphertext or associated data is not
+// authentic.
+func (s *HSMStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	var c hsmCiphertext
+	if err := json.Unmarshal(ciphertext, &c); err != nil {
+		return nil, kesdk.ErrDecrypt
+	}
+	resp, err := s.client.Decrypt(ctx, name, c.Version, azkeys.KeyOperationParameters{
+		Algorithm:                   to.Ptr(azkeys.EncryptionAlgorithmA256GCM),
+		Value:                       c.Ciphertext,
+		IV:                          c.IV,
+		AuthenticationTag:           c.Tag,
+		AdditionalAuthenticatedData: associatedData,
+	}, nil)
+	if err != nil {
+		// The HSM responds with 400 Bad Request if the
+		// ciphertext is not authentic.
+		if responseStatusCode(err) == http.StatusBadRequest {
+			return nil, kesdk.ErrDecrypt
+		}
+		return nil, s.keyError("decrypt with", name, err)
+	}
+	return resp.Result, nil
+}
+
+// Delete deletes and purges the HSM key with the given name. It
+// returns kes.ErrKeyNotFound if no such key exists.
+//
+// If the HSM refuses to purge the deleted key, for example since
+// purge protection is enabled, Delete succeeds nevertheless. The
+// key remains soft deleted until its retention period has expired.
+// Until then, it can be recovered but not used.
+func (s *HSMStore) Delete(ctx context.Context, name string) error {
+	if _, err := s.client.DeleteKey(ctx, name, nil); err != nil {
+		return s.keyError("delete", name, err)
+	}
+
+	stat, err := purgeWithRetry(ctx, name, 10, s.purgeKey)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stat.StatusCode == http.StatusOK:
+		return nil
+	case stat.StatusCode == http.StatusConflict && stat.ErrorCode == "ObjectIsBeingDeleted":
+		return nil
+	case isPurgeForbidden(stat):
+		return nil
+	default:
+		return fmt.Errorf("azure: failed to delete '%s': failed to purge deleted key: %s (%s)", name, stat.Message, stat.ErrorCode)
+	}
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty
+func (s *HSMStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	var names []string
+	pager := s.client.NewListKeyPropertiesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil, "", err
+			}
+			return nil, "", fmt.Errorf("azure: failed to list keys: %v", err)
+		}
+		for _, v := range page.Value {
+			if v.KID != nil {
+				names = append(names, v.KID.Name())
+			}
+		}
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the HSMStore.
+func (s *HSMStore) Close() error { return nil }
+
+// hsmCiphertext is the ciphertext produced by a HSMStore. The HSM
+// generates the IV and authentication tag and encrypts with the
+// current key version.
+type hsmCiphertext struct {
+	Version    string `json:"version"`
+	IV         []byte `json:"iv"`
+	Tag        []byte `json:"tag"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// createKey creates a new AES-256 HSM key with the given name.
+func (s *HSMStore) createKey(ctx context.Context, name string) (status, error) {
+	_, err := s.client.CreateKey(ctx, name, azkeys.CreateKeyParameters{
+		Kty:     to.Ptr(azkeys.KeyTypeOctHSM),
+		KeySize: to.Ptr[int32](256),
+		KeyOps: []*azkeys.KeyOperation{
+			to.Ptr(azkeys.KeyOperationEncrypt),
+			to.Ptr(azkeys.KeyOperationDecrypt),
+		},
+	}, nil)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status{}, err
+	}
+	if err != nil {
+		stat, sErr := transportErrToStatus(err)
+		if sErr != nil {
+			return stat, fmt.Errorf("azure: failed to create '%s': %v", name, err)
+		}
+		return stat, nil
+	}
+	return status{StatusCode: http.StatusOK}, nil
+}
+
+// purgeKey purges the deleted HSM key with the given name.
+func (s *HSMStore) purgeKey(ctx context.Context, name string) (status, error) {
+	_, err := s.client.PurgeDeletedKey(ctx, name, nil)
+	if err != nil {
+		stat, err := transportErrToStatus(err)
+		if stat.StatusCode != http.StatusNoContent && stat.StatusCode != http.StatusOK && stat.StatusCode != http.StatusNotFound {
+			return stat, err
+		}
+	}
+	return status{StatusCode: http.StatusOK}, nil
+}
+
+// keyError returns kes.ErrKeyNotFound if err indicates that the
+// HSM key with the given name does not exist. Otherwise, it returns
+// an error describing the failed operation.
+func (s *HSMStore) keyError(op, name string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if responseStatusCode(err) == http.StatusNotFound {
+		return kesdk.ErrKeyNotFound
+	}
+	return fmt.Errorf("azure: failed to %s '%s': %v", op, name, err)
+}
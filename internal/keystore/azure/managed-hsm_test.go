@@ -0,0 +1,220 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azkeys"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestHSMStore(t *testing.T) {
+	hsm := &fakeManagedHSM{}
+	srv := httptest.NewTLSServer(hsm)
+	defer srv.Close()
+
+	client, err := azkeys.NewClient(srv.URL, fakeCredential{}, &azkeys.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: srv.Client(),
+		},
+		DisableChallengeResourceVerification: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	store := &HSMStore{
+		endpoint:   srv.URL,
+		client:     client,
+		httpClient: srv.Client(),
+	}
+
+	ctx := context.Background()
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.CreateKey(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+
+	ciphertext, err := store.Encrypt(ctx, "my-key", []byte("plaintext"), []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	plaintext, err := store.Decrypt(ctx, "my-key", ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("plaintext")) {
+		t.Fatalf("Decrypted plaintext does not match: got '%s' - want '%s'", plaintext, "plaintext")
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("other context")); !errors.Is(err, kesdk.ErrDecrypt) {
+		t.Fatalf("Decrypting with wrong context: got '%v' - want '%v'", err, kesdk.ErrDecrypt)
+	}
+	if _, err = store.Encrypt(ctx, "other-key", []byte("plaintext"), nil); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Listing keys: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create deleted key again: %v", err)
+	}
+
+	// With purge protection, deleted keys remain soft deleted.
+	hsm.purgeProtection = true
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key with purge protection: %v", err)
+	}
+	if _, err = store.Encrypt(ctx, "my-key", []byte("plaintext"), nil); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.CreateKey(ctx, "my-key"); err == nil || errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating soft deleted key with purge protection: got '%v' - want purge error", err)
+	}
+}
+
+// fakeManagedHSM is an in-memory Azure Managed HSM. It implements
+// the subset of the keys REST API used by the HSMStore. Each key
+// has a single version.
+type fakeManagedHSM struct {
+	mu              sync.Mutex
+	keys            map[string][]byte // key name -> AES key
+	deleted         map[string][]byte // soft deleted keys
+	version         int
+	purgeProtection bool
+}
+
+func (h *fakeManagedHSM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.keys == nil {
+		h.keys, h.deleted = map[string][]byte{}, map[string][]byte{}
+	}
+	if r.URL.Path == "/" {
+		w.WriteHeader(http.StatusOK) // Status checks
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer fake-token" {
+		w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/tenant", resource="https://managedhsm.azure.net"`)
+		writeError(w, http.StatusUnauthorized, "Unauthorized", "missing access token")
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && len(segments) == 1 && segments[0] == "keys":
+		var items []any
+		for name := range h.keys {
+			items = append(items, map[string]any{"kid": h.kid(r, name)})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": items})
+
+	case r.Method == http.MethodPost && len(segments) == 3 && segments[2] == "create":
+		name := segments[1]
+		if _, ok := h.deleted[name]; ok {
+			writeError(w, http.StatusConflict, "ObjectIsDeletedButRecoverable", "key is deleted but recoverable")
+			return
+		}
+		key := make([]byte, 32)
+		rand.Read(key)
+		h.keys[name] = key
+		h.version++
+		writeJSON(w, http.StatusOK, map[string]any{"key": map[string]any{"kid": h.kid(r, name), "kty": "oct-HSM"}})
+
+	case r.Method == http.MethodGet && segments[0] == "keys":
+		if _, ok := h.keys[segments[1]]; !ok {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "key not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"key": map[string]any{"kid": h.kid(r, segments[1]), "kty": "oct-HSM"}})
+
+	case r.Method == http.MethodPost && segments[0] == "keys" && (segments[len(segments)-1] == "encrypt" || segments[len(segments)-1] == "decrypt"):
+		key, ok := h.keys[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "key not found")
+			return
+		}
+		var req azkeys.KeyOperationParameters
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "BadParameter", err.Error())
+			return
+		}
+		block, _ := aes.NewCipher(key)
+		aead, _ := cipher.NewGCM(block)
+		result := azkeys.KeyOperationResult{KID: to.Ptr(azkeys.ID(h.kid(r, segments[1])))}
+		if segments[len(segments)-1] == "encrypt" {
+			result.IV = make([]byte, aead.NonceSize())
+			rand.Read(result.IV)
+			sealed := aead.Seal(nil, result.IV, req.Value, req.AdditionalAuthenticatedData)
+			result.Result, result.AuthenticationTag = sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+		} else {
+			plaintext, err := aead.Open(nil, req.IV, append(req.Value, req.AuthenticationTag...), req.AdditionalAuthenticatedData)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "BadParameter", "decryption failed")
+				return
+			}
+			result.Result = plaintext
+		}
+		writeJSON(w, http.StatusOK, result)
+
+	case r.Method == http.MethodDelete && len(segments) == 2 && segments[0] == "keys":
+		key, ok := h.keys[segments[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "key not found")
+			return
+		}
+		delete(h.keys, segments[1])
+		h.deleted[segments[1]] = key
+		writeJSON(w, http.StatusOK, map[string]any{"key": map[string]any{"kid": h.kid(r, segments[1])}})
+
+	case r.Method == http.MethodDelete && len(segments) == 2 && segments[0] == "deletedkeys":
+		if _, ok := h.deleted[segments[1]]; !ok {
+			writeError(w, http.StatusNotFound, "KeyNotFound", "deleted key not found")
+			return
+		}
+		if h.purgeProtection {
+			writeError(w, http.StatusForbidden, "Forbidden", "Operation 'purge' is not allowed because purge protection is enabled")
+			return
+		}
+		delete(h.deleted, segments[1])
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusBadRequest, "BadParameter", "unsupported operation")
+	}
+}
+
+func (h *fakeManagedHSM) kid(r *http.Request, name string) string {
+	return "https://" + r.Host + "/keys/" + name + "/" + strconv.Itoa(h.version)
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientCertificate is a TLS client certificate that is reloaded
+// from its certificate and private key file whenever one of them
+// changes.
+//
+// On Cloud Foundry, an application instance can authenticate to
+// CredHub with its instance identity credentials. The files at
+// $CF_INSTANCE_CERT and $CF_INSTANCE_KEY are replaced about every
+// 24 hours. Hence, a certificate loaded once at startup expires
+// while KES is running.
+type clientCertificate struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// loadClientCertificate loads the client certificate from
+// the given certificate and private key file.
+func loadClientCertificate(certFile, keyFile string) (*clientCertificate, error) {
+	c := &clientCertificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetClientCertificate returns the current client certificate.
+// It can be used as tls.Config.GetClientCertificate.
+//
+// If one of the files has changed, it reloads the certificate
+// first. If reloading fails, for example because only one of the
+// files has been replaced yet, it returns the previous certificate
+// and tries again on the next TLS handshake.
+func (c *clientCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.modified() {
+		c.reload()
+	}
+	certificate := c.certificate
+	return &certificate, nil
+}
+
+// modified reports whether the certificate or private key
+// file has changed since the certificate has been loaded.
+func (c *clientCertificate) modified() bool {
+	certStat, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keyStat, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certStat.ModTime().Equal(c.certModTime) || !keyStat.ModTime().Equal(c.keyModTime)
+}
+
+// reload reads the certificate and private key file. The files
+// are stat'ed before they are read such that a file replaced
+// while reading is detected as modified again.
+func (c *clientCertificate) reload() error {
+	certStat, err := os.Stat(c.certFile)
+	if err != nil {
+		return fmt.Errorf("credhub config: failed to load client certificate: %v", err)
+	}
+	keyStat, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("credhub config: failed to load client private key: %v", err)
+	}
+
+	certPEM, err := os.ReadFile(c.certFile)
+	if err != nil {
+		return fmt.Errorf("credhub config: failed to load client certificate: %v", err)
+	}
+	keyPEM, err := os.ReadFile(c.keyFile)
+	if err != nil {
+		return fmt.Errorf("credhub config: failed to load client private key: %v", err)
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("credhub config: invalid client certificate: %v", err)
+	}
+
+	c.certificate = certificate
+	c.certModTime = certStat.ModTime()
+	c.keyModTime = keyStat.ModTime()
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+func TestClientCertificate_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "instance.crt"), filepath.Join(dir, "instance.key")
+	modTime := time.Now().Add(-time.Hour)
+
+	certPEM, keyPEM := generateCertificatePEM(t)
+	writeFile(t, certFile, certPEM, modTime)
+	writeFile(t, keyFile, keyPEM, modTime)
+
+	clientCert, err := loadClientCertificate(certFile, keyFile)
+	assertNoError(t, err)
+	cert, err := clientCert.GetClientCertificate(nil)
+	assertNoError(t, err)
+	initial := cert.Certificate[0]
+
+	t.Run("keep certificate while only one file has been replaced", func(t *testing.T) {
+		certPEM, keyPEM = generateCertificatePEM(t)
+		modTime = modTime.Add(time.Minute)
+		writeFile(t, certFile, certPEM, modTime)
+
+		cert, err := clientCert.GetClientCertificate(nil)
+		assertNoError(t, err)
+		assertEqualBytes(t, initial, cert.Certificate[0])
+	})
+	t.Run("reload certificate once both files have been replaced", func(t *testing.T) {
+		writeFile(t, keyFile, keyPEM, modTime)
+
+		cert, err := clientCert.GetClientCertificate(nil)
+		assertNoError(t, err)
+		if bytes.Equal(initial, cert.Certificate[0]) {
+			t.Fatal("client certificate has not been reloaded")
+		}
+	})
+}
+
+func generateCertificatePEM(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := kes.GenerateAPIKey(nil)
+	assertNoError(t, err)
+	cert, err := kes.GenerateCertificate(key)
+	assertNoError(t, err)
+	privateKey, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assertNoError(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKey})
+	return certPEM, keyPEM
+}
+
+func writeFile(t *testing.T, filename string, data []byte, modTime time.Time) {
+	assertNoError(t, os.WriteFile(filename, data, 0o600))
+	assertNoError(t, os.Chtimes(filename, modTime, modTime))
+}
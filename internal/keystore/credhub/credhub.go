@@ -15,6 +15,8 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path"
+	"slices"
 	"strings"
 	"time"
 
@@ -40,6 +42,78 @@ type Config struct {
 	ServerCaCertFilePath      string // Path to the CA certificate file for verifying the CredHub server's certificate.
 	Namespace                 string // A namespace within CredHub where credentials are stored.
 	ForceBase64ValuesEncoding bool   // If set to true, forces encoding of all the values as base64 before storage.
+
+	// CreateLockTTL, if > 0, makes Create acquire a lease on the key name
+	// that is shared by all KES servers using the same namespace. A lease
+	// of a KES server that fails to release it expires after CreateLockTTL.
+	CreateLockTTL time.Duration
+
+	// Permissions are granted on each credential KES creates. They
+	// restrict access to the key material to the listed actors instead
+	// of relying on permissions of the namespace. CredHub only enforces
+	// permissions if its ACLs are enabled.
+	Permissions []Permission
+
+	// RequestTimeout limits the duration of every request to CredHub,
+	// including reading the response. A request fails once either its
+	// context deadline or the RequestTimeout is exceeded, whatever comes
+	// first. If 0, DefaultRequestTimeout is used.
+	RequestTimeout time.Duration
+
+	// StatusTimeout limits the duration of a Status health check.
+	// It is usually shorter than the RequestTimeout such that an
+	// unresponsive CredHub is detected quickly. If 0,
+	// DefaultStatusTimeout is used.
+	StatusTimeout time.Duration
+
+	// MaxResponseSize limits the size of every response body, after
+	// decompression, in bytes. A request fails once CredHub sends a
+	// larger response, e.g. when listing a pathological number of
+	// credentials. If 0, DefaultMaxResponseSize is used.
+	MaxResponseSize int64
+
+	// DisableCompression, if true, prevents requesting gzip compressed
+	// responses from CredHub. By default, responses are compressed to
+	// reduce the bandwidth of large list operations.
+	DisableCompression bool
+}
+
+const (
+	// DefaultRequestTimeout is the default Config.RequestTimeout.
+	DefaultRequestTimeout = 15 * time.Second
+
+	// DefaultStatusTimeout is the default Config.StatusTimeout.
+	DefaultStatusTimeout = 3 * time.Second
+
+	// DefaultMaxResponseSize is the default Config.MaxResponseSize.
+	DefaultMaxResponseSize = 16 << 20 // 16 MiB
+)
+
+// requestTimeout returns the RequestTimeout or DefaultRequestTimeout
+// if no RequestTimeout is set.
+func (c *Config) requestTimeout() time.Duration {
+	if c.RequestTimeout > 0 {
+		return c.RequestTimeout
+	}
+	return DefaultRequestTimeout
+}
+
+// statusTimeout returns the StatusTimeout or DefaultStatusTimeout
+// if no StatusTimeout is set.
+func (c *Config) statusTimeout() time.Duration {
+	if c.StatusTimeout > 0 {
+		return c.StatusTimeout
+	}
+	return DefaultStatusTimeout
+}
+
+// maxResponseSize returns the MaxResponseSize or DefaultMaxResponseSize
+// if no MaxResponseSize is set.
+func (c *Config) maxResponseSize() int64 {
+	if c.MaxResponseSize > 0 {
+		return c.MaxResponseSize
+	}
+	return DefaultMaxResponseSize
 }
 
 // Certs contains the certificates needed for mutual TLS authentication.
@@ -58,6 +132,31 @@ func (c *Config) Validate() (*Certs, error) {
 	if c.Namespace == "" {
 		return certs, errors.New("credhub config: `Namespace` can't be empty")
 	}
+	if c.CreateLockTTL < 0 {
+		return certs, errors.New("credhub config: `CreateLockTTL` can't be negative")
+	}
+	if c.RequestTimeout < 0 {
+		return certs, errors.New("credhub config: `RequestTimeout` can't be negative")
+	}
+	if c.StatusTimeout < 0 {
+		return certs, errors.New("credhub config: `StatusTimeout` can't be negative")
+	}
+	if c.MaxResponseSize < 0 {
+		return certs, errors.New("credhub config: `MaxResponseSize` can't be negative")
+	}
+	for _, permission := range c.Permissions {
+		if permission.Actor == "" {
+			return certs, errors.New("credhub config: permission `Actor` can't be empty")
+		}
+		if len(permission.Operations) == 0 {
+			return certs, fmt.Errorf("credhub config: permission of '%s' has no `Operations`", permission.Actor)
+		}
+		for _, op := range permission.Operations {
+			if !validOperations[op] {
+				return certs, fmt.Errorf("credhub config: permission of '%s' has invalid operation '%s'", permission.Actor, op)
+			}
+		}
+	}
 	if !c.ServerInsecureSkipVerify {
 		if c.ServerCaCertFilePath == "" {
 			return certs, errors.New("credhub config: `ServerCaCertFilePath` can't be empty when `ServerInsecureSkipVerify` is false")
@@ -107,6 +206,8 @@ func (c *Config) validatePemFile(path, name string) (pemBytes, derBytes []byte,
 	return pemBytes, derBlock.Bytes, nil
 }
 
+var _ kes.PurgeableKeyStore = (*Store)(nil) // compiler check
+
 // Store represents a layer that interacts with a CredHub service using HTTP protocol.
 type Store struct {
 	LastError error
@@ -127,10 +228,16 @@ func NewStore(_ context.Context, config *Config) (*Store, error) {
 
 // Status returns the current state of the KeyStore.
 //
+// The health check fails if CredHub does not respond within
+// the Config.StatusTimeout, even if ctx has a later deadline.
+//
 // CredHub "Get Server Status":
 // - https://docs.cloudfoundry.org/api/credhub/version/main/#_get_server_status
 // - `credhub curl -X=GET -p /health`
 func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.statusTimeout())
+	defer cancel()
+
 	uri := "/health"
 	startTime := time.Now()
 	resp := s.client.doRequest(ctx, http.MethodGet, uri, nil)
@@ -155,7 +262,7 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 		return state, fmt.Errorf("CredHub is not UP, status: %s", responseData.Status)
 
 	}
-	return state, fmt.Errorf("the CredHub (%s) is not healthy, status: %s", uri, resp.status)
+	return state, keystore.StatusError(resp.statusCode, fmt.Errorf("the CredHub (%s) is not healthy, status: %s", uri, resp.status))
 }
 
 // Create creates a new entry with the given name if and only
@@ -169,19 +276,77 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 
 func (s *Store) create(ctx context.Context, name string, value []byte, operationID string) error {
 	_, err := s.sfGroup.Do(s.config.Namespace+"/"+name, func() (interface{}, error) {
-		_, err := s.Get(ctx, name)
-		switch {
-		case err == nil:
-			return nil, fmt.Errorf("key '%s' already exists: %w", name, kesdk.ErrKeyExists)
-		case errors.Is(err, kesdk.ErrKeyNotFound):
-			return nil, s.put(ctx, name, value, operationID)
-		default:
+		if err := s.checkNotExists(ctx, name); err != nil {
 			return nil, err
 		}
+
+		// The singleflight group only protects against concurrent
+		// Creates of this KES server. Other KES servers using the
+		// same namespace are excluded by a lease.
+		if s.config.CreateLockTTL > 0 {
+			unlock, err := s.lock(ctx, name, operationID)
+			if err != nil {
+				return nil, err
+			}
+			defer unlock()
+
+			// Another KES server may have created the entry before
+			// we acquired the lease.
+			if err = s.checkNotExists(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.put(ctx, name, value, operationID); err != nil {
+			return nil, err
+		}
+
+		// Don't keep key material that is not restricted as
+		// configured.
+		if err := s.setPermissions(ctx, name); err != nil {
+			s.Delete(context.WithoutCancel(ctx), name)
+			return nil, err
+		}
+		return nil, nil
 	})
 	return err
 }
 
+// checkNotExists returns kes.ErrKeyExists if an entry with the
+// given name exists and nil if no such entry exists.
+func (s *Store) checkNotExists(ctx context.Context, name string) error {
+	_, err := s.Get(ctx, name)
+	switch {
+	case err == nil:
+		return fmt.Errorf("key '%s' already exists: %w", name, kesdk.ErrKeyExists)
+	case errors.Is(err, kesdk.ErrKeyNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// Set replaces the value of an existing entry with the given
+// name. It returns kes.ErrKeyNotFound if no such entry exists.
+//
+// CredHub keeps the previous value as a previous version of the
+// credential.
+func (s *Store) Set(ctx context.Context, name string, value []byte) error {
+	return s.set(ctx, name, value, uuid.New().String())
+}
+
+func (s *Store) set(ctx context.Context, name string, value []byte, operationID string) error {
+	if _, err := s.Get(ctx, name); err != nil {
+		return err
+	}
+	if err := s.put(ctx, name, value, operationID); err != nil {
+		if errors.Is(err, kesdk.ErrKeyExists) {
+			return fmt.Errorf("key '%s' was modified concurrently by other process", name)
+		}
+		return err
+	}
+	return nil
+}
+
 // CredHub "Set a Value Credential":
 // - https://docs.cloudfoundry.org/api/credhub/version/main/#_set_a_value_credential
 // - `credhub curl -X=PUT -p "/api/v1/data" -d='{"name":"/test-namespace/key-1","type":"value","value":"1"}`
@@ -225,7 +390,7 @@ func (s *Store) put(ctx context.Context, name string, value []byte, operationID
 		return nil
 
 	}
-	return fmt.Errorf("failed to put entry (status: %s)", resp.status)
+	return keystore.StatusError(resp.statusCode, fmt.Errorf("failed to put entry (status: %s)", resp.status))
 }
 
 // Delete removes the entry. It may return either no error or
@@ -245,7 +410,7 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 	if resp.statusCode == http.StatusNotFound {
 		return kesdk.ErrKeyNotFound
 	} else if !resp.isStatusCode2xx() {
-		return fmt.Errorf("failed to delete entry: %s", resp.status)
+		return keystore.StatusError(resp.statusCode, fmt.Errorf("failed to delete entry: %s", resp.status))
 	}
 	return nil
 }
@@ -267,7 +432,7 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 	if resp.statusCode == http.StatusNotFound {
 		return nil, kesdk.ErrKeyNotFound
 	} else if !resp.isStatusCode2xx() {
-		return nil, fmt.Errorf("failed to get entry (status: %s)", resp.status)
+		return nil, keystore.StatusError(resp.statusCode, fmt.Errorf("failed to get entry (status: %s)", resp.status))
 	}
 	var responseData struct {
 		Data []struct {
@@ -302,6 +467,14 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 // - https://docs.cloudfoundry.org/api/credhub/version/main/#_find_a_credential_by_name_like
 // - `credhub curl -X=GET -p "/api/v1/data?path=/test-namespace/"`
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.ListFrom(ctx, prefix, "", n)
+}
+
+// ListFrom behaves like List but ignores all key names that are
+// lexicographically smaller than continueAt. CredHub does not
+// support paginated searches. Hence, ListFrom fetches all names
+// with the prefix and skips the ones before continueAt.
+func (s *Store) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
 	pathPrefix := s.config.Namespace + "/"
 	uri := fmt.Sprintf("/api/v1/data?name-like=%s%s", pathPrefix, prefix)
 	resp := s.client.doRequest(ctx, http.MethodGet, uri, nil)
@@ -311,7 +484,7 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 	}
 
 	if !resp.isStatusCode2xx() {
-		return nil, "", fmt.Errorf("failed to list entries (status: %s)", resp.status)
+		return nil, "", keystore.StatusError(resp.statusCode, fmt.Errorf("failed to list entries (status: %s)", resp.status))
 	}
 	var responseData struct {
 		Credentials []struct {
@@ -324,11 +497,97 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 
 	var names []string
 	for _, credential := range responseData.Credentials {
-		names = append(names, strings.TrimPrefix(credential.Name, pathPrefix))
+		name := strings.TrimPrefix(credential.Name, pathPrefix)
+		if strings.HasPrefix(name, lockPrefix) {
+			continue // Skip leases of in-progress Creates
+		}
+		names = append(names, name)
+	}
+	return keystore.ListFrom(names, prefix, continueAt, n)
+}
+
+// Purge deletes all credentials within the namespace whose names,
+// relative to the namespace, match one of the patterns. Patterns use
+// the syntax of path.Match. No patterns match any credential except
+// internal ones, like leases of in-progress Creates or entries of
+// the KES server, whose names start with a hyphen. Internal
+// credentials are only purged if a pattern matches them explicitly.
+//
+// Purge returns the sorted names of the matching credentials. If
+// dryRun is true, it does not delete them. Purge is intended for
+// decommissioning tenants that have their own namespace.
+//
+// CredHub "Find a Credential by Path":
+// - https://docs.cloudfoundry.org/api/credhub/version/main/#_find_a_credential_by_path
+// - `credhub curl -X=GET -p "/api/v1/data?path=/test-namespace/"`
+func (s *Store) Purge(ctx context.Context, patterns []string, dryRun bool) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+	}
+
+	pathPrefix := s.config.Namespace + "/"
+	uri := fmt.Sprintf("/api/v1/data?path=%s", pathPrefix)
+	resp := s.client.doRequest(ctx, http.MethodGet, uri, nil)
+	defer resp.closeResource()
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	if !resp.isStatusCode2xx() {
+		return nil, keystore.StatusError(resp.statusCode, fmt.Errorf("failed to find entries (status: %s)", resp.status))
+	}
+	var responseData struct {
+		Credentials []struct {
+			Name string `json:"name"`
+		} `json:"credentials"`
+	}
+	if err := json.NewDecoder(resp.body).Decode(&responseData); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, credential := range responseData.Credentials {
+		name, ok := strings.CutPrefix(credential.Name, pathPrefix)
+		if !ok || name == "" || !matchAny(patterns, name) {
+			continue
+		}
+		names = append(names, name)
 	}
-	resNames, resPrefix, err := keystore.List(names, prefix, n)
-	return resNames, resPrefix, err
+	slices.Sort(names)
+	names = slices.Compact(names)
+	if dryRun {
+		return names, nil
+	}
+
+	for i, name := range names {
+		if err := s.Delete(ctx, name); err != nil && !errors.Is(err, kesdk.ErrKeyNotFound) {
+			return names[:i], err
+		}
+	}
+	return names, nil
+}
+
+// matchAny reports whether name matches one of the patterns.
+// No patterns match any name that does not start with a hyphen.
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return !strings.HasPrefix(name, "-")
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Close  terminate or release resources that were opened or acquired.
-func (s *Store) Close() error { return nil }
+// It closes any idle connections to the CredHub server.
+func (s *Store) Close() error {
+	if c, ok := s.client.(*httpMTLSClient); ok {
+		c.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
@@ -2,16 +2,20 @@ package credhub
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/keystore"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -43,6 +47,117 @@ func TestStore_MTLS(t *testing.T) {
 	})
 }
 
+func TestStore_Timeout(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-hung:
+		}
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	newStore := func(config *Config) *Store {
+		config.BaseURL = server.URL
+		config.Namespace = testNamespace
+		config.ServerInsecureSkipVerify = true
+		store, err := NewStore(context.Background(), config)
+		assertNoError(t, err)
+		return store
+	}
+	assertTimeout := func(t *testing.T, f func() error, timeout time.Duration) {
+		start := time.Now()
+		err := f()
+		assertError(t, err)
+		if d := time.Since(start); d > timeout {
+			t.Fatalf("request took '%v' - want less than '%v'", d, timeout)
+		}
+		if code := keystore.ErrorCode(err); code != keystore.CodeUnreachable {
+			t.Fatalf("expect error code '%s', got '%s'", keystore.CodeUnreachable, code)
+		}
+	}
+
+	t.Run("request timeout", func(t *testing.T) {
+		store := newStore(&Config{RequestTimeout: 50 * time.Millisecond})
+		assertTimeout(t, func() error {
+			_, err := store.Get(context.Background(), "key-1")
+			return err
+		}, 5*time.Second)
+	})
+
+	t.Run("context deadline before request timeout", func(t *testing.T) {
+		store := newStore(&Config{RequestTimeout: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assertTimeout(t, func() error {
+			_, err := store.Get(ctx, "key-1")
+			return err
+		}, 5*time.Second)
+	})
+
+	t.Run("status timeout", func(t *testing.T) {
+		store := newStore(&Config{RequestTimeout: time.Hour, StatusTimeout: 50 * time.Millisecond})
+		assertTimeout(t, func() error {
+			_, err := store.Status(context.Background())
+			return err
+		}, 5*time.Second)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		store := newStore(&Config{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := store.Get(ctx, "key-1")
+		assertErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestStore_Compression(t *testing.T) {
+	const value = "Hello World"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := fmt.Sprintf(`{"data":[{"value":"%s"}]}`, value)
+		if r.Header.Get(acceptEncoding) != gzipEncoding {
+			io.WriteString(w, body)
+			return
+		}
+		w.Header().Set(contentEncoding, gzipEncoding)
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, body)
+		gz.Close()
+	}))
+	defer server.Close()
+
+	newStore := func(config *Config) *Store {
+		config.BaseURL = server.URL
+		config.Namespace = testNamespace
+		config.ServerInsecureSkipVerify = true
+		store, err := NewStore(context.Background(), config)
+		assertNoError(t, err)
+		return store
+	}
+
+	t.Run("gzip response", func(t *testing.T) {
+		v, err := newStore(&Config{}).Get(context.Background(), "key-1")
+		assertNoError(t, err)
+		assertEqualBytes(t, []byte(value), v)
+	})
+
+	t.Run("compression disabled", func(t *testing.T) {
+		v, err := newStore(&Config{DisableCompression: true}).Get(context.Background(), "key-1")
+		assertNoError(t, err)
+		assertEqualBytes(t, []byte(value), v)
+	})
+
+	t.Run("response exceeds max size", func(t *testing.T) {
+		_, err := newStore(&Config{MaxResponseSize: 16}).Get(context.Background(), "key-1")
+		assertErrorIs(t, err, errResponseTooLarge)
+
+		_, err = newStore(&Config{MaxResponseSize: 16, DisableCompression: true}).Get(context.Background(), "key-1")
+		assertErrorIs(t, err, errResponseTooLarge)
+	})
+}
+
 // `credhub curl -X=GET -p /health`
 func TestStore_Status(t *testing.T) {
 	fakeClient, store := NewFakeStore()
@@ -161,6 +276,43 @@ func TestStore_Create(t *testing.T) {
 	})
 }
 
+func TestStore_Set(t *testing.T) {
+	fakeClient, store := NewFakeStore()
+
+	t.Run("set element that doesn't exist", func(t *testing.T) {
+		fakeClient.respStatusCodes["GET"] = 404
+		const key = "key"
+		const value = "string-value"
+		err := store.Set(context.Background(), key, []byte(value))
+		assertErrorIs(t, err, kes.ErrKeyNotFound)
+		assertRequest(t, fakeClient, "GET", fmt.Sprintf("/api/v1/data?current=true&name=%s/%s", testNamespace, key))
+	})
+	t.Run("set element that exists", func(t *testing.T) {
+		fakeClient.respStatusCodes["GET"] = 200
+		fakeClient.respStatusCodes["PUT"] = 200
+		const key = "key"
+		const value = "string-value"
+		const operationID = "test"
+		fakeClient.respBody = fmt.Sprintf(`{"data":[{"value":"something"}],"value":"%s","metadata":{"operation_id":"%s"}}`, value, operationID)
+		err := store.set(context.Background(), key, []byte(value), operationID)
+		assertNoError(t, err)
+		assertRequestWithJSONBody(t, fakeClient, "PUT", "/api/v1/data",
+			fmt.Sprintf(`{"name":"%s/%s","type":"value","value":"%s","metadata":{"operation_id":"%s"}}`, testNamespace, key, value, operationID))
+	})
+	t.Run("set element modified concurrently", func(t *testing.T) {
+		fakeClient.respStatusCodes["GET"] = 200
+		fakeClient.respStatusCodes["PUT"] = 200
+		const key = "key"
+		const value = "string-value"
+		fakeClient.respBody = `{"data":[{"value":"something"}],"value":"other-value","metadata":{"operation_id":"other"}}`
+		err := store.set(context.Background(), key, []byte(value), "test")
+		assertError(t, err)
+		if errors.Is(err, kes.ErrKeyExists) {
+			t.Fatalf("expected error other than '%v'", kes.ErrKeyExists)
+		}
+	})
+}
+
 // `credhub curl -X=GET -p "/api/v1/data?name=/test-namespace/key-4&current=true"`
 func TestStore_Get(t *testing.T) {
 	fakeClient, store := NewFakeStore()
@@ -294,6 +446,75 @@ func TestStore_List(t *testing.T) {
 		assertEqualComparable(t, "prefix-key-2", list[1])
 		assertEqualComparable(t, "prefix-key-3", prefix)
 	})
+
+	t.Run("continues list at name", func(t *testing.T) {
+		fakeClient.respStatusCodes["GET"] = 200
+		fakeClient.respBody = `{"credentials":[
+			{"name":"/test-namespace/prefix-key-3"},
+			{"name":"/test-namespace/prefix-key-1"},
+			{"name":"/test-namespace/prefix-key-2"}
+		]}`
+		list, prefix, err := store.ListFrom(context.Background(), "prefix", "prefix-key-2", 2)
+		assertNoError(t, err)
+		assertEqualComparable(t, 2, len(list))
+		assertEqualComparable(t, "prefix-key-2", list[0])
+		assertEqualComparable(t, "prefix-key-3", list[1])
+		assertEqualComparable(t, "", prefix)
+	})
+}
+
+// `credhub curl -X=GET -p "/api/v1/data?path=/test-namespace/"`
+func TestStore_Purge(t *testing.T) {
+	fakeClient, store := NewFakeStore()
+	fakeClient.respStatusCodes["GET"] = 200
+	fakeClient.respStatusCodes["DELETE"] = 200
+	fakeClient.respBody = `{"credentials":[
+		{"name":"/test-namespace/tenant-2-key"},
+		{"name":"/test-namespace/tenant-1-key-2"},
+		{"name":"/test-namespace/-lock-tenant-1-key-3"},
+		{"name":"/test-namespace/tenant-1-key-1"}
+	]}`
+
+	t.Run("find by path request contract", func(t *testing.T) {
+		names, err := store.Purge(context.Background(), []string{"tenant-1-*"}, true)
+		assertNoError(t, err)
+		assertRequest(t, fakeClient, "GET", fmt.Sprintf("/api/v1/data?path=%s", testNamespace+"/"))
+		assertEqualComparable(t, 2, len(names))
+		assertEqualComparable(t, "tenant-1-key-1", names[0])
+		assertEqualComparable(t, "tenant-1-key-2", names[1])
+	})
+
+	t.Run("deletes matching credentials", func(t *testing.T) {
+		names, err := store.Purge(context.Background(), []string{"tenant-1-*"}, false)
+		assertNoError(t, err)
+		assertEqualComparable(t, 2, len(names))
+		assertRequest(t, fakeClient, "DELETE", fmt.Sprintf("/api/v1/data?name=%s/%s", testNamespace, "tenant-1-key-2"))
+	})
+
+	t.Run("no patterns match all but internal credentials", func(t *testing.T) {
+		names, err := store.Purge(context.Background(), nil, true)
+		assertNoError(t, err)
+		assertEqualComparable(t, 3, len(names))
+		assertEqualComparable(t, "tenant-1-key-1", names[0])
+	})
+
+	t.Run("explicit patterns match internal credentials", func(t *testing.T) {
+		names, err := store.Purge(context.Background(), []string{"-lock-*"}, true)
+		assertNoError(t, err)
+		assertEqualComparable(t, 1, len(names))
+		assertEqualComparable(t, "-lock-tenant-1-key-3", names[0])
+	})
+
+	t.Run("returns error for invalid pattern", func(t *testing.T) {
+		_, err := store.Purge(context.Background(), []string{"["}, true)
+		assertError(t, err)
+	})
+
+	t.Run("returns error for non-200 status", func(t *testing.T) {
+		fakeClient.respStatusCodes["GET"] = 500
+		_, err := store.Purge(context.Background(), nil, true)
+		assertError(t, err)
+	})
 }
 
 // === tools:
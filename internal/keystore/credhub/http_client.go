@@ -1,11 +1,15 @@
 package credhub
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 type httpResponse struct {
@@ -13,6 +17,7 @@ type httpResponse struct {
 	status     string
 	body       io.ReadCloser
 	err        error
+	cancel     context.CancelFunc // Releases the request's timeout, if any
 }
 
 func newHTTPResponseError(err error) httpResponse {
@@ -27,6 +32,9 @@ func (c *httpResponse) closeResource() {
 	if c.body != nil {
 		_ = c.body.Close()
 	}
+	if c.cancel != nil {
+		c.cancel()
+	}
 }
 
 type httpClient interface {
@@ -34,8 +42,11 @@ type httpClient interface {
 }
 
 type httpMTLSClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	timeout     time.Duration
+	maxBodySize int64
+	compression bool
 }
 
 func newHTTPMTLSClient(config *Config) (httpClient, error) {
@@ -53,24 +64,105 @@ func newHTTPMTLSClient(config *Config) (httpClient, error) {
 		tlsConfig.RootCAs = caCertPool
 	}
 	if config.EnableMutualTLS {
-		// Setup mutual TLS - client
-		tlsConfig.Certificates = []tls.Certificate{certs.ClientKeyPair}
+		// Setup mutual TLS - client. The certificate is reloaded once
+		// it changes on disk, e.g. when CF rotates the instance identity.
+		clientCert, err := loadClientCertificate(config.ClientCertFilePath, config.ClientKeyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = clientCert.GetClientCertificate
 	}
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	// Compressed responses are decompressed by doRequest instead of
+	// the transport such that the response size limit applies to the
+	// decompressed body.
+	transport := &http.Transport{TLSClientConfig: tlsConfig, DisableCompression: true}
 	httpClient := &http.Client{Transport: transport}
-	return &httpMTLSClient{baseURL: config.BaseURL, httpClient: httpClient}, nil
+	return &httpMTLSClient{
+		baseURL:     config.BaseURL,
+		httpClient:  httpClient,
+		timeout:     config.requestTimeout(),
+		maxBodySize: config.maxResponseSize(),
+		compression: !config.DisableCompression,
+	}, nil
 }
 
+// doRequest sends a request to CredHub. The request, including reading
+// the response body, is aborted once the ctx deadline or the client's
+// request timeout is exceeded. The timeout is released when the
+// response gets closed.
+//
+// Unless compression is disabled, doRequest requests a gzip compressed
+// response and decompresses it. Reading more than the client's maximum
+// body size from the (decompressed) response body fails.
 func (s *httpMTLSClient) doRequest(ctx context.Context, method, uri string, body io.Reader) httpResponse {
+	if err := ctx.Err(); err != nil {
+		return newHTTPResponseError(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+
 	url := s.baseURL + uri
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
+		cancel()
 		return newHTTPResponseError(err)
 	}
 	req.Header.Set(contentType, applicationJSON)
+	if s.compression {
+		req.Header.Set(acceptEncoding, gzipEncoding)
+	}
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
+		cancel()
 		return newHTTPResponseError(err)
 	}
-	return httpResponse{statusCode: resp.StatusCode, status: resp.Status, body: resp.Body, err: nil}
+
+	var respBody io.Reader = resp.Body
+	if resp.Header.Get(contentEncoding) == gzipEncoding {
+		if respBody, err = gzip.NewReader(resp.Body); err != nil {
+			resp.Body.Close()
+			cancel()
+			return newHTTPResponseError(fmt.Errorf("credhub: invalid gzip response: %v", err))
+		}
+	}
+	respBody = &limitedReader{r: respBody, n: s.maxBodySize}
+	return httpResponse{statusCode: resp.StatusCode, status: resp.Status, body: readCloser{respBody, resp.Body}, err: nil, cancel: cancel}
+}
+
+const (
+	acceptEncoding  = "Accept-Encoding"
+	contentEncoding = "Content-Encoding"
+	gzipEncoding    = "gzip"
+)
+
+// limitedReader reads from r but fails once more than n
+// bytes are read. Unlike an io.LimitedReader, it does not
+// truncate responses silently.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return n + int(l.n), errResponseTooLarge
+	}
+	return n, err
+}
+
+// errResponseTooLarge is returned when reading more than the maximum
+// response size from a CredHub response body.
+var errResponseTooLarge = errors.New("credhub: response body exceeds size limit")
+
+// readCloser reads from a (decompressed) response body and
+// closes the underlying response body.
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
@@ -0,0 +1,116 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// lockPrefix is the name prefix of lease credentials. Names of
+// valid keys never start with a hyphen. Hence, lease names never
+// conflict with key names.
+const lockPrefix = "-lock-"
+
+// lock acquires a lease on the given key name that is shared by all
+// KES servers using the same CredHub namespace. It returns a function
+// that releases the lease.
+//
+// CredHub has no compare-and-swap or create-only operation. However,
+// it keeps all versions of a credential and records their creation
+// time. Every KES server adds a new version to the lease credential
+// and the oldest version that has not expired yet holds the lease.
+// All other KES servers fail with kes.ErrKeyExists since they try to
+// create a key concurrently.
+//
+// CredHub timestamps the versions. Hence, the expiry does not depend
+// on the clocks of the KES servers.
+func (s *Store) lock(ctx context.Context, name, operationID string) (func(), error) {
+	lockName := lockPrefix + name
+	if err := s.put(ctx, lockName, []byte(operationID), operationID); err != nil {
+		return nil, err
+	}
+	unlock := func() { s.Delete(context.WithoutCancel(ctx), lockName) }
+
+	// Only the lease holder may delete the lease credential since
+	// deleting it removes the versions of all KES servers. If we
+	// cannot tell whether we hold the lease, our version remains
+	// and expires after the CreateLockTTL.
+	versions, err := s.lockVersions(ctx, lockName)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquiredAt time.Time
+	for _, v := range versions {
+		if v.Metadata.OperationID == operationID {
+			acquiredAt = v.CreatedAt
+			break
+		}
+	}
+	if acquiredAt.IsZero() {
+		// Another KES server has created the key and released
+		// the lease while we have been acquiring it.
+		return nil, fmt.Errorf("key '%s' is created concurrently by another process: %w", name, kesdk.ErrKeyExists)
+	}
+
+	// Versions created at the same time are ordered by their
+	// operation ID such that at most one KES server holds the lease.
+	holder, heldSince := operationID, acquiredAt
+	for _, v := range versions {
+		if acquiredAt.Sub(v.CreatedAt) >= s.config.CreateLockTTL {
+			continue // Lease has expired
+		}
+		if v.CreatedAt.Before(heldSince) || (v.CreatedAt.Equal(heldSince) && v.Metadata.OperationID < holder) {
+			holder, heldSince = v.Metadata.OperationID, v.CreatedAt
+		}
+	}
+	if holder != operationID {
+		// Don't release the lease. It's held by another KES server.
+		return nil, fmt.Errorf("key '%s' is created concurrently by another process: %w", name, kesdk.ErrKeyExists)
+	}
+	return unlock, nil
+}
+
+// lockVersion is a version of a lease credential.
+type lockVersion struct {
+	CreatedAt time.Time `json:"version_created_at"`
+	Metadata  struct {
+		OperationID string `json:"operation_id"`
+	} `json:"metadata"`
+}
+
+// lockVersions returns all versions of the lease credential.
+//
+// CredHub "Get a Credential by Name":
+// - https://docs.cloudfoundry.org/api/credhub/version/main/#_get_a_credential_by_name
+// - `credhub curl -X=GET -p "/api/v1/data?name=/test-namespace/-lock-key-1"`
+func (s *Store) lockVersions(ctx context.Context, lockName string) ([]lockVersion, error) {
+	uri := fmt.Sprintf("/api/v1/data?name=%s/%s", s.config.Namespace, lockName)
+	resp := s.client.doRequest(ctx, http.MethodGet, uri, nil)
+	defer resp.closeResource()
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	if resp.statusCode == http.StatusNotFound {
+		return nil, nil
+	} else if !resp.isStatusCode2xx() {
+		return nil, keystore.StatusError(resp.statusCode, fmt.Errorf("failed to get lease (status: %s)", resp.status))
+	}
+	var responseData struct {
+		Data []lockVersion `json:"data"`
+	}
+	if err := json.NewDecoder(resp.body).Decode(&responseData); err != nil {
+		return nil, err
+	}
+	return responseData.Data, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestStore_CreateLock(t *testing.T) {
+	credhub := &fakeCredHub{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	store := &Store{
+		config: &Config{Namespace: testNamespace, CreateLockTTL: 30 * time.Second},
+		client: credhub,
+	}
+
+	t.Run("create element", func(t *testing.T) {
+		err := store.create(context.Background(), "key-1", []byte("value"), "op-1")
+		assertNoError(t, err)
+		if _, ok := credhub.credentials[testNamespace+"/"+lockPrefix+"key-1"]; ok {
+			t.Fatal("lease has not been released")
+		}
+		value, err := store.Get(context.Background(), "key-1")
+		assertNoError(t, err)
+		assertEqualBytes(t, []byte("value"), value)
+	})
+	t.Run("create element while lease is held", func(t *testing.T) {
+		credhub.add(testNamespace+"/"+lockPrefix+"key-2", "op-other")
+		credhub.now = credhub.now.Add(10 * time.Second)
+
+		err := store.create(context.Background(), "key-2", []byte("value"), "op-2")
+		assertErrorIs(t, err, kes.ErrKeyExists)
+		_, err = store.Get(context.Background(), "key-2")
+		assertErrorIs(t, err, kes.ErrKeyNotFound)
+	})
+	t.Run("create element after lease expired", func(t *testing.T) {
+		credhub.now = credhub.now.Add(30 * time.Second)
+
+		err := store.create(context.Background(), "key-2", []byte("value"), "op-3")
+		assertNoError(t, err)
+	})
+	t.Run("failed lease lookup keeps lease of holder", func(t *testing.T) {
+		lockName := testNamespace + "/" + lockPrefix + "key-4"
+		credhub.add(lockName, "op-replica-a") // Replica A holds the lease
+		credhub.failLockReads = 1
+
+		err := store.create(context.Background(), "key-4", []byte("value"), "op-replica-b")
+		if err == nil {
+			t.Fatal("create succeeded although the lease lookup failed")
+		}
+		versions, ok := credhub.credentials[lockName]
+		if !ok {
+			t.Fatal("lease of replica A has been deleted")
+		}
+		if holder := versions[len(versions)-1].Metadata.OperationID; holder != "op-replica-a" {
+			t.Fatalf("lease is held by '%s' - want '%s'", holder, "op-replica-a")
+		}
+		delete(credhub.credentials, lockName)
+	})
+	t.Run("list hides leases", func(t *testing.T) {
+		credhub.add(testNamespace+"/"+lockPrefix+"key-3", "op-other")
+
+		names, _, err := store.List(context.Background(), "", -1)
+		assertNoError(t, err)
+		assertEqualComparable(t, 2, len(names))
+	})
+}
+
+// fakeCredHub is an in-memory CredHub that keeps all versions
+// of value credentials. It implements the subset of the REST
+// API used by the Store.
+type fakeCredHub struct {
+	mu          sync.Mutex
+	now         time.Time
+	credentials map[string][]fakeCredential  // newest version first
+	permissions map[string]permissionRequest // by UUID
+
+	failLockReads int // Number of lease lookups that fail
+}
+
+type fakeCredential struct {
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"version_created_at"`
+	Metadata  struct {
+		OperationID string `json:"operation_id"`
+	} `json:"metadata"`
+}
+
+func (c *fakeCredHub) add(name, operationID string) fakeCredential {
+	if c.credentials == nil {
+		c.credentials = map[string][]fakeCredential{}
+	}
+	credential := fakeCredential{Value: operationID, CreatedAt: c.now}
+	credential.Metadata.OperationID = operationID
+	c.credentials[name] = append([]fakeCredential{credential}, c.credentials[name]...)
+	return credential
+}
+
+func (c *fakeCredHub) doRequest(_ context.Context, method, uri string, body io.Reader) httpResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return httpResponse{err: err}
+	}
+	query := u.Query()
+	name := query.Get("name")
+	if strings.HasPrefix(u.Path, "/api/v2/permissions") {
+		return c.doPermissionRequest(method, u, body)
+	}
+	switch {
+	case method == http.MethodPut:
+		var req struct {
+			Name     string `json:"name"`
+			Value    string `json:"value"`
+			Metadata struct {
+				OperationID string `json:"operation_id"`
+			} `json:"metadata"`
+		}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return fakeResponse(http.StatusBadRequest, nil)
+		}
+		credential := c.add(req.Name, req.Metadata.OperationID)
+		credential.Value = req.Value
+		c.credentials[req.Name][0] = credential
+		return fakeResponse(http.StatusOK, credential)
+
+	case method == http.MethodGet && query.Has("name-like"):
+		var resp struct {
+			Credentials []struct {
+				Name string `json:"name"`
+			} `json:"credentials"`
+		}
+		for name := range c.credentials {
+			if strings.HasPrefix(name, query.Get("name-like")) {
+				resp.Credentials = append(resp.Credentials, struct {
+					Name string `json:"name"`
+				}{Name: name})
+			}
+		}
+		return fakeResponse(http.StatusOK, resp)
+
+	case method == http.MethodGet && c.failLockReads > 0 && strings.HasPrefix(name, testNamespace+"/"+lockPrefix):
+		c.failLockReads--
+		return fakeResponse(http.StatusInternalServerError, nil)
+
+	case method == http.MethodGet:
+		versions, ok := c.credentials[name]
+		if !ok {
+			return fakeResponse(http.StatusNotFound, nil)
+		}
+		if query.Get("current") == "true" {
+			versions = versions[:1]
+		}
+		return fakeResponse(http.StatusOK, map[string]any{"data": versions})
+
+	case method == http.MethodDelete:
+		if _, ok := c.credentials[name]; !ok {
+			return fakeResponse(http.StatusNotFound, nil)
+		}
+		delete(c.credentials, name)
+		return fakeResponse(http.StatusNoContent, nil)
+	default:
+		return fakeResponse(http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (c *fakeCredHub) doPermissionRequest(method string, u *url.URL, body io.Reader) httpResponse {
+	if c.permissions == nil {
+		c.permissions = map[string]permissionRequest{}
+	}
+	switch method {
+	case http.MethodPost, http.MethodPut:
+		var req permissionRequest
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return fakeResponse(http.StatusBadRequest, nil)
+		}
+		if method == http.MethodPut {
+			c.permissions[strings.TrimPrefix(u.Path, "/api/v2/permissions/")] = req
+			return fakeResponse(http.StatusOK, req)
+		}
+		for _, p := range c.permissions {
+			if p.Path == req.Path && p.Actor == req.Actor {
+				return fakeResponse(http.StatusConflict, nil)
+			}
+		}
+		c.permissions[uuid.New().String()] = req
+		return fakeResponse(http.StatusCreated, req)
+
+	case http.MethodGet:
+		query := u.Query()
+		for id, p := range c.permissions {
+			if p.Path == query.Get("path") && p.Actor == query.Get("actor") {
+				return fakeResponse(http.StatusOK, map[string]any{"uuid": id})
+			}
+		}
+		return fakeResponse(http.StatusNotFound, nil)
+	default:
+		return fakeResponse(http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func fakeResponse(code int, v any) httpResponse {
+	var body bytes.Buffer
+	if v != nil {
+		json.NewEncoder(&body).Encode(v)
+	}
+	return httpResponse{
+		statusCode: code,
+		status:     http.StatusText(code),
+		body:       &FakeReadCloser{Reader: &body},
+	}
+}
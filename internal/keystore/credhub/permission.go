@@ -0,0 +1,129 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/minio/kes/internal/keystore"
+)
+
+// Permission grants an actor a set of operations on a CredHub
+// credential.
+type Permission struct {
+	// Actor is the CredHub actor, for example the actor of an
+	// mTLS client: "mtls-app:<app-guid>".
+	Actor string
+
+	// Operations are the operations granted to the actor. Valid
+	// operations are: "read", "write", "delete", "read_acl" and
+	// "write_acl".
+	Operations []string
+}
+
+// validOperations contains all CredHub permission operations.
+var validOperations = map[string]bool{
+	"read":      true,
+	"write":     true,
+	"delete":    true,
+	"read_acl":  true,
+	"write_acl": true,
+}
+
+// permissionRequest is the request body of the CredHub
+// permission API.
+type permissionRequest struct {
+	Path       string   `json:"path"`
+	Actor      string   `json:"actor"`
+	Operations []string `json:"operations"`
+}
+
+// setPermissions grants all configured permissions on the
+// credential with the given name.
+func (s *Store) setPermissions(ctx context.Context, name string) error {
+	for _, permission := range s.config.Permissions {
+		req := permissionRequest{
+			Path:       s.config.Namespace + "/" + name,
+			Actor:      permission.Actor,
+			Operations: permission.Operations,
+		}
+		if err := s.addPermission(ctx, req); err != nil {
+			return fmt.Errorf("failed to set permissions of '%s' on key '%s': %v", permission.Actor, name, err)
+		}
+	}
+	return nil
+}
+
+// addPermission adds a permission. CredHub keeps permissions when
+// a credential is deleted. Hence, if the permission exists already,
+// for example since the key has been deleted and created again, it
+// replaces the existing permission.
+//
+// CredHub "Add a Permission":
+// - https://docs.cloudfoundry.org/api/credhub/version/main/#_add_a_permission
+// - `credhub curl -X=POST -p "/api/v2/permissions" -d='{"path":"/test-namespace/key-1","actor":"mtls-app:kes","operations":["read"]}'`
+func (s *Store) addPermission(ctx context.Context, req permissionRequest) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp := s.client.doRequest(ctx, http.MethodPost, "/api/v2/permissions", bytes.NewReader(payload))
+	defer resp.closeResource()
+	if resp.err != nil {
+		return resp.err
+	}
+
+	if resp.statusCode == http.StatusConflict {
+		return s.updatePermission(ctx, req)
+	}
+	if !resp.isStatusCode2xx() {
+		return keystore.StatusError(resp.statusCode, fmt.Errorf("status: %s", resp.status))
+	}
+	return nil
+}
+
+// updatePermission replaces the operations of an existing permission.
+//
+// CredHub "Get a Permission by Path and Actor" and "Update a Permission":
+// - https://docs.cloudfoundry.org/api/credhub/version/main/#_get_a_permission_by_path_and_actor
+// - https://docs.cloudfoundry.org/api/credhub/version/main/#_update_a_permission
+// - `credhub curl -X=GET -p "/api/v2/permissions?path=/test-namespace/key-1&actor=mtls-app:kes"`
+// - `credhub curl -X=PUT -p "/api/v2/permissions/<uuid>" -d='{"path":"/test-namespace/key-1","actor":"mtls-app:kes","operations":["read"]}'`
+func (s *Store) updatePermission(ctx context.Context, req permissionRequest) error {
+	uri := fmt.Sprintf("/api/v2/permissions?path=%s&actor=%s", url.QueryEscape(req.Path), url.QueryEscape(req.Actor))
+	resp := s.client.doRequest(ctx, http.MethodGet, uri, nil)
+	defer resp.closeResource()
+	if resp.err != nil {
+		return resp.err
+	}
+	if !resp.isStatusCode2xx() {
+		return keystore.StatusError(resp.statusCode, fmt.Errorf("status: %s", resp.status))
+	}
+	var permission struct {
+		UUID string `json:"uuid"`
+	}
+	if err := json.NewDecoder(resp.body).Decode(&permission); err != nil {
+		return fmt.Errorf("can't decode response of get permission (status: %s)", resp.status)
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	updateResp := s.client.doRequest(ctx, http.MethodPut, "/api/v2/permissions/"+url.PathEscape(permission.UUID), bytes.NewReader(payload))
+	defer updateResp.closeResource()
+	if updateResp.err != nil {
+		return updateResp.err
+	}
+	if !updateResp.isStatusCode2xx() {
+		return fmt.Errorf("status: %s", updateResp.status)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package credhub
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStore_CreatePermissions(t *testing.T) {
+	credhub := &fakeCredHub{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	store := &Store{
+		config: &Config{
+			Namespace: testNamespace,
+			Permissions: []Permission{
+				{Actor: "mtls-app:kes", Operations: []string{"read", "write", "delete"}},
+			},
+		},
+		client: credhub,
+	}
+
+	t.Run("create element with permissions", func(t *testing.T) {
+		err := store.create(context.Background(), "key-1", []byte("value"), "op-1")
+		assertNoError(t, err)
+		assertEqualComparable(t, 1, len(credhub.permissions))
+		for _, p := range credhub.permissions {
+			assertEqualComparable(t, testNamespace+"/key-1", p.Path)
+			assertEqualComparable(t, "mtls-app:kes", p.Actor)
+			if !slices.Equal(p.Operations, []string{"read", "write", "delete"}) {
+				t.Fatalf("invalid operations: got %v", p.Operations)
+			}
+		}
+	})
+	t.Run("re-create element replaces existing permissions", func(t *testing.T) {
+		assertNoError(t, store.Delete(context.Background(), "key-1"))
+		store.config.Permissions[0].Operations = []string{"read"}
+
+		err := store.create(context.Background(), "key-1", []byte("value"), "op-2")
+		assertNoError(t, err)
+		assertEqualComparable(t, 1, len(credhub.permissions))
+		for _, p := range credhub.permissions {
+			if !slices.Equal(p.Operations, []string{"read"}) {
+				t.Fatalf("invalid operations: got %v", p.Operations)
+			}
+		}
+	})
+}
+
+func TestConfig_ValidatePermissions(t *testing.T) {
+	for i, test := range []struct {
+		Permission Permission
+		ShouldFail bool
+	}{
+		{Permission: Permission{Actor: "mtls-app:kes", Operations: []string{"read", "write_acl"}}},
+		{Permission: Permission{Actor: "", Operations: []string{"read"}}, ShouldFail: true},
+		{Permission: Permission{Actor: "mtls-app:kes"}, ShouldFail: true},
+		{Permission: Permission{Actor: "mtls-app:kes", Operations: []string{"execute"}}, ShouldFail: true},
+	} {
+		config := &Config{
+			BaseURL:                  "https://localhost:8844",
+			Namespace:                testNamespace,
+			ServerInsecureSkipVerify: true,
+			Permissions:              []Permission{test.Permission},
+		}
+		_, err := config.Validate()
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate config: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: validation should have failed", i)
+		}
+	}
+}
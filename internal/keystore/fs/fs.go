@@ -8,11 +8,14 @@
 package fs
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -92,53 +95,197 @@ func (s *Store) Create(_ context.Context, name string, value []byte) error {
 		os.Remove(filename)
 		return err
 	}
+	os.Remove(filename + metadataSuffix) // Remove metadata left over by a key deleted out-of-band
 	return nil
 }
 
-// Get reads the content of the named file within the Conn
+// Set replaces the content of the named file within the Conn
 // directory. It returns kes.ErrKeyNotFound if no such file
 // exists.
-func (s *Store) Get(_ context.Context, name string) ([]byte, error) {
-	const MaxSize = 1 * mem.MiB
-
+//
+// Set writes the value to a temporary file first and then
+// renames it to the named file such that a concurrent Get
+// either reads the previous or the new value.
+func (s *Store) Set(_ context.Context, name string, value []byte) error {
 	if err := validName(name); err != nil {
-		return nil, err
+		return err
 	}
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	file, err := os.Open(filepath.Join(s.dir, name))
-	if errors.Is(err, os.ErrNotExist) {
-		return nil, kesdk.ErrKeyNotFound
+	filename := filepath.Join(s.dir, name)
+	if _, err := os.Stat(filename); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return kesdk.ErrKeyNotFound
+		}
+		return err
 	}
-	if err != nil {
-		return nil, err
+	return s.replace(filename, value)
+}
+
+// SetIf replaces the content of the named file within the Conn
+// directory if and only if its current content is equal to old.
+// It returns kes.ErrConflict if the content differs and
+// kes.ErrKeyNotFound if no such file exists.
+//
+// The comparison and the replacement are atomic with respect to
+// other operations on the same Store. They are not atomic with
+// respect to other processes modifying the directory.
+func (s *Store) SetIf(_ context.Context, name string, value, old []byte) error {
+	if err := validName(name); err != nil {
+		return err
 	}
-	defer file.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	value, err := io.ReadAll(mem.LimitReader(file, MaxSize))
+	filename := filepath.Join(s.dir, name)
+	current, err := s.read(filename)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err = file.Close(); err != nil {
+	if !bytes.Equal(current, old) {
+		return kes.ErrConflict
+	}
+	return s.replace(filename, value)
+}
+
+// Get reads the content of the named file within the Conn
+// directory. It returns kes.ErrKeyNotFound if no such file
+// exists.
+func (s *Store) Get(_ context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
 		return nil, err
 	}
-	return value, nil
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.read(filepath.Join(s.dir, name))
 }
 
 // Delete deletes the named file within the Conn directory if
 // and only if it exists. It returns kes.ErrKeyNotFound if
-// no such file exists.
+// no such file exists. It also deletes the file's metadata.
 func (s *Store) Delete(_ context.Context, name string) error {
 	if err := validName(name); err != nil {
 		return err
 	}
-	switch err := os.Remove(filepath.Join(s.dir, name)); {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	filename := filepath.Join(s.dir, name)
+	switch err := os.Remove(filename); {
 	case errors.Is(err, os.ErrNotExist):
 		return kesdk.ErrKeyNotFound
-	default:
+	case err != nil:
+		return err
+	}
+	if err := os.Remove(filename + metadataSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
+	return nil
+}
+
+// metadataSuffix is the file name suffix of metadata files.
+// Key names cannot contain a '.'. Hence, metadata files are
+// ignored when listing keys.
+const metadataSuffix = ".metadata"
+
+// Metadata returns the metadata of the named file within the Conn
+// directory. It returns an empty EntryMetadata if no metadata is
+// stored for the file.
+func (s *Store) Metadata(_ context.Context, name string) (kes.EntryMetadata, error) {
+	if err := validName(name); err != nil {
+		return kes.EntryMetadata{}, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	b, err := s.read(filepath.Join(s.dir, name) + metadataSuffix)
+	if errors.Is(err, kesdk.ErrKeyNotFound) {
+		return kes.EntryMetadata{}, nil
+	}
+	if err != nil {
+		return kes.EntryMetadata{}, err
+	}
+
+	var m metadata
+	if err = json.Unmarshal(b, &m); err != nil {
+		return kes.EntryMetadata{}, err
+	}
+	return kes.EntryMetadata{
+		Algorithm: m.Algorithm,
+		CreatedAt: m.CreatedAt,
+		CreatedBy: m.CreatedBy,
+		Versions:  m.Versions,
+		RotatedAt: m.RotatedAt,
+
+		Uses:       m.Uses,
+		LastUsedAt: m.LastUsedAt,
+
+		Tags:       m.Tags,
+		Protected:  m.Protected,
+		Operations: m.Operations,
+
+		Grants: m.Grants,
+	}, nil
+}
+
+// SetMetadata stores the metadata of the named file within the
+// Conn directory in a separate metadata file. It returns
+// kes.ErrKeyNotFound if no such file exists.
+func (s *Store) SetMetadata(_ context.Context, name string, m kes.EntryMetadata) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	b, err := json.Marshal(metadata{
+		Algorithm: m.Algorithm,
+		CreatedAt: m.CreatedAt,
+		CreatedBy: m.CreatedBy,
+		Versions:  m.Versions,
+		RotatedAt: m.RotatedAt,
+
+		Uses:       m.Uses,
+		LastUsedAt: m.LastUsedAt,
+
+		Tags:       m.Tags,
+		Protected:  m.Protected,
+		Operations: m.Operations,
+
+		Grants: m.Grants,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	filename := filepath.Join(s.dir, name)
+	if _, err := os.Stat(filename); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return kesdk.ErrKeyNotFound
+		}
+		return err
+	}
+	return s.replace(filename+metadataSuffix, b)
+}
+
+// metadata is the JSON representation of a kes.EntryMetadata.
+type metadata struct {
+	Algorithm string         `json:"algorithm"`
+	CreatedAt time.Time      `json:"created_at"`
+	CreatedBy kesdk.Identity `json:"created_by"`
+	Versions  int            `json:"versions"`
+	RotatedAt time.Time      `json:"rotated_at,omitempty"`
+
+	Uses       uint64    `json:"uses,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+
+	Tags       map[string]string `json:"tags,omitempty"`
+	Protected  bool              `json:"protected,omitempty"`
+	Operations []string          `json:"operations,omitempty"`
+
+	Grants map[kesdk.Identity][]string `json:"grants,omitempty"`
 }
 
 // List returns a new Iterator over the names of
@@ -154,6 +301,12 @@ func (s *Store) Delete(_ context.Context, name string) error {
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty
 func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.ListFrom(ctx, prefix, "", n)
+}
+
+// ListFrom behaves like List but ignores all key names that
+// are lexicographically smaller than continueAt.
+func (s *Store) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
 	dir, err := os.Open(s.dir)
 	if err != nil {
 		return nil, "", err
@@ -164,6 +317,9 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 	if err != nil {
 		return nil, "", err
 	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return validName(name) != nil // Ignore temp. files created by Set
+	})
 	select {
 	case <-ctx.Done():
 		if err := ctx.Err(); err != nil {
@@ -171,13 +327,145 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 		}
 		return nil, "", context.Canceled
 	default:
-		return keystore.List(names, prefix, n)
+		return keystore.ListFrom(names, prefix, continueAt, n)
 	}
 }
 
+// Watch returns a channel that receives an event whenever a file,
+// whose name starts with the given prefix, is created, replaced or
+// deleted. The channel is closed once the context is canceled.
+//
+// Watch polls the directory periodically. Hence, it also detects
+// changes made by other processes, like KES servers sharing the
+// same directory via a network filesystem. Multiple changes of the
+// same file within one poll interval may be reported as one event.
+// Events are dropped if the channel's buffer is full.
+func (s *Store) Watch(ctx context.Context, prefix string) (<-chan kes.KeyStoreEvent, error) {
+	const PollInterval = 1 * time.Second
+
+	files, err := s.scan(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan kes.KeyStoreEvent, 64)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := s.scan(prefix)
+			if err != nil {
+				continue // Retry on next tick, e.g. if the filesystem is temp. unavailable
+			}
+			for name, file := range current {
+				prev, ok := files[name]
+				switch {
+				case !ok:
+					notify(events, kes.EntryCreated, name)
+				case !os.SameFile(prev, file) || !prev.ModTime().Equal(file.ModTime()) || prev.Size() != file.Size():
+					notify(events, kes.EntryUpdated, name)
+				}
+			}
+			for name := range files {
+				if _, ok := current[name]; !ok {
+					notify(events, kes.EntryDeleted, name)
+				}
+			}
+			files = current
+		}
+	}()
+	return events, nil
+}
+
 // Close closes the Store.
 func (s *Store) Close() error { return nil }
 
+// scan returns the file info of all files within the Store
+// directory whose names start with the given prefix.
+func (s *Store) scan(prefix string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if validName(name) != nil || !strings.HasPrefix(name, prefix) {
+			continue // Ignore temp. files created by Set
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Deleted concurrently
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[name] = info
+	}
+	return files, nil
+}
+
+// notify sends an event without blocking. The event is
+// dropped if the channel's buffer is full.
+func notify(events chan<- kes.KeyStoreEvent, typ kes.KeyStoreEventType, name string) {
+	select {
+	case events <- kes.KeyStoreEvent{Type: typ, Name: name}:
+	default:
+	}
+}
+
+// read returns the content of the file. It returns
+// kes.ErrKeyNotFound if no such file exists.
+func (s *Store) read(filename string) ([]byte, error) {
+	const MaxSize = 1 * mem.MiB
+
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, kesdk.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	value, err := io.ReadAll(mem.LimitReader(file, MaxSize))
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// replace writes the value to a temporary file first and then
+// renames it to the given file such that a concurrent read either
+// returns the previous or the new value.
+func (s *Store) replace(filename string, value []byte) error {
+	// Key names cannot contain a '.'. Hence, the temp. file
+	// name cannot conflict with any key or metadata file.
+	tmp := filename + ".tmp"
+	os.Remove(tmp) // Remove any leftovers from a previous Set
+	if err := s.create(tmp, value); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 func (s *Store) create(filename string, value []byte) error {
 	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
 	if err != nil {
@@ -4,7 +4,17 @@
 
 package fs
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
 
 var validNameTests = []struct {
 	Name  string
@@ -31,3 +41,173 @@ func TestValidName(t *testing.T) {
 		}
 	}
 }
+
+func TestSet(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+	if err = store.Set(ctx, "my-key", []byte("Hello")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Set of non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Create(ctx, "my-key", []byte("Hello")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Set(ctx, "my-key", []byte("World")); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	value, err := store.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+	if string(value) != "World" {
+		t.Fatalf("Invalid value: got '%s' - want '%s'", value, "World")
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "my-key" {
+		t.Fatalf("Invalid key listing: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+}
+
+func TestSetIf(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	var _ kes.ConditionalKeyStore = store // compiler check
+
+	ctx := context.Background()
+	if err = store.SetIf(ctx, "my-key", []byte("World"), []byte("Hello")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("SetIf of non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Create(ctx, "my-key", []byte("Hello")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.SetIf(ctx, "my-key", []byte("World"), []byte("Hallo")); !errors.Is(err, kes.ErrConflict) {
+		t.Fatalf("SetIf with outdated value: got '%v' - want '%v'", err, kes.ErrConflict)
+	}
+	if err = store.SetIf(ctx, "my-key", []byte("World"), []byte("Hello")); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	value, err := store.Get(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+	if string(value) != "World" {
+		t.Fatalf("Invalid value: got '%s' - want '%s'", value, "World")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	var _ kes.MetadataKeyStore = store // compiler check
+
+	ctx := context.Background()
+	metadata := kes.EntryMetadata{
+		Algorithm: "AES256",
+		CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		CreatedBy: "my-identity",
+		Versions:  1,
+
+		Uses:       42,
+		LastUsedAt: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+
+		Tags: map[string]string{"env": "prod"},
+	}
+	if err = store.SetMetadata(ctx, "my-key", metadata); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Setting metadata of non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Create(ctx, "my-key", []byte("Hello")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.SetMetadata(ctx, "my-key", metadata); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+	m, err := store.Metadata(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if !reflect.DeepEqual(m, metadata) {
+		t.Fatalf("Metadata mismatch: got '%+v' - want '%+v'", m, metadata)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "my-key" {
+		t.Fatalf("Invalid key listing: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if m, err = store.Metadata(ctx, "my-key"); err != nil || !m.IsEmpty() {
+		t.Fatalf("Metadata of deleted key: got '%+v' - want empty metadata: %v", m, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err = store.Create(ctx, "my-key", []byte("Hello")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	events, err := store.Watch(ctx, "my-")
+	if err != nil {
+		t.Fatalf("Failed to watch store: %v", err)
+	}
+
+	// Changes within one poll interval may be reported in any order.
+	expect := func(want ...kes.KeyStoreEvent) {
+		t.Helper()
+
+		timeout := time.After(5 * time.Second)
+		for len(want) > 0 {
+			select {
+			case event := <-events:
+				i := slices.Index(want, event)
+				if i < 0 {
+					t.Fatalf("Unexpected event: %s %s", event.Type, event.Name)
+				}
+				want = slices.Delete(want, i, i+1)
+			case <-timeout:
+				t.Fatalf("Missing events: %v", want)
+			}
+		}
+	}
+
+	_ = store.Create(ctx, "other-key", nil)
+	_ = store.Create(ctx, "my-key-2", nil)
+	_ = store.Set(ctx, "my-key", []byte("World"))
+	expect(
+		kes.KeyStoreEvent{Type: kes.EntryCreated, Name: "my-key-2"},
+		kes.KeyStoreEvent{Type: kes.EntryUpdated, Name: "my-key"},
+	)
+
+	_ = store.Delete(ctx, "my-key-2")
+	expect(kes.KeyStoreEvent{Type: kes.EntryDeleted, Name: "my-key-2"})
+
+	cancel()
+	for range events { // The channel must be closed once ctx is canceled
+	}
+}
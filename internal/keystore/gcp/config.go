@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/url"
+	"path"
 	"strings"
 	"sync"
 )
@@ -83,6 +84,23 @@ type Config struct {
 	// access the SecretManager.
 	Credentials Credentials
 
+	// CredentialsFile is an optional path to a GCP
+	// credential configuration file. For example, a
+	// workload identity federation configuration
+	// that exchanges an external token for GCP
+	// credentials. It must not be set if Credentials
+	// are set.
+	//
+	// Ref: https://cloud.google.com/iam/docs/workload-identity-federation
+	CredentialsFile string
+
+	// KMSKey is an optional resource name of a Cloud
+	// KMS key. If set, the SecretManager encrypts new
+	// secrets with this customer-managed encryption
+	// key (CMEK). For example:
+	//   projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+	KMSKey string
+
 	// Scopes are GCP OAuth2 scopes for accessing GCP APIs.
 	// If not set, defaults to the GCP default scopes.
 	//
@@ -108,10 +126,12 @@ func (c *Config) Clone() *Config {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 	clone := &Config{
-		Endpoint:    c.Endpoint,
-		ProjectID:   c.ProjectID,
-		Credentials: c.Credentials,
-		ErrorLog:    c.ErrorLog,
+		Endpoint:        c.Endpoint,
+		ProjectID:       c.ProjectID,
+		Credentials:     c.Credentials,
+		CredentialsFile: c.CredentialsFile,
+		KMSKey:          c.KMSKey,
+		ErrorLog:        c.ErrorLog,
 	}
 	if len(c.Scopes) > 0 {
 		clone.Scopes = make([]string, 0, len(c.Scopes))
@@ -119,3 +139,40 @@ func (c *Config) Clone() *Config {
 	}
 	return clone
 }
+
+// KMSConfig is a structure containing configuration
+// options for connecting to GCP Cloud KMS.
+type KMSConfig struct {
+	// Endpoint is the GCP Cloud KMS endpoint.
+	Endpoint string
+
+	// ProjectID is the ID of the GCP project.
+	ProjectID string
+
+	// Location is the GCP location of the key ring.
+	// For example: us-east1 or global.
+	Location string
+
+	// KeyRing is the name of the Cloud KMS key ring
+	// that contains all keys.
+	KeyRing string
+
+	// Credentials are the GCP credentials to
+	// access Cloud KMS.
+	Credentials Credentials
+
+	// CredentialsFile is an optional path to a GCP
+	// credential configuration file. For example, a
+	// workload identity federation configuration.
+	// It must not be set if Credentials are set.
+	CredentialsFile string
+
+	// Scopes are GCP OAuth2 scopes for accessing GCP APIs.
+	// If not set, defaults to the GCP default scopes.
+	Scopes []string
+}
+
+// keyRing returns the resource name of the key ring.
+func (c *KMSConfig) keyRing() string {
+	return path.Join("projects", c.ProjectID, "locations", c.Location, "keyRings", c.KeyRing)
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package gcp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/minio/kes"
+	"github.com/minio/kes/keystoretest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestConformance(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, &fakeSecretManager{})
+	go srv.Serve(listener)
+	defer srv.Stop()
+
+	// The Store checks its status via HTTP, not gRPC.
+	statusSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer statusSrv.Close()
+
+	keystoretest.TestKeyStore(t, func(ctx context.Context) (kes.KeyStore, error) {
+		client, err := secretmanager.NewClient(ctx,
+			option.WithEndpoint(listener.Addr().String()),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Set has create-only semantics. Hence, the Store is not
+		// a MutableKeyStore and we hide its Set method.
+		return struct{ kes.KeyStore }{
+			&Store{
+				client: client,
+				config: &Config{
+					Endpoint:  statusSrv.URL,
+					ProjectID: "fake-project",
+				},
+			},
+		}, nil
+	})
+}
+
+func TestStore_CustomerManagedEncryption(t *testing.T) {
+	const KMSKey = "projects/fake-project/locations/global/keyRings/kes/cryptoKeys/cmek"
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	secretManager := &fakeSecretManager{}
+	srv := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, secretManager)
+	go srv.Serve(listener)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	client, err := secretmanager.NewClient(ctx,
+		option.WithEndpoint(listener.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	store := &Store{
+		client: client,
+		config: &Config{ProjectID: "fake-project", KMSKey: KMSKey},
+	}
+	if err = store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if key := secretManager.kmsKeys["projects/fake-project/secrets/my-key"]; key != KMSKey {
+		t.Fatalf("Invalid CMEK key: got '%s' - want '%s'", key, KMSKey)
+	}
+}
+
+// fakeSecretManager is an in-memory GCP SecretManager. It
+// implements the subset of the gRPC API used by the Store.
+type fakeSecretManager struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	mu      sync.Mutex
+	secrets map[string][][]byte // secret name -> versions
+	kmsKeys map[string]string   // secret name -> CMEK key
+}
+
+func (s *fakeSecretManager) CreateSecret(_ context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.secrets == nil {
+		s.secrets = map[string][][]byte{}
+	}
+	name := path.Join(req.Parent, "secrets", req.SecretId)
+	if _, ok := s.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "secret '%s' already exists", name)
+	}
+	s.secrets[name] = nil
+	if cmek := req.GetSecret().GetReplication().GetAutomatic().GetCustomerManagedEncryption(); cmek != nil {
+		if s.kmsKeys == nil {
+			s.kmsKeys = map[string]string{}
+		}
+		s.kmsKeys[name] = cmek.KmsKeyName
+	}
+	return &secretmanagerpb.Secret{Name: name}, nil
+}
+
+func (s *fakeSecretManager) AddSecretVersion(_ context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, ok := s.secrets[req.Parent]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret '%s' not found", req.Parent)
+	}
+	s.secrets[req.Parent] = append(versions, req.Payload.Data)
+	return &secretmanagerpb.SecretVersion{}, nil
+}
+
+func (s *fakeSecretManager) AccessSecretVersion(_ context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The Store only accesses the first version: <secret>/versions/1
+	versions := s.secrets[path.Dir(path.Dir(req.Name))]
+	if len(versions) == 0 || path.Base(req.Name) != "1" {
+		return nil, status.Errorf(codes.NotFound, "secret version '%s' not found", req.Name)
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.Name,
+		Payload: &secretmanagerpb.SecretPayload{Data: versions[0]},
+	}, nil
+}
+
+func (s *fakeSecretManager) DeleteSecret(_ context.Context, req *secretmanagerpb.DeleteSecretRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[req.Name]; !ok {
+		return nil, status.Errorf(codes.NotFound, "secret '%s' not found", req.Name)
+	}
+	delete(s.secrets, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *fakeSecretManager) ListSecrets(_ context.Context, req *secretmanagerpb.ListSecretsRequest) (*secretmanagerpb.ListSecretsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var resp secretmanagerpb.ListSecretsResponse
+	for name := range s.secrets {
+		if path.Dir(path.Dir(name)) == req.Parent {
+			resp.Secrets = append(resp.Secrets, &secretmanagerpb.Secret{Name: name})
+		}
+	}
+	return &resp, nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package gcp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// ConnectKMS connects and authenticates to GCP Cloud KMS.
+func ConnectKMS(ctx context.Context, c *KMSConfig) (*KMSStore, error) {
+	if c.ProjectID == "" {
+		return nil, errors.New("gcp: no project ID provided")
+	}
+	if c.Location == "" {
+		return nil, errors.New("gcp: no key ring location provided")
+	}
+	if c.KeyRing == "" {
+		return nil, errors.New("gcp: no key ring provided")
+	}
+
+	config := *c
+	options, err := clientOptions(config.ProjectID, config.Credentials, config.CredentialsFile, config.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if config.Endpoint != "" {
+		options = append(options, option.WithEndpoint(config.Endpoint))
+	} else {
+		const DefaultEndpoint = "https://cloudkms.googleapis.com/" // From the GCP SDK
+		config.Endpoint = DefaultEndpoint
+	}
+
+	service, err := cloudkms.NewService(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KMSStore{
+		config: config,
+		keys:   service.Projects.Locations.KeyRings.CryptoKeys,
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KMSStore is a GCP Cloud KMS key store. It stores no key material.
+// Instead, each key is a symmetric Cloud KMS key within a key ring
+// and Cloud KMS encrypts and decrypts all data keys. Hence, key
+// material never leaves Cloud KMS.
+//
+// A KMSStore cannot store arbitrary values, like secrets. Its
+// Create and Get methods always fail.
+//
+// Cloud KMS does not delete keys. Instead, Delete destroys all
+// versions of a key. The name of a deleted key cannot be used
+// again.
+type KMSStore struct {
+	config KMSConfig
+	keys   *cloudkms.ProjectsLocationsKeyRingsCryptoKeysService
+}
+
+var _ kes.CryptoKeyStore = (*KMSStore)(nil) // compiler check
+
+var errKMSValues = errors.New("gcp: Cloud KMS key store cannot store values")
+
+func (s *KMSStore) String() string { return "GCP Cloud KMS: " + s.config.keyRing() }
+
+// Status returns the current state of GCP Cloud KMS. In particular,
+// whether it is reachable and the network latency.
+func (s *KMSStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	return serviceStatus(ctx, s.config.Endpoint)
+}
+
+// Create always fails since a KMSStore does not store values.
+// Use CreateKey to create a Cloud KMS key.
+func (*KMSStore) Create(context.Context, string, []byte) error { return errKMSValues }
+
+// Get always fails since a KMSStore does not store values.
+func (*KMSStore) Get(context.Context, string) ([]byte, error) { return nil, errKMSValues }
+
+// CreateKey creates a new symmetric Cloud KMS key with the given
+// name within the key ring. It returns kes.ErrKeyExists if such a
+// key exists already, or existed in the past.
+func (s *KMSStore) CreateKey(ctx context.Context, name string) error {
+	_, err := s.keys.Create(s.config.keyRing(), &cloudkms.CryptoKey{
+		Purpose: "ENCRYPT_DECRYPT",
+	}).CryptoKeyId(name).Context(ctx).Do()
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if isStatusCode(err, http.StatusConflict) {
+			return kesdk.ErrKeyExists
+		}
+		return fmt.Errorf("gcp: failed to create '%s': %v", name, err)
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the Cloud KMS key with the
+// given name. The associated data is authenticated but not
+// encrypted. It returns kes.ErrKeyNotFound if no such key exists.
+func (s *KMSStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	resp, err := s.keys.Encrypt(s.cryptoKey(name), &cloudkms.EncryptRequest{
+		Plaintext:                   base64.StdEncoding.EncodeToString(plaintext),
+		AdditionalAuthenticatedData: base64.StdEncoding.EncodeToString(associatedData),
+	}).Context(ctx).Do()
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, s.keyError(ctx, name, fmt.Errorf("gcp: failed to encrypt with '%s': %v", name, err))
+	}
+	return base64.StdEncoding.DecodeString(resp.Ciphertext)
+}
+
+// Decrypt decrypts the ciphertext with the Cloud KMS key with the
+// given name. It returns kes.ErrKeyNotFound if no such key exists
+// and kes.ErrDecrypt if the ciphertext or associated data is not
+// authentic.
+func (s *KMSStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	resp, err := s.keys.Decrypt(s.cryptoKey(name), &cloudkms.DecryptRequest{
+		Ciphertext:                  base64.StdEncoding.EncodeToString(ciphertext),
+		AdditionalAuthenticatedData: base64.StdEncoding.EncodeToString(associatedData),
+	}).Context(ctx).Do()
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		// Cloud KMS responds with 400 Bad Request if the key has been
+		// destroyed or the ciphertext is not authentic.
+		if isStatusCode(err, http.StatusBadRequest) {
+			if err = s.keyError(ctx, name, err); errors.Is(err, kesdk.ErrKeyNotFound) {
+				return nil, err
+			}
+			return nil, kesdk.ErrDecrypt
+		}
+		return nil, s.keyError(ctx, name, fmt.Errorf("gcp: failed to decrypt with '%s': %v", name, err))
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
+
+// Delete destroys all versions of the Cloud KMS key with the given
+// name. Cloud KMS deletes the key material once the destruction
+// period of the key has passed. It returns kes.ErrKeyNotFound if no
+// such key exists.
+func (s *KMSStore) Delete(ctx context.Context, name string) error {
+	if exists, err := s.exists(ctx, name); err != nil {
+		return fmt.Errorf("gcp: failed to delete '%s': %v", name, err)
+	} else if !exists {
+		return kesdk.ErrKeyNotFound
+	}
+
+	versions := s.keys.CryptoKeyVersions
+	err := versions.List(s.cryptoKey(name)).Pages(ctx, func(resp *cloudkms.ListCryptoKeyVersionsResponse) error {
+		for _, version := range resp.CryptoKeyVersions {
+			if version.State != "ENABLED" && version.State != "DISABLED" {
+				continue
+			}
+			if _, err := versions.Destroy(version.Name, &cloudkms.DestroyCryptoKeyVersionRequest{}).Context(ctx).Do(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("gcp: failed to delete '%s': %v", name, err)
+	}
+	return nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue. It ignores keys without an enabled primary version,
+// like deleted keys.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+func (s *KMSStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	var names []string
+	err := s.keys.List(s.config.keyRing()).Pages(ctx, func(resp *cloudkms.ListCryptoKeysResponse) error {
+		for _, key := range resp.CryptoKeys {
+			if key.Primary != nil && key.Primary.State == "ENABLED" {
+				names = append(names, path.Base(key.Name))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("gcp: failed to list keys: %v", err)
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the KMSStore.
+func (s *KMSStore) Close() error { return nil }
+
+// cryptoKey returns the resource name of the Cloud KMS key
+// with the given name.
+func (s *KMSStore) cryptoKey(name string) string {
+	return path.Join(s.config.keyRing(), "cryptoKeys", name)
+}
+
+// exists reports whether a Cloud KMS key with the given name
+// exists and has an enabled primary version.
+func (s *KMSStore) exists(ctx context.Context, name string) (bool, error) {
+	key, err := s.keys.Get(s.cryptoKey(name)).Context(ctx).Do()
+	if isStatusCode(err, http.StatusNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return key.Primary != nil && key.Primary.State == "ENABLED", nil
+}
+
+// keyError returns kes.ErrKeyNotFound if no Cloud KMS key with the
+// given name exists or if it has been deleted. Cloud KMS responds
+// with different status codes for missing and destroyed keys.
+// Otherwise, it returns err.
+func (s *KMSStore) keyError(ctx context.Context, name string, err error) error {
+	if exists, eErr := s.exists(ctx, name); eErr == nil && !exists {
+		return kesdk.ErrKeyNotFound
+	}
+	return err
+}
+
+// isStatusCode reports whether err is a GCP API error with
+// the given HTTP status code.
+func isStatusCode(err error, code int) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == code
+}
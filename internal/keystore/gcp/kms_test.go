@@ -0,0 +1,213 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+func TestKMSStore(t *testing.T) {
+	srv := httptest.NewServer(&fakeCloudKMS{})
+	defer srv.Close()
+
+	ctx := context.Background()
+	config := KMSConfig{
+		Endpoint:  srv.URL + "/",
+		ProjectID: "fake-project",
+		Location:  "global",
+		KeyRing:   "kes",
+	}
+	service, err := cloudkms.NewService(ctx, option.WithEndpoint(config.Endpoint), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to create Cloud KMS client: %v", err)
+	}
+	store := &KMSStore{
+		config: config,
+		keys:   service.Projects.Locations.KeyRings.CryptoKeys,
+	}
+
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.CreateKey(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+
+	ciphertext, err := store.Encrypt(ctx, "my-key", []byte("plaintext"), []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	plaintext, err := store.Decrypt(ctx, "my-key", ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(plaintext, []byte("plaintext")) {
+		t.Fatalf("Decrypted plaintext does not match: got '%s' - want '%s'", plaintext, "plaintext")
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("other context")); !errors.Is(err, kesdk.ErrDecrypt) {
+		t.Fatalf("Decrypting with wrong context: got '%v' - want '%v'", err, kesdk.ErrDecrypt)
+	}
+	if _, err = store.Encrypt(ctx, "other-key", []byte("plaintext"), nil); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Listing keys: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("context")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Decrypting with deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if names, _, err = store.List(ctx, "", -1); err != nil || len(names) != 0 {
+		t.Fatalf("Listing keys after deletion: got '%v' - want no keys", names)
+	}
+}
+
+// fakeCloudKMS is an in-memory GCP Cloud KMS. It implements the
+// subset of the REST API used by the KMSStore. Each key has a
+// single version. Its "ciphertexts" are not encrypted.
+type fakeCloudKMS struct {
+	mu   sync.Mutex
+	keys map[string]string // key name -> state of its version
+}
+
+type fakeCloudKMSCiphertext struct {
+	Key       string
+	Plaintext string
+	AAD       string
+}
+
+func (k *fakeCloudKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys == nil {
+		k.keys = map[string]string{}
+	}
+	if r.URL.Path == "/" {
+		w.WriteHeader(http.StatusOK) // Status checks
+		return
+	}
+
+	var req struct {
+		Plaintext                   string
+		Ciphertext                  string
+		AdditionalAuthenticatedData string
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/"), ":")
+	switch {
+	case r.Method == http.MethodPost && action == "" && path.Base(name) == "cryptoKeys":
+		key := path.Join(name, r.URL.Query().Get("cryptoKeyId"))
+		if _, ok := k.keys[key]; ok {
+			writeError(w, http.StatusConflict, "key already exists")
+			return
+		}
+		k.keys[key] = "ENABLED"
+		writeJSON(w, k.cryptoKey(key))
+	case r.Method == http.MethodGet && path.Base(name) == "cryptoKeys":
+		var resp cloudkms.ListCryptoKeysResponse
+		for key := range k.keys {
+			if path.Dir(key) == name {
+				resp.CryptoKeys = append(resp.CryptoKeys, k.cryptoKey(key))
+			}
+		}
+		writeJSON(w, resp)
+	case r.Method == http.MethodGet && path.Base(name) == "cryptoKeyVersions":
+		key := path.Dir(name)
+		if _, ok := k.keys[key]; !ok {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		writeJSON(w, cloudkms.ListCryptoKeyVersionsResponse{
+			CryptoKeyVersions: []*cloudkms.CryptoKeyVersion{k.cryptoKey(key).Primary},
+		})
+	case r.Method == http.MethodGet:
+		if _, ok := k.keys[name]; !ok {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		writeJSON(w, k.cryptoKey(name))
+	case action == "destroy":
+		key := path.Dir(path.Dir(name))
+		k.keys[key] = "DESTROY_SCHEDULED"
+		writeJSON(w, k.cryptoKey(key).Primary)
+	case action == "encrypt":
+		if k.keys[name] != "ENABLED" {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		ciphertext, _ := json.Marshal(fakeCloudKMSCiphertext{Key: name, Plaintext: req.Plaintext, AAD: req.AdditionalAuthenticatedData})
+		writeJSON(w, cloudkms.EncryptResponse{Name: name, Ciphertext: base64.StdEncoding.EncodeToString(ciphertext)})
+	case action == "decrypt":
+		var ciphertext fakeCloudKMSCiphertext
+		if k.keys[name] != "ENABLED" {
+			writeError(w, http.StatusBadRequest, "key is not enabled")
+			return
+		}
+		raw, _ := base64.StdEncoding.DecodeString(req.Ciphertext)
+		if err := json.Unmarshal(raw, &ciphertext); err != nil || ciphertext.Key != name || ciphertext.AAD != req.AdditionalAuthenticatedData {
+			writeError(w, http.StatusBadRequest, "decryption failed")
+			return
+		}
+		writeJSON(w, cloudkms.DecryptResponse{Plaintext: ciphertext.Plaintext})
+	default:
+		writeError(w, http.StatusNotImplemented, "unsupported request")
+	}
+}
+
+func (k *fakeCloudKMS) cryptoKey(name string) *cloudkms.CryptoKey {
+	return &cloudkms.CryptoKey{
+		Name:    name,
+		Purpose: "ENCRYPT_DECRYPT",
+		Primary: &cloudkms.CryptoKeyVersion{
+			Name:  path.Join(name, "cryptoKeyVersions", "1"),
+			State: k.keys[name],
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q}}`, code, msg)
+}
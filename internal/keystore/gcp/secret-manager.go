@@ -38,67 +38,87 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 		c = &Config{}
 	}
 
-	var options []option.ClientOption
+	options, err := clientOptions(c.ProjectID, c.Credentials, c.CredentialsFile, c.Scopes)
+	if err != nil {
+		return nil, err
+	}
 	if c.Endpoint != "" {
 		options = append(options, option.WithEndpoint(c.Endpoint))
 	} else {
 		const DefaultEndpoint = "https://secretmanager.googleapis.com" // From the GCP SDK
 		c.Endpoint = DefaultEndpoint
 	}
+
+	client, err := secretmanager.NewClient(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Store{
+		client: client,
+		config: c,
+	}
+	if _, err = conn.Status(ctx); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// clientOptions returns the GCP client options for the given
+// credentials, credential configuration file and scopes.
+func clientOptions(projectID string, credentials Credentials, credentialsFile string, scopes []string) ([]option.ClientOption, error) {
+	var options []option.ClientOption
+
 	// We only pass credentials to the GCP client if they
 	// are not empty. When running inside GCP, e.g. on app engine,
 	// then the GCP credentials are provided by the environment and
 	// the GCP SDK will pick them up automatically. In this case
 	// the user does not have to provide login credentials at all.
 	empty := Credentials{}
-	if c.Credentials != empty {
+	if credentials != empty {
+		if credentialsFile != "" {
+			return nil, errors.New("gcp: credentials and credentials file are mutually exclusive")
+		}
+
 		// We do a sanity check here to ensure that the user
 		// actually provided some login credentials. However,
 		// if the user provides invalid credentials the GCP
 		// client will fail on the first request, not when
 		// it is created.
-		if c.Credentials.projectID != c.ProjectID {
-			c.Credentials.projectID = c.ProjectID
-		}
-		if c.Credentials.projectID == "" {
+		credentials.projectID = projectID
+		if credentials.projectID == "" {
 			return nil, errors.New("gcp: no project ID provided")
 		}
-		if c.Credentials.Client == "" {
+		if credentials.Client == "" {
 			return nil, errors.New("gcp: no client email provided")
 		}
-		if c.Credentials.ClientID == "" {
+		if credentials.ClientID == "" {
 			return nil, errors.New("gcp: no client ID provided")
 		}
-		if c.Credentials.Key == "" {
+		if credentials.Key == "" {
 			return nil, errors.New("gcp: no client private key provided")
 		}
-		if c.Credentials.KeyID == "" {
+		if credentials.KeyID == "" {
 			return nil, errors.New("gcp: no client private key ID provided")
 		}
 
-		credentialsJSON, err := c.Credentials.MarshalJSON()
+		credentialsJSON, err := credentials.MarshalJSON()
 		if err != nil {
 			return nil, err
 		}
 		options = append(options, option.WithCredentialsJSON(credentialsJSON))
 	}
-	if len(c.Scopes) != 0 {
-		options = append(options, option.WithScopes(c.Scopes...))
-	}
 
-	client, err := secretmanager.NewClient(ctx, options...)
-	if err != nil {
-		return nil, err
+	// A credential configuration file may contain service account
+	// credentials or an external account configuration, e.g. for
+	// workload identity federation. The GCP SDK detects the type.
+	if credentialsFile != "" {
+		options = append(options, option.WithCredentialsFile(credentialsFile))
 	}
-
-	conn := &Store{
-		client: client,
-		config: c,
+	if len(scopes) != 0 {
+		options = append(options, option.WithScopes(scopes...))
 	}
-	if _, err = conn.Status(ctx); err != nil {
-		return nil, err
-	}
-	return conn, nil
+	return options, nil
 }
 
 func (s *Store) String() string { return "GCP SecretManager: Project=" + s.config.ProjectID }
@@ -106,7 +126,13 @@ func (s *Store) String() string { return "GCP SecretManager: Project=" + s.confi
 // Status returns the current state of the GCP SecretManager instance.
 // In particular, whether it is reachable and the network latency.
 func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.Endpoint, nil)
+	return serviceStatus(ctx, s.config.Endpoint)
+}
+
+// serviceStatus returns the state of the GCP service at the given
+// endpoint.
+func serviceStatus(ctx context.Context, endpoint string) (kes.KeyStoreState, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return kes.KeyStoreState{}, err
 	}
@@ -137,7 +163,9 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		Secret: &secretmanagerpb.Secret{
 			Replication: &secretmanagerpb.Replication{
 				Replication: &secretmanagerpb.Replication_Automatic_{
-					Automatic: &secretmanagerpb.Replication_Automatic{},
+					Automatic: &secretmanagerpb.Replication_Automatic{
+						CustomerManagedEncryption: s.customerManagedEncryption(),
+					},
 				},
 			},
 		},
@@ -167,6 +195,17 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	return nil
 }
 
+// customerManagedEncryption returns the CMEK configuration of new
+// secrets or nil if secrets use Google-managed encryption keys.
+func (s *Store) customerManagedEncryption() *secretmanagerpb.CustomerManagedEncryption {
+	if s.config.KMSKey == "" {
+		return nil
+	}
+	return &secretmanagerpb.CustomerManagedEncryption{
+		KmsKeyName: s.config.KMSKey,
+	}
+}
+
 // Set stores the given key-value pair at GCP secret manager
 // if and only if it doesn't exists. If such an entry already exists
 // it returns kes.ErrKeyExists.
@@ -248,5 +287,6 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 	return keystore.List(names, prefix, n)
 }
 
-// Close closes the Store.
-func (s *Store) Close() error { return nil }
+// Close closes the Store and its connection to the
+// GCP SecretManager.
+func (s *Store) Close() error { return s.client.Close() }
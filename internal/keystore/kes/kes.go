@@ -0,0 +1,179 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package kes implements a key store that uses the keys of
+// another KES server.
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/https"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// Config is a structure containing configuration options
+// for connecting to a KES server.
+type Config struct {
+	// Endpoints contains one or multiple KES server endpoints.
+	// For example: https://127.0.0.1:7373
+	Endpoints []string
+
+	// Enclave is an optional enclave of the KES server. If
+	// empty, the default enclave is used.
+	Enclave string
+
+	// Certificate is the path to the mTLS client certificate
+	// used to authenticate to the KES server.
+	Certificate string
+
+	// PrivateKey is the path to the private key of the mTLS
+	// client certificate.
+	PrivateKey string
+
+	// CAPath is an optional path to the root CA certificate(s)
+	// for verifying the TLS certificate of the KES server. If
+	// empty, the system root CAs are used.
+	CAPath string
+}
+
+// Connect connects to the KES server using the given config.
+func Connect(ctx context.Context, config *Config) (*Store, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, errors.New("kes: no endpoint provided")
+	}
+	if config.Certificate == "" || config.PrivateKey == "" {
+		return nil, errors.New("kes: no client certificate provided")
+	}
+
+	cert, err := https.CertificateFromFile(config.Certificate, config.PrivateKey, "")
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if config.CAPath != "" {
+		if tlsConfig.RootCAs, err = https.CertPoolFromFile(config.CAPath); err != nil {
+			return nil, err
+		}
+	}
+
+	client := kesdk.NewClientWithConfig("", tlsConfig)
+	client.Endpoints = config.Endpoints
+	if config.Enclave != "" {
+		client.HTTPClient.Transport = &enclaveTransport{
+			RoundTripper: client.HTTPClient.Transport,
+			enclave:      config.Enclave,
+		}
+	}
+
+	s := &Store{
+		config: *config,
+		client: client,
+	}
+	if _, err = s.Status(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Store is a key store that uses the keys of another KES server.
+// It stores no key material. Instead, the KES server encrypts and
+// decrypts all data keys. Hence, key material never leaves the
+// KES server.
+//
+// A Store cannot store arbitrary values, like secrets. Its Create
+// and Get methods always fail.
+type Store struct {
+	config Config
+	client *kesdk.Client
+}
+
+var _ kes.CryptoKeyStore = (*Store)(nil) // compiler check
+
+var errValues = errors.New("kes: KES key store cannot store values")
+
+func (s *Store) String() string { return "KES: " + strings.Join(s.config.Endpoints, ",") }
+
+// Status returns the current state of the KES server. In particular,
+// whether it is reachable and the network latency.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	if _, err := s.client.Version(ctx); err != nil {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return kes.KeyStoreState{
+		Latency: time.Since(start),
+	}, nil
+}
+
+// Create always fails since a Store does not store values.
+// Use CreateKey to create a key at the KES server.
+func (*Store) Create(context.Context, string, []byte) error { return errValues }
+
+// Get always fails since a Store does not store values.
+func (*Store) Get(context.Context, string) ([]byte, error) { return nil, errValues }
+
+// CreateKey creates a new key with the given name at the KES
+// server. It returns kes.ErrKeyExists if such a key exists
+// already.
+func (s *Store) CreateKey(ctx context.Context, name string) error {
+	return s.client.CreateKey(ctx, name)
+}
+
+// Encrypt encrypts the plaintext with the key with the given name
+// at the KES server. It returns kes.ErrKeyNotFound if no such key
+// exists.
+func (s *Store) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	return s.client.Encrypt(ctx, name, plaintext, associatedData)
+}
+
+// Decrypt decrypts the ciphertext with the key with the given name
+// at the KES server. It returns kes.ErrKeyNotFound if no such key
+// exists.
+func (s *Store) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	return s.client.Decrypt(ctx, name, ciphertext, associatedData)
+}
+
+// Delete deletes the key with the given name at the KES server.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	return s.client.DeleteKey(ctx, name)
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.client.ListKeys(ctx, prefix, n)
+}
+
+// Close closes the Store.
+func (s *Store) Close() error {
+	s.client.HTTPClient.CloseIdleConnections()
+	return nil
+}
+
+// enclaveTransport is a http.RoundTripper that sends all
+// requests to an enclave of the KES server.
+type enclaveTransport struct {
+	http.RoundTripper
+
+	enclave string
+}
+
+func (t *enclaveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(headers.KesEnclave, t.enclave)
+	return t.RoundTripper.RoundTrip(req)
+}
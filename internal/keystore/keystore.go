@@ -5,7 +5,10 @@
 package keystore
 
 import (
+	"context"
 	"errors"
+	"net"
+	"net/http"
 	"slices"
 	"strings"
 )
@@ -51,6 +54,16 @@ func List(names []string, prefix string, n int) ([]string, string, error) {
 	}
 }
 
+// ListFrom behaves like List but ignores all names that are
+// lexicographically smaller than continueAt. Hence, a listing
+// can be continued at the name returned by a previous call.
+func ListFrom(names []string, prefix, continueAt string, n int) ([]string, string, error) {
+	if continueAt != "" {
+		names = slices.DeleteFunc(names, func(name string) bool { return name < continueAt })
+	}
+	return List(names, prefix, n)
+}
+
 // ErrUnreachable is an error that indicates that the
 // Store is not reachable - for example due to a
 // a network error.
@@ -65,6 +78,118 @@ func (e *ErrUnreachable) Error() string {
 	return "kes: keystore unreachable: " + e.Err.Error()
 }
 
+// Unwrap returns the underlying error.
+func (e *ErrUnreachable) Unwrap() error { return e.Err }
+
+// ErrThrottled is an error that indicates that the Store
+// rejected a request since the client sent too many requests,
+// for example when exceeding an API rate limit.
+type ErrThrottled struct {
+	Err error
+}
+
+func (e *ErrThrottled) Error() string {
+	if e.Err == nil {
+		return "kes: keystore throttled request"
+	}
+	return "kes: keystore throttled request: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrThrottled) Unwrap() error { return e.Err }
+
+// ErrAuth is an error that indicates that the Store rejected
+// a request since the KES server is not authenticated or not
+// authorized, for example due to expired credentials.
+type ErrAuth struct {
+	Err error
+}
+
+func (e *ErrAuth) Error() string {
+	if e.Err == nil {
+		return "kes: keystore denied access"
+	}
+	return "kes: keystore denied access: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrAuth) Unwrap() error { return e.Err }
+
+// Error codes of Store errors. They are stable, machine-readable
+// identifiers of the cause of an error that clients can branch on.
+const (
+	CodeUnreachable = "backend_unreachable" // The Store is not reachable
+	CodeThrottled   = "backend_throttled"   // The Store throttled the request
+	CodeAuth        = "backend_auth"        // The Store denied access
+)
+
+// StatusError returns err as ErrThrottled, ErrAuth or ErrUnreachable
+// if the HTTP status code, sent by a Store, indicates that the request
+// has been throttled, has been denied or that the Store is unavailable.
+// Otherwise, it returns err.
+func StatusError(status int, err error) error {
+	switch statusCode(status) {
+	case CodeThrottled:
+		return &ErrThrottled{Err: err}
+	case CodeAuth:
+		return &ErrAuth{Err: err}
+	case CodeUnreachable:
+		return &ErrUnreachable{Err: err}
+	default:
+		return err
+	}
+}
+
+// ErrorCode returns the error code of err, like CodeUnreachable,
+// or the empty string if err has no error code.
+//
+// Besides ErrUnreachable, ErrThrottled and ErrAuth, network errors
+// and exceeded deadlines are reported as CodeUnreachable. Errors
+// that carry an HTTP status code, via a StatusCode method, are
+// classified like StatusError does.
+func ErrorCode(err error) string {
+	var (
+		unreachable *ErrUnreachable
+		throttled   *ErrThrottled
+		auth        *ErrAuth
+		netErr      net.Error
+		statusErr   interface{ StatusCode() int }
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &throttled):
+		return CodeThrottled
+	case errors.As(err, &auth):
+		return CodeAuth
+	case errors.As(err, &unreachable):
+		return CodeUnreachable
+	case errors.Is(err, context.Canceled):
+		return "" // The request has been canceled by the client
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return CodeUnreachable
+	case errors.As(err, &statusErr):
+		return statusCode(statusErr.StatusCode())
+	default:
+		return ""
+	}
+}
+
+// statusCode returns the error code of an HTTP status code sent
+// by a Store, or the empty string if it has no error code.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return CodeThrottled
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CodeAuth
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnreachable
+	default:
+		return ""
+	}
+}
+
 // IsUnreachable reports whether err is an Unreachable
 // error. If IsUnreachable returns true it returns err
 // as Unreachable error.
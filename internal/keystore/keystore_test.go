@@ -5,6 +5,11 @@
 package keystore
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"slices"
 	"testing"
 )
@@ -25,6 +30,61 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListFrom(t *testing.T) {
+	names := []string{"my-key", "my-key2", "my-key3", "0-key", "1-key"}
+	list, continueAt, err := ListFrom(slices.Clone(names), "my", "my-key2", 1)
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if !slices.Equal(list, []string{"my-key2"}) {
+		t.Fatalf("Listing does not match: got '%v' - want '%v'", list, []string{"my-key2"})
+	}
+	if continueAt != "my-key3" {
+		t.Fatalf("Continue at does not match: got '%s' - want '%s'", continueAt, "my-key3")
+	}
+
+	list, continueAt, err = ListFrom(slices.Clone(names), "", continueAt, -1)
+	if err != nil {
+		t.Fatalf("Failed to list: %v", err)
+	}
+	if !slices.Equal(list, []string{"my-key3"}) {
+		t.Fatalf("Listing does not match: got '%v' - want '%v'", list, []string{"my-key3"})
+	}
+	if continueAt != "" {
+		t.Fatalf("Continue at does not match: got '%s' - want '%s'", continueAt, "")
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for i, test := range errorCodeTests {
+		if code := ErrorCode(test.Err); code != test.Code {
+			t.Fatalf("Test %d: error code mismatch: got '%s' - want '%s'", i, code, test.Code)
+		}
+	}
+}
+
+var errorCodeTests = []struct {
+	Err  error
+	Code string
+}{
+	{Err: nil, Code: ""},                                         // 0
+	{Err: errors.New("some error"), Code: ""},                    // 1
+	{Err: &ErrUnreachable{}, Code: CodeUnreachable},              // 2
+	{Err: &ErrThrottled{}, Code: CodeThrottled},                  // 3
+	{Err: &ErrAuth{}, Code: CodeAuth},                            // 4
+	{Err: fmt.Errorf("wrapped: %w", &ErrAuth{}), Code: CodeAuth}, // 5
+
+	{Err: StatusError(http.StatusTooManyRequests, errors.New("")), Code: CodeThrottled},      // 6
+	{Err: StatusError(http.StatusUnauthorized, errors.New("")), Code: CodeAuth},              // 7
+	{Err: StatusError(http.StatusForbidden, errors.New("")), Code: CodeAuth},                 // 8
+	{Err: StatusError(http.StatusServiceUnavailable, errors.New("")), Code: CodeUnreachable}, // 9
+	{Err: StatusError(http.StatusBadRequest, errors.New("")), Code: ""},                      // 10
+
+	{Err: context.DeadlineExceeded, Code: CodeUnreachable},                      // 11
+	{Err: context.Canceled, Code: ""},                                           // 12
+	{Err: &net.OpError{Op: "dial", Err: errors.New("")}, Code: CodeUnreachable}, // 13
+}
+
 var listTests = []struct {
 	Names  []string
 	Prefix string
@@ -0,0 +1,299 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package plugin implements a key store that delegates all
+// operations to an external program. The program can be
+// written in any language as long as it implements the
+// plugin protocol.
+//
+// For every operation, the Store executes the program, writes
+// a JSON request to its stdin and reads a JSON response from its
+// stdout. A request has the following format:
+//
+//	{
+//	  "op":     "status" | "create" | "set" | "get" | "delete" | "list",
+//	  "name":   "<key name>",              // create, set, get, delete
+//	  "value":  "<base64-encoded value>",  // create, set
+//	  "prefix": "<key name prefix>"        // list
+//	}
+//
+// A response has the following format:
+//
+//	{
+//	  "value": "<base64-encoded value>",   // get
+//	  "names": ["<key name>", ...],        // list
+//	  "error": {                           // if the operation failed
+//	    "code":    "not_found" | "exists" | "unsupported" | "auth" | "throttled" | "unavailable" | "",
+//	    "message": "<error description>"
+//	  }
+//	}
+//
+// The program must exit with a zero exit code whenever it
+// writes a response, even if the operation failed.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// Config is a structure containing configuration options
+// for executing a keystore plugin.
+type Config struct {
+	// Path is the path to the plugin executable.
+	Path string
+
+	// Args are optional command line arguments passed
+	// to the plugin.
+	Args []string
+
+	// Env contains the environment variables of the
+	// plugin in the form "KEY=value". The plugin does not
+	// inherit the environment of the KES server.
+	Env []string
+
+	// Timeout is the maximum time a single plugin execution
+	// may take. If <= 0, it defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// DefaultTimeout is the default maximum time a single
+// plugin execution may take.
+const DefaultTimeout = 15 * time.Second
+
+// maxOutputSize is the maximum size of a plugin response.
+const maxOutputSize = 16 << 20 // 16 MiB
+
+// Operations of the plugin protocol.
+const (
+	opStatus = "status"
+	opCreate = "create"
+	opSet    = "set"
+	opGet    = "get"
+	opDelete = "delete"
+	opList   = "list"
+)
+
+// Error codes of the plugin protocol.
+const (
+	codeNotFound    = "not_found"
+	codeExists      = "exists"
+	codeUnsupported = "unsupported"
+	codeAuth        = "auth"
+	codeThrottled   = "throttled"
+	codeUnavailable = "unavailable"
+)
+
+// NewStore returns a new Store that executes the plugin
+// for every operation. It does not execute the plugin.
+func NewStore(config *Config) (*Store, error) {
+	if config.Path == "" {
+		return nil, errors.New("plugin: no executable specified")
+	}
+	path, err := exec.LookPath(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin: invalid executable: %v", err)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Store{
+		name:    path,
+		path:    path,
+		args:    append([]string(nil), config.Args...),
+		env:     append([]string{}, config.Env...),
+		timeout: timeout,
+	}, nil
+}
+
+// Store is a key store that delegates all operations to
+// an external plugin program.
+type Store struct {
+	name    string
+	path    string
+	args    []string
+	env     []string
+	timeout time.Duration
+}
+
+var _ kes.MutableKeyStore = (*Store)(nil) // compiler check
+
+func (s *Store) String() string { return "Plugin: " + s.name }
+
+// Status executes the plugin to check whether the keystore
+// behind it is reachable.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	start := time.Now()
+	if _, err := s.exec(ctx, &request{Op: opStatus}); err != nil {
+		if _, ok := keystore.IsUnreachable(err); ok {
+			return kes.KeyStoreState{}, err
+		}
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	return kes.KeyStoreState{
+		Latency: time.Since(start),
+	}, nil
+}
+
+// Create stores the value if and only if no entry with the
+// given name exists. Otherwise, it returns kes.ErrKeyExists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	_, err := s.exec(ctx, &request{Op: opCreate, Name: name, Value: value})
+	return err
+}
+
+// Set replaces the value of an existing entry. It returns
+// kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Set(ctx context.Context, name string, value []byte) error {
+	_, err := s.exec(ctx, &request{Op: opSet, Name: name, Value: value})
+	return err
+}
+
+// Delete deletes the entry with the given name. It returns
+// kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	_, err := s.exec(ctx, &request{Op: opDelete, Name: name})
+	return err
+}
+
+// Get returns the value of the entry with the given name.
+// It returns kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.exec(ctx, &request{Op: opGet, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Value) == 0 {
+		return nil, fmt.Errorf("plugin: failed to get '%s': response contains no value", name)
+	}
+	return resp.Value, nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	resp, err := s.exec(ctx, &request{Op: opList, Prefix: prefix})
+	if err != nil {
+		return nil, "", err
+	}
+
+	names := make([]string, 0, len(resp.Names))
+	for _, name := range resp.Names {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the Store. Plugins only run during an operation.
+// Hence, Close does nothing.
+func (s *Store) Close() error { return nil }
+
+type request struct {
+	Op     string `json:"op"`
+	Name   string `json:"name,omitempty"`
+	Value  []byte `json:"value,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type response struct {
+	Value []byte   `json:"value,omitempty"`
+	Names []string `json:"names,omitempty"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// exec executes the plugin with the request and returns its
+// response. It converts plugin errors to KES errors.
+func (s *Store) exec(ctx context.Context, req *request) (*response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.path, s.args...)
+	cmd.Env = s.env
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &limitedWriter{W: &stdout, N: maxOutputSize}
+	cmd.Stderr = &limitedWriter{W: &stderr, N: 4 << 10}
+	if err = cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, &keystore.ErrUnreachable{Err: fmt.Errorf("plugin: '%s' operation failed: %v", req.Op, ctxErr)}
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("plugin: '%s' operation failed: %v: %s", req.Op, err, msg)
+		}
+		return nil, fmt.Errorf("plugin: '%s' operation failed: %v", req.Op, err)
+	}
+
+	var resp response
+	if err = json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin: '%s' operation failed: invalid response: %v", req.Op, err)
+	}
+	if resp.Error == nil {
+		return &resp, nil
+	}
+
+	err = fmt.Errorf("plugin: '%s' operation failed: %s", req.Op, resp.Error.Message)
+	switch resp.Error.Code {
+	case codeNotFound:
+		return nil, kesdk.ErrKeyNotFound
+	case codeExists:
+		return nil, kesdk.ErrKeyExists
+	case codeUnsupported:
+		return nil, fmt.Errorf("plugin: '%s' operation not supported: %s", req.Op, resp.Error.Message)
+	case codeAuth:
+		return nil, &keystore.ErrAuth{Err: err}
+	case codeThrottled:
+		return nil, &keystore.ErrThrottled{Err: err}
+	case codeUnavailable:
+		return nil, &keystore.ErrUnreachable{Err: err}
+	default:
+		return nil, err
+	}
+}
+
+// limitedWriter writes at most N bytes to W and
+// fails once the limit has been exceeded.
+type limitedWriter struct {
+	W io.Writer
+	N int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.N {
+		return 0, errors.New("plugin: output too large")
+	}
+	n, err := w.W.Write(p)
+	w.N -= int64(n)
+	return n, err
+}
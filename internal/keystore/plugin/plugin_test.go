@@ -0,0 +1,195 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// envPluginDir is set when the test binary runs as plugin.
+// The plugin stores keys as files in that directory.
+const envPluginDir = "KES_TEST_PLUGIN_DIR"
+
+func TestMain(m *testing.M) {
+	if dir, ok := os.LookupEnv(envPluginDir); ok {
+		runPlugin(dir)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestStore(t *testing.T) {
+	store, err := NewStore(&Config{
+		Path: os.Args[0],
+		Env:  []string{envPluginDir + "=" + t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err = store.Status(ctx); err != nil {
+		t.Fatalf("Failed to fetch status: %v", err)
+	}
+
+	value := []byte("Hello World")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	value = []byte("Hello Plugin")
+	if err = store.Set(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	if err = store.Create(ctx, "other-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	names, prefix, err := store.List(ctx, "my-", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "my-key" || prefix != "" {
+		t.Fatalf("Invalid listing: got '%v' and prefix '%s' - want '[my-key]'", names, prefix)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Getting deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreInvalidPlugin(t *testing.T) {
+	if _, err := NewStore(&Config{Path: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("Created store with non-existing plugin")
+	}
+
+	// Without the plugin env. variable, the test binary
+	// runs no tests and writes no JSON response.
+	store, err := NewStore(&Config{Path: os.Args[0], Args: []string{"-test.run=^$"}})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err = store.Get(context.Background(), "my-key"); err == nil {
+		t.Fatal("Plugin without valid response succeeded")
+	}
+}
+
+func TestNewWASMStore(t *testing.T) {
+	if _, err := NewWASMStore(&WASMConfig{Module: filepath.Join(t.TempDir(), "missing.wasm")}); err == nil {
+		t.Fatal("Created store with non-existing WASM module")
+	}
+
+	module := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(module, []byte("\x00asm"), 0o600); err != nil {
+		t.Fatalf("Failed to create WASM module: %v", err)
+	}
+	store, err := NewWASMStore(&WASMConfig{
+		Module:  module,
+		Runtime: os.Args[0],
+		Args:    []string{"-v"},
+		Env:     []string{"TOKEN=secret"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if args := []string{"run", "--env", "TOKEN", "--", module, "-v"}; !slices.Equal(store.args, args) {
+		t.Fatalf("Invalid runtime args: got '%v' - want '%v'", store.args, args)
+	}
+	if env := []string{"TOKEN=secret"}; !slices.Equal(store.env, env) {
+		t.Fatalf("Invalid runtime env: got '%v' - want '%v'", store.env, env)
+	}
+
+	if _, err = NewWASMStore(&WASMConfig{Module: module, Runtime: os.Args[0], Env: []string{"TOKEN"}}); err == nil {
+		t.Fatal("Created store with invalid env. variable")
+	}
+}
+
+// runPlugin implements the plugin protocol and stores
+// keys as files within dir.
+func runPlugin(dir string) {
+	var (
+		req  request
+		resp response
+	)
+	fail := func(code, msg string) {
+		resp.Error = &struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}{Code: code, Message: msg}
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fail("", err.Error())
+	}
+
+	filename := filepath.Join(dir, req.Name)
+	switch req.Op {
+	case opStatus:
+	case opCreate:
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			fail(codeExists, "key already exists")
+			break
+		}
+		if err == nil {
+			_, err = f.Write(req.Value)
+			f.Close()
+		}
+		if err != nil {
+			fail("", err.Error())
+		}
+	case opSet:
+		if _, err := os.Stat(filename); err != nil {
+			fail(codeNotFound, "key does not exist")
+			break
+		}
+		if err := os.WriteFile(filename, req.Value, 0o600); err != nil {
+			fail("", err.Error())
+		}
+	case opGet:
+		value, err := os.ReadFile(filename)
+		if err != nil {
+			fail(codeNotFound, "key does not exist")
+			break
+		}
+		resp.Value = value
+	case opDelete:
+		if err := os.Remove(filename); err != nil {
+			fail(codeNotFound, "key does not exist")
+		}
+	case opList:
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			fail("", err.Error())
+			break
+		}
+		for _, entry := range entries {
+			resp.Names = append(resp.Names, entry.Name())
+		}
+	default:
+		fail(codeUnsupported, "unknown operation")
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+}
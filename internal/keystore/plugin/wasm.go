@@ -0,0 +1,94 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// WASMConfig is a structure containing configuration options
+// for executing a keystore plugin compiled to WebAssembly.
+type WASMConfig struct {
+	// Module is the path to the WASM module. It must target
+	// WASI and implement the plugin protocol.
+	Module string
+
+	// Runtime is the path to the wasmtime executable. If
+	// empty, it defaults to DefaultWASMRuntime.
+	Runtime string
+
+	// Args are optional command line arguments passed
+	// to the module.
+	Args []string
+
+	// Env contains the environment variables of the module
+	// in the form "KEY=value". They are passed to the runtime
+	// via its environment, not its command line.
+	Env []string
+
+	// Timeout is the maximum time a single plugin execution
+	// may take. If <= 0, it defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// DefaultWASMRuntime is the default WASI runtime executing
+// WASM keystore plugins.
+const DefaultWASMRuntime = "wasmtime"
+
+// NewWASMStore returns a new Store that executes the WASM module
+// for every operation.
+//
+// The module runs within the sandbox of the WASI runtime. It has
+// no access to the file system, the network or the environment
+// of the KES server. It can only read the request from its stdin,
+// write the response to its stdout and read the env. variables
+// of the config. Hence, the keystore behind the module must be
+// reachable via the WASI runtime, e.g. a key store embedded into
+// the module that is populated via env. variables, or via a host
+// the runtime is configured to expose.
+func NewWASMStore(config *WASMConfig) (*Store, error) {
+	if config.Module == "" {
+		return nil, errors.New("plugin: no WASM module specified")
+	}
+	if _, err := os.Stat(config.Module); err != nil {
+		return nil, fmt.Errorf("plugin: invalid WASM module: %v", err)
+	}
+
+	runtime := config.Runtime
+	if runtime == "" {
+		runtime = DefaultWASMRuntime
+	}
+
+	// Env. variables may contain secrets, like access tokens. Hence,
+	// they must not appear on the command line of the runtime, which
+	// is visible to other processes. Instead, the runtime receives them
+	// as its own env. variables and passes them, by name, to the module.
+	args := []string{"run"}
+	for _, env := range config.Env {
+		name, _, ok := strings.Cut(env, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("plugin: invalid env. variable '%s': not in the form KEY=value", name)
+		}
+		args = append(args, "--env", name)
+	}
+	args = append(args, "--", config.Module)
+	args = append(args, config.Args...)
+
+	store, err := NewStore(&Config{
+		Path:    runtime,
+		Args:    args,
+		Env:     config.Env,
+		Timeout: config.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	store.name = config.Module
+	return store, nil
+}
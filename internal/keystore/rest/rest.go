@@ -0,0 +1,412 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package rest implements a key store that stores keys at a
+// generic HTTP REST service. The requests and the JSON format
+// of the service are configurable.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/https"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// Config is a structure containing configuration options for
+// connecting to a generic HTTP REST service.
+type Config struct {
+	// Endpoint is the base URL of the REST service, e.g.
+	// https://secrets.internal:8443. The URLs of all
+	// operations are relative to it.
+	Endpoint string
+
+	// Header contains HTTP headers sent with every request,
+	// e.g. an Authorization header with an API token.
+	Header http.Header
+
+	// Certificate is an optional path to an mTLS client
+	// certificate used to authenticate to the REST service.
+	Certificate string
+
+	// PrivateKey is the path to the private key of the mTLS
+	// client certificate.
+	PrivateKey string
+
+	// CAPath is an optional path to the root CA certificate(s)
+	// for verifying the TLS certificate of the REST service. If
+	// empty, the system root CAs are used.
+	CAPath string
+
+	// Create describes the request that stores a new key. The
+	// service must reject requests for existing keys with 409
+	// Conflict or 412 Precondition Failed. The request body is
+	// a JSON object with the base64-encoded key at Create.Path.
+	Create Operation
+
+	// Get describes the request that fetches a key. The response
+	// body must contain the base64-encoded key at Get.Path.
+	Get Operation
+
+	// Update optionally describes the request that replaces an
+	// existing key, like Create. If empty, keys cannot be rotated.
+	Update Operation
+
+	// Delete describes the request that deletes a key.
+	Delete Operation
+
+	// List describes the request that lists keys. The response
+	// body must contain the key names at List.Path. The URL may
+	// contain a {prefix} placeholder. Key names not starting
+	// with the prefix are ignored.
+	List Operation
+
+	// Status optionally describes the request that checks
+	// whether the service is reachable. If empty, a GET request
+	// is sent to the endpoint. Any response without a 5xx status
+	// code indicates that the service is reachable.
+	Status Operation
+}
+
+// Operation describes an HTTP request sent to the REST service.
+type Operation struct {
+	// Method is the HTTP method. If empty, it defaults to PUT
+	// for creating or updating keys, DELETE for deleting keys
+	// and GET otherwise.
+	Method string
+
+	// URL is the URL of the request relative to the endpoint.
+	// The {name} placeholder is replaced with the key name and
+	// the {prefix} placeholder with the prefix when listing.
+	// For example: /v1/secrets/{name}
+	URL string
+
+	// Path is the location of the key within the JSON request
+	// or response body or of the key names when listing. Path
+	// segments are separated by dots and '*' selects all elements
+	// of a JSON array. For example: data.value or items.*.name
+	Path string
+}
+
+// maxResponseSize is the maximum size of a response body
+// read from the REST service.
+const maxResponseSize = 16 << 20 // 16 MiB
+
+// NewStore returns a new Store that stores keys at the REST
+// service. It does not send any request to the service.
+func NewStore(config *Config) (*Store, error) {
+	if config.Endpoint == "" {
+		return nil, errors.New("rest: no endpoint specified")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("rest: invalid endpoint: %v", err)
+	}
+	for name, op := range map[string]Operation{"create": config.Create, "get": config.Get, "delete": config.Delete, "list": config.List} {
+		if op.URL == "" {
+			return nil, fmt.Errorf("rest: no URL for '%s' specified", name)
+		}
+	}
+	for name, op := range map[string]Operation{"create": config.Create, "get": config.Get, "list": config.List} {
+		if op.Path == "" {
+			return nil, fmt.Errorf("rest: no JSON path for '%s' specified", name)
+		}
+	}
+	if config.Update.URL != "" && config.Update.Path == "" {
+		return nil, errors.New("rest: no JSON path for 'update' specified")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.Certificate != "" || config.PrivateKey != "" {
+		cert, err := https.CertificateFromFile(config.Certificate, config.PrivateKey, "")
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if config.CAPath != "" {
+		rootCAs, err := https.CertPoolFromFile(config.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Store{
+		config: *config,
+		client: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Store is a key store that stores keys at a generic HTTP
+// REST service.
+type Store struct {
+	config Config
+	client *http.Client
+}
+
+var _ kes.MutableKeyStore = (*Store)(nil) // compiler check
+
+func (s *Store) String() string { return "REST: " + s.config.Endpoint }
+
+// Status returns the current state of the REST service. In
+// particular, whether it is reachable and the network latency.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	op := s.config.Status
+	if op.URL == "" {
+		op.URL = "/"
+	}
+
+	start := time.Now()
+	resp, err := s.send(ctx, op, http.MethodGet, "", "", nil)
+	if err != nil {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: fmt.Errorf("rest: status check failed (status: %s)", resp.Status)}
+	}
+	return kes.KeyStoreState{
+		Latency: time.Since(start),
+	}, nil
+}
+
+// Create stores the value at the REST service if and only if no
+// entry with the given name exists. Otherwise, it returns
+// kes.ErrKeyExists.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	body, err := encodeValue(s.config.Create.Path, value)
+	if err != nil {
+		return err
+	}
+	resp, err := s.send(ctx, s.config.Create, http.MethodPut, name, "", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusPreconditionFailed:
+		return kesdk.ErrKeyExists
+	case !isSuccess(resp.StatusCode):
+		return statusError(resp, "failed to create '%s'", name)
+	}
+	return nil
+}
+
+// Set replaces the value of an existing entry. It returns
+// kes.ErrKeyNotFound if no such entry exists and an error
+// if no update operation is configured.
+func (s *Store) Set(ctx context.Context, name string, value []byte) error {
+	if s.config.Update.URL == "" {
+		return errors.New("rest: keystore cannot replace values: no update operation specified")
+	}
+	body, err := encodeValue(s.config.Update.Path, value)
+	if err != nil {
+		return err
+	}
+	resp, err := s.send(ctx, s.config.Update, http.MethodPut, name, "", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return kesdk.ErrKeyNotFound
+	case !isSuccess(resp.StatusCode):
+		return statusError(resp, "failed to update '%s'", name)
+	}
+	return nil
+}
+
+// Delete deletes the entry with the given name. It returns
+// kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	resp, err := s.send(ctx, s.config.Delete, http.MethodDelete, name, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return kesdk.ErrKeyNotFound
+	case !isSuccess(resp.StatusCode):
+		return statusError(resp, "failed to delete '%s'", name)
+	}
+	return nil
+}
+
+// Get returns the value of the entry with the given name. It
+// returns kes.ErrKeyNotFound if no such entry exists.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.send(ctx, s.config.Get, http.MethodGet, name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, kesdk.ErrKeyNotFound
+	case !isSuccess(resp.StatusCode):
+		return nil, statusError(resp, "failed to get '%s'", name)
+	}
+
+	var body any
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("rest: failed to get '%s': invalid response: %v", name, err)
+	}
+	values := lookup(body, s.config.Get.Path)
+	if len(values) != 1 {
+		return nil, fmt.Errorf("rest: failed to get '%s': no value at '%s'", name, s.config.Get.Path)
+	}
+	v, ok := values[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("rest: failed to get '%s': value at '%s' is not a string", name, s.config.Get.Path)
+	}
+	value, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("rest: failed to get '%s': value is not base64-encoded: %v", name, err)
+	}
+	return value, nil
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	resp, err := s.send(ctx, s.config.List, http.MethodGet, "", prefix, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if !isSuccess(resp.StatusCode) {
+		return nil, "", statusError(resp, "failed to list keys")
+	}
+
+	var body any
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, "", fmt.Errorf("rest: failed to list keys: invalid response: %v", err)
+	}
+
+	var names []string
+	for _, v := range lookup(body, s.config.List.Path) {
+		if name, ok := v.(string); ok && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the Store.
+func (s *Store) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// send sends the request described by the operation. It uses the
+// given default method if the operation does not specify one.
+func (s *Store) send(ctx context.Context, op Operation, method, name, prefix string, body []byte) (*http.Response, error) {
+	if op.Method != "" {
+		method = op.Method
+	}
+	uri := strings.NewReplacer(
+		"{name}", url.PathEscape(name),
+		"{prefix}", url.QueryEscape(prefix),
+	).Replace(op.URL)
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.config.Endpoint, "/")+uri, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range s.config.Header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.client.Do(req)
+}
+
+// encodeValue returns a JSON object with the base64-encoded value
+// at the given path. For example, the path "data.value" produces
+// {"data":{"value":"<base64>"}}.
+func encodeValue(path string, value []byte) ([]byte, error) {
+	var body any = base64.StdEncoding.EncodeToString(value)
+	segments := strings.Split(path, ".")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] == "*" {
+			return nil, fmt.Errorf("rest: invalid JSON path '%s': '*' is not supported for request bodies", path)
+		}
+		body = map[string]any{segments[i]: body}
+	}
+	return json.Marshal(body)
+}
+
+// lookup returns all values at the path within the JSON value v.
+// A '*' path segment selects all elements of a JSON array.
+func lookup(v any, path string) []any {
+	values := []any{v}
+	for _, segment := range strings.Split(path, ".") {
+		var next []any
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]any:
+				if elem, ok := v[segment]; ok {
+					next = append(next, elem)
+				}
+			case []any:
+				if segment == "*" {
+					next = append(next, v...)
+				}
+			}
+		}
+		values = next
+	}
+
+	// A path ending with an array selects all its elements,
+	// e.g. a list of key names.
+	if len(values) == 1 {
+		if array, ok := values[0].([]any); ok {
+			return array
+		}
+	}
+	return values
+}
+
+// isSuccess reports whether the HTTP status code is 2xx.
+func isSuccess(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices
+}
+
+// statusError returns an error for a response with an unexpected
+// status code.
+func statusError(resp *http.Response, format string, args ...any) error {
+	return keystore.StatusError(resp.StatusCode, fmt.Errorf("rest: "+format+" (status: %s)", append(args, resp.Status)...))
+}
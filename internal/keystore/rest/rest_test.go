@@ -0,0 +1,211 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	srv := httptest.NewServer(newSecretService("secret-token"))
+	defer srv.Close()
+
+	store, err := NewStore(&Config{
+		Endpoint: srv.URL,
+		Header:   http.Header{"Authorization": []string{"Bearer secret-token"}},
+		Create:   Operation{Method: http.MethodPost, URL: "/secrets/{name}", Path: "data.value"},
+		Get:      Operation{URL: "/secrets/{name}", Path: "data.value"},
+		Update:   Operation{URL: "/secrets/{name}", Path: "data.value"},
+		Delete:   Operation{URL: "/secrets/{name}"},
+		List:     Operation{URL: "/secrets?prefix={prefix}", Path: "items.*.name"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err = store.Status(ctx); err != nil {
+		t.Fatalf("Failed to fetch status: %v", err)
+	}
+
+	value := []byte("Hello World")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	value = []byte("Hello REST")
+	if err = store.Set(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	if err = store.Create(ctx, "other-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	names, prefix, err := store.List(ctx, "my-", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(names) != 1 || names[0] != "my-key" || prefix != "" {
+		t.Fatalf("Invalid listing: got '%v' and prefix '%s' - want '[my-key]'", names, prefix)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Getting deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(newSecretService("secret-token"))
+	defer srv.Close()
+
+	store, err := NewStore(&Config{
+		Endpoint: srv.URL,
+		Create:   Operation{URL: "/secrets/{name}", Path: "value"},
+		Get:      Operation{URL: "/secrets/{name}", Path: "value"},
+		Delete:   Operation{URL: "/secrets/{name}"},
+		List:     Operation{URL: "/secrets", Path: "items.*.name"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err = store.Get(context.Background(), "my-key"); err == nil || errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Getting key without credentials: got '%v' - want authentication error", err)
+	}
+	if err = store.Set(context.Background(), "my-key", []byte("Hello World")); err == nil {
+		t.Fatal("Setting key without update operation succeeded")
+	}
+}
+
+var lookupTests = []struct {
+	Body  string
+	Path  string
+	Value []any
+}{
+	{Body: `{"value":"a"}`, Path: "value", Value: []any{"a"}},
+	{Body: `{"data":{"value":"a"}}`, Path: "data.value", Value: []any{"a"}},
+	{Body: `{"data":{"value":"a"}}`, Path: "data.key", Value: nil},
+	{Body: `{"keys":["a","b"]}`, Path: "keys", Value: []any{"a", "b"}},
+	{Body: `{"items":[{"name":"a"},{"name":"b"},{}]}`, Path: "items.*.name", Value: []any{"a", "b"}},
+	{Body: `["a","b"]`, Path: "*", Value: []any{"a", "b"}},
+}
+
+func TestLookup(t *testing.T) {
+	for i, test := range lookupTests {
+		var body any
+		if err := json.Unmarshal([]byte(test.Body), &body); err != nil {
+			t.Fatalf("Test %d: failed to parse body: %v", i, err)
+		}
+		values := lookup(body, test.Path)
+		if len(values) != len(test.Value) {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, values, test.Value)
+		}
+		for j := range values {
+			if values[j] != test.Value[j] {
+				t.Fatalf("Test %d: got '%v' - want '%v'", i, values, test.Value)
+			}
+		}
+	}
+}
+
+// newSecretService returns an HTTP handler that behaves like a
+// simple secret service storing JSON documents under /secrets.
+func newSecretService(token string) http.Handler {
+	var (
+		mu      sync.Mutex
+		secrets = map[string]json.RawMessage{}
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path == "/secrets" {
+			type Item struct {
+				Name string `json:"name"`
+			}
+			var items []Item
+			for name := range secrets {
+				items = append(items, Item{Name: name})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"items": items})
+			return
+		}
+
+		name, ok := strings.CutPrefix(r.URL.Path, "/secrets/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, exists := secrets[name]
+		switch r.Method {
+		case http.MethodPost:
+			if exists {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			var body json.RawMessage
+			json.NewDecoder(r.Body).Decode(&body)
+			secrets[name] = body
+		case http.MethodPut:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var body json.RawMessage
+			json.NewDecoder(r.Body).Decode(&body)
+			secrets[name] = body
+		case http.MethodGet:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(secrets[name])
+		case http.MethodDelete:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(secrets, name)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package split implements a key store that splits every
+// value across two independent key stores such that both
+// are required to reconstruct it.
+package split
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/secmem"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// NewStore returns a new Store that splits all values across
+// the first and the second key store.
+//
+// Both key stores must store key material. Hence, NewStore
+// returns an error if either is a kes.CryptoKeyStore.
+func NewStore(first, second kes.KeyStore) (*Store, error) {
+	if _, ok := first.(kes.CryptoKeyStore); ok {
+		return nil, fmt.Errorf("split: keystore '%v' cannot store key shares", first)
+	}
+	if _, ok := second.(kes.CryptoKeyStore); ok {
+		return nil, fmt.Errorf("split: keystore '%v' cannot store key shares", second)
+	}
+	return &Store{
+		first:  first,
+		second: second,
+	}, nil
+}
+
+// Store is a key store that splits every value across two
+// independent key stores, e.g. CredHub and AWS SecretsManager,
+// for dual control over the key material.
+//
+// For every value, it stores a random share of the same length
+// at the first key store and the value XOR'ed with the share at
+// the second. Neither key store alone reveals anything about the
+// value. The second key store also stores an HMAC of the value,
+// keyed with the share, such that a share that does not belong
+// to the masked value is detected.
+//
+// A Store can replace values if both key stores implement
+// kes.MutableKeyStore. However, replacing a value modifies both
+// key stores one after another. Hence, concurrent reads may fail
+// until both key stores have been updated.
+type Store struct {
+	first, second kes.KeyStore
+}
+
+var _ kes.MutableKeyStore = (*Store)(nil) // compiler check
+
+var (
+	errInvalidShare = errors.New("split: key shares do not match")
+	errNotMutable   = errors.New("split: keystore cannot replace values")
+)
+
+func (s *Store) String() string { return fmt.Sprintf("Split: %v | %v", s.first, s.second) }
+
+// Status returns the current state of the Store. It is
+// unreachable if either key store is unreachable. The latency
+// is the latency of the slower key store.
+func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	first, err := s.first.Status(ctx)
+	if err != nil {
+		return kes.KeyStoreState{}, err
+	}
+	second, err := s.second.Status(ctx)
+	if err != nil {
+		return kes.KeyStoreState{}, err
+	}
+	return kes.KeyStoreState{
+		Latency: max(first.Latency, second.Latency),
+	}, nil
+}
+
+// Create splits the value and stores the shares at both key
+// stores if and only if no entry with the given name exists.
+// Otherwise, it returns kes.ErrKeyExists.
+//
+// If storing the second share fails, Create removes the first
+// share again.
+func (s *Store) Create(ctx context.Context, name string, value []byte) error {
+	share, masked, err := split(value)
+	if err != nil {
+		return err
+	}
+	defer secmem.Zero(share)
+
+	if err = s.first.Create(ctx, name, share); err != nil {
+		return err
+	}
+	if err = s.second.Create(ctx, name, masked); err != nil {
+		s.rollback(name)
+		return err
+	}
+	return nil
+}
+
+// Set replaces the value of an existing entry with the given
+// name. It returns kes.ErrKeyNotFound if no such entry exists.
+//
+// If storing the second share fails, Set restores the previous
+// first share.
+func (s *Store) Set(ctx context.Context, name string, value []byte) error {
+	first, ok := s.first.(kes.MutableKeyStore)
+	if !ok {
+		return errNotMutable
+	}
+	second, ok := s.second.(kes.MutableKeyStore)
+	if !ok {
+		return errNotMutable
+	}
+
+	prev, err := s.first.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer secmem.Zero(prev)
+
+	share, masked, err := split(value)
+	if err != nil {
+		return err
+	}
+	defer secmem.Zero(share)
+
+	if err = first.Set(ctx, name, share); err != nil {
+		return err
+	}
+	if err = second.Set(ctx, name, masked); err != nil {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if rErr := first.Set(ctx, name, prev); rErr != nil {
+			return fmt.Errorf("split: failed to restore first key share of '%s': %v: %v", name, rErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the shares of the entry at both key stores.
+// It returns no error if no such entry exists.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if err := s.second.Delete(ctx, name); err != nil && !errors.Is(err, kesdk.ErrKeyNotFound) {
+		return err
+	}
+	if err := s.first.Delete(ctx, name); err != nil && !errors.Is(err, kesdk.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Get fetches the shares of the entry at both key stores and
+// combines them to the value. It returns kes.ErrKeyNotFound if
+// no such entry exists.
+func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
+	share, err := s.first.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer secmem.Zero(share)
+
+	masked, err := s.second.Get(ctx, name)
+	if errors.Is(err, kesdk.ErrKeyNotFound) {
+		return nil, fmt.Errorf("split: second key share of '%s' not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return combine(share, masked)
+}
+
+// List returns the first n key names, that start with the given
+// prefix, and the next prefix from which the listing should
+// continue. It lists the names of the first key store.
+func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return s.first.List(ctx, prefix, n)
+}
+
+// Close closes both key stores.
+func (s *Store) Close() error {
+	return errors.Join(s.first.Close(), s.second.Close())
+}
+
+// rollback removes the first share of the entry with the
+// given name, even if the request has been canceled.
+func (s *Store) rollback(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = s.first.Delete(ctx, name)
+}
+
+// split returns a random share of the same length as value and
+// the value XOR'ed with the share followed by an HMAC-SHA256 of
+// the value, keyed with the share.
+func split(value []byte) (share, masked []byte, err error) {
+	if len(value) == 0 {
+		return nil, nil, errors.New("split: value is empty")
+	}
+
+	share = make([]byte, len(value))
+	if _, err = rand.Read(share); err != nil {
+		return nil, nil, err
+	}
+	masked = make([]byte, len(value), len(value)+sha256.Size)
+	for i := range value {
+		masked[i] = value[i] ^ share[i]
+	}
+	mac := hmac.New(sha256.New, share)
+	mac.Write(value)
+	return share, mac.Sum(masked), nil
+}
+
+// combine reverses split and returns the value. It returns an
+// error if the share does not belong to the masked value.
+func combine(share, masked []byte) ([]byte, error) {
+	if len(masked) != len(share)+sha256.Size {
+		return nil, errInvalidShare
+	}
+	masked, sum := masked[:len(share)], masked[len(share):]
+
+	value := make([]byte, len(share))
+	for i := range share {
+		value[i] = masked[i] ^ share[i]
+	}
+	mac := hmac.New(sha256.New, share)
+	mac.Write(value)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		secmem.Zero(value)
+		return nil, errInvalidShare
+	}
+	return value, nil
+}
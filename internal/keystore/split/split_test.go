@@ -0,0 +1,87 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package split
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestStore(t *testing.T) {
+	first, second := &kes.MemKeyStore{}, &kes.MemKeyStore{}
+	store, err := NewStore(first, second)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+	value := []byte("Hello World")
+	if err = store.Create(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", value); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+
+	share, _ := first.Get(ctx, "my-key")
+	masked, _ := second.Get(ctx, "my-key")
+	if bytes.Contains(share, value) || bytes.Contains(masked, value) {
+		t.Fatal("Key store contains the unsplit value")
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	value = []byte("Hello Split")
+	if err = store.Set(ctx, "my-key", value); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	if v, err := store.Get(ctx, "my-key"); err != nil || !bytes.Equal(v, value) {
+		t.Fatalf("Failed to get key: got '%s' - want '%s': %v", v, value, err)
+	}
+
+	// Combining the latest masked value with an outdated share
+	// must not produce a value.
+	if err = first.Set(ctx, "my-key", share); err != nil {
+		t.Fatalf("Failed to set first share: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, errInvalidShare) {
+		t.Fatalf("Getting key with outdated share: got '%v' - want '%v'", err, errInvalidShare)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = first.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("First share has not been deleted: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if _, err = second.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Second share has not been deleted: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+func TestStoreCreateRollback(t *testing.T) {
+	first, second := &kes.MemKeyStore{}, &kes.MemKeyStore{}
+	store, err := NewStore(first, second)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	ctx := context.Background()
+	if err = second.Create(ctx, "my-key", []byte("stale")); err != nil {
+		t.Fatalf("Failed to create second share: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("Hello World")); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating key with existing second share: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if _, err = first.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("First share has not been removed: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
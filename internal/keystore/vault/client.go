@@ -7,7 +7,10 @@ package vault
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
 	"path"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -104,9 +107,20 @@ func (c *client) AuthenticateWithK8S(login *Kubernetes) authFunc {
 			client = client.WithNamespace(login.Namespace)
 		}
 
+		// Kubernetes rotates projected service account tokens.
+		// Hence, we read the JWT file on every login.
+		jwt := login.JWT
+		if login.JWTFile != "" {
+			b, err := os.ReadFile(login.JWTFile)
+			if err != nil {
+				return nil, fmt.Errorf("vault: failed to read kubernetes JWT: %v", err)
+			}
+			jwt = strings.TrimSpace(string(b))
+		}
+
 		secret, err := client.Logical().WriteWithContext(ctx, path.Join("auth", login.Engine, "login"), map[string]interface{}{
 			"role": login.Role,
-			"jwt":  login.JWT,
+			"jwt":  jwt,
 		})
 		if secret == nil && err == nil {
 			// The Vault SDK eventually returns no error but also no
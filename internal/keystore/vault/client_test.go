@@ -0,0 +1,55 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+func TestAuthenticateWithK8S_JWTFile(t *testing.T) {
+	vault := &fakeVault{jwt: "jwt-1"}
+	srv := httptest.NewServer(vault)
+	defer srv.Close()
+
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtFile, []byte("jwt-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := vaultapi.DefaultConfig()
+	config.Address = srv.URL
+	vaultClient, err := vaultapi.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &client{Client: vaultClient}
+	authenticate := client.AuthenticateWithK8S(&Kubernetes{
+		Engine:  EngineKubernetes,
+		Role:    "kes",
+		JWT:     "ignored",
+		JWTFile: jwtFile,
+	})
+
+	if _, err = authenticate(context.Background()); err != nil {
+		t.Fatalf("Failed to authenticate: %v", err)
+	}
+
+	// Rotate the service account token
+	vault.mu.Lock()
+	vault.jwt = "jwt-2"
+	vault.mu.Unlock()
+	if err = os.WriteFile(jwtFile, []byte("jwt-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = authenticate(context.Background()); err != nil {
+		t.Fatalf("Failed to authenticate with rotated JWT: %v", err)
+	}
+}
@@ -20,6 +20,17 @@ const (
 	APIv2 = "v2"
 )
 
+const (
+	// TransitModeWrap encrypts the keys stored at the K/V engine
+	// with a single transit key.
+	TransitModeWrap = "wrap"
+
+	// TransitModeKeys stores no keys at the K/V engine. Instead,
+	// each key is a transit key and Vault performs all encrypt
+	// and decrypt operations. Key material never leaves Vault.
+	TransitModeKeys = "keys"
+)
+
 const (
 	// EngineKV is the Hashicorp Vault default KV secret engine path.
 	EngineKV = "kv"
@@ -34,6 +45,10 @@ const (
 	// EngineKubernetes is the Hashicorp Vault default Kubernetes
 	// authentication engine path.
 	EngineKubernetes = "kubernetes"
+
+	// ServiceAccountTokenFile is the path of the service account
+	// token that Kubernetes mounts into each pod by default.
+	ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
 // AppRole contains authentication information
@@ -113,6 +128,15 @@ type Kubernetes struct {
 
 	// JWT is the issued authentication token.
 	JWT string
+
+	// JWTFile is the path to a file containing the JWT, for
+	// example a projected service account token. The file is
+	// read on every login such that a rotated JWT is used when
+	// re-authenticating. If set, JWT is ignored.
+	//
+	// If JWT and JWTFile are empty, ServiceAccountTokenFile
+	// is used.
+	JWTFile string
 }
 
 // Clone returns a copy of the Kubernetes auth.
@@ -125,6 +149,7 @@ func (k *Kubernetes) Clone() *Kubernetes {
 		Namespace: k.Namespace,
 		Role:      k.Role,
 		JWT:       k.JWT,
+		JWTFile:   k.JWTFile,
 	}
 }
 
@@ -139,7 +164,12 @@ type Transit struct {
 
 	// KeyName is the name of the transit key
 	// used for en/decrypting K/V entries.
+	// It is ignored if Mode is TransitModeKeys.
 	KeyName string
+
+	// Mode controls how the transit engine is used.
+	// If empty, defaults to TransitModeWrap.
+	Mode string
 }
 
 // Clone returns a copy of the Transit.
@@ -150,6 +180,7 @@ func (t *Transit) Clone() *Transit {
 	return &Transit{
 		Engine:  t.Engine,
 		KeyName: t.KeyName,
+		Mode:    t.Mode,
 	}
 }
 
@@ -200,6 +231,15 @@ type Config struct {
 	// engine. It adds an additional layer of encryption.
 	Transit *Transit
 
+	// SoftDelete, if true, makes Delete only mark a key as
+	// deleted instead of removing all its versions and its
+	// metadata. It only applies to the K/V v2 engine.
+	//
+	// A soft-deleted key can be recovered with "vault kv undelete".
+	// However, its name cannot be used for a new key until it has
+	// been removed with "vault kv metadata delete".
+	SoftDelete bool
+
 	// StatusPingAfter is the duration after which
 	// the KeyStore will check the status of the Vault
 	// server. Particularly, this status information
@@ -242,6 +282,7 @@ func (c *Config) Clone() *Config {
 		AppRole:         c.AppRole.Clone(),
 		K8S:             c.K8S.Clone(),
 		Transit:         c.Transit.Clone(),
+		SoftDelete:      c.SoftDelete,
 		StatusPingAfter: c.StatusPingAfter,
 		PrivateKey:      c.PrivateKey,
 		Certificate:     c.Certificate,
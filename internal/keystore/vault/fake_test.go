@@ -0,0 +1,545 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/keystoretest"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	keystoretest.TestKeyStore(t, func(ctx context.Context) (kes.KeyStore, error) {
+		return Connect(ctx, &Config{
+			Endpoint: srv.URL,
+			Engine:   "kv",
+			Prefix:   "kes",
+			AppRole: &AppRole{
+				ID:     "role-id",
+				Secret: "secret-id",
+			},
+		})
+	})
+}
+
+func TestConformanceKVv2(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	keystoretest.TestKeyStore(t, func(ctx context.Context) (kes.KeyStore, error) {
+		return Connect(ctx, &Config{
+			Endpoint:   srv.URL,
+			Engine:     "kv2",
+			APIVersion: APIv2,
+			Prefix:     "kes",
+			AppRole: &AppRole{
+				ID:     "role-id",
+				Secret: "secret-id",
+			},
+		})
+	})
+}
+
+func TestSoftDeleteKVv2(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Endpoint:   srv.URL,
+		Engine:     "kv2",
+		APIVersion: APIv2,
+		Prefix:     "kes",
+		SoftDelete: true,
+		AppRole: &AppRole{
+			ID:     "role-id",
+			Secret: "secret-id",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to Vault: %v", err)
+	}
+	defer store.Close()
+
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = store.Get(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Reading soft-deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating soft-deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+}
+
+func TestMetadataKVv2(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := Connect(ctx, &Config{
+		Endpoint:   srv.URL,
+		Engine:     "kv2",
+		APIVersion: APIv2,
+		Prefix:     "kes",
+		SoftDelete: true,
+		AppRole: &AppRole{
+			ID:     "role-id",
+			Secret: "secret-id",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to Vault: %v", err)
+	}
+	defer store.Close()
+
+	var _ kes.MetadataKeyStore = store // compiler check
+
+	metadata := kes.EntryMetadata{
+		Algorithm: "AES256",
+		CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		CreatedBy: "my-identity",
+		Versions:  2,
+		RotatedAt: time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC),
+
+		Uses:       42,
+		LastUsedAt: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+	}
+	if err = store.SetMetadata(ctx, "my-key", metadata); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Setting metadata of non-existing key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Create(ctx, "my-key", []byte("my-value")); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if m, err := store.Metadata(ctx, "my-key"); err != nil || !m.IsEmpty() {
+		t.Fatalf("Metadata of key without metadata: got '%+v' - want empty metadata: %v", m, err)
+	}
+	if err = store.SetMetadata(ctx, "my-key", metadata); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+	m, err := store.Metadata(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if m.Algorithm != metadata.Algorithm || !m.CreatedAt.Equal(metadata.CreatedAt) || m.CreatedBy != metadata.CreatedBy || m.Versions != metadata.Versions || !m.RotatedAt.Equal(metadata.RotatedAt) || m.Uses != metadata.Uses || !m.LastUsedAt.Equal(metadata.LastUsedAt) {
+		t.Fatalf("Metadata mismatch: got '%+v' - want '%+v'", m, metadata)
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if m, err = store.Metadata(ctx, "my-key"); err != nil || !m.IsEmpty() {
+		t.Fatalf("Metadata of soft-deleted key: got '%+v' - want empty metadata: %v", m, err)
+	}
+}
+
+func TestTransitStore(t *testing.T) {
+	srv := httptest.NewServer(&fakeVault{})
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := ConnectTransit(ctx, &Config{
+		Endpoint: srv.URL,
+		Transit: &Transit{
+			Mode: TransitModeKeys,
+		},
+		AppRole: &AppRole{
+			ID:     "role-id",
+			Secret: "secret-id",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to Vault: %v", err)
+	}
+	defer store.Close()
+
+	if err = store.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = store.CreateKey(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kesdk.ErrKeyExists)
+	}
+	if err = store.Create(ctx, "my-secret", []byte("my-value")); err == nil {
+		t.Fatal("Storing a value at a transit key store succeeded")
+	}
+
+	ciphertext, err := store.Encrypt(ctx, "my-key", []byte("plaintext"), []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	plaintext, err := store.Decrypt(ctx, "my-key", ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if string(plaintext) != "plaintext" {
+		t.Fatalf("Decrypted plaintext mismatch: got '%s' - want '%s'", plaintext, "plaintext")
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("other context")); !errors.Is(err, kesdk.ErrDecrypt) {
+		t.Fatalf("Decrypting with wrong associated data: got '%v' - want '%v'", err, kesdk.ErrDecrypt)
+	}
+
+	names, _, err := store.List(ctx, "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if !slices.Equal(names, []string{"my-key"}) {
+		t.Fatalf("Listing keys: got '%v' - want '%v'", names, []string{"my-key"})
+	}
+
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err = store.Encrypt(ctx, "my-key", []byte("plaintext"), nil); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if _, err = store.Decrypt(ctx, "my-key", ciphertext, []byte("context")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Decrypting with deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+	if err = store.Delete(ctx, "my-key"); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting deleted key: got '%v' - want '%v'", err, kesdk.ErrKeyNotFound)
+	}
+}
+
+// fakeVault is an in-memory Vault server. It implements the
+// AppRole and Kubernetes login, the health API, the K/V v1
+// secret engine mounted at /v1/kv, the K/V v2 secret engine
+// mounted at /v1/kv2 and the transit engine mounted at
+// /v1/transit.
+type fakeVault struct {
+	mu       sync.Mutex
+	jwt      string                    // Kubernetes JWT accepted on login
+	entries  map[string]map[string]any // K/V path -> secret data
+	versions map[string][]*fakeVersion // K/V v2 path -> versions
+	custom   map[string]map[string]any // K/V v2 path -> custom metadata
+	transit  map[string]*fakeTransitKey
+}
+
+// fakeTransitKey is a transit engine key.
+type fakeTransitKey struct {
+	Key             []byte
+	DeletionAllowed bool
+}
+
+// fakeVersion is a version of a K/V v2 secret.
+type fakeVersion struct {
+	Data    map[string]any
+	Deleted bool
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	switch p := strings.TrimSuffix(r.URL.Path, "/"); {
+	case p == "/v1/sys/health":
+		writeJSON(w, http.StatusOK, map[string]any{"initialized": true, "sealed": false})
+	case p == "/v1/auth/approle/login" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		writeJSON(w, http.StatusOK, map[string]any{
+			"auth": map[string]any{"client_token": "fake-token", "lease_duration": 0},
+		})
+	case p == "/v1/auth/kubernetes/login" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var login struct {
+			Role string `json:"role"`
+			JWT  string `json:"jwt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login.JWT != v.jwt {
+			writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"auth": map[string]any{"client_token": "fake-token", "lease_duration": 0},
+		})
+	case r.Header.Get("X-Vault-Token") != "fake-token":
+		writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+	case strings.HasPrefix(p, "/v1/kv/"):
+		v.serveKV(w, r, strings.TrimPrefix(p, "/v1/kv/"))
+	case strings.HasPrefix(p, "/v1/kv2/"):
+		v.serveKVv2(w, r, strings.TrimPrefix(p, "/v1/kv2/"))
+	case strings.HasPrefix(p, "/v1/transit/"):
+		v.serveTransit(w, r, strings.TrimPrefix(p, "/v1/transit/"))
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+	}
+}
+
+func (v *fakeVault) serveKV(w http.ResponseWriter, r *http.Request, location string) {
+	if v.entries == nil {
+		v.entries = map[string]map[string]any{}
+	}
+
+	switch {
+	case r.Method == "LIST" || (r.Method == http.MethodGet && r.URL.Query().Get("list") == "true"):
+		var keys []string
+		for p := range v.entries {
+			if path.Dir(p) == location {
+				keys = append(keys, path.Base(p))
+			}
+		}
+		if len(keys) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		slices.Sort(keys)
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"keys": keys}})
+	case r.Method == http.MethodGet:
+		data, ok := v.entries[location]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": data})
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		var data map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+		v.entries[location] = data
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(v.entries, location)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"errors": []string{"method not allowed"}})
+	}
+}
+
+func (v *fakeVault) serveKVv2(w http.ResponseWriter, r *http.Request, p string) {
+	if v.versions == nil {
+		v.versions = map[string][]*fakeVersion{}
+	}
+	op, location, _ := strings.Cut(p, "/")
+	versions := v.versions[location]
+
+	switch {
+	case op == "metadata" && (r.Method == "LIST" || (r.Method == http.MethodGet && r.URL.Query().Get("list") == "true")):
+		var keys []string
+		for p := range v.versions {
+			if path.Dir(p) == location {
+				keys = append(keys, path.Base(p))
+			}
+		}
+		if len(keys) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		slices.Sort(keys)
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"keys": keys}})
+	case op == "metadata" && r.Method == http.MethodGet:
+		if len(versions) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		vs := map[string]any{}
+		for i, version := range versions {
+			metadata := map[string]any{"version": i + 1, "deletion_time": "", "destroyed": false}
+			if version.Deleted {
+				metadata["deletion_time"] = "2024-06-01T13:00:00Z"
+			}
+			vs[strconv.Itoa(i+1)] = metadata
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"current_version": len(versions),
+			"custom_metadata": v.custom[location],
+			"versions":        vs,
+		}})
+	case op == "metadata" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var req struct {
+			CustomMetadata map[string]any `json:"custom_metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+		if v.custom == nil {
+			v.custom = map[string]map[string]any{}
+		}
+		v.custom[location] = req.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+	case op == "metadata" && r.Method == http.MethodDelete:
+		delete(v.versions, location)
+		delete(v.custom, location)
+		w.WriteHeader(http.StatusNoContent)
+	case op == "delete" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var req struct {
+			Versions []any `json:"versions"` // Vault accepts numbers and strings
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+		for _, version := range req.Versions {
+			if n, _ := strconv.Atoi(fmt.Sprint(version)); n > 0 && n <= len(versions) {
+				versions[n-1].Deleted = true
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case op == "data" && r.Method == http.MethodGet:
+		n := len(versions)
+		if q := r.URL.Query().Get("version"); q != "" {
+			n, _ = strconv.Atoi(q)
+		}
+		if n <= 0 || n > len(versions) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		metadata := map[string]any{"version": n, "created_time": "2024-06-01T12:00:00Z", "deletion_time": "", "destroyed": false}
+		if versions[n-1].Deleted {
+			metadata["deletion_time"] = "2024-06-01T13:00:00Z"
+			writeJSON(w, http.StatusNotFound, map[string]any{"data": map[string]any{"data": nil, "metadata": metadata}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"data": versions[n-1].Data, "metadata": metadata}})
+	case op == "data" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		var req struct {
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+		if req.Options.CAS != nil && *req.Options.CAS != len(versions) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"check-and-set parameter did not match the current version"}})
+			return
+		}
+		v.versions[location] = append(versions, &fakeVersion{Data: req.Data})
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"version": len(versions) + 1}})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"errors": []string{"method not allowed"}})
+	}
+}
+
+func (v *fakeVault) serveTransit(w http.ResponseWriter, r *http.Request, p string) {
+	if v.transit == nil {
+		v.transit = map[string]*fakeTransitKey{}
+	}
+	op, name, _ := strings.Cut(p, "/")
+	name, config := strings.CutSuffix(name, "/config")
+	key, ok := v.transit[name]
+
+	var req struct {
+		Plaintext       []byte `json:"plaintext"`
+		Ciphertext      string `json:"ciphertext"`
+		AssociatedData  []byte `json:"associated_data"`
+		DeletionAllowed bool   `json:"deletion_allowed"`
+	}
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{err.Error()}})
+			return
+		}
+	}
+
+	switch {
+	case op == "keys" && name == "" && (r.Method == "LIST" || (r.Method == http.MethodGet && r.URL.Query().Get("list") == "true")):
+		var keys []string
+		for name := range v.transit {
+			keys = append(keys, name)
+		}
+		if len(keys) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		slices.Sort(keys)
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"keys": keys}})
+	case op == "keys" && r.Method == http.MethodGet:
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"errors": []string{}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"name": name, "type": "aes256-gcm96"}})
+	case op == "keys" && config:
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"encryption key not found"}})
+			return
+		}
+		key.DeletionAllowed = req.DeletionAllowed
+		w.WriteHeader(http.StatusNoContent)
+	case op == "keys" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		if !ok {
+			v.transit[name] = &fakeTransitKey{Key: make([]byte, 32)}
+			rand.Read(v.transit[name].Key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case op == "keys" && r.Method == http.MethodDelete:
+		if ok && !key.DeletionAllowed {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"deletion is not allowed for this key"}})
+			return
+		}
+		delete(v.transit, name)
+		w.WriteHeader(http.StatusNoContent)
+	case !ok && (op == "encrypt" || op == "decrypt"):
+		// Without the create capability, Vault rejects encrypting
+		// with a missing key with 403 and decrypting with 400.
+		if op == "encrypt" {
+			writeJSON(w, http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"encryption key not found"}})
+	case op == "encrypt":
+		block, _ := aes.NewCipher(key.Key)
+		aead, _ := cipher.NewGCM(block)
+		nonce := make([]byte, aead.NonceSize())
+		rand.Read(nonce)
+		ciphertext := aead.Seal(nonce, nonce, req.Plaintext, req.AssociatedData)
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(ciphertext),
+		}})
+	case op == "decrypt":
+		ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(req.Ciphertext, "vault:v1:"))
+		block, _ := aes.NewCipher(key.Key)
+		aead, _ := cipher.NewGCM(block)
+		if err != nil || len(ciphertext) < aead.NonceSize() {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"invalid ciphertext"}})
+			return
+		}
+		plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], req.AssociatedData)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []string{"cipher: message authentication failed"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+		}})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"errors": []string{"method not allowed"}})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,262 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"aead.dev/mem"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// TransitStore is a Hashicorp Vault key store that keeps each key
+// as transit key at the Vault transit engine. It stores no keys
+// at the K/V engine. Instead, Vault creates the keys and performs
+// all encrypt and decrypt operations. Hence, key material never
+// leaves Vault.
+//
+// A TransitStore cannot store arbitrary values, like secrets. Its
+// Create and Get methods always fail.
+//
+// The Vault policy of KES should not grant the "create" capability
+// on the transit encrypt path. Otherwise, Vault creates a transit
+// key when KES encrypts data with a key that does not exist.
+type TransitStore struct {
+	store *Store
+}
+
+var _ kes.CryptoKeyStore = (*TransitStore)(nil) // compiler check
+
+// ConnectTransit connects to a Hashicorp Vault server with the
+// given configuration. The transit mode must be TransitModeKeys.
+func ConnectTransit(ctx context.Context, c *Config) (*TransitStore, error) {
+	if c.Transit == nil || c.Transit.Mode != TransitModeKeys {
+		return nil, fmt.Errorf("vault: transit mode is not '%s'", TransitModeKeys)
+	}
+	store, err := connect(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return &TransitStore{store: store}, nil
+}
+
+var errTransitValues = errors.New("vault: transit key store cannot store values")
+
+func (s *TransitStore) String() string { return "Hashicorp Vault Transit: " + s.store.config.Endpoint }
+
+// Status returns the current state of the Hashicorp Vault instance.
+// In particular, whether it is reachable and the network latency.
+func (s *TransitStore) Status(ctx context.Context) (kes.KeyStoreState, error) {
+	return s.store.Status(ctx)
+}
+
+// Create always fails since a TransitStore does not store values.
+// Use CreateKey to create a transit key.
+func (*TransitStore) Create(context.Context, string, []byte) error { return errTransitValues }
+
+// Get always fails since a TransitStore does not store values.
+func (*TransitStore) Get(context.Context, string) ([]byte, error) { return nil, errTransitValues }
+
+// CreateKey creates a new transit key with the given name. It
+// returns kes.ErrKeyExists if such a transit key exists already.
+func (s *TransitStore) CreateKey(ctx context.Context, name string) error {
+	if s.store.client.Sealed() {
+		return errSealed
+	}
+
+	// Vault does not fail when creating a transit key that exists
+	// already. Hence, we have to check whether it exists first.
+	// Like Store.Create, this is not atomic.
+	switch exists, err := s.exists(ctx, name); {
+	case err != nil:
+		return fmt.Errorf("vault: failed to create '%s': %v", name, err)
+	case exists:
+		return kesdk.ErrKeyExists
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#create-key
+	location := path.Join(s.store.config.Transit.Engine, "keys", name)
+	if _, err := s.store.client.Logical().WriteWithContext(ctx, location, map[string]any{
+		"type": "aes256-gcm96",
+	}); err != nil {
+		return statusError(err, fmt.Errorf("vault: failed to create '%s': %v", location, err))
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the transit key with the
+// given name. It returns kes.ErrKeyNotFound if no such transit
+// key exists.
+func (s *TransitStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	if s.store.client.Sealed() {
+		return nil, errSealed
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#encrypt-data
+	location := path.Join(s.store.config.Transit.Engine, "encrypt", name)
+	data := map[string]any{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	}
+	if len(associatedData) > 0 {
+		data["associated_data"] = base64.StdEncoding.EncodeToString(associatedData)
+	}
+	secret, err := s.store.client.Logical().WriteWithContext(ctx, location, data)
+	if err != nil {
+		return nil, s.keyError(ctx, name, fmt.Errorf("vault: failed to encrypt with '%s': %v", name, err))
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("vault: failed to encrypt with '%s': empty vault response", name)
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault: failed to encrypt with '%s': no ciphertext in vault response", name)
+	}
+	return []byte(ciphertext), nil
+}
+
+// Decrypt decrypts the ciphertext with the transit key with the
+// given name. It returns kes.ErrKeyNotFound if no such transit
+// key exists.
+func (s *TransitStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	if s.store.client.Sealed() {
+		return nil, errSealed
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#decrypt-data
+	location := path.Join(s.store.config.Transit.Engine, "decrypt", name)
+	data := map[string]any{
+		"ciphertext": string(ciphertext),
+	}
+	if len(associatedData) > 0 {
+		data["associated_data"] = base64.StdEncoding.EncodeToString(associatedData)
+	}
+	secret, err := s.store.client.Logical().WriteWithContext(ctx, location, data)
+	if err != nil {
+		var rErr *vaultapi.ResponseError
+		if errors.As(err, &rErr) && rErr.StatusCode == http.StatusBadRequest {
+			// Vault responds with 400 Bad Request if the key does not
+			// exist or the ciphertext is not authentic.
+			if err = s.keyError(ctx, name, err); errors.Is(err, kesdk.ErrKeyNotFound) {
+				return nil, err
+			}
+			return nil, kesdk.ErrDecrypt
+		}
+		return nil, s.keyError(ctx, name, fmt.Errorf("vault: failed to decrypt with '%s': %v", name, err))
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("vault: failed to decrypt with '%s': empty vault response", name)
+	}
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault: failed to decrypt with '%s': no plaintext in vault response", name)
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+// Delete deletes the transit key with the given name. Vault
+// refuses to delete transit keys unless deletion has been
+// allowed explicitly. Hence, Delete allows it first.
+func (s *TransitStore) Delete(ctx context.Context, name string) error {
+	if s.store.client.Sealed() {
+		return errSealed
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#update-key-configuration
+	location := path.Join(s.store.config.Transit.Engine, "keys", name)
+	if _, err := s.store.client.Logical().WriteWithContext(ctx, path.Join(location, "config"), map[string]any{
+		"deletion_allowed": true,
+	}); err != nil {
+		return s.keyError(ctx, name, fmt.Errorf("vault: failed to delete '%s': %v", location, err))
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#delete-key
+	if _, err := s.store.client.Logical().DeleteWithContext(ctx, location); err != nil {
+		return statusError(err, fmt.Errorf("vault: failed to delete '%s': %v", location, err))
+	}
+	return nil
+}
+
+// List returns the first n transit key names, that start with the
+// given prefix, and the next prefix from which the listing should
+// continue.
+//
+// It returns all keys with the prefix if n < 0 and less than n
+// names if n is greater than the number of keys with the prefix.
+//
+// An empty prefix matches any key name. At the end of the listing
+// or when there are no (more) keys starting with the prefix, the
+// returned prefix is empty.
+func (s *TransitStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	if s.store.client.Sealed() {
+		return nil, "", errSealed
+	}
+
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#list-keys
+	location := path.Join(s.store.config.Transit.Engine, "keys")
+	resp, err := s.store.client.Logical().ReadRawWithDataWithContext(ctx, location, map[string][]string{"list": {"true"}})
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return []string{}, "", nil // Vault responds with 404 if there are no keys
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("vault: failed to list '%s': %v", location, err)
+	}
+
+	const MaxBody = 32 * mem.MiB
+	secret, err := vaultapi.ParseSecret(mem.LimitReader(resp.Body, MaxBody))
+	if err != nil {
+		return nil, "", fmt.Errorf("vault: failed to list '%s': %v", location, err)
+	}
+	if secret == nil {
+		return []string{}, "", nil
+	}
+	values, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("vault: failed to list '%s': invalid key listing format", location)
+	}
+	names := make([]string, 0, len(values))
+	for _, v := range values {
+		names = append(names, fmt.Sprint(v))
+	}
+	return keystore.List(names, prefix, n)
+}
+
+// Close closes the TransitStore. It stops any authentication renewal
+// in the background.
+func (s *TransitStore) Close() error { return s.store.Close() }
+
+// exists reports whether a transit key with the given name exists.
+//
+// See: https://developer.hashicorp.com/vault/api-docs/secret/transit#read-key
+func (s *TransitStore) exists(ctx context.Context, name string) (bool, error) {
+	secret, err := s.store.client.Logical().ReadWithContext(ctx, path.Join(s.store.config.Transit.Engine, "keys", name))
+	if err != nil {
+		return false, err
+	}
+	return secret != nil, nil
+}
+
+// keyError returns kes.ErrKeyNotFound if no transit key with the
+// given name exists. Vault does not report missing transit keys
+// consistently. Depending on the operation and the Vault policy,
+// it responds with 400 Bad Request or 403 Forbidden. Hence,
+// keyError checks whether the key exists once an operation failed.
+// Otherwise, it returns err.
+func (s *TransitStore) keyError(ctx context.Context, name string, err error) error {
+	if exists, eErr := s.exists(ctx, name); eErr == nil && !exists {
+		return kesdk.ErrKeyNotFound
+	}
+	return err
+}
@@ -20,6 +20,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,7 +40,18 @@ type Store struct {
 
 // Connect connects to a Hashicorp Vault server with
 // the given configuration.
+//
+// Use ConnectTransit if the transit mode is TransitModeKeys.
 func Connect(ctx context.Context, c *Config) (*Store, error) {
+	if c.Transit != nil && c.Transit.Mode == TransitModeKeys {
+		return nil, errors.New("vault: transit mode 'keys' requires a transit key store")
+	}
+	return connect(ctx, c)
+}
+
+// connect connects to a Hashicorp Vault server, authenticates
+// and keeps the authentication token up to date.
+func connect(ctx context.Context, c *Config) (*Store, error) {
 	c = c.Clone()
 
 	if c.Engine == "" {
@@ -57,11 +69,17 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 		if c.K8S.Engine == "" {
 			c.K8S.Engine = EngineKubernetes
 		}
+		if c.K8S.Role != "" && c.K8S.JWT == "" && c.K8S.JWTFile == "" {
+			c.K8S.JWTFile = ServiceAccountTokenFile
+		}
 	}
 	if c.Transit != nil {
 		if c.Transit.Engine == "" {
 			c.Transit.Engine = EngineTransit
 		}
+		if c.Transit.Mode == "" {
+			c.Transit.Mode = TransitModeWrap
+		}
 	}
 	if c.StatusPingAfter == 0 {
 		c.StatusPingAfter = 15 * time.Second
@@ -74,15 +92,18 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 		return nil, fmt.Errorf("vault: invalid engine API version '%s'", c.APIVersion)
 	}
 	if c.AppRole != nil && c.K8S != nil {
-		if (c.AppRole.ID == "" || c.AppRole.Secret == "") && (c.K8S.JWT == "" || c.K8S.Role == "") {
+		if (c.AppRole.ID == "" || c.AppRole.Secret == "") && ((c.K8S.JWT == "" && c.K8S.JWTFile == "") || c.K8S.Role == "") {
 			return nil, errors.New("vault: no authentication method specified")
 		}
-		if (c.AppRole.ID != "" || c.AppRole.Secret != "") && (c.K8S.JWT != "" || c.K8S.Role != "") {
+		if (c.AppRole.ID != "" || c.AppRole.Secret != "") && (c.K8S.JWT != "" || c.K8S.JWTFile != "" || c.K8S.Role != "") {
 			return nil, errors.New("vault: more than one authentication method specified: approle and kubernetes configuration is present")
 		}
 	}
 	if c.Transit != nil {
-		if c.Transit.KeyName == "" {
+		if c.Transit.Mode != TransitModeWrap && c.Transit.Mode != TransitModeKeys {
+			return nil, fmt.Errorf("vault: invalid transit mode '%s'", c.Transit.Mode)
+		}
+		if c.Transit.Mode == TransitModeWrap && c.Transit.KeyName == "" {
 			return nil, errors.New("vault: transit key name is empty")
 		}
 	}
@@ -127,8 +148,10 @@ func Connect(ctx context.Context, c *Config) (*Store, error) {
 	switch {
 	case c.AppRole != nil && (c.AppRole.ID != "" || c.AppRole.Secret != ""):
 		authenticate = client.AuthenticateWithAppRole(c.AppRole)
-	case c.K8S != nil && (c.K8S.Role != "" || c.K8S.JWT != ""):
+	case c.K8S != nil && (c.K8S.Role != "" || c.K8S.JWT != "" || c.K8S.JWTFile != ""):
 		authenticate = client.AuthenticateWithK8S(c.K8S)
+	default:
+		return nil, errors.New("vault: no authentication method specified")
 	}
 
 	auth, err := authenticate(ctx)
@@ -177,7 +200,10 @@ func (s *Store) Status(ctx context.Context) (kes.KeyStoreState, error) {
 		case health.Sealed:
 			return kes.KeyStoreState{}, &keystore.ErrUnreachable{Err: errSealed}
 		default:
-			return kes.KeyStoreState{Latency: time.Since(start)}, nil
+			return kes.KeyStoreState{
+				Latency: time.Since(start),
+				Version: health.Version,
+			}, nil
 		}
 	}
 	if errors.Is(err, context.Canceled) && errors.Is(err, context.DeadlineExceeded) {
@@ -237,9 +263,16 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	case err == nil && secret != nil && s.config.APIVersion == APIv2 && len(secret.Data) > 0:
 		data := secret.Data
 		v, ok := data["data"]
-		if !ok || v == nil {
+		if !ok {
 			return fmt.Errorf("vault: entry exists but failed to read '%s': invalid K/V v2 format: missing 'data' entry", location)
 		}
+		if v == nil {
+			// The latest version has been deleted or destroyed. Its
+			// metadata still exists such that a create with cas=0
+			// would fail. The key has to be removed permanently before
+			// a new key with the same name can be created.
+			return fmt.Errorf("vault: '%s' has been deleted but not removed permanently: %w", location, kesdk.ErrKeyExists)
+		}
 		data, ok = v.(map[string]interface{})
 		if !ok || data == nil {
 			return fmt.Errorf("vault: entry exists but failed to read '%s': invalid K/V v2 format: invalid 'data' entry", location)
@@ -249,7 +282,7 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 		}
 		return kesdk.ErrKeyExists
 	case err != nil:
-		return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+		return statusError(err, fmt.Errorf("vault: failed to create '%s': %v", location, err))
 	}
 
 	if s.config.Transit != nil {
@@ -272,7 +305,7 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 			if _, err = vaultapi.ParseSecret(resp.Body); err != nil {
 				return fmt.Errorf("vault: failed to create '%s': failed to encrypt key: %v", location, err)
 			}
-			return fmt.Errorf("vault: failed to create '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode)
+			return keystore.StatusError(resp.StatusCode, fmt.Errorf("vault: failed to create '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode))
 		}
 
 		secret, err := vaultapi.ParseSecret(resp.Body)
@@ -321,11 +354,16 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	// error.
 	req := s.client.Client.NewRequest(http.MethodPut, "/v1/"+location)
 	if err := req.SetJSONBody(data); err != nil {
-		return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+		return statusError(err, fmt.Errorf("vault: failed to create '%s': %v", location, err))
 	}
 	resp, err := s.client.Client.RawRequestWithContext(ctx, req)
 	if err != nil {
-		return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+		// With cas=0, K/V v2 rejects the write if the entry has been
+		// created concurrently, e.g. by another KES server.
+		if s.config.APIVersion == APIv2 && isCASMismatch(err) {
+			return fmt.Errorf("vault: '%s' has been created concurrently: %w", location, kesdk.ErrKeyExists)
+		}
+		return statusError(err, fmt.Errorf("vault: failed to create '%s': %v", location, err))
 	}
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
@@ -335,9 +373,9 @@ func (s *Store) Create(ctx context.Context, name string, value []byte) error {
 	// We have to check both status codes. Ref: https://github.com/minio/kes-go/issues/224
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
 		if _, err = vaultapi.ParseSecret(resp.Body); err != nil {
-			return fmt.Errorf("vault: failed to create '%s': %v", location, err)
+			return statusError(err, fmt.Errorf("vault: failed to create '%s': %v", location, err))
 		}
-		return fmt.Errorf("vault: failed to read '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode)
+		return keystore.StatusError(resp.StatusCode, fmt.Errorf("vault: failed to read '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode))
 	}
 	return nil
 }
@@ -355,7 +393,12 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 		err      error
 	)
 	if s.config.APIVersion == APIv2 {
+		// Keys are immutable. Hence, we always read the first version.
+		// Later versions, if any, have not been created by KES.
 		entry, err = s.client.KVv2(s.config.Engine).GetVersion(ctx, location, 1)
+		if err == nil && entry != nil && (entry.Data == nil || isDeleted(entry.VersionMetadata)) {
+			return nil, kesdk.ErrKeyNotFound // The version has been deleted or destroyed
+		}
 	} else {
 		entry, err = s.client.KVv1(s.config.Engine).Get(ctx, location)
 	}
@@ -365,7 +408,7 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 		if (err == nil && entry == nil) || errors.Is(err, vaultapi.ErrSecretNotFound) {
 			return nil, kesdk.ErrKeyNotFound
 		}
-		return nil, fmt.Errorf("vault: failed to read '%s': %v", location, err)
+		return nil, statusError(err, fmt.Errorf("vault: failed to read '%s': %v", location, err))
 	}
 
 	// Verify that we got a well-formed response from Vault
@@ -403,7 +446,7 @@ func (s *Store) Get(ctx context.Context, name string) ([]byte, error) {
 			if _, err = vaultapi.ParseSecret(resp.Body); err != nil {
 				return nil, fmt.Errorf("vault: failed to read '%s': failed to encrypt key: %v", location, err)
 			}
-			return nil, fmt.Errorf("vault: failed to read '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode)
+			return nil, keystore.StatusError(resp.StatusCode, fmt.Errorf("vault: failed to read '%s': server responded with: %s (%d)", location, resp.Status, resp.StatusCode))
 		}
 
 		secret, err := vaultapi.ParseSecret(resp.Body)
@@ -434,19 +477,214 @@ func (s *Store) Delete(ctx context.Context, name string) error {
 		location = path.Join(s.config.Prefix, name)
 		err      error
 	)
-	if s.config.APIVersion == APIv2 {
+	switch {
+	case s.config.APIVersion == APIv2 && s.config.SoftDelete:
+		// See: https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#delete-secret-versions
+		err = s.client.KVv2(s.config.Engine).DeleteVersions(ctx, location, []int{1})
+	case s.config.APIVersion == APIv2:
 		// See: https://www.vaultproject.io/api/secret/kv/kv-v2#delete-metadata-and-all-versions
 		err = s.client.KVv2(s.config.Engine).DeleteMetadata(ctx, location)
-	} else {
+	default:
 		// See: https://www.vaultproject.io/api/secret/kv/kv-v1#delete-secret
 		err = s.client.KVv1(s.config.Engine).Delete(ctx, location)
 	}
 	if err != nil {
-		return fmt.Errorf("vault: failed to delete '%s': %v", location, err)
+		return statusError(err, fmt.Errorf("vault: failed to delete '%s': %v", location, err))
 	}
 	return nil
 }
 
+// Metadata returns the metadata of the entry with the given name.
+// It is stored as K/V v2 custom metadata. Hence, Metadata returns
+// an empty EntryMetadata for the K/V v1 engine or if the entry has
+// no custom metadata.
+func (s *Store) Metadata(ctx context.Context, name string) (kes.EntryMetadata, error) {
+	if s.config.APIVersion != APIv2 {
+		return kes.EntryMetadata{}, nil
+	}
+	if s.client.Sealed() {
+		return kes.EntryMetadata{}, errSealed
+	}
+
+	location := path.Join(s.config.Prefix, name)
+	metadata, err := s.client.KVv2(s.config.Engine).GetMetadata(ctx, location)
+	if errors.Is(err, vaultapi.ErrSecretNotFound) {
+		return kes.EntryMetadata{}, nil
+	}
+	if err != nil {
+		return kes.EntryMetadata{}, statusError(err, fmt.Errorf("vault: failed to read metadata of '%s': %v", location, err))
+	}
+	if v, ok := metadata.Versions["1"]; ok && isDeleted(&v) {
+		return kes.EntryMetadata{}, nil // The metadata of soft-deleted entries remains
+	}
+	return parseMetadata(metadata.CustomMetadata), nil
+}
+
+// SetMetadata stores the metadata of the entry with the given name
+// as K/V v2 custom metadata. Custom metadata set by others is
+// replaced. SetMetadata does nothing for the K/V v1 engine.
+func (s *Store) SetMetadata(ctx context.Context, name string, metadata kes.EntryMetadata) error {
+	if s.config.APIVersion != APIv2 {
+		return nil
+	}
+	if s.client.Sealed() {
+		return errSealed
+	}
+
+	location := path.Join(s.config.Prefix, name)
+	kv := s.client.KVv2(s.config.Engine)
+	if _, err := kv.GetMetadata(ctx, location); err != nil {
+		if errors.Is(err, vaultapi.ErrSecretNotFound) {
+			return kesdk.ErrKeyNotFound
+		}
+		return statusError(err, fmt.Errorf("vault: failed to read metadata of '%s': %v", location, err))
+	}
+
+	// We only send the custom metadata. Vault keeps all other
+	// metadata fields, like max_versions, unchanged.
+	// See: https://developer.hashicorp.com/vault/api-docs/secret/kv/kv-v2#create-update-metadata
+	_, err := s.client.Logical().WriteWithContext(ctx, path.Join(s.config.Engine, "metadata", location), map[string]any{
+		"custom_metadata": encodeMetadata(metadata),
+	})
+	if err != nil {
+		return fmt.Errorf("vault: failed to write metadata of '%s': %v", location, err)
+	}
+	return nil
+}
+
+// tagPrefix is the prefix of K/V v2 custom metadata
+// keys storing key tags.
+const tagPrefix = "tag:"
+
+// grantPrefix is the prefix of K/V v2 custom metadata
+// keys storing the operations granted to an identity.
+const grantPrefix = "grant:"
+
+// encodeMetadata returns the EntryMetadata as K/V v2 custom
+// metadata. Vault only supports string values. Each tag is
+// stored as separate custom metadata key with the tagPrefix.
+func encodeMetadata(m kes.EntryMetadata) map[string]any {
+	custom := map[string]any{
+		"algorithm":  m.Algorithm,
+		"created_at": m.CreatedAt.Format(time.RFC3339Nano),
+		"created_by": m.CreatedBy.String(),
+		"versions":   strconv.Itoa(m.Versions),
+	}
+	if !m.RotatedAt.IsZero() {
+		custom["rotated_at"] = m.RotatedAt.Format(time.RFC3339Nano)
+	}
+	if m.Uses > 0 {
+		custom["uses"] = strconv.FormatUint(m.Uses, 10)
+	}
+	if !m.LastUsedAt.IsZero() {
+		custom["last_used_at"] = m.LastUsedAt.Format(time.RFC3339Nano)
+	}
+	if m.Protected {
+		custom["protected"] = "true"
+	}
+	if len(m.Operations) > 0 {
+		custom["operations"] = strings.Join(m.Operations, ",")
+	}
+	for key, value := range m.Tags {
+		custom[tagPrefix+key] = value
+	}
+	for identity, ops := range m.Grants {
+		custom[grantPrefix+identity.String()] = strings.Join(ops, ",")
+	}
+	return custom
+}
+
+// parseMetadata parses K/V v2 custom metadata created by
+// encodeMetadata. It returns an empty EntryMetadata if the
+// custom metadata is missing or malformed.
+func parseMetadata(custom map[string]any) kes.EntryMetadata {
+	str := func(key string) string {
+		s, _ := custom[key].(string)
+		return s
+	}
+
+	versions, err := strconv.Atoi(str("versions"))
+	if err != nil || versions <= 0 {
+		return kes.EntryMetadata{}
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, str("created_at"))
+	if err != nil {
+		return kes.EntryMetadata{}
+	}
+	var rotatedAt time.Time
+	if s := str("rotated_at"); s != "" {
+		if rotatedAt, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return kes.EntryMetadata{}
+		}
+	}
+
+	// Key usage is informational. We ignore malformed
+	// usage rather than rejecting the entire metadata.
+	uses, _ := strconv.ParseUint(str("uses"), 10, 64)
+	lastUsedAt, _ := time.Parse(time.RFC3339Nano, str("last_used_at"))
+
+	var (
+		tags   map[string]string
+		grants map[kesdk.Identity][]string
+	)
+	for key := range custom {
+		if name, ok := strings.CutPrefix(key, tagPrefix); ok {
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			tags[name] = str(key)
+		}
+		if identity, ok := strings.CutPrefix(key, grantPrefix); ok && str(key) != "" {
+			if grants == nil {
+				grants = map[kesdk.Identity][]string{}
+			}
+			grants[kesdk.Identity(identity)] = strings.Split(str(key), ",")
+		}
+	}
+	var operations []string
+	if s := str("operations"); s != "" {
+		operations = strings.Split(s, ",")
+	}
+	return kes.EntryMetadata{
+		Algorithm: str("algorithm"),
+		CreatedAt: createdAt,
+		CreatedBy: kesdk.Identity(str("created_by")),
+		Versions:  versions,
+		RotatedAt: rotatedAt,
+
+		Uses:       uses,
+		LastUsedAt: lastUsedAt,
+
+		Tags:       tags,
+		Protected:  str("protected") == "true",
+		Operations: operations,
+
+		Grants: grants,
+	}
+}
+
+// isDeleted reports whether the K/V v2 version has been
+// deleted or destroyed.
+func isDeleted(v *vaultapi.KVVersionMetadata) bool {
+	return v != nil && (v.Destroyed || !v.DeletionTime.IsZero())
+}
+
+// isCASMismatch reports whether err indicates that a K/V v2
+// write has been rejected since the check-and-set version did
+// not match the current version.
+func isCASMismatch(err error) bool {
+	var rErr *vaultapi.ResponseError
+	if !errors.As(err, &rErr) || rErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, msg := range rErr.Errors {
+		if strings.Contains(msg, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
 // List returns the first n key names, that start with the given
 // prefix, and the next prefix from which the listing should
 // continue.
@@ -478,10 +716,15 @@ func (s *Store) List(ctx context.Context, prefix string, n int) ([]string, strin
 	}
 
 	resp, err := s.client.Logical().ReadRawWithDataWithContext(ctx, location, map[string][]string{"list": {"true"}})
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return []string{}, "", nil // Vault responds with 404 if there are no entries
+	}
 	if err != nil {
 		return nil, "", fmt.Errorf("vault: failed to list '%s': %v", location, err)
 	}
-	defer resp.Body.Close()
 
 	// Vault returns all keys in one request and does not provide a
 	// (reasonable) way to parse the response in batches or use some
@@ -516,3 +759,15 @@ func (s *Store) Close() error {
 	s.stop()
 	return nil
 }
+
+// statusError returns err as keystore error with a stable error
+// code, like keystore.ErrThrottled, if cause is a Vault response
+// error with a status code that indicates that the request has
+// been throttled or denied, or that Vault is unavailable.
+func statusError(cause, err error) error {
+	var rErr *vaultapi.ResponseError
+	if errors.As(cause, &rErr) {
+		return keystore.StatusError(rErr.StatusCode, err)
+	}
+	return err
+}
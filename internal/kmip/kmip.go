@@ -0,0 +1,223 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kmip
+
+import "time"
+
+// KMIP tags used by the KES KMIP API.
+const (
+	TagAttribute                  Tag = 0x420008
+	TagAttributeName              Tag = 0x42000A
+	TagAttributeValue             Tag = 0x42000B
+	TagBatchCount                 Tag = 0x42000D
+	TagBatchItem                  Tag = 0x42000F
+	TagCryptographicAlgorithm     Tag = 0x420028
+	TagCryptographicLength        Tag = 0x42002A
+	TagKeyBlock                   Tag = 0x420040
+	TagKeyFormatType              Tag = 0x420042
+	TagKeyMaterial                Tag = 0x420043
+	TagKeyValue                   Tag = 0x420045
+	TagMaximumResponseSize        Tag = 0x420050
+	TagName                       Tag = 0x420053
+	TagNameType                   Tag = 0x420054
+	TagNameValue                  Tag = 0x420055
+	TagObjectType                 Tag = 0x420057
+	TagOperation                  Tag = 0x42005C
+	TagProtocolVersion            Tag = 0x420069
+	TagProtocolVersionMajor       Tag = 0x42006A
+	TagProtocolVersionMinor       Tag = 0x42006B
+	TagRequestHeader              Tag = 0x420077
+	TagRequestMessage             Tag = 0x420078
+	TagRequestPayload             Tag = 0x420079
+	TagResponseHeader             Tag = 0x42007A
+	TagResponseMessage            Tag = 0x42007B
+	TagResponsePayload            Tag = 0x42007C
+	TagResultMessage              Tag = 0x42007D
+	TagResultReason               Tag = 0x42007E
+	TagResultStatus               Tag = 0x42007F
+	TagSymmetricKey               Tag = 0x42008F
+	TagTemplateAttribute          Tag = 0x420091
+	TagTimeStamp                  Tag = 0x420092
+	TagUniqueBatchItemID          Tag = 0x420093
+	TagUniqueIdentifier           Tag = 0x420094
+	TagData                       Tag = 0x4200C2
+	TagAuthenticatedEncryptionAAD Tag = 0x4200FE
+	TagAttributes                 Tag = 0x420125
+)
+
+// Operation is a KMIP operation.
+type Operation uint32
+
+// KMIP operations supported by the KES KMIP API.
+const (
+	OpCreate           Operation = 0x01
+	OpGet              Operation = 0x0A
+	OpDestroy          Operation = 0x14
+	OpDiscoverVersions Operation = 0x1E
+	OpEncrypt          Operation = 0x1F
+	OpDecrypt          Operation = 0x20
+)
+
+// ResultStatus is the status of a KMIP batch item.
+type ResultStatus uint32
+
+// KMIP result status values.
+const (
+	StatusSuccess         ResultStatus = 0x00
+	StatusOperationFailed ResultStatus = 0x01
+)
+
+// ResultReason is the reason of a failed KMIP batch item.
+type ResultReason uint32
+
+// KMIP result reasons.
+const (
+	ReasonItemNotFound                ResultReason = 0x01
+	ReasonResponseTooLarge            ResultReason = 0x02
+	ReasonAuthenticationNotSuccessful ResultReason = 0x03
+	ReasonInvalidMessage              ResultReason = 0x04
+	ReasonOperationNotSupported       ResultReason = 0x05
+	ReasonMissingData                 ResultReason = 0x06
+	ReasonInvalidField                ResultReason = 0x07
+	ReasonCryptographicFailure        ResultReason = 0x0A
+	ReasonPermissionDenied            ResultReason = 0x0C
+	ReasonGeneralFailure              ResultReason = 0x100
+)
+
+// KMIP enumeration values.
+const (
+	ObjectTypeSymmetricKey uint32 = 0x02
+	AlgorithmAES           uint32 = 0x03
+	NameTypeText           uint32 = 0x01
+)
+
+// ProtocolVersion is a KMIP protocol version.
+type ProtocolVersion struct {
+	Major, Minor int32
+}
+
+// Item returns the ProtocolVersion as KMIP item.
+func (v ProtocolVersion) Item() Item {
+	return Struct(TagProtocolVersion,
+		Int(TagProtocolVersionMajor, v.Major),
+		Int(TagProtocolVersionMinor, v.Minor),
+	)
+}
+
+// Versions are the KMIP protocol versions supported
+// by the KES KMIP API, in order of preference.
+var Versions = []ProtocolVersion{
+	{2, 0},
+	{1, 4},
+	{1, 3},
+	{1, 2},
+}
+
+// Request is a KMIP request message.
+type Request struct {
+	Version ProtocolVersion
+	Items   []BatchItem
+}
+
+// BatchItem is a batch item of a KMIP request message.
+type BatchItem struct {
+	Operation Operation
+	ID        []byte // Optional unique batch item ID
+	Payload   Item
+}
+
+// ParseRequest parses a KMIP request message.
+func ParseRequest(msg Item) (*Request, error) {
+	if msg.Tag != TagRequestMessage || msg.Type != TypeStructure {
+		return nil, &Error{Reason: ReasonInvalidMessage, Message: "not a request message"}
+	}
+	header, ok := msg.Find(TagRequestHeader)
+	if !ok {
+		return nil, &Error{Reason: ReasonInvalidMessage, Message: "missing request header"}
+	}
+	version, ok := header.Find(TagProtocolVersion)
+	if !ok {
+		return nil, &Error{Reason: ReasonInvalidMessage, Message: "missing protocol version"}
+	}
+
+	var req Request
+	major, _ := version.Find(TagProtocolVersionMajor)
+	minor, _ := version.Find(TagProtocolVersionMinor)
+	req.Version.Major, _ = major.Int()
+	req.Version.Minor, _ = minor.Int()
+
+	for _, item := range msg.FindAll(TagBatchItem) {
+		op, ok := item.Find(TagOperation)
+		if !ok {
+			return nil, &Error{Reason: ReasonInvalidMessage, Message: "missing operation"}
+		}
+		operation, ok := op.Enum()
+		if !ok {
+			return nil, &Error{Reason: ReasonInvalidMessage, Message: "invalid operation"}
+		}
+
+		batchItem := BatchItem{Operation: Operation(operation)}
+		if id, ok := item.Find(TagUniqueBatchItemID); ok {
+			batchItem.ID, _ = id.Bytes()
+		}
+		batchItem.Payload, _ = item.Find(TagRequestPayload)
+		req.Items = append(req.Items, batchItem)
+	}
+	if len(req.Items) == 0 {
+		return nil, &Error{Reason: ReasonInvalidMessage, Message: "no batch items"}
+	}
+	return &req, nil
+}
+
+// Error is a KMIP operation error.
+type Error struct {
+	Reason  ResultReason
+	Message string
+}
+
+func (e *Error) Error() string { return "kmip: " + e.Message }
+
+// Result is the result of a KMIP batch item.
+type Result struct {
+	Operation Operation
+	ID        []byte
+	Payload   []Item // Response payload, if the operation succeeded
+	Err       *Error // Error, if the operation failed
+}
+
+// Response returns a KMIP response message for
+// the given protocol version and results.
+func Response(version ProtocolVersion, now time.Time, results ...Result) Item {
+	items := []Item{
+		Struct(TagResponseHeader,
+			version.Item(),
+			Time(TagTimeStamp, now),
+			Int(TagBatchCount, int32(len(results))),
+		),
+	}
+	for _, result := range results {
+		var batchItem []Item
+		if result.Operation != 0 {
+			batchItem = append(batchItem, Enum(TagOperation, uint32(result.Operation)))
+		}
+		if result.ID != nil {
+			batchItem = append(batchItem, Bytes(TagUniqueBatchItemID, result.ID))
+		}
+		if result.Err != nil {
+			batchItem = append(batchItem,
+				Enum(TagResultStatus, uint32(StatusOperationFailed)),
+				Enum(TagResultReason, uint32(result.Err.Reason)),
+				Text(TagResultMessage, result.Err.Message),
+			)
+		} else {
+			batchItem = append(batchItem,
+				Enum(TagResultStatus, uint32(StatusSuccess)),
+				Struct(TagResponsePayload, result.Payload...),
+			)
+		}
+		items = append(items, Struct(TagBatchItem, batchItem...))
+	}
+	return Struct(TagResponseMessage, items...)
+}
@@ -0,0 +1,337 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package kmip implements the KMIP TTLV encoding and the
+// subset of the KMIP protocol used by the KES KMIP API.
+package kmip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Tag identifies a KMIP item, e.g. TagOperation.
+type Tag uint32
+
+// Type is the type of a KMIP item value.
+type Type uint8
+
+// KMIP item types.
+const (
+	TypeStructure   Type = 0x01
+	TypeInteger     Type = 0x02
+	TypeLongInteger Type = 0x03
+	TypeBigInteger  Type = 0x04
+	TypeEnumeration Type = 0x05
+	TypeBoolean     Type = 0x06
+	TypeTextString  Type = 0x07
+	TypeByteString  Type = 0x08
+	TypeDateTime    Type = 0x09
+	TypeInterval    Type = 0x0A
+)
+
+// Item is a KMIP TTLV item. Its value depends on the type:
+//   - TypeStructure:   []Item
+//   - TypeInteger:     int32
+//   - TypeLongInteger: int64
+//   - TypeBigInteger:  []byte (two's complement, big endian)
+//   - TypeEnumeration: uint32
+//   - TypeBoolean:     bool
+//   - TypeTextString:  string
+//   - TypeByteString:  []byte
+//   - TypeDateTime:    time.Time
+//   - TypeInterval:    uint32
+type Item struct {
+	Tag   Tag
+	Type  Type
+	Value any
+}
+
+// Struct returns a new structure item containing the given items.
+func Struct(tag Tag, items ...Item) Item { return Item{Tag: tag, Type: TypeStructure, Value: items} }
+
+// Int returns a new integer item.
+func Int(tag Tag, v int32) Item { return Item{Tag: tag, Type: TypeInteger, Value: v} }
+
+// Enum returns a new enumeration item.
+func Enum(tag Tag, v uint32) Item { return Item{Tag: tag, Type: TypeEnumeration, Value: v} }
+
+// Text returns a new text string item.
+func Text(tag Tag, s string) Item { return Item{Tag: tag, Type: TypeTextString, Value: s} }
+
+// Bytes returns a new byte string item.
+func Bytes(tag Tag, b []byte) Item { return Item{Tag: tag, Type: TypeByteString, Value: b} }
+
+// Time returns a new date-time item.
+func Time(tag Tag, t time.Time) Item { return Item{Tag: tag, Type: TypeDateTime, Value: t} }
+
+// Items returns the items of a structure item. It
+// returns nil if the item is not a structure.
+func (i Item) Items() []Item {
+	items, _ := i.Value.([]Item)
+	return items
+}
+
+// Find returns the first item of the structure item
+// with the given tag.
+func (i Item) Find(tag Tag) (Item, bool) {
+	for _, item := range i.Items() {
+		if item.Tag == tag {
+			return item, true
+		}
+	}
+	return Item{}, false
+}
+
+// FindAll returns all items of the structure item
+// with the given tag.
+func (i Item) FindAll(tag Tag) []Item {
+	var items []Item
+	for _, item := range i.Items() {
+		if item.Tag == tag {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Search returns the first item with the given tag
+// within the structure item or any nested structure.
+func (i Item) Search(tag Tag) (Item, bool) {
+	for _, item := range i.Items() {
+		if item.Tag == tag {
+			return item, true
+		}
+		if item, ok := item.Search(tag); ok {
+			return item, true
+		}
+	}
+	return Item{}, false
+}
+
+// Text returns the value of a text string item.
+func (i Item) Text() (string, bool) {
+	s, ok := i.Value.(string)
+	return s, ok
+}
+
+// Bytes returns the value of a byte string item.
+func (i Item) Bytes() ([]byte, bool) {
+	if i.Type != TypeByteString {
+		return nil, false
+	}
+	b, ok := i.Value.([]byte)
+	return b, ok
+}
+
+// Int returns the value of an integer item.
+func (i Item) Int() (int32, bool) {
+	v, ok := i.Value.(int32)
+	return v, ok
+}
+
+// Enum returns the value of an enumeration item.
+func (i Item) Enum() (uint32, bool) {
+	if i.Type != TypeEnumeration {
+		return 0, false
+	}
+	v, ok := i.Value.(uint32)
+	return v, ok
+}
+
+// MarshalBinary returns the TTLV encoding of the item.
+func (i Item) MarshalBinary() ([]byte, error) { return i.append(nil) }
+
+func (i Item) append(b []byte) ([]byte, error) {
+	var value []byte
+	switch i.Type {
+	case TypeStructure:
+		items, ok := i.Value.([]Item)
+		if !ok && i.Value != nil {
+			return nil, i.typeError()
+		}
+		for _, item := range items {
+			var err error
+			if value, err = item.append(value); err != nil {
+				return nil, err
+			}
+		}
+	case TypeInteger:
+		v, ok := i.Value.(int32)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = binary.BigEndian.AppendUint32(nil, uint32(v))
+	case TypeLongInteger:
+		v, ok := i.Value.(int64)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = binary.BigEndian.AppendUint64(nil, uint64(v))
+	case TypeEnumeration, TypeInterval:
+		v, ok := i.Value.(uint32)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = binary.BigEndian.AppendUint32(nil, v)
+	case TypeBoolean:
+		v, ok := i.Value.(bool)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = make([]byte, 8)
+		if v {
+			value[7] = 1
+		}
+	case TypeTextString:
+		v, ok := i.Value.(string)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = []byte(v)
+	case TypeByteString, TypeBigInteger:
+		v, ok := i.Value.([]byte)
+		if !ok && i.Value != nil {
+			return nil, i.typeError()
+		}
+		value = v
+	case TypeDateTime:
+		v, ok := i.Value.(time.Time)
+		if !ok {
+			return nil, i.typeError()
+		}
+		value = binary.BigEndian.AppendUint64(nil, uint64(v.Unix()))
+	default:
+		return nil, fmt.Errorf("kmip: invalid type '%#x' of item '%#06x'", i.Type, i.Tag)
+	}
+
+	b = append(b, byte(i.Tag>>16), byte(i.Tag>>8), byte(i.Tag), byte(i.Type))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(value)))
+	b = append(b, value...)
+	if n := len(value) % 8; n != 0 {
+		b = append(b, make([]byte, 8-n)...)
+	}
+	return b, nil
+}
+
+func (i Item) typeError() error {
+	return fmt.Errorf("kmip: invalid value '%T' for type '%#x' of item '%#06x'", i.Value, i.Type, i.Tag)
+}
+
+// ErrTooLarge is returned by Read if the
+// item exceeds the max. size.
+var ErrTooLarge = errors.New("kmip: message too large")
+
+// Read reads one TTLV encoded item from r. It returns
+// ErrTooLarge if the encoded item is larger than
+// maxSize bytes.
+func Read(r io.Reader, maxSize int) (Item, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Item{}, err
+	}
+	length := int(binary.BigEndian.Uint32(header[4:]))
+	if length > maxSize-len(header) {
+		return Item{}, ErrTooLarge
+	}
+	b := make([]byte, len(header)+padded(length))
+	copy(b, header[:])
+	if _, err := io.ReadFull(r, b[len(header):]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Item{}, err
+	}
+
+	item, _, err := decode(b)
+	return item, err
+}
+
+// Unmarshal decodes one TTLV encoded item.
+func Unmarshal(b []byte) (Item, error) {
+	item, rest, err := decode(b)
+	if err != nil {
+		return Item{}, err
+	}
+	if len(rest) != 0 {
+		return Item{}, errors.New("kmip: invalid item: trailing data")
+	}
+	return item, nil
+}
+
+func decode(b []byte) (Item, []byte, error) {
+	if len(b) < 8 {
+		return Item{}, nil, errors.New("kmip: invalid item: too short")
+	}
+	item := Item{
+		Tag:  Tag(b[0])<<16 | Tag(b[1])<<8 | Tag(b[2]),
+		Type: Type(b[3]),
+	}
+	length := int(binary.BigEndian.Uint32(b[4:8]))
+	b = b[8:]
+	if length > len(b) || padded(length) > len(b) {
+		return Item{}, nil, fmt.Errorf("kmip: invalid item '%#06x': length exceeds message", item.Tag)
+	}
+	value, rest := b[:length], b[padded(length):]
+
+	fixedLength := func(n int) error {
+		if length != n {
+			return fmt.Errorf("kmip: invalid item '%#06x': invalid length '%d' for type '%#x'", item.Tag, length, item.Type)
+		}
+		return nil
+	}
+	switch item.Type {
+	case TypeStructure:
+		items := []Item{}
+		for len(value) > 0 {
+			var (
+				child Item
+				err   error
+			)
+			if child, value, err = decode(value); err != nil {
+				return Item{}, nil, err
+			}
+			items = append(items, child)
+		}
+		item.Value = items
+	case TypeInteger:
+		if err := fixedLength(4); err != nil {
+			return Item{}, nil, err
+		}
+		item.Value = int32(binary.BigEndian.Uint32(value))
+	case TypeLongInteger:
+		if err := fixedLength(8); err != nil {
+			return Item{}, nil, err
+		}
+		item.Value = int64(binary.BigEndian.Uint64(value))
+	case TypeEnumeration, TypeInterval:
+		if err := fixedLength(4); err != nil {
+			return Item{}, nil, err
+		}
+		item.Value = binary.BigEndian.Uint32(value)
+	case TypeBoolean:
+		if err := fixedLength(8); err != nil {
+			return Item{}, nil, err
+		}
+		item.Value = binary.BigEndian.Uint64(value) != 0
+	case TypeTextString:
+		item.Value = string(value)
+	case TypeByteString, TypeBigInteger:
+		item.Value = append([]byte(nil), value...)
+	case TypeDateTime:
+		if err := fixedLength(8); err != nil {
+			return Item{}, nil, err
+		}
+		item.Value = time.Unix(int64(binary.BigEndian.Uint64(value)), 0).UTC()
+	default:
+		return Item{}, nil, fmt.Errorf("kmip: invalid item '%#06x': unknown type '%#x'", item.Tag, item.Type)
+	}
+	return item, rest, nil
+}
+
+// padded returns n rounded up to a multiple of 8.
+func padded(n int) int { return (n + 7) &^ 7 }
@@ -0,0 +1,76 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kmip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItemMarshalBinary(t *testing.T) {
+	for i, test := range marshalTests {
+		b, err := test.Item.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d: failed to encode item: %v", i, err)
+		}
+		if got := hex.EncodeToString(b); got != test.Encoding {
+			t.Fatalf("Test %d: encoding mismatch: got '%s' - want '%s'", i, got, test.Encoding)
+		}
+
+		item, err := Unmarshal(b)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decode item: %v", i, err)
+		}
+		if !reflect.DeepEqual(item, test.Item) {
+			t.Fatalf("Test %d: item mismatch: got '%v' - want '%v'", i, item, test.Item)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	item := Struct(TagRequestPayload, Text(TagUniqueIdentifier, "my-key"), Bytes(TagData, []byte("Hello World")))
+	b, err := item.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to encode item: %v", err)
+	}
+
+	got, err := Read(bytes.NewReader(b), len(b))
+	if err != nil {
+		t.Fatalf("Failed to read item: %v", err)
+	}
+	if !reflect.DeepEqual(got, item) {
+		t.Fatalf("Item mismatch: got '%v' - want '%v'", got, item)
+	}
+	if _, err = Read(bytes.NewReader(b), len(b)-1); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Reading too large item: got '%v' - want '%v'", err, ErrTooLarge)
+	}
+	if _, err = Read(bytes.NewReader(b[:len(b)-1]), len(b)); err == nil {
+		t.Fatal("Reading truncated item succeeded")
+	}
+}
+
+// The encodings are taken from the examples of the
+// KMIP 1.4 specification, section 9.1.2.
+var marshalTests = []struct {
+	Item     Item
+	Encoding string
+}{
+	{Item: Int(0x420020, 8), Encoding: "42002002000000040000000800000000"},
+	{Item: Item{Tag: 0x420020, Type: TypeLongInteger, Value: int64(123456789000000000)}, Encoding: "420020030000000801b69b4ba5749200"},
+	{Item: Enum(0x420020, 255), Encoding: "4200200500000004000000ff00000000"},
+	{Item: Item{Tag: 0x420020, Type: TypeBoolean, Value: true}, Encoding: "42002006000000080000000000000001"},
+	{Item: Text(0x420020, "Hello World"), Encoding: "420020070000000b48656c6c6f20576f726c640000000000"},
+	{Item: Bytes(0x420020, []byte{0x01, 0x02, 0x03}), Encoding: "42002008000000030102030000000000"},
+	{Item: Time(0x420020, time.Date(2008, 3, 14, 11, 56, 40, 0, time.UTC)), Encoding: "42002009000000080000000047da67f8"},
+	{Item: Item{Tag: 0x420020, Type: TypeInterval, Value: uint32(864000)}, Encoding: "4200200a00000004000d2f0000000000"},
+	{
+		Item:     Struct(0x420020, Enum(0x420004, 254), Int(0x420005, 255)),
+		Encoding: "42002001000000204200040500000004000000fe000000004200050200000004000000ff00000000",
+	},
+}
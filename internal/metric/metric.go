@@ -5,9 +5,11 @@
 package metric
 
 import (
+	"encoding/hex"
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/minio/kes/internal/api"
@@ -50,12 +52,24 @@ func New() *Metrics {
 			Name:      "request_active",
 			Help:      "Number of active requests that are not finished, yet.",
 		}),
+		requestThrottled: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "http",
+			Name:      "request_throttled",
+			Help:      "Number of requests that have been rejected due to a rate limit. (HTTP 429 status code)",
+		}),
 		requestLatency: factory.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "kes",
 			Subsystem: "http",
 			Name:      "response_time",
 			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 1.5, 3.0, 5.0, 10.0}, // from 10ms to 10s
 			Help:      "Histogram of request response times spawning from 10ms to 10s.",
+
+			// Expose a native histogram alongside the classic buckets.
+			// Prometheus scrapes it when using the protobuf format.
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  160,
+			NativeHistogramMinResetDuration: 1 * time.Hour,
 		}),
 
 		errorLogEvents: factory.NewCounter(prometheus.CounterOpts{
@@ -71,6 +85,45 @@ func New() *Metrics {
 			Help:      "Number of audit log events written to the audit log targets.",
 		}),
 
+		dekCacheHits: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "cache",
+			Name:      "dek_hits",
+			Help:      "Number of decryption requests served from the data key cache.",
+		}),
+		dekCacheMisses: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "cache",
+			Name:      "dek_misses",
+			Help:      "Number of decryption requests not found in the data key cache.",
+		}),
+
+		keyStoreSlowOps: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "keystore",
+			Name:      "slow_operations",
+			Help:      "Number of keystore operations that exceeded their latency threshold.",
+		}, []string{"backend", "operation"}),
+		keyStoreErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "keystore",
+			Name:      "errors",
+			Help:      "Number of keystore errors by error code, like 'backend_unreachable'.",
+		}, []string{"backend", "code"}),
+
+		tlsConnections: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "kes",
+			Subsystem: "tls",
+			Name:      "connections_open",
+			Help:      "Number of currently open client connections.",
+		}),
+		tlsHandshakeErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "tls",
+			Name:      "handshake_errors",
+			Help:      "Number of failed TLS handshakes by reason, like 'unknown_ca' or 'expired_cert'.",
+		}, []string{"reason"}),
+
 		startTime: time.Now(),
 		upTimeInSeconds: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: "kes",
@@ -130,11 +183,21 @@ type Metrics struct {
 	requestFailed    *prometheus.CounterVec
 	requestErrored   *prometheus.CounterVec
 	requestActive    prometheus.Gauge
+	requestThrottled prometheus.Counter
 	requestLatency   prometheus.Histogram
 
 	errorLogEvents prometheus.Counter
 	auditLogEvents prometheus.Counter
 
+	dekCacheHits   prometheus.Counter
+	dekCacheMisses prometheus.Counter
+
+	keyStoreSlowOps *prometheus.CounterVec
+	keyStoreErrors  *prometheus.CounterVec
+
+	tlsConnections     prometheus.Gauge
+	tlsHandshakeErrors *prometheus.CounterVec
+
 	startTime       time.Time // Used to compute the up time as upTime = now - startTime
 	upTimeInSeconds prometheus.Gauge
 	numCPUs         prometheus.Gauge
@@ -169,9 +232,55 @@ func (m *Metrics) EncodeTo(encoder expfmt.Encoder) error {
 			return err
 		}
 	}
+	if closer, ok := encoder.(expfmt.Closer); ok {
+		return closer.Close() // The OpenMetrics format requires a final "# EOF"
+	}
 	return nil
 }
 
+// CountDEKCache increments the number of data key cache
+// hits if hit is true. Otherwise, it increments the number
+// of cache misses.
+func (m *Metrics) CountDEKCache(hit bool) {
+	if hit {
+		m.dekCacheHits.Inc()
+	} else {
+		m.dekCacheMisses.Inc()
+	}
+}
+
+// CountSlowKeyStoreOp increments the number of slow keystore
+// operations of the given backend.
+func (m *Metrics) CountSlowKeyStoreOp(backend, operation string) {
+	m.keyStoreSlowOps.WithLabelValues(backend, operation).Inc()
+}
+
+// CountKeyStoreError increments the number of keystore
+// errors of the given backend and error code.
+func (m *Metrics) CountKeyStoreError(backend, code string) {
+	m.keyStoreErrors.WithLabelValues(backend, code).Inc()
+}
+
+// CountConnection increments the number of open client
+// connections if open is true. Otherwise, it decrements it.
+func (m *Metrics) CountConnection(open bool) {
+	if open {
+		m.tlsConnections.Inc()
+	} else {
+		m.tlsConnections.Dec()
+	}
+}
+
+// CountTLSHandshakeError increments the number of failed
+// TLS handshakes for the given reason.
+func (m *Metrics) CountTLSHandshakeError(reason string) {
+	m.tlsHandshakeErrors.WithLabelValues(reason).Inc()
+}
+
+// CountThrottled increments the number of requests
+// rejected due to a rate limit.
+func (m *Metrics) CountThrottled() { m.requestThrottled.Inc() }
+
 // Count returns a HandlerFunc that wraps h and counts the
 // how many requests succeeded (HTTP 200 OK) and how many
 // failed.
@@ -203,12 +312,16 @@ func (m *Metrics) Count(h api.Handler) api.Handler {
 // application takes to generate and send a response after
 // receiving a request. It basically shows how many request
 // the application can handle.
+//
+// If the request carries a sampled W3C trace context, Latency
+// attaches its trace ID as exemplar to the latency observation.
 func (m *Metrics) Latency(h api.Handler) api.Handler {
 	return api.HandlerFunc(func(resp *api.Response, req *api.Request) {
 		rw := &latencyResponseWriter{
 			ResponseWriter: resp.ResponseWriter,
 			start:          time.Now(),
 			histogram:      m.requestLatency,
+			traceID:        traceID(req.Header),
 		}
 		defer rw.updateMetrics()
 		resp.ResponseWriter = rw
@@ -275,6 +388,7 @@ type latencyResponseWriter struct {
 
 	start     time.Time            // The point in time when the request was received
 	histogram prometheus.Histogram // The latency histogram
+	traceID   string               // Optional trace ID used as exemplar
 }
 
 var (
@@ -284,7 +398,46 @@ var (
 
 // Updates metric request-response latency.
 func (w *latencyResponseWriter) updateMetrics() {
-	w.histogram.Observe(time.Since(w.start).Seconds())
+	latency := time.Since(w.start).Seconds()
+	if observer, ok := w.histogram.(prometheus.ExemplarObserver); ok && w.traceID != "" {
+		observer.ObserveWithExemplar(latency, prometheus.Labels{"trace_id": w.traceID})
+		return
+	}
+	w.histogram.Observe(latency)
+}
+
+// traceID returns the trace ID of the W3C trace context header
+// if the trace is sampled. Otherwise, it returns an empty string.
+//
+// Ref: https://www.w3.org/TR/trace-context/#traceparent-header
+func traceID(h http.Header) string {
+	// A traceparent has the form: version-traceid-parentid-flags
+	// For example: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	fields := strings.Split(h.Get("Traceparent"), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" {
+		return ""
+	}
+	id, parent, flags := fields[1], fields[2], fields[3]
+	if len(id) != 32 || len(parent) != 16 || len(flags) != 2 {
+		return ""
+	}
+	if id == "00000000000000000000000000000000" || !isHex(id) || !isHex(parent) {
+		return ""
+	}
+	if b, err := hex.DecodeString(flags); err != nil || b[0]&1 == 0 {
+		return "" // Not sampled
+	}
+	return id
+}
+
+// isHex reports whether s consists of lower-case hex characters.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
 }
 
 // Flush sends any buffered data to the client.
@@ -296,6 +449,11 @@ func (w *latencyResponseWriter) Flush() {
 	}
 }
 
+// Unwrap returns the underlying ResponseWriter.
+//
+// This method is mainly used in the context of ResponseController.
+func (w *latencyResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 // countResponseWriter is an http.ResponseWriter that
 // counts the number of requests partition by requests
 // that:
@@ -352,3 +510,8 @@ func (w *countResponseWriter) Flush() {
 		flusher.Flush()
 	}
 }
+
+// Unwrap returns the underlying ResponseWriter.
+//
+// This method is mainly used in the context of ResponseController.
+func (w *countResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,63 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package metric
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestTraceID(t *testing.T) {
+	for i, test := range traceIDTests {
+		h := http.Header{}
+		if test.TraceParent != "" {
+			h.Set("Traceparent", test.TraceParent)
+		}
+		if id := traceID(h); id != test.TraceID {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, id, test.TraceID)
+		}
+	}
+}
+
+func TestLatencyExemplar(t *testing.T) {
+	m := New()
+	w := &latencyResponseWriter{
+		ResponseWriter: httptest.NewRecorder(),
+		start:          time.Now(),
+		histogram:      m.requestLatency,
+		traceID:        "4bf92f3577b34da6a3ce929d0e0e4736",
+	}
+	w.updateMetrics()
+
+	var buf bytes.Buffer
+	if err := m.EncodeTo(expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeOpenMetrics))); err != nil {
+		t.Fatalf("Failed to encode metrics: %v", err)
+	}
+	if !strings.Contains(buf.String(), `trace_id="4bf92f3577b34da6a3ce929d0e0e4736"`) {
+		t.Fatalf("Metrics do not contain trace exemplar:\n%s", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), "# EOF\n") {
+		t.Fatal("OpenMetrics output is not terminated by '# EOF'")
+	}
+}
+
+var traceIDTests = []struct {
+	TraceParent string
+	TraceID     string
+}{
+	{TraceParent: "", TraceID: ""},
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", TraceID: ""}, // not sampled
+	{TraceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", TraceID: ""}, // invalid trace ID
+	{TraceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", TraceID: ""}, // upper-case hex
+	{TraceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TraceID: ""}, // invalid version
+	{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-01", TraceID: ""},
+}
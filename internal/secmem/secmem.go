@@ -0,0 +1,75 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package secmem implements memory hygiene for key material.
+//
+// It provides secure buffers that are allocated outside the Go
+// heap, locked into RAM, excluded from core dumps and zeroed once
+// released. Further, it provides functions to lock the memory of
+// the entire process and to disable core dumps.
+package secmem
+
+import "runtime"
+
+// Buffer is a fixed-size buffer for sensitive data, like
+// plaintext key material.
+//
+// On Unix systems, a Buffer is allocated outside the Go heap
+// such that the Go runtime never copies its content. Its pages
+// are locked into RAM, if possible, and, on Linux, excluded from
+// core dumps. On other systems, a Buffer is allocated on the Go
+// heap.
+//
+// A Buffer must be released via Destroy once it is no longer
+// needed. Destroy zeros the buffer's content.
+type Buffer struct {
+	b      []byte
+	mapped []byte // The mapped pages, if allocated outside the Go heap
+	locked bool
+}
+
+// New returns a new Buffer of the given size.
+//
+// It falls back to memory that is not locked or not allocated
+// outside the Go heap if the system does not support it or the
+// process has reached its limit of locked memory.
+func New(size int) *Buffer {
+	if size <= 0 {
+		return &Buffer{}
+	}
+	if buf, err := alloc(size); err == nil {
+		return buf
+	}
+	return &Buffer{b: make([]byte, size)}
+}
+
+// Bytes returns the content of the Buffer. The returned slice
+// must not be used once the Buffer has been destroyed.
+func (b *Buffer) Bytes() []byte { return b.b }
+
+// Len returns the size of the Buffer in bytes.
+func (b *Buffer) Len() int { return len(b.b) }
+
+// Locked reports whether the Buffer is locked into RAM.
+func (b *Buffer) Locked() bool { return b.locked }
+
+// Destroy zeros the Buffer's content and releases its memory.
+// The Buffer must not be used after calling Destroy. Destroy
+// may be called multiple times.
+func (b *Buffer) Destroy() {
+	Zero(b.b)
+	if b.mapped != nil {
+		free(b.mapped, b.locked)
+	}
+	b.b, b.mapped, b.locked = nil, nil, false
+}
+
+// Zero overwrites b with zeros.
+//
+// Unlike a plain loop or clear, the compiler does not remove the
+// zeroing of slices that are not used afterwards.
+func Zero(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secmem
+
+import "golang.org/x/sys/unix"
+
+// LockAll locks all current and future memory pages of the
+// process into RAM such that they are never swapped to disk.
+func LockAll() error { return unix.Mlockall(unix.MCL_CURRENT | unix.MCL_FUTURE) }
+
+// UnlockAll unlocks all memory pages of the process.
+func UnlockAll() error { return unix.Munlockall() }
+
+// dontDump excludes the memory pages from core dumps.
+func dontDump(b []byte) { unix.Madvise(b, unix.MADV_DONTDUMP) }
+
+func disableDumpable() error { return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0) }
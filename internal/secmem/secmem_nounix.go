@@ -0,0 +1,17 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package secmem
+
+import "errors"
+
+func alloc(int) (*Buffer, error) { return nil, errors.ErrUnsupported }
+
+func free([]byte, bool) {}
+
+// DisableCoreDumps prevents the process from writing core
+// dumps. It is only supported on Unix systems.
+func DisableCoreDumps() error { return errors.ErrUnsupported }
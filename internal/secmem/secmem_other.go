@@ -0,0 +1,21 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package secmem
+
+import "errors"
+
+// LockAll locks all current and future memory pages of the
+// process into RAM. It is only supported on Linux.
+func LockAll() error { return errors.ErrUnsupported }
+
+// UnlockAll unlocks all memory pages of the process. It is
+// only supported on Linux.
+func UnlockAll() error { return errors.ErrUnsupported }
+
+func dontDump([]byte) {}
+
+func disableDumpable() error { return nil }
@@ -0,0 +1,56 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package secmem
+
+import (
+	"bytes"
+	"testing"
+)
+
+var newTests = []struct {
+	Size int
+}{
+	{Size: 0},
+	{Size: 1},
+	{Size: 32},
+	{Size: 4096},
+	{Size: 4097},
+}
+
+func TestNew(t *testing.T) {
+	for i, test := range newTests {
+		buf := New(test.Size)
+		if n := buf.Len(); n != test.Size {
+			t.Fatalf("Test %d: got size '%d' - want '%d'", i, n, test.Size)
+		}
+		if n := len(buf.Bytes()); n != test.Size {
+			t.Fatalf("Test %d: got slice length '%d' - want '%d'", i, n, test.Size)
+		}
+		if n := cap(buf.Bytes()); n != test.Size {
+			t.Fatalf("Test %d: got slice capacity '%d' - want '%d'", i, n, test.Size)
+		}
+
+		content := bytes.Repeat([]byte{0xff}, test.Size)
+		copy(buf.Bytes(), content)
+		if !bytes.Equal(buf.Bytes(), content) {
+			t.Fatalf("Test %d: buffer content does not match", i)
+		}
+
+		buf.Destroy()
+		if buf.Len() != 0 || buf.Bytes() != nil || buf.Locked() {
+			t.Fatalf("Test %d: buffer has not been destroyed", i)
+		}
+		buf.Destroy() // Destroy must be idempotent
+	}
+}
+
+func TestZero(t *testing.T) {
+	b := []byte("secret key material")
+	Zero(b)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("Slice has not been zeroed: got '%x'", b)
+	}
+	Zero(nil)
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package secmem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc returns a new Buffer backed by anonymous memory pages
+// outside the Go heap. It locks the pages, if possible.
+func alloc(size int) (*Buffer, error) {
+	pageSize := os.Getpagesize()
+	n := (size + pageSize - 1) / pageSize * pageSize
+
+	mapped, err := unix.Mmap(-1, 0, n, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	dontDump(mapped)
+	return &Buffer{
+		b:      mapped[:size:size],
+		mapped: mapped,
+		locked: unix.Mlock(mapped) == nil,
+	}, nil
+}
+
+// free unlocks, if locked, and unmaps the memory pages.
+func free(mapped []byte, locked bool) {
+	if locked {
+		unix.Munlock(mapped)
+	}
+	unix.Munmap(mapped)
+}
+
+// DisableCoreDumps prevents the process from writing core dumps
+// by setting the core file size limit to zero. On Linux, it also
+// marks the process as not dumpable, which prevents other processes
+// of the same user from attaching via ptrace(2).
+func DisableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0}); err != nil {
+		return err
+	}
+	return disableDumpable()
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// A secret is split into n shares such that any threshold of them
+// reconstruct the secret while fewer shares reveal nothing about it.
+// Each byte of the secret is the constant term of a random polynomial
+// of degree threshold-1. A share consists of the polynomial values at
+// a distinct x-coordinate followed by the x-coordinate itself:
+//
+//	y_0 | y_1 | ... | y_n-1 | x
+//
+// Hence, a share is one byte longer than the secret.
+//
+// All field operations are implemented in constant time.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxShares is the max. number of shares a secret can be split into.
+const MaxShares = 255
+
+// Split splits the secret into n shares of which any threshold
+// reconstruct the secret. The threshold must be at least 2 and
+// not greater than n.
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("shamir: secret is empty")
+	}
+	if n < 2 || n > MaxShares {
+		return nil, fmt.Errorf("shamir: invalid number of shares '%d'", n)
+	}
+	if threshold < 2 || threshold > n {
+		return nil, fmt.Errorf("shamir: invalid threshold '%d' for '%d' shares", threshold, n)
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	defer clear(coefficients)
+	for i, b := range secret {
+		coefficients[0] = b
+		if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share[i] = evaluate(coefficients, share[len(secret)])
+		}
+	}
+	return shares, nil
+}
+
+// Combine reconstructs the secret from the given shares. It returns
+// an error if the shares are malformed. If fewer shares than the
+// threshold are provided, Combine returns a wrong secret.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("shamir: at least two shares required")
+	}
+	if len(shares) > MaxShares {
+		return nil, errors.New("shamir: too many shares")
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("shamir: invalid share length")
+	}
+	xs := make([]byte, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("shamir: shares have different lengths")
+		}
+		x := share[size-1]
+		if x == 0 {
+			return nil, errors.New("shamir: invalid share")
+		}
+		for _, v := range xs[:i] {
+			if v == x {
+				return nil, errors.New("shamir: duplicate share")
+			}
+		}
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	for i := range secret {
+		// Lagrange interpolation at x = 0.
+		var b byte
+		for j, share := range shares {
+			basis := byte(1)
+			for k, x := range xs {
+				if k != j {
+					basis = mul(basis, div(x, x^xs[j]))
+				}
+			}
+			b ^= mul(share[i], basis)
+		}
+		secret[i] = b
+	}
+	return secret, nil
+}
+
+// evaluate returns the value of the polynomial with the
+// given coefficients at x using Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// mul multiplies a and b in GF(2^8) with the AES reduction
+// polynomial x^8 + x^4 + x^3 + x + 1.
+func mul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		a = (a << 1) ^ (-(a >> 7) & 0x1b)
+		b >>= 1
+	}
+	return p
+}
+
+// div divides a by b in GF(2^8). The divisor b must not be 0.
+func div(a, b byte) byte {
+	// b^-1 = b^254 since b^255 = 1 for all b != 0.
+	sq := mul(b, b)
+	inv := sq
+	for i := 0; i < 6; i++ {
+		sq = mul(sq, sq)
+		inv = mul(inv, sq)
+	}
+	return mul(a, inv)
+}
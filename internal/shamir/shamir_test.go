@@ -0,0 +1,102 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("my-secret-root-key-0123456789abc")
+	for i, test := range splitTests {
+		shares, err := Split(secret, test.N, test.Threshold)
+		if err != nil {
+			t.Fatalf("Test %d: failed to split secret: %v", i, err)
+		}
+		if len(shares) != test.N {
+			t.Fatalf("Test %d: got '%d' shares - want '%d'", i, len(shares), test.N)
+		}
+
+		for j := 0; j+test.Threshold <= len(shares); j++ {
+			combined, err := Combine(shares[j : j+test.Threshold])
+			if err != nil {
+				t.Fatalf("Test %d: failed to combine shares: %v", i, err)
+			}
+			if !bytes.Equal(combined, secret) {
+				t.Fatalf("Test %d: combined secret does not match: got '%x' - want '%x'", i, combined, secret)
+			}
+		}
+
+		combined, err := Combine(shares)
+		if err != nil {
+			t.Fatalf("Test %d: failed to combine all shares: %v", i, err)
+		}
+		if !bytes.Equal(combined, secret) {
+			t.Fatalf("Test %d: combined secret does not match: got '%x' - want '%x'", i, combined, secret)
+		}
+
+		if test.Threshold > 2 {
+			combined, err = Combine(shares[:test.Threshold-1])
+			if err != nil {
+				t.Fatalf("Test %d: failed to combine shares: %v", i, err)
+			}
+			if bytes.Equal(combined, secret) {
+				t.Fatalf("Test %d: secret reconstructed from fewer than '%d' shares", i, test.Threshold)
+			}
+		}
+	}
+}
+
+var splitTests = []struct {
+	N, Threshold int
+}{
+	{N: 2, Threshold: 2},
+	{N: 3, Threshold: 2},
+	{N: 5, Threshold: 3},
+	{N: 10, Threshold: 10},
+	{N: MaxShares, Threshold: 17},
+}
+
+func TestSplitInvalid(t *testing.T) {
+	t.Parallel()
+
+	for i, test := range []struct {
+		Secret       []byte
+		N, Threshold int
+	}{
+		{Secret: nil, N: 3, Threshold: 2},
+		{Secret: []byte("secret"), N: 1, Threshold: 1},
+		{Secret: []byte("secret"), N: 3, Threshold: 1},
+		{Secret: []byte("secret"), N: 3, Threshold: 4},
+		{Secret: []byte("secret"), N: MaxShares + 1, Threshold: 2},
+	} {
+		if _, err := Split(test.Secret, test.N, test.Threshold); err == nil {
+			t.Fatalf("Test %d: split should have failed", i)
+		}
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	t.Parallel()
+
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split secret: %v", err)
+	}
+	for i, shares := range [][][]byte{
+		nil,
+		{shares[0]},
+		{shares[0], shares[0]},
+		{shares[0], shares[1][1:]},
+		{shares[0], append(bytes.Clone(shares[1][:len(shares[1])-1]), 0)},
+	} {
+		if _, err := Combine(shares); err == nil {
+			t.Fatalf("Test %d: combine should have failed", i)
+		}
+	}
+}
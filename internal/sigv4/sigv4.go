@@ -0,0 +1,212 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package sigv4 implements AWS Signature Version 4
+// request signing and verification.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm is the signing algorithm of
+	// AWS Signature Version 4.
+	Algorithm = "AWS4-HMAC-SHA256"
+
+	// MaxClockSkew is the max. time difference
+	// between the signature and the server time.
+	MaxClockSkew = 15 * time.Minute
+
+	timeFormat  = "20060102T150405Z"
+	shortFormat = "20060102"
+)
+
+// Credentials are AWS access credentials.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+}
+
+// Sign signs the request with the given credentials for
+// the AWS region and service. The body must be the request
+// body.
+func Sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	signedHeaders := []string{"host", "x-amz-date"}
+	for name := range req.Header {
+		if name := strings.ToLower(name); !slices.Contains(signedHeaders, name) && (name == "content-type" || strings.HasPrefix(name, "x-amz-")) {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	scope := strings.Join([]string{now.Format(shortFormat), region, service, "aws4_request"}, "/")
+	signature := signature(req, body, creds.SecretKey, signedHeaders, scope, now)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, creds.AccessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// Verify verifies the signature of the request for the AWS
+// region and service. The body must be the request body.
+//
+// The host and X-Amz-Date headers, and all given headers, must
+// be signed. Services that act on headers, like X-Amz-Target,
+// should require them to be signed. Otherwise, they could be
+// modified without invalidating the signature.
+//
+// It calls lookup to obtain the secret key of the request's
+// access key. On success, it returns the access key.
+func Verify(req *http.Request, body []byte, region, service string, lookup func(accessKey string) (string, bool), now time.Time, headers ...string) (string, error) {
+	auth := req.Header.Get("Authorization")
+	algorithm, fields, ok := strings.Cut(auth, " ")
+	if !ok || algorithm != Algorithm {
+		return "", errors.New("sigv4: missing or unsupported authorization")
+	}
+
+	var credential, signedHeaders, sig string
+	for _, field := range strings.Split(fields, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			sig = value
+		}
+	}
+	accessKey, scope, ok := strings.Cut(credential, "/")
+	if !ok || signedHeaders == "" || sig == "" {
+		return "", errors.New("sigv4: malformed authorization")
+	}
+
+	date, err := time.Parse(timeFormat, req.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "", errors.New("sigv4: missing or invalid X-Amz-Date")
+	}
+	if d := now.Sub(date); d > MaxClockSkew || d < -MaxClockSkew {
+		return "", errors.New("sigv4: signature expired or not yet valid")
+	}
+	if want := strings.Join([]string{date.Format(shortFormat), region, service, "aws4_request"}, "/"); scope != want {
+		return "", fmt.Errorf("sigv4: invalid credential scope '%s'", scope)
+	}
+
+	headerNames := strings.Split(signedHeaders, ";")
+	if !slices.Contains(headerNames, "host") || !slices.Contains(headerNames, "x-amz-date") {
+		return "", errors.New("sigv4: host and x-amz-date must be signed")
+	}
+	for _, header := range headers {
+		if !slices.Contains(headerNames, strings.ToLower(header)) {
+			return "", fmt.Errorf("sigv4: %s must be signed", strings.ToLower(header))
+		}
+	}
+	secretKey, ok := lookup(accessKey)
+	if !ok {
+		return "", errors.New("sigv4: unknown access key")
+	}
+
+	expected := signature(req, body, secretKey, headerNames, scope, date)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return "", errors.New("sigv4: signature mismatch")
+	}
+	return accessKey, nil
+}
+
+// signature computes the request signature.
+func signature(req *http.Request, body []byte, secretKey string, signedHeaders []string, scope string, date time.Time) string {
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = req.Host
+		} else {
+			values := req.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		Algorithm,
+		date.UTC().Format(timeFormat),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// escape URI-encodes s as specified by AWS.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example is taken from the AWS Signature Version 4
+// documentation.
+func TestVerify(t *testing.T) {
+	const (
+		AccessKey = "AKIDEXAMPLE"
+		SecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+		Signature = "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	)
+	date := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	Sign(req, nil, Credentials{AccessKey: AccessKey, SecretKey: SecretKey}, "us-east-1", "iam", date)
+	if auth := req.Header.Get("Authorization"); !strings.HasSuffix(auth, "Signature="+Signature) {
+		t.Fatalf("Signature mismatch: got '%s' - want '%s'", auth, Signature)
+	}
+
+	lookup := func(accessKey string) (string, bool) { return SecretKey, accessKey == AccessKey }
+	accessKey, err := Verify(req, nil, "us-east-1", "iam", lookup, date.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to verify signature: %v", err)
+	}
+	if accessKey != AccessKey {
+		t.Fatalf("Access key mismatch: got '%s' - want '%s'", accessKey, AccessKey)
+	}
+
+	if _, err = Verify(req, nil, "us-east-1", "iam", lookup, date, "Content-Type"); err != nil {
+		t.Fatalf("Failed to verify signature with signed Content-Type: %v", err)
+	}
+	if _, err = Verify(req, nil, "us-east-1", "iam", lookup, date, "X-Amz-Target"); err == nil {
+		t.Fatal("Verified signature without signed X-Amz-Target")
+	}
+	if _, err = Verify(req, nil, "us-east-1", "iam", lookup, date.Add(MaxClockSkew+time.Second)); err == nil {
+		t.Fatal("Verified expired signature")
+	}
+	if _, err = Verify(req, nil, "eu-west-1", "iam", lookup, date); err == nil {
+		t.Fatal("Verified signature for different region")
+	}
+	if _, err = Verify(req, []byte("body"), "us-east-1", "iam", lookup, date); err == nil {
+		t.Fatal("Verified signature for modified body")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if _, err = Verify(req, nil, "us-east-1", "iam", lookup, date); err == nil {
+		t.Fatal("Verified signature for modified header")
+	}
+}
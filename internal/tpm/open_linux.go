@@ -0,0 +1,16 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package tpm
+
+import (
+	"io"
+
+	"github.com/google/go-tpm/tpmutil"
+)
+
+func open(path string) (io.ReadWriteCloser, error) { return tpmutil.OpenTPM(path) }
@@ -0,0 +1,19 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package tpm
+
+import (
+	"errors"
+	"io"
+)
+
+func open(string) (io.ReadWriteCloser, error) {
+	// We only support TPM devices
+	// on linux at the moment.
+	return nil, errors.New("TPM is not supported on this platform")
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package tpm implements sealing and unsealing of secrets
+// with a TPM 2.0.
+//
+// A sealed secret can only be unsealed by the TPM that sealed
+// it and only as long as the selected platform configuration
+// registers (PCRs) contain the same values as when the secret
+// got sealed. Hence, a sealed secret copied to another machine,
+// or a disk image booted with a modified firmware or boot chain,
+// cannot be unsealed.
+package tpm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/legacy/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// DefaultDevice is the default TPM device. It refers to the
+// in-kernel resource manager that allows multiple processes
+// to share the TPM.
+const DefaultDevice = "/dev/tpmrm0"
+
+// DefaultPCRs are the PCRs a secret is bound to by default.
+// PCR 0 measures the platform firmware and PCR 7 the secure
+// boot state.
+var DefaultPCRs = []int{0, 7}
+
+// Open opens the TPM device at the given path. If path is
+// empty, Open uses the DefaultDevice.
+func Open(path string) (io.ReadWriteCloser, error) {
+	if path == "" {
+		path = DefaultDevice
+	}
+	rw, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: failed to open '%s': %v", path, err)
+	}
+	return rw, nil
+}
+
+// ParsePCRs parses a comma-separated list of PCR indices,
+// like "0,2,7". It returns the DefaultPCRs if s is empty.
+func ParsePCRs(s string) ([]int, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return slices.Clone(DefaultPCRs), nil
+	}
+
+	var pcrs []int
+	for _, v := range strings.Split(s, ",") {
+		pcr, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || pcr < 0 || pcr > 23 {
+			return nil, fmt.Errorf("tpm: invalid PCR '%s'", v)
+		}
+		if !slices.Contains(pcrs, pcr) {
+			pcrs = append(pcrs, pcr)
+		}
+	}
+	slices.Sort(pcrs)
+	return pcrs, nil
+}
+
+// Seal seals the secret with the TPM such that it can only be
+// unsealed as long as the given PCRs contain their current
+// values. It returns the sealed secret.
+func Seal(rw io.ReadWriter, secret []byte, pcrs []int) ([]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("tpm: secret is empty")
+	}
+	if len(secret) > 128 { // The TPM limit for sealed data objects
+		return nil, errors.New("tpm: secret is too large")
+	}
+	if len(pcrs) == 0 {
+		return nil, errors.New("tpm: no PCRs selected")
+	}
+
+	srk, err := createSRK(rw)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, srk)
+
+	policy, err := pcrPolicy(rw, tpm2.SessionTrial, pcrs)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := tpm2.PolicyGetDigest(rw, policy)
+	tpm2.FlushContext(rw, policy)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: failed to compute PCR policy: %v", err)
+	}
+
+	private, public, err := tpm2.Seal(rw, srk, "", "", digest, secret)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: failed to seal secret: %v", err)
+	}
+	return json.Marshal(sealedSecret{
+		PCRs:    pcrs,
+		Public:  public,
+		Private: private,
+	})
+}
+
+// Unseal unseals the sealed secret with the TPM. It fails if
+// the secret has been sealed by a different TPM or if any of
+// the PCRs has changed since the secret got sealed.
+func Unseal(rw io.ReadWriter, sealed []byte) ([]byte, error) {
+	var s sealedSecret
+	if err := json.Unmarshal(sealed, &s); err != nil {
+		return nil, fmt.Errorf("tpm: invalid sealed secret: %v", err)
+	}
+	if len(s.PCRs) == 0 || len(s.Public) == 0 || len(s.Private) == 0 {
+		return nil, errors.New("tpm: invalid sealed secret")
+	}
+
+	srk, err := createSRK(rw)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, srk)
+
+	obj, _, err := tpm2.Load(rw, srk, "", s.Public, s.Private)
+	if err != nil {
+		return nil, fmt.Errorf("tpm: failed to load sealed secret: %v", err)
+	}
+	defer tpm2.FlushContext(rw, obj)
+
+	policy, err := pcrPolicy(rw, tpm2.SessionPolicy, s.PCRs)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, policy)
+
+	secret, err := tpm2.UnsealWithSession(rw, policy, obj, "")
+	if err != nil {
+		return nil, fmt.Errorf("tpm: failed to unseal secret: %v", err)
+	}
+	return secret, nil
+}
+
+// sealedSecret is the encoded form of a secret sealed
+// by the TPM.
+type sealedSecret struct {
+	PCRs    []int  `json:"pcrs"`
+	Public  []byte `json:"public"`
+	Private []byte `json:"private"`
+}
+
+// srkTemplate is the template of the storage root key (SRK).
+// The TPM derives the SRK deterministically from its owner
+// seed and the template. Hence, it can be re-created on demand
+// and does not have to be persisted.
+var srkTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagStorageDefault | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Symmetric: &tpm2.SymScheme{
+			Alg:     tpm2.AlgAES,
+			KeyBits: 128,
+			Mode:    tpm2.AlgCFB,
+		},
+		KeyBits: 2048,
+	},
+}
+
+// createSRK creates the storage root key under the owner
+// hierarchy and returns its handle.
+func createSRK(rw io.ReadWriter) (tpmutil.Handle, error) {
+	srk, _, err := tpm2.CreatePrimary(rw, tpm2.HandleOwner, tpm2.PCRSelection{}, "", "", srkTemplate)
+	if err != nil {
+		return 0, fmt.Errorf("tpm: failed to create storage root key: %v", err)
+	}
+	return srk, nil
+}
+
+// pcrPolicy starts a new session of the given type and binds
+// it to the current values of the given SHA-256 PCRs. The
+// caller has to flush the returned session.
+func pcrPolicy(rw io.ReadWriter, typ tpm2.SessionType, pcrs []int) (tpmutil.Handle, error) {
+	session, _, err := tpm2.StartAuthSession(
+		rw,
+		tpm2.HandleNull,
+		tpm2.HandleNull,
+		make([]byte, 16), // nonceCaller
+		nil,              // secret
+		typ,
+		tpm2.AlgNull,
+		tpm2.AlgSHA256,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("tpm: failed to start session: %v", err)
+	}
+
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}
+	if err = tpm2.PolicyPCR(rw, session, nil, sel); err != nil {
+		tpm2.FlushContext(rw, session)
+		return 0, fmt.Errorf("tpm: failed to bind session to PCRs %v: %v", pcrs, err)
+	}
+	return session, nil
+}
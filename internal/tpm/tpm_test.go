@@ -0,0 +1,71 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package tpm
+
+import (
+	"slices"
+	"testing"
+)
+
+var parsePCRsTests = []struct {
+	PCRs       string
+	Want       []int
+	ShouldFail bool
+}{
+	{PCRs: "", Want: DefaultPCRs},            // 0
+	{PCRs: "  ", Want: DefaultPCRs},          // 1
+	{PCRs: "7", Want: []int{7}},              // 2
+	{PCRs: "7,0,2", Want: []int{0, 2, 7}},    // 3
+	{PCRs: " 4 , 4,23 ", Want: []int{4, 23}}, // 4
+	{PCRs: "24", ShouldFail: true},           // 5
+	{PCRs: "-1", ShouldFail: true},           // 6
+	{PCRs: "0,,7", ShouldFail: true},         // 7
+	{PCRs: "0,seven", ShouldFail: true},      // 8
+}
+
+func TestParsePCRs(t *testing.T) {
+	for i, test := range parsePCRsTests {
+		pcrs, err := ParsePCRs(test.PCRs)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to parse PCRs '%s': %v", i, test.PCRs, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: parsing PCRs '%s' should have failed", i, test.PCRs)
+		}
+		if err == nil && !slices.Equal(pcrs, test.Want) {
+			t.Fatalf("Test %d: got PCRs '%v' - want '%v'", i, pcrs, test.Want)
+		}
+	}
+}
+
+func TestParsePCRsDefault(t *testing.T) {
+	pcrs, err := ParsePCRs("")
+	if err != nil {
+		t.Fatalf("Failed to parse PCRs: %v", err)
+	}
+	pcrs[0] = 23
+	if DefaultPCRs[0] == 23 {
+		t.Fatal("ParsePCRs returned the DefaultPCRs instead of a copy")
+	}
+}
+
+var unsealInvalidTests = []string{
+	``,
+	`not json`,
+	`{}`,
+	`{"pcrs":[0,7]}`,
+	`{"pcrs":[],"public":"AQ==","private":"AQ=="}`,
+	`{"pcrs":[0,7],"public":"","private":"AQ=="}`,
+}
+
+func TestUnsealInvalid(t *testing.T) {
+	for i, sealed := range unsealInvalidTests {
+		// Invalid sealed secrets are rejected before
+		// accessing the TPM. Hence, no device is needed.
+		if _, err := Unseal(nil, []byte(sealed)); err == nil {
+			t.Fatalf("Test %d: unsealing invalid secret should have failed", i)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// errInvalidJWE is returned when a JWE is malformed or has not
+// been produced by the JWE encrypt API.
+var errInvalidJWE = kes.NewError(http.StatusBadRequest, "invalid JWE")
+
+// joseHeader is the protected header of JWS and JWE tokens
+// issued by the server.
+type joseHeader struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc,omitempty"`
+	KeyID       string `json:"kid"`
+	Type        string `json:"typ,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// joseKeyID returns the JOSE key ID of the given version
+// of the key. Versions start at 1. Key names cannot contain
+// a '.' such that the key ID is unambiguous.
+func joseKeyID(name string, version int) string {
+	return name + "." + strconv.Itoa(version)
+}
+
+// parseJOSEKeyID parses a key ID created by joseKeyID.
+func parseJOSEKeyID(kid string) (name string, version int, ok bool) {
+	i := strings.LastIndexByte(kid, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(kid[i+1:])
+	if err != nil || version < 1 {
+		return "", 0, false
+	}
+	return kid[:i], version, true
+}
+
+// signJWS is a HandlerFunc that issues JWS tokens, in compact
+// serialization, signed with the latest version of a signing key.
+// The payload must be a JSON object, usually a set of JWT claims.
+// The key ID refers to the key version such that tokens can be
+// verified with the key's public key after the key has been rotated.
+func (s *Server) signJWS(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.SignJWSRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, body.Claims); err != nil || payload.Len() == 0 || payload.Bytes()[0] != '{' {
+		resp.Fail(http.StatusBadRequest, "claims must be a JSON object")
+		return
+	}
+	if body.Type == "" {
+		body.Type = "JWT"
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	latest := key.Latest()
+	alg, err := latest.Key.JWSAlgorithm()
+	if err != nil {
+		resp.Failr(crypto.ErrNoSigning)
+		return
+	}
+
+	kid := joseKeyID(req.Resource, len(key.Versions))
+	header, err := json.Marshal(joseHeader{
+		Algorithm: alg,
+		KeyID:     kid,
+		Type:      body.Type,
+	})
+	if err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to create JWS")
+		return
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload.Bytes())
+	signature, err := latest.Key.SignJWS([]byte(signingInput))
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to sign JWS")
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.SignJWSResponse{
+		Token: signingInput + "." + base64.RawURLEncoding.EncodeToString(signature),
+		KeyID: kid,
+	})
+}
+
+// encryptJWE is a HandlerFunc that encrypts a plaintext as JWE,
+// in compact serialization, with the latest version of a key. It
+// uses direct encryption ("dir") with AES-256-GCM. Hence, only
+// the KES server can decrypt the JWE using the JWE decrypt API.
+func (s *Server) encryptJWE(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.EncryptJWERequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+
+	header, err := json.Marshal(joseHeader{
+		Algorithm:   crypto.JWEDirect,
+		Encryption:  crypto.JWEA256GCM,
+		KeyID:       joseKeyID(req.Resource, len(key.Versions)),
+		Type:        body.Type,
+		ContentType: body.ContentType,
+	})
+	if err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to create JWE")
+		return
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	iv, ciphertext, tag, err := key.Latest().Key.EncryptJWE(protected, body.Plaintext)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to encrypt plaintext")
+		return
+	}
+
+	// A JWE using direct encryption has an empty encrypted key.
+	token := strings.Join([]string{
+		protected,
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, ".")
+	api.ReplyWith(resp, http.StatusOK, api.EncryptJWEResponse{
+		Token: token,
+	})
+}
+
+// decryptJWE is a HandlerFunc that decrypts a JWE produced by
+// encryptJWE. The key version is selected by the JWE's key ID,
+// which must refer to the requested key.
+func (s *Server) decryptJWE(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.DecryptJWERequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	header, iv, ciphertext, tag, err := parseJWE(body.Token)
+	if err != nil {
+		resp.Failr(errInvalidJWE)
+		return
+	}
+	name, version, ok := parseJOSEKeyID(header.KeyID)
+	if !ok || name != req.Resource {
+		resp.Failf(http.StatusBadRequest, "JWE key ID '%s' does not refer to key '%s'", header.KeyID, req.Resource)
+		return
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	if version > len(key.Versions) {
+		resp.Failr(kes.ErrDecrypt)
+		return
+	}
+
+	protected, _, _ := strings.Cut(body.Token, ".")
+	plaintext, err := key.Versions[version-1].Key.DecryptJWE(protected, iv, ciphertext, tag)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to decrypt JWE")
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.DecryptJWEResponse{
+		Plaintext: plaintext,
+	})
+}
+
+// parseJWE parses a JWE in compact serialization that uses
+// direct encryption with AES-256-GCM.
+func parseJWE(token string) (header joseHeader, iv, ciphertext, tag []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return header, nil, nil, nil, errors.New("kes: invalid JWE compact serialization")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return header, nil, nil, nil, err
+	}
+	if err = json.Unmarshal(b, &header); err != nil {
+		return header, nil, nil, nil, err
+	}
+	if header.Algorithm != crypto.JWEDirect || header.Encryption != crypto.JWEA256GCM {
+		return header, nil, nil, nil, errors.New("kes: unsupported JWE algorithm")
+	}
+
+	if iv, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return header, nil, nil, nil, err
+	}
+	if ciphertext, err = base64.RawURLEncoding.DecodeString(parts[3]); err != nil {
+		return header, nil, nil, nil, err
+	}
+	if tag, err = base64.RawURLEncoding.DecodeString(parts[4]); err != nil {
+		return header, nil, nil, nil, err
+	}
+	return header, iv, ciphertext, tag, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// JWKSConfig is a structure containing the configuration
+// of the JSON Web Key Set (JWKS) API.
+//
+// The JWKS API publishes the public keys of signing keys such
+// that JWT verifiers can verify tokens issued via the JWS API
+// without access to KES. By default, the API requires a policy
+// allowing "/.well-known/jwks.json". It can be made public by
+// disabling authentication for this API route.
+type JWKSConfig struct {
+	// Keys are the names of the signing keys, of the default
+	// enclave, whose public keys are published. Keys that do
+	// not exist or are not Ed25519 or ECDSA-P256 keys are
+	// omitted.
+	Keys []string
+}
+
+// jwks is a HandlerFunc that returns the public keys of all versions
+// of the configured signing keys as JWK set. Each JWK has the same
+// key ID as the JWS tokens signed by the corresponding key version.
+// Hence, tokens remain verifiable after a key has been rotated while
+// verifiers pick up new key versions by refreshing the key set.
+func (s *Server) jwks(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+
+	set := api.JWKSResponse{Keys: []api.JWK{}}
+	if state.JWKS != nil {
+		for _, name := range state.JWKS.Keys {
+			// Publishing public keys does not count as using the key.
+			key, err := state.Keys.get(req.Context(), name)
+			if errors.Is(err, kes.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				if err, ok := api.IsError(err); ok {
+					resp.Failr(err)
+					return
+				}
+
+				s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+				return
+			}
+
+			for i, version := range key.Versions {
+				jwk, ok, err := publicJWK(version.Key)
+				if err != nil {
+					s.fail(resp, req, err, http.StatusInternalServerError, "failed to encode public key")
+					return
+				}
+				if !ok {
+					continue
+				}
+				jwk.KeyID = joseKeyID(name, i+1)
+				set.Keys = append(set.Keys, jwk)
+			}
+		}
+	}
+	api.ReplyWith(resp, http.StatusOK, set)
+}
+
+// publicJWK returns the public key of the SecretKey as JWK.
+// It returns false if the SecretKey is not a signing key.
+func publicJWK(key crypto.SecretKey) (api.JWK, bool, error) {
+	alg, err := key.JWSAlgorithm()
+	if err != nil {
+		return api.JWK{}, false, nil
+	}
+	der, err := key.PublicKey()
+	if err != nil {
+		return api.JWK{}, false, err
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return api.JWK{}, false, err
+	}
+
+	switch publicKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		return api.JWK{
+			KeyType:   "OKP",
+			Use:       "sig",
+			Algorithm: alg,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey),
+		}, true, nil
+	case *ecdsa.PublicKey:
+		ecdhKey, err := publicKey.ECDH()
+		if err != nil {
+			return api.JWK{}, false, err
+		}
+		point := ecdhKey.Bytes() // Uncompressed: 0x04 || X || Y
+		size := (len(point) - 1) / 2
+		return api.JWK{
+			KeyType:   "EC",
+			Use:       "sig",
+			Algorithm: alg,
+			Curve:     "P-256",
+			X:         base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+			Y:         base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		}, true, nil
+	default:
+		return api.JWK{}, false, nil
+	}
+}
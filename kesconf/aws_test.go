@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/minio/kes/kesconf"
+	"github.com/minio/kes/keystoretest"
 )
 
 var awsConfigFile = flag.String("aws.config", "", "Path to a KES config file with AWS SecretsManager config")
@@ -26,15 +27,5 @@ func TestAWS(t *testing.T) {
 		t.Fatalf("Invalid Keystore: want %T - got %T", config.KeyStore, &kesconf.AWSSecretsManagerKeyStore{})
 	}
 
-	ctx, cancel := testingContext(t)
-	defer cancel()
-
-	store, err := config.KeyStore.Connect(ctx)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	t.Run("Create", func(t *testing.T) { testCreate(ctx, store, t, RandString(ranStringLength)) })
-	t.Run("Get", func(t *testing.T) { testGet(ctx, store, t, RandString(ranStringLength)) })
-	t.Run("Status", func(t *testing.T) { testStatus(ctx, store, t) })
+	keystoretest.TestKeyStore(t, config.KeyStore.Connect)
 }
@@ -5,17 +5,26 @@
 package kesconf
 
 import (
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/minio/kes/internal/keystore/credhub"
+	"github.com/minio/kes/internal/keystore/rest"
+	"github.com/minio/kes/internal/tpm"
 	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
 	"gopkg.in/yaml.v3"
 )
 
@@ -24,6 +33,8 @@ type ymlFile struct {
 
 	Addr env[string] `yaml:"address"`
 
+	FIPS env[bool] `yaml:"fips"`
+
 	Admin struct {
 		Identity env[kes.Identity] `yaml:"identity"`
 	} `yaml:"admin"`
@@ -41,39 +52,202 @@ type ymlFile struct {
 				ClientCert env[string] `yaml:"cert"`
 			} `yaml:"header"`
 		} `yaml:"proxy"`
+
+		Revocation struct {
+			CRLs       []env[string]      `yaml:"crl"`
+			CRLRefresh env[time.Duration] `yaml:"crl_refresh"`
+			OCSP       env[bool]          `yaml:"ocsp"`
+			FailOpen   env[bool]          `yaml:"fail_open"`
+		} `yaml:"revocation"`
 	} `yaml:"tls"`
 
 	Policies map[string]struct {
 		Allow      []string            `yaml:"allow"`
 		Deny       []string            `yaml:"deny"`
 		Identities []env[kes.Identity] `yaml:"identities"`
+		Rotation   struct {
+			Interval env[time.Duration] `yaml:"interval"`
+		} `yaml:"rotation"`
 	} `yaml:"policy"`
 
+	Enclaves map[string]struct {
+		Policies map[string]struct {
+			Allow      []string            `yaml:"allow"`
+			Deny       []string            `yaml:"deny"`
+			Identities []env[kes.Identity] `yaml:"identities"`
+			Rotation   struct {
+				Interval env[time.Duration] `yaml:"interval"`
+			} `yaml:"rotation"`
+		} `yaml:"policy"`
+		KeyStore yaml.Node `yaml:"keystore"` // optional, same format as the server keystore
+	} `yaml:"enclave"`
+
 	Cache struct {
 		Expiry struct {
 			Any     env[time.Duration] `yaml:"any"`
 			Unused  env[time.Duration] `yaml:"unused"`
 			Offline env[time.Duration] `yaml:"offline"`
 		} `yaml:"expiry"`
+		DEK struct {
+			Size   env[int]           `yaml:"size"`
+			Expiry env[time.Duration] `yaml:"expiry"`
+		} `yaml:"dek"`
 	} `yaml:"cache"`
 
+	Peers struct {
+		Endpoints []env[string] `yaml:"endpoints"`
+		TLS       struct {
+			PrivateKey  env[string] `yaml:"key"`
+			Certificate env[string] `yaml:"cert"`
+			Password    env[string] `yaml:"password"`
+			CAPath      env[string] `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"peers"`
+
+	Replication struct {
+		Site         env[string]        `yaml:"site"`
+		Endpoints    []env[string]      `yaml:"endpoints"`
+		SyncInterval env[time.Duration] `yaml:"sync_interval"`
+		TLS          struct {
+			PrivateKey  env[string] `yaml:"key"`
+			Certificate env[string] `yaml:"cert"`
+			Password    env[string] `yaml:"password"`
+			CAPath      env[string] `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"replication"`
+
+	ReadReplica struct {
+		Enabled      env[bool]          `yaml:"enabled"`
+		SyncInterval env[time.Duration] `yaml:"sync_interval"`
+	} `yaml:"read_replica"`
+
+	Unix struct {
+		Path       env[string]                  `yaml:"path"`
+		Identities map[uint32]env[kes.Identity] `yaml:"identities"`
+	} `yaml:"unix"`
+
+	KMIP struct {
+		Address env[string] `yaml:"address"`
+	} `yaml:"kmip"`
+
+	AWSKMS struct {
+		Address     env[string] `yaml:"address"`
+		Region      env[string] `yaml:"region"`
+		Credentials map[string]struct {
+			SecretKey env[string]       `yaml:"secret_key"`
+			Identity  env[kes.Identity] `yaml:"identity"`
+		} `yaml:"credentials"`
+	} `yaml:"aws_kms"`
+
+	Authz struct {
+		Endpoint env[string]        `yaml:"endpoint"`
+		Format   env[string]        `yaml:"format"`
+		Timeout  env[time.Duration] `yaml:"timeout"`
+		FailOpen env[bool]          `yaml:"fail_open"`
+		Cache    env[time.Duration] `yaml:"cache"`
+		TLS      struct {
+			PrivateKey  env[string] `yaml:"key"`
+			Certificate env[string] `yaml:"cert"`
+			CAPath      env[string] `yaml:"ca"`
+		} `yaml:"tls"`
+	} `yaml:"authz"`
+
 	API struct {
+		GRPC  env[bool] `yaml:"grpc"`
 		Paths map[string]struct {
-			InsecureSkipAuth env[bool]          `yaml:"skip_auth"`
-			Timeout          env[time.Duration] `yaml:"timeout"`
+			InsecureSkipAuth  env[bool]          `yaml:"skip_auth"`
+			Timeout           env[time.Duration] `yaml:"timeout"`
+			RateLimit         env[float64]       `yaml:"rate_limit"`
+			IdentityRateLimit env[float64]       `yaml:"identity_rate_limit"`
 		} `yaml:",inline"`
 	} `yaml:"api"`
 
 	Log struct {
-		Error env[string] `yaml:"error"`
-		Audit env[string] `yaml:"audit"`
+		Error       env[string] `yaml:"error"`
+		Audit       env[string] `yaml:"audit"`
+		AuditFormat env[string] `yaml:"audit_format"`
+		AuditSyslog struct {
+			Address env[string] `yaml:"address"`
+			Format  env[string] `yaml:"format"`
+			TLS     env[bool]   `yaml:"tls"`
+			CAPath  env[string] `yaml:"ca"`
+		} `yaml:"audit_syslog"`
+		SlowKeyStore struct {
+			Threshold  env[time.Duration]            `yaml:"threshold"`
+			Operations map[string]env[time.Duration] `yaml:"operations"`
+		} `yaml:"slow_keystore"`
 	} `yaml:"log"`
 
+	Shutdown struct {
+		Timeout env[time.Duration] `yaml:"timeout"`
+	} `yaml:"shutdown"`
+
+	Startup struct {
+		RequireKeyStore env[bool]          `yaml:"require_keystore"`
+		KeyStoreTimeout env[time.Duration] `yaml:"keystore_timeout"`
+	} `yaml:"startup"`
+
+	Memory struct {
+		Lock             env[string] `yaml:"lock"`
+		DisableCoreDumps env[bool]   `yaml:"disable_core_dumps"`
+	} `yaml:"memory"`
+
 	Keys []struct {
 		Name env[string] `yaml:"name"`
 	} `yaml:"keys"`
 
+	Rotation map[string]struct {
+		Interval env[time.Duration] `yaml:"interval"`
+	} `yaml:"rotation"`
+
+	SoftDelete struct {
+		Retention env[time.Duration] `yaml:"retention"`
+	} `yaml:"soft_delete"`
+
+	Anomaly struct {
+		DecryptRate   env[int]           `yaml:"decrypt_rate"`
+		DormantPeriod env[time.Duration] `yaml:"dormant_period"`
+		Webhook       env[string]        `yaml:"webhook"`
+	} `yaml:"anomaly"`
+
+	JWKS struct {
+		Keys []env[string] `yaml:"keys"`
+	} `yaml:"jwks"`
+
+	CA map[string]struct {
+		Key      env[string]        `yaml:"key"`
+		Subject  env[string]        `yaml:"subject"`
+		DNSNames []env[string]      `yaml:"dns"`
+		IPRanges []env[string]      `yaml:"ip"`
+		MaxTTL   env[time.Duration] `yaml:"max_ttl"`
+	} `yaml:"ca"`
+
+	SSHCA map[string]struct {
+		Key        env[string]        `yaml:"key"`
+		Type       env[string]        `yaml:"type"`
+		Principals []env[string]      `yaml:"principals"`
+		MaxTTL     env[time.Duration] `yaml:"max_ttl"`
+	} `yaml:"ssh_ca"`
+
+	Seal struct {
+		Threshold   env[int]            `yaml:"threshold"`
+		KeyCheck    env[string]         `yaml:"key_check"`
+		ShareChecks []env[string]       `yaml:"share_checks"`
+		Operators   []env[kes.Identity] `yaml:"operators"`
+		Auto        *struct {
+			Key        env[string] `yaml:"key"`
+			Ciphertext env[string] `yaml:"ciphertext"`
+			KMS        yaml.Node   `yaml:"kms"` // same format as the server keystore
+		} `yaml:"auto"`
+	} `yaml:"seal"`
+
 	KeyStore struct {
+		Timeout struct {
+			Connect   env[time.Duration] `yaml:"connect"`
+			Operation env[time.Duration] `yaml:"operation"`
+			Budget    env[time.Duration] `yaml:"budget"`
+		} `yaml:"timeout"` // applies to any keystore
+
 		FS *struct {
 			Path env[string] `yaml:"path"`
 		}
@@ -93,10 +267,12 @@ type ymlFile struct {
 			APIVersion env[string] `yaml:"version"`
 			Namespace  env[string] `yaml:"namespace"`
 			Prefix     env[string] `yaml:"prefix"`
+			SoftDelete env[bool]   `yaml:"soft_delete"`
 
 			Transit *struct {
 				Engine  env[string] `yaml:"engine"`
 				KeyName env[string] `yaml:"key"`
+				Mode    env[string] `yaml:"mode"`
 			}
 
 			AppRole *struct {
@@ -159,13 +335,30 @@ type ymlFile struct {
 				ProjectID   env[string]   `yaml:"project_id"`
 				Endpoint    env[string]   `yaml:"endpoint"`
 				Scopes      []env[string] `yaml:"scopes"`
+				KMSKey      env[string]   `yaml:"kms_key"`
 				Credentials struct {
 					Client   env[string] `yaml:"client_email"`
 					ClientID env[string] `yaml:"client_id"`
 					KeyID    env[string] `yaml:"private_key_id"`
 					Key      env[string] `yaml:"private_key"`
 				} `yaml:"credentials"`
+				CredentialsFile env[string] `yaml:"credentials_file"`
 			} `yaml:"secretmanager"`
+
+			KMS *struct {
+				ProjectID   env[string]   `yaml:"project_id"`
+				Endpoint    env[string]   `yaml:"endpoint"`
+				Location    env[string]   `yaml:"location"`
+				KeyRing     env[string]   `yaml:"key_ring"`
+				Scopes      []env[string] `yaml:"scopes"`
+				Credentials struct {
+					Client   env[string] `yaml:"client_email"`
+					ClientID env[string] `yaml:"client_id"`
+					KeyID    env[string] `yaml:"private_key_id"`
+					Key      env[string] `yaml:"private_key"`
+				} `yaml:"credentials"`
+				CredentialsFile env[string] `yaml:"credentials_file"`
+			} `yaml:"kms"`
 		} `yaml:"gcp"`
 
 		AWS *struct {
@@ -179,12 +372,46 @@ type ymlFile struct {
 					SecretKey    env[string] `yaml:"secretkey"`
 					SessionToken env[string] `yaml:"token"`
 				} `yaml:"credentials"`
+
+				AssumeRole *struct {
+					RoleARN     env[string] `yaml:"role_arn"`
+					ExternalID  env[string] `yaml:"external_id"`
+					SessionName env[string] `yaml:"session_name"`
+					Region      env[string] `yaml:"region"`
+					Endpoint    env[string] `yaml:"endpoint"`
+				} `yaml:"assume_role"`
 			} `yaml:"secretsmanager"`
+
+			KMS *struct {
+				Endpoint       env[string] `yaml:"endpoint"`
+				Region         env[string] `yaml:"region"`
+				AliasPrefix    env[string] `yaml:"alias_prefix"`
+				DeletionWindow env[int]    `yaml:"deletion_window"`
+				Grants         []struct {
+					GranteePrincipal env[string] `yaml:"grantee"`
+					Operations       []string    `yaml:"operations"`
+				} `yaml:"grants"`
+
+				Login struct {
+					AccessKey    env[string] `yaml:"accesskey"`
+					SecretKey    env[string] `yaml:"secretkey"`
+					SessionToken env[string] `yaml:"token"`
+				} `yaml:"credentials"`
+
+				AssumeRole *struct {
+					RoleARN     env[string] `yaml:"role_arn"`
+					ExternalID  env[string] `yaml:"external_id"`
+					SessionName env[string] `yaml:"session_name"`
+					Region      env[string] `yaml:"region"`
+					Endpoint    env[string] `yaml:"endpoint"`
+				} `yaml:"assume_role"`
+			} `yaml:"kms"`
 		} `yaml:"aws"`
 
 		Azure *struct {
 			KeyVault *struct {
 				Endpoint    env[string] `yaml:"endpoint"`
+				ManagedHSM  env[bool]   `yaml:"managed_hsm"`
 				Credentials *struct {
 					TenantID env[string] `yaml:"tenant_id"`
 					ClientID env[string] `yaml:"client_id"`
@@ -212,16 +439,80 @@ type ymlFile struct {
 		} `yaml:"entrust"`
 
 		CredHub *struct {
-			BaseURL                   env[string] `yaml:"base_url"`
-			EnableMutualTLS           env[bool]   `yaml:"enable_mutual_tls"`
-			ClientCertFilePath        env[string] `yaml:"client_cert_file_path"`
-			ClientKeyFilePath         env[string] `yaml:"client_key_file_path"`
-			ServerCaCertFilePath      env[string] `yaml:"server_ca_cert_file_path"`
-			ServerInsecureSkipVerify  env[bool]   `yaml:"server_insecure_skip_verify"`
-			Namespace                 env[string] `yaml:"namespace"`
-			ForceBase64ValuesEncoding env[bool]   `yaml:"force_base64_values_encoding"`
+			BaseURL                   env[string]        `yaml:"base_url"`
+			EnableMutualTLS           env[bool]          `yaml:"enable_mutual_tls"`
+			ClientCertFilePath        env[string]        `yaml:"client_cert_file_path"`
+			ClientKeyFilePath         env[string]        `yaml:"client_key_file_path"`
+			ServerCaCertFilePath      env[string]        `yaml:"server_ca_cert_file_path"`
+			ServerInsecureSkipVerify  env[bool]          `yaml:"server_insecure_skip_verify"`
+			Namespace                 env[string]        `yaml:"namespace"`
+			ForceBase64ValuesEncoding env[bool]          `yaml:"force_base64_values_encoding"`
+			CreateLockTTL             env[time.Duration] `yaml:"create_lock_ttl"`
+			RequestTimeout            env[time.Duration] `yaml:"request_timeout"`
+			StatusTimeout             env[time.Duration] `yaml:"status_timeout"`
+			MaxResponseSize           env[int64]         `yaml:"max_response_size"`
+			DisableCompression        env[bool]          `yaml:"disable_compression"`
+			Permissions               []struct {
+				Actor      env[string] `yaml:"actor"`
+				Operations []string    `yaml:"operations"`
+			} `yaml:"permissions"`
 		} `yaml:"credhub"`
+
+		Split *struct {
+			First  yaml.Node `yaml:"first"`  // same format as the server keystore
+			Second yaml.Node `yaml:"second"` // same format as the server keystore
+		} `yaml:"split"`
+
+		REST *struct {
+			Endpoint env[string]            `yaml:"endpoint"`
+			Headers  map[string]env[string] `yaml:"headers"`
+			TLS      struct {
+				PrivateKey  env[string] `yaml:"key"`
+				Certificate env[string] `yaml:"cert"`
+				CAPath      env[string] `yaml:"ca"`
+			} `yaml:"tls"`
+			Create ymlRESTOperation `yaml:"create"`
+			Get    ymlRESTOperation `yaml:"get"`
+			Update ymlRESTOperation `yaml:"update"`
+			Delete ymlRESTOperation `yaml:"delete"`
+			List   ymlRESTOperation `yaml:"list"`
+			Status ymlRESTOperation `yaml:"status"`
+		} `yaml:"rest"`
+
+		Exec *struct {
+			Path    env[string]            `yaml:"path"`
+			Args    []env[string]          `yaml:"args"`
+			Env     map[string]env[string] `yaml:"env"`
+			Timeout env[time.Duration]     `yaml:"timeout"`
+		} `yaml:"exec"`
+
+		WASM *struct {
+			Module  env[string]            `yaml:"module"`
+			Runtime env[string]            `yaml:"runtime"`
+			Args    []env[string]          `yaml:"args"`
+			Env     map[string]env[string] `yaml:"env"`
+			Timeout env[time.Duration]     `yaml:"timeout"`
+		} `yaml:"wasm"`
 	} `yaml:"keystore"`
+
+	KeyStores map[string]yaml.Node `yaml:"standby_keystores"` // same format as the server keystore
+
+	Profiles map[string]yaml.Node `yaml:"profiles"` // merged into the config by applyProfile
+}
+
+// ymlRESTOperation describes a request of the generic REST keystore.
+type ymlRESTOperation struct {
+	Method env[string] `yaml:"method"`
+	URL    env[string] `yaml:"url"`
+	Path   env[string] `yaml:"path"`
+}
+
+func (o *ymlRESTOperation) toOperation() rest.Operation {
+	return rest.Operation{
+		Method: o.Method.Value,
+		URL:    o.URL.Value,
+		Path:   o.Path.Value,
+	}
 }
 
 func findVersion(root *yaml.Node) (string, error) {
@@ -261,27 +552,15 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 	if y.Admin.Identity.Value.IsUnknown() {
 		return nil, errors.New("kesconf: invalid admin identity: no admin identity")
 	}
-	if y.TLS.PrivateKey.Value == "" {
-		return nil, errors.New("kesconf: invalid tls config: no private key")
-	}
-	if y.TLS.Certificate.Value == "" {
-		return nil, errors.New("kesconf: invalid tls config: no certificate")
-	}
-
-	clientAuth := tls.RequireAnyClientCert
-	if v := strings.ToLower(y.TLS.ClientAuth.Value); v != "" && v != "on" && v != "off" {
-		return nil, fmt.Errorf("kesconf: invalid tls config: invalid auth '%s'", y.TLS.ClientAuth)
-	} else if v == "on" {
-		clientAuth = tls.RequireAndVerifyClientCert
-	}
-
-	for _, proxy := range y.TLS.Proxy.Identities {
-		if proxy.Value == y.Admin.Identity.Value {
-			return nil, fmt.Errorf("kesconf: invalid tls proxy: identity '%s' is already admin", proxy.Value)
-		}
+	tlsConfig, err := ymlToTLSConfig(y)
+	if err != nil {
+		return nil, err
 	}
 
 	for name, policy := range y.Policies {
+		if policy.Rotation.Interval.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid key rotation interval '%v' for policy '%s'", policy.Rotation.Interval.Value, name)
+		}
 		for _, identity := range policy.Identities {
 			if identity.Value == y.Admin.Identity.Value {
 				return nil, fmt.Errorf("kesconf: invalid policy '%s': identity '%s' is already admin", name, identity.Value)
@@ -303,6 +582,60 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 	if y.Cache.Expiry.Offline.Value < 0 {
 		return nil, fmt.Errorf("kesconf: invalid offline cache expiry '%v'", y.Cache.Expiry.Offline.Value)
 	}
+	if y.Cache.DEK.Size.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid data key cache size '%d'", y.Cache.DEK.Size.Value)
+	}
+	if y.Cache.DEK.Expiry.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid data key cache expiry '%v'", y.Cache.DEK.Expiry.Value)
+	}
+	if y.Cache.DEK.Size.Value > 0 && y.Cache.DEK.Expiry.Value == 0 {
+		return nil, errors.New("kesconf: data key cache expiry must be set when the data key cache is enabled")
+	}
+
+	for _, endpoint := range y.Peers.Endpoints {
+		if endpoint.Value == "" {
+			return nil, errors.New("kesconf: invalid peer config: empty endpoint")
+		}
+	}
+	for _, endpoint := range y.Replication.Endpoints {
+		if endpoint.Value == "" {
+			return nil, errors.New("kesconf: invalid replication config: empty endpoint")
+		}
+	}
+	if y.Replication.Site.Value == "" && len(y.Replication.Endpoints) > 0 {
+		return nil, errors.New("kesconf: invalid replication config: empty site")
+	}
+	if y.Replication.SyncInterval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid replication sync interval '%v'", y.Replication.SyncInterval.Value)
+	}
+	if y.ReadReplica.SyncInterval.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid read replica sync interval '%v'", y.ReadReplica.SyncInterval.Value)
+	}
+	if y.ReadReplica.Enabled.Value && y.Replication.Site.Value != "" {
+		return nil, errors.New("kesconf: a read replica cannot replicate keys")
+	}
+	if y.Unix.Path.Value == "" && len(y.Unix.Identities) > 0 {
+		return nil, errors.New("kesconf: invalid unix socket config: empty path")
+	}
+
+	if y.Shutdown.Timeout.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid shutdown timeout '%v'", y.Shutdown.Timeout.Value)
+	}
+	if y.Startup.KeyStoreTimeout.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid startup keystore timeout '%v'", y.Startup.KeyStoreTimeout.Value)
+	}
+	if y.Startup.KeyStoreTimeout.Value > 0 && !y.Startup.RequireKeyStore.Value {
+		return nil, errors.New("kesconf: invalid startup config: keystore timeout requires 'require_keystore'")
+	}
+
+	memoryLock := strings.ToLower(strings.TrimSpace(y.Memory.Lock.Value))
+	switch memoryLock {
+	case "":
+		memoryLock = MemoryLockAuto
+	case MemoryLockAuto, MemoryLockOn, MemoryLockOff:
+	default:
+		return nil, fmt.Errorf("kesconf: invalid memory lock '%s': expected '%s', '%s' or '%s'", y.Memory.Lock.Value, MemoryLockAuto, MemoryLockOn, MemoryLockOff)
+	}
 
 	errLevel, err := parseLogLevel(y.Log.Error.Value)
 	if err != nil {
@@ -312,24 +645,57 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
+	auditFormat := strings.ToLower(strings.TrimSpace(y.Log.AuditFormat.Value))
+	switch auditFormat {
+	case "":
+		auditFormat = AuditFormatText
+	case AuditFormatText, AuditFormatMinIO:
+	default:
+		return nil, fmt.Errorf("kesconf: invalid audit log format '%s'", y.Log.AuditFormat.Value)
+	}
+	var auditSyslog *SyslogConfig
+	if y.Log.AuditSyslog.Address.Value != "" {
+		format := strings.ToLower(strings.TrimSpace(y.Log.AuditSyslog.Format.Value))
+		switch format {
+		case "":
+			format = SyslogFormatCEF
+		case SyslogFormatCEF, SyslogFormatLEEF:
+		default:
+			return nil, fmt.Errorf("kesconf: invalid audit syslog format '%s'", y.Log.AuditSyslog.Format.Value)
+		}
+		auditSyslog = &SyslogConfig{
+			Address: y.Log.AuditSyslog.Address.Value,
+			Format:  format,
+			TLS:     y.Log.AuditSyslog.TLS.Value,
+			CAPath:  y.Log.AuditSyslog.CAPath.Value,
+		}
+	}
+	var slowKeyStore *SlowLogConfig
+	if y.Log.SlowKeyStore.Threshold.Value != 0 || len(y.Log.SlowKeyStore.Operations) > 0 {
+		if y.Log.SlowKeyStore.Threshold.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid slow keystore threshold '%v'", y.Log.SlowKeyStore.Threshold.Value)
+		}
+		slowKeyStore = &SlowLogConfig{
+			Threshold:  y.Log.SlowKeyStore.Threshold.Value,
+			Operations: make(map[string]time.Duration, len(y.Log.SlowKeyStore.Operations)),
+		}
+		for op, threshold := range y.Log.SlowKeyStore.Operations {
+			if threshold.Value < 0 {
+				return nil, fmt.Errorf("kesconf: invalid slow keystore threshold '%v' for operation '%s'", threshold.Value, op)
+			}
+			slowKeyStore.Operations[op] = threshold.Value
+		}
+	}
 
 	for path, api := range y.API.Paths {
 		if api.Timeout.Value < 0 {
 			return nil, fmt.Errorf("kesconf: invalid timeout '%d' for API '%s'", api.Timeout.Value, path)
 		}
-
-		// If mTLS authentication is disabled for at least one API,
-		// we must no longer require that a client sends a certificate.
-		// However, this may cause authentication errors when a client
-		// (the client's HTTP/TLS stack) does not send a certificate
-		// for an API that requires authentication.
-		if api.InsecureSkipAuth.Value {
-			if clientAuth == tls.RequireAnyClientCert {
-				clientAuth = tls.RequestClientCert
-			}
-			if clientAuth == tls.RequireAndVerifyClientCert {
-				clientAuth = tls.VerifyClientCertIfGiven
-			}
+		if api.RateLimit.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid rate limit '%v' for API '%s'", api.RateLimit.Value, path)
+		}
+		if api.IdentityRateLimit.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid identity rate limit '%v' for API '%s'", api.IdentityRateLimit.Value, path)
 		}
 	}
 
@@ -343,37 +709,219 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 		}
 	}
 
+	for pattern, rotation := range y.Rotation {
+		if pattern == "" {
+			return nil, errors.New("kesconf: invalid key rotation config: empty key name pattern")
+		}
+		if rotation.Interval.Value <= 0 {
+			return nil, fmt.Errorf("kesconf: invalid key rotation interval '%v' for '%s'", rotation.Interval.Value, pattern)
+		}
+	}
+
+	if y.SoftDelete.Retention.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid soft delete retention '%v'", y.SoftDelete.Retention.Value)
+	}
+	if y.Anomaly.DecryptRate.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly decrypt rate '%d'", y.Anomaly.DecryptRate.Value)
+	}
+	if y.Anomaly.DormantPeriod.Value < 0 {
+		return nil, fmt.Errorf("kesconf: invalid anomaly dormant period '%v'", y.Anomaly.DormantPeriod.Value)
+	}
+	if y.Anomaly.Webhook.Value != "" && y.Anomaly.DecryptRate.Value == 0 && y.Anomaly.DormantPeriod.Value == 0 {
+		return nil, errors.New("kesconf: invalid anomaly config: webhook requires 'decrypt_rate' or 'dormant_period'")
+	}
+	seal, err := ymlToSealConfig(y)
+	if err != nil {
+		return nil, err
+	}
+
 	keystore, err := ymlToKeyStore(y)
 	if err != nil {
 		return nil, err
 	}
 
+	var standby map[string]KeyStore
+	if len(y.KeyStores) > 0 {
+		standby = make(map[string]KeyStore, len(y.KeyStores))
+		for name, node := range y.KeyStores {
+			var ks ymlFile
+			if err := node.Decode(&ks.KeyStore); err != nil {
+				return nil, err
+			}
+			if standby[name], err = ymlToKeyStore(&ks); err != nil {
+				return nil, fmt.Errorf("%v in standby keystore '%s'", err, name)
+			}
+		}
+	}
+
+	var enclaves map[string]Enclave
+	if len(y.Enclaves) > 0 {
+		enclaves = make(map[string]Enclave, len(y.Enclaves))
+		for name, enclave := range y.Enclaves {
+			var e Enclave
+			if enclave.KeyStore.Kind != 0 {
+				var ks ymlFile
+				if err := enclave.KeyStore.Decode(&ks.KeyStore); err != nil {
+					return nil, err
+				}
+				if e.KeyStore, err = ymlToKeyStore(&ks); err != nil {
+					return nil, fmt.Errorf("%v in enclave '%s'", err, name)
+				}
+			}
+			if len(enclave.Policies) > 0 {
+				e.Policies = make(map[string]Policy, len(enclave.Policies))
+				for policyName, policy := range enclave.Policies {
+					identities := make([]kes.Identity, 0, len(policy.Identities))
+					for _, id := range policy.Identities {
+						identities = append(identities, id.Value)
+					}
+					e.Policies[policyName] = Policy{
+						Allow:      policy.Allow,
+						Deny:       policy.Deny,
+						Identities: identities,
+						Rotation: RotationConfig{
+							Interval: policy.Rotation.Interval.Value,
+						},
+					}
+				}
+			}
+			enclaves[name] = e
+		}
+	}
+
 	c := &File{
 		Addr:  y.Addr.Value,
 		Admin: y.Admin.Identity.Value,
-		TLS: &TLSConfig{
-			PrivateKey:        y.TLS.PrivateKey.Value,
-			Certificate:       y.TLS.Certificate.Value,
-			Password:          y.TLS.Password.Value,
-			ClientAuth:        clientAuth,
-			CAPath:            y.TLS.CAPath.Value,
-			ForwardCertHeader: y.TLS.Proxy.Header.ClientCert.Value,
-		},
+		FIPS:  y.FIPS.Value,
+		TLS:   tlsConfig,
 		Cache: &CacheConfig{
 			Expiry:        y.Cache.Expiry.Any.Value,
 			ExpiryUnused:  y.Cache.Expiry.Unused.Value,
 			ExpiryOffline: y.Cache.Expiry.Offline.Value,
+			DEKSize:       y.Cache.DEK.Size.Value,
+			DEKExpiry:     y.Cache.DEK.Expiry.Value,
 		},
 		Log: &LogConfig{
-			ErrLevel:   errLevel,
-			AuditLevel: auditLevel,
+			ErrLevel:     errLevel,
+			AuditLevel:   auditLevel,
+			AuditFormat:  auditFormat,
+			AuditSyslog:  auditSyslog,
+			SlowKeyStore: slowKeyStore,
+		},
+		Shutdown: &ShutdownConfig{
+			Timeout: y.Shutdown.Timeout.Value,
+		},
+		Memory: &MemoryConfig{
+			Lock:             memoryLock,
+			DisableCoreDumps: y.Memory.DisableCoreDumps.Value,
 		},
-		KeyStore: keystore,
+		Enclaves:  enclaves,
+		Seal:      seal,
+		KeyStore:  keystore,
+		KeyStores: standby,
 	}
-	if len(y.TLS.Proxy.Identities) > 0 {
-		c.TLS.Proxies = make([]kes.Identity, 0, len(y.TLS.Proxy.Identities))
-		for _, proxy := range y.TLS.Proxy.Identities {
-			c.TLS.Proxies = append(c.TLS.Proxies, proxy.Value)
+	if len(y.Peers.Endpoints) > 0 {
+		// Peers authenticate this server by its TLS certificate
+		// unless a separate client certificate is specified.
+		c.Peers = &PeerConfig{
+			Endpoints:   make([]string, 0, len(y.Peers.Endpoints)),
+			PrivateKey:  y.Peers.TLS.PrivateKey.Value,
+			Certificate: y.Peers.TLS.Certificate.Value,
+			Password:    y.Peers.TLS.Password.Value,
+			CAPath:      y.Peers.TLS.CAPath.Value,
+		}
+		for _, endpoint := range y.Peers.Endpoints {
+			c.Peers.Endpoints = append(c.Peers.Endpoints, endpoint.Value)
+		}
+		if c.Peers.PrivateKey == "" && c.Peers.Certificate == "" {
+			c.Peers.PrivateKey = c.TLS.PrivateKey
+			c.Peers.Certificate = c.TLS.Certificate
+			c.Peers.Password = c.TLS.Password
+		}
+		if c.Peers.CAPath == "" {
+			c.Peers.CAPath = c.TLS.CAPath
+		}
+	}
+	if y.Replication.Site.Value != "" {
+		// Other sites authenticate this server by its TLS certificate
+		// unless a separate client certificate is specified.
+		c.Replication = &ReplicationConfig{
+			Site:         y.Replication.Site.Value,
+			Endpoints:    make([]string, 0, len(y.Replication.Endpoints)),
+			SyncInterval: y.Replication.SyncInterval.Value,
+			PrivateKey:   y.Replication.TLS.PrivateKey.Value,
+			Certificate:  y.Replication.TLS.Certificate.Value,
+			Password:     y.Replication.TLS.Password.Value,
+			CAPath:       y.Replication.TLS.CAPath.Value,
+		}
+		for _, endpoint := range y.Replication.Endpoints {
+			c.Replication.Endpoints = append(c.Replication.Endpoints, endpoint.Value)
+		}
+		if c.Replication.PrivateKey == "" && c.Replication.Certificate == "" {
+			c.Replication.PrivateKey = c.TLS.PrivateKey
+			c.Replication.Certificate = c.TLS.Certificate
+			c.Replication.Password = c.TLS.Password
+		}
+		if c.Replication.CAPath == "" {
+			c.Replication.CAPath = c.TLS.CAPath
+		}
+	}
+	if y.ReadReplica.Enabled.Value {
+		c.ReadReplica = &ReadReplicaConfig{
+			SyncInterval: y.ReadReplica.SyncInterval.Value,
+		}
+	}
+	if y.Unix.Path.Value != "" {
+		c.Unix = &UnixConfig{
+			Path:       y.Unix.Path.Value,
+			Identities: make(map[uint32]kes.Identity, len(y.Unix.Identities)),
+		}
+		for uid, identity := range y.Unix.Identities {
+			c.Unix.Identities[uid] = identity.Value
+		}
+	}
+	if y.KMIP.Address.Value != "" {
+		c.KMIP = &KMIPConfig{
+			Address: y.KMIP.Address.Value,
+		}
+	}
+	if y.AWSKMS.Address.Value != "" {
+		c.AWSKMS = &AWSKMSConfig{
+			Address:     y.AWSKMS.Address.Value,
+			Region:      y.AWSKMS.Region.Value,
+			Credentials: make(map[string]AWSCredential, len(y.AWSKMS.Credentials)),
+		}
+		for accessKey, cred := range y.AWSKMS.Credentials {
+			c.AWSKMS.Credentials[accessKey] = AWSCredential{
+				SecretKey: cred.SecretKey.Value,
+				Identity:  cred.Identity.Value,
+			}
+		}
+	}
+	if y.Authz.Endpoint.Value != "" {
+		format := strings.ToLower(strings.TrimSpace(y.Authz.Format.Value))
+		switch format {
+		case "":
+			format = AuthzFormatOPA
+		case AuthzFormatOPA, AuthzFormatWebhook:
+		default:
+			return nil, fmt.Errorf("kesconf: invalid authorization format '%s'", y.Authz.Format.Value)
+		}
+		if y.Authz.Timeout.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid authorization timeout '%v'", y.Authz.Timeout.Value)
+		}
+		if y.Authz.Cache.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid authorization cache expiry '%v'", y.Authz.Cache.Value)
+		}
+		c.Authz = &AuthzConfig{
+			Endpoint:    y.Authz.Endpoint.Value,
+			Format:      format,
+			Timeout:     y.Authz.Timeout.Value,
+			FailOpen:    y.Authz.FailOpen.Value,
+			CacheExpiry: y.Authz.Cache.Value,
+			PrivateKey:  y.Authz.TLS.PrivateKey.Value,
+			Certificate: y.Authz.TLS.Certificate.Value,
+			CAPath:      y.Authz.TLS.CAPath.Value,
 		}
 	}
 	if len(y.Policies) > 0 {
@@ -387,18 +935,24 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 				Allow:      policy.Allow,
 				Deny:       policy.Deny,
 				Identities: identities,
+				Rotation: RotationConfig{
+					Interval: policy.Rotation.Interval.Value,
+				},
 			}
 		}
 	}
-	if len(y.API.Paths) > 0 {
+	if len(y.API.Paths) > 0 || y.API.GRPC.Value {
 		paths := make(map[string]APIPathConfig, len(y.API.Paths))
 		for path, api := range y.API.Paths {
 			paths[path] = APIPathConfig{
-				InsecureSkipAuth: api.InsecureSkipAuth.Value,
-				Timeout:          api.Timeout.Value,
+				InsecureSkipAuth:  api.InsecureSkipAuth.Value,
+				Timeout:           api.Timeout.Value,
+				RateLimit:         api.RateLimit.Value,
+				IdentityRateLimit: api.IdentityRateLimit.Value,
 			}
 		}
 		c.API = &APIConfig{
+			GRPC:  y.API.GRPC.Value,
 			Paths: paths,
 		}
 	}
@@ -413,6 +967,180 @@ func ymlToServerConfig(y *ymlFile) (*File, error) {
 			c.Keys = append(c.Keys, Key{Name: key.Name.Value})
 		}
 	}
+	if y.Startup.RequireKeyStore.Value {
+		c.Startup = &StartupConfig{
+			RequireKeyStore: true,
+			KeyStoreTimeout: y.Startup.KeyStoreTimeout.Value,
+		}
+	}
+	if y.SoftDelete.Retention.Value > 0 {
+		c.SoftDelete = &SoftDeleteConfig{
+			Retention: y.SoftDelete.Retention.Value,
+		}
+	}
+	if y.Anomaly.DecryptRate.Value > 0 || y.Anomaly.DormantPeriod.Value > 0 {
+		c.Anomaly = &AnomalyConfig{
+			DecryptRate:   y.Anomaly.DecryptRate.Value,
+			DormantPeriod: y.Anomaly.DormantPeriod.Value,
+			Webhook:       y.Anomaly.Webhook.Value,
+		}
+	}
+	if len(y.JWKS.Keys) > 0 {
+		c.JWKS = &JWKSConfig{
+			Keys: make([]string, 0, len(y.JWKS.Keys)),
+		}
+		for _, name := range y.JWKS.Keys {
+			c.JWKS.Keys = append(c.JWKS.Keys, name.Value)
+		}
+	}
+	if len(y.CA) > 0 {
+		c.CA = make(map[string]CAConfig, len(y.CA))
+		for name, profile := range y.CA {
+			if profile.Key.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid CA config: no key for profile '%s'", name)
+			}
+			if profile.MaxTTL.Value < 0 {
+				return nil, fmt.Errorf("kesconf: invalid CA config: invalid max. TTL '%v' for profile '%s'", profile.MaxTTL.Value, name)
+			}
+
+			ca := CAConfig{
+				Key:     profile.Key.Value,
+				Subject: profile.Subject.Value,
+				MaxTTL:  profile.MaxTTL.Value,
+			}
+			for _, dnsName := range profile.DNSNames {
+				ca.DNSNames = append(ca.DNSNames, dnsName.Value)
+			}
+			for _, ipRange := range profile.IPRanges {
+				prefix, err := netip.ParsePrefix(ipRange.Value)
+				if err != nil {
+					addr, aErr := netip.ParseAddr(ipRange.Value)
+					if aErr != nil {
+						return nil, fmt.Errorf("kesconf: invalid CA config: invalid IP range '%s' for profile '%s': %v", ipRange.Value, name, err)
+					}
+					prefix = netip.PrefixFrom(addr, addr.BitLen())
+				}
+				ca.IPRanges = append(ca.IPRanges, prefix.Masked())
+			}
+			c.CA[name] = ca
+		}
+	}
+	if len(y.SSHCA) > 0 {
+		c.SSHCA = make(map[string]SSHCAConfig, len(y.SSHCA))
+		for name, profile := range y.SSHCA {
+			if profile.Key.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid SSH CA config: no key for profile '%s'", name)
+			}
+			if profile.MaxTTL.Value < 0 {
+				return nil, fmt.Errorf("kesconf: invalid SSH CA config: invalid max. TTL '%v' for profile '%s'", profile.MaxTTL.Value, name)
+			}
+
+			var host bool
+			switch t := strings.ToLower(profile.Type.Value); t {
+			case "", "user":
+			case "host":
+				host = true
+			default:
+				return nil, fmt.Errorf("kesconf: invalid SSH CA config: invalid certificate type '%s' for profile '%s'", t, name)
+			}
+			ca := SSHCAConfig{
+				Key:    profile.Key.Value,
+				Host:   host,
+				MaxTTL: profile.MaxTTL.Value,
+			}
+			for _, principal := range profile.Principals {
+				ca.Principals = append(ca.Principals, principal.Value)
+			}
+			c.SSHCA[name] = ca
+		}
+	}
+	if len(y.Rotation) > 0 {
+		c.Rotation = make(map[string]RotationConfig, len(y.Rotation))
+		for pattern, rotation := range y.Rotation {
+			c.Rotation[pattern] = RotationConfig{
+				Interval: rotation.Interval.Value,
+			}
+		}
+	}
+	return c, nil
+}
+
+// ymlToTLSConfig returns the TLS config of the KES server. If
+// mTLS authentication is disabled for at least one API, clients
+// are no longer required to send a certificate.
+func ymlToTLSConfig(y *ymlFile) (*TLSConfig, error) {
+	if y.TLS.PrivateKey.Value == "" {
+		return nil, errors.New("kesconf: invalid tls config: no private key")
+	}
+	if y.TLS.Certificate.Value == "" {
+		return nil, errors.New("kesconf: invalid tls config: no certificate")
+	}
+
+	clientAuth := tls.RequireAnyClientCert
+	if v := strings.ToLower(y.TLS.ClientAuth.Value); v != "" && v != "on" && v != "off" {
+		return nil, fmt.Errorf("kesconf: invalid tls config: invalid auth '%s'", y.TLS.ClientAuth)
+	} else if v == "on" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	var revocation *RevocationConfig
+	if len(y.TLS.Revocation.CRLs) > 0 || y.TLS.Revocation.OCSP.Value {
+		if clientAuth != tls.RequireAndVerifyClientCert {
+			return nil, errors.New("kesconf: invalid tls config: certificate revocation checking requires 'auth: on'")
+		}
+		if y.TLS.Revocation.CRLRefresh.Value < 0 {
+			return nil, fmt.Errorf("kesconf: invalid tls config: invalid CRL refresh interval '%v'", y.TLS.Revocation.CRLRefresh.Value)
+		}
+		revocation = &RevocationConfig{
+			CRLRefresh: y.TLS.Revocation.CRLRefresh.Value,
+			OCSP:       y.TLS.Revocation.OCSP.Value,
+			FailOpen:   y.TLS.Revocation.FailOpen.Value,
+		}
+		for _, crl := range y.TLS.Revocation.CRLs {
+			if crl.Value == "" {
+				return nil, errors.New("kesconf: invalid tls config: empty CRL path or URL")
+			}
+			revocation.CRLs = append(revocation.CRLs, crl.Value)
+		}
+	}
+
+	for _, proxy := range y.TLS.Proxy.Identities {
+		if proxy.Value == y.Admin.Identity.Value {
+			return nil, fmt.Errorf("kesconf: invalid tls proxy: identity '%s' is already admin", proxy.Value)
+		}
+	}
+
+	for _, api := range y.API.Paths {
+		// If mTLS authentication is disabled for at least one API,
+		// we must no longer require that a client sends a certificate.
+		// However, this may cause authentication errors when a client
+		// (the client's HTTP/TLS stack) does not send a certificate
+		// for an API that requires authentication.
+		if api.InsecureSkipAuth.Value {
+			if clientAuth == tls.RequireAnyClientCert {
+				clientAuth = tls.RequestClientCert
+			}
+			if clientAuth == tls.RequireAndVerifyClientCert {
+				clientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+	}
+
+	c := &TLSConfig{
+		PrivateKey:        y.TLS.PrivateKey.Value,
+		Certificate:       y.TLS.Certificate.Value,
+		Password:          y.TLS.Password.Value,
+		ClientAuth:        clientAuth,
+		CAPath:            y.TLS.CAPath.Value,
+		ForwardCertHeader: y.TLS.Proxy.Header.ClientCert.Value,
+		Revocation:        revocation,
+	}
+	if len(y.TLS.Proxy.Identities) > 0 {
+		c.Proxies = make([]kes.Identity, 0, len(y.TLS.Proxy.Identities))
+		for _, proxy := range y.TLS.Proxy.Identities {
+			c.Proxies = append(c.Proxies, proxy.Value)
+		}
+	}
 	return c, nil
 }
 
@@ -434,6 +1162,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 		if keystore != nil {
 			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
 		}
+		var jwtFile string
 		if y.KeyStore.Vault.Endpoint.Value == "" {
 			return nil, errors.New("kesconf: invalid vault keystore: no endpoint specified")
 		}
@@ -452,24 +1181,38 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			}
 		}
 		if y.KeyStore.Vault.Kubernetes != nil {
-			if y.KeyStore.Vault.Kubernetes.JWT.Value == "" {
-				return nil, errors.New("kesconf: invalid vault keystore: invalid kubernetes config: no JWT specified")
+			if y.KeyStore.Vault.Kubernetes.Role.Value == "" {
+				return nil, errors.New("kesconf: invalid vault keystore: invalid kubernetes config: no role specified")
 			}
 
 			// If the passed JWT value contains a path separator we assume it's a file.
 			// We always check for '/' and the OS-specific one make cover cases where
 			// a path is specified using '/' but the underlying OS is e.g. windows.
+			//
+			// The file is read again on every login since Kubernetes rotates
+			// projected service account tokens. If no JWT is specified, the
+			// pod's default service account token is used.
 			if jwt := y.KeyStore.Vault.Kubernetes.JWT.Value; strings.ContainsRune(jwt, '/') || strings.ContainsRune(jwt, os.PathSeparator) {
-				b, err := os.ReadFile(y.KeyStore.Vault.Kubernetes.JWT.Value)
+				b, err := os.ReadFile(jwt)
 				if err != nil {
-					return nil, fmt.Errorf("kesconf: failed to read vault kubernetes JWT from '%s': %v", y.KeyStore.Vault.Kubernetes.JWT.Value, err)
+					return nil, fmt.Errorf("kesconf: failed to read vault kubernetes JWT from '%s': %v", jwt, err)
 				}
-				y.KeyStore.Vault.Kubernetes.JWT.Value = string(b)
+				jwtFile = jwt
+				y.KeyStore.Vault.Kubernetes.JWT.Value = strings.TrimSpace(string(b))
 			}
 		}
 		if y.KeyStore.Vault.Transit != nil {
-			if y.KeyStore.Vault.Transit.KeyName.Value == "" {
-				return nil, errors.New("kesconf: invalid vault keystore: invalid transit config: no key name specified")
+			switch mode := y.KeyStore.Vault.Transit.Mode.Value; mode {
+			case "", "wrap":
+				if y.KeyStore.Vault.Transit.KeyName.Value == "" {
+					return nil, errors.New("kesconf: invalid vault keystore: invalid transit config: no key name specified")
+				}
+			case "keys":
+				if y.KeyStore.Vault.Transit.KeyName.Value != "" {
+					return nil, fmt.Errorf("kesconf: invalid vault keystore: invalid transit config: key name must not be specified for mode '%s'", mode)
+				}
+			default:
+				return nil, fmt.Errorf("kesconf: invalid vault keystore: invalid transit config: invalid mode '%s'", mode)
 			}
 		}
 
@@ -485,6 +1228,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			APIVersion:  y.KeyStore.Vault.APIVersion.Value,
 			Engine:      y.KeyStore.Vault.Engine.Value,
 			Prefix:      y.KeyStore.Vault.Prefix.Value,
+			SoftDelete:  y.KeyStore.Vault.SoftDelete.Value,
 			PrivateKey:  y.KeyStore.Vault.TLS.PrivateKey.Value,
 			Certificate: y.KeyStore.Vault.TLS.Certificate.Value,
 			CAPath:      y.KeyStore.Vault.TLS.CAPath.Value,
@@ -503,6 +1247,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 				Engine:    y.KeyStore.Vault.Kubernetes.Engine.Value,
 				Namespace: y.KeyStore.Vault.Kubernetes.Namespace.Value,
 				JWT:       y.KeyStore.Vault.Kubernetes.JWT.Value,
+				JWTFile:   jwtFile,
 				Role:      y.KeyStore.Vault.Kubernetes.Role.Value,
 			}
 		}
@@ -510,6 +1255,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			s.Transit = &VaultTransit{
 				Engine:  y.KeyStore.Vault.Transit.Engine.Value,
 				KeyName: y.KeyStore.Vault.Transit.KeyName.Value,
+				Mode:    y.KeyStore.Vault.Transit.Mode.Value,
 			}
 		}
 		keystore = s
@@ -569,13 +1315,47 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			}
 		}
 		keystore = &GCPSecretManagerKeyStore{
-			ProjectID:   y.KeyStore.GCP.SecretManager.ProjectID.Value,
-			Endpoint:    y.KeyStore.GCP.SecretManager.Endpoint.Value,
-			ClientEmail: y.KeyStore.GCP.SecretManager.Credentials.Client.Value,
-			ClientID:    y.KeyStore.GCP.SecretManager.Credentials.ClientID.Value,
-			KeyID:       y.KeyStore.GCP.SecretManager.Credentials.KeyID.Value,
-			Key:         y.KeyStore.GCP.SecretManager.Credentials.Key.Value,
-			Scopes:      scopes,
+			ProjectID:       y.KeyStore.GCP.SecretManager.ProjectID.Value,
+			Endpoint:        y.KeyStore.GCP.SecretManager.Endpoint.Value,
+			KMSKey:          y.KeyStore.GCP.SecretManager.KMSKey.Value,
+			ClientEmail:     y.KeyStore.GCP.SecretManager.Credentials.Client.Value,
+			ClientID:        y.KeyStore.GCP.SecretManager.Credentials.ClientID.Value,
+			KeyID:           y.KeyStore.GCP.SecretManager.Credentials.KeyID.Value,
+			Key:             y.KeyStore.GCP.SecretManager.Credentials.Key.Value,
+			CredentialsFile: y.KeyStore.GCP.SecretManager.CredentialsFile.Value,
+			Scopes:          scopes,
+		}
+	}
+
+	// GCP Cloud KMS
+	if y.KeyStore.GCP != nil && y.KeyStore.GCP.KMS != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.GCP.KMS.ProjectID.Value == "" {
+			return nil, errors.New("kesconf: invalid GCP kms keystore: no project ID specified")
+		}
+		if y.KeyStore.GCP.KMS.Location.Value == "" {
+			return nil, errors.New("kesconf: invalid GCP kms keystore: no location specified")
+		}
+		if y.KeyStore.GCP.KMS.KeyRing.Value == "" {
+			return nil, errors.New("kesconf: invalid GCP kms keystore: no key ring specified")
+		}
+		var scopes []string
+		for _, scope := range y.KeyStore.GCP.KMS.Scopes {
+			scopes = append(scopes, scope.Value)
+		}
+		keystore = &GCPKMSKeyStore{
+			ProjectID:       y.KeyStore.GCP.KMS.ProjectID.Value,
+			Endpoint:        y.KeyStore.GCP.KMS.Endpoint.Value,
+			Location:        y.KeyStore.GCP.KMS.Location.Value,
+			KeyRing:         y.KeyStore.GCP.KMS.KeyRing.Value,
+			ClientEmail:     y.KeyStore.GCP.KMS.Credentials.Client.Value,
+			ClientID:        y.KeyStore.GCP.KMS.Credentials.ClientID.Value,
+			KeyID:           y.KeyStore.GCP.KMS.Credentials.KeyID.Value,
+			Key:             y.KeyStore.GCP.KMS.Credentials.Key.Value,
+			CredentialsFile: y.KeyStore.GCP.KMS.CredentialsFile.Value,
+			Scopes:          scopes,
 		}
 	}
 
@@ -590,7 +1370,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 		if y.KeyStore.AWS.SecretsManager.Region.Value == "" {
 			return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: no region specified")
 		}
-		keystore = &AWSSecretsManagerKeyStore{
+		s := &AWSSecretsManagerKeyStore{
 			Endpoint:     y.KeyStore.AWS.SecretsManager.Endpoint.Value,
 			Region:       y.KeyStore.AWS.SecretsManager.Region.Value,
 			KMSKey:       y.KeyStore.AWS.SecretsManager.KmsKey.Value,
@@ -598,6 +1378,69 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			SecretKey:    y.KeyStore.AWS.SecretsManager.Login.SecretKey.Value,
 			SessionToken: y.KeyStore.AWS.SecretsManager.Login.SessionToken.Value,
 		}
+		if role := y.KeyStore.AWS.SecretsManager.AssumeRole; role != nil {
+			if role.RoleARN.Value == "" {
+				return nil, errors.New("kesconf: invalid AWS secretsmanager keystore: invalid assume_role config: no role ARN specified")
+			}
+			s.AssumeRole = &AWSAssumeRole{
+				RoleARN:     role.RoleARN.Value,
+				ExternalID:  role.ExternalID.Value,
+				SessionName: role.SessionName.Value,
+				Region:      role.Region.Value,
+				Endpoint:    role.Endpoint.Value,
+			}
+		}
+		keystore = s
+	}
+
+	// AWS KMS
+	if y.KeyStore.AWS != nil && y.KeyStore.AWS.KMS != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.AWS.KMS.Endpoint.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS kms keystore: no endpoint specified")
+		}
+		if y.KeyStore.AWS.KMS.Region.Value == "" {
+			return nil, errors.New("kesconf: invalid AWS kms keystore: no region specified")
+		}
+		if w := y.KeyStore.AWS.KMS.DeletionWindow.Value; w != 0 && (w < 7 || w > 30) {
+			return nil, fmt.Errorf("kesconf: invalid AWS kms keystore: invalid deletion window '%d': must be between 7 and 30 days", w)
+		}
+		s := &AWSKMSKeyStore{
+			Endpoint:       y.KeyStore.AWS.KMS.Endpoint.Value,
+			Region:         y.KeyStore.AWS.KMS.Region.Value,
+			AliasPrefix:    y.KeyStore.AWS.KMS.AliasPrefix.Value,
+			DeletionWindow: y.KeyStore.AWS.KMS.DeletionWindow.Value,
+			AccessKey:      y.KeyStore.AWS.KMS.Login.AccessKey.Value,
+			SecretKey:      y.KeyStore.AWS.KMS.Login.SecretKey.Value,
+			SessionToken:   y.KeyStore.AWS.KMS.Login.SessionToken.Value,
+		}
+		for _, grant := range y.KeyStore.AWS.KMS.Grants {
+			if grant.GranteePrincipal.Value == "" {
+				return nil, errors.New("kesconf: invalid AWS kms keystore: invalid grant: no grantee specified")
+			}
+			if len(grant.Operations) == 0 {
+				return nil, fmt.Errorf("kesconf: invalid AWS kms keystore: invalid grant for '%s': no operations specified", grant.GranteePrincipal.Value)
+			}
+			s.Grants = append(s.Grants, AWSKMSGrant{
+				GranteePrincipal: grant.GranteePrincipal.Value,
+				Operations:       grant.Operations,
+			})
+		}
+		if role := y.KeyStore.AWS.KMS.AssumeRole; role != nil {
+			if role.RoleARN.Value == "" {
+				return nil, errors.New("kesconf: invalid AWS kms keystore: invalid assume_role config: no role ARN specified")
+			}
+			s.AssumeRole = &AWSAssumeRole{
+				RoleARN:     role.RoleARN.Value,
+				ExternalID:  role.ExternalID.Value,
+				SessionName: role.SessionName.Value,
+				Region:      role.Region.Value,
+				Endpoint:    role.Endpoint.Value,
+			}
+		}
+		keystore = s
 	}
 
 	// Azure KeyVault
@@ -622,13 +1465,9 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client secret specified")
 			}
 		}
-		if y.KeyStore.Azure.KeyVault.ManagedIdentity != nil {
-			if y.KeyStore.Azure.KeyVault.ManagedIdentity.ClientID.Value == "" {
-				return nil, errors.New("kesconf: invalid Azure keyvault keystore: no client ID specified")
-			}
-		}
 		s := &AzureKeyVaultKeyStore{
-			Endpoint: y.KeyStore.Azure.KeyVault.Endpoint.Value,
+			Endpoint:   y.KeyStore.Azure.KeyVault.Endpoint.Value,
+			ManagedHSM: y.KeyStore.Azure.KeyVault.ManagedHSM.Value,
 		}
 		if y.KeyStore.Azure.KeyVault.Credentials != nil {
 			s.TenantID = y.KeyStore.Azure.KeyVault.Credentials.TenantID.Value
@@ -636,6 +1475,7 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			s.ClientSecret = y.KeyStore.Azure.KeyVault.Credentials.Secret.Value
 		}
 		if y.KeyStore.Azure.KeyVault.ManagedIdentity != nil {
+			s.ManagedIdentity = true
 			s.ManagedIdentityClientID = y.KeyStore.Azure.KeyVault.ManagedIdentity.ClientID.Value
 		}
 		keystore = s
@@ -671,6 +1511,36 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 		}
 	}
 
+	// KES
+	if y.KeyStore.KES != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if len(y.KeyStore.KES.Endpoint) == 0 {
+			return nil, errors.New("kesconf: invalid KES keystore: no endpoint specified")
+		}
+		endpoints := make([]string, 0, len(y.KeyStore.KES.Endpoint))
+		for _, endpoint := range y.KeyStore.KES.Endpoint {
+			if endpoint.Value == "" {
+				return nil, errors.New("kesconf: invalid KES keystore: empty endpoint")
+			}
+			endpoints = append(endpoints, endpoint.Value)
+		}
+		if y.KeyStore.KES.TLS.Certificate.Value == "" {
+			return nil, errors.New("kesconf: invalid KES keystore: invalid tls config: no TLS certificate provided")
+		}
+		if y.KeyStore.KES.TLS.PrivateKey.Value == "" {
+			return nil, errors.New("kesconf: invalid KES keystore: invalid tls config: no TLS private key provided")
+		}
+		keystore = &KESKeyStore{
+			Endpoints:   endpoints,
+			Enclave:     y.KeyStore.KES.Enclave.Value,
+			Certificate: y.KeyStore.KES.TLS.Certificate.Value,
+			PrivateKey:  y.KeyStore.KES.TLS.PrivateKey.Value,
+			CAPath:      y.KeyStore.KES.TLS.CAPath.Value,
+		}
+	}
+
 	// CF CredHub
 	if y.KeyStore.CredHub != nil {
 		if keystore != nil {
@@ -685,6 +1555,17 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 			ServerCaCertFilePath:      y.KeyStore.CredHub.ServerCaCertFilePath.Value,
 			Namespace:                 y.KeyStore.CredHub.Namespace.Value,
 			ForceBase64ValuesEncoding: y.KeyStore.CredHub.ForceBase64ValuesEncoding.Value,
+			CreateLockTTL:             y.KeyStore.CredHub.CreateLockTTL.Value,
+			RequestTimeout:            y.KeyStore.CredHub.RequestTimeout.Value,
+			StatusTimeout:             y.KeyStore.CredHub.StatusTimeout.Value,
+			MaxResponseSize:           y.KeyStore.CredHub.MaxResponseSize.Value,
+			DisableCompression:        y.KeyStore.CredHub.DisableCompression.Value,
+		}
+		for _, permission := range y.KeyStore.CredHub.Permissions {
+			config.Permissions = append(config.Permissions, credhub.Permission{
+				Actor:      permission.Actor.Value,
+				Operations: permission.Operations,
+			})
 		}
 		_, err := config.Validate()
 		if err != nil {
@@ -693,12 +1574,174 @@ func ymlToKeyStore(y *ymlFile) (KeyStore, error) {
 		keystore = &CredHubKeyStore{Config: &config}
 	}
 
+	// Split Keystore
+	if y.KeyStore.Split != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.Split.First.Kind == 0 {
+			return nil, errors.New("kesconf: invalid split keystore: no first keystore specified")
+		}
+		if y.KeyStore.Split.Second.Kind == 0 {
+			return nil, errors.New("kesconf: invalid split keystore: no second keystore specified")
+		}
+
+		var first, second ymlFile
+		if err := y.KeyStore.Split.First.Decode(&first.KeyStore); err != nil {
+			return nil, err
+		}
+		if err := y.KeyStore.Split.Second.Decode(&second.KeyStore); err != nil {
+			return nil, err
+		}
+		firstStore, err := ymlToKeyStore(&first)
+		if err != nil {
+			return nil, fmt.Errorf("%v in first split keystore", err)
+		}
+		secondStore, err := ymlToKeyStore(&second)
+		if err != nil {
+			return nil, fmt.Errorf("%v in second split keystore", err)
+		}
+		keystore = &SplitKeyStore{
+			First:  firstStore,
+			Second: secondStore,
+		}
+	}
+
+	// Generic REST Keystore
+	if y.KeyStore.REST != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.REST.Endpoint.Value == "" {
+			return nil, errors.New("kesconf: invalid REST keystore: no endpoint specified")
+		}
+		for name, op := range map[string]ymlRESTOperation{"create": y.KeyStore.REST.Create, "get": y.KeyStore.REST.Get, "delete": y.KeyStore.REST.Delete, "list": y.KeyStore.REST.List} {
+			if op.URL.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid REST keystore: no URL for '%s' specified", name)
+			}
+		}
+		for name, op := range map[string]ymlRESTOperation{"create": y.KeyStore.REST.Create, "get": y.KeyStore.REST.Get, "list": y.KeyStore.REST.List} {
+			if op.Path.Value == "" {
+				return nil, fmt.Errorf("kesconf: invalid REST keystore: no JSON path for '%s' specified", name)
+			}
+		}
+		if y.KeyStore.REST.Update.URL.Value != "" && y.KeyStore.REST.Update.Path.Value == "" {
+			return nil, errors.New("kesconf: invalid REST keystore: no JSON path for 'update' specified")
+		}
+		if (y.KeyStore.REST.TLS.Certificate.Value == "") != (y.KeyStore.REST.TLS.PrivateKey.Value == "") {
+			return nil, errors.New("kesconf: invalid REST keystore: invalid tls config: TLS certificate and private key must be specified together")
+		}
+
+		header := make(http.Header, len(y.KeyStore.REST.Headers))
+		for key, value := range y.KeyStore.REST.Headers {
+			header.Set(key, value.Value)
+		}
+		keystore = &RESTKeyStore{
+			Endpoint:    y.KeyStore.REST.Endpoint.Value,
+			Header:      header,
+			Certificate: y.KeyStore.REST.TLS.Certificate.Value,
+			PrivateKey:  y.KeyStore.REST.TLS.PrivateKey.Value,
+			CAPath:      y.KeyStore.REST.TLS.CAPath.Value,
+			Create:      y.KeyStore.REST.Create.toOperation(),
+			Get:         y.KeyStore.REST.Get.toOperation(),
+			Update:      y.KeyStore.REST.Update.toOperation(),
+			Delete:      y.KeyStore.REST.Delete.toOperation(),
+			List:        y.KeyStore.REST.List.toOperation(),
+			Status:      y.KeyStore.REST.Status.toOperation(),
+		}
+	}
+
+	// Exec / Plugin Keystore
+	if y.KeyStore.Exec != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.Exec.Path.Value == "" {
+			return nil, errors.New("kesconf: invalid exec keystore: no executable path specified")
+		}
+		if y.KeyStore.Exec.Timeout.Value < 0 {
+			return nil, errors.New("kesconf: invalid exec keystore: timeout is negative")
+		}
+		env, err := pluginEnv(y.KeyStore.Exec.Env)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid exec keystore: %v", err)
+		}
+		keystore = &ExecKeyStore{
+			Path:    y.KeyStore.Exec.Path.Value,
+			Args:    pluginArgs(y.KeyStore.Exec.Args),
+			Env:     env,
+			Timeout: y.KeyStore.Exec.Timeout.Value,
+		}
+	}
+
+	// WASM Plugin Keystore
+	if y.KeyStore.WASM != nil {
+		if keystore != nil {
+			return nil, errors.New("kesconf: invalid keystore config: more than once keystore specified")
+		}
+		if y.KeyStore.WASM.Module.Value == "" {
+			return nil, errors.New("kesconf: invalid WASM keystore: no module specified")
+		}
+		if y.KeyStore.WASM.Timeout.Value < 0 {
+			return nil, errors.New("kesconf: invalid WASM keystore: timeout is negative")
+		}
+		env, err := pluginEnv(y.KeyStore.WASM.Env)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid WASM keystore: %v", err)
+		}
+		keystore = &WASMKeyStore{
+			Module:  y.KeyStore.WASM.Module.Value,
+			Runtime: y.KeyStore.WASM.Runtime.Value,
+			Args:    pluginArgs(y.KeyStore.WASM.Args),
+			Env:     env,
+			Timeout: y.KeyStore.WASM.Timeout.Value,
+		}
+	}
+
 	if keystore == nil {
 		return nil, errors.New("kesconf: no keystore specified")
 	}
+
+	if timeout := y.KeyStore.Timeout; timeout.Connect.Value != 0 || timeout.Operation.Value != 0 || timeout.Budget.Value != 0 {
+		if timeout.Connect.Value < 0 || timeout.Operation.Value < 0 || timeout.Budget.Value < 0 {
+			return nil, errors.New("kesconf: invalid keystore timeout: timeout is negative")
+		}
+		if timeout.Budget.Value > 0 && timeout.Operation.Value > timeout.Budget.Value {
+			return nil, fmt.Errorf("kesconf: invalid keystore timeout: operation timeout '%v' exceeds budget '%v'", timeout.Operation.Value, timeout.Budget.Value)
+		}
+		keystore = &TimeoutKeyStore{
+			KeyStore:         keystore,
+			ConnectTimeout:   timeout.Connect.Value,
+			OperationTimeout: timeout.Operation.Value,
+			Budget:           timeout.Budget.Value,
+		}
+	}
 	return keystore, nil
 }
 
+// pluginArgs returns the command line arguments of a keystore plugin.
+func pluginArgs(args []env[string]) []string {
+	s := make([]string, 0, len(args))
+	for _, arg := range args {
+		s = append(s, arg.Value)
+	}
+	return s
+}
+
+// pluginEnv returns the env. variables of a keystore plugin in
+// the form "KEY=value", sorted by name.
+func pluginEnv(vars map[string]env[string]) ([]string, error) {
+	s := make([]string, 0, len(vars))
+	for key, value := range vars {
+		if key == "" || strings.ContainsRune(key, '=') {
+			return nil, fmt.Errorf("invalid env. variable name '%s'", key)
+		}
+		s = append(s, key+"="+value.Value)
+	}
+	slices.Sort(s)
+	return s, nil
+}
+
 type env[T any] struct {
 	Var   string
 	Value T
@@ -720,13 +1763,27 @@ func (r env[T]) MarshalYAML() (any, error) {
 
 func (r *env[T]) UnmarshalYAML(node *yaml.Node) error {
 	var env string
-	if v := strings.TrimSpace(node.Value); strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
+	if node.Tag == EncryptedTag {
+		v, err := DecryptValue(node.Value, []byte(os.Getenv(EnvConfigPassword)))
+		if err != nil {
+			return fmt.Errorf("kesconf: failed to decrypt value in line '%d': %v", node.Line, err)
+		}
+		node.Value, node.Tag = v, ""
+	} else if v := strings.TrimSpace(node.Value); strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
 		env = strings.TrimSpace(v[2 : len(v)-1])
-		v, ok := os.LookupEnv(env)
-		if !ok {
-			return fmt.Errorf("kesconf: referenced env. variable '%s' in line '%d' not found", env, node.Line)
+		if filename, ok := strings.CutPrefix(env, "tpm:"); ok {
+			v, err := unsealTPM(filename)
+			if err != nil {
+				return fmt.Errorf("kesconf: failed to unseal '%s' in line '%d': %v", filename, node.Line, err)
+			}
+			node.Value = v
+		} else {
+			v, ok := os.LookupEnv(env)
+			if !ok {
+				return fmt.Errorf("kesconf: referenced env. variable '%s' in line '%d' not found", env, node.Line)
+			}
+			node.Value = v
 		}
-		node.Value = v
 	}
 
 	var v T
@@ -738,6 +1795,102 @@ func (r *env[T]) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// unsealTPM reads the secret sealed by the TPM from the given
+// file and unseals it with the TPM device. The device can be
+// customized via the KES_TPM_DEVICE env. variable.
+func unsealTPM(filename string) (string, error) {
+	sealed, err := os.ReadFile(strings.TrimSpace(filename))
+	if err != nil {
+		return "", err
+	}
+	rw, err := tpm.Open(os.Getenv("KES_TPM_DEVICE"))
+	if err != nil {
+		return "", err
+	}
+	defer rw.Close()
+
+	secret, err := tpm.Unseal(rw, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+const (
+	// EncryptedTag is the YAML tag of encrypted config values.
+	// For example:
+	//
+	//	client_key: !encrypted "AAAAA..."
+	//
+	// Encrypted values are decrypted with the password
+	// of the EnvConfigPassword env. variable.
+	EncryptedTag = "!encrypted"
+
+	// EnvConfigPassword is the env. variable containing the
+	// password for decrypting encrypted config values.
+	EnvConfigPassword = "KES_CONFIG_PASSWORD"
+)
+
+// Parameters of the Argon2id key derivation of encrypted config values.
+const (
+	encryptedSaltSize = 16
+	argon2Time        = 3
+	argon2Memory      = 64 * 1024 // in KiB
+	argon2Threads     = 4
+)
+
+// EncryptValue encrypts the value with a key derived from the password
+// and returns the base64-encoded ciphertext. It can be used in the config
+// file as value tagged with EncryptedTag.
+func EncryptValue(value string, password []byte) (string, error) {
+	if len(password) == 0 {
+		return "", errors.New("kesconf: password is empty")
+	}
+
+	var salt [encryptedSaltSize]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(argon2.IDKey(password, salt[:], argon2Time, argon2Memory, argon2Threads, chacha20poly1305.KeySize))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := append(salt[:], nonce...)
+	ciphertext = aead.Seal(ciphertext, nonce, []byte(value), salt[:])
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptValue decrypts the base64-encoded ciphertext, produced by
+// EncryptValue, with a key derived from the password.
+func DecryptValue(ciphertext string, password []byte) (string, error) {
+	if len(password) == 0 {
+		return "", fmt.Errorf("no password specified. Set the env. variable '%s'", EnvConfigPassword)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ciphertext))
+	if err != nil {
+		return "", errors.New("invalid ciphertext encoding")
+	}
+	if len(b) < encryptedSaltSize+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return "", errors.New("invalid ciphertext length")
+	}
+	salt, nonce, b := b[:encryptedSaltSize], b[encryptedSaltSize:encryptedSaltSize+chacha20poly1305.NonceSizeX], b[encryptedSaltSize+chacha20poly1305.NonceSizeX:]
+
+	aead, err := chacha20poly1305.NewX(argon2.IDKey(password, salt, argon2Time, argon2Memory, argon2Threads, chacha20poly1305.KeySize))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, nonce, b, salt)
+	if err != nil {
+		return "", errors.New("invalid password or ciphertext")
+	}
+	return string(plaintext), nil
+}
+
 func parseLogLevel(s string) (slog.Level, error) {
 	const (
 		LevelDebug = "DEBUG"
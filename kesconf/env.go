@@ -0,0 +1,174 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kesconf
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of all env. variables that configure
+// the KES server.
+//
+// The env. variable of a config file field is the EnvPrefix
+// followed by the field's path in upper case with '_' as
+// separator. For example:
+//
+//	tls:
+//	  key: ./server.key     # KES_TLS_KEY=./server.key
+//	keystore:
+//	  credhub:
+//	    base_url: https://  # KES_KEYSTORE_CREDHUB_BASE_URL=https://
+//
+// Lists of values, like 'tls.proxy.identities', are specified as
+// comma-separated list. Maps and lists of objects, like 'policy'
+// or 'keys', can only be specified in the config file.
+//
+// A value set via an env. variable takes precedence over the
+// value in the config file.
+const EnvPrefix = "KES_"
+
+// ReadEnv reads the KES server configuration from env. variables
+// only. It returns an error if no such env. variable is set.
+//
+// See EnvPrefix for the names of the env. variables.
+func ReadEnv() (*File, error) {
+	var y ymlFile
+	n, err := applyEnv(&y, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("kesconf: no config file specified and no '%s*' env. variable set", EnvPrefix)
+	}
+	return ymlToServerConfig(&y)
+}
+
+// ReadFileWithEnv reads the KES configuration from the given file,
+// like ReadFile, and overrides the values of the file with the
+// values of the corresponding env. variables.
+//
+// If filename is empty, ReadFileWithEnv reads the configuration
+// from env. variables only, like ReadEnv.
+//
+// See EnvPrefix for the names of the env. variables.
+func ReadFileWithEnv(filename string) (*File, error) {
+	if filename == "" {
+		return ReadEnv()
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	y, err := readConfig(f, formatOf(filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = applyEnv(y, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return ymlToServerConfig(y)
+}
+
+// applyEnv sets all fields of y for which lookup returns a value
+// and returns the number of fields set.
+func applyEnv(y *ymlFile, lookup func(string) (string, bool)) (int, error) {
+	return applyEnvStruct(reflect.ValueOf(y).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup)
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	nodeType        = reflect.TypeOf(yaml.Node{})
+)
+
+// applyEnvStruct sets all env[T] fields, and lists of env[T]
+// fields, of the struct v. Nested structs are set recursively.
+// A nil struct pointer is only allocated if at least one of
+// its fields gets set.
+func applyEnvStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) (int, error) {
+	var n int
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		name = prefix + "_" + strings.ToUpper(name)
+
+		switch {
+		case field.Type == nodeType:
+			continue // Nested keystores can only be specified in the config file
+		case reflect.PointerTo(field.Type).Implements(unmarshalerType):
+			s, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := unmarshalEnv(value, name, s); err != nil {
+				return n, err
+			}
+			n++
+		case field.Type.Kind() == reflect.Struct:
+			m, err := applyEnvStruct(value, name, lookup)
+			if err != nil {
+				return n, err
+			}
+			n += m
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			elem := reflect.New(field.Type.Elem())
+			if !value.IsNil() {
+				elem = value
+			}
+			m, err := applyEnvStruct(elem.Elem(), name, lookup)
+			if err != nil {
+				return n, err
+			}
+			if m > 0 {
+				value.Set(elem)
+			}
+			n += m
+		case field.Type.Kind() == reflect.Slice && reflect.PointerTo(field.Type.Elem()).Implements(unmarshalerType):
+			s, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			var items []string
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+
+			list := reflect.MakeSlice(field.Type, len(items), len(items))
+			for j, item := range items {
+				if err := unmarshalEnv(list.Index(j), name, item); err != nil {
+					return n, err
+				}
+			}
+			value.Set(list)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// unmarshalEnv parses the value s of the env. variable name into v.
+func unmarshalEnv(v reflect.Value, name, s string) error {
+	node := &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Value: s,
+	}
+	if err := v.Addr().Interface().(yaml.Unmarshaler).UnmarshalYAML(node); err != nil {
+		return fmt.Errorf("kesconf: invalid value of env. variable '%s': %v", name, err)
+	}
+	return nil
+}
@@ -12,6 +12,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net/http"
+	"net/netip"
 	"os"
 	"slices"
 	"time"
@@ -28,13 +31,19 @@ import (
 	"github.com/minio/kes/internal/keystore/fs"
 	"github.com/minio/kes/internal/keystore/gcp"
 	"github.com/minio/kes/internal/keystore/gemalto"
+	kesks "github.com/minio/kes/internal/keystore/kes"
+	"github.com/minio/kes/internal/keystore/plugin"
+	"github.com/minio/kes/internal/keystore/rest"
+	"github.com/minio/kes/internal/keystore/split"
 	"github.com/minio/kes/internal/keystore/vault"
 	kesdk "github.com/minio/kms-go/kes"
-	"gopkg.in/yaml.v3"
 )
 
 // ReadFile opens the given file and reads the KES configuration
-// from it by calling ReadFrom.
+// from it. It detects the config format by the file extension:
+// ".json" files are read by calling ReadFromJSON and ".toml" files
+// by calling ReadFromTOML. All other files are read by calling
+// ReadFrom.
 func ReadFile(filename string) (*File, error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -43,22 +52,57 @@ func ReadFile(filename string) (*File, error) {
 	// make sure to close file in case of panic
 	defer func(f *os.File) { _ = f.Close() }(f)
 
-	file, err := ReadFrom(f)
+	y, err := readConfig(f, formatOf(filename))
 	if cErr := f.Close(); err == nil {
 		err = cErr
 	}
-	return file, err
+	if err != nil {
+		return nil, err
+	}
+	return ymlToServerConfig(y)
 }
 
 // ReadFrom parses and returns a new KES server configuration file
 // from r.
 func ReadFrom(r io.Reader) (*File, error) {
-	var node yaml.Node
-	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+	y, err := readConfig(r, formatYAML)
+	if err != nil {
+		return nil, err
+	}
+	return ymlToServerConfig(y)
+}
+
+// ReadFromJSON parses and returns a new KES server configuration
+// file in JSON format from r. The JSON config has the same structure
+// as the YAML config.
+func ReadFromJSON(r io.Reader) (*File, error) {
+	y, err := readConfig(r, formatJSON)
+	if err != nil {
+		return nil, err
+	}
+	return ymlToServerConfig(y)
+}
+
+// ReadFromTOML parses and returns a new KES server configuration
+// file in TOML format from r. The TOML config has the same structure
+// as the YAML config.
+func ReadFromTOML(r io.Reader) (*File, error) {
+	y, err := readConfig(r, formatTOML)
+	if err != nil {
 		return nil, err
 	}
+	return ymlToServerConfig(y)
+}
 
-	version, err := findVersion(&node)
+// readConfig parses the config file, in the given format, read
+// from r and applies the active profile, if any.
+func readConfig(r io.Reader, f format) (*ymlFile, error) {
+	node, err := decodeNode(r, f)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := findVersion(node)
 	if err != nil {
 		return nil, err
 	}
@@ -66,12 +110,15 @@ func ReadFrom(r io.Reader) (*File, error) {
 	if version != "" && version != Version {
 		return nil, fmt.Errorf("edge: invalid server config version '%s'", version)
 	}
+	if err = applyProfile(node); err != nil {
+		return nil, err
+	}
 
 	var y ymlFile
 	if err := node.Decode(&y); err != nil {
 		return nil, err
 	}
-	return ymlToServerConfig(&y)
+	return &y, nil
 }
 
 // File is a structure that holds the content of a KES server
@@ -94,15 +141,63 @@ type File struct {
 	// Admin is the KES server admin identity.
 	Admin kes.Identity
 
+	// FIPS enables FIPS mode. Then, the KES server only uses
+	// FIPS approved TLS cipher suites, curves and cryptographic
+	// algorithms.
+	FIPS bool
+
 	// TLS contains the KES server TLS configuration.
 	TLS *TLSConfig
 
 	// Cache contains the KES server cache configuration.
 	Cache *CacheConfig
 
+	// Peers contains the KES servers that share the keystore
+	// with this server.
+	Peers *PeerConfig
+
+	// Replication contains the KES server's key replication
+	// configuration. If nil, keys are not replicated to other
+	// KES clusters.
+	Replication *ReplicationConfig
+
+	// ReadReplica contains the read replica configuration.
+	// If not nil, the KES server only serves operations that
+	// read keys from a read-only copy of the keystore.
+	ReadReplica *ReadReplicaConfig
+
+	// Unix contains the KES server Unix domain socket
+	// configuration. If nil, the server only accepts
+	// HTTPS requests.
+	Unix *UnixConfig
+
+	// KMIP contains the KES server KMIP configuration.
+	// If nil, the server does not accept KMIP requests.
+	KMIP *KMIPConfig
+
+	// AWSKMS contains the KES server AWS KMS API configuration.
+	// If nil, the server does not accept AWS KMS requests.
+	AWSKMS *AWSKMSConfig
+
+	// Authz contains the external authorization configuration.
+	// If nil, requests are only authorized by policies.
+	Authz *AuthzConfig
+
 	// Log contains the KES server logging configuration.
 	Log *LogConfig
 
+	// Shutdown contains the KES server shutdown configuration.
+	Shutdown *ShutdownConfig
+
+	// Startup contains the KES server startup configuration.
+	// If nil, the server starts even if its keystore is not
+	// reachable.
+	Startup *StartupConfig
+
+	// Memory contains the KES server memory protection
+	// configuration.
+	Memory *MemoryConfig
+
 	// API contains the KES server API configuration.
 	API *APIConfig
 
@@ -110,14 +205,56 @@ type File struct {
 	// and statical identity assignments.
 	Policies map[string]Policy
 
+	// Enclaves contains the KES server enclave definitions.
+	// Each enclave has its own policies and, optionally, its
+	// own keystore.
+	Enclaves map[string]Enclave
+
 	// Keys contains pre-defined keys that the KES server will
 	// either create, or expect to exist, before accepting requests.
 	Keys []Key
 
+	// Rotation contains the KES server key rotation config.
+	// It maps key names or key name patterns, like "my-app-*",
+	// to the rotation config of all matching keys.
+	Rotation map[string]RotationConfig
+
+	// SoftDelete contains the KES server soft delete config.
+	// If nil, deleted keys cannot be restored.
+	SoftDelete *SoftDeleteConfig
+
+	// Seal, if not nil, makes the KES server start sealed. Then,
+	// encrypted values of the config file are decrypted with the
+	// root key reconstructed from the unseal key shares submitted
+	// by operators. See ReadSealedFile.
+	Seal *SealConfig
+
+	// Anomaly contains the KES server anomaly detection config.
+	// If nil, no alerts are raised for suspicious key usage.
+	Anomaly *AnomalyConfig
+
+	// JWKS contains the KES server JWKS API config. If nil,
+	// the JWKS API returns an empty key set.
+	JWKS *JWKSConfig
+
+	// CA contains the KES server CA profiles. It maps
+	// profile names to the CA config of the profile.
+	CA map[string]CAConfig
+
+	// SSHCA contains the KES server SSH CA profiles. It maps
+	// profile names to the SSH CA config of the profile.
+	SSHCA map[string]SSHCAConfig
+
 	// KeyStore contains the KES server keystore configuration.
 	// The KeyStore manages the keys used by the KES server for
 	// encryption and decryption.
 	KeyStore KeyStore
+
+	// KeyStores contains the KES server standby keystores. The
+	// KES server admin can switch from the KeyStore to a standby
+	// keystore, without restarting the server, once all keys
+	// have been migrated.
+	KeyStores map[string]KeyStore
 }
 
 // TLSConfig returns a new TLS configuration as specified by
@@ -164,6 +301,7 @@ func (f *File) TLSConfig() (*tls.Config, error) {
 func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 	conf := &kes.Config{
 		Admin: f.Admin,
+		FIPS:  f.FIPS,
 	}
 
 	if f.TLS != nil {
@@ -172,6 +310,15 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 			return nil, err
 		}
 		conf.TLS = tlsConf
+
+		if r := f.TLS.Revocation; r != nil {
+			conf.Revocation = &kes.RevocationConfig{
+				CRLs:       slices.Clone(r.CRLs),
+				CRLRefresh: r.CRLRefresh,
+				OCSP:       r.OCSP,
+				FailOpen:   r.FailOpen,
+			}
+		}
 	}
 
 	if f.Cache != nil {
@@ -179,49 +326,289 @@ func (f *File) Config(ctx context.Context) (*kes.Config, error) {
 			Expiry:        f.Cache.Expiry,
 			ExpiryUnused:  f.Cache.ExpiryUnused,
 			ExpiryOffline: f.Cache.ExpiryOffline,
+			DEKSize:       f.Cache.DEKSize,
+			DEKExpiry:     f.Cache.DEKExpiry,
+		}
+	}
+
+	if f.SoftDelete != nil {
+		conf.SoftDelete = &kes.SoftDeleteConfig{
+			Retention: f.SoftDelete.Retention,
+		}
+	}
+
+	if f.Anomaly != nil {
+		conf.Anomalies = &kes.AnomalyConfig{
+			DecryptRate:   f.Anomaly.DecryptRate,
+			DormantPeriod: f.Anomaly.DormantPeriod,
+			Webhook:       f.Anomaly.Webhook,
+		}
+	}
+
+	if f.JWKS != nil {
+		conf.JWKS = &kes.JWKSConfig{
+			Keys: slices.Clone(f.JWKS.Keys),
+		}
+	}
+
+	if len(f.CA) > 0 {
+		conf.CA = make(map[string]kes.CAConfig, len(f.CA))
+		for name, profile := range f.CA {
+			conf.CA[name] = kes.CAConfig{
+				Key:      profile.Key,
+				Subject:  profile.Subject,
+				DNSNames: slices.Clone(profile.DNSNames),
+				IPRanges: slices.Clone(profile.IPRanges),
+				MaxTTL:   profile.MaxTTL,
+			}
+		}
+	}
+
+	if len(f.SSHCA) > 0 {
+		conf.SSHCA = make(map[string]kes.SSHCAConfig, len(f.SSHCA))
+		for name, profile := range f.SSHCA {
+			conf.SSHCA[name] = kes.SSHCAConfig{
+				Key:        profile.Key,
+				Host:       profile.Host,
+				Principals: slices.Clone(profile.Principals),
+				MaxTTL:     profile.MaxTTL,
+			}
 		}
 	}
 
+	if f.Peers != nil && len(f.Peers.Endpoints) > 0 {
+		tlsConf, err := f.Peers.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		conf.Peers = &kes.PeerConfig{
+			Endpoints: slices.Clone(f.Peers.Endpoints),
+			TLS:       tlsConf,
+		}
+	}
+
+	if f.Replication != nil {
+		tlsConf, err := f.Replication.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		conf.Replication = &kes.ReplicationConfig{
+			Site:         f.Replication.Site,
+			Endpoints:    slices.Clone(f.Replication.Endpoints),
+			TLS:          tlsConf,
+			SyncInterval: f.Replication.SyncInterval,
+		}
+	}
+
+	if f.ReadReplica != nil {
+		conf.ReadReplica = &kes.ReadReplicaConfig{
+			SyncInterval: f.ReadReplica.SyncInterval,
+		}
+	}
+
+	if f.Unix != nil {
+		conf.UnixSocket = &kes.UnixSocketConfig{
+			Path:       f.Unix.Path,
+			Identities: maps.Clone(f.Unix.Identities),
+		}
+	}
+
+	if f.Log != nil {
+		var handlers []kes.AuditHandler
+		switch {
+		case f.Log.AuditFormat == AuditFormatMinIO:
+			handlers = append(handlers, kes.NewMinIOAuditHandler(os.Stdout, f.Log.AuditLevel))
+		case f.Log.AuditSyslog != nil:
+			// The server only logs audit events to STDOUT by default
+			// if no audit handler is configured. Hence, we have to add
+			// it explicitly when sending audit events to syslog.
+			handlers = append(handlers, &kes.AuditLogHandler{
+				Handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: f.Log.AuditLevel}),
+			})
+		}
+		if f.Log.AuditSyslog != nil {
+			syslog, err := f.Log.AuditSyslog.Handler()
+			if err != nil {
+				return nil, err
+			}
+			handlers = append(handlers, syslog)
+		}
+
+		switch len(handlers) {
+		case 0:
+		case 1:
+			conf.AuditLog = handlers[0]
+		default:
+			conf.AuditLog = kes.MultiAuditHandler(handlers...)
+		}
+
+		if f.Log.SlowKeyStore != nil {
+			conf.SlowLog = &kes.SlowLogConfig{
+				Threshold:  f.Log.SlowKeyStore.Threshold,
+				Operations: maps.Clone(f.Log.SlowKeyStore.Operations),
+			}
+		}
+	}
+
+	if f.KMIP != nil {
+		conf.KMIP = &kes.KMIPConfig{
+			Address: f.KMIP.Address,
+		}
+	}
+
+	if f.AWSKMS != nil {
+		conf.AWSKMS = &kes.AWSKMSConfig{
+			Address:     f.AWSKMS.Address,
+			Region:      f.AWSKMS.Region,
+			Credentials: make(map[string]kes.AWSCredential, len(f.AWSKMS.Credentials)),
+		}
+		for accessKey, cred := range f.AWSKMS.Credentials {
+			conf.AWSKMS.Credentials[accessKey] = kes.AWSCredential{
+				SecretKey: cred.SecretKey,
+				Identity:  cred.Identity,
+			}
+		}
+	}
+
+	if f.Authz != nil {
+		authz, err := f.Authz.Config()
+		if err != nil {
+			return nil, err
+		}
+		conf.Authz = authz
+	}
+
+	if f.API != nil {
+		conf.GRPC = f.API.GRPC
+	}
 	if f.API != nil && len(f.API.Paths) > 0 {
 		conf.Routes = make(map[string]kes.RouteConfig, len(f.API.Paths))
 		for path, config := range f.API.Paths {
 			conf.Routes[path] = kes.RouteConfig{
-				Timeout:          config.Timeout,
-				InsecureSkipAuth: config.InsecureSkipAuth,
+				Timeout:           config.Timeout,
+				InsecureSkipAuth:  config.InsecureSkipAuth,
+				RateLimit:         config.RateLimit,
+				IdentityRateLimit: config.IdentityRateLimit,
 			}
 		}
 	}
 
-	var policies map[string]kes.Policy
 	if len(f.Policies) > 0 {
-		policies = make(map[string]kes.Policy, len(f.Policies))
-		for name, policy := range f.Policies {
-			p := kes.Policy{
-				Allow:      make(map[string]kesdk.Rule, len(policy.Allow)),
-				Deny:       make(map[string]kesdk.Rule, len(policy.Deny)),
-				Identities: slices.Clone(policy.Identities),
-			}
-			for _, pattern := range policy.Allow {
-				p.Allow[pattern] = struct{}{}
-			}
-			for _, pattern := range policy.Deny {
-				p.Deny[pattern] = struct{}{}
+		conf.Policies = policiesToConfig(f.Policies)
+	}
+
+	if len(f.Rotation) > 0 {
+		conf.Rotation = make(map[string]kes.RotationConfig, len(f.Rotation))
+		for pattern, rotation := range f.Rotation {
+			conf.Rotation[pattern] = kes.RotationConfig{
+				Interval: rotation.Interval,
 			}
-			policies[name] = p
 		}
-		conf.Policies = policies
 	}
 
 	if f.KeyStore != nil {
-		keystore, err := f.KeyStore.Connect(ctx)
+		keystore, err := connectKeyStore(ctx, f.KeyStore, f.Startup)
 		if err != nil {
 			return nil, err
 		}
 		conf.Keys = keystore
 	}
+
+	if len(f.KeyStores) > 0 {
+		conf.KeyStores = make(map[string]kes.KeyStore, len(f.KeyStores))
+		for name, keystore := range f.KeyStores {
+			store, err := keystore.Connect(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("standby keystore '%s': %v", name, err)
+			}
+			conf.KeyStores[name] = store
+		}
+	}
+
+	if len(f.Enclaves) > 0 {
+		conf.Enclaves = make(map[string]kes.EnclaveConfig, len(f.Enclaves))
+		for name, enclave := range f.Enclaves {
+			var e kes.EnclaveConfig
+			if len(enclave.Policies) > 0 {
+				e.Policies = policiesToConfig(enclave.Policies)
+			}
+			if enclave.KeyStore != nil {
+				keystore, err := enclave.KeyStore.Connect(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("enclave '%s': %v", name, err)
+				}
+				e.Keys = keystore
+			}
+			conf.Enclaves[name] = e
+		}
+	}
 	return conf, nil
 }
 
+// connectKeyStore connects to the keystore. If the startup config
+// requires a reachable keystore, it also checks the keystore status
+// and retries, with exponential backoff, until the startup keystore
+// timeout has elapsed.
+func connectKeyStore(ctx context.Context, keystore KeyStore, startup *StartupConfig) (kes.KeyStore, error) {
+	const (
+		MinDelay = 1 * time.Second
+		MaxDelay = 30 * time.Second
+	)
+
+	if startup == nil || !startup.RequireKeyStore {
+		return keystore.Connect(ctx)
+	}
+
+	deadline := time.Now().Add(startup.KeyStoreTimeout)
+	for delay := MinDelay; ; delay = min(2*delay, MaxDelay) {
+		store, err := keystore.Connect(ctx)
+		if err == nil {
+			if _, err = store.Status(ctx); err == nil {
+				return store, nil
+			}
+			store.Close()
+		}
+
+		if remaining := time.Until(deadline); remaining <= 0 {
+			return nil, fmt.Errorf("keystore is not reachable: %v", err)
+		} else if delay > remaining {
+			delay = remaining
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("keystore is not reachable: %v", context.Cause(ctx))
+		case <-timer.C:
+		}
+	}
+}
+
+// policiesToConfig converts a set of policies from the
+// configuration file into a set of KES server policies.
+func policiesToConfig(policies map[string]Policy) map[string]kes.Policy {
+	conf := make(map[string]kes.Policy, len(policies))
+	for name, policy := range policies {
+		p := kes.Policy{
+			Allow:      make(map[string]kesdk.Rule, len(policy.Allow)),
+			Deny:       make(map[string]kesdk.Rule, len(policy.Deny)),
+			Identities: slices.Clone(policy.Identities),
+			Rotation: kes.RotationConfig{
+				Interval: policy.Rotation.Interval,
+			},
+		}
+		for _, pattern := range policy.Allow {
+			p.Allow[pattern] = struct{}{}
+		}
+		for _, pattern := range policy.Deny {
+			p.Deny[pattern] = struct{}{}
+		}
+		conf[name] = p
+	}
+	return conf
+}
+
 // TLSConfig is a structure that holds the TLS configuration
 // for a KES server.
 type TLSConfig struct {
@@ -261,6 +648,31 @@ type TLSConfig struct {
 	// TLS / HTTPS proxy to forward the actual client certificate
 	// to KES.
 	ForwardCertHeader string
+
+	// Revocation is an optional configuration for checking
+	// whether client certificates have been revoked. It requires
+	// that ClientAuth verifies client certificates.
+	Revocation *RevocationConfig
+}
+
+// RevocationConfig is a structure that holds the client
+// certificate revocation configuration for a KES server.
+type RevocationConfig struct {
+	// CRLs is a list of file paths or HTTP(S) URLs of CRLs.
+	CRLs []string
+
+	// CRLRefresh is the interval in which CRLs are fetched again.
+	// If zero, the KES server fetches CRLs every hour.
+	CRLRefresh time.Duration
+
+	// OCSP controls whether the KES server queries the OCSP
+	// responder of client certificates.
+	OCSP bool
+
+	// FailOpen controls whether requests are allowed if the
+	// revocation status of a client certificate cannot be
+	// determined. By default, such requests are denied.
+	FailOpen bool
 }
 
 // CacheConfig is a structure that holds the Cache configuration
@@ -288,6 +700,260 @@ type CacheConfig struct {
 	// available. As long as the keystore is available, the regular
 	// cache expiry periods apply.
 	ExpiryOffline time.Duration
+
+	// DEKSize is the max. number of decrypted data keys kept in
+	// the data key cache. The data key cache is disabled if zero.
+	DEKSize int
+
+	// DEKExpiry is the time period after which entries in the
+	// data key cache are discarded.
+	DEKExpiry time.Duration
+}
+
+// PeerConfig is a structure that holds the configuration
+// of the KES servers sharing the keystore with a KES server.
+type PeerConfig struct {
+	// Endpoints are the HTTPS endpoints of the peers.
+	Endpoints []string
+
+	// PrivateKey is the path to the TLS private key used to
+	// authenticate to the peers.
+	PrivateKey string
+
+	// Certificate is the path to the TLS certificate used to
+	// authenticate to the peers.
+	Certificate string
+
+	// Password is an optional password to decrypt the private key.
+	Password string
+
+	// CAPath is an optional path to a X.509 certificate or directory
+	// containing X.509 certificates that are used, in addition to the
+	// system root certificates, to verify the peers' certificates.
+	CAPath string
+}
+
+// TLSConfig returns a new TLS client configuration for
+// connecting to the peers.
+func (c *PeerConfig) TLSConfig() (*tls.Config, error) {
+	return clientTLSConfig("peer", c.Certificate, c.PrivateKey, c.Password, c.CAPath)
+}
+
+// ReplicationConfig is a structure that holds the configuration
+// for replicating keys between KES clusters.
+type ReplicationConfig struct {
+	// Site is the unique name of the KES cluster. All servers
+	// of a cluster must use the same site name.
+	Site string
+
+	// Endpoints are the HTTPS endpoints of the other sites.
+	Endpoints []string
+
+	// SyncInterval is the interval at which all keys are pushed
+	// to the other sites. If 0, a default interval is used.
+	SyncInterval time.Duration
+
+	// PrivateKey is the path to the TLS private key used to
+	// authenticate to the other sites.
+	PrivateKey string
+
+	// Certificate is the path to the TLS certificate used to
+	// authenticate to the other sites.
+	Certificate string
+
+	// Password is an optional password to decrypt the private key.
+	Password string
+
+	// CAPath is an optional path to a X.509 certificate or directory
+	// containing X.509 certificates that are used, in addition to the
+	// system root certificates, to verify the other sites' certificates.
+	CAPath string
+}
+
+// TLSConfig returns a new TLS client configuration for
+// connecting to the other sites.
+func (c *ReplicationConfig) TLSConfig() (*tls.Config, error) {
+	return clientTLSConfig("replication", c.Certificate, c.PrivateKey, c.Password, c.CAPath)
+}
+
+// ReadReplicaConfig is a structure that holds the configuration
+// of a KES server running as read replica.
+type ReadReplicaConfig struct {
+	// SyncInterval is the interval at which the read replica
+	// discards its cached keys. If 0, a default interval is used.
+	SyncInterval time.Duration
+}
+
+// clientTLSConfig returns a new TLS client configuration with
+// the given client certificate and CA certificates. The kind
+// describes the connections in error messages.
+func clientTLSConfig(kind, certFile, keyFile, password, caPath string) (*tls.Config, error) {
+	certificate, err := https.CertificateFromFile(certFile, keyFile, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s TLS certificate: %v", kind, err)
+	}
+
+	var rootCAs *x509.CertPool
+	if caPath != "" {
+		rootCAs, err = https.CertPoolFromFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s TLS CA certificates: %v", kind, err)
+		}
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      rootCAs,
+	}, nil
+}
+
+// Enclave is a structure that holds the configuration of
+// a KES enclave.
+type Enclave struct {
+	// Policies contains the enclave's policy definitions
+	// and statical identity assignments.
+	Policies map[string]Policy
+
+	// KeyStore is the enclave's keystore. If nil, the enclave's
+	// keys are stored on the server's keystore within a separate
+	// namespace.
+	KeyStore KeyStore
+}
+
+// UnixConfig is a structure that holds the Unix domain
+// socket configuration of a KES server.
+type UnixConfig struct {
+	// Path is the path of the Unix domain socket.
+	Path string
+
+	// Identities maps user IDs of local processes
+	// to KES identities.
+	Identities map[uint32]kes.Identity
+}
+
+// KMIPConfig is a structure that holds the KMIP
+// configuration of a KES server.
+type KMIPConfig struct {
+	// Address is the network address the KMIP
+	// API listens on, e.g. "0.0.0.0:5696".
+	Address string
+}
+
+// AWSKMSConfig is a structure that holds the AWS KMS API
+// configuration of a KES server.
+type AWSKMSConfig struct {
+	// Address is the network address the AWS KMS
+	// API listens on, e.g. "0.0.0.0:7374".
+	Address string
+
+	// Region is the AWS region clients send
+	// requests to, e.g. "us-east-1".
+	Region string
+
+	// Credentials maps AWS access keys to their
+	// secret keys and KES identities.
+	Credentials map[string]AWSCredential
+}
+
+// AWSCredential is an AWS secret key and the KES
+// identity requests signed with it are served for.
+type AWSCredential struct {
+	SecretKey string
+	Identity  kes.Identity
+}
+
+// AuthzConfig is a structure that holds the configuration
+// of an external authorization endpoint.
+type AuthzConfig struct {
+	// Endpoint is the HTTP(S) URL of the authorization endpoint.
+	// For example, an OPA data API endpoint:
+	// "https://opa:8181/v1/data/kes/allow".
+	Endpoint string
+
+	// Format is the request format of the endpoint. Either
+	// AuthzFormatOPA or AuthzFormatWebhook.
+	Format string
+
+	// Timeout is the max. time the KES server waits for an
+	// authorization decision.
+	Timeout time.Duration
+
+	// FailOpen determines whether requests are allowed if the
+	// endpoint fails to make a decision. By default, they are
+	// denied.
+	FailOpen bool
+
+	// CacheExpiry is the time the KES server caches authorization
+	// decisions. If zero, decisions are not cached.
+	CacheExpiry time.Duration
+
+	// PrivateKey is an optional path to a TLS private key used
+	// to authenticate to the endpoint.
+	PrivateKey string
+
+	// Certificate is an optional path to a TLS certificate used
+	// to authenticate to the endpoint.
+	Certificate string
+
+	// CAPath is an optional path to a X.509 certificate or directory
+	// containing X.509 certificates that are used, in addition to the
+	// system root certificates, to verify the endpoint's certificate.
+	CAPath string
+}
+
+// External authorization formats.
+const (
+	// AuthzFormatOPA is the Open Policy Agent REST API format.
+	AuthzFormatOPA = "opa"
+
+	// AuthzFormatWebhook is a generic JSON webhook format.
+	AuthzFormatWebhook = "webhook"
+)
+
+// Config returns the KES server configuration of the
+// external authorization endpoint.
+func (c *AuthzConfig) Config() (*kes.AuthzConfig, error) {
+	conf := &kes.AuthzConfig{
+		Endpoint:    c.Endpoint,
+		Format:      kes.AuthzOPA,
+		Timeout:     c.Timeout,
+		FailOpen:    c.FailOpen,
+		CacheExpiry: c.CacheExpiry,
+	}
+	if c.Format == AuthzFormatWebhook {
+		conf.Format = kes.AuthzWebhook
+	}
+
+	tlsConf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.PrivateKey != "" || c.Certificate != "" {
+		certificate, err := https.CertificateFromFile(c.Certificate, c.PrivateKey, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read authorization TLS certificate: %v", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{certificate}
+	}
+	if c.CAPath != "" {
+		rootCAs, err := https.CertPoolFromFile(c.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read authorization TLS CA certificates: %v", err)
+		}
+		tlsConf.RootCAs = rootCAs
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	conf.Client = &http.Client{Transport: transport}
+	return conf, nil
+}
+
+// RotationConfig is a structure that holds the key rotation
+// configuration for a set of keys.
+type RotationConfig struct {
+	// Interval is the time period after which the KES server
+	// rotates a key automatically by adding a new key version.
+	Interval time.Duration
 }
 
 // LogConfig is a structure that holds the logging configuration
@@ -300,11 +966,231 @@ type LogConfig struct {
 	// Audit determines whether the KES server logs audit events to STDOUT.
 	// It does not en/disable audit logging in general.
 	AuditLevel slog.Level
+
+	// AuditFormat is the format of audit events logged to STDOUT.
+	// It is either AuditFormatText or AuditFormatMinIO.
+	AuditFormat string
+
+	// AuditSyslog is an optional syslog server receiving audit
+	// events. Audit events are sent to it regardless of AuditLevel.
+	AuditSyslog *SyslogConfig
+
+	// SlowKeyStore, if not nil, makes the KES server log keystore
+	// operations that exceed a latency threshold.
+	SlowKeyStore *SlowLogConfig
+}
+
+// SlowLogConfig is a structure that holds the configuration
+// for logging slow keystore operations.
+type SlowLogConfig struct {
+	// Threshold is the latency above which keystore
+	// operations are logged.
+	Threshold time.Duration
+
+	// Operations contains per-operation thresholds, like
+	// for "get" or "create", that override Threshold.
+	Operations map[string]time.Duration
+}
+
+// SyslogConfig is a structure that holds the configuration
+// of a syslog server receiving audit events.
+type SyslogConfig struct {
+	// Address is the host:port of the syslog server.
+	Address string
+
+	// Format is the message format. Either SyslogFormatCEF
+	// or SyslogFormatLEEF.
+	Format string
+
+	// TLS determines whether audit events are sent via
+	// TLS instead of plain TCP.
+	TLS bool
+
+	// CAPath is an optional path to a X.509 certificate or directory
+	// containing X.509 certificates that are used, in addition to the
+	// system root certificates, to verify the syslog server's certificate.
+	CAPath string
+}
+
+// Syslog message formats.
+const (
+	// SyslogFormatCEF is the ArcSight Common Event Format.
+	SyslogFormatCEF = "cef"
+
+	// SyslogFormatLEEF is the IBM Log Event Extended Format.
+	SyslogFormatLEEF = "leef"
+)
+
+// Handler returns a new AuditHandler sending audit events
+// to the syslog server.
+func (c *SyslogConfig) Handler() (*kes.SyslogAuditHandler, error) {
+	conf := &kes.SyslogConfig{
+		Address: c.Address,
+		Format:  kes.SyslogCEF,
+	}
+	if c.Format == SyslogFormatLEEF {
+		conf.Format = kes.SyslogLEEF
+	}
+	if c.TLS {
+		var rootCAs *x509.CertPool
+		if c.CAPath != "" {
+			var err error
+			if rootCAs, err = https.CertPoolFromFile(c.CAPath); err != nil {
+				return nil, fmt.Errorf("failed to read syslog TLS CA certificates: %v", err)
+			}
+		}
+		conf.TLS = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    rootCAs,
+		}
+	}
+	return kes.NewSyslogAuditHandler(conf)
+}
+
+// Audit log formats.
+const (
+	// AuditFormatText logs audit events as text lines.
+	AuditFormatText = "text"
+
+	// AuditFormatMinIO logs audit events as JSON objects using
+	// the schema of MinIO's audit webhook.
+	AuditFormatMinIO = "minio"
+)
+
+// ShutdownConfig is a structure that holds the shutdown
+// configuration for a KES server.
+type ShutdownConfig struct {
+	// Timeout is the time period the KES server waits for
+	// in-flight requests to finish when shutting down. Once
+	// it has elapsed, requests are canceled. If zero, the
+	// kes.ServerShutdownTimeout is used.
+	Timeout time.Duration
+}
+
+// StartupConfig is a structure that holds the startup
+// configuration for a KES server.
+type StartupConfig struct {
+	// RequireKeyStore controls whether the keystore must be
+	// reachable before the KES server starts. If true, File.Config
+	// fails if it cannot connect to the keystore or the keystore
+	// does not respond to a status check.
+	RequireKeyStore bool
+
+	// KeyStoreTimeout is the time period the KES server keeps
+	// trying to reach the keystore, with exponential backoff,
+	// before startup fails. If zero, startup fails immediately.
+	KeyStoreTimeout time.Duration
+}
+
+// SoftDeleteConfig is a structure that holds the soft delete
+// configuration for a KES server.
+type SoftDeleteConfig struct {
+	// Retention is the period during which a deleted key
+	// can be restored before it gets deleted permanently.
+	Retention time.Duration
+}
+
+// AnomalyConfig is a structure that holds the anomaly detection
+// configuration for a KES server.
+type AnomalyConfig struct {
+	// DecryptRate is the max. number of decrypt operations per
+	// minute an identity may perform with a single key before
+	// an alert is raised.
+	DecryptRate int
+
+	// DormantPeriod is the time after which an unused key is
+	// considered dormant. An alert is raised when a dormant
+	// key is used again.
+	DormantPeriod time.Duration
+
+	// Webhook is an optional HTTP(S) URL that receives alerts.
+	Webhook string
+}
+
+// JWKSConfig is a structure that holds the configuration
+// of the KES server JWKS API.
+type JWKSConfig struct {
+	// Keys are the names of the signing keys whose
+	// public keys are published via the JWKS API.
+	Keys []string
+}
+
+// CAConfig is a structure that holds the configuration
+// of a KES server CA profile.
+type CAConfig struct {
+	// Key is the name of the key that signs certificates.
+	Key string
+
+	// Subject is the common name of the CA certificate.
+	Subject string
+
+	// DNSNames are the DNS names, like "*.svc.cluster.local",
+	// certificates may be issued for.
+	DNSNames []string
+
+	// IPRanges are the IP address ranges certificates
+	// may be issued for.
+	IPRanges []netip.Prefix
+
+	// MaxTTL is the max. validity period of issued
+	// certificates.
+	MaxTTL time.Duration
+}
+
+// SSHCAConfig is a structure that holds the configuration
+// of a KES server SSH CA profile.
+type SSHCAConfig struct {
+	// Key is the name of the key that signs certificates.
+	Key string
+
+	// Host, if set, makes the profile issue host certificates
+	// instead of user certificates.
+	Host bool
+
+	// Principals are the user or host names certificates
+	// may be issued for.
+	Principals []string
+
+	// MaxTTL is the max. validity period of issued
+	// certificates.
+	MaxTTL time.Duration
+}
+
+// Supported memory lock modes.
+const (
+	// MemoryLockAuto tries to lock the memory of the KES server
+	// and continues with unlocked memory if locking fails.
+	MemoryLockAuto = "auto"
+
+	// MemoryLockOn requires that the memory of the KES server
+	// is locked. The KES server fails to start otherwise.
+	MemoryLockOn = "on"
+
+	// MemoryLockOff does not lock the memory of the KES server.
+	MemoryLockOff = "off"
+)
+
+// MemoryConfig is a structure that holds the memory protection
+// configuration for a KES server.
+type MemoryConfig struct {
+	// Lock controls whether the KES server locks its memory
+	// into RAM such that key material is never swapped to disk.
+	// It is either MemoryLockAuto, MemoryLockOn or MemoryLockOff.
+	// Memory can only be locked on Linux.
+	Lock string
+
+	// DisableCoreDumps prevents the KES server from writing core
+	// dumps that may contain key material when it crashes.
+	DisableCoreDumps bool
 }
 
 // APIConfig is a structure that holds the API configuration
 // for a KES server.
 type APIConfig struct {
+	// GRPC enables the KES gRPC API. gRPC requests are served
+	// on the same address as the HTTPS API.
+	GRPC bool
+
 	// Paths contains a set of API paths and there
 	// API configuration.
 	Paths map[string]APIPathConfig
@@ -327,6 +1213,16 @@ type APIPathConfig struct {
 	// cases for APIs that don't expose sensitive information,
 	// like metrics.
 	InsecureSkipAuth bool
+
+	// RateLimit is the max. number of requests per second
+	// the API accepts from all clients combined. If zero,
+	// requests are not rate limited.
+	RateLimit float64
+
+	// IdentityRateLimit is the max. number of requests per
+	// second the API accepts from a single identity. If zero,
+	// requests are not rate limited per identity.
+	IdentityRateLimit float64
 }
 
 // Policy is a structure defining a KES policy.
@@ -350,6 +1246,10 @@ type Policy struct {
 	// It must not contain the admin or any
 	// TLS proxy identity.
 	Identities []kes.Identity
+
+	// Rotation is the rotation config of all
+	// keys the policy allows to create.
+	Rotation RotationConfig
 }
 
 // Key is a structure defining a cryptographic key
@@ -370,6 +1270,79 @@ type KeyStore interface {
 	Connect(ctx context.Context) (kes.KeyStore, error)
 }
 
+// TimeoutKeyStore is a structure containing the timeouts
+// of another keystore. The timeouts are applied to any
+// keystore via context deadlines.
+type TimeoutKeyStore struct {
+	// KeyStore is the keystore the timeouts apply to.
+	KeyStore KeyStore
+
+	// ConnectTimeout is the max. time connecting to
+	// the keystore may take. If <= 0, connecting does
+	// not time out.
+	ConnectTimeout time.Duration
+
+	// OperationTimeout is the timeout of a single attempt
+	// of a keystore operation. If <= 0, attempts do not
+	// time out.
+	OperationTimeout time.Duration
+
+	// Budget is the max. time a keystore operation may take,
+	// including retries. Operations that do not modify the
+	// keystore are retried within their budget if the keystore
+	// is unreachable. If <= 0, operations are not retried.
+	Budget time.Duration
+}
+
+// Connect connects to the keystore and returns a kes.KeyStore
+// that applies the operation timeout and budget to all its
+// operations.
+//
+// Connect does not cancel the context passed to the keystore
+// when the connect timeout is exceeded since keystores may use
+// it for background tasks, like renewing access tokens. Instead,
+// it closes the keystore once it has been connected.
+func (s *TimeoutKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	conf := &kes.KeyStoreTimeoutConfig{
+		Operation: s.OperationTimeout,
+		Budget:    s.Budget,
+	}
+	if s.ConnectTimeout <= 0 {
+		store, err := s.KeyStore.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return kes.NewTimeoutKeyStore(store, conf), nil
+	}
+
+	type Result struct {
+		Store kes.KeyStore
+		Err   error
+	}
+	ch := make(chan Result, 1)
+	go func() {
+		store, err := s.KeyStore.Connect(ctx)
+		ch <- Result{Store: store, Err: err}
+	}()
+
+	timer := time.NewTimer(s.ConnectTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return kes.NewTimeoutKeyStore(r.Store, conf), nil
+	case <-timer.C:
+		go func() {
+			if r := <-ch; r.Err == nil {
+				r.Store.Close()
+			}
+		}()
+		return nil, fmt.Errorf("kesconf: connecting to keystore timed out after %v: %w", s.ConnectTimeout, context.DeadlineExceeded)
+	}
+}
+
 // FSKeyStore is a structure containing the configuration
 // for a simple filesystem keystore.
 //
@@ -414,6 +1387,12 @@ type VaultKeyStore struct {
 	// level.
 	Prefix string
 
+	// SoftDelete, if true, makes the K/V v2 engine only mark
+	// deleted keys as deleted such that they can be recovered.
+	// Otherwise, all versions and the metadata of a deleted key
+	// are removed.
+	SoftDelete bool
+
 	// AppRole contains the Vault AppRole authentication
 	// method credentials.
 	AppRole *VaultAppRoleAuth
@@ -429,6 +1408,11 @@ type VaultKeyStore struct {
 	// This is an optional and additional layer of encryption.
 	// Since Vault manages and encrypts K/V values in any case,
 	// using the transit engine is usually not necessary.
+	//
+	// In the transit mode "keys", no keys are stored at the
+	// K/V engine. Instead, Vault performs all cryptographic
+	// operations with transit keys such that key material
+	// never leaves Vault.
 	Transit *VaultTransit
 
 	// PrivateKey is an optional path to a
@@ -516,6 +1500,14 @@ type VaultKubernetesAuth struct {
 	// the JWT for for authenticating via the kubernetes authentication
 	// method.
 	JWT string
+
+	// JWTFile is an optional path to a file containing the JWT. The
+	// file is read on every login such that a rotated service account
+	// token is used when re-authenticating. If set, JWT is ignored.
+	//
+	// If JWT and JWTFile are empty, the pod's default service account
+	// token is used.
+	JWTFile string
 }
 
 // VaultTransit is a structure containing the configuration
@@ -526,7 +1518,18 @@ type VaultTransit struct {
 	Engine string
 
 	// KeyName is the name of the key used for en/decryption.
+	// It must be empty if Mode is "keys".
 	KeyName string
+
+	// Mode is either "wrap" or "keys". In the "wrap" mode, the
+	// keys stored at the K/V engine are encrypted with the key
+	// KeyName. In the "keys" mode, each key is a transit key and
+	// Vault creates keys and encrypts and decrypts data keys.
+	// Secrets and operations that require key material, like
+	// HMAC or key export, are not supported.
+	//
+	// If empty, defaults to "wrap".
+	Mode string
 }
 
 // Connect returns a kv.Store that stores key-value pairs on a Hashicorp Vault server.
@@ -543,6 +1546,7 @@ func (s *VaultKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 		APIVersion:      s.APIVersion,
 		Namespace:       s.Namespace,
 		Prefix:          s.Prefix,
+		SoftDelete:      s.SoftDelete,
 		PrivateKey:      s.PrivateKey,
 		Certificate:     s.Certificate,
 		CAPath:          s.CAPath,
@@ -562,12 +1566,17 @@ func (s *VaultKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 			Namespace: s.Kubernetes.Namespace,
 			Role:      s.Kubernetes.Role,
 			JWT:       s.Kubernetes.JWT,
+			JWTFile:   s.Kubernetes.JWTFile,
 		}
 	}
 	if s.Transit != nil {
 		c.Transit = &vault.Transit{
 			Engine:  s.Transit.Engine,
 			KeyName: s.Transit.KeyName,
+			Mode:    s.Transit.Mode,
+		}
+		if s.Transit.Mode == vault.TransitModeKeys {
+			return vault.ConnectTransit(ctx, c)
 		}
 	}
 	return vault.Connect(ctx, c)
@@ -677,6 +1686,19 @@ type GCPSecretManagerKeyStore struct {
 	// service account used to access the
 	// SecretManager.
 	Key string
+
+	// CredentialsFile is an optional path to
+	// a GCP credential configuration file, for
+	// example a workload identity federation
+	// configuration. It must not be set if a
+	// service account key is set.
+	CredentialsFile string
+
+	// KMSKey is an optional resource name of a
+	// Cloud KMS key. If set, the SecretManager
+	// encrypts new secrets with this customer-
+	// managed encryption key (CMEK).
+	KMSKey string
 }
 
 // Connect returns a kv.Store that stores key-value pairs on GCP SecretManager.
@@ -691,6 +1713,78 @@ func (s *GCPSecretManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore, e
 			KeyID:    s.KeyID,
 			Key:      s.Key,
 		},
+		CredentialsFile: s.CredentialsFile,
+		KMSKey:          s.KMSKey,
+	})
+}
+
+// GCPKMSKeyStore is a structure containing the
+// configuration for GCP Cloud KMS.
+type GCPKMSKeyStore struct {
+	// ProjectID is the GCP project ID.
+	ProjectID string
+
+	// Endpoint is the Cloud KMS endpoint. If
+	// empty, defaults to:
+	//   https://cloudkms.googleapis.com
+	Endpoint string
+
+	// Location is the GCP location of the key
+	// ring. For example: us-east1 or global.
+	Location string
+
+	// KeyRing is the name of the Cloud KMS key
+	// ring that contains all keys.
+	KeyRing string
+
+	// Scopes are GCP OAuth2 scopes for accessing
+	// GCP APIs. If empty, defaults to the GCP
+	// default scopes.
+	Scopes []string
+
+	// ClientEmail is the Client email of the
+	// GCP service account used to access
+	// Cloud KMS.
+	ClientEmail string
+
+	// ClientID is the Client ID of the GCP
+	// service account used to access
+	// Cloud KMS.
+	ClientID string
+
+	// KeyID is the private key ID of the GCP
+	// service account used to access
+	// Cloud KMS.
+	KeyID string
+
+	// Key is the private key of the GCP
+	// service account used to access
+	// Cloud KMS.
+	Key string
+
+	// CredentialsFile is an optional path to
+	// a GCP credential configuration file, for
+	// example a workload identity federation
+	// configuration. It must not be set if a
+	// service account key is set.
+	CredentialsFile string
+}
+
+// Connect returns a kes.KeyStore that keeps keys at GCP Cloud KMS.
+func (s *GCPKMSKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	return gcp.ConnectKMS(ctx, &gcp.KMSConfig{
+		Endpoint:  s.Endpoint,
+		ProjectID: s.ProjectID,
+		Location:  s.Location,
+		KeyRing:   s.KeyRing,
+		Scopes:    s.Scopes,
+		Credentials: gcp.Credentials{
+			ClientID: s.ClientID,
+			Client:   s.ClientEmail,
+			KeyID:    s.KeyID,
+			Key:      s.Key,
+		},
+		CredentialsFile: s.CredentialsFile,
 	})
 }
 
@@ -721,11 +1815,37 @@ type AWSSecretsManagerKeyStore struct {
 	// SessionToken is an optional session token for authenticating
 	// to AWS.
 	SessionToken string
+
+	// AssumeRole is an optional IAM role that is assumed to access
+	// the SecretsManager, for example of a central security account.
+	AssumeRole *AWSAssumeRole
+}
+
+// AWSAssumeRole is a structure containing the configuration
+// of an IAM role assumed via AWS STS.
+type AWSAssumeRole struct {
+	// RoleARN is the ARN of the IAM role.
+	RoleARN string
+
+	// ExternalID is an optional external ID required by
+	// the role's trust policy.
+	ExternalID string
+
+	// SessionName is the role session name. If empty,
+	// defaults to "kes".
+	SessionName string
+
+	// Region is the region of the STS endpoint. If empty,
+	// defaults to the SecretsManager region.
+	Region string
+
+	// Endpoint is an optional STS endpoint.
+	Endpoint string
 }
 
 // Connect returns a kv.Store that stores key-value pairs on AWS SecretsManager.
 func (s *AWSSecretsManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
-	return aws.Connect(ctx, &aws.Config{
+	c := &aws.Config{
 		Addr:     s.Endpoint,
 		Region:   s.Region,
 		KMSKeyID: s.KMSKey,
@@ -734,7 +1854,99 @@ func (s *AWSSecretsManagerKeyStore) Connect(ctx context.Context) (kes.KeyStore,
 			SecretKey:    s.SecretKey,
 			SessionToken: s.SessionToken,
 		},
-	})
+	}
+	if s.AssumeRole != nil {
+		c.AssumeRole = &aws.AssumeRole{
+			RoleARN:     s.AssumeRole.RoleARN,
+			ExternalID:  s.AssumeRole.ExternalID,
+			SessionName: s.AssumeRole.SessionName,
+			Region:      s.AssumeRole.Region,
+			Endpoint:    s.AssumeRole.Endpoint,
+		}
+	}
+	return aws.Connect(ctx, c)
+}
+
+// AWSKMSKeyStore is a structure containing the configuration
+// for AWS KMS. In contrast to AWSSecretsManagerKeyStore, it stores
+// no key material. Instead, each key is an AWS KMS key and AWS KMS
+// generates, encrypts and decrypts data keys.
+type AWSKMSKeyStore struct {
+	// Endpoint is the AWS KMS endpoint. AWS KMS endpoints
+	// have the following schema:
+	//  kms[-fips].<region>.amazonaws.com
+	Endpoint string
+
+	// Region is the AWS region of the KMS.
+	Region string
+
+	// AliasPrefix is an optional prefix of the KMS key
+	// aliases. The alias of a key is:
+	//  alias/<AliasPrefix><name>
+	AliasPrefix string
+
+	// DeletionWindow is the number of days after which AWS KMS
+	// deletes a deleted key. If zero, defaults to 30 days.
+	DeletionWindow int
+
+	// Grants are created on each new KMS key.
+	Grants []AWSKMSGrant
+
+	// AccessKey is the access key for authenticating to AWS.
+	AccessKey string
+
+	// SecretKey is the secret key for authenticating to AWS.
+	SecretKey string
+
+	// SessionToken is an optional session token for authenticating
+	// to AWS.
+	SessionToken string
+
+	// AssumeRole is an optional IAM role that is assumed to
+	// access AWS KMS.
+	AssumeRole *AWSAssumeRole
+}
+
+// AWSKMSGrant is a structure containing an AWS KMS grant.
+type AWSKMSGrant struct {
+	// GranteePrincipal is the ARN of the principal
+	// that is allowed to use the KMS key.
+	GranteePrincipal string
+
+	// Operations are the KMS operations granted to
+	// the principal, for example "Decrypt".
+	Operations []string
+}
+
+// Connect returns a kes.CryptoKeyStore that keeps keys at AWS KMS.
+func (s *AWSKMSKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	c := &aws.KMSConfig{
+		Addr:           s.Endpoint,
+		Region:         s.Region,
+		AliasPrefix:    s.AliasPrefix,
+		DeletionWindow: s.DeletionWindow,
+		Login: aws.Credentials{
+			AccessKey:    s.AccessKey,
+			SecretKey:    s.SecretKey,
+			SessionToken: s.SessionToken,
+		},
+	}
+	for _, grant := range s.Grants {
+		c.Grants = append(c.Grants, aws.Grant{
+			GranteePrincipal: grant.GranteePrincipal,
+			Operations:       grant.Operations,
+		})
+	}
+	if s.AssumeRole != nil {
+		c.AssumeRole = &aws.AssumeRole{
+			RoleARN:     s.AssumeRole.RoleARN,
+			ExternalID:  s.AssumeRole.ExternalID,
+			SessionName: s.AssumeRole.SessionName,
+			Region:      s.AssumeRole.Region,
+			Endpoint:    s.AssumeRole.Endpoint,
+		}
+	}
+	return aws.ConnectKMS(ctx, c)
 }
 
 // AzureKeyVaultKeyStore is a structure containing the
@@ -743,6 +1955,12 @@ type AzureKeyVaultKeyStore struct {
 	// Endpoint is the Azure KeyVault endpoint.
 	Endpoint string
 
+	// ManagedHSM indicates whether the endpoint is an
+	// Azure Managed HSM pool instead of a KeyVault. A
+	// Managed HSM keeps all keys within the HSM and
+	// cannot store secrets.
+	ManagedHSM bool
+
 	// TenantID is the ID of the Azure KeyVault tenant.
 	TenantID string
 
@@ -754,14 +1972,23 @@ type AzureKeyVaultKeyStore struct {
 	// Azure KeyVault.
 	ClientSecret string
 
+	// ManagedIdentity indicates whether to authenticate
+	// with an Azure managed identity. The access tokens
+	// are fetched from the Azure instance metadata service
+	// (IMDS) and refreshed automatically.
+	ManagedIdentity bool
+
 	// ManagedIdentityClientID is the client ID of the
 	// Azure managed identity that access the KeyVault.
+	// If empty, the system-assigned managed identity
+	// is used.
 	ManagedIdentityClientID string
 }
 
 // Connect returns a kv.Store that stores key-value pairs on Azure KeyVault.
 func (s *AzureKeyVaultKeyStore) Connect(_ context.Context) (kes.KeyStore, error) {
-	if (s.TenantID != "" || s.ClientID != "" || s.ClientSecret != "") && s.ManagedIdentityClientID != "" {
+	managedIdentity := s.ManagedIdentity || s.ManagedIdentityClientID != ""
+	if (s.TenantID != "" || s.ClientID != "" || s.ClientSecret != "") && managedIdentity {
 		return nil, errors.New("edge: failed to connect to Azure KeyVault: more than one authentication method specified")
 	}
 	var cred azcore.TokenCredential
@@ -769,16 +1996,21 @@ func (s *AzureKeyVaultKeyStore) Connect(_ context.Context) (kes.KeyStore, error)
 	switch {
 	case s.TenantID != "" || s.ClientID != "" || s.ClientSecret != "":
 		cred, err = azidentity.NewClientSecretCredential(s.TenantID, s.ClientID, s.ClientSecret, nil)
-	case s.ManagedIdentityClientID != "":
-		cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
-			ID: azidentity.ClientID(s.ManagedIdentityClientID),
-		})
+	case managedIdentity:
+		var options azidentity.ManagedIdentityCredentialOptions
+		if s.ManagedIdentityClientID != "" {
+			options.ID = azidentity.ClientID(s.ManagedIdentityClientID)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(&options)
 	default:
 		cred, err = azidentity.NewDefaultAzureCredential(nil)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("azure: failed to create default Azure credential: %v", err)
 	}
+	if s.ManagedHSM {
+		return azure.ConnectManagedHSM(s.Endpoint, cred)
+	}
 	return azure.ConnectWithCredentials(s.Endpoint, cred)
 }
 
@@ -831,6 +2063,46 @@ func (s *EntrustKeyControlKeyStore) Connect(ctx context.Context) (kes.KeyStore,
 	})
 }
 
+// KESKeyStore is a structure containing the configuration
+// for using the keys of another KES server.
+type KESKeyStore struct {
+	// Endpoints contains one or multiple KES server endpoints.
+	// For example: https://127.0.0.1:7373
+	Endpoints []string
+
+	// Enclave is an optional enclave of the KES server.
+	Enclave string
+
+	// Certificate is the path to the mTLS client
+	// certificate used to authenticate to the KES
+	// server.
+	Certificate string
+
+	// PrivateKey is the path to the private key of
+	// the mTLS client certificate.
+	PrivateKey string
+
+	// CAPath is an optional path to the root
+	// CA certificate(s) for verifying the TLS
+	// certificate of the KES server.
+	//
+	// If empty, the OS default root CA set is
+	// used.
+	CAPath string
+}
+
+// Connect returns a kes.CryptoKeyStore that uses the keys of
+// another KES server.
+func (s *KESKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	return kesks.Connect(ctx, &kesks.Config{
+		Endpoints:   s.Endpoints,
+		Enclave:     s.Enclave,
+		Certificate: s.Certificate,
+		PrivateKey:  s.PrivateKey,
+		CAPath:      s.CAPath,
+	})
+}
+
 // CredHubKeyStore is a structure containing the configuration for CredHub.
 type CredHubKeyStore struct {
 	Config *credhub.Config
@@ -840,3 +2112,165 @@ type CredHubKeyStore struct {
 func (s *CredHubKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
 	return credhub.NewStore(ctx, s.Config)
 }
+
+// SplitKeyStore is a structure containing the configuration
+// of a keystore that splits all keys across two independent
+// keystores. Both keystores are required to reconstruct a key.
+//
+// Neither keystore can be a kes.CryptoKeyStore, like AWS KMS,
+// since they have to store key shares.
+type SplitKeyStore struct {
+	// First is the keystore storing random key shares.
+	First KeyStore
+
+	// Second is the keystore storing the keys XOR'ed with the
+	// key shares of the first keystore.
+	Second KeyStore
+}
+
+// Connect connects to both keystores and returns a kes.KeyStore
+// that splits all keys across them.
+func (s *SplitKeyStore) Connect(ctx context.Context) (kes.KeyStore, error) {
+	first, err := s.First.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	second, err := s.Second.Connect(ctx)
+	if err != nil {
+		first.Close()
+		return nil, err
+	}
+	store, err := split.NewStore(first, second)
+	if err != nil {
+		first.Close()
+		second.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// RESTKeyStore is a structure containing the configuration
+// of a generic HTTP REST service storing keys. The requests
+// and the JSON format of the service are configurable.
+type RESTKeyStore struct {
+	// Endpoint is the base URL of the REST service.
+	Endpoint string
+
+	// Header contains HTTP headers sent with every
+	// request, e.g. an Authorization header.
+	Header http.Header
+
+	// Certificate is an optional path to an mTLS
+	// client certificate used to authenticate to
+	// the REST service.
+	Certificate string
+
+	// PrivateKey is the path to the private key of
+	// the mTLS client certificate.
+	PrivateKey string
+
+	// CAPath is an optional path to the root
+	// CA certificate(s) for verifying the TLS
+	// certificate of the REST service.
+	//
+	// If empty, the OS default root CA set is
+	// used.
+	CAPath string
+
+	// Create, Get, Update, Delete, List and Status
+	// describe the requests sent to the REST service.
+	// Update and Status are optional.
+	Create, Get, Update, Delete, List, Status rest.Operation
+}
+
+// Connect returns a kes.KeyStore that stores keys at the
+// REST service.
+func (s *RESTKeyStore) Connect(context.Context) (kes.KeyStore, error) {
+	return rest.NewStore(&rest.Config{
+		Endpoint:    s.Endpoint,
+		Header:      s.Header,
+		Certificate: s.Certificate,
+		PrivateKey:  s.PrivateKey,
+		CAPath:      s.CAPath,
+		Create:      s.Create,
+		Get:         s.Get,
+		Update:      s.Update,
+		Delete:      s.Delete,
+		List:        s.List,
+		Status:      s.Status,
+	})
+}
+
+// ExecKeyStore is a structure containing the configuration
+// of a keystore plugin. A plugin is an external program,
+// written in any language, that KES executes for every
+// keystore operation. See the plugin package for the
+// protocol.
+type ExecKeyStore struct {
+	// Path is the path to the plugin executable.
+	Path string
+
+	// Args are optional command line arguments
+	// passed to the plugin.
+	Args []string
+
+	// Env contains the environment variables of the
+	// plugin in the form "KEY=value". The plugin does
+	// not inherit the environment of the KES server.
+	Env []string
+
+	// Timeout is the maximum time a single plugin
+	// execution may take. If 0, the default of 15s
+	// is used.
+	Timeout time.Duration
+}
+
+// Connect returns a kes.KeyStore that delegates all
+// operations to the plugin.
+func (s *ExecKeyStore) Connect(context.Context) (kes.KeyStore, error) {
+	return plugin.NewStore(&plugin.Config{
+		Path:    s.Path,
+		Args:    s.Args,
+		Env:     s.Env,
+		Timeout: s.Timeout,
+	})
+}
+
+// WASMKeyStore is a structure containing the configuration
+// of a keystore plugin compiled to WebAssembly. KES executes
+// the WASM module within the sandbox of a WASI runtime for
+// every keystore operation. The module implements the same
+// protocol as plugins of the ExecKeyStore.
+type WASMKeyStore struct {
+	// Module is the path to the WASM module.
+	Module string
+
+	// Runtime is the path to the wasmtime executable.
+	// If empty, wasmtime is looked up in the PATH.
+	Runtime string
+
+	// Args are optional command line arguments
+	// passed to the module.
+	Args []string
+
+	// Env contains the environment variables of the
+	// module in the form "KEY=value".
+	Env []string
+
+	// Timeout is the maximum time a single plugin
+	// execution may take. If 0, the default of 15s
+	// is used.
+	Timeout time.Duration
+}
+
+// Connect returns a kes.KeyStore that delegates all
+// operations to the WASM module.
+func (s *WASMKeyStore) Connect(context.Context) (kes.KeyStore, error) {
+	return plugin.NewWASMStore(&plugin.WASMConfig{
+		Module:  s.Module,
+		Runtime: s.Runtime,
+		Args:    s.Args,
+		Env:     s.Env,
+		Timeout: s.Timeout,
+	})
+}
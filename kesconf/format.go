@@ -0,0 +1,154 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kesconf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// format is a config file format.
+type format int
+
+// Supported config file formats.
+const (
+	formatYAML format = iota
+	formatJSON
+	formatTOML
+)
+
+// formatOf returns the config file format of the file based
+// on its extension. Files with a ".json" extension are JSON
+// and files with a ".toml" extension are TOML. All other
+// files are YAML.
+func formatOf(filename string) format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return formatJSON
+	case ".toml":
+		return formatTOML
+	default:
+		return formatYAML
+	}
+}
+
+// decodeNode parses the config document read from r in the given
+// format and returns it as YAML document node. Hence, JSON and TOML
+// config files share the YAML config model. For example, they can
+// reference env. variables as "${VAR}" as well. However, they do
+// not support YAML tags, like EncryptedTag.
+func decodeNode(r io.Reader, f format) (*yaml.Node, error) {
+	var root *yaml.Node
+	switch f {
+	case formatJSON:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("kesconf: invalid JSON config: %v", err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, errors.New("kesconf: invalid JSON config: unexpected data after top-level value")
+		}
+		node, err := valueToNode(v)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid JSON config: %v", err)
+		}
+		root = node
+	case formatTOML:
+		var v map[string]any
+		if _, err := toml.NewDecoder(r).Decode(&v); err != nil {
+			return nil, fmt.Errorf("kesconf: invalid TOML config: %v", err)
+		}
+		node, err := valueToNode(v)
+		if err != nil {
+			return nil, fmt.Errorf("kesconf: invalid TOML config: %v", err)
+		}
+		root = node
+	default:
+		var node yaml.Node
+		if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+			return nil, err
+		}
+		return &node, nil
+	}
+	return &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{root},
+	}, nil
+}
+
+// valueToNode converts a JSON or TOML value to a YAML node.
+// Object keys are sorted to produce a deterministic document.
+func valueToNode(v any) (*yaml.Node, error) {
+	switch v := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.String()}, nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.String()}, nil
+	case int64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v.Format(time.RFC3339Nano)}, nil
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, elem := range v {
+			n, err := valueToNode(elem)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
+		}
+		return node, nil
+	case []map[string]any: // TOML array of tables
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, elem := range v {
+			n, err := valueToNode(elem)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
+		}
+		return node, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			value, err := valueToNode(v[key])
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type '%T'", v)
+	}
+}
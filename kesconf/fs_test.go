@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/minio/kes/kesconf"
+	"github.com/minio/kes/keystoretest"
 )
 
 var FSPath = flag.String("fs.path", "", "Path used for FS tests")
@@ -21,15 +22,5 @@ func TestFS(t *testing.T) {
 		Path: *FSPath,
 	}
 
-	ctx, cancel := testingContext(t)
-	defer cancel()
-
-	store, err := config.Connect(ctx)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	t.Run("Create", func(t *testing.T) { testCreate(ctx, store, t, RandString(ranStringLength)) })
-	t.Run("Get", func(t *testing.T) { testGet(ctx, store, t, RandString(ranStringLength)) })
-	t.Run("Status", func(t *testing.T) { testStatus(ctx, store, t) })
+	keystoretest.TestKeyStore(t, config.Connect)
 }
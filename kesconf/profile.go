@@ -0,0 +1,97 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kesconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvProfile is the env. variable selecting the active config
+// profile. For example, KES_PROFILE=prod applies the "prod"
+// profile of the config file:
+//
+//	keystore:
+//	  fs:
+//	    path: ./keys
+//	profiles:
+//	  prod:
+//	    keystore:
+//	      fs:
+//	        path: /var/lib/kes/keys
+//
+// The values of the active profile override the values of the
+// config file. Nested objects are merged while all other values,
+// including lists, are replaced. If no profile is selected, the
+// profiles section is ignored.
+const EnvProfile = "KES_PROFILE"
+
+// profilesKey is the top-level key of the profiles section.
+const profilesKey = "profiles"
+
+// applyProfile merges the profile selected by the EnvProfile
+// env. variable into the given YAML document root. It returns
+// an error if the profile is selected but not defined.
+func applyProfile(root *yaml.Node) error {
+	name := strings.TrimSpace(os.Getenv(EnvProfile))
+	if name == "" {
+		return nil
+	}
+	if len(root.Content) != 1 || root.Content[0].Kind != yaml.MappingNode {
+		return errors.New("kesconf: invalid config format")
+	}
+	doc := root.Content[0]
+
+	profiles := mappingValue(doc, profilesKey)
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return fmt.Errorf("kesconf: profile '%s' not found: no profiles specified", name)
+	}
+	profile := mappingValue(profiles, name)
+	if profile == nil {
+		return fmt.Errorf("kesconf: profile '%s' not found", name)
+	}
+	if profile.Kind != yaml.MappingNode {
+		return fmt.Errorf("kesconf: invalid profile '%s' at line '%d'", name, profile.Line)
+	}
+	for _, key := range []string{"version", profilesKey} {
+		if mappingValue(profile, key) != nil {
+			return fmt.Errorf("kesconf: invalid profile '%s': '%s' cannot be overridden", name, key)
+		}
+	}
+	mergeNode(doc, profile)
+	return nil
+}
+
+// mergeNode merges src into dst. If both are YAML mappings, it
+// merges their entries recursively. Otherwise, src replaces dst.
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if v := mappingValue(dst, key.Value); v != nil {
+			mergeNode(v, value)
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+// mappingValue returns the value of the key within the YAML
+// mapping node, or nil if no such key exists.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
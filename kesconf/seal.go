@@ -0,0 +1,390 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kesconf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/shamir"
+	"gopkg.in/yaml.v3"
+)
+
+// SealConfig is a structure that holds the seal configuration
+// of a KES server.
+//
+// A sealed KES server does not decrypt the encrypted values of
+// its config file on startup. Instead, it waits until operators
+// have submitted at least Threshold unseal key shares via the
+// unseal API. The shares are combined to the root key that
+// replaces the EnvConfigPassword. See RootKeyPassword.
+//
+// Alternatively, the root key can be wrapped by an external KMS.
+// Then, the KES server unseals itself on startup. See Auto.
+type SealConfig struct {
+	// Threshold is the number of unseal key shares required
+	// to reconstruct the root key. It may be 0 if Auto is set.
+	// Then, the server cannot be unsealed with unseal key
+	// shares.
+	Threshold int
+
+	// KeyCheck is used to verify that the root key reconstructed
+	// from the submitted shares is correct. It does not reveal
+	// anything about the root key.
+	KeyCheck []byte
+
+	// ShareChecks are used to verify each submitted unseal key
+	// share before the KES server keeps it. Each one belongs to
+	// one share and does not reveal anything about it. If empty,
+	// invalid shares are only detected once enough shares have
+	// been submitted.
+	ShareChecks [][]byte
+
+	// Operators are the identities, besides the admin, that may
+	// submit unseal key shares.
+	Operators []kes.Identity
+
+	// Auto, if set, makes the KES server unseal itself on
+	// startup by unwrapping the root key with an external KMS.
+	// If unwrapping fails, the server waits for unseal key
+	// shares, unless Threshold is 0.
+	Auto *AutoUnsealConfig
+}
+
+// AutoUnsealConfig is a structure that holds the configuration
+// for unsealing a KES server with an external KMS.
+//
+// The root key is wrapped, i.e. encrypted, by a key of the KMS.
+// Hence, only KES servers with access to the KMS key can obtain
+// the root key.
+type AutoUnsealConfig struct {
+	// KMS is the keystore holding the KMS key. It must connect
+	// to a kes.CryptoKeyStore, like AWS KMS, GCP Cloud KMS, an
+	// Azure managed HSM, Vault transit or another KES server.
+	KMS KeyStore
+
+	// Key is the name of the KMS key.
+	Key string
+
+	// Ciphertext is the root key wrapped by the KMS key.
+	Ciphertext []byte
+}
+
+// autoUnsealContext is the associated data used when wrapping
+// the root key.
+const autoUnsealContext = "kes root key"
+
+// WrapRootKey wraps the root key with the KMS key and returns
+// the ciphertext.
+func (c *AutoUnsealConfig) WrapRootKey(ctx context.Context, rootKey []byte) ([]byte, error) {
+	kms, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer kms.Close()
+
+	ciphertext, err := kms.Encrypt(ctx, c.Key, rootKey, []byte(autoUnsealContext))
+	if err != nil {
+		return nil, fmt.Errorf("kesconf: failed to wrap root key with '%s': %v", c.Key, err)
+	}
+	return ciphertext, nil
+}
+
+// UnwrapRootKey unwraps the Ciphertext with the KMS key and
+// returns the root key.
+func (c *AutoUnsealConfig) UnwrapRootKey(ctx context.Context) ([]byte, error) {
+	kms, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer kms.Close()
+
+	rootKey, err := kms.Decrypt(ctx, c.Key, c.Ciphertext, []byte(autoUnsealContext))
+	if err != nil {
+		return nil, fmt.Errorf("kesconf: failed to unwrap root key with '%s': %v", c.Key, err)
+	}
+	return rootKey, nil
+}
+
+// connect connects to the KMS. It fails if the KMS is not
+// a kes.CryptoKeyStore.
+func (c *AutoUnsealConfig) connect(ctx context.Context) (kes.CryptoKeyStore, error) {
+	store, err := c.KMS.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kms, ok := store.(kes.CryptoKeyStore)
+	if !ok {
+		store.Close()
+		return nil, fmt.Errorf("kesconf: keystore '%v' cannot wrap the root key", store)
+	}
+	return kms, nil
+}
+
+// sealKeyCheckLabel is the message authenticated with the root
+// key to compute the KeyCheck of a SealConfig.
+const sealKeyCheckLabel = "kes unseal key check"
+
+// NewSealConfig returns a new SealConfig for the given root key
+// that requires threshold unseal key shares.
+func NewSealConfig(rootKey []byte, threshold int) *SealConfig {
+	mac := hmac.New(sha256.New, rootKey)
+	mac.Write([]byte(sealKeyCheckLabel))
+	return &SealConfig{
+		Threshold: threshold,
+		KeyCheck:  mac.Sum(nil),
+	}
+}
+
+// Verify returns kes.ErrInvalidUnsealKey if the root key does
+// not match the KeyCheck.
+func (c *SealConfig) Verify(rootKey []byte) error {
+	if !hmac.Equal(NewSealConfig(rootKey, c.Threshold).KeyCheck, c.KeyCheck) {
+		return kes.ErrInvalidUnsealKey
+	}
+	return nil
+}
+
+// sealShareCheckLabel is the message authenticated with an unseal
+// key share to compute its share check.
+const sealShareCheckLabel = "kes unseal key share check"
+
+// ShareCheck returns the share check of the unseal key share. See
+// SealConfig.ShareChecks.
+func ShareCheck(share []byte) []byte {
+	mac := hmac.New(sha256.New, share)
+	mac.Write([]byte(sealShareCheckLabel))
+	return mac.Sum(nil)
+}
+
+// VerifyShare returns kes.ErrInvalidUnsealKey if the unseal key
+// share does not match any of the ShareChecks. It accepts any
+// share if no ShareChecks are present.
+func (c *SealConfig) VerifyShare(share []byte) error {
+	if len(c.ShareChecks) == 0 {
+		return nil
+	}
+	check := ShareCheck(share)
+	for _, c := range c.ShareChecks {
+		if hmac.Equal(c, check) {
+			return nil
+		}
+	}
+	return kes.ErrInvalidUnsealKey
+}
+
+// RootKeyPassword returns the password that decrypts the encrypted
+// values of the config file of a sealed KES server. Values encrypted
+// with this password, e.g. by setting the EnvConfigPassword to it,
+// can only be decrypted once the server has been unsealed.
+func RootKeyPassword(rootKey []byte) string { return hex.EncodeToString(rootKey) }
+
+// sealedSections are the top-level sections of the config file a
+// sealed KES server reads before it gets unsealed. They must not
+// contain encrypted values.
+var sealedSections = []string{"version", "address", "fips", "admin", "tls", "api", "seal"}
+
+// ReadSealedFile reads the parts of the KES configuration from the
+// given file that a sealed KES server requires before it gets
+// unsealed, i.e. the address, admin, TLS and seal configuration. It
+// overrides them with the values of the corresponding env. variables,
+// like ReadFileWithEnv.
+//
+// It returns nil and no error if the configuration does not seal
+// the KES server. Otherwise, the server has to read the entire
+// configuration once it has been unsealed.
+func ReadSealedFile(filename string) (*File, error) {
+	node, err := readNode(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only decode the seal section first. The other sections
+	// may contain encrypted values if the server is not sealed.
+	y, err := decodeSections(node, "seal")
+	if err != nil {
+		return nil, err
+	}
+	seal, err := ymlToSealConfig(y)
+	if err != nil || seal == nil {
+		return nil, err
+	}
+
+	if y, err = decodeSections(node, sealedSections...); err != nil {
+		return nil, err
+	}
+	if y.Admin.Identity.Value.IsUnknown() {
+		return nil, errors.New("kesconf: invalid admin identity: no admin identity")
+	}
+	tlsConfig, err := ymlToTLSConfig(y)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Addr:  y.Addr.Value,
+		Admin: y.Admin.Identity.Value,
+		FIPS:  y.FIPS.Value,
+		TLS:   tlsConfig,
+		Seal:  seal,
+	}, nil
+}
+
+// ReadAutoUnsealConfig reads the auto unseal configuration from
+// the seal section of the given file. Unlike ReadSealedFile, it
+// does not require a wrapped root key or a key check. Hence, it
+// can be used to wrap a new root key.
+func ReadAutoUnsealConfig(filename string) (*AutoUnsealConfig, error) {
+	node, err := readNode(filename)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeSections(node, "seal")
+	if err != nil {
+		return nil, err
+	}
+	if y.Seal.Auto == nil {
+		return nil, errors.New("kesconf: no auto unseal config specified")
+	}
+	return ymlToAutoUnsealConfig(y, false)
+}
+
+// readNode reads the config document from the given file and
+// checks its config version. It detects the config format by
+// the file extension, like ReadFile, and applies the active
+// profile, if any.
+func readNode(filename string) (*yaml.Node, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	node, err := decodeNode(f, formatOf(filename))
+	if err != nil {
+		return nil, err
+	}
+	version, err := findVersion(node)
+	if err != nil {
+		return nil, err
+	}
+	if version != "" && version != "v1" {
+		return nil, fmt.Errorf("kesconf: invalid config version '%s'", version)
+	}
+	if err = applyProfile(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// decodeSections decodes the given top-level sections of the YAML
+// document root and applies the env. variables to them.
+func decodeSections(root *yaml.Node, sections ...string) (*ymlFile, error) {
+	var y ymlFile
+	if len(root.Content) == 1 && root.Content[0].Kind == yaml.MappingNode {
+		doc := *root.Content[0]
+		doc.Content = nil
+		for i := 0; i+1 < len(root.Content[0].Content); i += 2 {
+			key, value := root.Content[0].Content[i], root.Content[0].Content[i+1]
+			if slices.Contains(sections, key.Value) {
+				doc.Content = append(doc.Content, key, value)
+			}
+		}
+		if err := doc.Decode(&y); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := applyEnv(&y, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return &y, nil
+}
+
+// ymlToSealConfig returns the seal config of the KES server or
+// nil if the server is not sealed.
+func ymlToSealConfig(y *ymlFile) (*SealConfig, error) {
+	if y.Seal.Threshold.Value == 0 && y.Seal.KeyCheck.Value == "" && y.Seal.Auto == nil {
+		return nil, nil
+	}
+	if threshold := y.Seal.Threshold.Value; threshold != 0 || y.Seal.Auto == nil {
+		if threshold < 2 || threshold > shamir.MaxShares {
+			return nil, fmt.Errorf("kesconf: invalid seal threshold '%d'", threshold)
+		}
+	}
+	keyCheck, err := hex.DecodeString(y.Seal.KeyCheck.Value)
+	if err != nil || len(keyCheck) != sha256.Size {
+		return nil, errors.New("kesconf: invalid seal config: invalid 'key_check'")
+	}
+
+	shareChecks := make([][]byte, 0, len(y.Seal.ShareChecks))
+	for _, v := range y.Seal.ShareChecks {
+		check, err := hex.DecodeString(v.Value)
+		if err != nil || len(check) != sha256.Size {
+			return nil, errors.New("kesconf: invalid seal config: invalid 'share_checks'")
+		}
+		shareChecks = append(shareChecks, check)
+	}
+	operators := make([]kes.Identity, 0, len(y.Seal.Operators))
+	for _, v := range y.Seal.Operators {
+		if v.Value.IsUnknown() {
+			return nil, errors.New("kesconf: invalid seal config: empty operator identity")
+		}
+		operators = append(operators, v.Value)
+	}
+
+	var auto *AutoUnsealConfig
+	if y.Seal.Auto != nil {
+		if auto, err = ymlToAutoUnsealConfig(y, true); err != nil {
+			return nil, err
+		}
+	}
+	return &SealConfig{
+		Threshold:   y.Seal.Threshold.Value,
+		KeyCheck:    keyCheck,
+		ShareChecks: shareChecks,
+		Operators:   operators,
+		Auto:        auto,
+	}, nil
+}
+
+// ymlToAutoUnsealConfig returns the auto unseal config of the KES
+// server. If requireCiphertext is false, the wrapped root key may
+// be empty.
+func ymlToAutoUnsealConfig(y *ymlFile, requireCiphertext bool) (*AutoUnsealConfig, error) {
+	if y.Seal.Auto.Key.Value == "" {
+		return nil, errors.New("kesconf: invalid auto unseal config: no key specified")
+	}
+	if y.Seal.Auto.KMS.Kind == 0 {
+		return nil, errors.New("kesconf: invalid auto unseal config: no kms specified")
+	}
+	if requireCiphertext && y.Seal.Auto.Ciphertext.Value == "" {
+		return nil, errors.New("kesconf: invalid auto unseal config: no ciphertext specified")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(y.Seal.Auto.Ciphertext.Value)
+	if err != nil {
+		return nil, errors.New("kesconf: invalid auto unseal config: invalid ciphertext")
+	}
+
+	var ks ymlFile
+	if err = y.Seal.Auto.KMS.Decode(&ks.KeyStore); err != nil {
+		return nil, err
+	}
+	kms, err := ymlToKeyStore(&ks)
+	if err != nil {
+		return nil, fmt.Errorf("%v in auto unseal kms", err)
+	}
+	return &AutoUnsealConfig{
+		KMS:        kms,
+		Key:        y.Seal.Auto.Key.Value,
+		Ciphertext: ciphertext,
+	}, nil
+}
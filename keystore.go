@@ -5,17 +5,25 @@
 package kes
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"net/http"
 	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/minio/kes/internal/api"
 	"github.com/minio/kes/internal/cache"
 	"github.com/minio/kes/internal/crypto"
 	"github.com/minio/kes/internal/keystore"
+	"github.com/minio/kes/internal/secmem"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -33,6 +41,9 @@ type KeyStore interface {
 	// Create creates a new entry with the given name if and only
 	// if no such entry exists.
 	// Otherwise, Create returns kes.ErrKeyExists.
+	//
+	// Create must not retain the value once it returns. The caller
+	// may zero the value afterwards.
 	Create(ctx context.Context, name string, value []byte) error
 
 	// Delete removes the entry. It may return either no error or
@@ -41,6 +52,9 @@ type KeyStore interface {
 
 	// Get returns the value for the given name. It returns
 	// kes.ErrKeyNotFound if no such entry exits.
+	//
+	// The returned value is owned by the caller, which may zero
+	// it once it has been parsed.
 	Get(ctx context.Context, name string) ([]byte, error)
 
 	// List returns the first n key names, that start with the given
@@ -56,10 +70,371 @@ type KeyStore interface {
 	List(ctx context.Context, prefix string, n int) ([]string, string, error)
 }
 
+// A MutableKeyStore is a KeyStore that can replace the value
+// of existing entries. For example, a KES server replaces a
+// key when rotating it.
+//
+// Implementing MutableKeyStore is optional. Keys stored on a
+// KeyStore that does not implement it cannot be rotated.
+type MutableKeyStore interface {
+	KeyStore
+
+	// Set replaces the value of an existing entry with the given
+	// name. It returns kes.ErrKeyNotFound if no such entry exists.
+	Set(ctx context.Context, name string, value []byte) error
+}
+
+// A ConditionalKeyStore is a MutableKeyStore that can replace
+// the value of an entry atomically if and only if the entry has
+// not been modified concurrently. A KES server uses it to avoid
+// lost updates when multiple servers rotate the same key.
+//
+// Implementing ConditionalKeyStore is optional. Entries stored
+// on a MutableKeyStore that does not implement it are replaced
+// unconditionally.
+type ConditionalKeyStore interface {
+	MutableKeyStore
+
+	// SetIf replaces the value of an existing entry with the given
+	// name if and only if its current value is equal to old. It
+	// returns ErrConflict if the current value differs and
+	// kes.ErrKeyNotFound if no such entry exists.
+	//
+	// Implementations may compare versions or hashes of the values
+	// instead of the values themselves.
+	SetIf(ctx context.Context, name string, value, old []byte) error
+}
+
+// ErrConflict is returned by a ConditionalKeyStore if an entry
+// has been modified concurrently.
+var ErrConflict = errors.New("kes: entry has been modified concurrently")
+
+// setIf replaces the value of the entry if its current value is
+// equal to old. It replaces the value unconditionally if the
+// KeyStore does not implement ConditionalKeyStore.
+func setIf(ctx context.Context, store MutableKeyStore, name string, value, old []byte) error {
+	if s, ok := store.(ConditionalKeyStore); ok {
+		return s.SetIf(ctx, name, value, old)
+	}
+	return store.Set(ctx, name, value)
+}
+
+// A MetadataKeyStore is a KeyStore that stores metadata, like the
+// key algorithm or creation time, alongside the entries. A KES
+// server uses it to describe keys without fetching and decoding
+// their key material.
+//
+// Implementing MetadataKeyStore is optional. Keys stored on a
+// KeyStore that does not implement it are described by decoding
+// the stored key.
+type MetadataKeyStore interface {
+	KeyStore
+
+	// Metadata returns the metadata of the entry with the given
+	// name. It returns an empty EntryMetadata if no metadata is
+	// stored for the entry.
+	Metadata(ctx context.Context, name string) (EntryMetadata, error)
+
+	// SetMetadata stores the metadata of an existing entry and
+	// replaces any previous metadata. It returns kes.ErrKeyNotFound
+	// if no such entry exists. Deleting an entry also deletes its
+	// metadata.
+	SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error
+}
+
+// EntryMetadata is the metadata of a key stored at a
+// MetadataKeyStore.
+type EntryMetadata struct {
+	Algorithm string       // Algorithm of the latest key version
+	CreatedAt time.Time    // Creation time of the first key version
+	CreatedBy kes.Identity // Identity that created the first key version
+	Versions  int          // Number of key versions
+	RotatedAt time.Time    // Creation time of the latest key version, if rotated
+
+	Uses       uint64    // Number of operations performed with the key
+	LastUsedAt time.Time // Time of the most recent operation, if used
+
+	Tags       map[string]string // Key-value pairs attached to the key, if any
+	Protected  bool              // Whether the key is protected against deletion
+	Operations []string          // Operations the key may be used for. If empty, all operations
+
+	Grants map[kes.Identity][]string // Operations granted to identities on the key, if any
+}
+
+// IsEmpty reports whether the EntryMetadata is empty.
+func (m *EntryMetadata) IsEmpty() bool { return m.Versions == 0 }
+
+// metadataOf returns the EntryMetadata of the key.
+func metadataOf(key *crypto.Key) EntryMetadata {
+	latest := key.Latest()
+	m := EntryMetadata{
+		Algorithm: latest.Key.Type().String(),
+		CreatedAt: key.Versions[0].CreatedAt,
+		CreatedBy: key.Versions[0].CreatedBy,
+		Versions:  len(key.Versions),
+	}
+	if len(key.Versions) > 1 {
+		m.RotatedAt = latest.CreatedAt
+	}
+	return m
+}
+
+// An ExpiringKeyStore is a KeyStore that can create entries that
+// get deleted automatically once their time-to-live has elapsed.
+// A KES server uses it to clean up short-lived secrets.
+//
+// Implementing ExpiringKeyStore is optional. Entries with a TTL
+// stored on a KeyStore that does not implement it are ignored
+// once expired and deleted periodically by the KES server.
+type ExpiringKeyStore interface {
+	KeyStore
+
+	// CreateWithTTL creates a new entry with the given name if and
+	// only if no such entry exists. The entry gets deleted once the
+	// ttl has elapsed. Otherwise, CreateWithTTL behaves like Create.
+	CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error
+}
+
+// createWithTTL creates a new entry that expires after the given
+// ttl. If the KeyStore does not implement ExpiringKeyStore, the
+// entry is created without a TTL.
+func createWithTTL(ctx context.Context, store KeyStore, name string, value []byte, ttl time.Duration) error {
+	if s, ok := store.(ExpiringKeyStore); ok {
+		return s.CreateWithTTL(ctx, name, value, ttl)
+	}
+	return store.Create(ctx, name, value)
+}
+
+// expiringKeyStore reports whether the KeyStore deletes expired
+// entries on its own. The KeyStore of an enclave does if the
+// KeyStore shared by all enclaves does.
+func expiringKeyStore(store KeyStore) bool {
+	_, ok := baseKeyStore(store).(ExpiringKeyStore)
+	return ok
+}
+
+// A PaginatedKeyStore is a KeyStore that can continue a listing
+// at a given entry name. A KES server uses it to list keys page
+// by page instead of fetching all key names at once.
+//
+// Implementing PaginatedKeyStore is optional. Listings of a
+// KeyStore that does not implement it are paginated in memory.
+type PaginatedKeyStore interface {
+	KeyStore
+
+	// ListFrom returns the first n key names, that start with the
+	// given prefix and are lexicographically greater than or equal
+	// to continueAt, and the name at which the listing should
+	// continue. An empty continueAt starts at the first name.
+	//
+	// Otherwise, ListFrom behaves like List.
+	ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error)
+}
+
+// listFrom lists the key names of the KeyStore via ListFrom. If
+// the KeyStore does not implement PaginatedKeyStore, it lists all
+// names with the prefix and paginates them in memory.
+func listFrom(ctx context.Context, store KeyStore, prefix, continueAt string, n int) ([]string, string, error) {
+	if s, ok := store.(PaginatedKeyStore); ok {
+		return s.ListFrom(ctx, prefix, continueAt, n)
+	}
+	if continueAt == "" {
+		return store.List(ctx, prefix, n)
+	}
+
+	names, _, err := store.List(ctx, prefix, -1)
+	if err != nil {
+		return nil, "", err
+	}
+	return keystore.ListFrom(names, prefix, continueAt, n)
+}
+
+// A WatchableKeyStore is a KeyStore that reports changes of its
+// entries, including changes made out-of-band by other clients,
+// like other KES servers sharing the same KeyStore.
+//
+// Implementing WatchableKeyStore is optional. A KES server uses it
+// to evict cached keys as soon as they get replaced or deleted,
+// instead of waiting for the cache entries to expire.
+type WatchableKeyStore interface {
+	KeyStore
+
+	// Watch returns a channel that receives an event whenever an
+	// entry, whose name starts with the given prefix, is created,
+	// replaced or deleted. An empty prefix matches any entry.
+	//
+	// The channel is closed once the context is canceled. It may
+	// also be closed earlier, for example when the connection to
+	// the KeyStore breaks. Events may get dropped if the receiver
+	// does not keep up.
+	Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error)
+}
+
+// KeyStoreEventType is the type of a KeyStoreEvent.
+type KeyStoreEventType uint
+
+// All valid KeyStoreEventTypes.
+const (
+	EntryCreated KeyStoreEventType = iota + 1
+	EntryUpdated
+	EntryDeleted
+)
+
+// String returns the string representation of the event type.
+func (t KeyStoreEventType) String() string {
+	switch t {
+	case EntryCreated:
+		return "created"
+	case EntryUpdated:
+		return "updated"
+	case EntryDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyStoreEvent describes a change of a KeyStore entry.
+type KeyStoreEvent struct {
+	Type KeyStoreEventType
+	Name string
+}
+
+// A CryptoKeyStore is a KeyStore that never reveals its keys.
+// Instead, it creates keys and encrypts and decrypts data itself,
+// for example within an HSM or an external KMS.
+//
+// Implementing CryptoKeyStore is optional. A KES server does not
+// generate, cache or export keys stored at a CryptoKeyStore. It
+// only proxies create, generate, encrypt and decrypt requests.
+// Operations that require key material, like importing, exporting,
+// rotating or computing HMACs, fail with HTTP 501.
+//
+// The KeyStore methods Delete and List operate on the keys of
+// the CryptoKeyStore. Create and Get may not be supported.
+type CryptoKeyStore interface {
+	KeyStore
+
+	// CreateKey creates a new key with the given name if and
+	// only if no such key exists. Otherwise, CreateKey returns
+	// kes.ErrKeyExists.
+	CreateKey(ctx context.Context, name string) error
+
+	// Encrypt encrypts and authenticates the plaintext and
+	// associated data with the key with the given name. It
+	// returns kes.ErrKeyNotFound if no such key exists.
+	Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error)
+
+	// Decrypt decrypts and verifies the ciphertext and associated
+	// data with the key with the given name. It returns
+	// kes.ErrKeyNotFound if no such key exists.
+	Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error)
+}
+
+// A DataKeyStore is a CryptoKeyStore that generates data keys
+// itself, for example to use a certified random number generator.
+//
+// Implementing DataKeyStore is optional. Otherwise, a KES server
+// generates data keys and encrypts them with the CryptoKeyStore.
+type DataKeyStore interface {
+	CryptoKeyStore
+
+	// GenerateKey generates a new 256 bit data key and encrypts it
+	// with the key with the given name. It returns the plaintext and
+	// the ciphertext of the data key, or kes.ErrKeyNotFound if no
+	// such key exists.
+	GenerateKey(ctx context.Context, name string, associatedData []byte) (plaintext, ciphertext []byte, err error)
+}
+
+// generateKey generates a data key with the DataKeyStore. If the store
+// does not implement DataKeyStore, it generates a random data key and
+// encrypts it with the CryptoKeyStore.
+func generateKey(ctx context.Context, store CryptoKeyStore, name string, associatedData []byte) (plaintext, ciphertext []byte, err error) {
+	if s, ok := store.(DataKeyStore); ok {
+		return s.GenerateKey(ctx, name, associatedData)
+	}
+
+	plaintext = make([]byte, 32)
+	if _, err = rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+	if ciphertext, err = store.Encrypt(ctx, name, plaintext, associatedData); err != nil {
+		return nil, nil, err
+	}
+	return plaintext, ciphertext, nil
+}
+
+// A PurgeableKeyStore is a KeyStore that deletes many entries at
+// once, for example all entries of a tenant that gets decommissioned.
+//
+// Implementing PurgeableKeyStore is optional. A KES server rejects
+// purge requests if its KeyStore does not implement it.
+type PurgeableKeyStore interface {
+	KeyStore
+
+	// Purge deletes all entries whose names match one of the
+	// path.Match patterns. No patterns match any entry except entries
+	// used internally by the KES server or the KeyStore, which start
+	// with a hyphen. Internal entries only match explicit patterns.
+	//
+	// It returns the sorted names of the deleted entries. If dryRun
+	// is true, Purge only returns the names of the matching entries
+	// without deleting them.
+	Purge(ctx context.Context, patterns []string, dryRun bool) ([]string, error)
+}
+
+// cryptoKeyStore returns the KeyStore as CryptoKeyStore, or nil if it
+// does not implement CryptoKeyStore. The KeyStore of an enclave is a
+// CryptoKeyStore if the KeyStore shared by all enclaves is one.
+func cryptoKeyStore(store KeyStore) CryptoKeyStore {
+	if _, ok := baseKeyStore(store).(CryptoKeyStore); !ok {
+		return nil
+	}
+	s, _ := store.(CryptoKeyStore)
+	return s
+}
+
+// baseKeyStore returns the KeyStore that actually stores the
+// entries of store. It unwraps the KeyStores of enclaves and
+// KeyStores that log slow operations or apply timeouts since
+// they implement all optional KeyStore interfaces.
+func baseKeyStore(store KeyStore) KeyStore {
+	for {
+		switch s := store.(type) {
+		case *enclaveKeyStore:
+			store = s.store
+		case *watchableEnclaveKeyStore:
+			store = s.store
+		case *slowLogKeyStore:
+			store = s.store
+		case *watchableSlowLogKeyStore:
+			store = s.store
+		case *faultKeyStore:
+			store = s.store
+		case *watchableFaultKeyStore:
+			store = s.store
+		case *timeoutKeyStore:
+			store = s.store
+		case *watchableTimeoutKeyStore:
+			store = s.store
+		case *cryptoTimeoutKeyStore:
+			store = s.store
+		case *watchableCryptoTimeoutKeyStore:
+			store = s.store
+		default:
+			return store
+		}
+	}
+}
+
 // KeyStoreState is a structure containing information about
 // the current state of a KeyStore.
 type KeyStoreState struct {
 	Latency time.Duration
+
+	// Version is the version of the KeyStore backend, like
+	// the Vault server version. It is empty if unknown.
+	Version string
 }
 
 // MemKeyStore is a volatile KeyStore that stores key-value pairs in
@@ -67,10 +442,28 @@ type KeyStoreState struct {
 // from different go routines. It is optimized for reads but not
 // well-suited for many writes/deletes.
 type MemKeyStore struct {
-	keys cache.Cow[string, []byte]
+	keys     cache.Cow[string, []byte]
+	metadata cache.Cow[string, EntryMetadata]
+
+	mu       sync.Mutex
+	watchers []*memWatcher
+	timers   map[string]*time.Timer // Expiry timers of entries with a TTL
 }
 
-var _ KeyStore = (*MemKeyStore)(nil) // compiler check
+// memWatcher is a MemKeyStore subscriber receiving
+// events for entries starting with its prefix.
+type memWatcher struct {
+	prefix string
+	events chan KeyStoreEvent
+}
+
+var ( // compiler checks
+	_ ConditionalKeyStore = (*MemKeyStore)(nil)
+	_ ExpiringKeyStore    = (*MemKeyStore)(nil)
+	_ MetadataKeyStore    = (*MemKeyStore)(nil)
+	_ PaginatedKeyStore   = (*MemKeyStore)(nil)
+	_ WatchableKeyStore   = (*MemKeyStore)(nil)
+)
 
 func (ks *MemKeyStore) String() string { return "In Memory" }
 
@@ -89,15 +482,92 @@ func (ks *MemKeyStore) Create(_ context.Context, name string, value []byte) erro
 	if !ks.keys.Add(name, slices.Clone(value)) {
 		return kes.ErrKeyExists
 	}
+	ks.notify(EntryCreated, name)
+	return nil
+}
+
+// CreateWithTTL creates a new entry with the given name if and
+// only if no such entry exists. The entry is deleted once the
+// ttl has elapsed. Otherwise, CreateWithTTL returns
+// kes.ErrKeyExists.
+func (ks *MemKeyStore) CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error {
+	if err := ks.Create(ctx, name, value); err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		ks.mu.Lock()
+		expired := ks.timers[name] == timer
+		if expired {
+			delete(ks.timers, name)
+		}
+		ks.mu.Unlock()
+
+		// The entry may have been deleted, and maybe re-created,
+		// in the meantime. Then, the timer has been replaced or
+		// removed.
+		if expired {
+			ks.metadata.Delete(name)
+		}
+		if expired && ks.keys.Delete(name) {
+			ks.notify(EntryDeleted, name)
+		}
+	})
+	if ks.timers == nil {
+		ks.timers = map[string]*time.Timer{}
+	}
+	ks.timers[name] = timer
+	return nil
+}
+
+// Set replaces the value of an existing entry with the given
+// name. It returns kes.ErrKeyNotFound if no such entry exists.
+func (ks *MemKeyStore) Set(_ context.Context, name string, value []byte) error {
+	if !ks.keys.Replace(name, slices.Clone(value)) {
+		return kes.ErrKeyNotFound
+	}
+	ks.notify(EntryUpdated, name)
+	return nil
+}
+
+// SetIf replaces the value of an existing entry with the given
+// name if and only if its current value is equal to old. It
+// returns ErrConflict if the current value differs and
+// kes.ErrKeyNotFound if no such entry exists.
+func (ks *MemKeyStore) SetIf(_ context.Context, name string, value, old []byte) error {
+	value = slices.Clone(value)
+	replaced := ks.keys.ReplaceFunc(name, func(v []byte) ([]byte, bool) {
+		return value, bytes.Equal(v, old)
+	})
+	if !replaced {
+		if _, ok := ks.keys.Get(name); !ok {
+			return kes.ErrKeyNotFound
+		}
+		return ErrConflict
+	}
+	ks.notify(EntryUpdated, name)
 	return nil
 }
 
 // Delete removes the entry. It may return either no error or
 // kes.ErrKeyNotFound if no such entry exists.
 func (ks *MemKeyStore) Delete(_ context.Context, name string) error {
+	ks.mu.Lock()
+	if timer, ok := ks.timers[name]; ok {
+		timer.Stop()
+		delete(ks.timers, name)
+	}
+	ks.mu.Unlock()
+
+	ks.metadata.Delete(name)
 	if !ks.keys.Delete(name) {
 		return kes.ErrKeyNotFound
 	}
+	ks.notify(EntryDeleted, name)
 	return nil
 }
 
@@ -110,6 +580,32 @@ func (ks *MemKeyStore) Get(_ context.Context, name string) ([]byte, error) {
 	return nil, kes.ErrKeyNotFound
 }
 
+// Metadata returns the metadata of the entry with the given name.
+// It returns an empty EntryMetadata if no metadata is stored for
+// the entry.
+//
+// Metadata never returns an error.
+func (ks *MemKeyStore) Metadata(_ context.Context, name string) (EntryMetadata, error) {
+	m, _ := ks.metadata.Get(name)
+	m.Tags = maps.Clone(m.Tags)
+	m.Operations = slices.Clone(m.Operations)
+	m.Grants = maps.Clone(m.Grants)
+	return m, nil
+}
+
+// SetMetadata stores the metadata of an existing entry. It returns
+// kes.ErrKeyNotFound if no such entry exists.
+func (ks *MemKeyStore) SetMetadata(_ context.Context, name string, metadata EntryMetadata) error {
+	if _, ok := ks.keys.Get(name); !ok {
+		return kes.ErrKeyNotFound
+	}
+	metadata.Tags = maps.Clone(metadata.Tags)
+	metadata.Operations = slices.Clone(metadata.Operations)
+	metadata.Grants = maps.Clone(metadata.Grants)
+	ks.metadata.Set(name, metadata)
+	return nil
+}
+
 // List returns the first n key names that start with the given
 // prefix and the next prefix from which to continue the listing.
 //
@@ -121,13 +617,25 @@ func (ks *MemKeyStore) Get(_ context.Context, name string) ([]byte, error) {
 // returned prefix is empty.
 //
 // List never returns an error.
-func (ks *MemKeyStore) List(_ context.Context, prefix string, n int) ([]string, string, error) {
+func (ks *MemKeyStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	return ks.ListFrom(ctx, prefix, "", n)
+}
+
+// ListFrom behaves like List but ignores all key names that are
+// lexicographically smaller than continueAt.
+//
+// ListFrom never returns an error.
+func (ks *MemKeyStore) ListFrom(_ context.Context, prefix, continueAt string, n int) ([]string, string, error) {
 	if n == 0 {
 		return []string{}, prefix, nil
 	}
 
 	keys := ks.keys.Keys()
 	slices.Sort(keys)
+	if continueAt != "" {
+		i, _ := slices.BinarySearch(keys, continueAt)
+		keys = keys[i:]
+	}
 
 	if prefix == "" {
 		if n < 0 || n >= len(keys) {
@@ -152,6 +660,50 @@ func (ks *MemKeyStore) List(_ context.Context, prefix string, n int) ([]string,
 	return keys[i:], "", nil
 }
 
+// Watch returns a channel that receives an event whenever an entry
+// starting with the given prefix is created, replaced or deleted.
+// The channel is closed once the context is canceled.
+//
+// Events are dropped if the channel's buffer is full. Watch never
+// returns an error.
+func (ks *MemKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	w := &memWatcher{
+		prefix: prefix,
+		events: make(chan KeyStoreEvent, 64),
+	}
+
+	ks.mu.Lock()
+	ks.watchers = append(ks.watchers, w)
+	ks.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		ks.mu.Lock()
+		defer ks.mu.Unlock()
+
+		ks.watchers = slices.DeleteFunc(ks.watchers, func(v *memWatcher) bool { return v == w })
+		close(w.events)
+	}()
+	return w.events, nil
+}
+
+// notify sends an event to all watchers of the entry.
+func (ks *MemKeyStore) notify(typ KeyStoreEventType, name string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for _, w := range ks.watchers {
+		if !strings.HasPrefix(name, w.prefix) {
+			continue
+		}
+		select {
+		case w.events <- KeyStoreEvent{Type: typ, Name: name}:
+		default:
+		}
+	}
+}
+
 // Close does nothing and returns no error.
 //
 // It is implemented to satisfy the KeyStore
@@ -168,8 +720,13 @@ func (ks *MemKeyStore) Close() error { return nil }
 func newCache(store KeyStore, conf *CacheConfig) *keyCache {
 	ctx, stop := context.WithCancel(context.Background())
 	c := &keyCache{
-		store: store,
-		stop:  stop,
+		store:  store,
+		crypto: cryptoKeyStore(store),
+		deks:   newDEKCache(conf.DEKSize, conf.DEKExpiry),
+		stop:   stop,
+	}
+	if store, ok := store.(MetadataKeyStore); ok {
+		go c.gc(ctx, usageFlushInterval, func() { c.usage.Flush(ctx, store, &c.metadata, c.cachedMetadata) })
 	}
 
 	expiryOffline := conf.ExpiryOffline
@@ -200,6 +757,22 @@ func newCache(store KeyStore, conf *CacheConfig) *keyCache {
 			c.cache.DeleteAll()
 		}
 	})
+	if c.deks != nil {
+		go c.gc(ctx, conf.DEKExpiry/2, c.deks.DeleteExpired)
+	}
+	if !expiringKeyStore(store) {
+		const SweepInterval = 5 * time.Minute
+		go c.gc(ctx, SweepInterval, func() {
+			c.deleteExpiredSecrets(ctx)
+			c.deleteExpiredKeys(ctx)
+		})
+	}
+	if w, ok := store.(WatchableKeyStore); ok {
+		// Subscribe before returning such that no change
+		// made after newCache returns gets missed.
+		events, _ := w.Watch(ctx, "")
+		go c.watch(ctx, w, events)
+	}
 	go c.gc(ctx, 10*time.Second, func() {
 		_, err := c.store.Status(ctx)
 		if err != nil && !errors.Is(err, context.Canceled) {
@@ -211,6 +784,34 @@ func newCache(store KeyStore, conf *CacheConfig) *keyCache {
 	return c
 }
 
+// errKeyStoreNotFound is returned when trying to switch to a
+// standby KeyStore that does not exist.
+var errKeyStoreNotFound = api.NewError(http.StatusNotFound, "key store does not exist")
+
+// errRotateNotSupported is returned when trying to rotate a key
+// stored on a KeyStore that does not implement MutableKeyStore.
+var errRotateNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support key rotation")
+
+// errKeyMaterialNotSupported is returned when trying to access
+// or import the key material of a key stored at a CryptoKeyStore.
+var errKeyMaterialNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support operations that require key material")
+
+// errPurgeNotSupported is returned when trying to purge a KeyStore
+// that does not implement PurgeableKeyStore.
+var errPurgeNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support purging entries")
+
+// errKeyProtected is returned when trying to delete a key that
+// is protected against deletion.
+var errKeyProtected = api.NewError(http.StatusConflict, "key is protected against deletion")
+
+// errProtectNotSupported is returned when trying to protect a key
+// stored on a KeyStore that does not implement MetadataKeyStore.
+var errProtectNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support key protection")
+
+// errRotateConflict is returned when a key keeps being modified
+// concurrently while trying to rotate it.
+var errRotateConflict = api.NewError(http.StatusConflict, "key has been modified concurrently")
+
 // keyCache is an in-memory cache for keys fetched from a Keystore.
 // A keyCache runs a background garbage collector that periodically
 // evicts cache entries based on a CacheConfig.
@@ -221,6 +822,10 @@ type keyCache struct {
 	store KeyStore
 	cache cache.Cow[string, *cacheEntry]
 
+	// crypto is the store as CryptoKeyStore. It is nil
+	// if the store does not implement CryptoKeyStore.
+	crypto CryptoKeyStore
+
 	// The barrier prevents reading the same key multiple
 	// times concurrently from the kv.Store.
 	// When a particular key isn't cached, we don't want
@@ -229,16 +834,47 @@ type keyCache struct {
 	// all others to wait until the first is done.
 	barrier cache.Barrier[string]
 
+	// The metadata lock serializes read-modify-writes of
+	// the metadata of a key, like setting its tags, such
+	// that concurrent updates are not lost. It must be
+	// acquired after the barrier, if both are held.
+	metadata cache.Barrier[string]
+
+	// Optional cache for decrypted data keys.
+	// It is nil if the data key cache is disabled.
+	deks *dekCache
+
+	// Usage of keys not yet persisted at the KeyStore.
+	usage keyUsage
+
+	// Import wrapping keys stored at the KeyStore.
+	imports importKeyring
+
 	// Controls whether we treat the cache as offline
 	// cache (with different GC config).
 	offline atomic.Bool
 	stop    func() // Stops the GC
+
+	// Optional function reporting created, rotated
+	// and deleted keys to peers sharing the KeyStore.
+	notify func(KeyStoreEvent)
+
+	// Optional function replicating created, rotated
+	// and deleted keys to other KES clusters.
+	replicate func(KeyStoreEvent)
+
+	// Optional soft delete config. If nil, keys are
+	// deleted immediately.
+	softDeletes *SoftDeleteConfig
 }
 
-// A cache entry with a recently used flag.
+// A cache entry with a recently used flag, the operations the
+// key may be used for and the operations granted on the key.
 type cacheEntry struct {
-	Key  crypto.KeyVersion
-	Used atomic.Bool
+	Key        crypto.Key
+	Operations []string
+	Grants     map[kes.Identity][]string
+	Used       atomic.Bool
 }
 
 // Status returns the current state of the underlying KeyStore.
@@ -254,31 +890,337 @@ func (c *keyCache) Status(ctx context.Context) (KeyStoreState, error) {
 
 // Create creates a new key with the given name if and only if
 // no such entry exists. Otherwise, kes.ErrKeyExists is returned.
+// It fails if the key store is a CryptoKeyStore. Use CreateKey
+// instead.
 func (c *keyCache) Create(ctx context.Context, name string, key crypto.KeyVersion) error {
-	b, err := crypto.EncodeKeyVersion(key)
+	return c.CreateVersions(ctx, name, crypto.Key{Versions: []crypto.KeyVersion{key}})
+}
+
+// CreateVersions creates a new key with the given name and all
+// versions of key if and only if no such entry exists. Otherwise,
+// kes.ErrKeyExists is returned. It fails if the key store is a
+// CryptoKeyStore.
+func (c *keyCache) CreateVersions(ctx context.Context, name string, key crypto.Key) error {
+	return c.createVersions(ctx, name, key, nil)
+}
+
+// CreateWithMetadata behaves like Create but also stores the metadata
+// modified by update, like tags or operations, alongside the key. If
+// the metadata cannot be stored, the key is deleted again. It returns
+// errNotSupported if keys cannot have metadata.
+//
+// The key cannot be used until its metadata has been stored. Hence,
+// a key restricted to certain operations is never unrestricted.
+func (c *keyCache) CreateWithMetadata(ctx context.Context, name string, key crypto.KeyVersion, errNotSupported error, update func(*EntryMetadata)) error {
+	return c.CreateVersionsWithMetadata(ctx, name, crypto.Key{Versions: []crypto.KeyVersion{key}}, errNotSupported, update)
+}
+
+// CreateVersionsWithMetadata behaves like CreateWithMetadata but
+// creates the key with all versions of key.
+func (c *keyCache) CreateVersionsWithMetadata(ctx context.Context, name string, key crypto.Key, errNotSupported error, update func(*EntryMetadata)) error {
+	if _, ok := c.store.(MetadataKeyStore); !ok || !c.SupportsMetadata() {
+		return errNotSupported
+	}
+	return c.createVersions(ctx, name, key, update)
+}
+
+// createVersions creates a new key with all versions of key. If
+// update is not nil, it stores the metadata modified by update or
+// fails. Otherwise, storing metadata is best-effort.
+func (c *keyCache) createVersions(ctx context.Context, name string, key crypto.Key, update func(*EntryMetadata)) error {
+	if c.crypto != nil {
+		return errKeyMaterialNotSupported
+	}
+	b, err := crypto.EncodeKey(key)
 	if err != nil {
 		return err
 	}
+	defer secmem.Zero(b)
+
+	// Fetching a key acquires its barrier. Holding it until the
+	// metadata has been stored ensures that the key is not used,
+	// and cached, without its metadata.
+	c.barrier.Lock(name)
+	defer c.barrier.Unlock(name)
 
 	if err = c.store.Create(ctx, name, b); err != nil {
 		if errors.Is(err, kes.ErrKeyExists) {
 			return kes.ErrKeyExists
 		}
+		return err
+	}
+	if update == nil {
+		c.setMetadata(ctx, name, &key)
+	} else {
+		m := metadataOf(&key)
+		update(&m)
+		c.metadata.Lock(name)
+		err = c.store.(MetadataKeyStore).SetMetadata(ctx, name, m)
+		c.metadata.Unlock(name)
+		if err != nil {
+			if dErr := c.store.Delete(ctx, name); dErr != nil {
+				return errors.Join(err, dErr)
+			}
+			return err
+		}
+	}
+	c.notifyPeers(EntryCreated, name)
+	return nil
+}
+
+// CreateKey creates a new key with the given name at the
+// CryptoKeyStore. The key material never leaves the store.
+// It returns kes.ErrKeyExists if such a key exists already.
+func (c *keyCache) CreateKey(ctx context.Context, name string) error {
+	if c.crypto == nil {
+		return errors.New("kes: key store is not a crypto key store")
+	}
+	if err := c.crypto.CreateKey(ctx, name); err != nil {
+		if errors.Is(err, kes.ErrKeyExists) {
+			return kes.ErrKeyExists
+		}
+		return err
 	}
-	return err
+	c.notifyPeers(EntryCreated, name)
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the key with the given
+// name at the CryptoKeyStore. It returns kes.ErrKeyNotFound
+// if no such key exists.
+func (c *keyCache) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	if c.crypto == nil {
+		return nil, errors.New("kes: key store is not a crypto key store")
+	}
+	c.usage.Add(name, time.Now())
+
+	ciphertext, err := c.crypto.Encrypt(ctx, name, plaintext, associatedData)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, kes.ErrKeyNotFound
+		}
+		return nil, err
+	}
+	return ciphertext, nil
+}
+
+// GenerateKey generates a new data key and encrypts it with the
+// key with the given name at the CryptoKeyStore. It returns
+// kes.ErrKeyNotFound if no such key exists.
+func (c *keyCache) GenerateKey(ctx context.Context, name string, associatedData []byte) (plaintext, ciphertext []byte, err error) {
+	if c.crypto == nil {
+		return nil, nil, errors.New("kes: key store is not a crypto key store")
+	}
+	c.usage.Add(name, time.Now())
+
+	plaintext, ciphertext, err = generateKey(ctx, c.crypto, name, associatedData)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, nil, kes.ErrKeyNotFound
+		}
+		return nil, nil, err
+	}
+	return plaintext, ciphertext, nil
+}
+
+// DecryptRemote behaves like Decrypt but decrypts the ciphertext
+// with the key with the given name at the CryptoKeyStore.
+func (c *keyCache) DecryptRemote(ctx context.Context, name string, ciphertext, associatedData []byte) (plaintext []byte, cached bool, err error) {
+	if c.crypto == nil {
+		return nil, false, errors.New("kes: key store is not a crypto key store")
+	}
+	return c.decrypt("decrypt", name, ciphertext, associatedData, func(ciphertext, associatedData []byte) ([]byte, error) {
+		plaintext, err := c.crypto.Decrypt(ctx, name, ciphertext, associatedData)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, kes.ErrKeyNotFound
+		}
+		return plaintext, err
+	})
+}
+
+// Describe returns the metadata of the key with the given name.
+// It returns kes.ErrKeyNotFound if no such key exists.
+//
+// Describe uses the metadata stored at a MetadataKeyStore, if
+// present, or the cached key. Otherwise, it fetches the key without
+// counting it as used. The returned key usage includes the usage not
+// yet persisted at the KeyStore.
+func (c *keyCache) Describe(ctx context.Context, name string) (EntryMetadata, error) {
+	var m EntryMetadata
+	if store, ok := c.store.(MetadataKeyStore); ok {
+		var err error
+		if m, err = store.Metadata(ctx, name); err != nil {
+			if errors.Is(err, kes.ErrKeyNotFound) {
+				return EntryMetadata{}, kes.ErrKeyNotFound
+			}
+			return EntryMetadata{}, err
+		}
+	}
+	if m.IsEmpty() {
+		key, err := c.get(ctx, name)
+		if err != nil {
+			return EntryMetadata{}, err
+		}
+		m = withUsage(metadataOf(&key), m)
+	}
+	c.usage.Apply(name, &m)
+	return m, nil
+}
+
+// cachedMetadata returns the EntryMetadata of the cached key
+// with the given name, if present.
+func (c *keyCache) cachedMetadata(name string) (EntryMetadata, bool) {
+	entry, ok := c.cache.Get(name)
+	if !ok {
+		return EntryMetadata{}, false
+	}
+	return metadataOf(&entry.Key), true
+}
+
+// withUsage returns m with the key usage of usage.
+func withUsage(m, usage EntryMetadata) EntryMetadata {
+	m.Uses, m.LastUsedAt = usage.Uses, usage.LastUsedAt
+	return m
+}
+
+// setMetadata stores the metadata of the key at the KeyStore if it
+// implements MetadataKeyStore. Metadata is informational. Failing
+// to store it does not fail the key operation. At worst, Describe
+// reports outdated metadata until the key is modified again.
+//
+// It keeps the key usage, tags, deletion protection, operations and
+// grants already stored at the KeyStore.
+func (c *keyCache) setMetadata(ctx context.Context, name string, key *crypto.Key) {
+	if store, ok := c.store.(MetadataKeyStore); ok {
+		c.metadata.Lock(name)
+		defer c.metadata.Unlock(name)
+
+		m, _ := store.Metadata(ctx, name)
+		next := withUsage(metadataOf(key), m)
+		next.Tags, next.Protected, next.Operations = m.Tags, m.Protected, m.Operations
+		next.Grants = m.Grants
+		_ = store.SetMetadata(ctx, name, next)
+	}
+}
+
+// SupportsMetadata reports whether keys can be tagged or protected
+// against deletion. Both are stored as metadata. Hence, the KeyStore
+// has to implement MetadataKeyStore. Keys of a CryptoKeyStore cannot
+// be tagged or protected.
+func (c *keyCache) SupportsMetadata() bool {
+	_, ok := baseKeyStore(c.store).(MetadataKeyStore)
+	return ok && c.crypto == nil
+}
+
+// SetTags replaces the tags of the key with the given name. It
+// returns errTagsNotSupported if the KeyStore does not implement
+// MetadataKeyStore and kes.ErrKeyNotFound if no such key exists.
+func (c *keyCache) SetTags(ctx context.Context, name string, tags map[string]string) error {
+	return c.updateMetadata(ctx, name, errTagsNotSupported, func(m *EntryMetadata) { m.Tags = tags })
+}
+
+// SetProtected enables or disables the deletion protection of the
+// key with the given name. It returns errProtectNotSupported if the
+// KeyStore does not implement MetadataKeyStore and kes.ErrKeyNotFound
+// if no such key exists.
+func (c *keyCache) SetProtected(ctx context.Context, name string, protected bool) error {
+	return c.updateMetadata(ctx, name, errProtectNotSupported, func(m *EntryMetadata) { m.Protected = protected })
+}
+
+// updateMetadata applies update to the metadata of the key with the
+// given name. If no metadata is stored for the key yet, it derives it
+// from the key. It returns errNotSupported if keys cannot have metadata.
+//
+// Concurrent updates of the same key are serialized. Hence, none
+// of them gets lost.
+func (c *keyCache) updateMetadata(ctx context.Context, name string, errNotSupported error, update func(*EntryMetadata)) error {
+	store, ok := c.store.(MetadataKeyStore)
+	if !ok || !c.SupportsMetadata() {
+		return errNotSupported
+	}
+
+	c.metadata.Lock(name)
+	defer c.metadata.Unlock(name)
+
+	m, err := store.Metadata(ctx, name)
+	if err != nil {
+		return err
+	}
+	if m.IsEmpty() {
+		// We must not fetch the key via get since it acquires
+		// the barrier while we hold the metadata lock.
+		b, err := c.store.Get(ctx, name)
+		if err != nil {
+			if errors.Is(err, kes.ErrKeyNotFound) {
+				return kes.ErrKeyNotFound
+			}
+			return err
+		}
+		key, err := crypto.ParseKey(b)
+		secmem.Zero(b)
+		if err != nil {
+			return err
+		}
+		m = withUsage(metadataOf(&key), m)
+	}
+	update(&m)
+	return store.SetMetadata(ctx, name, m)
+}
+
+// protected reports whether the key with the given name is
+// protected against deletion.
+func (c *keyCache) protected(ctx context.Context, name string) (bool, error) {
+	store, ok := c.store.(MetadataKeyStore)
+	if !ok {
+		return false, nil
+	}
+	m, err := store.Metadata(ctx, name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return false, nil // Let the KeyStore report that the key does not exist
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.Protected, nil
 }
 
 // Delete deletes the key from the key store and removes it from the
 // cache. It may return either no error or kes.ErrKeyNotFound if no
 // such entry exists.
+//
+// It returns errKeyProtected if the key is protected against deletion.
+//
+// If soft deletes are enabled, the key can be restored with Undelete
+// until its retention period has elapsed. Keys of a CryptoKeyStore
+// are always deleted immediately.
 func (c *keyCache) Delete(ctx context.Context, name string) error {
-	if err := c.store.Delete(ctx, name); err != nil {
-		if errors.Is(err, kes.ErrKeyNotFound) {
+	if err := c.delete(ctx, name); err != nil {
+		return err
+	}
+	c.notifyPeers(EntryDeleted, name)
+	return nil
+}
+
+// delete behaves like Delete but does not notify peers.
+func (c *keyCache) delete(ctx context.Context, name string) error {
+	if protected, err := c.protected(ctx, name); err != nil {
+		return err
+	} else if protected {
+		return errKeyProtected
+	}
+
+	if c.softDeletes != nil && c.crypto == nil {
+		if err := c.softDelete(ctx, name, c.softDeletes.Retention); err != nil {
 			return err
 		}
+	} else if err := c.store.Delete(ctx, name); err != nil {
 		return err
 	}
 	c.cache.Delete(name)
+	if c.deks != nil {
+		c.deks.DeleteKey(name)
+	}
+	c.usage.Delete(name)
 	return nil
 }
 
@@ -290,7 +1232,23 @@ func (c *keyCache) Delete(ctx context.Context, name string) error {
 // Get tries to make as few calls to the underlying key store. Multiple
 // concurrent Get calls for the same key, that is not in the cache, are
 // serialized.
-func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, error) {
+//
+// Get fails if the key store is a CryptoKeyStore since its keys
+// never leave the store. It counts the key as used.
+func (c *keyCache) Get(ctx context.Context, name string) (crypto.Key, error) {
+	key, err := c.get(ctx, name)
+	if err != nil {
+		return crypto.Key{}, err
+	}
+	c.usage.Add(name, time.Now())
+	return key, nil
+}
+
+// get behaves like Get but does not count the key as used.
+func (c *keyCache) get(ctx context.Context, name string) (crypto.Key, error) {
+	if c.crypto != nil {
+		return crypto.Key{}, errKeyMaterialNotSupported
+	}
 	if entry, ok := c.cache.Get(name); ok {
 		entry.Used.Store(true)
 		return entry.Key, nil
@@ -313,24 +1271,283 @@ func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, err
 	b, err := c.store.Get(ctx, name)
 	if err != nil {
 		if errors.Is(err, kes.ErrKeyNotFound) {
-			return crypto.KeyVersion{}, kes.ErrKeyNotFound
+			return crypto.Key{}, kes.ErrKeyNotFound
 		}
-		return crypto.KeyVersion{}, err
+		return crypto.Key{}, err
 	}
+	defer secmem.Zero(b)
 
-	k, err := crypto.ParseKeyVersion(b)
+	k, err := crypto.ParseKey(b)
 	if err != nil {
-		return crypto.KeyVersion{}, err
+		return crypto.Key{}, err
+	}
+
+	// Cache the operations the key may be used for and the grants
+	// alongside the key. If they cannot be read, we must not cache
+	// the key. Otherwise, a restricted key could be used for any
+	// operation.
+	var m EntryMetadata
+	if store, ok := c.store.(MetadataKeyStore); ok {
+		if m, err = store.Metadata(ctx, name); err != nil {
+			return crypto.Key{}, err
+		}
 	}
 
 	entry := &cacheEntry{
-		Key: k,
+		Key:        k,
+		Operations: m.Operations,
+		Grants:     m.Grants,
 	}
 	entry.Used.Store(true)
 	c.cache.Set(name, entry)
 	return entry.Key, nil
 }
 
+// entry returns the cache entry of the key with the given name. It
+// fetches the key if it is not cached.
+func (c *keyCache) entry(ctx context.Context, name string) (*cacheEntry, error) {
+	if entry, ok := c.cache.Get(name); ok {
+		return entry, nil
+	}
+	if _, err := c.get(ctx, name); err != nil {
+		return nil, err
+	}
+	entry, ok := c.cache.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("kes: key '%s' has been evicted concurrently", name)
+	}
+	return entry, nil
+}
+
+// Decrypt decrypts the ciphertext with the key with the given name.
+// If the data key cache is enabled, Decrypt first looks up the
+// plaintext in the cache and only fetches the key on a miss. The
+// returned cached flag reports whether the plaintext has been served
+// from the cache.
+//
+// Deleting a key evicts all its cached plaintexts. Hence, requests
+// for a deleted key fail even though the key is not fetched on a
+// cache hit.
+func (c *keyCache) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) (plaintext []byte, cached bool, err error) {
+	return c.decrypt("decrypt", name, ciphertext, associatedData, func(ciphertext, associatedData []byte) ([]byte, error) {
+		key, err := c.get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return key.Decrypt(ciphertext, associatedData)
+	})
+}
+
+// Reencrypt decrypts the ciphertext with the key with the given name
+// and encrypts the plaintext again with the latest key version. The
+// plaintext never leaves the server. It returns kes.ErrKeyNotFound
+// if no such key exists and kes.ErrDecrypt if the ciphertext cannot
+// be decrypted.
+//
+// Unlike Decrypt, Reencrypt never caches the plaintext.
+func (c *keyCache) Reencrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	var plaintext []byte
+	if c.crypto != nil {
+		var err error
+		if plaintext, err = c.crypto.Decrypt(ctx, name, ciphertext, associatedData); err != nil {
+			if errors.Is(err, kes.ErrKeyNotFound) {
+				return nil, kes.ErrKeyNotFound
+			}
+			return nil, err
+		}
+		defer secmem.Zero(plaintext)
+
+		c.usage.Add(name, time.Now())
+		ciphertext, err = c.crypto.Encrypt(ctx, name, plaintext, associatedData)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, kes.ErrKeyNotFound
+		}
+		return ciphertext, err
+	}
+
+	key, err := c.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if plaintext, err = key.Decrypt(ciphertext, associatedData); err != nil {
+		return nil, err
+	}
+
+	// Encryption may happen in-place and overwrite the plaintext.
+	// Hence, we must only clear the plaintext if encryption fails.
+	c.usage.Add(name, time.Now())
+	if ciphertext, err = key.Latest().Key.Encrypt(plaintext, associatedData); err != nil {
+		secmem.Zero(plaintext)
+		return nil, err
+	}
+	return ciphertext, nil
+}
+
+// Unwrap behaves like Decrypt but unwraps a ciphertext wrapped for
+// the public key of the key with the given name.
+func (c *keyCache) Unwrap(ctx context.Context, name string, ciphertext, associatedData []byte) (plaintext []byte, cached bool, err error) {
+	return c.decrypt("unwrap", name, ciphertext, associatedData, func(ciphertext, associatedData []byte) ([]byte, error) {
+		key, err := c.get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return key.Unwrap(ciphertext, associatedData)
+	})
+}
+
+func (c *keyCache) decrypt(op, name string, ciphertext, associatedData []byte, f func(ciphertext, associatedData []byte) ([]byte, error)) ([]byte, bool, error) {
+	if c.deks == nil {
+		plaintext, err := f(ciphertext, associatedData)
+		if err == nil {
+			c.usage.Add(name, time.Now())
+		}
+		return plaintext, false, err
+	}
+
+	// Compute the ID before decrypting since decryption
+	// may happen in-place and modify the ciphertext.
+	id := dekID(op, name, ciphertext, associatedData)
+	if plaintext, ok := c.deks.Get(id); ok {
+		c.usage.Add(name, time.Now())
+		return plaintext, true, nil
+	}
+	plaintext, err := f(ciphertext, associatedData)
+	if err != nil {
+		return nil, false, err
+	}
+	c.deks.Add(id, name, plaintext)
+	c.usage.Add(name, time.Now())
+	return plaintext, false, nil
+}
+
+// Rotate adds a new key version to the key with the given name
+// and replaces the cached key, if any. It returns kes.ErrKeyNotFound
+// if no such key exists.
+//
+// Rotate reads the key from the key store, not the cache, to avoid
+// dropping key versions added by a concurrent rotation.
+func (c *keyCache) Rotate(ctx context.Context, name string, identity kes.Identity) (crypto.Key, error) {
+	// Number of times we try to rotate a key that gets
+	// modified concurrently, e.g. by another KES server.
+	const MaxAttempts = 3
+
+	if c.crypto != nil {
+		return crypto.Key{}, errKeyMaterialNotSupported
+	}
+	store, ok := c.store.(MutableKeyStore)
+	if !ok {
+		return crypto.Key{}, errRotateNotSupported
+	}
+
+	c.barrier.Lock(name)
+	defer c.barrier.Unlock(name)
+
+	for i := 1; ; i++ {
+		key, err := c.rotate(ctx, store, name, identity, nil)
+		if errors.Is(err, ErrConflict) {
+			if i < MaxAttempts {
+				continue
+			}
+			return crypto.Key{}, errRotateConflict
+		}
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		c.rotated(ctx, name, key)
+		return key, nil
+	}
+}
+
+// RotateIfDue behaves like Rotate but only rotates the key if due
+// reports true for the key currently stored at the key store. It
+// reports whether the key has been rotated.
+//
+// Unlike Rotate, RotateIfDue does not retry if the key has been
+// modified concurrently. Instead, it assumes that another KES server
+// sharing the key store has rotated the key. Hence, only one server
+// rotates a scheduled key if the key store is a ConditionalKeyStore.
+func (c *keyCache) RotateIfDue(ctx context.Context, name string, due func(*crypto.Key) bool) (bool, error) {
+	if c.crypto != nil {
+		return false, errKeyMaterialNotSupported
+	}
+	store, ok := c.store.(MutableKeyStore)
+	if !ok {
+		return false, errRotateNotSupported
+	}
+
+	c.barrier.Lock(name)
+	defer c.barrier.Unlock(name)
+
+	key, err := c.rotate(ctx, store, name, "", due)
+	if errors.Is(err, errRotateNotDue) || errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c.rotated(ctx, name, key)
+	return true, nil
+}
+
+// errRotateNotDue is returned by rotate if the key is not
+// due for rotation.
+var errRotateNotDue = errors.New("kes: key is not due for rotation")
+
+// rotated updates the metadata and the cache entry of a rotated
+// key and notifies the peers.
+func (c *keyCache) rotated(ctx context.Context, name string, key crypto.Key) {
+	c.setMetadata(ctx, name, &key)
+
+	entry := &cacheEntry{
+		Key: key,
+	}
+	if old, ok := c.cache.Get(name); ok {
+		entry.Operations, entry.Grants = old.Operations, old.Grants
+	}
+	entry.Used.Store(true)
+	c.cache.Replace(name, entry)
+	c.notifyPeers(EntryUpdated, name)
+}
+
+// rotate reads the key from the store, adds a new key version and
+// replaces the stored key if it has not been modified in between.
+// If due is not nil, rotate returns errRotateNotDue unless due
+// reports true for the stored key.
+func (c *keyCache) rotate(ctx context.Context, store MutableKeyStore, name string, identity kes.Identity, due func(*crypto.Key) bool) (crypto.Key, error) {
+	old, err := store.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return crypto.Key{}, kes.ErrKeyNotFound
+		}
+		return crypto.Key{}, err
+	}
+	defer secmem.Zero(old)
+
+	key, err := crypto.ParseKey(old)
+	if err != nil {
+		return crypto.Key{}, err
+	}
+	if due != nil && !due(&key) {
+		return crypto.Key{}, errRotateNotDue
+	}
+	if key, err = key.Rotate(rand.Reader, identity); err != nil {
+		return crypto.Key{}, err
+	}
+	b, err := crypto.EncodeKey(key)
+	if err != nil {
+		return crypto.Key{}, err
+	}
+	defer secmem.Zero(b)
+
+	if err = setIf(ctx, store, name, b, old); err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return crypto.Key{}, kes.ErrKeyNotFound
+		}
+		return crypto.Key{}, err
+	}
+	return key, nil
+}
+
 // List returns the first n key names, that start with the given prefix,
 // and the next prefix from which the listing should continue.
 //
@@ -340,14 +1557,158 @@ func (c *keyCache) Get(ctx context.Context, name string) (crypto.KeyVersion, err
 // An empty prefix matches any key name. At the end of the listing
 // or when there are no (more) keys starting with the prefix, the
 // returned prefix is empty.
+//
+// List does not return secrets or entries of other enclaves stored
+// at the same key store. Their names start with a hyphen and are
+// reserved for internal use.
 func (c *keyCache) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
-	return c.store.List(ctx, prefix, n)
+	return c.ListFrom(ctx, prefix, "", n)
+}
+
+// ListFrom behaves like List but continues the listing at the
+// given key name. An empty continueAt starts at the first key.
+func (c *keyCache) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
+	// Valid key names never start with a hyphen. Hence, we skip
+	// all internal entries, which would otherwise be listed first.
+	if prefix == "" && continueAt < "0" {
+		continueAt = "0"
+	}
+
+	names, continueAt, err := listFrom(ctx, c.store, prefix, continueAt, n)
+	if err != nil {
+		return nil, "", err
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return strings.HasPrefix(name, "-")
+	})
+	return names, continueAt, nil
+}
+
+// Purge deletes all entries of the KeyStore whose names match one
+// of the patterns and discards all cached keys. If dryRun is true,
+// it only returns the names of the matching entries.
+//
+// Purge skips keys that are protected against deletion and
+// soft-deleted keys whose retention period has not elapsed. If
+// soft deletes are enabled, matching keys are soft-deleted instead
+// of deleted permanently.
+//
+// It returns errPurgeNotSupported if the KeyStore does not implement
+// PurgeableKeyStore. The KeyStore of an enclave never implements it
+// since its entries share the KeyStore with other enclaves.
+func (c *keyCache) Purge(ctx context.Context, patterns []string, dryRun bool) ([]string, error) {
+	if _, ok := asEnclaveKeyStore(c.store); ok {
+		return nil, errPurgeNotSupported
+	}
+	store, ok := baseKeyStore(c.store).(PurgeableKeyStore)
+	if !ok {
+		return nil, errPurgeNotSupported
+	}
+
+	matches, err := store.Purge(ctx, patterns, true)
+	if err != nil {
+		return nil, err
+	}
+	matches, err = c.purgeable(ctx, matches)
+	if err != nil || dryRun {
+		return matches, err
+	}
+
+	// Instead of purging the patterns, we purge the matching
+	// entries that are not protected by name. Otherwise, the
+	// KeyStore would delete the protected ones as well.
+	var (
+		names  []string
+		quoted []string
+	)
+	for _, name := range matches {
+		if c.softDeletes == nil || c.crypto != nil || strings.HasPrefix(name, "-") {
+			quoted = append(quoted, quotePattern(name))
+			continue
+		}
+		if err = c.softDelete(ctx, name, c.softDeletes.Retention); err != nil {
+			if !errors.Is(err, kes.ErrKeyNotFound) {
+				break
+			}
+			err = nil
+		}
+		names = append(names, name)
+	}
+	if err == nil && len(quoted) > 0 {
+		var purged []string
+		purged, err = store.Purge(ctx, quoted, false)
+		names = append(names, purged...)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		c.cache.Delete(name)
+		if c.deks != nil {
+			c.deks.DeleteKey(name)
+		}
+		c.usage.Delete(name)
+		c.notifyPeers(EntryDeleted, name)
+	}
+	return names, err
+}
+
+// purgeable returns the names of all entries that Purge may
+// delete. It removes keys that are protected against deletion
+// and soft-deleted keys whose retention period has not elapsed.
+func (c *keyCache) purgeable(ctx context.Context, names []string) ([]string, error) {
+	purgeable := make([]string, 0, len(names))
+	for _, name := range names {
+		if deleted, ok := strings.CutPrefix(name, deletedPrefix); ok {
+			key, err := c.getDeleted(ctx, deleted)
+			if err == nil {
+				secmem.Zero(key.Bytes)
+				continue
+			}
+			if !errors.Is(err, kes.ErrKeyNotFound) {
+				return nil, err
+			}
+		} else if !strings.HasPrefix(name, "-") {
+			protected, err := c.protected(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if protected {
+				continue
+			}
+		}
+		purgeable = append(purgeable, name)
+	}
+	return purgeable, nil
+}
+
+// quotePattern returns a path.Match pattern that matches
+// only the given name.
+func quotePattern(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // Close stops the cache's background garbage collector and
 // releases associated resources.
+//
+// It persists any key usage not yet stored at a MetadataKeyStore.
 func (c *keyCache) Close() error {
 	c.stop()
+	if store, ok := c.store.(MetadataKeyStore); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		c.usage.Flush(ctx, store, &c.metadata, c.cachedMetadata)
+		cancel()
+	}
+	if c.deks != nil {
+		c.deks.DeleteAll()
+	}
 	return c.store.Close()
 }
 
@@ -368,3 +1729,50 @@ func (c *keyCache) gc(ctx context.Context, interval time.Duration, f func()) {
 		}
 	}
 }
+
+// evict removes the key of the event from the cache. It also
+// removes its cached data keys unless the key has been replaced.
+//
+// Cached data keys of replaced keys are kept since rotating a key
+// does not remove existing key versions.
+func (c *keyCache) evict(event KeyStoreEvent) {
+	c.cache.Delete(event.Name)
+	if c.deks != nil && event.Type != EntryUpdated {
+		c.deks.DeleteKey(event.Name)
+	}
+}
+
+// notifyPeers reports the changed key to peers sharing the
+// KeyStore and to other KES clusters replicating keys, if any.
+func (c *keyCache) notifyPeers(typ KeyStoreEventType, name string) {
+	event := KeyStoreEvent{Type: typ, Name: name}
+	if c.notify != nil {
+		c.notify(event)
+	}
+	if c.replicate != nil {
+		c.replicate(event)
+	}
+}
+
+// watch evicts cached keys, and their cached data keys, once the
+// WatchableKeyStore reports that they have been changed. It keeps
+// watching the store, re-subscribing if the event channel gets
+// closed or is nil, until the context is canceled.
+func (c *keyCache) watch(ctx context.Context, store WatchableKeyStore, events <-chan KeyStoreEvent) {
+	const RetryDelay = 5 * time.Second
+
+	for {
+		if events != nil {
+			for event := range events {
+				c.evict(event)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(RetryDelay):
+		}
+		events, _ = store.Watch(ctx, "")
+	}
+}
@@ -0,0 +1,623 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestMemKeyStoreSetIf(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	if err := store.SetIf(ctx, "my-key", []byte("v2"), []byte("v1")); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Replacing missing entry: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	_ = store.Create(ctx, "my-key", []byte("v1"))
+	if err := store.SetIf(ctx, "my-key", []byte("v2"), []byte("v0")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Replacing modified entry: got '%v' - want '%v'", err, ErrConflict)
+	}
+	if err := store.SetIf(ctx, "my-key", []byte("v2"), []byte("v1")); err != nil {
+		t.Fatalf("Failed to replace entry: %v", err)
+	}
+	if v, _ := store.Get(ctx, "my-key"); string(v) != "v2" {
+		t.Fatalf("Invalid value: got '%s' - want '%s'", v, "v2")
+	}
+}
+
+func TestMemKeyStoreCreateWithTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	if err := store.CreateWithTTL(ctx, "my-key", []byte("v1"), time.Millisecond); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := store.CreateWithTTL(ctx, "my-key", []byte("v1"), time.Millisecond); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Creating existing entry: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	for i := 0; ; i++ {
+		if _, err := store.Get(ctx, "my-key"); errors.Is(err, kes.ErrKeyNotFound) {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Expired entry has not been deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Re-creating a deleted entry must not be affected by
+	// the expiry timer of the previous entry.
+	if err := store.CreateWithTTL(ctx, "my-key", []byte("v1"), 20*time.Millisecond); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if err := store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete entry: %v", err)
+	}
+	if err := store.Create(ctx, "my-key", []byte("v2")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := store.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Entry without TTL has been deleted: %v", err)
+	}
+}
+
+func TestKeyCacheRotateConflict(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &conflictKeyStore{}
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	if err := cache.Create(ctx, "my-key", newKeyVersion(t)); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	store.Conflicts = 2
+	key, err := cache.Rotate(ctx, "my-key", "")
+	if err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if n := len(key.Versions); n != 2 {
+		t.Fatalf("Invalid number of key versions: got '%d' - want '%d'", n, 2)
+	}
+
+	store.Conflicts = 3
+	if _, err = cache.Rotate(ctx, "my-key", ""); err != errRotateConflict {
+		t.Fatalf("Rotating concurrently modified key: got '%v' - want '%v'", err, errRotateConflict)
+	}
+}
+
+func TestKeyCacheRotateIfDue(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &conflictKeyStore{}
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	if err := cache.Create(ctx, "my-key", newKeyVersion(t)); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	due := func(key *crypto.Key) bool { return len(key.Versions) < 2 }
+
+	// A conflict indicates that another server has rotated the key.
+	store.Conflicts = 1
+	if rotated, err := cache.RotateIfDue(ctx, "my-key", due); err != nil || rotated {
+		t.Fatalf("Rotating concurrently modified key: got '%v' and '%v' - want '%v' and '%v'", rotated, err, false, nil)
+	}
+	if rotated, err := cache.RotateIfDue(ctx, "my-key", due); err != nil || !rotated {
+		t.Fatalf("Failed to rotate key: got '%v' and '%v' - want '%v' and '%v'", rotated, err, true, nil)
+	}
+	if rotated, err := cache.RotateIfDue(ctx, "my-key", due); err != nil || rotated {
+		t.Fatalf("Rotating key that is not due: got '%v' and '%v' - want '%v' and '%v'", rotated, err, false, nil)
+	}
+}
+
+func TestMemKeyStoreWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := &MemKeyStore{}
+	events, err := store.Watch(ctx, "my-")
+	if err != nil {
+		t.Fatalf("Failed to watch store: %v", err)
+	}
+
+	_ = store.Create(ctx, "other-key", nil)
+	_ = store.Create(ctx, "my-key", nil)
+	_ = store.Set(ctx, "my-key", nil)
+	_ = store.Delete(ctx, "my-key")
+
+	for _, want := range []KeyStoreEvent{
+		{Type: EntryCreated, Name: "my-key"},
+		{Type: EntryUpdated, Name: "my-key"},
+		{Type: EntryDeleted, Name: "my-key"},
+	} {
+		if event := <-events; event != want {
+			t.Fatalf("Invalid event: got '%s %s' - want '%s %s'", event.Type, event.Name, want.Type, want.Name)
+		}
+	}
+
+	cancel()
+	for range events { // The channel must be closed once ctx is canceled
+	}
+}
+
+func TestEnclaveKeyStoreWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shared := &MemKeyStore{}
+	store, ok := newEnclaveKeyStore(shared, enclavePrefix("my-enclave")).(WatchableKeyStore)
+	if !ok {
+		t.Fatal("Enclave KeyStore on a WatchableKeyStore is not watchable")
+	}
+	if _, ok = newEnclaveKeyStore(struct{ KeyStore }{shared}, "").(WatchableKeyStore); ok {
+		t.Fatal("Enclave KeyStore on a KeyStore without Watch is watchable")
+	}
+	events, err := store.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to watch store: %v", err)
+	}
+
+	_ = shared.Create(ctx, "my-key", nil) // Outside the enclave
+	_ = store.Create(ctx, "my-key", nil)
+	_ = store.Delete(ctx, "my-key")
+
+	for _, want := range []KeyStoreEvent{
+		{Type: EntryCreated, Name: "my-key"},
+		{Type: EntryDeleted, Name: "my-key"},
+	} {
+		if event := <-events; event != want {
+			t.Fatalf("Invalid event: got '%s %s' - want '%s %s'", event.Type, event.Name, want.Type, want.Name)
+		}
+	}
+
+	cancel()
+	for range events { // The channel must be closed once ctx is canceled
+	}
+}
+
+func TestKeyCacheWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	if err := cache.Create(ctx, "my-key", newKeyVersion(t)); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := cache.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+
+	// Delete the key out-of-band. The cache must evict it.
+	if err := store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	for i := 0; ; i++ {
+		if _, ok := cache.cache.Get("my-key"); !ok {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Deleted key has not been evicted from the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeyCacheDescribe(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for i, store := range []KeyStore{
+		&MemKeyStore{},
+		struct{ MutableKeyStore }{&MemKeyStore{}}, // A KeyStore without metadata
+	} {
+		cache := newCache(store, &CacheConfig{})
+		defer cache.Close()
+
+		version := newKeyVersion(t)
+		version.CreatedBy = "my-identity"
+		if err := cache.Create(ctx, "my-key", version); err != nil {
+			t.Fatalf("Test %d: failed to create key: %v", i, err)
+		}
+		if _, ok := store.(MetadataKeyStore); ok {
+			if m, _ := store.(MetadataKeyStore).Metadata(ctx, "my-key"); m.IsEmpty() {
+				t.Fatalf("Test %d: no metadata stored for key", i)
+			}
+		}
+
+		cache.cache.DeleteAll()
+		m, err := cache.Describe(ctx, "my-key")
+		if err != nil {
+			t.Fatalf("Test %d: failed to describe key: %v", i, err)
+		}
+		if m.Algorithm != version.Key.Type().String() || m.CreatedBy != version.CreatedBy || m.Versions != 1 || !m.RotatedAt.IsZero() {
+			t.Fatalf("Test %d: invalid metadata: %+v", i, m)
+		}
+
+		if _, err = cache.Rotate(ctx, "my-key", ""); err != nil {
+			t.Fatalf("Test %d: failed to rotate key: %v", i, err)
+		}
+		cache.cache.DeleteAll()
+		if m, err = cache.Describe(ctx, "my-key"); err != nil {
+			t.Fatalf("Test %d: failed to describe key: %v", i, err)
+		}
+		if m.Versions != 2 || m.RotatedAt.IsZero() {
+			t.Fatalf("Test %d: invalid metadata of rotated key: %+v", i, m)
+		}
+
+		if err = cache.Delete(ctx, "my-key"); err != nil {
+			t.Fatalf("Test %d: failed to delete key: %v", i, err)
+		}
+		if _, err = cache.Describe(ctx, "my-key"); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Test %d: describing deleted key: got '%v' - want '%v'", i, err, kes.ErrKeyNotFound)
+		}
+	}
+}
+
+func TestKeyCacheUsage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	version := newKeyVersion(t)
+	if err := cache.Create(ctx, "my-key", version); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if m, err := cache.Describe(ctx, "my-key"); err != nil || m.Uses != 0 || !m.LastUsedAt.IsZero() {
+		t.Fatalf("Unused key: got uses=%d last_used=%v - want no usage: %v", m.Uses, m.LastUsedAt, err)
+	}
+
+	ciphertext, err := version.Key.Encrypt([]byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+	if _, err = cache.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to get key: %v", err)
+	}
+	if _, _, err = cache.Decrypt(ctx, "my-key", ciphertext, nil); err != nil {
+		t.Fatalf("Failed to decrypt ciphertext: %v", err)
+	}
+	m, err := cache.Describe(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	if m.Uses != 2 || m.LastUsedAt.IsZero() {
+		t.Fatalf("Used key: got uses=%d last_used=%v - want 2 uses", m.Uses, m.LastUsedAt)
+	}
+
+	// Flushing persists the usage and keeps it across rotations.
+	cache.usage.Flush(ctx, store, &cache.metadata, cache.cachedMetadata)
+	if _, err = cache.Rotate(ctx, "my-key", ""); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if stored, _ := store.Metadata(ctx, "my-key"); stored.Uses != 2 || !stored.LastUsedAt.Equal(m.LastUsedAt) {
+		t.Fatalf("Persisted usage: got uses=%d last_used=%v - want uses=2 last_used=%v", stored.Uses, stored.LastUsedAt, m.LastUsedAt)
+	}
+	if m, err = cache.Describe(ctx, "my-key"); err != nil || m.Uses != 2 {
+		t.Fatalf("Flushed key: got uses=%d - want 2 uses: %v", m.Uses, err)
+	}
+}
+
+func TestKeyCacheConcurrentMetadata(t *testing.T) {
+	t.Parallel()
+
+	const N = 16
+
+	ctx := testContext(t)
+	store := slowMetadataKeyStore{&MemKeyStore{}}
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	if err := cache.Create(ctx, "my-key", newKeyVersion(t)); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Concurrent updates of different metadata fields must not
+	// overwrite each other.
+	var wg sync.WaitGroup
+	errs := make(chan error, N+2)
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- cache.SetGrant(ctx, "my-key", kes.Identity(fmt.Sprintf("identity-%d", i)), []string{keyOpEncrypt})
+		}(i)
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs <- cache.SetTags(ctx, "my-key", map[string]string{"env": "prod"})
+	}()
+	go func() {
+		defer wg.Done()
+		errs <- cache.SetProtected(ctx, "my-key", true)
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Failed to update metadata: %v", err)
+		}
+	}
+
+	m, err := store.Metadata(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if len(m.Grants) != N {
+		t.Fatalf("Lost grants: got %d - want %d", len(m.Grants), N)
+	}
+	if m.Tags["env"] != "prod" {
+		t.Fatalf("Lost tags: got '%v'", m.Tags)
+	}
+	if !m.Protected {
+		t.Fatal("Lost deletion protection")
+	}
+}
+
+// slowMetadataKeyStore is a KeyStore whose SetMetadata operations
+// take some time such that concurrent updates overlap.
+type slowMetadataKeyStore struct{ *MemKeyStore }
+
+func (s slowMetadataKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	time.Sleep(time.Millisecond)
+	return s.MemKeyStore.SetMetadata(ctx, name, metadata)
+}
+
+func TestKeyCacheDecryptCached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	cache := newCache(store, &CacheConfig{DEKSize: 10, DEKExpiry: time.Minute})
+	defer cache.Close()
+
+	version := newKeyVersion(t)
+	if err := cache.Create(ctx, "my-key", version); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	ciphertext, err := version.Key.Encrypt([]byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+	if _, cached, err := cache.Decrypt(ctx, "my-key", bytes.Clone(ciphertext), nil); err != nil || cached {
+		t.Fatalf("Failed to decrypt ciphertext: cached=%v err=%v", cached, err)
+	}
+
+	// Remove the key from the store and the key cache. A data key
+	// cache hit must not fetch the key again.
+	if err = store.Delete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	cache.cache.DeleteAll()
+	plaintext, cached, err := cache.Decrypt(ctx, "my-key", ciphertext, nil)
+	if err != nil || !cached {
+		t.Fatalf("Data key cache hit fetched key: cached=%v err=%v", cached, err)
+	}
+	if string(plaintext) != "Hello World" {
+		t.Fatalf("Invalid plaintext: got '%s' - want '%s'", plaintext, "Hello World")
+	}
+}
+
+func TestKeyCacheExpiredSecrets(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := struct{ MutableKeyStore }{&MemKeyStore{}} // A KeyStore that does not delete expired entries
+	cache := newCache(store, &CacheConfig{})
+	defer cache.Close()
+
+	expired := &secret{Bytes: []byte("expired"), ExpiresAt: time.Now().Add(-time.Minute)}
+	for _, name := range []string{"my-secret", "my-secret-2"} {
+		if err := cache.CreateSecret(ctx, name, expired); err != nil {
+			t.Fatalf("Failed to create secret '%s': %v", name, err)
+		}
+	}
+	if err := cache.CreateSecret(ctx, "my-secret-3", &secret{Bytes: []byte("valid")}); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	// Creating a secret must replace an expired one.
+	if err := cache.CreateSecret(ctx, "my-secret", &secret{Bytes: []byte("new")}); err != nil {
+		t.Fatalf("Failed to replace expired secret: %v", err)
+	}
+	if err := cache.CreateSecret(ctx, "my-secret", &secret{Bytes: []byte("new")}); !errors.Is(err, kes.ErrSecretExists) {
+		t.Fatalf("Creating existing secret: got '%v' - want '%v'", err, kes.ErrSecretExists)
+	}
+
+	cache.deleteExpiredSecrets(ctx)
+	names, _, err := cache.ListSecrets(ctx, "", "", -1)
+	if err != nil {
+		t.Fatalf("Failed to list secrets: %v", err)
+	}
+	if want := []string{"my-secret", "my-secret-3"}; !slices.Equal(names, want) {
+		t.Fatalf("Expired secrets have not been deleted: got %v - want %v", names, want)
+	}
+}
+
+func TestKeyCacheSoftDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &MemKeyStore{}
+	cache := newCache(struct{ MutableKeyStore }{store}, &CacheConfig{}) // A KeyStore that does not delete expired entries
+	cache.softDeletes = &SoftDeleteConfig{Retention: time.Hour}
+	defer cache.Close()
+
+	for _, name := range []string{"my-key", "my-key-2"} {
+		if err := cache.Create(ctx, name, newKeyVersion(t)); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+		if err := cache.Delete(ctx, name); err != nil {
+			t.Fatalf("Failed to delete key '%s': %v", name, err)
+		}
+		if _, err := cache.Get(ctx, name); !errors.Is(err, kes.ErrKeyNotFound) {
+			t.Fatalf("Using deleted key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+		}
+	}
+	if names, _, _ := cache.List(ctx, "", -1); len(names) != 0 {
+		t.Fatalf("Deleted keys are listed: %v", names)
+	}
+
+	// Let the retention period of 'my-key-2' elapse.
+	b, _ := store.Get(ctx, deletedPrefix+"my-key-2")
+	var key deletedKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		t.Fatalf("Failed to parse deleted key: %v", err)
+	}
+	key.PurgeAt = time.Now().Add(-time.Minute)
+	b, _ = json.Marshal(&key)
+	if err := store.Set(ctx, deletedPrefix+"my-key-2", b); err != nil {
+		t.Fatalf("Failed to update deleted key: %v", err)
+	}
+
+	cache.deleteExpiredKeys(ctx)
+	if _, err := store.Get(ctx, deletedPrefix+"my-key-2"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Expired key has not been deleted: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if err := cache.Undelete(ctx, "my-key-2"); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Restoring expired key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if err := cache.Undelete(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to restore key: %v", err)
+	}
+	if _, err := cache.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to use restored key: %v", err)
+	}
+}
+
+func TestKeyCacheUndeleteMetadata(t *testing.T) {
+	t.Parallel()
+
+	const Name = "my-key"
+	identity := kes.Identity("3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22")
+
+	ctx := context.Background()
+	store := &failingSetMetadataKeyStore{MemKeyStore: &MemKeyStore{}}
+	cache := newCache(store, &CacheConfig{})
+	cache.softDeletes = &SoftDeleteConfig{Retention: time.Hour}
+	defer cache.Close()
+
+	err := cache.CreateWithMetadata(ctx, Name, newKeyVersion(t), errOperationsNotSupported, func(m *EntryMetadata) {
+		m.Tags, m.Operations = map[string]string{"env": "prod"}, []string{keyOpEncrypt}
+	})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = cache.SetGrant(ctx, Name, identity, []string{keyOpEncrypt}); err != nil {
+		t.Fatalf("Failed to grant key: %v", err)
+	}
+	if err = cache.Delete(ctx, Name); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if err = cache.Undelete(ctx, Name); err != nil {
+		t.Fatalf("Failed to restore key: %v", err)
+	}
+
+	m, err := store.Metadata(ctx, Name)
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if !slices.Equal(m.Operations, []string{keyOpEncrypt}) {
+		t.Fatalf("Restored key lost its operations: got '%v'", m.Operations)
+	}
+	if m.Tags["env"] != "prod" {
+		t.Fatalf("Restored key lost its tags: got '%v'", m.Tags)
+	}
+	if !slices.Equal(m.Grants[identity], []string{keyOpEncrypt}) {
+		t.Fatalf("Restored key lost its grants: got '%v'", m.Grants)
+	}
+
+	// If the metadata cannot be restored, the key must not
+	// be restored either. Otherwise, it is not restricted.
+	if err = cache.Delete(ctx, Name); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	store.Fail.Store(true)
+	if err = cache.Undelete(ctx, Name); err == nil {
+		t.Fatal("Restoring key without its metadata should have failed")
+	}
+	if _, err = store.Get(ctx, Name); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Key has been restored without its metadata: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	store.Fail.Store(false)
+	if err = cache.Undelete(ctx, Name); err != nil {
+		t.Fatalf("Failed to restore key: %v", err)
+	}
+}
+
+// failingSetMetadataKeyStore is a KeyStore whose SetMetadata
+// operations fail once Fail is set.
+type failingSetMetadataKeyStore struct {
+	*MemKeyStore
+	Fail atomic.Bool
+}
+
+func (s *failingSetMetadataKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	if s.Fail.Load() {
+		return errors.New("kes: failed to store metadata")
+	}
+	return s.MemKeyStore.SetMetadata(ctx, name, metadata)
+}
+
+// conflictKeyStore is a MemKeyStore that simulates
+// concurrent modifications by failing the next
+// Conflicts SetIf calls with ErrConflict.
+type conflictKeyStore struct {
+	MemKeyStore
+
+	Conflicts int
+}
+
+func (s *conflictKeyStore) SetIf(ctx context.Context, name string, value, old []byte) error {
+	if s.Conflicts > 0 {
+		s.Conflicts--
+		return ErrConflict
+	}
+	return s.MemKeyStore.SetIf(ctx, name, value, old)
+}
+
+func newKeyVersion(t *testing.T) crypto.KeyVersion {
+	key, err := crypto.GenerateSecretKey(crypto.AES256, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate HMAC key: %v", err)
+	}
+	return crypto.KeyVersion{Key: key, HMACKey: hmac}
+}
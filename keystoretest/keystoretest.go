@@ -0,0 +1,211 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+// Package keystoretest implements conformance tests for
+// kes.KeyStore implementations.
+//
+// Authors of KeyStore implementations can verify that their
+// implementation behaves like the KES server expects:
+//
+//	func TestKeyStore(t *testing.T) {
+//		keystoretest.TestKeyStore(t, func(ctx context.Context) (kes.KeyStore, error) {
+//			return mystore.Connect(ctx, config)
+//		})
+//	}
+//
+// The conformance tests can be run with injected faults, like added
+// latency, by wrapping the KeyStore with a kes.FaultInjector.
+package keystoretest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/minio/kes"
+	kesdk "github.com/minio/kms-go/kes"
+)
+
+// NewFunc returns a new KeyStore for a single conformance test.
+// The returned KeyStore is closed once the test has finished.
+type NewFunc func(context.Context) (kes.KeyStore, error)
+
+// TestKeyStore runs the conformance tests against the KeyStores
+// returned by newStore. Each test uses its own KeyStore.
+//
+// The tests create and delete entries with a random name prefix.
+// They do not modify or delete any other entries. Hence, they
+// can be run against KeyStores that already contain entries.
+//
+// The Set tests are skipped if the KeyStore does not implement
+// kes.MutableKeyStore.
+func TestKeyStore(t *testing.T, newStore NewFunc) {
+	t.Run("Status", func(t *testing.T) { run(t, newStore, testStatus) })
+	t.Run("Create", func(t *testing.T) { run(t, newStore, testCreate) })
+	t.Run("Get", func(t *testing.T) { run(t, newStore, testGet) })
+	t.Run("Set", func(t *testing.T) { run(t, newStore, testSet) })
+	t.Run("Delete", func(t *testing.T) { run(t, newStore, testDelete) })
+	t.Run("List", func(t *testing.T) { run(t, newStore, testList) })
+}
+
+func testStatus(ctx context.Context, t *testing.T, store kes.KeyStore, _ string) {
+	if _, err := store.Status(ctx); err != nil {
+		t.Fatalf("Failed to fetch status: %v", err)
+	}
+}
+
+func testCreate(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	name := prefix + "key"
+	if err := store.Create(ctx, name, []byte("value")); err != nil {
+		t.Fatalf("Failed to create '%s': %v", name, err)
+	}
+	if err := store.Create(ctx, name, []byte("value")); !errors.Is(err, kesdk.ErrKeyExists) {
+		t.Fatalf("Creating existing entry '%s': got '%v' - want '%v'", name, err, kesdk.ErrKeyExists)
+	}
+}
+
+func testGet(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	name := prefix + "key"
+	if _, err := store.Get(ctx, name); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Getting missing entry '%s': got '%v' - want '%v'", name, err, kesdk.ErrKeyNotFound)
+	}
+
+	value := []byte("value")
+	if err := store.Create(ctx, name, value); err != nil {
+		t.Fatalf("Failed to create '%s': %v", name, err)
+	}
+	v, err := store.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("Failed to get '%s': %v", name, err)
+	}
+	if !bytes.Equal(v, value) {
+		t.Fatalf("Invalid value of '%s': got '%s' - want '%s'", name, v, value)
+	}
+}
+
+func testSet(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	s, ok := store.(kes.MutableKeyStore)
+	if !ok {
+		t.Skipf("%T does not implement MutableKeyStore", store)
+	}
+
+	name := prefix + "key"
+	if err := s.Set(ctx, name, []byte("value")); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Replacing missing entry '%s': got '%v' - want '%v'", name, err, kesdk.ErrKeyNotFound)
+	}
+	if err := s.Create(ctx, name, []byte("value")); err != nil {
+		t.Fatalf("Failed to create '%s': %v", name, err)
+	}
+
+	value := []byte("new-value")
+	if err := s.Set(ctx, name, value); err != nil {
+		t.Fatalf("Failed to replace '%s': %v", name, err)
+	}
+	v, err := s.Get(ctx, name)
+	if err != nil {
+		t.Fatalf("Failed to get '%s': %v", name, err)
+	}
+	if !bytes.Equal(v, value) {
+		t.Fatalf("Invalid value of '%s': got '%s' - want '%s'", name, v, value)
+	}
+}
+
+func testDelete(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	name := prefix + "key"
+	if err := store.Delete(ctx, name); err != nil && !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Deleting missing entry '%s': got '%v' - want '%v' or no error", name, err, kesdk.ErrKeyNotFound)
+	}
+
+	if err := store.Create(ctx, name, []byte("value")); err != nil {
+		t.Fatalf("Failed to create '%s': %v", name, err)
+	}
+	if err := store.Delete(ctx, name); err != nil {
+		t.Fatalf("Failed to delete '%s': %v", name, err)
+	}
+	if _, err := store.Get(ctx, name); !errors.Is(err, kesdk.ErrKeyNotFound) {
+		t.Fatalf("Getting deleted entry '%s': got '%v' - want '%v'", name, err, kesdk.ErrKeyNotFound)
+	}
+	if err := store.Create(ctx, name, []byte("value")); err != nil {
+		t.Fatalf("Failed to re-create deleted entry '%s': %v", name, err)
+	}
+}
+
+func testList(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	names := []string{prefix + "key-1", prefix + "key-2", prefix + "key-3"}
+	for _, name := range names {
+		if err := store.Create(ctx, name, []byte("value")); err != nil {
+			t.Fatalf("Failed to create '%s': %v", name, err)
+		}
+	}
+
+	list, continueAt, err := store.List(ctx, prefix, -1)
+	if err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	if !slices.Equal(list, names) {
+		t.Fatalf("Invalid listing: got '%v' - want '%v'", list, names)
+	}
+	if continueAt != "" {
+		t.Fatalf("Invalid listing: got continue at '%s' - want ''", continueAt)
+	}
+
+	if list, _, err = store.List(ctx, prefix+"key-2", -1); err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	if !slices.Equal(list, names[1:2]) {
+		t.Fatalf("Invalid listing: got '%v' - want '%v'", list, names[1:2])
+	}
+
+	if list, _, err = store.List(ctx, prefix, 2); err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	if len(list) > 2 || !slices.Equal(list, names[:len(list)]) {
+		t.Fatalf("Invalid listing: got '%v' - want at most '%v'", list, names[:2])
+	}
+}
+
+// run runs the test function with a new KeyStore and a random
+// entry name prefix. It deletes all entries starting with the
+// prefix and closes the KeyStore once the test function returns.
+func run(t *testing.T, newStore NewFunc, f func(context.Context, *testing.T, kes.KeyStore, string)) {
+	ctx := context.Background()
+	if deadline, ok := t.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	store, err := newStore(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create KeyStore: %v", err)
+	}
+	defer store.Close()
+
+	var random [8]byte
+	if _, err = rand.Read(random[:]); err != nil {
+		t.Fatalf("Failed to generate entry prefix: %v", err)
+	}
+	prefix := "keystoretest-" + hex.EncodeToString(random[:]) + "-"
+	defer clean(ctx, t, store, prefix)
+
+	f(ctx, t, store, prefix)
+}
+
+// clean deletes all entries starting with the prefix.
+func clean(ctx context.Context, t *testing.T, store kes.KeyStore, prefix string) {
+	names, _, err := store.List(ctx, prefix, -1)
+	if err != nil {
+		t.Errorf("Cleanup: failed to list: %v", err)
+		return
+	}
+	for _, name := range names {
+		if err := store.Delete(ctx, name); err != nil && !errors.Is(err, kesdk.ErrKeyNotFound) {
+			t.Errorf("Cleanup: failed to delete '%s': %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package keystoretest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/keystore/fs"
+	"github.com/minio/kes/keystoretest"
+)
+
+func TestMemKeyStore(t *testing.T) {
+	keystoretest.TestKeyStore(t, func(context.Context) (kes.KeyStore, error) {
+		return &kes.MemKeyStore{}, nil
+	})
+}
+
+func TestFSKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	keystoretest.TestKeyStore(t, func(context.Context) (kes.KeyStore, error) {
+		return fs.NewStore(dir)
+	})
+}
+
+func TestFaultKeyStore(t *testing.T) {
+	faults := &kes.FaultInjector{}
+	if err := faults.Inject(kes.Fault{Latency: time.Millisecond}); err != nil {
+		t.Fatalf("Failed to inject fault: %v", err)
+	}
+	keystoretest.TestKeyStore(t, func(context.Context) (kes.KeyStore, error) {
+		return faults.Wrap(&kes.MemKeyStore{}), nil
+	})
+}
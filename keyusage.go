@@ -0,0 +1,130 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/minio/kes/internal/api"
+)
+
+// Operations a key can be restricted to. A key created with a set
+// of operations can only be used for these operations. Keys created
+// without any operations can be used for all of them.
+const (
+	keyOpEncrypt  = "encrypt"  // Encrypt data, including batches, streams and deterministic encryption
+	keyOpDecrypt  = "decrypt"  // Decrypt data, including batches, streams and deterministic decryption
+	keyOpGenerate = "generate" // Generate data encryption keys
+	keyOpUnwrap   = "unwrap"   // Unwrap keys, including token sessions
+	keyOpHMAC     = "hmac"     // Compute and verify HMACs
+	keyOpSign     = "sign"     // Sign and verify messages, including token sessions
+	keyOpDerive   = "derive"   // Derive sub-keys
+)
+
+// keyOperations are all operations a key can be restricted to.
+var keyOperations = []string{keyOpEncrypt, keyOpDecrypt, keyOpGenerate, keyOpUnwrap, keyOpHMAC, keyOpSign, keyOpDerive}
+
+var (
+	errOperationsNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support restricting key operations")
+	errOperationNotAllowed    = api.NewError(http.StatusForbidden, "key must not be used for this operation")
+)
+
+// validKeyOperations returns an error if ops contains an unknown
+// or duplicate operation.
+func validKeyOperations(ops []string) error {
+	for i, op := range ops {
+		if !slices.Contains(keyOperations, op) {
+			return fmt.Errorf("invalid key operation '%s'", op)
+		}
+		if slices.Contains(ops[:i], op) {
+			return fmt.Errorf("duplicate key operation '%s'", op)
+		}
+	}
+	return nil
+}
+
+// SetOperations restricts the key with the given name to the given
+// operations. It returns errOperationsNotSupported if the KeyStore
+// does not implement MetadataKeyStore and kes.ErrKeyNotFound if no
+// such key exists.
+//
+// The key is evicted from the cache such that the restriction
+// applies to all subsequent operations.
+func (c *keyCache) SetOperations(ctx context.Context, name string, ops []string) error {
+	err := c.updateMetadata(ctx, name, errOperationsNotSupported, func(m *EntryMetadata) { m.Operations = ops })
+	c.cache.Delete(name)
+	return err
+}
+
+// allows reports whether the key with the given name may be used
+// for the operation. Keys of a KeyStore that does not implement
+// MetadataKeyStore, and keys of a CryptoKeyStore, cannot be
+// restricted and may be used for any operation.
+//
+// Keys created with operations are not usable until their operations
+// have been stored. See CreateWithMetadata.
+func (c *keyCache) allows(ctx context.Context, name, op string) (bool, error) {
+	if _, ok := c.store.(MetadataKeyStore); !ok || !c.SupportsMetadata() {
+		return true, nil
+	}
+	entry, err := c.entry(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return len(entry.Operations) == 0 || slices.Contains(entry.Operations, op), nil
+}
+
+// restrictKeyUsage returns a Handler that only passes requests to h
+// if the key named by the request resource may be used for the
+// operation. Otherwise, it responds with errOperationNotAllowed.
+func (s *Server) restrictKeyUsage(op string, h api.Handler) api.Handler {
+	return s.restrictKeyUsageOf(op, func(req *api.Request) (*keyCache, string) {
+		return s.state.Load().enclave(req).Keys, req.Resource
+	}, h)
+}
+
+// restrictProfileKeyUsage returns a Handler that only passes requests
+// to h if the key of the CA or SSH CA profile named by the request
+// resource may be used for the operation. Profile keys belong to the
+// default enclave.
+func (s *Server) restrictProfileKeyUsage(op string, profileKey func(*serverState, string) (string, bool), h api.Handler) api.Handler {
+	return s.restrictKeyUsageOf(op, func(req *api.Request) (*keyCache, string) {
+		state := s.state.Load()
+		name, _ := profileKey(state, req.Resource)
+		return state.Keys, name
+	}, h)
+}
+
+// restrictKeyUsageOf returns a Handler that only passes requests to
+// h if the key returned by key may be used for the operation. If the
+// key name is invalid, e.g. since the request refers to no key, the
+// request is passed to h, which rejects it.
+func (s *Server) restrictKeyUsageOf(op string, key func(*api.Request) (*keyCache, string), h api.Handler) api.Handler {
+	return api.HandlerFunc(func(resp *api.Response, req *api.Request) {
+		keys, name := key(req)
+		if !validName(name) {
+			h.ServeAPI(resp, req) // Let the handler reject the request
+			return
+		}
+
+		ok, err := keys.allows(req.Context(), name, op)
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+			s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+			return
+		}
+		if !ok {
+			resp.Failr(errOperationNotAllowed)
+			return
+		}
+		h.ServeAPI(resp, req)
+	})
+}
@@ -0,0 +1,341 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/kmip"
+)
+
+const (
+	kmipMaxMessageSize = 1 << 20
+	kmipIdleTimeout    = 90 * time.Second
+	kmipWriteTimeout   = 15 * time.Second
+)
+
+// serveKMIP accepts KMIP connections on ln until ln
+// is closed. Connections are closed once ctx is done.
+//
+// KMIP operations are served by the corresponding HTTP
+// API. Hence, they are authenticated, authorized, rate
+// limited and audited the same way.
+func (s *Server) serveKMIP(ctx context.Context, ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleKMIP(ctx, conn)
+	}
+}
+
+func (s *Server) handleKMIP(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(kmipIdleTimeout))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		s.logTLSHandshakeError(ctx, tlsConn, err)
+		return
+	}
+	state := tlsConn.ConnectionState()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(kmipIdleTimeout))
+		msg, err := kmip.Read(conn, kmipMaxMessageSize)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.state.Load().Log.DebugContext(ctx, fmt.Sprintf("kes: failed to read KMIP request: %v", err), "remote", conn.RemoteAddr())
+			if errors.Is(err, kmip.ErrTooLarge) {
+				s.writeKMIP(conn, kmip.Response(kmip.Versions[0], time.Now(), kmip.Result{
+					Err: &kmip.Error{Reason: kmip.ReasonInvalidMessage, Message: "request too large"},
+				}))
+			}
+			return
+		}
+
+		req, err := kmip.ParseRequest(msg)
+		if err != nil {
+			var kErr *kmip.Error
+			if errors.As(err, &kErr) {
+				s.writeKMIP(conn, kmip.Response(kmip.Versions[0], time.Now(), kmip.Result{Err: kErr}))
+			}
+			return
+		}
+
+		results := make([]kmip.Result, 0, len(req.Items))
+		for _, item := range req.Items {
+			payload, err := s.kmipCall(ctx, &state, conn.RemoteAddr(), item)
+			results = append(results, kmip.Result{
+				Operation: item.Operation,
+				ID:        item.ID,
+				Payload:   payload,
+				Err:       err,
+			})
+		}
+		if err = s.writeKMIP(conn, kmip.Response(req.Version, time.Now(), results...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) writeKMIP(conn net.Conn, msg kmip.Item) error {
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		s.state.Load().Log.Error(fmt.Sprintf("kes: failed to encode KMIP response: %v", err))
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(kmipWriteTimeout))
+	_, err = conn.Write(b)
+	return err
+}
+
+// kmipCall executes one KMIP batch item. It returns the
+// response payload or an error.
+func (s *Server) kmipCall(ctx context.Context, state *tls.ConnectionState, addr net.Addr, item kmip.BatchItem) ([]kmip.Item, *kmip.Error) {
+	call := func(method, path string, body, resp any) *kmip.Error {
+		return s.kmipHTTP(ctx, state, addr, method, path, body, resp)
+	}
+
+	switch item.Operation {
+	case kmip.OpDiscoverVersions:
+		var (
+			versions  []kmip.Item
+			requested = item.Payload.FindAll(kmip.TagProtocolVersion)
+		)
+		for _, version := range kmip.Versions {
+			if len(requested) == 0 {
+				versions = append(versions, version.Item())
+				continue
+			}
+			for _, v := range requested {
+				major, _ := v.Find(kmip.TagProtocolVersionMajor)
+				minor, _ := v.Find(kmip.TagProtocolVersionMinor)
+				if m, _ := major.Int(); m == version.Major {
+					if n, _ := minor.Int(); n == version.Minor {
+						versions = append(versions, version.Item())
+					}
+				}
+			}
+		}
+		return versions, nil
+
+	case kmip.OpCreate:
+		objectType, _ := item.Payload.Find(kmip.TagObjectType)
+		if t, ok := objectType.Enum(); !ok || t != kmip.ObjectTypeSymmetricKey {
+			return nil, &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "only symmetric keys are supported"}
+		}
+		if algorithm, ok := kmipAttribute(item.Payload, kmip.TagCryptographicAlgorithm, "Cryptographic Algorithm"); ok {
+			if a, ok := algorithm.Enum(); !ok || a != kmip.AlgorithmAES {
+				return nil, &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "only AES keys are supported"}
+			}
+		}
+		if length, ok := kmipAttribute(item.Payload, kmip.TagCryptographicLength, "Cryptographic Length"); ok {
+			if l, ok := length.Int(); !ok || l != 256 {
+				return nil, &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "only 256 bit keys are supported"}
+			}
+		}
+		name, ok := kmipAttribute(item.Payload, kmip.TagName, "Name")
+		if !ok {
+			return nil, &kmip.Error{Reason: kmip.ReasonMissingData, Message: "missing key name"}
+		}
+		nameValue, _ := name.Find(kmip.TagNameValue)
+		keyName, ok := nameValue.Text()
+		if !ok || !validName(keyName) {
+			return nil, &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "invalid key name"}
+		}
+
+		if err := call(http.MethodPut, api.PathKeyCreate+keyName, nil, nil); err != nil {
+			return nil, err
+		}
+		return []kmip.Item{
+			kmip.Enum(kmip.TagObjectType, kmip.ObjectTypeSymmetricKey),
+			kmip.Text(kmip.TagUniqueIdentifier, keyName),
+		}, nil
+
+	case kmip.OpGet:
+		keyName, err := kmipKeyName(item.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if err = call(http.MethodGet, api.PathKeyDescribe+keyName, nil, nil); err != nil {
+			return nil, err
+		}
+		// KES never reveals plaintext key material to clients. Keys
+		// can only be exported wrapped by a client transport key via
+		// the export API.
+		return nil, &kmip.Error{Reason: kmip.ReasonPermissionDenied, Message: fmt.Sprintf("key '%s' is not extractable", keyName)}
+
+	case kmip.OpDestroy:
+		keyName, err := kmipKeyName(item.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if err = call(http.MethodDelete, api.PathKeyDelete+keyName, nil, nil); err != nil {
+			return nil, err
+		}
+		return []kmip.Item{kmip.Text(kmip.TagUniqueIdentifier, keyName)}, nil
+
+	case kmip.OpEncrypt, kmip.OpDecrypt:
+		keyName, err := kmipKeyName(item.Payload)
+		if err != nil {
+			return nil, err
+		}
+		dataItem, ok := item.Payload.Find(kmip.TagData)
+		if !ok {
+			return nil, &kmip.Error{Reason: kmip.ReasonMissingData, Message: "missing data"}
+		}
+		data, ok := dataItem.Bytes()
+		if !ok {
+			return nil, &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "invalid data"}
+		}
+		var associatedData []byte
+		if aad, ok := item.Payload.Find(kmip.TagAuthenticatedEncryptionAAD); ok {
+			associatedData, _ = aad.Bytes()
+		}
+
+		if item.Operation == kmip.OpEncrypt {
+			var resp api.EncryptKeyResponse
+			err = call(http.MethodPut, api.PathKeyEncrypt+keyName, api.EncryptKeyRequest{
+				Plaintext: data,
+				Context:   associatedData,
+			}, &resp)
+			data = resp.Ciphertext
+		} else {
+			var resp api.DecryptKeyResponse
+			err = call(http.MethodPut, api.PathKeyDecrypt+keyName, api.DecryptKeyRequest{
+				Ciphertext: data,
+				Context:    associatedData,
+			}, &resp)
+			data = resp.Plaintext
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []kmip.Item{
+			kmip.Text(kmip.TagUniqueIdentifier, keyName),
+			kmip.Bytes(kmip.TagData, data),
+		}, nil
+
+	default:
+		return nil, &kmip.Error{Reason: kmip.ReasonOperationNotSupported, Message: fmt.Sprintf("operation '%#x' is not supported", uint32(item.Operation))}
+	}
+}
+
+// kmipHTTP serves a KMIP operation by calling the HTTP API with
+// the given method and path. If body is not nil, it is sent as
+// JSON request body. If resp is not nil, the JSON response body
+// is decoded into it.
+func (s *Server) kmipHTTP(ctx context.Context, state *tls.ConnectionState, addr net.Addr, method, path string, body, resp any) *kmip.Error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return &kmip.Error{Reason: kmip.ReasonGeneralFailure, Message: "failed to encode request"}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(reqBody))
+	if err != nil {
+		return &kmip.Error{Reason: kmip.ReasonInvalidField, Message: fmt.Sprintf("invalid request: %v", err)}
+	}
+	req.RequestURI = path
+	req.TLS = state
+	req.RemoteAddr = addr.String()
+	if body != nil {
+		req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+	}
+
+	w := &bufferedResponseWriter{header: http.Header{}}
+	s.handler.Load().ServeHTTP(w, req)
+
+	if code := w.statusCode(); code != http.StatusOK {
+		return &kmip.Error{Reason: kmipReason(code), Message: w.message()}
+	}
+	if resp != nil {
+		if err = json.Unmarshal(w.body.Bytes(), resp); err != nil {
+			return &kmip.Error{Reason: kmip.ReasonGeneralFailure, Message: "failed to decode response"}
+		}
+	}
+	return nil
+}
+
+// kmipKeyName returns the unique identifier of the payload.
+// The KMIP API uses key names as unique identifiers.
+func kmipKeyName(payload kmip.Item) (string, *kmip.Error) {
+	id, ok := payload.Find(kmip.TagUniqueIdentifier)
+	if !ok {
+		return "", &kmip.Error{Reason: kmip.ReasonMissingData, Message: "missing unique identifier"}
+	}
+	name, ok := id.Text()
+	if !ok || !validName(name) {
+		return "", &kmip.Error{Reason: kmip.ReasonInvalidField, Message: "invalid unique identifier"}
+	}
+	return name, nil
+}
+
+// kmipAttribute returns the attribute of a Create request payload.
+// KMIP 2.x requests contain an Attributes structure with the attribute
+// tag while KMIP 1.x requests contain a TemplateAttribute structure
+// with named attributes.
+func kmipAttribute(payload kmip.Item, tag kmip.Tag, name string) (kmip.Item, bool) {
+	if attributes, ok := payload.Find(kmip.TagAttributes); ok {
+		return attributes.Find(tag)
+	}
+	template, _ := payload.Find(kmip.TagTemplateAttribute)
+	for _, attribute := range template.FindAll(kmip.TagAttribute) {
+		attrName, _ := attribute.Find(kmip.TagAttributeName)
+		if s, _ := attrName.Text(); s == name {
+			return attribute.Find(kmip.TagAttributeValue)
+		}
+	}
+	return kmip.Item{}, false
+}
+
+// kmipReason returns the KMIP result reason corresponding
+// to the HTTP status code.
+func kmipReason(code int) kmip.ResultReason {
+	switch code {
+	case http.StatusBadRequest:
+		return kmip.ReasonInvalidField
+	case http.StatusUnauthorized:
+		return kmip.ReasonAuthenticationNotSuccessful
+	case http.StatusForbidden:
+		return kmip.ReasonPermissionDenied
+	case http.StatusNotFound:
+		return kmip.ReasonItemNotFound
+	case http.StatusRequestEntityTooLarge:
+		return kmip.ReasonInvalidMessage
+	default:
+		return kmip.ReasonGeneralFailure
+	}
+}
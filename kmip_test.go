@@ -0,0 +1,152 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/kmip"
+)
+
+func TestKMIP(t *testing.T) {
+	t.Parallel()
+
+	const Name = "my-key"
+
+	ln := newLocalListener()
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx := testContext(t)
+	srv, _ := startServer(ctx, &Config{
+		KMIP: &KMIPConfig{Address: addr},
+	})
+	defer srv.Close()
+
+	var (
+		conn *tls.Conn
+		err  error
+	)
+	for i := 0; i < 100; i++ { // The KMIP listener is started asynchronously
+		if conn, err = tls.Dial("tcp", addr, defaultClientTLSConfig()); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Failed to connect to KMIP server: %v", err)
+	}
+	defer conn.Close()
+
+	call := func(op kmip.Operation, payload ...kmip.Item) kmip.Item {
+		req := kmip.Struct(kmip.TagRequestMessage,
+			kmip.Struct(kmip.TagRequestHeader,
+				kmip.ProtocolVersion{Major: 1, Minor: 4}.Item(),
+				kmip.Int(kmip.TagBatchCount, 1),
+			),
+			kmip.Struct(kmip.TagBatchItem,
+				kmip.Enum(kmip.TagOperation, uint32(op)),
+				kmip.Struct(kmip.TagRequestPayload, payload...),
+			),
+		)
+		b, err := req.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to encode KMIP request: %v", err)
+		}
+		if _, err = conn.Write(b); err != nil {
+			t.Fatalf("Failed to send KMIP request: %v", err)
+		}
+		resp, err := kmip.Read(conn, kmipMaxMessageSize)
+		if err != nil {
+			t.Fatalf("Failed to read KMIP response: %v", err)
+		}
+		item, ok := resp.Find(kmip.TagBatchItem)
+		if !ok {
+			t.Fatal("Invalid KMIP response: no batch item")
+		}
+		return item
+	}
+	reason := func(item kmip.Item) kmip.ResultReason {
+		status, _ := item.Find(kmip.TagResultStatus)
+		if s, _ := status.Enum(); kmip.ResultStatus(s) == kmip.StatusSuccess {
+			return 0
+		}
+		reason, _ := item.Find(kmip.TagResultReason)
+		r, _ := reason.Enum()
+		return kmip.ResultReason(r)
+	}
+	payload := func(item kmip.Item, tag kmip.Tag) kmip.Item {
+		payload, _ := item.Find(kmip.TagResponsePayload)
+		v, ok := payload.Find(tag)
+		if !ok {
+			t.Fatalf("Invalid KMIP response: missing item '%#06x'", tag)
+		}
+		return v
+	}
+
+	create := []kmip.Item{
+		kmip.Enum(kmip.TagObjectType, kmip.ObjectTypeSymmetricKey),
+		kmip.Struct(kmip.TagTemplateAttribute,
+			kmip.Struct(kmip.TagAttribute,
+				kmip.Text(kmip.TagAttributeName, "Cryptographic Algorithm"),
+				kmip.Enum(kmip.TagAttributeValue, kmip.AlgorithmAES),
+			),
+			kmip.Struct(kmip.TagAttribute,
+				kmip.Text(kmip.TagAttributeName, "Name"),
+				kmip.Struct(kmip.TagAttributeValue,
+					kmip.Text(kmip.TagNameValue, Name),
+					kmip.Enum(kmip.TagNameType, kmip.NameTypeText),
+				),
+			),
+		),
+	}
+	resp := call(kmip.OpCreate, create...)
+	if r := reason(resp); r != 0 {
+		t.Fatalf("Failed to create key: result reason '%#x'", r)
+	}
+	if id, _ := payload(resp, kmip.TagUniqueIdentifier).Text(); id != Name {
+		t.Fatalf("Invalid unique identifier: got '%s' - want '%s'", id, Name)
+	}
+	if r := reason(call(kmip.OpCreate, create...)); r != kmip.ReasonInvalidField {
+		t.Fatalf("Creating existing key: got result reason '%#x' - want '%#x'", r, kmip.ReasonInvalidField)
+	}
+
+	plaintext := []byte("Hello World")
+	resp = call(kmip.OpEncrypt,
+		kmip.Text(kmip.TagUniqueIdentifier, Name),
+		kmip.Bytes(kmip.TagData, plaintext),
+	)
+	if r := reason(resp); r != 0 {
+		t.Fatalf("Failed to encrypt plaintext: result reason '%#x'", r)
+	}
+	ciphertext, _ := payload(resp, kmip.TagData).Bytes()
+
+	resp = call(kmip.OpDecrypt,
+		kmip.Text(kmip.TagUniqueIdentifier, Name),
+		kmip.Bytes(kmip.TagData, ciphertext),
+	)
+	if r := reason(resp); r != 0 {
+		t.Fatalf("Failed to decrypt ciphertext: result reason '%#x'", r)
+	}
+	if p, _ := payload(resp, kmip.TagData).Bytes(); !bytes.Equal(p, plaintext) {
+		t.Fatalf("Plaintext mismatch: got '%s' - want '%s'", p, plaintext)
+	}
+
+	if r := reason(call(kmip.OpGet, kmip.Text(kmip.TagUniqueIdentifier, Name))); r != kmip.ReasonPermissionDenied {
+		t.Fatalf("Get key: got result reason '%#x' - want '%#x'", r, kmip.ReasonPermissionDenied)
+	}
+	if r := reason(call(kmip.OpDestroy, kmip.Text(kmip.TagUniqueIdentifier, Name))); r != 0 {
+		t.Fatalf("Failed to destroy key: result reason '%#x'", r)
+	}
+	if r := reason(call(kmip.OpGet, kmip.Text(kmip.TagUniqueIdentifier, Name))); r != kmip.ReasonItemNotFound {
+		t.Fatalf("Get destroyed key: got result reason '%#x' - want '%#x'", r, kmip.ReasonItemNotFound)
+	}
+	if r := reason(call(kmip.Operation(0x18))); r != kmip.ReasonOperationNotSupported {
+		t.Fatalf("Unsupported operation: got result reason '%#x' - want '%#x'", r, kmip.ReasonOperationNotSupported)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+)
+
+// peerNotifier notifies the peers of a KES server about keys that
+// have been created, rotated or deleted such that the peers can
+// evict these keys from their caches.
+//
+// Notifications are best effort. They are sent asynchronously and
+// are not retried. Peers that miss a notification evict the key once
+// its cache entry expires.
+type peerNotifier struct {
+	endpoints []string
+	client    *http.Client
+	log       *slog.Logger
+}
+
+// newPeerNotifier returns a new peerNotifier that sends notifications
+// to the peers in conf. It returns nil if conf contains no peers.
+func newPeerNotifier(conf *PeerConfig, log *slog.Logger) *peerNotifier {
+	if conf == nil || len(conf.Endpoints) == 0 {
+		return nil
+	}
+	return &peerNotifier{
+		endpoints: conf.Endpoints,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   conf.TLS.Clone(),
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   90 * time.Second,
+			},
+			Timeout: 10 * time.Second,
+		},
+		log: log,
+	}
+}
+
+// attach makes the key cache notify the peers whenever a key of
+// the given enclave is created, rotated or deleted. It must be
+// called before the key cache is used.
+func (p *peerNotifier) attach(keys *keyCache, enclave string) {
+	if p == nil {
+		return
+	}
+	keys.notify = func(event KeyStoreEvent) { p.Notify(enclave, event) }
+}
+
+// attachAll attaches the key caches of the state's default enclave
+// and all other enclaves.
+func (p *peerNotifier) attachAll(state *serverState) {
+	p.attach(state.Keys, "")
+	for name, enclave := range state.Enclaves {
+		p.attach(enclave.Keys, name)
+	}
+}
+
+// Notify sends the event to all peers. It does not wait for
+// the peers to respond.
+func (p *peerNotifier) Notify(enclave string, event KeyStoreEvent) {
+	body, err := json.Marshal(api.PeerNotifyRequest{
+		Event: event.Type.String(),
+	})
+	if err != nil {
+		p.log.Warn(fmt.Sprintf("failed to notify peers: %v", err))
+		return
+	}
+	for _, endpoint := range p.endpoints {
+		go p.send(endpoint, enclave, event.Name, body)
+	}
+}
+
+func (p *peerNotifier) send(endpoint, enclave, name string, body []byte) {
+	endpoint = strings.TrimSuffix(endpoint, "/") + api.PathPeerNotify + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		p.log.Warn(fmt.Sprintf("failed to notify peer: %v", err), "peer", endpoint)
+		return
+	}
+	req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+	if enclave != "" {
+		req.Header.Set(headers.KesEnclave, enclave)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.log.Warn(fmt.Sprintf("failed to notify peer: %v", err), "peer", endpoint)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		p.log.Warn(fmt.Sprintf("failed to notify peer: %s", resp.Status), "peer", endpoint)
+	}
+}
+
+// Close closes idle connections to the peers.
+func (p *peerNotifier) Close() {
+	if p != nil {
+		p.client.CloseIdleConnections()
+	}
+}
+
+// parseKeyStoreEventType parses s as KeyStoreEventType.
+func parseKeyStoreEventType(s string) (KeyStoreEventType, bool) {
+	for _, t := range []KeyStoreEventType{EntryCreated, EntryUpdated, EntryDeleted} {
+		if t.String() == s {
+			return t, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/minio/kes/internal/api"
+)
+
+const (
+	// maxRandomSize is the max. number of random bytes a client
+	// can request at once.
+	maxRandomSize = 1024
+
+	// defaultRandomRateLimit is the number of random requests per
+	// second each identity can send if the API has no rate limit.
+	defaultRandomRateLimit = 10
+)
+
+// random is a HandlerFunc that sends cryptographically secure
+// random bytes to the client. The resource is the number of bytes.
+//
+// Since the size is part of the API path, policies can limit how
+// many bytes an identity may request. For example, a policy that
+// only allows "/v1/random/32" restricts an identity to 32 bytes
+// per request. Requests are limited to defaultRandomRateLimit per
+// second and identity unless the API has custom rate limits.
+func (s *Server) random(resp *api.Response, req *api.Request) {
+	size, err := strconv.Atoi(req.Resource)
+	if err != nil || size <= 0 || size > maxRandomSize {
+		resp.Failf(http.StatusBadRequest, "invalid size '%s': must be between 1 and %d bytes", req.Resource, maxRandomSize)
+		return
+	}
+
+	bytes := make([]byte, size)
+	if _, err = rand.Read(bytes); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to generate random bytes")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("generated %d random bytes", size),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.RandomResponse{
+		Bytes: bytes,
+	})
+}
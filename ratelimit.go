@@ -0,0 +1,88 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter is an api.Handler that rejects requests with HTTP
+// 429 Too Many Requests once the clients exceed a rate limit.
+// Requests are limited for all clients combined and for each
+// identity individually.
+type rateLimiter struct {
+	h       api.Handler
+	metrics *metric.Metrics
+
+	limiter       *rate.Limiter // nil if not rate limited
+	identityLimit float64       // <= 0 if not rate limited
+
+	mu         sync.Mutex
+	identities map[kes.Identity]*rate.Limiter
+}
+
+// newRateLimiter returns a new rateLimiter that accepts at most
+// limit requests per second from all clients and identityLimit
+// requests per second from each identity. A limit <= 0 disables
+// the respective rate limit.
+func newRateLimiter(h api.Handler, limit, identityLimit float64, metrics *metric.Metrics) *rateLimiter {
+	l := &rateLimiter{
+		h:             h,
+		metrics:       metrics,
+		identityLimit: identityLimit,
+		identities:    map[kes.Identity]*rate.Limiter{},
+	}
+	if limit > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(limit), burst(limit))
+	}
+	return l
+}
+
+// ServeAPI passes the request to the underlying handler if it
+// does not exceed the rate limits.
+func (l *rateLimiter) ServeAPI(resp *api.Response, req *api.Request) {
+	// The identity limit is checked first such that a noisy client
+	// does not consume the tokens shared by all clients.
+	if !l.allow(req.Identity) || (l.limiter != nil && !l.limiter.Allow()) {
+		l.metrics.CountThrottled()
+
+		resp.Header().Set(headers.RetryAfter, "1")
+		resp.Fail(http.StatusTooManyRequests, "too many requests")
+		return
+	}
+	l.h.ServeAPI(resp, req)
+}
+
+// allow reports whether the identity has not exceeded its rate
+// limit, if any.
+func (l *rateLimiter) allow(identity kes.Identity) bool {
+	if l.identityLimit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	limiter, ok := l.identities[identity]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.identityLimit), burst(l.identityLimit))
+		l.identities[identity] = limiter
+	}
+	l.mu.Unlock()
+
+	return limiter.Allow()
+}
+
+// burst returns the burst size for the given rate limit. It
+// allows a client to send up to one second of requests at once.
+func burst(limit float64) int {
+	return max(1, int(math.Ceil(limit)))
+}
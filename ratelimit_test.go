@@ -0,0 +1,54 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	ok := api.HandlerFunc(func(resp *api.Response, _ *api.Request) { resp.WriteHeader(http.StatusOK) })
+	serve := func(h api.Handler, identity kes.Identity) int {
+		w := httptest.NewRecorder()
+		h.ServeAPI(&api.Response{ResponseWriter: w}, &api.Request{
+			Request:  httptest.NewRequest(http.MethodPut, api.PathKeyCreate+"my-key", nil),
+			Identity: identity,
+		})
+		return w.Code
+	}
+
+	// Each identity may send 2 requests at once.
+	l := newRateLimiter(ok, 0, 2, metric.New())
+	for i := 0; i < 2; i++ {
+		if code := serve(l, "identity-1"); code != http.StatusOK {
+			t.Fatalf("Request %d: got status '%d' - want '%d'", i, code, http.StatusOK)
+		}
+	}
+	if code := serve(l, "identity-1"); code != http.StatusTooManyRequests {
+		t.Fatalf("Identity rate limit exceeded: got status '%d' - want '%d'", code, http.StatusTooManyRequests)
+	}
+	if code := serve(l, "identity-2"); code != http.StatusOK {
+		t.Fatalf("Other identity is rate limited: got status '%d' - want '%d'", code, http.StatusOK)
+	}
+
+	// All identities may send 3 requests at once.
+	l = newRateLimiter(ok, 3, 2, metric.New())
+	for _, identity := range []kes.Identity{"identity-1", "identity-1", "identity-2"} {
+		if code := serve(l, identity); code != http.StatusOK {
+			t.Fatalf("Request of '%s': got status '%d' - want '%d'", identity, code, http.StatusOK)
+		}
+	}
+	if code := serve(l, "identity-3"); code != http.StatusTooManyRequests {
+		t.Fatalf("Rate limit exceeded: got status '%d' - want '%d'", code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+// ReadReplicaConfig is a structure containing the configuration of
+// a KES server running as read replica.
+//
+// A read replica serves keys from a read-only copy of the primary
+// KeyStore that is kept in sync outside the KES server, for example
+// a replicated SQL database or a periodically copied snapshot at a
+// disaster recovery site. It only serves operations that read keys,
+// like describing, listing, decrypting and unwrapping. All other
+// operations, like creating, rotating or deleting keys, but also
+// encrypting data, are rejected.
+//
+// Since the copy of the KeyStore changes without the KES server
+// noticing, a read replica discards all cached keys and data keys
+// periodically such that it serves keys created, rotated or deleted
+// at the primary site.
+type ReadReplicaConfig struct {
+	// SyncInterval is the interval at which the read replica
+	// discards its cached keys and data keys. It should match
+	// the interval at which the copy of the KeyStore gets
+	// synced. If 0, it defaults to 1 minute.
+	SyncInterval time.Duration
+}
+
+const defaultReadReplicaSyncInterval = 1 * time.Minute
+
+// errReadReplica is returned by a read replica for all operations
+// that may modify keys or produce new ciphertexts.
+var errReadReplica = api.NewError(http.StatusNotImplemented, "server is a read replica")
+
+// readReplicaPaths are the API paths served by a read replica.
+var readReplicaPaths = []string{
+	api.PathVersion,
+	api.PathStatus,
+	api.PathReady,
+	api.PathMetrics,
+	api.PathListAPIs,
+	api.PathConfig,
+	api.PathUnseal,
+	api.PathRandom,
+	api.PathJWKS,
+	api.PathCACertificate,
+	api.PathSSHCA,
+
+	api.PathKeyDescribe,
+	api.PathKeyList,
+	api.PathKeyExport,
+	api.PathKeyDecrypt,
+	api.PathKeyDecryptBatch,
+	api.PathKeyDecryptStream,
+	api.PathKeyDecryptDet,
+	api.PathKeyDecryptFPE,
+	api.PathKeyDecryptJWE,
+	api.PathKeyHMACVerify,
+	api.PathKeyDerive,
+	api.PathKeyPublic,
+	api.PathKeyVerify,
+	api.PathKeyUnwrap,
+
+	api.PathTokenOpen,
+	api.PathTokenClose,
+	api.PathTokenUnwrap,
+
+	api.PathSecretRead,
+	api.PathSecretList,
+
+	api.PathPolicyDescribe,
+	api.PathPolicyRead,
+	api.PathPolicyList,
+
+	api.PathIdentityDescribe,
+	api.PathIdentityList,
+	api.PathIdentitySelfDescribe,
+
+	api.PathPeerNotify,
+
+	api.PathLogError,
+	api.PathLogAudit,
+
+	api.PathDebugPprof,
+	api.PathDebugRuntime,
+}
+
+// readReplicaRoutes returns a ServeMux that only serves the API
+// paths of a read replica. All other requests fail with
+// errReadReplica.
+func readReplicaRoutes(routes map[string]api.Route) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, path := range readReplicaPaths {
+		if route, ok := routes[path]; ok {
+			mux.Handle(path, route)
+		}
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		api.Failr(&api.Response{ResponseWriter: w}, errReadReplica)
+	})
+	return mux
+}
+
+// ReadReplica reports whether the server is a read replica.
+// See ReadReplicaConfig.
+func (s *Server) ReadReplica() bool {
+	state := s.state.Load()
+	return state != nil && state.ReadReplica != nil
+}
+
+// syncReadReplica periodically discards all cached keys and data
+// keys of a read replica until ctx.Done() returns.
+func (s *Server) syncReadReplica(ctx context.Context) {
+	for {
+		interval := defaultReadReplicaSyncInterval
+		if conf := s.state.Load().ReadReplica; conf != nil && conf.SyncInterval > 0 {
+			interval = conf.SyncInterval
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		state := s.state.Load()
+		if state.ReadReplica == nil {
+			continue
+		}
+		state.Keys.evictAll()
+		for _, enclave := range state.Enclaves {
+			enclave.Keys.evictAll()
+		}
+	}
+}
+
+// evictAll removes all keys and data keys from the cache.
+func (c *keyCache) evictAll() {
+	c.cache.DeleteAll()
+	if c.deks != nil {
+		c.deks.DeleteAll()
+	}
+}
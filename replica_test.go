@@ -0,0 +1,81 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestReadReplica(t *testing.T) {
+	const Name = "my-key"
+	ctx := testContext(t)
+
+	// The primary and the read replica share a KeyStore to
+	// simulate a copy of the primary KeyStore kept in sync.
+	store := struct{ KeyStore }{&MemKeyStore{}} // Hide that MemKeyStore is watchable
+	primary, primaryURL := startServer(ctx, &Config{Keys: store})
+	defer primary.Close()
+
+	replica, replicaURL := startServer(ctx, &Config{
+		Keys:        store,
+		ReadReplica: &ReadReplicaConfig{SyncInterval: 10 * time.Millisecond},
+	})
+	defer replica.Close()
+
+	if !replica.ReadReplica() {
+		t.Fatal("Server is not a read replica")
+	}
+
+	client, replicaClient := defaultClient(primaryURL), defaultClient(replicaURL)
+	if err := client.CreateKey(ctx, Name); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", Name, err)
+	}
+	ciphertext, err := client.Encrypt(ctx, Name, []byte("Hello World"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+
+	if _, err = replicaClient.DescribeKey(ctx, Name); err != nil {
+		t.Fatalf("Failed to describe key on read replica: %v", err)
+	}
+	if _, err = replicaClient.Decrypt(ctx, Name, ciphertext, nil); err != nil {
+		t.Fatalf("Failed to decrypt ciphertext on read replica: %v", err)
+	}
+	if err = replicaClient.CreateKey(ctx, "my-key-2"); !isStatus(err, http.StatusNotImplemented) {
+		t.Fatalf("Creating key on read replica: got '%v' - want '%v'", err, errReadReplica)
+	}
+	if _, err = replicaClient.Encrypt(ctx, Name, []byte("Hello World"), nil); !isStatus(err, http.StatusNotImplemented) {
+		t.Fatalf("Encrypting on read replica: got '%v' - want '%v'", err, errReadReplica)
+	}
+	if err = replicaClient.DeleteKey(ctx, Name); !isStatus(err, http.StatusNotImplemented) {
+		t.Fatalf("Deleting key on read replica: got '%v' - want '%v'", err, errReadReplica)
+	}
+	if err = sendRequest(ctx, replicaClient, http.MethodPut, api.PathKeyRotate+Name, nil, nil); !isStatus(err, http.StatusNotImplemented) {
+		t.Fatalf("Rotating key on read replica: got '%v' - want '%v'", err, errReadReplica)
+	}
+
+	// The read replica discards cached keys periodically. Hence,
+	// it eventually notices that the key has been deleted.
+	if err = client.DeleteKey(ctx, Name); err != nil {
+		t.Fatalf("Failed to delete key '%s': %v", Name, err)
+	}
+	for {
+		_, err := replicaClient.Decrypt(ctx, Name, ciphertext, nil)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Read replica has not noticed deleted key '%s': %v", Name, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,555 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+// ReplicationConfig is a structure containing the configuration
+// for replicating keys between KES clusters.
+//
+// Unlike peers, which share a KeyStore, each replicating cluster,
+// called site, has its own KeyStore. Whenever a key is created,
+// rotated or deleted, the KES server pushes the key to the other
+// sites. Hence, applications, like MinIO, at one site can decrypt
+// data encrypted at another site.
+//
+// Keys may be modified at multiple sites concurrently. Each site
+// tracks the writes of a key with a vector timestamp. Concurrent
+// writes are resolved by last-writer-wins, using the time of the
+// write and, on ties, the site name. Only keys of the default
+// enclave are replicated, and keys stored on a CryptoKeyStore
+// cannot be replicated since their key material never leaves
+// the store.
+type ReplicationConfig struct {
+	// Site is the unique name of this KES cluster. All servers
+	// of a cluster must use the same site name.
+	Site string
+
+	// Endpoints are the HTTPS endpoints of the other sites,
+	// typically load balancers in front of their KES servers.
+	// For example: "https://kes.eu-west.local:7373".
+	Endpoints []string
+
+	// TLS is the TLS client configuration used to connect to
+	// the other sites. It should contain a client certificate
+	// with an identity that is allowed to access the replicate
+	// API, like the admin identity, at all sites.
+	TLS *tls.Config
+
+	// SyncInterval is the interval at which the KES server
+	// pushes all keys, including deleted ones, to the other
+	// sites. It repairs replication events missed by a site,
+	// e.g. due to a network partition. If 0, it defaults to
+	// 5 minutes.
+	SyncInterval time.Duration
+}
+
+// replicationPrefix is the prefix of the entries at the KeyStore
+// storing the replica version of a key. The version of a deleted
+// key is kept as tombstone such that a delete is not undone by a
+// site that has missed it.
+const replicationPrefix = "-replication-"
+
+const (
+	defaultReplicationSyncInterval = 5 * time.Minute
+	replicationTimeout             = 15 * time.Second
+)
+
+var (
+	errReplicationDisabled      = api.NewError(http.StatusNotImplemented, "key replication is not enabled")
+	errReplicationNotSupported  = api.NewError(http.StatusNotImplemented, "key store does not support key replication")
+	errReplicationInvalidSource = api.NewError(http.StatusBadRequest, "invalid replication site or vector timestamp")
+)
+
+// replicaVersion is the version of a replicated key. The Clock is a
+// vector timestamp that counts the writes of the key per site.
+type replicaVersion struct {
+	Clock   map[string]uint64 `json:"clock"`
+	Time    time.Time         `json:"time"`              // Time of the last write
+	Site    string            `json:"site"`              // Site of the last write
+	Deleted bool              `json:"deleted,omitempty"` // Whether the last write deleted the key
+}
+
+// Ordering of two vector timestamps.
+const (
+	versionEqual      = iota // Both versions are identical
+	versionBefore            // The version happened before the other
+	versionAfter             // The version happened after the other
+	versionConcurrent        // Neither version happened before the other
+)
+
+// Compare returns the ordering of v relative to w.
+func (v *replicaVersion) Compare(w *replicaVersion) int {
+	var before, after bool
+	for site, n := range v.Clock {
+		if m := w.Clock[site]; n > m {
+			after = true
+		} else if n < m {
+			before = true
+		}
+	}
+	for site, m := range w.Clock {
+		if _, ok := v.Clock[site]; !ok && m > 0 {
+			before = true
+		}
+	}
+
+	switch {
+	case before && after:
+		return versionConcurrent
+	case before:
+		return versionBefore
+	case after:
+		return versionAfter
+	default:
+		return versionEqual
+	}
+}
+
+// Supersedes reports whether v replaces w. It does if v happened
+// after w or, for concurrent writes, if v has been written later
+// than w. Concurrent writes at the same time are ordered by site.
+func (v *replicaVersion) Supersedes(w *replicaVersion) bool {
+	switch v.Compare(w) {
+	case versionAfter:
+		return true
+	case versionConcurrent:
+		if !v.Time.Equal(w.Time) {
+			return v.Time.After(w.Time)
+		}
+		return v.Site > w.Site
+	default:
+		return false
+	}
+}
+
+// Merge sets the clock of v to the element-wise maximum of
+// both vector timestamps.
+func (v *replicaVersion) Merge(w *replicaVersion) {
+	if v.Clock == nil {
+		v.Clock = make(map[string]uint64, len(w.Clock))
+	}
+	for site, n := range w.Clock {
+		if n > v.Clock[site] {
+			v.Clock[site] = n
+		}
+	}
+}
+
+// replicator pushes created, rotated and deleted keys to other KES
+// clusters and applies keys pushed by them.
+//
+// Replication is best effort. Keys are pushed asynchronously and
+// are not retried. Instead, all keys are pushed periodically to
+// repair missed events. See ReplicationConfig.SyncInterval.
+type replicator struct {
+	site      string
+	endpoints []string
+	interval  time.Duration
+	client    *http.Client
+	log       *slog.Logger
+
+	// mu serializes reading and writing replica versions.
+	mu sync.Mutex
+}
+
+// newReplicator returns a new replicator for the given config.
+// It returns nil if conf is nil.
+func newReplicator(conf *ReplicationConfig, log *slog.Logger) *replicator {
+	if conf == nil {
+		return nil
+	}
+	interval := conf.SyncInterval
+	if interval == 0 {
+		interval = defaultReplicationSyncInterval
+	}
+	return &replicator{
+		site:      conf.Site,
+		endpoints: conf.Endpoints,
+		interval:  interval,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				TLSClientConfig:   conf.TLS.Clone(),
+				ForceAttemptHTTP2: true,
+				IdleConnTimeout:   90 * time.Second,
+			},
+			Timeout: replicationTimeout,
+		},
+		log: log,
+	}
+}
+
+// attach makes the key cache replicate keys whenever a key is
+// created, rotated or deleted. It must be called before the key
+// cache is used. Keys of a CryptoKeyStore are not replicated.
+func (r *replicator) attach(keys *keyCache) {
+	if r == nil {
+		return
+	}
+	if keys.crypto != nil {
+		r.log.Warn("kes: keys of a crypto key store cannot be replicated")
+		return
+	}
+	keys.replicate = func(event KeyStoreEvent) { go r.Replicate(keys, event) }
+}
+
+// Replicate records the event as a new write of this site and
+// pushes the key to all other sites.
+func (r *replicator) Replicate(keys *keyCache, event KeyStoreEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicationTimeout)
+	defer cancel()
+
+	r.mu.Lock()
+	v, err := r.version(ctx, keys.store, event.Name)
+	if err == nil {
+		if v == nil {
+			v = &replicaVersion{Clock: map[string]uint64{}}
+		}
+		v.Clock[r.site]++
+		v.Time = time.Now().UTC()
+		v.Site = r.site
+		v.Deleted = event.Type == EntryDeleted
+		err = r.setVersion(ctx, keys.store, event.Name, v)
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		r.log.Warn(fmt.Sprintf("failed to replicate key: %v", err), "key", event.Name)
+		return
+	}
+	r.push(keys, event.Name, v)
+}
+
+// Apply applies the key pushed by another site if its version
+// supersedes the local version. It reports the applied change,
+// if any.
+//
+// If the local version wins over a concurrent remote version,
+// Apply pushes the local key back to all sites such that they
+// converge.
+func (r *replicator) Apply(ctx context.Context, keys *keyCache, name string, remote *replicaVersion, key []byte) (KeyStoreEventType, bool, error) {
+	if keys.crypto != nil {
+		return 0, false, errReplicationNotSupported
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	local, err := r.version(ctx, keys.store, name)
+	if err != nil {
+		return 0, false, err
+	}
+	if local != nil && !remote.Supersedes(local) {
+		if local.Compare(remote) == versionConcurrent {
+			local.Merge(remote)
+			if err = r.setVersion(ctx, keys.store, name, local); err != nil {
+				return 0, false, err
+			}
+			go r.push(keys, name, local)
+		}
+		return 0, false, nil
+	}
+
+	event := KeyStoreEvent{Type: EntryDeleted, Name: name}
+	if remote.Deleted {
+		if err = keys.delete(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+			return 0, false, err
+		}
+	} else if event.Type, err = keys.setReplica(ctx, name, key); err != nil {
+		return 0, false, err
+	}
+
+	v := *remote
+	v.Clock = nil
+	v.Merge(remote)
+	if local != nil {
+		v.Merge(local)
+	}
+	if err = r.setVersion(ctx, keys.store, name, &v); err != nil {
+		return 0, false, err
+	}
+
+	// Only notify the peers of this cluster. Replicating the key
+	// again is not necessary since the remote site pushes it to
+	// all other sites.
+	if keys.notify != nil {
+		keys.notify(event)
+	}
+	return event.Type, true, nil
+}
+
+// Sync pushes all keys, and the tombstones of deleted keys, to all
+// other sites. Keys without a replica version, e.g. keys created
+// before replication has been enabled, get an initial version.
+func (r *replicator) Sync(ctx context.Context, keys *keyCache) error {
+	names, _, err := keys.List(ctx, "", -1)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		r.mu.Lock()
+		v, err := r.version(ctx, keys.store, name)
+		if err == nil && v == nil {
+			err = r.setVersion(ctx, keys.store, name, &replicaVersion{
+				Clock: map[string]uint64{r.site: 1},
+				Time:  time.Now().UTC(),
+				Site:  r.site,
+			})
+		}
+		r.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, _, err := keys.store.List(ctx, replicationPrefix, -1)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry, replicationPrefix)
+
+		r.mu.Lock()
+		v, err := r.version(ctx, keys.store, name)
+		r.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			r.push(keys, name, v)
+		}
+	}
+	return nil
+}
+
+// push sends the key with the given name and its version to all
+// other sites.
+func (r *replicator) push(keys *keyCache, name string, v *replicaVersion) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicationTimeout)
+	defer cancel()
+
+	req := api.ReplicateRequest{
+		Site:    v.Site,
+		Clock:   v.Clock,
+		Time:    v.Time,
+		Deleted: v.Deleted,
+	}
+	if !v.Deleted {
+		key, err := keys.store.Get(ctx, name)
+		if err != nil {
+			r.log.Warn(fmt.Sprintf("failed to replicate key: %v", err), "key", name)
+			return
+		}
+		defer secmem.Zero(key)
+		req.Key = key
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		r.log.Warn(fmt.Sprintf("failed to replicate key: %v", err), "key", name)
+		return
+	}
+	defer secmem.Zero(body)
+
+	for _, endpoint := range r.endpoints {
+		r.send(ctx, endpoint, name, body)
+	}
+}
+
+func (r *replicator) send(ctx context.Context, endpoint, name string, body []byte) {
+	endpoint = strings.TrimSuffix(endpoint, "/") + api.PathReplicate + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		r.log.Warn(fmt.Sprintf("failed to replicate key: %v", err), "site", endpoint, "key", name)
+		return
+	}
+	req.Header.Set(headers.ContentType, headers.ContentTypeJSON)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.log.Warn(fmt.Sprintf("failed to replicate key: %v", err), "site", endpoint, "key", name)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.log.Warn(fmt.Sprintf("failed to replicate key: %s", resp.Status), "site", endpoint, "key", name)
+	}
+}
+
+// version returns the replica version of the key with the given
+// name. It returns nil if the key has not been replicated yet.
+func (r *replicator) version(ctx context.Context, store KeyStore, name string) (*replicaVersion, error) {
+	b, err := store.Get(ctx, replicationPrefix+name)
+	if errors.Is(err, kes.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var v replicaVersion
+	if err = json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	if v.Clock == nil {
+		v.Clock = map[string]uint64{}
+	}
+	return &v, nil
+}
+
+// setVersion stores the replica version of the key with the
+// given name and replaces any previous version.
+func (r *replicator) setVersion(ctx context.Context, store KeyStore, name string, v *replicaVersion) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	name = replicationPrefix + name
+	if s, ok := store.(MutableKeyStore); ok {
+		if err = s.Set(ctx, name, b); !errors.Is(err, kes.ErrKeyNotFound) {
+			return err
+		}
+	} else if err = store.Delete(ctx, name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	return store.Create(ctx, name, b)
+}
+
+// Close closes idle connections to the other sites.
+func (r *replicator) Close() {
+	if r != nil {
+		r.client.CloseIdleConnections()
+	}
+}
+
+// setReplica creates or replaces the key with the given name with
+// the replicated key b and evicts the key from the cache. It returns
+// whether the key has been created or updated.
+func (c *keyCache) setReplica(ctx context.Context, name string, b []byte) (KeyStoreEventType, error) {
+	key, err := crypto.ParseKey(b)
+	if err != nil {
+		return 0, api.NewError(http.StatusBadRequest, "invalid replicated key")
+	}
+
+	typ := EntryCreated
+	err = c.store.Create(ctx, name, b)
+	if errors.Is(err, kes.ErrKeyExists) {
+		store, ok := c.store.(MutableKeyStore)
+		if !ok {
+			return 0, errReplicationNotSupported
+		}
+		typ, err = EntryUpdated, store.Set(ctx, name, b)
+	}
+	if err != nil {
+		return 0, err
+	}
+	c.setMetadata(ctx, name, &key)
+	c.evict(KeyStoreEvent{Type: typ, Name: name})
+	return typ, nil
+}
+
+// syncReplicas periodically pushes all keys of the default enclave
+// to the other sites until ctx.Done() returns.
+func (s *Server) syncReplicas(ctx context.Context) {
+	for {
+		interval := defaultReplicationSyncInterval
+		if r := s.state.Load().Replication; r != nil {
+			interval = r.interval
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		state := s.state.Load()
+		if state.Replication == nil || state.Seal != nil || state.Keys.replicate == nil {
+			continue
+		}
+		if err := state.Replication.Sync(ctx, state.Keys); err != nil {
+			state.Log.ErrorContext(ctx, fmt.Sprintf("kes: failed to sync replicated keys: %v", err))
+		}
+	}
+}
+
+// replicate applies a key created, rotated or deleted at another
+// site. The key is ignored if this site has a newer version.
+func (s *Server) replicate(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.ReplicateRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer secmem.Zero(body.Key)
+
+	state := s.state.Load()
+	if state.Replication == nil {
+		resp.Failr(errReplicationDisabled)
+		return
+	}
+	if req.Enclave != "" {
+		resp.Failf(http.StatusBadRequest, "keys of enclave '%s' cannot be replicated", req.Enclave)
+		return
+	}
+	if body.Site == "" || body.Clock[body.Site] == 0 {
+		resp.Failr(errReplicationInvalidSource)
+		return
+	}
+
+	typ, applied, err := state.Replication.Apply(req.Context(), state.Keys, req.Resource, &replicaVersion{
+		Clock:   body.Clock,
+		Time:    body.Time,
+		Site:    body.Site,
+		Deleted: body.Deleted,
+	}, body.Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to replicate key")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	if applied {
+		state.Audit.Log(
+			fmt.Sprintf("site '%s' replicated key '%s' as %s", body.Site, req.Resource, typ),
+			StatusOK,
+			req,
+		)
+	}
+	resp.Reply(StatusOK)
+}
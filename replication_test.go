@@ -0,0 +1,68 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicaVersionSupersedes(t *testing.T) {
+	now := time.Now()
+	for i, test := range []struct {
+		V, W       replicaVersion
+		Order      int
+		Supersedes bool
+	}{
+		{ // 0
+			V:     replicaVersion{Clock: map[string]uint64{"a": 1}},
+			W:     replicaVersion{Clock: map[string]uint64{"a": 1}},
+			Order: versionEqual,
+		},
+		{ // 1
+			V:          replicaVersion{Clock: map[string]uint64{"a": 2}},
+			W:          replicaVersion{Clock: map[string]uint64{"a": 1}},
+			Order:      versionAfter,
+			Supersedes: true,
+		},
+		{ // 2
+			V:     replicaVersion{Clock: map[string]uint64{"a": 1}},
+			W:     replicaVersion{Clock: map[string]uint64{"a": 1, "b": 1}},
+			Order: versionBefore,
+		},
+		{ // 3: concurrent, v written later
+			V:          replicaVersion{Clock: map[string]uint64{"a": 2, "b": 1}, Time: now, Site: "a"},
+			W:          replicaVersion{Clock: map[string]uint64{"a": 1, "b": 2}, Time: now.Add(-time.Second), Site: "b"},
+			Order:      versionConcurrent,
+			Supersedes: true,
+		},
+		{ // 4: concurrent, v written earlier
+			V:     replicaVersion{Clock: map[string]uint64{"b": 1}, Time: now.Add(-time.Second), Site: "b"},
+			W:     replicaVersion{Clock: map[string]uint64{"a": 1}, Time: now, Site: "a"},
+			Order: versionConcurrent,
+		},
+		{ // 5: concurrent at the same time, ordered by site
+			V:          replicaVersion{Clock: map[string]uint64{"b": 1}, Time: now, Site: "b"},
+			W:          replicaVersion{Clock: map[string]uint64{"a": 1}, Time: now, Site: "a"},
+			Order:      versionConcurrent,
+			Supersedes: true,
+		},
+	} {
+		if order := test.V.Compare(&test.W); order != test.Order {
+			t.Fatalf("Test %d: got order '%d' - want '%d'", i, order, test.Order)
+		}
+		if supersedes := test.V.Supersedes(&test.W); supersedes != test.Supersedes {
+			t.Fatalf("Test %d: got supersedes '%v' - want '%v'", i, supersedes, test.Supersedes)
+		}
+
+		v := test.V
+		v.Clock = nil
+		v.Merge(&test.V)
+		v.Merge(&test.W)
+		if v.Compare(&test.V) == versionBefore || v.Compare(&test.W) == versionBefore {
+			t.Fatalf("Test %d: merged version happened before one of the versions", i)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationConfig is a structure containing the configuration
+// for checking whether client certificates have been revoked.
+//
+// Revocation checking requires verified client certificates.
+// Hence, the server's TLS config must use tls.VerifyClientCertIfGiven
+// or tls.RequireAndVerifyClientCert. The KES server checks the leaf
+// certificate of the verified chain against all configured CRLs
+// issued by the leaf's issuer and, optionally, queries the OCSP
+// responder listed in the leaf certificate.
+//
+// The Go TLS stack does not expose OCSP responses stapled by
+// clients. Therefore, the KES server always queries the OCSP
+// responder itself and caches its responses.
+type RevocationConfig struct {
+	// CRLs is a list of file paths or HTTP(S) URLs of PEM or DER
+	// encoded certificate revocation lists.
+	CRLs []string
+
+	// CRLRefresh is the interval in which CRLs are fetched again.
+	// It also limits how long OCSP responses are cached. If zero,
+	// it defaults to 1h.
+	CRLRefresh time.Duration
+
+	// OCSP controls whether the KES server queries the OCSP
+	// responder of client certificates.
+	OCSP bool
+
+	// Client is the HTTP client used to fetch CRLs and to send
+	// OCSP requests. If nil, an HTTP client with a timeout of
+	// 10s is used.
+	Client *http.Client
+
+	// FailOpen controls whether requests are allowed or denied
+	// if the revocation status of a client certificate cannot be
+	// determined, e.g. because the OCSP responder is unreachable.
+	// By default, such requests are denied.
+	FailOpen bool
+}
+
+// revocationMaxCacheSize is the max. number of cached
+// revocation decisions.
+const revocationMaxCacheSize = 10000
+
+// revocationChecker checks whether client certificates have
+// been revoked and caches its decisions.
+type revocationChecker struct {
+	conf RevocationConfig
+
+	refresh  sync.Mutex // Held while CRLs are fetched
+	mu       sync.RWMutex
+	crls     []*revocationList
+	loadedAt time.Time
+	cache    map[[sha256.Size]byte]revocationDecision
+}
+
+// revocationList is a CRL with the set of its revoked
+// serial numbers.
+type revocationList struct {
+	*x509.RevocationList
+	Serials map[string]struct{}
+}
+
+// revocationDecision is a cached revocation decision.
+type revocationDecision struct {
+	Revoked   bool
+	ExpiresAt time.Time
+}
+
+// newRevocationChecker returns a new revocationChecker for the
+// config and fetches all configured CRLs. It returns nil if
+// conf is nil.
+func newRevocationChecker(conf *RevocationConfig) (*revocationChecker, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	r := &revocationChecker{
+		conf:  *conf,
+		cache: map[[sha256.Size]byte]revocationDecision{},
+	}
+	r.conf.CRLs = append([]string(nil), conf.CRLs...)
+	if r.conf.Client == nil {
+		r.conf.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if r.conf.CRLRefresh == 0 {
+		r.conf.CRLRefresh = 1 * time.Hour
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	crls, err := r.fetchCRLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.crls, r.loadedAt = crls, time.Now()
+	return r, nil
+}
+
+// Revoked reports whether the verified client certificate of
+// the request has been revoked. Requests without a verified
+// client certificate are not considered revoked.
+//
+// It returns an error describing why the revocation status could
+// not be determined or why CRLs could not be refreshed, if any.
+// If the revocation status could not be determined, the decision
+// depends on whether the revocationChecker fails open or closed.
+// Callers must reject the request on any error unless the
+// revocationChecker fails open.
+func (r *revocationChecker) Revoked(req *http.Request) (bool, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return false, nil
+	}
+	chain := req.TLS.VerifiedChains[0]
+	if len(chain) < 2 {
+		return false, nil // A self-signed certificate cannot be revoked
+	}
+	leaf, issuer := chain[0], chain[1]
+
+	refreshErr := r.refreshCRLs(req.Context())
+
+	key := sha256.Sum256(leaf.Raw)
+	now := time.Now()
+	r.mu.RLock()
+	decision, ok := r.cache[key]
+	r.mu.RUnlock()
+	if ok && now.Before(decision.ExpiresAt) {
+		return decision.Revoked, refreshErr
+	}
+
+	revoked, expiresAt, err := r.check(req.Context(), leaf, issuer)
+	if err != nil {
+		return !r.conf.FailOpen, errors.Join(err, refreshErr)
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= revocationMaxCacheSize {
+		for k, d := range r.cache {
+			if now.After(d.ExpiresAt) {
+				delete(r.cache, k)
+			}
+		}
+		if len(r.cache) >= revocationMaxCacheSize {
+			clear(r.cache)
+		}
+	}
+	r.cache[key] = revocationDecision{
+		Revoked:   revoked,
+		ExpiresAt: expiresAt,
+	}
+	r.mu.Unlock()
+	return revoked, refreshErr
+}
+
+// FailOpen reports whether requests are allowed if Revoked
+// returns an error.
+func (r *revocationChecker) FailOpen() bool { return r.conf.FailOpen }
+
+// check reports whether the certificate, issued by issuer, has
+// been revoked and until when the decision is valid.
+func (r *revocationChecker) check(ctx context.Context, cert, issuer *x509.Certificate) (bool, time.Time, error) {
+	expiresAt := time.Now().Add(r.conf.CRLRefresh)
+
+	r.mu.RLock()
+	crls := r.crls
+	r.mu.RUnlock()
+
+	serial := cert.SerialNumber.String()
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if _, ok := crl.Serials[serial]; !ok {
+			continue
+		}
+		// Only trust CRLs signed by the certificate's issuer.
+		// A CA may have the same name as another CA.
+		if crl.CheckSignatureFrom(issuer) == nil {
+			return true, expiresAt, nil
+		}
+	}
+
+	if !r.conf.OCSP || len(cert.OCSPServer) == 0 {
+		return false, expiresAt, nil
+	}
+	resp, err := r.queryOCSP(ctx, cert, issuer)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(expiresAt) {
+		expiresAt = resp.NextUpdate
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return false, expiresAt, nil
+	case ocsp.Revoked:
+		return true, expiresAt, nil
+	default:
+		return false, time.Time{}, fmt.Errorf("kes: OCSP responder '%s' does not know client certificate '%s'", cert.OCSPServer[0], serial)
+	}
+}
+
+// queryOCSP sends an OCSP request for the certificate to its
+// OCSP responder and returns the verified response.
+func (r *revocationChecker) queryOCSP(ctx context.Context, cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	body, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to create OCSP request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to create OCSP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := r.conf.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kes: OCSP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kes: OCSP request failed: %s", resp.Status)
+	}
+
+	const MaxSize = 1 << 20
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, MaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("kes: failed to read OCSP response: %v", err)
+	}
+	ocspResp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("kes: invalid OCSP response: %v", err)
+	}
+	return ocspResp, nil
+}
+
+// refreshCRLs fetches all CRLs again if they are older than
+// the refresh interval. Only one request fetches CRLs at a time
+// while other requests keep using the current CRLs. If fetching
+// fails, the current CRLs are kept until the next refresh.
+func (r *revocationChecker) refreshCRLs(ctx context.Context) error {
+	if len(r.conf.CRLs) == 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) >= r.conf.CRLRefresh
+	r.mu.RUnlock()
+	if !stale || !r.refresh.TryLock() {
+		return nil
+	}
+	defer r.refresh.Unlock()
+
+	crls, err := r.fetchCRLs(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.loadedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	r.crls = crls
+	clear(r.cache)
+	return nil
+}
+
+// fetchCRLs reads and parses all configured CRLs.
+func (r *revocationChecker) fetchCRLs(ctx context.Context) ([]*revocationList, error) {
+	crls := make([]*revocationList, 0, len(r.conf.CRLs))
+	for _, src := range r.conf.CRLs {
+		var (
+			b   []byte
+			err error
+		)
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			b, err = r.download(ctx, src)
+		} else {
+			b, err = os.ReadFile(src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("kes: failed to fetch CRL '%s': %v", src, err)
+		}
+
+		lists, err := parseCRLs(b)
+		if err != nil {
+			return nil, fmt.Errorf("kes: failed to parse CRL '%s': %v", src, err)
+		}
+		crls = append(crls, lists...)
+	}
+	return crls, nil
+}
+
+// download fetches the resource at the URL.
+func (r *revocationChecker) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.conf.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+
+	const MaxSize = 64 << 20
+	return io.ReadAll(io.LimitReader(resp.Body, MaxSize))
+}
+
+// parseCRLs parses one DER or one or more PEM encoded CRLs.
+func parseCRLs(b []byte) ([]*revocationList, error) {
+	var ders [][]byte
+	if rest := bytes.TrimSpace(b); bytes.HasPrefix(rest, []byte("-----BEGIN")) {
+		for len(rest) > 0 {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			if block.Type == "X509 CRL" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+		if len(ders) == 0 {
+			return nil, errors.New("no PEM-encoded CRL found")
+		}
+	} else {
+		ders = append(ders, b)
+	}
+
+	crls := make([]*revocationList, 0, len(ders))
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, err
+		}
+		serials := make(map[string]struct{}, len(crl.RevokedCertificateEntries))
+		for _, entry := range crl.RevokedCertificateEntries {
+			serials[entry.SerialNumber.String()] = struct{}{}
+		}
+		crls = append(crls, &revocationList{
+			RevocationList: crl,
+			Serials:        serials,
+		})
+	}
+	return crls, nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestRevocationCRL(t *testing.T) {
+	ctx := testContext(t)
+
+	ca := newTestCA(t)
+	valid, validID := ca.Issue(t, 1, "")
+	revoked, revokedID := ca.Issue(t, 2, "")
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(2), RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	filename := filepath.Join(t.TempDir(), "crl.pem")
+	if err = os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0o600); err != nil {
+		t.Fatalf("Failed to write CRL: %v", err)
+	}
+
+	_, url := startServer(ctx, ca.ServerConfig(&RevocationConfig{CRLs: []string{filename}}, validID, revokedID))
+
+	if err := ca.Client(url, valid).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := ca.Client(url, revoked).CreateKey(ctx, "my-key-2"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key with revoked certificate: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	ctx := testContext(t)
+
+	ca := newTestCA(t)
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status := ocsp.Good
+		if req.SerialNumber.Cmp(big.NewInt(2)) == 0 {
+			status = ocsp.Revoked
+		}
+		resp, err := ocsp.CreateResponse(ca.Cert, ca.Cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, ca.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	valid, validID := ca.Issue(t, 1, responder.URL)
+	revoked, revokedID := ca.Issue(t, 2, responder.URL)
+	_, url := startServer(ctx, ca.ServerConfig(&RevocationConfig{OCSP: true}, validID, revokedID))
+
+	if err := ca.Client(url, valid).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := ca.Client(url, revoked).CreateKey(ctx, "my-key-2"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key with revoked certificate: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	// Once the responder is unreachable, uncached certificates are
+	// rejected unless the server fails open.
+	responder.Close()
+	for i, test := range []struct {
+		FailOpen bool
+	}{
+		{FailOpen: false},
+		{FailOpen: true},
+	} {
+		r, err := newRevocationChecker(&RevocationConfig{OCSP: true, FailOpen: test.FailOpen})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create revocation checker: %v", i, err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{valid.Leaf, ca.Cert}},
+		}
+		revoked, err := r.Revoked(req)
+		if err == nil {
+			t.Fatalf("Test %d: revocation check should have failed", i)
+		}
+		if revoked != !test.FailOpen {
+			t.Fatalf("Test %d: got 'revoked=%v' - want 'revoked=%v'", i, revoked, !test.FailOpen)
+		}
+	}
+}
+
+func TestRevocationFailClosed(t *testing.T) {
+	ctx := testContext(t)
+
+	ca := newTestCA(t)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+
+	// Once the CRL cannot be refreshed, requests are rejected
+	// unless the server fails open.
+	for i, test := range []struct {
+		FailOpen bool
+	}{
+		{FailOpen: false},
+		{FailOpen: true},
+	} {
+		distributor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(crl)
+		}))
+
+		valid, validID := ca.Issue(t, 1, "")
+		_, url := startServer(ctx, ca.ServerConfig(&RevocationConfig{
+			CRLs:       []string{distributor.URL},
+			CRLRefresh: 10 * time.Millisecond,
+			FailOpen:   test.FailOpen,
+		}, validID))
+
+		client := ca.Client(url, valid)
+		if err := client.CreateKey(ctx, "my-key"); err != nil {
+			t.Fatalf("Test %d: failed to create key: %v", i, err)
+		}
+
+		distributor.Close()
+		time.Sleep(20 * time.Millisecond)
+
+		err := client.CreateKey(ctx, "my-key-2")
+		if test.FailOpen && err != nil {
+			t.Fatalf("Test %d: failed to create key: %v", i, err)
+		}
+		if !test.FailOpen && !errors.Is(err, kes.ErrNotAllowed) {
+			t.Fatalf("Test %d: creating key without revocation status: got '%v' - want '%v'", i, err, kes.ErrNotAllowed)
+		}
+	}
+}
+
+// testCA is a certificate authority issuing client certificates.
+type testCA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1000),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	return &testCA{Cert: cert, Key: key}
+}
+
+// Issue returns a new client certificate with the given serial
+// number and OCSP responder, if not empty, and its identity.
+func (ca *testCA) Issue(t *testing.T, serial int64, ocspServer string) (tls.Certificate, kes.Identity) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate client key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+	return ca.sign(t, template, key)
+}
+
+// sign returns a new client certificate for the template
+// and key signed by the CA, and its identity.
+func (ca *testCA) sign(t *testing.T, template *x509.Certificate, key crypto.Signer) (tls.Certificate, kes.Identity) {
+	raw, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		t.Fatalf("Failed to create client certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse client certificate: %v", err)
+	}
+
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return tls.Certificate{
+		Certificate: [][]byte{raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}, kes.Identity(hex.EncodeToString(h[:]))
+}
+
+// ServerConfig returns a server config that verifies client
+// certificates issued by the CA and allows the identities to
+// access all key APIs.
+func (ca *testCA) ServerConfig(revocation *RevocationConfig, identities ...kes.Identity) *Config {
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Cert)
+	return &Config{
+		Admin: "disabled",
+		TLS: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+			Certificates: []tls.Certificate{defaultServerCertificate()},
+		},
+		Policies: map[string]Policy{
+			"my-policy": {
+				Allow:      map[string]kes.Rule{"/v1/key/*": {}},
+				Identities: identities,
+			},
+		},
+		Revocation: revocation,
+	}
+}
+
+// Client returns a new client that authenticates with the
+// client certificate.
+func (ca *testCA) Client(endpoint string, cert tls.Certificate) *kes.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+	return kes.NewClientWithConfig(endpoint, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      rootCAs,
+		Certificates: []tls.Certificate{cert},
+	})
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kms-go/kes"
+)
+
+// rotationCheckInterval is the time period after which
+// the server checks again whether any key has to be
+// rotated.
+const rotationCheckInterval = 1 * time.Minute
+
+// rotationInterval returns the rotation interval for the key
+// with the given name and reports whether the key should be
+// rotated automatically. If multiple patterns match the name,
+// the longest pattern applies.
+func rotationInterval(rotation map[string]RotationConfig, name string) (time.Duration, bool) {
+	var (
+		match    string
+		interval time.Duration
+		found    bool
+	)
+	for pattern, conf := range rotation {
+		if !matchKeyPattern(pattern, name) {
+			continue
+		}
+		if !found || len(pattern) > len(match) {
+			match, interval, found = pattern, conf.Interval, true
+		}
+	}
+	return interval, found && interval > 0
+}
+
+// policyRotation returns the rotation config derived from the
+// policies. Each policy with a rotation interval applies to all
+// keys it allows to create. If multiple policies apply to the same
+// key name pattern, the shortest interval applies.
+func policyRotation(policies map[string]Policy) map[string]RotationConfig {
+	rotation := make(map[string]RotationConfig)
+	for _, policy := range policies {
+		if policy.Rotation.Interval <= 0 {
+			continue
+		}
+		for path := range policy.Allow {
+			pattern, ok := strings.CutPrefix(path, api.PathKeyCreate)
+			if !ok || pattern == "" || !validPattern(pattern) {
+				continue
+			}
+			if conf, ok := rotation[pattern]; ok && conf.Interval <= policy.Rotation.Interval {
+				continue
+			}
+			rotation[pattern] = policy.Rotation
+		}
+	}
+	return rotation
+}
+
+// mergeRotation returns the union of the two rotation configs.
+// If both contain the same pattern, the config of explicit applies.
+func mergeRotation(explicit, policies map[string]RotationConfig) map[string]RotationConfig {
+	if len(policies) == 0 {
+		return explicit
+	}
+	rotation := maps.Clone(policies)
+	maps.Copy(rotation, explicit)
+	return rotation
+}
+
+// rotation returns the rotation config of the named enclave,
+// or of the default enclave if name is empty.
+func (s *serverState) rotation(enclave string) map[string]RotationConfig {
+	if enclave == "" {
+		return mergeRotation(s.Rotation, s.PolicyRotation)
+	}
+	if e, ok := s.Enclaves[enclave]; ok {
+		return mergeRotation(s.Rotation, e.Rotation)
+	}
+	return s.Rotation
+}
+
+// nextRotation returns the point in time when the key should
+// be rotated next, if it should be rotated automatically.
+func nextRotation(rotation map[string]RotationConfig, name string, key *crypto.Key) (time.Time, bool) {
+	interval, ok := rotationInterval(rotation, name)
+	if !ok {
+		return time.Time{}, false
+	}
+	return key.Latest().CreatedAt.Add(interval), true
+}
+
+// matchKeyPattern reports whether name matches the pattern.
+// A pattern is either a key name or a prefix followed by '*'.
+func matchKeyPattern(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// rotateKeys periodically rotates all keys matching the
+// server's rotation config until ctx.Done() returns.
+func (s *Server) rotateKeys(ctx context.Context) {
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.rotateScheduled(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rotateScheduled rotates all keys matching the server's rotation
+// config, or the rotation config of a policy, for which the latest
+// key version is older than the rotation interval at the given
+// point in time.
+//
+// It rotates the keys of the default enclave as well as the keys
+// of all other enclaves.
+//
+// Multiple KES servers sharing a KeyStore rotate the same keys.
+// However, a key is only rotated once per interval if the KeyStore
+// implements ConditionalKeyStore. Each server checks whether the
+// key is still due and only replaces it if it has not been rotated
+// by another server in the meantime. A read replica does not rotate
+// any keys.
+func (s *Server) rotateScheduled(ctx context.Context, now time.Time) {
+	state := s.state.Load()
+	if state.ReadReplica != nil {
+		return
+	}
+
+	if rotation := state.rotation(""); len(rotation) > 0 {
+		s.rotateEnclave(ctx, state, "", state.Keys, rotation, now)
+	}
+	for name, enclave := range state.Enclaves {
+		if rotation := state.rotation(name); len(rotation) > 0 {
+			s.rotateEnclave(ctx, state, name, enclave.Keys, rotation, now)
+		}
+	}
+}
+
+// rotateEnclave rotates all keys of the named enclave that are
+// scheduled for rotation at the given point in time.
+func (s *Server) rotateEnclave(ctx context.Context, state *serverState, enclave string, keyStore *keyCache, rotation map[string]RotationConfig, now time.Time) {
+	names := make(map[string]struct{})
+	for pattern := range rotation {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if !ok {
+			names[pattern] = struct{}{}
+			continue
+		}
+
+		keys, _, err := keyStore.List(ctx, prefix, -1)
+		if err != nil {
+			state.Log.ErrorContext(ctx, fmt.Sprintf("kes: failed to list keys for rotation: %v", err), "pattern", pattern, "enclave", enclave)
+			continue
+		}
+		for _, name := range keys {
+			names[name] = struct{}{}
+		}
+	}
+
+	for name := range names {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Checking whether a key is due for rotation
+		// does not count as using the key.
+		key, err := keyStore.get(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			state.Log.ErrorContext(ctx, fmt.Sprintf("kes: failed to read key '%s' for rotation: %v", name, err), "enclave", enclave)
+			continue
+		}
+
+		if next, ok := nextRotation(rotation, name, &key); !ok || next.After(now) {
+			continue
+		}
+
+		// The cached key may be stale. Hence, RotateIfDue checks
+		// again whether the stored key is due for rotation, and
+		// does not rotate it if another server has done so.
+		rotated, err := keyStore.RotateIfDue(ctx, name, func(key *crypto.Key) bool {
+			next, ok := nextRotation(rotation, name, key)
+			return ok && !next.After(now)
+		})
+		if err != nil {
+			if errors.Is(err, kes.ErrKeyNotFound) {
+				continue
+			}
+			state.Log.ErrorContext(ctx, fmt.Sprintf("kes: failed to rotate key '%s': %v", name, err), "enclave", enclave)
+			continue
+		}
+		if !rotated {
+			continue
+		}
+
+		msg := fmt.Sprintf("secret key '%s' rotated", name)
+		if enclave != "" {
+			msg = fmt.Sprintf("secret key '%s' rotated in enclave '%s'", name, enclave)
+		}
+		state.Audit.LogEvent(
+			ctx,
+			msg,
+			http.MethodPut,
+			api.PathKeyRotate+name,
+			http.StatusOK,
+		)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kes/internal/shamir"
+	"github.com/minio/kms-go/kes"
+)
+
+// SealConfig is a structure containing the configuration of a
+// sealed KES server.
+//
+// A sealed KES server serves no requests, except for the version
+// and unseal API, until it gets unsealed. The root key of the KES
+// server, which typically protects secrets within its config, like
+// keystore credentials, is split into N unseal key shares using
+// Shamir's secret sharing. Each operator holds one of them. To
+// unseal the server, at least Threshold operators submit their
+// share via the unseal API. Then, the KES server combines them
+// to the root key and obtains its actual configuration by calling
+// Unseal.
+//
+// The root key itself is never stored by the KES server. Hence, a
+// sealed KES server has to be unsealed again after every restart.
+type SealConfig struct {
+	// Threshold is the number of distinct unseal key shares
+	// required to reconstruct the root key. It must be at
+	// least 2.
+	Threshold int
+
+	// Unseal returns the config of the unsealed KES server
+	// for the root key reconstructed from the submitted shares.
+	// It should return ErrInvalidUnsealKey if the root key is
+	// not correct, e.g. since one of the shares is invalid.
+	//
+	// If Unseal returns ErrInvalidUnsealKey, all submitted shares
+	// are discarded and the server remains sealed. If it fails
+	// otherwise, the shares are kept and unsealing is retried once
+	// a share is submitted again. The returned config replaces
+	// the sealed config like Server.Update. Therefore, options
+	// that cannot be changed by Server.Update, like the KMIP
+	// address, are not applied when unsealing.
+	Unseal func(ctx context.Context, rootKey []byte) (*Config, error)
+
+	// Operators are the identities, besides the admin, that may
+	// submit unseal key shares. Shares submitted by any other
+	// identity are rejected.
+	Operators []Identity
+
+	// VerifyShare, if not nil, verifies each submitted unseal
+	// key share before it is kept. It should return
+	// ErrInvalidUnsealKey if the share is not a share of the
+	// root key. Then, only this share is rejected.
+	//
+	// Without VerifyShare, an invalid share is only detected
+	// once enough shares have been submitted. Then, all
+	// submitted shares have to be discarded.
+	VerifyShare func(share []byte) error
+}
+
+// ErrInvalidUnsealKey is returned by SealConfig.Unseal if the root
+// key reconstructed from the submitted unseal key shares is not
+// correct.
+var ErrInvalidUnsealKey = errors.New("kes: invalid unseal key")
+
+var (
+	errSealed           = api.NewError(http.StatusServiceUnavailable, "server is sealed")
+	errInvalidUnsealKey = api.NewError(http.StatusBadRequest, "invalid unseal key shares")
+)
+
+// unsealer collects unseal key shares until enough shares have been
+// submitted to unseal the server.
+type unsealer struct {
+	threshold   int
+	operators   []Identity
+	unseal      func(context.Context, []byte) (*Config, error)
+	verifyShare func([]byte) error
+
+	mu       sync.Mutex
+	shares   [][]byte
+	unsealed bool
+}
+
+// newUnsealer returns a new unsealer for the given config.
+func newUnsealer(conf *SealConfig) *unsealer {
+	return &unsealer{
+		threshold:   conf.Threshold,
+		operators:   slices.Clone(conf.Operators),
+		unseal:      conf.Unseal,
+		verifyShare: conf.VerifyShare,
+	}
+}
+
+// Allows reports whether the identity may submit unseal
+// key shares, either as admin or as unseal operator.
+func (u *unsealer) Allows(identity, admin Identity) bool {
+	if identity.IsUnknown() {
+		return false
+	}
+	return identity == admin || slices.Contains(u.operators, identity)
+}
+
+// Submit adds the share to the submitted shares and returns the number
+// of shares submitted so far. Submitting the same share again only
+// retries unsealing, if enough shares have been submitted. Without a
+// share, Submit only returns the current progress.
+//
+// A share that fails verification, or has the same x-coordinate as a
+// different share submitted before, is rejected. The shares submitted
+// so far are kept.
+//
+// Once enough shares have been submitted, Submit combines them to the
+// root key, obtains the unsealed config and passes it to update. Then,
+// it reports that the server is no longer sealed. If the root key is
+// invalid, all submitted shares are discarded. If unsealing fails
+// otherwise, the shares are kept and unsealing is retried once a share
+// is submitted again.
+func (u *unsealer) Submit(ctx context.Context, share []byte, update func(*Config) error) (progress int, sealed bool, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.unsealed {
+		return 0, false, nil
+	}
+	if len(share) == 0 {
+		return len(u.shares), true, nil
+	}
+	if len(share) < 2 || (len(u.shares) > 0 && len(share) != len(u.shares[0])) {
+		return len(u.shares), true, errInvalidUnsealKey
+	}
+	submitted := false
+	for _, s := range u.shares {
+		if bytes.Equal(s, share) {
+			submitted = true
+			break
+		}
+		// The last byte of a share is its x-coordinate. Two
+		// distinct shares with the same x-coordinate cannot
+		// both be valid.
+		if s[len(s)-1] == share[len(share)-1] {
+			return len(u.shares), true, errInvalidUnsealKey
+		}
+	}
+	if !submitted && u.verifyShare != nil {
+		if err := u.verifyShare(share); err != nil {
+			if errors.Is(err, ErrInvalidUnsealKey) {
+				return len(u.shares), true, errInvalidUnsealKey
+			}
+			return len(u.shares), true, err
+		}
+	}
+
+	if !submitted {
+		u.shares = append(u.shares, bytes.Clone(share))
+	}
+	if len(u.shares) < u.threshold {
+		return len(u.shares), true, nil
+	}
+
+	rootKey, err := shamir.Combine(u.shares)
+	if err != nil {
+		u.discard()
+		return 0, true, errInvalidUnsealKey
+	}
+	defer secmem.Zero(rootKey)
+
+	conf, err := u.unseal(ctx, rootKey)
+	if err != nil {
+		if errors.Is(err, ErrInvalidUnsealKey) {
+			u.discard()
+			return 0, true, errInvalidUnsealKey
+		}
+		return len(u.shares), true, err
+	}
+	if err = update(conf); err != nil {
+		return len(u.shares), true, err
+	}
+	u.discard()
+	u.unsealed = true
+	return 0, false, nil
+}
+
+// discard removes all submitted shares.
+func (u *unsealer) discard() {
+	for _, share := range u.shares {
+		secmem.Zero(share)
+	}
+	u.shares = nil
+}
+
+// sealedKeyStore is the KeyStore of a sealed server. A sealed
+// server does not serve any key or secret API. Hence, it is only
+// used by background tasks, like key rotation, and fails all
+// operations.
+type sealedKeyStore struct{}
+
+func (sealedKeyStore) String() string { return "Sealed" }
+
+func (sealedKeyStore) Status(context.Context) (KeyStoreState, error) {
+	return KeyStoreState{}, errSealed
+}
+
+func (sealedKeyStore) Create(context.Context, string, []byte) error { return errSealed }
+
+func (sealedKeyStore) Delete(context.Context, string) error { return errSealed }
+
+func (sealedKeyStore) Get(context.Context, string) ([]byte, error) { return nil, errSealed }
+
+func (sealedKeyStore) List(context.Context, string, int) ([]string, string, error) {
+	return nil, "", errSealed
+}
+
+func (sealedKeyStore) Close() error { return nil }
+
+// sealedRoutes returns a ServeMux that only serves the version
+// and unseal API. All other requests fail with errSealed.
+func sealedRoutes(routes map[string]api.Route) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(api.PathVersion, routes[api.PathVersion])
+	mux.Handle(api.PathUnseal, routes[api.PathUnseal])
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		api.Failr(&api.Response{ResponseWriter: w}, errSealed)
+	})
+	return mux
+}
+
+// Sealed reports whether the server is sealed. A sealed server
+// only serves the version and unseal API. See SealConfig.
+func (s *Server) Sealed() bool {
+	state := s.state.Load()
+	return state != nil && state.Seal != nil
+}
+
+// unseal adds the unseal key share sent by the client to the
+// submitted shares and unseals the server once enough shares
+// have been submitted. Requests without a share only return
+// the unseal progress.
+//
+// Only the admin and the unseal operators may submit shares.
+// Each submission is audited with the client identity.
+func (s *Server) unseal(resp *api.Response, req *api.Request) {
+	state := s.state.Load()
+	if state.Seal == nil {
+		api.ReplyWith(resp, http.StatusOK, api.UnsealResponse{Sealed: false})
+		return
+	}
+
+	if !state.Seal.Allows(req.Identity, state.Admin) {
+		state.Audit.Log("unseal key share rejected: identity is not an unseal operator", http.StatusForbidden, req)
+		resp.Failr(kes.ErrNotAllowed)
+		return
+	}
+
+	var body api.UnsealRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+		resp.Failf(http.StatusBadRequest, "invalid request body")
+		return
+	}
+	defer secmem.Zero(body.Share)
+
+	progress, sealed, err := state.Seal.Submit(req.Context(), body.Share, func(conf *Config) error {
+		closer, err := s.update(conf, true)
+		if err != nil {
+			return err
+		}
+		return closer.Close()
+	})
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			state.Audit.Log("invalid unseal key share submitted", err.Status(), req)
+			resp.Failr(err)
+			return
+		}
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to unseal server")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	switch {
+	case !sealed:
+		state.Log.InfoContext(req.Context(), "kes: server has been unsealed", "req", req)
+		state.Audit.Log("server unsealed", StatusOK, req)
+	case len(body.Share) > 0:
+		state.Audit.Log("unseal key share submitted", StatusOK, req)
+	}
+	api.ReplyWith(resp, StatusOK, api.UnsealResponse{
+		Sealed:    sealed,
+		Threshold: state.Seal.threshold,
+		Progress:  progress,
+	})
+}
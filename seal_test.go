@@ -0,0 +1,226 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/shamir"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestSeal(t *testing.T) {
+	ctx := testContext(t)
+
+	rootKey := make([]byte, 32)
+	if _, err := rand.Read(rootKey); err != nil {
+		t.Fatalf("Failed to generate root key: %v", err)
+	}
+	shares, err := shamir.Split(rootKey, 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split root key: %v", err)
+	}
+	invalidShares, err := shamir.Split(make([]byte, 32), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split root key: %v", err)
+	}
+
+	conf := &Config{}
+	conf.Seal = &SealConfig{
+		Threshold: 2,
+		Unseal: func(_ context.Context, key []byte) (*Config, error) {
+			if !bytes.Equal(key, rootKey) {
+				return nil, ErrInvalidUnsealKey
+			}
+			return &Config{
+				Admin:    conf.Admin,
+				TLS:      conf.TLS,
+				Cache:    conf.Cache,
+				Keys:     &MemKeyStore{},
+				ErrorLog: discardLog{},
+				AuditLog: discardAudit{},
+			}, nil
+		},
+	}
+	srv, url := startServer(ctx, conf)
+	defer srv.Close()
+
+	client := defaultClient(url)
+	if !srv.Sealed() {
+		t.Fatal("Server is not sealed")
+	}
+	if _, err = client.Version(ctx); err != nil {
+		t.Fatalf("Failed to fetch version of sealed server: %v", err)
+	}
+	if err = client.CreateKey(ctx, "my-key"); !isStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("Creating key on sealed server: got '%v' - want '%v'", err, errSealed)
+	}
+	if _, err = srv.Update(&Config{Admin: conf.Admin, TLS: conf.TLS, Keys: &MemKeyStore{}}); err == nil {
+		t.Fatal("Updating sealed server should have failed")
+	}
+
+	for i, test := range []struct {
+		Share    []byte
+		Sealed   bool
+		Progress int
+		Fail     bool
+	}{
+		{Share: nil, Sealed: true, Progress: 0},               // 0
+		{Share: shares[0], Sealed: true, Progress: 1},         // 1
+		{Share: shares[0], Sealed: true, Progress: 1},         // 2: duplicate share
+		{Share: shares[0][1:], Sealed: true, Fail: true},      // 3: invalid share length
+		{Share: invalidShares[1], Sealed: true, Fail: true},   // 4: wrong root key
+		{Share: shares[1], Sealed: true, Progress: 1},         // 5: shares have been discarded
+		{Share: shares[2], Sealed: false},                     // 6
+		{Share: invalidShares[0], Sealed: false, Progress: 0}, // 7: already unsealed
+	} {
+		var resp api.UnsealResponse
+		err := sendRequest(ctx, client, http.MethodPut, api.PathUnseal, api.UnsealRequest{Share: test.Share}, &resp)
+		if err != nil && !test.Fail {
+			t.Fatalf("Test %d: failed to submit unseal key share: %v", i, err)
+		}
+		if err == nil && test.Fail {
+			t.Fatalf("Test %d: submitting unseal key share should have failed", i)
+		}
+		if test.Fail {
+			continue
+		}
+		if resp.Sealed != test.Sealed {
+			t.Fatalf("Test %d: got sealed '%v' - want '%v'", i, resp.Sealed, test.Sealed)
+		}
+		if resp.Progress != test.Progress {
+			t.Fatalf("Test %d: got progress '%d' - want '%d'", i, resp.Progress, test.Progress)
+		}
+	}
+
+	if srv.Sealed() {
+		t.Fatal("Server is still sealed")
+	}
+	if err = client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key on unsealed server: %v", err)
+	}
+}
+
+func TestSealVerifyShare(t *testing.T) {
+	ctx := testContext(t)
+
+	rootKey := make([]byte, 32)
+	if _, err := rand.Read(rootKey); err != nil {
+		t.Fatalf("Failed to generate root key: %v", err)
+	}
+	shares, err := shamir.Split(rootKey, 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split root key: %v", err)
+	}
+	invalidShares, err := shamir.Split(make([]byte, 32), 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split root key: %v", err)
+	}
+
+	operatorKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	otherKey, err := kes.GenerateAPIKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+
+	var unsealAttempts atomic.Int32
+	conf := &Config{}
+	conf.Seal = &SealConfig{
+		Threshold: 2,
+		Operators: []Identity{operatorKey.Identity()},
+		VerifyShare: func(share []byte) error {
+			for _, s := range shares {
+				if bytes.Equal(s, share) {
+					return nil
+				}
+			}
+			return ErrInvalidUnsealKey
+		},
+		Unseal: func(_ context.Context, key []byte) (*Config, error) {
+			if !bytes.Equal(key, rootKey) {
+				return nil, ErrInvalidUnsealKey
+			}
+			if unsealAttempts.Add(1) == 1 {
+				return nil, errors.New("keystore is not reachable")
+			}
+			return &Config{
+				Admin:    conf.Admin,
+				TLS:      conf.TLS,
+				Cache:    conf.Cache,
+				Keys:     &MemKeyStore{},
+				ErrorLog: discardLog{},
+				AuditLog: discardAudit{},
+			}, nil
+		},
+	}
+	srv, url := startServer(ctx, conf)
+	defer srv.Close()
+
+	newClient := func(key kes.APIKey) *kes.Client {
+		cert, err := kes.GenerateCertificate(key)
+		if err != nil {
+			t.Fatalf("Failed to generate client certificate: %v", err)
+		}
+		tlsConfig := defaultClientTLSConfig()
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &cert, nil }
+		return kes.NewClientWithConfig(url, tlsConfig)
+	}
+	admin, operator, other := defaultClient(url), newClient(operatorKey), newClient(otherKey)
+
+	for i, test := range []struct {
+		Client   *kes.Client
+		Share    []byte
+		Sealed   bool
+		Progress int
+		Status   int
+	}{
+		{Client: other, Share: shares[0], Sealed: true, Status: http.StatusForbidden},           // 0: not an unseal operator
+		{Client: operator, Share: shares[0], Sealed: true, Progress: 1},                         // 1
+		{Client: admin, Share: invalidShares[1], Sealed: true, Status: http.StatusBadRequest},   // 2: invalid share
+		{Client: admin, Share: nil, Sealed: true, Progress: 1},                                  // 3: valid shares have been kept
+		{Client: admin, Share: shares[1], Sealed: true, Status: http.StatusInternalServerError}, // 4: unsealing fails
+		{Client: operator, Share: nil, Sealed: true, Progress: 2},                               // 5: shares have been kept
+		{Client: operator, Share: shares[1], Sealed: false},                                     // 6: unsealing is retried
+	} {
+		var resp api.UnsealResponse
+		err := sendRequest(ctx, test.Client, http.MethodPut, api.PathUnseal, api.UnsealRequest{Share: test.Share}, &resp)
+		if test.Status != 0 {
+			if !isStatus(err, test.Status) {
+				t.Fatalf("Test %d: got '%v' - want status '%d'", i, err, test.Status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: failed to submit unseal key share: %v", i, err)
+		}
+		if resp.Sealed != test.Sealed {
+			t.Fatalf("Test %d: got sealed '%v' - want '%v'", i, resp.Sealed, test.Sealed)
+		}
+		if resp.Progress != test.Progress {
+			t.Fatalf("Test %d: got progress '%d' - want '%d'", i, resp.Progress, test.Progress)
+		}
+	}
+	if srv.Sealed() {
+		t.Fatal("Server is still sealed")
+	}
+}
+
+// isStatus reports whether err is a KES API error with the
+// given HTTP status code.
+func isStatus(err error, code int) bool {
+	var e kes.Error
+	return errors.As(err, &e) && e.Status() == code
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// secretPrefix is the prefix of all secret entries at the
+// KeyStore. Secrets are stored next to keys at the same
+// KeyStore. Valid key names never start with a hyphen,
+// and therefore, cannot collide with secret entries.
+const secretPrefix = "-secret-"
+
+// secret is an opaque value, like an API token, stored
+// at the KeyStore.
+type secret struct {
+	Bytes     []byte
+	Type      kes.SecretType
+	CreatedAt time.Time
+	CreatedBy kes.Identity
+	ExpiresAt time.Time // Zero if the secret does not expire
+}
+
+// Expired reports whether the secret has expired.
+func (s *secret) Expired() bool {
+	return !s.ExpiresAt.IsZero() && !time.Now().Before(s.ExpiresAt)
+}
+
+// MarshalJSON returns the secret's JSON representation.
+func (s *secret) MarshalJSON() ([]byte, error) {
+	type JSON struct {
+		Bytes     []byte         `json:"bytes"`
+		Type      kes.SecretType `json:"type"`
+		CreatedAt time.Time      `json:"created_at"`
+		CreatedBy kes.Identity   `json:"created_by"`
+		ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	}
+	var expiresAt *time.Time
+	if !s.ExpiresAt.IsZero() {
+		expiresAt = &s.ExpiresAt
+	}
+	return json.Marshal(JSON{
+		Bytes:     s.Bytes,
+		Type:      s.Type,
+		CreatedAt: s.CreatedAt,
+		CreatedBy: s.CreatedBy,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// UnmarshalJSON parses the secret's JSON representation.
+func (s *secret) UnmarshalJSON(b []byte) error {
+	type JSON struct {
+		Bytes     []byte         `json:"bytes"`
+		Type      kes.SecretType `json:"type"`
+		CreatedAt time.Time      `json:"created_at"`
+		CreatedBy kes.Identity   `json:"created_by"`
+		ExpiresAt time.Time      `json:"expires_at"`
+	}
+
+	var v JSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	s.Bytes = v.Bytes
+	s.Type = v.Type
+	s.CreatedAt = v.CreatedAt
+	s.CreatedBy = v.CreatedBy
+	s.ExpiresAt = v.ExpiresAt
+	return nil
+}
+
+// CreateSecret creates a new secret with the given name if and
+// only if no such secret exists. Otherwise, kes.ErrSecretExists
+// is returned.
+//
+// Secrets are not cached. They are always read from and written
+// to the underlying KeyStore. Secrets that expire are deleted by
+// the KeyStore if it implements ExpiringKeyStore. Otherwise, they
+// get deleted when accessed or by the periodic sweep of the
+// keyCache. An expired secret that still exists at the KeyStore
+// is replaced.
+func (c *keyCache) CreateSecret(ctx context.Context, name string, s *secret) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	create := func() error {
+		if s.ExpiresAt.IsZero() {
+			return c.store.Create(ctx, secretPrefix+name, b)
+		}
+		return createWithTTL(ctx, c.store, secretPrefix+name, b, time.Until(s.ExpiresAt))
+	}
+	if err = create(); errors.Is(err, kes.ErrKeyExists) {
+		// GetSecret deletes the existing secret if it has expired.
+		if _, err = c.GetSecret(ctx, name); errors.Is(err, kes.ErrSecretNotFound) {
+			err = create()
+		} else if err == nil {
+			err = kes.ErrKeyExists
+		}
+	}
+	if errors.Is(err, kes.ErrKeyExists) {
+		return kes.ErrSecretExists
+	}
+	return err
+}
+
+// DeleteSecret deletes the secret with the given name. It returns
+// kes.ErrSecretNotFound if no such secret exists.
+func (c *keyCache) DeleteSecret(ctx context.Context, name string) error {
+	if err := c.store.Delete(ctx, secretPrefix+name); err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return kes.ErrSecretNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetSecret returns the secret with the given name. It returns
+// kes.ErrSecretNotFound if no such secret exists or the secret
+// has expired.
+func (c *keyCache) GetSecret(ctx context.Context, name string) (*secret, error) {
+	b, err := c.store.Get(ctx, secretPrefix+name)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, kes.ErrSecretNotFound
+		}
+		return nil, err
+	}
+
+	var s secret
+	if err = json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if s.Expired() {
+		// The KeyStore may not delete expired entries on its own.
+		// The secret is gone for clients, so we try to remove it.
+		// However, we don't care if that fails.
+		_ = c.store.Delete(ctx, secretPrefix+name)
+		return nil, kes.ErrSecretNotFound
+	}
+	return &s, nil
+}
+
+// ListSecrets returns the first n secret names, that start with
+// the given prefix, and the secret name at which the listing should
+// continue. It continues a previous listing at continueAt, unless
+// continueAt is empty. Otherwise, it behaves like List.
+func (c *keyCache) ListSecrets(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
+	if continueAt != "" {
+		continueAt = secretPrefix + continueAt
+	}
+	names, continueAt, err := listFrom(ctx, c.store, secretPrefix+prefix, continueAt, n)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range names {
+		names[i] = strings.TrimPrefix(names[i], secretPrefix)
+	}
+	return names, strings.TrimPrefix(continueAt, secretPrefix), nil
+}
+
+// deleteExpiredSecrets deletes all expired secrets. It is used to
+// clean up KeyStores that do not delete expired entries on their own.
+func (c *keyCache) deleteExpiredSecrets(ctx context.Context) {
+	const PageSize = 250
+
+	var continueAt string
+	for {
+		names, next, err := c.ListSecrets(ctx, "", continueAt, PageSize)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			_, _ = c.GetSecret(ctx, name) // GetSecret deletes the secret if it has expired
+		}
+		if next == "" {
+			return
+		}
+		continueAt = next
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+)
+
+// probePrefix is the prefix of the temporary entries created by
+// a KeyStore self-test. Valid key names never start with a hyphen,
+// and therefore, cannot collide with probe entries.
+const probePrefix = "-probe-"
+
+// selfTest performs a create, read, list and delete round trip with
+// a temporary probe entry against the KeyStore and returns the result
+// and latency of each step. For a CryptoKeyStore, it creates a probe
+// key and encrypts and decrypts a value instead of reading and
+// listing it.
+//
+// The self-test stops at the first failing step. However, it always
+// tries to delete the probe entry once it has been created.
+func selfTest(ctx context.Context, store KeyStore) []api.KeyStoreTestStep {
+	var (
+		steps []api.KeyStoreTestStep
+		err   error
+	)
+	step := func(name string, f func() error) bool {
+		start := time.Now()
+		err = f()
+
+		s := api.KeyStoreTestStep{
+			Name:    name,
+			Latency: time.Since(start).Microseconds(),
+		}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		steps = append(steps, s)
+		return err == nil
+	}
+
+	var id [8]byte
+	if _, err = rand.Read(id[:]); err != nil {
+		return []api.KeyStoreTestStep{{Name: "init", Error: err.Error()}}
+	}
+	name := probePrefix + hex.EncodeToString(id[:])
+
+	var value [32]byte
+	if _, err = rand.Read(value[:]); err != nil {
+		return []api.KeyStoreTestStep{{Name: "init", Error: err.Error()}}
+	}
+
+	if !step("status", func() error { _, err := store.Status(ctx); return err }) {
+		return steps
+	}
+
+	if crypto := cryptoKeyStore(store); crypto != nil {
+		if !step("create", func() error { return crypto.CreateKey(ctx, name) }) {
+			return steps
+		}
+		var ciphertext []byte
+		_ = step("encrypt", func() (err error) {
+			// Encryption may happen in-place. Hence, we
+			// encrypt a copy of the probe value.
+			ciphertext, err = crypto.Encrypt(ctx, name, bytes.Clone(value[:]), nil)
+			return err
+		}) && step("decrypt", func() error {
+			plaintext, err := crypto.Decrypt(ctx, name, ciphertext, nil)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(plaintext, value[:]) {
+				return errors.New("decrypted value does not match encrypted value")
+			}
+			return nil
+		})
+	} else {
+		if !step("create", func() error { return store.Create(ctx, name, value[:]) }) {
+			return steps
+		}
+		_ = step("get", func() error {
+			v, err := store.Get(ctx, name)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(v, value[:]) {
+				return errors.New("value read does not match value written")
+			}
+			return nil
+		}) && step("list", func() error {
+			names, _, err := store.List(ctx, name, -1)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(names, name) {
+				return errors.New("probe entry is not listed")
+			}
+			return nil
+		})
+	}
+
+	step("delete", func() error { return store.Delete(ctx, name) })
+	return steps
+}
@@ -5,16 +5,26 @@
 package kes
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/keystore"
 	"github.com/minio/kms-go/kes"
 )
 
@@ -44,6 +54,126 @@ const (
 	defaultIdentity = "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22"
 )
 
+func TestServerClose(t *testing.T) {
+	ctx := testContext(t)
+
+	store := &blockingKeyStore{
+		MemKeyStore: &MemKeyStore{},
+		entered:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	audit := &flushAudit{}
+	srv, endpoint := startServer(ctx, &Config{
+		Keys:     store,
+		AuditLog: audit,
+	})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- defaultClient(endpoint).CreateKey(ctx, "my-key") }()
+	<-store.entered
+
+	closeCh := make(chan error, 1)
+	go func() { closeCh <- srv.Close() }()
+
+	select {
+	case <-closeCh:
+		t.Fatal("Server has been closed before in-flight request finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(store.release)
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("In-flight request failed: %v", err)
+	}
+	if err := <-closeCh; err != nil {
+		t.Fatalf("Failed to close server: %v", err)
+	}
+	if !audit.flushed.Load() {
+		t.Fatal("Audit log has not been flushed")
+	}
+	if !store.closed.Load() {
+		t.Fatal("KeyStore has not been closed")
+	}
+}
+
+func TestCryptoKeyStore(t *testing.T) {
+	ctx := testContext(t)
+
+	_, endpoint := startServer(ctx, &Config{
+		Keys: &memCryptoKeyStore{MemKeyStore: &MemKeyStore{}},
+	})
+	client := defaultClient(endpoint)
+
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "my-key"); !errors.Is(err, kes.ErrKeyExists) {
+		t.Fatalf("Creating existing key: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+
+	dek, err := client.GenerateKey(ctx, "my-key", []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	plaintext, err := client.Decrypt(ctx, "my-key", dek.Ciphertext, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatal("Decrypted data key does not match generated data key")
+	}
+	if _, err = client.Encrypt(ctx, "other-key", []byte("plaintext"), nil); !errors.Is(err, kes.ErrKeyNotFound) {
+		t.Fatalf("Encrypting with missing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	// Operations requiring key material must fail.
+	var kErr kes.Error
+	if _, err = client.HMAC(ctx, "my-key", []byte("message")); !errors.As(err, &kErr) || kErr.Status() != http.StatusNotImplemented {
+		t.Fatalf("Computing HMAC: got '%v' - want status %d", err, http.StatusNotImplemented)
+	}
+}
+
+func TestKeyStoreErrorCode(t *testing.T) {
+	ctx := testContext(t)
+
+	_, endpoint := startServer(ctx, &Config{
+		Keys: throttledKeyStore{&MemKeyStore{}},
+	})
+	client := defaultClient(endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/key/create/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", resp.StatusCode, http.StatusBadGateway)
+	}
+	if body.Code != keystore.CodeThrottled {
+		t.Fatalf("Error code mismatch: got '%s' - want '%s'", body.Code, keystore.CodeThrottled)
+	}
+}
+
+// throttledKeyStore is a KeyStore that throttles all
+// create requests.
+type throttledKeyStore struct{ *MemKeyStore }
+
+func (throttledKeyStore) Create(context.Context, string, []byte) error {
+	return &keystore.ErrThrottled{Err: errors.New("too many requests")}
+}
+
 func startServer(ctx context.Context, conf *Config) (*Server, string) {
 	ln := newLocalListener()
 
@@ -112,6 +242,12 @@ func testContext(t *testing.T) context.Context {
 }
 
 func defaultClient(endpoint string) *kes.Client {
+	return kes.NewClientWithConfig(endpoint, defaultClientTLSConfig())
+}
+
+// defaultClientTLSConfig returns a TLS config that authenticates
+// as the default admin identity.
+func defaultClientTLSConfig() *tls.Config {
 	adminKey, err := kes.ParseAPIKey(defaultAPIKey)
 	if err != nil {
 		panic(fmt.Sprintf("kes: failed to parse API key '%s': %v", defaultAPIKey, err))
@@ -124,13 +260,77 @@ func defaultClient(endpoint string) *kes.Client {
 	rootCAs := x509.NewCertPool()
 	rootCAs.AddCert(defaultServerCertificate().Leaf)
 
-	return kes.NewClientWithConfig(endpoint, &tls.Config{
+	return &tls.Config{
 		MinVersion: tls.VersionTLS12,
 		RootCAs:    rootCAs,
 		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
 			return &clientCert, nil
 		},
-	})
+	}
+}
+
+// enclaveClient returns a new client that sends all
+// requests to the named enclave.
+func enclaveClient(endpoint, enclave string) *kes.Client {
+	client := defaultClient(endpoint)
+	client.HTTPClient.Transport = enclaveTransport{
+		RoundTripper: client.HTTPClient.Transport,
+		Enclave:      enclave,
+	}
+	return client
+}
+
+type enclaveTransport struct {
+	http.RoundTripper
+	Enclave string
+}
+
+func (t enclaveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(headers.KesEnclave, t.Enclave)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// sendRequest sends a request to the client's first endpoint
+// and decodes the response body into resp, if not nil. The
+// request body, if not nil, is sent as JSON.
+//
+// It returns a kes.Error if the server does not respond with
+// 200 OK. It can be used to test APIs the client does not
+// support.
+func sendRequest(ctx context.Context, client *kes.Client, method, path string, body, resp any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, client.Endpoints[0]+path, r)
+	if err != nil {
+		return err
+	}
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if err = json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return fmt.Errorf("%s: %v", res.Status, err)
+		}
+		return kes.NewError(res.StatusCode, e.Message)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
 }
 
 func newLocalListener() net.Listener {
@@ -167,3 +367,82 @@ type discardAudit struct{}
 func (discardAudit) Enabled(context.Context, slog.Level) bool { return false }
 
 func (discardAudit) Handle(context.Context, AuditRecord) error { return nil }
+
+// blockingKeyStore is a MemKeyStore whose Create blocks
+// until release is closed.
+type blockingKeyStore struct {
+	*MemKeyStore
+
+	entered, release chan struct{}
+	closed           atomic.Bool
+}
+
+func (s *blockingKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	close(s.entered)
+	<-s.release
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.MemKeyStore.Create(ctx, name, value)
+}
+
+func (s *blockingKeyStore) Close() error {
+	s.closed.Store(true)
+	return s.MemKeyStore.Close()
+}
+
+// memCryptoKeyStore is a MemKeyStore that implements CryptoKeyStore.
+// It never reveals the keys it creates.
+type memCryptoKeyStore struct {
+	*MemKeyStore
+}
+
+func (s *memCryptoKeyStore) CreateKey(ctx context.Context, name string) error {
+	key, err := crypto.GenerateSecretKey(crypto.AES256, rand.Reader)
+	if err != nil {
+		return err
+	}
+	hmac, err := crypto.GenerateHMACKey(crypto.SHA256, rand.Reader)
+	if err != nil {
+		return err
+	}
+	b, err := crypto.EncodeKey(crypto.Key{Versions: []crypto.KeyVersion{{Key: key, HMACKey: hmac}}})
+	if err != nil {
+		return err
+	}
+	return s.MemKeyStore.Create(ctx, name, b)
+}
+
+func (s *memCryptoKeyStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	key, err := s.key(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return key.Latest().Key.Encrypt(plaintext, associatedData)
+}
+
+func (s *memCryptoKeyStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	key, err := s.key(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return key.Decrypt(ciphertext, associatedData)
+}
+
+func (s *memCryptoKeyStore) key(ctx context.Context, name string) (crypto.Key, error) {
+	b, err := s.MemKeyStore.Get(ctx, name)
+	if err != nil {
+		return crypto.Key{}, err
+	}
+	return crypto.ParseKey(b)
+}
+
+type flushAudit struct {
+	discardAudit
+	flushed atomic.Bool
+}
+
+func (a *flushAudit) Flush() error {
+	a.flushed.Store(true)
+	return nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/metric"
+)
+
+// SlowLogConfig is a structure containing the configuration for
+// logging slow KeyStore operations.
+//
+// A KES server logs every KeyStore operation that takes longer
+// than its threshold, including the KeyStore backend, the operation
+// and a hash of the key name, and counts it in the
+// kes_keystore_slow_operations metric.
+type SlowLogConfig struct {
+	// Threshold is the latency above which a KeyStore operation
+	// is considered slow.
+	Threshold time.Duration
+
+	// Operations contains per-operation thresholds that override
+	// Threshold. The valid operations are: "status", "create",
+	// "delete", "get", "list", "set", "metadata", "set_metadata",
+	// "create_key", "encrypt", "decrypt" and "generate_key".
+	//
+	// A zero threshold disables logging of the operation.
+	Operations map[string]time.Duration
+}
+
+// Slow KeyStore operations.
+const (
+	slowOpStatus      = "status"
+	slowOpCreate      = "create"
+	slowOpDelete      = "delete"
+	slowOpGet         = "get"
+	slowOpList        = "list"
+	slowOpSet         = "set"
+	slowOpMetadata    = "metadata"
+	slowOpSetMetadata = "set_metadata"
+	slowOpCreateKey   = "create_key"
+	slowOpEncrypt     = "encrypt"
+	slowOpDecrypt     = "decrypt"
+	slowOpGenerateKey = "generate_key"
+)
+
+// validSlowOp reports whether op is a KeyStore operation
+// that can be logged when slow.
+func validSlowOp(op string) bool {
+	switch op {
+	case slowOpStatus, slowOpCreate, slowOpDelete, slowOpGet, slowOpList, slowOpSet,
+		slowOpMetadata, slowOpSetMetadata, slowOpCreateKey, slowOpEncrypt, slowOpDecrypt, slowOpGenerateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// newSlowLogKeyStore returns a KeyStore that logs and counts slow
+// operations of store. It returns store if conf is nil.
+func newSlowLogKeyStore(store KeyStore, conf *SlowLogConfig, log *slog.Logger, metrics *metric.Metrics) KeyStore {
+	if conf == nil || store == nil {
+		return store
+	}
+	s := &slowLogKeyStore{
+		store:      store,
+		backend:    keyStoreBackend(store),
+		threshold:  conf.Threshold,
+		operations: make(map[string]time.Duration, len(conf.Operations)),
+		log:        log,
+		metrics:    metrics,
+	}
+	for op, threshold := range conf.Operations {
+		s.operations[op] = threshold
+	}
+	if _, ok := store.(WatchableKeyStore); ok {
+		return &watchableSlowLogKeyStore{s}
+	}
+	return s
+}
+
+// keyStoreBackend returns a short name of the KeyStore's backend,
+// like "Hashicorp Vault".
+func keyStoreBackend(store KeyStore) string {
+	store = baseKeyStore(store)
+	if s, ok := store.(fmt.Stringer); ok {
+		name, _, _ := strings.Cut(s.String(), ":")
+		return name
+	}
+	return fmt.Sprintf("%T", store)
+}
+
+// slowLogKeyStore is a KeyStore that logs and counts operations
+// of another KeyStore exceeding a latency threshold.
+//
+// Like the KeyStore of an enclave, it implements all optional
+// KeyStore interfaces and falls back to the behavior of the KES
+// server if the wrapped KeyStore does not implement them.
+type slowLogKeyStore struct {
+	store      KeyStore
+	backend    string
+	threshold  time.Duration
+	operations map[string]time.Duration
+	log        *slog.Logger
+	metrics    *metric.Metrics
+}
+
+// watchableSlowLogKeyStore is a slowLogKeyStore on top of a
+// WatchableKeyStore.
+type watchableSlowLogKeyStore struct {
+	*slowLogKeyStore
+}
+
+var _ WatchableKeyStore = (*watchableSlowLogKeyStore)(nil) // compiler check
+
+// Watch returns a channel that receives an event whenever an entry,
+// whose name starts with the given prefix, is created, replaced or
+// deleted. Watching is not logged since it is a long-running operation.
+func (s *watchableSlowLogKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	return s.store.(WatchableKeyStore).Watch(ctx, prefix)
+}
+
+var ( // compiler checks
+	_ ConditionalKeyStore = (*slowLogKeyStore)(nil)
+	_ DataKeyStore        = (*slowLogKeyStore)(nil)
+	_ ExpiringKeyStore    = (*slowLogKeyStore)(nil)
+	_ MetadataKeyStore    = (*slowLogKeyStore)(nil)
+	_ PaginatedKeyStore   = (*slowLogKeyStore)(nil)
+)
+
+// String returns the string representation of the wrapped KeyStore.
+func (s *slowLogKeyStore) String() string {
+	if store, ok := s.store.(fmt.Stringer); ok {
+		return store.String()
+	}
+	return s.backend
+}
+
+// Status returns the current state of the KeyStore.
+func (s *slowLogKeyStore) Status(ctx context.Context) (KeyStoreState, error) {
+	defer s.observe(ctx, slowOpStatus, "", time.Now())
+	return s.store.Status(ctx)
+}
+
+// Create creates a new entry at the KeyStore.
+func (s *slowLogKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	defer s.observe(ctx, slowOpCreate, name, time.Now())
+	return s.store.Create(ctx, name, value)
+}
+
+// CreateWithTTL creates a new entry that expires after the given
+// ttl. It creates the entry without a TTL if the KeyStore does not
+// implement ExpiringKeyStore.
+func (s *slowLogKeyStore) CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error {
+	defer s.observe(ctx, slowOpCreate, name, time.Now())
+	return createWithTTL(ctx, s.store, name, value, ttl)
+}
+
+// Set replaces the value of an existing entry. It fails if the
+// KeyStore is not mutable.
+func (s *slowLogKeyStore) Set(ctx context.Context, name string, value []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	defer s.observe(ctx, slowOpSet, name, time.Now())
+	return store.Set(ctx, name, value)
+}
+
+// SetIf replaces the value of an existing entry if its current
+// value is equal to old. It replaces the value unconditionally if
+// the KeyStore does not implement ConditionalKeyStore and fails if
+// it is not mutable.
+func (s *slowLogKeyStore) SetIf(ctx context.Context, name string, value, old []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	defer s.observe(ctx, slowOpSet, name, time.Now())
+	return setIf(ctx, store, name, value, old)
+}
+
+// Metadata returns the metadata of the entry. It returns an empty
+// EntryMetadata if the KeyStore does not implement MetadataKeyStore.
+func (s *slowLogKeyStore) Metadata(ctx context.Context, name string) (EntryMetadata, error) {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return EntryMetadata{}, nil
+	}
+	defer s.observe(ctx, slowOpMetadata, name, time.Now())
+	return store.Metadata(ctx, name)
+}
+
+// SetMetadata stores the metadata of the entry. It does nothing if
+// the KeyStore does not implement MetadataKeyStore.
+func (s *slowLogKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return nil
+	}
+	defer s.observe(ctx, slowOpSetMetadata, name, time.Now())
+	return store.SetMetadata(ctx, name, metadata)
+}
+
+// CreateKey creates a new key at the KeyStore. It fails if the
+// KeyStore is not a CryptoKeyStore.
+func (s *slowLogKeyStore) CreateKey(ctx context.Context, name string) error {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return errKeyMaterialNotSupported
+	}
+	defer s.observe(ctx, slowOpCreateKey, name, time.Now())
+	return store.CreateKey(ctx, name)
+}
+
+// Encrypt encrypts the plaintext with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *slowLogKeyStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	defer s.observe(ctx, slowOpEncrypt, name, time.Now())
+	return store.Encrypt(ctx, name, plaintext, associatedData)
+}
+
+// GenerateKey generates a data key with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *slowLogKeyStore) GenerateKey(ctx context.Context, name string, associatedData []byte) ([]byte, []byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, nil, errKeyMaterialNotSupported
+	}
+	defer s.observe(ctx, slowOpGenerateKey, name, time.Now())
+	return generateKey(ctx, store, name, associatedData)
+}
+
+// Decrypt decrypts the ciphertext with the key at the KeyStore. It
+// fails if the KeyStore is not a CryptoKeyStore.
+func (s *slowLogKeyStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) ([]byte, error) {
+	store, ok := s.store.(CryptoKeyStore)
+	if !ok {
+		return nil, errKeyMaterialNotSupported
+	}
+	defer s.observe(ctx, slowOpDecrypt, name, time.Now())
+	return store.Decrypt(ctx, name, ciphertext, associatedData)
+}
+
+// Delete removes the entry from the KeyStore.
+func (s *slowLogKeyStore) Delete(ctx context.Context, name string) error {
+	defer s.observe(ctx, slowOpDelete, name, time.Now())
+	return s.store.Delete(ctx, name)
+}
+
+// Get returns the value of the entry.
+func (s *slowLogKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	defer s.observe(ctx, slowOpGet, name, time.Now())
+	return s.store.Get(ctx, name)
+}
+
+// List returns the first n entry names that start with the
+// given prefix.
+func (s *slowLogKeyStore) List(ctx context.Context, prefix string, n int) ([]string, string, error) {
+	defer s.observe(ctx, slowOpList, "", time.Now())
+	return s.store.List(ctx, prefix, n)
+}
+
+// ListFrom behaves like List but continues the listing at the
+// given entry name.
+func (s *slowLogKeyStore) ListFrom(ctx context.Context, prefix, continueAt string, n int) ([]string, string, error) {
+	defer s.observe(ctx, slowOpList, "", time.Now())
+	return listFrom(ctx, s.store, prefix, continueAt, n)
+}
+
+// Close closes the KeyStore.
+func (s *slowLogKeyStore) Close() error { return s.store.Close() }
+
+// observe logs and counts the operation on the named entry if
+// it took longer than the operation's threshold since start.
+//
+// It logs a hash of the entry name instead of the name itself
+// since key names may reveal information about the data they
+// protect.
+func (s *slowLogKeyStore) observe(ctx context.Context, op, name string, start time.Time) {
+	latency := time.Since(start)
+
+	threshold, ok := s.operations[op]
+	if !ok {
+		threshold = s.threshold
+	}
+	if threshold <= 0 || latency < threshold {
+		return
+	}
+
+	if s.metrics != nil {
+		s.metrics.CountSlowKeyStoreOp(s.backend, op)
+	}
+	if s.log != nil {
+		attrs := []any{
+			"backend", s.backend,
+			"operation", op,
+			"latency", latency,
+			"threshold", threshold,
+		}
+		if name != "" {
+			h := sha256.Sum256([]byte(name))
+			attrs = append(attrs, "key_hash", hex.EncodeToString(h[:8]))
+		}
+		s.log.WarnContext(ctx, "kes: slow keystore operation", attrs...)
+	}
+}
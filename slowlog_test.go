@@ -0,0 +1,91 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/metric"
+)
+
+func TestSlowLogKeyStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+
+	store := newSlowLogKeyStore(slowKeyStore{&MemKeyStore{}, 10 * time.Millisecond}, &SlowLogConfig{
+		Threshold: time.Millisecond,
+		Operations: map[string]time.Duration{
+			slowOpCreate: time.Hour,
+		},
+	}, log, metric.New())
+
+	if err := store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Operation below its threshold has been logged: %s", buf.String())
+	}
+
+	if _, err := store.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to get entry: %v", err)
+	}
+	h := sha256.Sum256([]byte("my-key"))
+	for _, want := range []string{
+		"operation=get",
+		`backend="In Memory"`,
+		"key_hash=" + hex.EncodeToString(h[:8]),
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("Slow operation log does not contain '%s': %s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "my-key") {
+		t.Fatalf("Slow operation log contains the key name: %s", buf.String())
+	}
+}
+
+func TestSlowLogKeyStoreInterfaces(t *testing.T) {
+	t.Parallel()
+
+	conf := &SlowLogConfig{Threshold: time.Second}
+	if _, ok := newSlowLogKeyStore(&MemKeyStore{}, conf, nil, nil).(WatchableKeyStore); !ok {
+		t.Fatal("Slow log KeyStore on a WatchableKeyStore is not watchable")
+	}
+	if _, ok := newSlowLogKeyStore(struct{ KeyStore }{&MemKeyStore{}}, conf, nil, nil).(WatchableKeyStore); ok {
+		t.Fatal("Slow log KeyStore on a KeyStore without Watch is watchable")
+	}
+
+	store := newSlowLogKeyStore(struct{ KeyStore }{&MemKeyStore{}}, conf, nil, nil)
+	if cryptoKeyStore(store) != nil {
+		t.Fatal("Slow log KeyStore on a KeyStore is a CryptoKeyStore")
+	}
+	if expiringKeyStore(store) {
+		t.Fatal("Slow log KeyStore on a KeyStore is an ExpiringKeyStore")
+	}
+	if !expiringKeyStore(newSlowLogKeyStore(&MemKeyStore{}, conf, nil, nil)) {
+		t.Fatal("Slow log KeyStore on an ExpiringKeyStore is not an ExpiringKeyStore")
+	}
+}
+
+// slowKeyStore is a KeyStore that delays Get operations.
+type slowKeyStore struct {
+	*MemKeyStore
+	Delay time.Duration
+}
+
+func (s slowKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	time.Sleep(s.Delay)
+	return s.MemKeyStore.Get(ctx, name)
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/secmem"
+	"github.com/minio/kms-go/kes"
+)
+
+// SoftDeleteConfig is a structure containing the configuration
+// for soft-deleting keys.
+//
+// A soft-deleted key cannot be used but can be restored until its
+// retention period has elapsed. Afterwards, it gets deleted
+// permanently.
+type SoftDeleteConfig struct {
+	// Retention is the period during which a deleted key can
+	// be restored. It must be positive.
+	Retention time.Duration
+}
+
+// deletedPrefix is the prefix of all soft-deleted keys at the
+// KeyStore. A soft-deleted key is renamed instead of deleted.
+// Valid key names never start with a hyphen, and therefore,
+// cannot collide with soft-deleted keys.
+const deletedPrefix = "-deleted-"
+
+// deletedKey is a soft-deleted key stored at the KeyStore.
+type deletedKey struct {
+	Bytes      []byte                    // The encoded key
+	Tags       map[string]string         // The key tags, if any
+	Operations []string                  // The operations the key may be used for, if restricted
+	Grants     map[kes.Identity][]string // The operations granted on the key, if any
+	DeletedAt  time.Time
+	PurgeAt    time.Time // Time at which the key gets deleted permanently
+}
+
+// Expired reports whether the key's retention period has elapsed.
+func (k *deletedKey) Expired() bool { return !time.Now().Before(k.PurgeAt) }
+
+// MarshalJSON returns the deleted key's JSON representation.
+func (k *deletedKey) MarshalJSON() ([]byte, error) {
+	type JSON struct {
+		Bytes      []byte                    `json:"bytes"`
+		Tags       map[string]string         `json:"tags,omitempty"`
+		Operations []string                  `json:"operations,omitempty"`
+		Grants     map[kes.Identity][]string `json:"grants,omitempty"`
+		DeletedAt  time.Time                 `json:"deleted_at"`
+		PurgeAt    time.Time                 `json:"purge_at"`
+	}
+	return json.Marshal(JSON(*k))
+}
+
+// UnmarshalJSON parses the deleted key's JSON representation.
+func (k *deletedKey) UnmarshalJSON(b []byte) error {
+	type JSON struct {
+		Bytes      []byte                    `json:"bytes"`
+		Tags       map[string]string         `json:"tags"`
+		Operations []string                  `json:"operations"`
+		Grants     map[kes.Identity][]string `json:"grants"`
+		DeletedAt  time.Time                 `json:"deleted_at"`
+		PurgeAt    time.Time                 `json:"purge_at"`
+	}
+
+	var v JSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*k = deletedKey(v)
+	return nil
+}
+
+// softDelete moves the key with the given name to a separate entry
+// that gets deleted once the retention period has elapsed. It returns
+// kes.ErrKeyNotFound if no such key exists.
+//
+// A previously soft-deleted key with the same name is replaced.
+func (c *keyCache) softDelete(ctx context.Context, name string, retention time.Duration) error {
+	b, err := c.store.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return kes.ErrKeyNotFound
+		}
+		return err
+	}
+	defer secmem.Zero(b)
+
+	var m EntryMetadata
+	if store, ok := c.store.(MetadataKeyStore); ok {
+		if m, err = store.Metadata(ctx, name); err != nil {
+			return err // Don't lose the operations the key is restricted to
+		}
+	}
+
+	now := time.Now().UTC()
+	v, err := json.Marshal(&deletedKey{
+		Bytes:      b,
+		Tags:       m.Tags,
+		Operations: m.Operations,
+		Grants:     m.Grants,
+		DeletedAt:  now,
+		PurgeAt:    now.Add(retention),
+	})
+	if err != nil {
+		return err
+	}
+	defer secmem.Zero(v)
+
+	if err = c.store.Delete(ctx, deletedPrefix+name); err != nil && !errors.Is(err, kes.ErrKeyNotFound) {
+		return err
+	}
+	if err = createWithTTL(ctx, c.store, deletedPrefix+name, v, retention); err != nil {
+		return err
+	}
+	return c.store.Delete(ctx, name)
+}
+
+// Undelete restores the soft-deleted key with the given name. It
+// returns kes.ErrKeyNotFound if no such soft-deleted key exists or
+// its retention period has elapsed, and kes.ErrKeyExists if a key
+// with the same name has been created in the meantime.
+//
+// Undelete fails if the key store is a CryptoKeyStore since its
+// keys are never soft-deleted.
+func (c *keyCache) Undelete(ctx context.Context, name string) error {
+	if c.crypto != nil {
+		return errKeyMaterialNotSupported
+	}
+	key, err := c.getDeleted(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer secmem.Zero(key.Bytes)
+
+	k, err := crypto.ParseKey(key.Bytes)
+	if err != nil {
+		return err
+	}
+
+	// The operations and grants are not informational. Hence, they
+	// are restored alongside the key. Otherwise, a restricted key
+	// could be used for any operation until they have been restored.
+	if len(key.Tags) > 0 || len(key.Operations) > 0 || len(key.Grants) > 0 {
+		errNotSupported := errTagsNotSupported
+		if len(key.Operations) > 0 {
+			errNotSupported = errOperationsNotSupported
+		} else if len(key.Grants) > 0 {
+			errNotSupported = errGrantsNotSupported
+		}
+		err = c.CreateVersionsWithMetadata(ctx, name, k, errNotSupported, func(m *EntryMetadata) {
+			m.Tags, m.Operations, m.Grants = key.Tags, key.Operations, key.Grants
+		})
+	} else {
+		err = c.CreateVersions(ctx, name, k)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The key has been restored. If deleting the soft-deleted
+	// copy fails, it gets deleted once it has expired.
+	_ = c.store.Delete(ctx, deletedPrefix+name)
+	return nil
+}
+
+// getDeleted returns the soft-deleted key with the given name. It
+// returns kes.ErrKeyNotFound if no such key exists or its retention
+// period has elapsed.
+func (c *keyCache) getDeleted(ctx context.Context, name string) (*deletedKey, error) {
+	b, err := c.store.Get(ctx, deletedPrefix+name)
+	if err != nil {
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			return nil, kes.ErrKeyNotFound
+		}
+		return nil, err
+	}
+	defer secmem.Zero(b)
+
+	var key deletedKey
+	if err = json.Unmarshal(b, &key); err != nil {
+		return nil, err
+	}
+	if key.Expired() {
+		// The KeyStore may not delete expired entries on its own.
+		// The key is gone for clients, so we try to remove it.
+		// However, we don't care if that fails.
+		secmem.Zero(key.Bytes)
+		_ = c.store.Delete(ctx, deletedPrefix+name)
+		return nil, kes.ErrKeyNotFound
+	}
+	return &key, nil
+}
+
+// deleteExpiredKeys permanently deletes all soft-deleted keys
+// whose retention period has elapsed. It is used to clean up
+// KeyStores that do not delete expired entries on their own.
+func (c *keyCache) deleteExpiredKeys(ctx context.Context) {
+	const PageSize = 250
+
+	var continueAt string
+	for {
+		names, next, err := listFrom(ctx, c.store, deletedPrefix, continueAt, PageSize)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			// getDeleted deletes the key if it has expired
+			if key, err := c.getDeleted(ctx, strings.TrimPrefix(name, deletedPrefix)); err == nil {
+				secmem.Zero(key.Bytes)
+			}
+		}
+		if next == "" {
+			return
+		}
+		continueAt = next
+	}
+}
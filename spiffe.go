@@ -0,0 +1,129 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// spiffeScheme is the URI scheme prefix of SPIFFE IDs.
+const spiffeScheme = "spiffe://"
+
+// spiffeID returns the SPIFFE ID of the request's X.509-SVID.
+//
+// It only returns a SPIFFE ID if the client certificate has been
+// verified during the TLS handshake, i.e. has been issued by a CA
+// of the trust bundle, and contains exactly one valid SPIFFE ID as
+// URI SAN. Otherwise, any client could claim any SPIFFE ID.
+func spiffeID(req *http.Request) (kes.Identity, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	leaf := req.TLS.VerifiedChains[0][0]
+	if leaf.IsCA || len(leaf.URIs) != 1 {
+		return "", false
+	}
+
+	id := leaf.URIs[0].String()
+	if !validSPIFFEPattern(id) || strings.HasSuffix(id, "*") {
+		return "", false
+	}
+	return kes.Identity(id), true
+}
+
+// lookupIdentity returns the identityEntry of the identity.
+//
+// If the identity is a SPIFFE ID without an exact match, it returns
+// the entry of the most specific SPIFFE ID pattern matching it. For
+// example, "spiffe://example.org/ns/minio/*" matches all workloads
+// of the "example.org" trust domain within the "minio" namespace.
+func lookupIdentity(identities map[kes.Identity]identityEntry, identity kes.Identity) (identityEntry, bool) {
+	if entry, ok := identities[identity]; ok || !strings.HasPrefix(identity.String(), spiffeScheme) {
+		return entry, ok
+	}
+
+	var (
+		match  identityEntry
+		length = -1
+	)
+	for pattern, entry := range identities {
+		prefix, ok := strings.CutSuffix(pattern.String(), "*")
+		if !ok || !strings.HasPrefix(prefix, spiffeScheme) {
+			continue
+		}
+		if len(prefix) > length && strings.HasPrefix(identity.String(), prefix) {
+			match, length = entry, len(prefix)
+		}
+	}
+	return match, length >= 0
+}
+
+// validSPIFFEPattern reports whether s is a valid SPIFFE ID or
+// SPIFFE ID pattern.
+//
+// A SPIFFE ID has the form "spiffe://<trust-domain>/<path>". The
+// trust domain only contains the characters a-z, 0-9, '.', '-' and
+// '_'. Path segments only contain the characters a-z, A-Z, 0-9,
+// '.', '-' and '_' and must not be "." or "..". A pattern may end
+// with a '*' matching all SPIFFE IDs with the same prefix, like
+// "spiffe://example.org/*" for the entire trust domain.
+func validSPIFFEPattern(s string) bool {
+	const MaxLength = 2048 // Max. SPIFFE ID length
+
+	if len(s) > MaxLength {
+		return false
+	}
+	s, ok := strings.CutPrefix(s, spiffeScheme)
+	if !ok {
+		return false
+	}
+	s, wildcard := strings.CutSuffix(s, "*")
+
+	domain, path, hasPath := strings.Cut(s, "/")
+	if domain == "" {
+		return false
+	}
+	for _, r := range domain {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9':
+		case r == '.' || r == '-' || r == '_':
+		default:
+			return false
+		}
+	}
+	if !hasPath {
+		return !wildcard // The '*' must be part of the path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			// Only the last segment of a pattern may be empty,
+			// like in "spiffe://example.org/ns/*".
+			if wildcard && i == len(segments)-1 {
+				continue
+			}
+			return false
+		}
+		if segment == "." || segment == ".." {
+			return false
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z':
+			case r >= 'A' && r <= 'Z':
+			case r >= '0' && r <= '9':
+			case r == '.' || r == '-' || r == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
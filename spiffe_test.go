@@ -0,0 +1,142 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestValidSPIFFEPattern(t *testing.T) {
+	t.Parallel()
+	for i, test := range validSPIFFEPatternTests {
+		if valid := validSPIFFEPattern(test.Pattern); valid != !test.ShouldFail {
+			t.Errorf("Test %d: got 'valid=%v' - want 'fail=%v' for pattern '%s'", i, valid, test.ShouldFail, test.Pattern)
+		}
+	}
+}
+
+func TestLookupIdentity(t *testing.T) {
+	t.Parallel()
+
+	identities := map[kes.Identity]identityEntry{
+		"spiffe://example.org/*":                 {Name: "trust-domain"},
+		"spiffe://example.org/ns/minio/*":        {Name: "namespace"},
+		"spiffe://example.org/ns/minio/sa/minio": {Name: "workload"},
+		defaultIdentity:                          {Name: "identity"},
+	}
+	for i, test := range []struct {
+		Identity kes.Identity
+		Policy   string
+		NotFound bool
+	}{
+		{Identity: defaultIdentity, Policy: "identity"},
+		{Identity: "spiffe://example.org/ns/minio/sa/minio", Policy: "workload"},
+		{Identity: "spiffe://example.org/ns/minio/sa/other", Policy: "namespace"},
+		{Identity: "spiffe://example.org/ns/other/sa/minio", Policy: "trust-domain"},
+		{Identity: "spiffe://example.com/ns/minio/sa/minio", NotFound: true},
+		{Identity: "spiffe://example.org", NotFound: true},
+		{Identity: "7ec8095a5308a535b72b35c7ccd4ce1d7c14af713acd22e2935a9d6e4fe18127", NotFound: true},
+	} {
+		entry, ok := lookupIdentity(identities, test.Identity)
+		if ok == test.NotFound {
+			t.Fatalf("Test %d: got 'found=%v' - want 'found=%v'", i, ok, !test.NotFound)
+		}
+		if ok && entry.Name != test.Policy {
+			t.Fatalf("Test %d: got policy '%s' - want '%s'", i, entry.Name, test.Policy)
+		}
+	}
+}
+
+func TestSPIFFEIdentity(t *testing.T) {
+	ctx := testContext(t)
+
+	ca := newTestCA(t)
+	minio, _ := ca.IssueSVID(t, 1, "spiffe://example.org/ns/minio/sa/minio")
+	other, _ := ca.IssueSVID(t, 2, "spiffe://example.org/ns/other/sa/minio")
+
+	_, url := startServer(ctx, ca.ServerConfig(nil, "spiffe://example.org/ns/minio/*"))
+
+	client := ca.Client(url, minio)
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	var info api.SelfDescribeIdentityResponse
+	if err := sendRequest(ctx, client, http.MethodGet, api.PathIdentitySelfDescribe, nil, &info); err != nil {
+		t.Fatalf("Failed to self-describe identity: %v", err)
+	}
+	if id := "spiffe://example.org/ns/minio/sa/minio"; info.Identity != id {
+		t.Fatalf("Invalid identity: got '%s' - want '%s'", info.Identity, id)
+	}
+	if info.Policy == nil || info.Policy.Name != "my-policy" {
+		t.Fatalf("Invalid policy: got '%v' - want '%s'", info.Policy, "my-policy")
+	}
+
+	if err := ca.Client(url, other).CreateKey(ctx, "my-key-2"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Creating key with unassigned SPIFFE ID: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+// IssueSVID returns a new X.509-SVID with the given serial number
+// and SPIFFE ID, and its certificate public key identity.
+func (ca *testCA) IssueSVID(t *testing.T, serial int64, id string) (tls.Certificate, kes.Identity) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate client key: %v", err)
+	}
+	uri, err := url.Parse(id)
+	if err != nil {
+		t.Fatalf("Failed to parse SPIFFE ID '%s': %v", id, err)
+	}
+	return ca.sign(t, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{uri},
+	}, key)
+}
+
+var validSPIFFEPatternTests = []struct {
+	Pattern    string
+	ShouldFail bool
+}{
+	{Pattern: "spiffe://example.org"},                   // 0
+	{Pattern: "spiffe://example.org/*"},                 // 1
+	{Pattern: "spiffe://example.org/ns/minio/sa/minio"}, // 2
+	{Pattern: "spiffe://example.org/ns/minio/*"},        // 3
+	{Pattern: "spiffe://example.org/ns/min*"},           // 4
+	{Pattern: "spiffe://my_domain-1.org/A.b-c_D"},       // 5
+
+	{Pattern: "", ShouldFail: true},                                                  // 6
+	{Pattern: "spiffe://", ShouldFail: true},                                         // 7
+	{Pattern: "spiffe://*", ShouldFail: true},                                        // 8
+	{Pattern: "spiffe://example.org*", ShouldFail: true},                             // 9
+	{Pattern: "spiffe://Example.org/ns", ShouldFail: true},                           // 10
+	{Pattern: "spiffe://example.org/", ShouldFail: true},                             // 11
+	{Pattern: "spiffe://example.org/ns//minio", ShouldFail: true},                    // 12
+	{Pattern: "spiffe://example.org/ns/../minio", ShouldFail: true},                  // 13
+	{Pattern: "spiffe://example.org/ns/*/sa", ShouldFail: true},                      // 14
+	{Pattern: "spiffe://example.org:8080/ns", ShouldFail: true},                      // 15
+	{Pattern: "https://example.org/ns", ShouldFail: true},                            // 16
+	{Pattern: "spiffe://example.org/ns?x=y", ShouldFail: true},                       // 17
+	{Pattern: "spiffe://example.org/" + strings.Repeat("a", 2048), ShouldFail: true}, // 18
+}
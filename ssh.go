@@ -0,0 +1,266 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHCAConfig is a structure containing the configuration of
+// an SSH certificate authority (CA) profile.
+//
+// An SSH CA profile issues short-lived OpenSSH user or host
+// certificates signed by a KES key. SSH servers and clients
+// trust the CA by its public key, e.g. via TrustedUserCAKeys
+// or a @cert-authority entry in known_hosts.
+//
+// Certificates are only issued if all requested principals
+// are allowed by the profile.
+type SSHCAConfig struct {
+	// Key is the name of the Ed25519 or ECDSA-P256 key,
+	// of the default enclave, that signs certificates.
+	Key string
+
+	// Host, if set, makes the profile issue host certificates.
+	// Otherwise, it issues user certificates.
+	Host bool
+
+	// Principals are the principals certificates may be issued
+	// for. For user certificates, principals are user names. For
+	// host certificates, principals are host names and may start
+	// with a "*." wildcard that matches exactly one DNS label.
+	Principals []string
+
+	// MaxTTL is the max. validity period of issued certificates.
+	// It is also the validity period of certificates requested
+	// without a TTL. If 0, it defaults to 1 hour.
+	MaxTTL time.Duration
+}
+
+// defaultSSHCAMaxTTL is the default max. validity period of
+// certificates issued by an SSH CA profile.
+const defaultSSHCAMaxTTL = 1 * time.Hour
+
+// sshUserExtensions are the extensions of issued user
+// certificates. They match the defaults of ssh-keygen.
+var sshUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// sshCA is a HandlerFunc that returns the public keys of an SSH
+// CA profile in authorized_keys format. It returns the public key
+// of the latest key version and a bundle with the public keys of
+// all key versions, that SSH servers and clients should trust to
+// verify certificates issued before a key rotation.
+func (s *Server) sshCA(resp *api.Response, req *api.Request) {
+	profile, ok := s.state.Load().SSHCA[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "SSH CA profile '%s' does not exist", req.Resource)
+		return
+	}
+	// Publishing public keys does not count as using the key.
+	key, err := s.state.Load().Keys.get(req.Context(), profile.Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+
+	var publicKey, bundle []byte
+	for _, version := range key.Versions {
+		signer, err := sshSigner(version.Key)
+		if err != nil {
+			if err, ok := api.IsError(err); ok {
+				resp.Failr(err)
+				return
+			}
+
+			s.fail(resp, req, err, http.StatusInternalServerError, "failed to create SSH CA key")
+			return
+		}
+		publicKey = ssh.MarshalAuthorizedKey(signer.PublicKey())
+		bundle = append(bundle, publicKey...)
+	}
+	api.ReplyWith(resp, http.StatusOK, api.SSHCAResponse{
+		PublicKey: string(bytes.TrimSpace(publicKey)),
+		Bundle:    string(bundle),
+	})
+}
+
+// sshCAProfileKey returns the name of the key of the
+// SSH CA profile with the given name, if it exists.
+func sshCAProfileKey(state *serverState, name string) (string, bool) {
+	profile, ok := state.SSHCA[name]
+	return profile.Key, ok
+}
+
+// sshSign is a HandlerFunc that issues an OpenSSH certificate for
+// a public key signed by the latest version of the SSH CA profile's
+// key. The key must be allowed to sign.
+//
+// All requested principals must be allowed by the profile. The key
+// ID of issued certificates is the identity of the requesting client
+// such that SSH servers log who requested the certificate. Serial
+// numbers are audited.
+func (s *Server) sshSign(resp *api.Response, req *api.Request) {
+	profile, ok := s.state.Load().SSHCA[req.Resource]
+	if !ok {
+		resp.Failf(http.StatusNotFound, "SSH CA profile '%s' does not exist", req.Resource)
+		return
+	}
+
+	var body api.SignSSHRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	maxTTL := profile.MaxTTL
+	if maxTTL == 0 {
+		maxTTL = defaultSSHCAMaxTTL
+	}
+	ttl := maxTTL
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			resp.Failf(http.StatusBadRequest, "certificate TTL '%s' is invalid", body.TTL)
+			return
+		}
+		if ttl > maxTTL {
+			resp.Failf(http.StatusBadRequest, "certificate TTL '%v' exceeds max. TTL '%v'", ttl, maxTTL)
+			return
+		}
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(body.PublicKey))
+	if err != nil {
+		resp.Fail(http.StatusBadRequest, "invalid public key: not in authorized_keys format")
+		return
+	}
+	if _, ok := publicKey.(*ssh.Certificate); ok {
+		resp.Fail(http.StatusBadRequest, "invalid public key: must not be a certificate")
+		return
+	}
+	if len(body.Principals) == 0 {
+		resp.Fail(http.StatusBadRequest, "no principals")
+		return
+	}
+	for _, principal := range body.Principals {
+		if !profile.allowPrincipal(principal) {
+			resp.Failf(http.StatusForbidden, "certificate request not allowed: principal '%s' is not allowed", principal)
+			return
+		}
+	}
+
+	key, err := s.state.Load().Keys.Get(req.Context(), profile.Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	signer, err := sshSigner(key.Latest().Key)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to create SSH CA key")
+		return
+	}
+
+	var serial [8]byte
+	if _, err = rand.Read(serial[:]); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to generate serial number")
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	cert := &ssh.Certificate{
+		Key:             publicKey,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           req.Identity.String(),
+		ValidPrincipals: body.Principals,
+		ValidAfter:      uint64(now.Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: sshUserExtensions,
+		},
+	}
+	if profile.Host {
+		cert.CertType = ssh.HostCert
+		cert.Permissions = ssh.Permissions{}
+	}
+	if err = cert.SignCert(rand.Reader, signer); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to issue certificate")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("SSH certificate '%d' issued by SSH CA profile '%s'", cert.Serial, req.Resource),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.SignSSHResponse{
+		Certificate: string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(cert))),
+		Serial:      cert.Serial,
+		ExpiresAt:   now.Add(ttl),
+	})
+}
+
+// allowPrincipal reports whether the SSH CA profile
+// may issue certificates for the principal.
+func (c *SSHCAConfig) allowPrincipal(principal string) bool {
+	for _, allowed := range c.Principals {
+		if c.Host && matchDNSName(allowed, principal) {
+			return true
+		}
+		if !c.Host && allowed == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// sshSigner returns an SSH signer for the SecretKey.
+//
+// It returns ErrNoSigning if the SecretKey is not a signing key.
+func sshSigner(key crypto.SecretKey) (ssh.Signer, error) {
+	if !key.Type().IsSigning() {
+		return nil, crypto.ErrNoSigning
+	}
+	signer, err := key.Signer()
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromSigner(signer)
+}
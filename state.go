@@ -6,10 +6,12 @@ package kes
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"maps"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"aead.dev/mem"
@@ -22,10 +24,27 @@ type serverState struct {
 	Addr      net.Addr
 	StartTime time.Time
 
-	Admin      kes.Identity
-	Keys       *keyCache
-	Policies   map[string]*kes.Policy
-	Identities map[kes.Identity]identityEntry
+	Admin          kes.Identity
+	Keys           *keyCache
+	KeyStores      map[string]KeyStore // Standby KeyStores
+	Cache          *CacheConfig
+	SoftDelete     *SoftDeleteConfig
+	Policies       map[string]*kes.Policy
+	Identities     map[kes.Identity]identityEntry
+	UnixIdentities map[uint32]kes.Identity // By user ID of local processes
+	Enclaves       enclaveSet
+	Rotation       map[string]RotationConfig
+	PolicyRotation map[string]RotationConfig // Derived from the policies
+	Peers          *peerNotifier
+	Replication    *replicator            // Optional key replication to other sites
+	Authz          *authorizer            // Optional external authorization
+	Revocation     *revocationChecker     // Optional client certificate revocation checking
+	Anomalies      *anomalyDetector       // Optional anomaly detection
+	Seal           *unsealer              // Non-nil while the server is sealed
+	ReadReplica    *ReadReplicaConfig     // Non-nil if the server is a read replica
+	JWKS           *JWKSConfig            // Optional signing keys published via the JWKS API
+	CA             map[string]CAConfig    // Optional CA profiles issuing certificates
+	SSHCA          map[string]SSHCAConfig // Optional SSH CA profiles issuing SSH certificates
 
 	Metrics *metric.Metrics
 	Routes  map[string]api.Route
@@ -35,6 +54,74 @@ type serverState struct {
 	Audit      *auditLogger
 }
 
+// Close closes the key stores of the default enclave
+// and all other enclaves.
+func (s *serverState) Close() error {
+	err := s.Enclaves.Close()
+	if cErr := s.Keys.Close(); err == nil {
+		err = cErr
+	}
+	for _, store := range s.KeyStores {
+		if cErr := store.Close(); err == nil {
+			err = cErr
+		}
+	}
+	s.Peers.Close()
+	s.Replication.Close()
+	return err
+}
+
+// openKeyStores creates the key cache of the state's default
+// enclave and sets the state's standby KeyStores. If configured,
+// slow operations of all KeyStores are logged and faults are
+// injected into them.
+func openKeyStores(state *serverState, conf *Config) {
+	state.Keys = newCache(newSlowLogKeyStore(newFaultKeyStore(conf.Keys, conf.FaultInjector), conf.SlowLog, state.Log, state.Metrics), conf.Cache)
+	state.Keys.softDeletes = state.SoftDelete
+	state.KeyStores = maps.Clone(conf.KeyStores)
+	for name, store := range state.KeyStores {
+		state.KeyStores[name] = newSlowLogKeyStore(newFaultKeyStore(store, conf.FaultInjector), conf.SlowLog, state.Log, state.Metrics)
+	}
+}
+
+// switchKeyStore returns a copy of the server state that uses
+// the named standby KeyStore, and an io.Closer that closes the
+// replaced key caches, including the previous KeyStore. Enclaves
+// sharing the previous KeyStore now share the standby KeyStore.
+//
+// The standby KeyStore must exist.
+func (s *serverState) switchKeyStore(name string) (*serverState, io.Closer) {
+	store := s.KeyStores[name]
+
+	state := *s
+	state.Keys = newCache(store, s.Cache)
+	state.Keys.softDeletes = s.SoftDelete
+	s.Peers.attach(state.Keys, "")
+	s.Replication.attach(state.Keys)
+	state.KeyStores = maps.Clone(s.KeyStores)
+	delete(state.KeyStores, name)
+
+	replaced := enclaveSet{"": &enclave{Keys: s.Keys}}
+	state.Enclaves = make(enclaveSet, len(s.Enclaves))
+	for name, e := range s.Enclaves {
+		shared, ok := asEnclaveKeyStore(e.Keys.store)
+		if !ok {
+			state.Enclaves[name] = e
+			continue
+		}
+		state.Enclaves[name] = &enclave{
+			Keys:       newCache(newEnclaveKeyStore(store, shared.prefix), s.Cache),
+			Policies:   e.Policies,
+			Identities: e.Identities,
+			Rotation:   e.Rotation,
+		}
+		state.Enclaves[name].Keys.softDeletes = s.SoftDelete
+		s.Peers.attach(state.Enclaves[name].Keys, name)
+		replaced[name] = e
+	}
+	return &state, replaced
+}
+
 type identityEntry struct {
 	Name string
 	*kes.Policy
@@ -58,6 +145,62 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: api.HandlerFunc(s.ready),
 		},
+		api.PathJWKS: {
+			Method:  http.MethodGet,
+			Path:    api.PathJWKS,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.jwks))),
+		},
+		api.PathCACertificate: {
+			Method:  http.MethodGet,
+			Path:    api.PathCACertificate,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.caCertificate))),
+		},
+		api.PathCASign: {
+			Method:  http.MethodPut,
+			Path:    api.PathCASign,
+			MaxBody: 64 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictProfileKeyUsage(keyOpSign, caProfileKey, s.detectAnomalies(false, api.HandlerFunc(s.caSign))))),
+		},
+		api.PathSSHCA: {
+			Method:  http.MethodGet,
+			Path:    api.PathSSHCA,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.sshCA))),
+		},
+		api.PathSSHSign: {
+			Method:  http.MethodPut,
+			Path:    api.PathSSHSign,
+			MaxBody: 64 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictProfileKeyUsage(keyOpSign, sshCAProfileKey, s.detectAnomalies(false, api.HandlerFunc(s.sshSign))))),
+		},
+		api.PathConfig: {
+			Method:  http.MethodGet,
+			Path:    api.PathConfig,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: api.HandlerFunc(s.config),
+		},
+		api.PathUnseal: {
+			Method:  http.MethodPut,
+			Path:    api.PathUnseal,
+			MaxBody: 1 * mem.KB,
+			Timeout: 1 * time.Minute,
+			Auth:    insecureIdentifyOnly{},
+			Handler: api.HandlerFunc(s.unseal),
+		},
 		api.PathStatus: {
 			Method:  http.MethodGet,
 			Path:    api.PathStatus,
@@ -83,10 +226,19 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Handler: api.HandlerFunc(s.listAPIs),
 		},
 
+		api.PathRandom: {
+			Method:  http.MethodGet,
+			Path:    api.PathRandom,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.random))),
+		},
+
 		api.PathKeyCreate: {
 			Method:  http.MethodPut,
 			Path:    api.PathKeyCreate,
-			MaxBody: 0,
+			MaxBody: 1 * mem.KB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.createKey))),
@@ -99,6 +251,22 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.importKey))),
 		},
+		api.PathKeyImportParams: {
+			Method:  http.MethodGet,
+			Path:    api.PathKeyImportParams,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.importParams))),
+		},
+		api.PathKeyExport: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyExport,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.exportKey))),
+		},
 		api.PathKeyDescribe: {
 			Method:  http.MethodGet,
 			Path:    api.PathKeyDescribe,
@@ -123,13 +291,45 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.deleteKey))),
 		},
+		api.PathKeyUndelete: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyUndelete,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.undeleteKey))),
+		},
+		api.PathKeyProtect: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyProtect,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.protectKey))),
+		},
+		api.PathKeyUnprotect: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyUnprotect,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.unprotectKey))),
+		},
+		api.PathKeyGrant: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyGrant,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.grantKey))),
+		},
 		api.PathKeyEncrypt: {
 			Method:  http.MethodPut,
 			Path:    api.PathKeyEncrypt,
 			MaxBody: 1 * mem.MB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.encryptKey))),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptKey))))),
 		},
 		api.PathKeyGenerate: {
 			Method:  http.MethodPut,
@@ -137,7 +337,7 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			MaxBody: 1 * mem.MB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.generateKey))),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpGenerate, s.detectAnomalies(false, api.HandlerFunc(s.generateKey))))),
 		},
 		api.PathKeyDecrypt: {
 			Method:  http.MethodPut,
@@ -145,7 +345,79 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			MaxBody: 1 * mem.MB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.decryptKey))),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptKey))))),
+		},
+		api.PathKeyEncryptBatch: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyEncryptBatch,
+			MaxBody: 4 * mem.MB,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptKeyBatch))))),
+		},
+		api.PathKeyDecryptBatch: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDecryptBatch,
+			MaxBody: 4 * mem.MB,
+			Timeout: 30 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptKeyBatch))))),
+		},
+		api.PathKeyEncryptStream: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyEncryptStream,
+			MaxBody: -1, // No limit
+			Timeout: 0,  // No timeout
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptKeyStream))))),
+		},
+		api.PathKeyDecryptStream: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDecryptStream,
+			MaxBody: -1, // No limit
+			Timeout: 0,  // No timeout
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptKeyStream))))),
+		},
+		api.PathKeyReencrypt: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyReencrypt,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(true, api.HandlerFunc(s.reencryptKey)))))),
+		},
+		api.PathKeyEncryptDet: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyEncryptDet,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptKeyDeterministic))))),
+		},
+		api.PathKeyDecryptDet: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDecryptDet,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptKeyDeterministic))))),
+		},
+		api.PathKeyEncryptFPE: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyEncryptFPE,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptKeyFPE))))),
+		},
+		api.PathKeyDecryptFPE: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDecryptFPE,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptKeyFPE))))),
 		},
 		api.PathKeyHMAC: {
 			Method:  http.MethodPut,
@@ -153,7 +425,161 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			MaxBody: 1 * mem.MB,
 			Timeout: 15 * time.Second,
 			Auth:    (*verifyIdentity)(&s.state),
-			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.hmacKey))),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpHMAC, s.detectAnomalies(false, api.HandlerFunc(s.hmacKey))))),
+		},
+		api.PathKeyHMACVerify: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyHMACVerify,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpHMAC, api.HandlerFunc(s.verifyHMAC)))),
+		},
+		api.PathKeyDerive: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDerive,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDerive, s.detectAnomalies(false, api.HandlerFunc(s.deriveKey))))),
+		},
+		api.PathKeyRotate: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyRotate,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.rotateKey))),
+		},
+		api.PathKeyPublic: {
+			Method:  http.MethodGet,
+			Path:    api.PathKeyPublic,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.publicKey))),
+		},
+		api.PathKeySign: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeySign,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpSign, s.detectAnomalies(false, api.HandlerFunc(s.signKey))))),
+		},
+		api.PathKeyVerify: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyVerify,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpSign, api.HandlerFunc(s.verifyKey)))),
+		},
+		api.PathKeyTimestamp: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyTimestamp,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpSign, s.detectAnomalies(false, api.HandlerFunc(s.timestamp))))),
+		},
+		api.PathKeySignJWS: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeySignJWS,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpSign, s.detectAnomalies(false, api.HandlerFunc(s.signJWS))))),
+		},
+		api.PathKeyEncryptJWE: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyEncryptJWE,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpEncrypt, s.detectAnomalies(false, api.HandlerFunc(s.encryptJWE))))),
+		},
+		api.PathKeyDecryptJWE: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyDecryptJWE,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpDecrypt, s.detectAnomalies(true, api.HandlerFunc(s.decryptJWE))))),
+		},
+		api.PathKeyUnwrap: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyUnwrap,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpUnwrap, s.detectAnomalies(true, api.HandlerFunc(s.unwrapKey))))),
+		},
+
+		api.PathTokenOpen: {
+			Method:  http.MethodPut,
+			Path:    api.PathTokenOpen,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.openTokenSession))),
+		},
+		api.PathTokenClose: {
+			Method:  http.MethodPut,
+			Path:    api.PathTokenClose,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.closeTokenSession))),
+		},
+		api.PathTokenSign: {
+			Method:  http.MethodPut,
+			Path:    api.PathTokenSign,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpSign, api.HandlerFunc(s.tokenSign)))),
+		},
+		api.PathTokenUnwrap: {
+			Method:  http.MethodPut,
+			Path:    api.PathTokenUnwrap,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(s.restrictKeyUsage(keyOpUnwrap, api.HandlerFunc(s.tokenUnwrap)))),
+		},
+
+		api.PathSecretCreate: {
+			Method:  http.MethodPut,
+			Path:    api.PathSecretCreate,
+			MaxBody: 64 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.createSecret))),
+		},
+		api.PathSecretRead: {
+			Method:  http.MethodGet,
+			Path:    api.PathSecretRead,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.readSecret))),
+		},
+		api.PathSecretDelete: {
+			Method:  http.MethodDelete,
+			Path:    api.PathSecretDelete,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.deleteSecret))),
+		},
+		api.PathSecretList: {
+			Method:  http.MethodGet,
+			Path:    api.PathSecretList,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.listSecrets))),
 		},
 
 		api.PathPolicyDescribe: {
@@ -214,6 +640,46 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.ErrorEventCounter(api.HandlerFunc(s.logError)),
 		},
+		api.PathKeyStoreSwitch: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyStoreSwitch,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.switchKeyStore))),
+		},
+		api.PathKeyStorePurge: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyStorePurge,
+			MaxBody: 64 * mem.KB,
+			Timeout: 5 * time.Minute,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.purgeKeyStore))),
+		},
+		api.PathKeyStoreTest: {
+			Method:  http.MethodPut,
+			Path:    api.PathKeyStoreTest,
+			MaxBody: 0,
+			Timeout: 1 * time.Minute,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.testKeyStore))),
+		},
+		api.PathPeerNotify: {
+			Method:  http.MethodPut,
+			Path:    api.PathPeerNotify,
+			MaxBody: 1 * mem.KB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.notifyPeer))),
+		},
+		api.PathReplicate: {
+			Method:  http.MethodPut,
+			Path:    api.PathReplicate,
+			MaxBody: 1 * mem.MB,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: metrics.Latency(metrics.Count(api.HandlerFunc(s.replicate))),
+		},
 		api.PathLogAudit: {
 			Method:  http.MethodGet,
 			Path:    api.PathLogAudit,
@@ -222,6 +688,23 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 			Auth:    (*verifyIdentity)(&s.state),
 			Handler: metrics.AuditEventCounter(api.HandlerFunc(s.logAudit)),
 		},
+
+		api.PathDebugPprof: {
+			Method:  http.MethodGet,
+			Path:    api.PathDebugPprof,
+			MaxBody: 0,
+			Timeout: maxProfileDuration + 30*time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: api.HandlerFunc(s.pprof),
+		},
+		api.PathDebugRuntime: {
+			Method:  http.MethodGet,
+			Path:    api.PathDebugRuntime,
+			MaxBody: 0,
+			Timeout: 15 * time.Second,
+			Auth:    (*verifyIdentity)(&s.state),
+			Handler: api.HandlerFunc(s.runtimeStats),
+		},
 	}
 
 	for path, conf := range routeConfig { // apply API customization
@@ -235,9 +718,20 @@ func initRoutes(s *Server, routeConfig map[string]RouteConfig, metrics *metric.M
 		if conf.Timeout > 0 {
 			route.Timeout = conf.Timeout
 		}
+		if conf.RateLimit > 0 || conf.IdentityRateLimit > 0 {
+			route.Handler = newRateLimiter(route.Handler, conf.RateLimit, conf.IdentityRateLimit, metrics)
+		}
 		routes[path] = route
 	}
 
+	// Random bytes are rate limited per identity by default
+	// such that a single client cannot flood the server.
+	if conf := routeConfig[api.PathRandom]; conf.RateLimit <= 0 && conf.IdentityRateLimit <= 0 {
+		route := routes[api.PathRandom]
+		route.Handler = newRateLimiter(route.Handler, 0, defaultRandomRateLimit, metrics)
+		routes[api.PathRandom] = route
+	}
+
 	mux := http.NewServeMux()
 	for path, route := range routes {
 		mux.Handle(path, route)
@@ -252,6 +746,19 @@ func initPolicies(policies map[string]Policy) (map[string]*kes.Policy, map[kes.I
 		if !validName(name) {
 			return nil, nil, fmt.Errorf("kes: policy name '%s' is empty, too long or contains invalid characters", name)
 		}
+		if policy.Rotation.Interval < 0 {
+			return nil, nil, fmt.Errorf("kes: invalid key rotation interval '%v' for policy '%s'", policy.Rotation.Interval, name)
+		}
+		for pattern := range policy.Allow {
+			if !validPolicyPattern(pattern) {
+				return nil, nil, fmt.Errorf("kes: invalid allow rule '%s' for policy '%s'", pattern, name)
+			}
+		}
+		for pattern := range policy.Deny {
+			if !validPolicyPattern(pattern) {
+				return nil, nil, fmt.Errorf("kes: invalid deny rule '%s' for policy '%s'", pattern, name)
+			}
+		}
 		p := &kes.Policy{
 			Allow: maps.Clone(policy.Allow),
 			Deny:  maps.Clone(policy.Deny),
@@ -259,7 +766,11 @@ func initPolicies(policies map[string]Policy) (map[string]*kes.Policy, map[kes.I
 
 		policySet[name] = p
 		for _, id := range policy.Identities {
-			if !validName(id.String()) {
+			if strings.HasPrefix(id.String(), spiffeScheme) {
+				if !validSPIFFEPattern(id.String()) {
+					return nil, nil, fmt.Errorf("kes: invalid SPIFFE ID '%s'", id)
+				}
+			} else if !validName(id.String()) {
 				return nil, nil, fmt.Errorf("kes: identity '%s' is empty, too long or contains invalid characters", id)
 			}
 			if _, ok := identitySet[id]; ok {
@@ -0,0 +1,169 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+	"github.com/minio/kes/internal/headers"
+	"github.com/minio/kes/internal/secmem"
+)
+
+// The stream API encrypts and decrypts payloads of arbitrary size
+// without buffering them. Clients send the payload as request body
+// and receive the encrypted or decrypted payload as response body.
+//
+// Each stream is encrypted with its own data key. The data key is
+// encrypted with a KES key and stored in the stream header. Hence,
+// a request is authorized once per stream, not per chunk. Clients
+// can provide associated data via the base64-encoded 'context' query
+// parameter. It must be the same for encryption and decryption.
+
+// encryptKeyStream encrypts the request body and sends the
+// encrypted stream to the client.
+//
+// If encryption fails after the response header has been sent,
+// the response is aborted such that clients see an incomplete
+// response.
+func (s *Server) encryptKeyStream(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	associatedData, err := streamContext(req)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid stream context: %v", err)
+		return
+	}
+
+	dataKey := make([]byte, crypto.StreamKeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to generate data key")
+		return
+	}
+	defer secmem.Zero(dataKey)
+
+	sealedKey, err := sealStreamKey(req.Context(), s.state.Load().enclave(req).Keys, req.Resource, dataKey, associatedData)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to encrypt data key")
+		return
+	}
+
+	// HTTP/1.x servers stop reading the request body once they
+	// send the response. HTTP/2 is always full duplex.
+	_ = http.NewResponseController(resp).EnableFullDuplex()
+
+	resp.Header().Set(headers.ContentType, headers.ContentTypeBinary)
+	resp.WriteHeader(http.StatusOK)
+	if err = crypto.EncryptStream(resp, req.Body, dataKey, sealedKey); err != nil {
+		s.abortStream(req, err)
+	}
+}
+
+// decryptKeyStream decrypts the encrypted stream sent as request
+// body and sends the plaintext to the client.
+//
+// If decryption fails after the response header has been sent,
+// e.g. since the stream has been truncated, the response is aborted
+// such that clients see an incomplete response. Clients must not
+// use the plaintext of an incomplete response.
+func (s *Server) decryptKeyStream(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+	associatedData, err := streamContext(req)
+	if err != nil {
+		resp.Failf(http.StatusBadRequest, "invalid stream context: %v", err)
+		return
+	}
+
+	sealedKey, err := crypto.ReadStreamHeader(req.Body)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	keys := s.state.Load().enclave(req).Keys
+	decrypt := keys.Decrypt
+	if keys.crypto != nil {
+		decrypt = keys.DecryptRemote
+	}
+	dataKey, _, err := decrypt(req.Context(), req.Resource, sealedKey, associatedData)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to decrypt data key")
+		return
+	}
+	defer secmem.Zero(dataKey)
+
+	// HTTP/1.x servers stop reading the request body once they
+	// send the response. HTTP/2 is always full duplex.
+	_ = http.NewResponseController(resp).EnableFullDuplex()
+
+	resp.Header().Set(headers.ContentType, headers.ContentTypeBinary)
+	resp.WriteHeader(http.StatusOK)
+	if err = crypto.DecryptStream(resp, req.Body, dataKey); err != nil {
+		s.abortStream(req, err)
+	}
+}
+
+// abortStream aborts a stream request once the response header has
+// been sent. Clients see an incomplete response. Errors caused by the
+// client, like a modified stream or a closed connection, are not logged.
+func (s *Server) abortStream(req *api.Request, err error) {
+	if _, ok := api.IsError(err); !ok && !errors.Is(err, context.Canceled) {
+		s.state.Load().Log.ErrorContext(req.Context(), err.Error(), "req", req)
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// sealStreamKey encrypts the stream data key with the latest
+// version of the key with the given name.
+func sealStreamKey(ctx context.Context, keys *keyCache, name string, dataKey, associatedData []byte) ([]byte, error) {
+	if keys.crypto != nil {
+		return keys.Encrypt(ctx, name, dataKey, associatedData)
+	}
+
+	key, err := keys.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encryption may happen in-place. Limiting the capacity
+	// ensures that the data key does not get overwritten.
+	latest := key.Latest()
+	return latest.Key.Encrypt(dataKey[:len(dataKey):len(dataKey)], associatedData)
+}
+
+// streamContext returns the associated data of a stream request.
+// It is sent as base64-encoded 'context' query parameter.
+func streamContext(req *api.Request) ([]byte, error) {
+	s := req.URL.Query().Get("context")
+	if s == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
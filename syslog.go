@@ -0,0 +1,366 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/sys"
+)
+
+// SyslogFormat is the message format used by a SyslogAuditHandler.
+type SyslogFormat int
+
+// Supported syslog message formats.
+const (
+	// SyslogCEF is the ArcSight Common Event Format (CEF),
+	// supported by SIEMs like Splunk or ArcSight.
+	SyslogCEF SyslogFormat = iota
+
+	// SyslogLEEF is the IBM Log Event Extended Format (LEEF)
+	// version 2.0, supported by SIEMs like QRadar.
+	SyslogLEEF
+)
+
+// String returns the string representation of the format.
+func (f SyslogFormat) String() string {
+	switch f {
+	case SyslogCEF:
+		return "CEF"
+	case SyslogLEEF:
+		return "LEEF"
+	default:
+		return "SyslogFormat(" + strconv.Itoa(int(f)) + ")"
+	}
+}
+
+// SyslogConfig is a structure containing the configuration
+// of a SyslogAuditHandler.
+type SyslogConfig struct {
+	// Address is the host:port of the syslog server. Messages
+	// are sent via TCP.
+	Address string
+
+	// TLS is an optional TLS configuration. If not nil, messages
+	// are sent via TLS.
+	TLS *tls.Config
+
+	// Format is the message format of audit events.
+	Format SyslogFormat
+
+	// Level is the min. level of audit events sent to the syslog
+	// server. If nil, slog.LevelInfo is used.
+	Level slog.Leveler
+}
+
+// SyslogAuditHandler is an AuditHandler that sends AuditRecords,
+// formatted as CEF or LEEF messages, to a syslog server via TCP
+// or TLS. Messages are framed as specified by RFC 5424 and 6587.
+//
+// It sends messages asynchronously and drops messages when the
+// syslog server is unreachable or cannot keep up. It reconnects
+// automatically.
+//
+// Use NewSyslogAuditHandler to create a SyslogAuditHandler.
+type SyslogAuditHandler struct {
+	conf     SyslogConfig
+	hostname string
+	version  string
+
+	queue chan syslogMessage
+	close sync.Once
+	done  chan struct{}
+}
+
+var _ AuditFlusher = (*SyslogAuditHandler)(nil) // compiler check
+
+// syslogMessage is a message queued by a SyslogAuditHandler.
+// A message with a done channel is a flush marker.
+type syslogMessage struct {
+	Data []byte
+	Done chan struct{}
+}
+
+const (
+	// syslogQueueSize is the max. number of queued messages.
+	syslogQueueSize = 4096
+
+	// syslogRetryDelay is the time a SyslogAuditHandler waits
+	// before reconnecting after a failed connection attempt.
+	syslogRetryDelay = 1 * time.Second
+
+	// syslogFacility is the syslog facility "log audit".
+	syslogFacility = 13
+)
+
+// NewSyslogAuditHandler returns a new SyslogAuditHandler sending
+// audit records to the syslog server configured by conf.
+//
+// Close the SyslogAuditHandler to release associated resources.
+func NewSyslogAuditHandler(conf *SyslogConfig) (*SyslogAuditHandler, error) {
+	if conf.Address == "" {
+		return nil, errors.New("kes: invalid syslog config: no address specified")
+	}
+	if _, _, err := net.SplitHostPort(conf.Address); err != nil {
+		return nil, fmt.Errorf("kes: invalid syslog address '%s': %v", conf.Address, err)
+	}
+	if conf.Format != SyslogCEF && conf.Format != SyslogLEEF {
+		return nil, fmt.Errorf("kes: invalid syslog format '%v'", conf.Format)
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	info, _ := sys.ReadBinaryInfo()
+
+	h := &SyslogAuditHandler{
+		conf:     *conf,
+		hostname: hostname,
+		version:  info.Version,
+		queue:    make(chan syslogMessage, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+	if h.conf.TLS != nil {
+		h.conf.TLS = h.conf.TLS.Clone()
+	}
+	if h.conf.Level == nil {
+		h.conf.Level = slog.LevelInfo
+	}
+	go h.send()
+	return h, nil
+}
+
+// Enabled reports whether the SyslogAuditHandler handles records
+// at the given level.
+func (h *SyslogAuditHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.conf.Level.Level()
+}
+
+// Handle formats the AuditRecord and queues it for sending.
+// It drops the record if the queue is full.
+func (h *SyslogAuditHandler) Handle(_ context.Context, r AuditRecord) error {
+	var msg string
+	switch h.conf.Format {
+	case SyslogLEEF:
+		msg = leefMessage(&r, h.version)
+	default:
+		msg = cefMessage(&r, h.version)
+	}
+
+	select {
+	case <-h.done:
+		return errors.New("kes: syslog audit handler is closed")
+	default:
+	}
+	select {
+	case h.queue <- syslogMessage{Data: h.frame(&r, msg)}:
+		return nil
+	default:
+		return errors.New("kes: syslog audit queue is full")
+	}
+}
+
+// Flush waits until all queued records have been sent or
+// dropped.
+func (h *SyslogAuditHandler) Flush() error {
+	done := make(chan struct{})
+	select {
+	case h.queue <- syslogMessage{Done: done}:
+	case <-h.done:
+		return nil
+	}
+	select {
+	case <-done:
+	case <-h.done:
+	}
+	return nil
+}
+
+// Close stops sending audit records and closes the
+// connection to the syslog server.
+func (h *SyslogAuditHandler) Close() error {
+	h.close.Do(func() { close(h.done) })
+	return nil
+}
+
+// frame returns the syslog message as RFC 5424 message with
+// octet-counting framing as specified by RFC 6587.
+func (h *SyslogAuditHandler) frame(r *AuditRecord, msg string) []byte {
+	const AppName = "kes"
+
+	var severity int
+	switch {
+	case r.Level >= slog.LevelError:
+		severity = 3
+	case r.Level >= slog.LevelWarn:
+		severity = 4
+	case r.Level >= slog.LevelInfo:
+		severity = 6
+	default:
+		severity = 7
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		syslogFacility*8+severity,
+		r.Time.UTC().Format(time.RFC3339Nano),
+		h.hostname,
+		AppName,
+		os.Getpid(),
+		h.conf.Format,
+		msg,
+	)
+	return []byte(strconv.Itoa(len(line)) + " " + line)
+}
+
+// send writes queued messages to the syslog server until
+// the handler is closed.
+func (h *SyslogAuditHandler) send() {
+	var (
+		conn      net.Conn
+		nextRetry time.Time
+	)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	for {
+		var msg syslogMessage
+		select {
+		case <-h.done:
+			return
+		case msg = <-h.queue:
+		}
+		if msg.Done != nil {
+			close(msg.Done)
+			continue
+		}
+
+		// Try to send the message at most twice, in case
+		// the syslog server has closed an idle connection.
+		for i := 0; i < 2; i++ {
+			if conn == nil {
+				if time.Now().Before(nextRetry) {
+					break // Drop messages until we try to reconnect
+				}
+
+				var err error
+				if h.conf.TLS != nil {
+					conn, err = tls.DialWithDialer(dialer, "tcp", h.conf.Address, h.conf.TLS)
+				} else {
+					conn, err = dialer.Dial("tcp", h.conf.Address)
+				}
+				if err != nil {
+					conn, nextRetry = nil, time.Now().Add(syslogRetryDelay)
+					break
+				}
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if _, err := conn.Write(msg.Data); err == nil {
+				break
+			}
+			conn.Close()
+			conn = nil
+		}
+	}
+}
+
+// cefMessage returns the AuditRecord as ArcSight Common Event
+// Format (CEF) message:
+//
+//	CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+func cefMessage(r *AuditRecord, version string) string {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	ext := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+
+	name, resource := auditAPIName(r.Path)
+	severity := 3 // Low
+	switch {
+	case r.StatusCode >= 500:
+		severity = 8 // High
+	case r.StatusCode >= 400:
+		severity = 5 // Medium
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|MinIO|KES|%s|%s|%s|%d|",
+		header.Replace(version),
+		header.Replace(name),
+		header.Replace(r.Message),
+		severity,
+	)
+	fmt.Fprintf(&b, "rt=%d requestMethod=%s request=%s outcome=%d",
+		r.Time.UnixMilli(),
+		ext.Replace(r.Method),
+		ext.Replace(r.Path),
+		r.StatusCode,
+	)
+	if resource != "" {
+		fmt.Fprintf(&b, " fname=%s", ext.Replace(resource))
+	}
+	if r.RemoteIP.IsValid() {
+		fmt.Fprintf(&b, " src=%s", r.RemoteIP)
+	}
+	if !r.Identity.IsUnknown() {
+		fmt.Fprintf(&b, " suser=%s", ext.Replace(r.Identity.String()))
+	}
+	fmt.Fprintf(&b, " cn1=%d cn1Label=responseTimeMs msg=%s", r.ResponseTime.Milliseconds(), ext.Replace(r.Message))
+	return b.String()
+}
+
+// leefMessage returns the AuditRecord as IBM Log Event Extended
+// Format (LEEF) 2.0 message with tab-separated attributes:
+//
+//	LEEF:Version|Vendor|Product|Version|EventID|Attributes
+func leefMessage(r *AuditRecord, version string) string {
+	header := strings.NewReplacer(`|`, ` `, "\t", " ", "\n", " ", "\r", " ")
+	attr := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+	name, resource := auditAPIName(r.Path)
+	severity := 3
+	switch {
+	case r.StatusCode >= 500:
+		severity = 8
+	case r.StatusCode >= 400:
+		severity = 5
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|MinIO|KES|%s|%s|", header.Replace(version), header.Replace(name))
+	fmt.Fprintf(&b, "devTime=%d\tcat=%s\tsev=%d\trequestMethod=%s\turl=%s\tresponseCode=%d\tresponseTimeMs=%d",
+		r.Time.UnixMilli(),
+		attr.Replace(name),
+		severity,
+		attr.Replace(r.Method),
+		attr.Replace(r.Path),
+		r.StatusCode,
+		r.ResponseTime.Milliseconds(),
+	)
+	if resource != "" {
+		fmt.Fprintf(&b, "\tresource=%s", attr.Replace(resource))
+	}
+	if r.RemoteIP.IsValid() {
+		fmt.Fprintf(&b, "\tsrc=%s", r.RemoteIP)
+	}
+	if !r.Identity.IsUnknown() {
+		fmt.Fprintf(&b, "\tusrName=%s", attr.Replace(r.Identity.String()))
+	}
+	fmt.Fprintf(&b, "\tmsg=%s", attr.Replace(r.Message))
+	return b.String()
+}
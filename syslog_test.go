@@ -0,0 +1,104 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogAuditHandler(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	for i, test := range syslogTests {
+		h, err := NewSyslogAuditHandler(&SyslogConfig{
+			Address: ln.Addr().String(),
+			Format:  test.Format,
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create syslog handler: %v", i, err)
+		}
+
+		if err = h.Handle(context.Background(), syslogRecord); err != nil {
+			t.Fatalf("Test %d: failed to handle audit record: %v", i, err)
+		}
+		if err = h.Flush(); err != nil {
+			t.Fatalf("Test %d: failed to flush: %v", i, err)
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Test %d: failed to accept connection: %v", i, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatalf("Test %d: failed to read message length: %v", i, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			t.Fatalf("Test %d: invalid message length '%s': %v", i, length, err)
+		}
+		msg := make([]byte, n)
+		if _, err = io.ReadFull(r, msg); err != nil {
+			t.Fatalf("Test %d: failed to read message: %v", i, err)
+		}
+		conn.Close()
+		h.Close()
+
+		if !strings.HasPrefix(string(msg), "<110>1 2024-06-01T12:00:00Z ") {
+			t.Fatalf("Test %d: invalid syslog header: %s", i, msg)
+		}
+		if !strings.Contains(string(msg), test.Message) {
+			t.Fatalf("Test %d: message '%s' does not contain '%s'", i, msg, test.Message)
+		}
+	}
+}
+
+func TestCEFMessage(t *testing.T) {
+	r := syslogRecord
+	r.Message = "key 'a|b=c' created"
+
+	const Want = `CEF:0|MinIO|KES|v1|key/create|key 'a\|b=c' created|3|rt=1717243200000 requestMethod=PUT request=/v1/key/create/my-key outcome=200 fname=my-key src=10.1.2.3 suser=my-identity cn1=3 cn1Label=responseTimeMs msg=key 'a|b\=c' created`
+	if msg := cefMessage(&r, "v1"); msg != Want {
+		t.Fatalf("CEF message mismatch:\ngot:  %s\nwant: %s", msg, Want)
+	}
+}
+
+var syslogRecord = AuditRecord{
+	Time:         time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+	Method:       http.MethodPut,
+	Path:         "/v1/key/create/my-key",
+	Identity:     "my-identity",
+	RemoteIP:     netip.MustParseAddr("10.1.2.3"),
+	StatusCode:   http.StatusOK,
+	ResponseTime: 3 * time.Millisecond,
+	Level:        slog.LevelInfo,
+	Message:      "secret key 'my-key' created",
+}
+
+var syslogTests = []struct {
+	Format  SyslogFormat
+	Message string
+}{
+	{Format: SyslogCEF, Message: " CEF - CEF:0|MinIO|KES|"},
+	{Format: SyslogCEF, Message: "|key/create|secret key 'my-key' created|3|"},
+	{Format: SyslogLEEF, Message: " LEEF - LEEF:2.0|MinIO|KES|"},
+	{Format: SyslogLEEF, Message: "\tusrName=my-identity\t"},
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/kes/internal/api"
+)
+
+// errTagsNotSupported is returned when trying to tag a key stored
+// on a KeyStore that does not implement MetadataKeyStore.
+var errTagsNotSupported = api.NewError(http.StatusNotImplemented, "key store does not support key tags")
+
+// validTags returns an error if the key tags exceed the size
+// limits or contain invalid characters.
+//
+// Tag keys only contain the characters a-z, A-Z, 0-9, '.', '-',
+// '_' and '/'. The limits keep tags within the custom metadata
+// limits of KeyStores like Hashicorp Vault.
+func validTags(tags map[string]string) error {
+	const (
+		MaxTags     = 32
+		MaxKeyLen   = 64
+		MaxValueLen = 256
+	)
+
+	if len(tags) > MaxTags {
+		return fmt.Errorf("too many tags: a key can have at most %d tags", MaxTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > MaxKeyLen {
+			return fmt.Errorf("tag key '%s' is empty or longer than %d characters", key, MaxKeyLen)
+		}
+		for _, r := range key {
+			switch {
+			case r >= 'a' && r <= 'z':
+			case r >= 'A' && r <= 'Z':
+			case r >= '0' && r <= '9':
+			case r == '.' || r == '-' || r == '_' || r == '/':
+			default:
+				return fmt.Errorf("tag key '%s' contains invalid characters", key)
+			}
+		}
+		if len(value) > MaxValueLen {
+			return fmt.Errorf("value of tag '%s' is longer than %d characters", key, MaxValueLen)
+		}
+	}
+	return nil
+}
+
+// parseTagFilter parses 'key=value' tag filters, like "env=prod",
+// into a map of tags a key must have.
+func parseTagFilter(filters []string) (map[string]string, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(filters))
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag filter '%s': must be 'key=value'", filter)
+		}
+		if v, ok := tags[key]; ok && v != value {
+			return nil, fmt.Errorf("conflicting tag filters for '%s'", key)
+		}
+		tags[key] = value
+	}
+	if err := validTags(tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// hasTags reports whether tags contains all key-value pairs
+// of filter.
+func hasTags(tags, filter map[string]string) bool {
+	for key, value := range filter {
+		if v, ok := tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,329 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/kes/internal/keystore"
+)
+
+// KeyStoreTimeoutConfig is a structure containing the timeouts
+// of KeyStore operations.
+//
+// Operations that do not modify the KeyStore, like Get or List,
+// are retried if the KeyStore is unreachable or throttles them,
+// as long as their budget has not been exceeded. Operations that
+// modify the KeyStore are never retried since a timed out attempt
+// may have succeeded.
+type KeyStoreTimeoutConfig struct {
+	// Operation is the timeout of a single attempt of a KeyStore
+	// operation. If <= 0, attempts do not time out.
+	Operation time.Duration
+
+	// Budget is the max. time a KeyStore operation may take,
+	// including all retries. If <= 0, operations are not
+	// retried and only time out when their attempt does.
+	Budget time.Duration
+}
+
+const (
+	// minTimeoutRetryDelay and maxTimeoutRetryDelay are the
+	// min. and max. delays between retries of KeyStore operations.
+	minTimeoutRetryDelay = 50 * time.Millisecond
+	maxTimeoutRetryDelay = 1 * time.Second
+)
+
+// NewTimeoutKeyStore returns a KeyStore that applies the timeouts
+// of conf to all operations of store. It returns store if conf is
+// nil or contains no timeouts.
+//
+// Unlike the per-backend timeouts of some KeyStores, the timeouts
+// are applied via context deadlines. Hence, they apply to any
+// KeyStore respecting the context of its operations.
+func NewTimeoutKeyStore(store KeyStore, conf *KeyStoreTimeoutConfig) KeyStore {
+	if conf == nil || store == nil || (conf.Operation <= 0 && conf.Budget <= 0) {
+		return store
+	}
+	s := &timeoutKeyStore{
+		store:     store,
+		operation: max(conf.Operation, 0),
+		budget:    max(conf.Budget, 0),
+	}
+	if _, ok := store.(CryptoKeyStore); ok {
+		if _, ok := store.(WatchableKeyStore); ok {
+			return &watchableCryptoTimeoutKeyStore{&cryptoTimeoutKeyStore{s}}
+		}
+		return &cryptoTimeoutKeyStore{s}
+	}
+	if _, ok := store.(WatchableKeyStore); ok {
+		return &watchableTimeoutKeyStore{s}
+	}
+	return s
+}
+
+// timeoutKeyStore is a KeyStore that applies timeouts to the
+// operations of another KeyStore.
+//
+// Like the KeyStore of an enclave, it implements all optional
+// KeyStore interfaces, except CryptoKeyStore, and falls back to
+// the behavior of the KES server if the wrapped KeyStore does not
+// implement them. Hence, a timeoutKeyStore on top of a KeyStore
+// can be used wherever a CryptoKeyStore is not accepted.
+type timeoutKeyStore struct {
+	store     KeyStore
+	operation time.Duration
+	budget    time.Duration
+}
+
+// watchableTimeoutKeyStore is a timeoutKeyStore on top of a
+// WatchableKeyStore.
+type watchableTimeoutKeyStore struct {
+	*timeoutKeyStore
+}
+
+var _ WatchableKeyStore = (*watchableTimeoutKeyStore)(nil) // compiler check
+
+// Watch returns a channel that receives an event whenever an entry,
+// whose name starts with the given prefix, is created, replaced or
+// deleted. Watching never times out since it is a long-running
+// operation.
+func (s *watchableTimeoutKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	return s.store.(WatchableKeyStore).Watch(ctx, prefix)
+}
+
+// cryptoTimeoutKeyStore is a timeoutKeyStore on top of a
+// CryptoKeyStore.
+type cryptoTimeoutKeyStore struct {
+	*timeoutKeyStore
+}
+
+var _ DataKeyStore = (*cryptoTimeoutKeyStore)(nil) // compiler check
+
+// CreateKey creates a new key at the KeyStore.
+func (s *cryptoTimeoutKeyStore) CreateKey(ctx context.Context, name string) error {
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return s.store.(CryptoKeyStore).CreateKey(ctx, name)
+	})
+}
+
+// Encrypt encrypts the plaintext with the key at the KeyStore.
+func (s *cryptoTimeoutKeyStore) Encrypt(ctx context.Context, name string, plaintext, associatedData []byte) (ciphertext []byte, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		ciphertext, err = s.store.(CryptoKeyStore).Encrypt(ctx, name, plaintext, associatedData)
+		return err
+	})
+	return ciphertext, err
+}
+
+// GenerateKey generates a data key with the key at the KeyStore.
+func (s *cryptoTimeoutKeyStore) GenerateKey(ctx context.Context, name string, associatedData []byte) (plaintext, ciphertext []byte, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		plaintext, ciphertext, err = generateKey(ctx, s.store.(CryptoKeyStore), name, associatedData)
+		return err
+	})
+	return plaintext, ciphertext, err
+}
+
+// Decrypt decrypts the ciphertext with the key at the KeyStore.
+func (s *cryptoTimeoutKeyStore) Decrypt(ctx context.Context, name string, ciphertext, associatedData []byte) (plaintext []byte, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		plaintext, err = s.store.(CryptoKeyStore).Decrypt(ctx, name, ciphertext, associatedData)
+		return err
+	})
+	return plaintext, err
+}
+
+// watchableCryptoTimeoutKeyStore is a cryptoTimeoutKeyStore on
+// top of a CryptoKeyStore that is also a WatchableKeyStore.
+type watchableCryptoTimeoutKeyStore struct {
+	*cryptoTimeoutKeyStore
+}
+
+var _ WatchableKeyStore = (*watchableCryptoTimeoutKeyStore)(nil) // compiler check
+
+// Watch returns a channel that receives an event whenever an entry,
+// whose name starts with the given prefix, is created, replaced or
+// deleted. Watching never times out since it is a long-running
+// operation.
+func (s *watchableCryptoTimeoutKeyStore) Watch(ctx context.Context, prefix string) (<-chan KeyStoreEvent, error) {
+	return s.store.(WatchableKeyStore).Watch(ctx, prefix)
+}
+
+var ( // compiler checks
+	_ ConditionalKeyStore = (*timeoutKeyStore)(nil)
+	_ ExpiringKeyStore    = (*timeoutKeyStore)(nil)
+	_ MetadataKeyStore    = (*timeoutKeyStore)(nil)
+	_ PaginatedKeyStore   = (*timeoutKeyStore)(nil)
+)
+
+// String returns the string representation of the wrapped KeyStore.
+func (s *timeoutKeyStore) String() string {
+	if store, ok := s.store.(fmt.Stringer); ok {
+		return store.String()
+	}
+	return keyStoreBackend(s.store)
+}
+
+// Status returns the current state of the KeyStore.
+func (s *timeoutKeyStore) Status(ctx context.Context) (state KeyStoreState, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		state, err = s.store.Status(ctx)
+		return err
+	})
+	return state, err
+}
+
+// Create creates a new entry at the KeyStore.
+func (s *timeoutKeyStore) Create(ctx context.Context, name string, value []byte) error {
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return s.store.Create(ctx, name, value)
+	})
+}
+
+// CreateWithTTL creates a new entry that expires after the given
+// ttl. It creates the entry without a TTL if the KeyStore does not
+// implement ExpiringKeyStore.
+func (s *timeoutKeyStore) CreateWithTTL(ctx context.Context, name string, value []byte, ttl time.Duration) error {
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return createWithTTL(ctx, s.store, name, value, ttl)
+	})
+}
+
+// Set replaces the value of an existing entry. It fails if the
+// KeyStore is not mutable.
+func (s *timeoutKeyStore) Set(ctx context.Context, name string, value []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return store.Set(ctx, name, value)
+	})
+}
+
+// SetIf replaces the value of an existing entry if its current
+// value is equal to old. It replaces the value unconditionally if
+// the KeyStore does not implement ConditionalKeyStore and fails if
+// it is not mutable.
+func (s *timeoutKeyStore) SetIf(ctx context.Context, name string, value, old []byte) error {
+	store, ok := s.store.(MutableKeyStore)
+	if !ok {
+		return errRotateNotSupported
+	}
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return setIf(ctx, store, name, value, old)
+	})
+}
+
+// Metadata returns the metadata of the entry. It returns an empty
+// EntryMetadata if the KeyStore does not implement MetadataKeyStore.
+func (s *timeoutKeyStore) Metadata(ctx context.Context, name string) (metadata EntryMetadata, err error) {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return EntryMetadata{}, nil
+	}
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		metadata, err = store.Metadata(ctx, name)
+		return err
+	})
+	return metadata, err
+}
+
+// SetMetadata stores the metadata of the entry. It does nothing if
+// the KeyStore does not implement MetadataKeyStore.
+func (s *timeoutKeyStore) SetMetadata(ctx context.Context, name string, metadata EntryMetadata) error {
+	store, ok := s.store.(MetadataKeyStore)
+	if !ok {
+		return nil
+	}
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return store.SetMetadata(ctx, name, metadata)
+	})
+}
+
+// Delete removes the entry from the KeyStore.
+func (s *timeoutKeyStore) Delete(ctx context.Context, name string) error {
+	return s.do(ctx, false, func(ctx context.Context) error {
+		return s.store.Delete(ctx, name)
+	})
+}
+
+// Get returns the value of the entry.
+func (s *timeoutKeyStore) Get(ctx context.Context, name string) (value []byte, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		value, err = s.store.Get(ctx, name)
+		return err
+	})
+	return value, err
+}
+
+// List returns the first n entry names that start with the
+// given prefix.
+func (s *timeoutKeyStore) List(ctx context.Context, prefix string, n int) (names []string, next string, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		names, next, err = s.store.List(ctx, prefix, n)
+		return err
+	})
+	return names, next, err
+}
+
+// ListFrom behaves like List but continues the listing at the
+// given entry name.
+func (s *timeoutKeyStore) ListFrom(ctx context.Context, prefix, continueAt string, n int) (names []string, next string, err error) {
+	err = s.do(ctx, true, func(ctx context.Context) (err error) {
+		names, next, err = listFrom(ctx, s.store, prefix, continueAt, n)
+		return err
+	})
+	return names, next, err
+}
+
+// Close closes the KeyStore.
+func (s *timeoutKeyStore) Close() error { return s.store.Close() }
+
+// do executes the operation within the budget. Each attempt
+// times out after the operation timeout.
+//
+// If retry is true and the KeyStore is unreachable or throttles
+// the operation, it retries the operation, with an exponential
+// backoff, until it succeeds or the budget has been exceeded.
+func (s *timeoutKeyStore) do(ctx context.Context, retry bool, op func(context.Context) error) error {
+	if s.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.budget)
+		defer cancel()
+	}
+
+	for delay := minTimeoutRetryDelay; ; delay = min(2*delay, maxTimeoutRetryDelay) {
+		err := s.attempt(ctx, op)
+		if err == nil || !retry || s.budget <= 0 {
+			return err
+		}
+		if code := keystore.ErrorCode(err); code != keystore.CodeUnreachable && code != keystore.CodeThrottled {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt executes the operation once. It times out after
+// the operation timeout.
+func (s *timeoutKeyStore) attempt(ctx context.Context, op func(context.Context) error) error {
+	if s.operation <= 0 {
+		return op(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.operation)
+	defer cancel()
+	return op(ctx)
+}
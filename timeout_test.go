@@ -0,0 +1,156 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/keystore"
+)
+
+func TestTimeoutKeyStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewTimeoutKeyStore(blockingGetKeyStore{&MemKeyStore{}}, &KeyStoreTimeoutConfig{
+		Operation: 10 * time.Millisecond,
+	})
+	if err := store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err := store.Get(ctx, "my-key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get did not time out: got '%v' - want '%v'", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTimeoutKeyStoreBudget(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	flaky := &flakyKeyStore{MemKeyStore: &MemKeyStore{}, Failures: 2}
+	store := NewTimeoutKeyStore(flaky, &KeyStoreTimeoutConfig{
+		Operation: 100 * time.Millisecond,
+		Budget:    5 * time.Second,
+	})
+	if err := store.Create(ctx, "my-key", []byte("value")); err != nil {
+		t.Fatalf("Failed to create entry: %v", err)
+	}
+	if _, err := store.Get(ctx, "my-key"); err != nil {
+		t.Fatalf("Get has not been retried: %v", err)
+	}
+	if n := flaky.Calls.Load(); n != 3 {
+		t.Fatalf("Invalid number of attempts: got %d - want %d", n, 3)
+	}
+
+	// Once the budget is exceeded, the operation fails.
+	flaky = &flakyKeyStore{MemKeyStore: &MemKeyStore{}, Failures: 1000}
+	store = NewTimeoutKeyStore(flaky, &KeyStoreTimeoutConfig{
+		Budget: 200 * time.Millisecond,
+	})
+	start := time.Now()
+	if _, err := store.Get(ctx, "my-key"); keystore.ErrorCode(err) != keystore.CodeUnreachable {
+		t.Fatalf("Get should have failed with '%s': got '%v'", keystore.CodeUnreachable, err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("Get exceeded its budget: took %v", d)
+	}
+}
+
+func TestTimeoutKeyStoreInterfaces(t *testing.T) {
+	t.Parallel()
+
+	conf := &KeyStoreTimeoutConfig{Operation: time.Second}
+	if store := NewTimeoutKeyStore(&MemKeyStore{}, nil); store == nil {
+		t.Fatal("Timeout KeyStore without config is nil")
+	} else if _, ok := store.(*MemKeyStore); !ok {
+		t.Fatal("Timeout KeyStore without config wraps the KeyStore")
+	}
+	if _, ok := NewTimeoutKeyStore(&MemKeyStore{}, conf).(WatchableKeyStore); !ok {
+		t.Fatal("Timeout KeyStore on a WatchableKeyStore is not watchable")
+	}
+	if _, ok := NewTimeoutKeyStore(struct{ KeyStore }{&MemKeyStore{}}, conf).(WatchableKeyStore); ok {
+		t.Fatal("Timeout KeyStore on a KeyStore without Watch is watchable")
+	}
+
+	store := NewTimeoutKeyStore(struct{ KeyStore }{&MemKeyStore{}}, conf)
+	if _, ok := store.(CryptoKeyStore); ok {
+		t.Fatal("Timeout KeyStore on a KeyStore is a CryptoKeyStore")
+	}
+	if expiringKeyStore(store) {
+		t.Fatal("Timeout KeyStore on a KeyStore is an ExpiringKeyStore")
+	}
+	if !expiringKeyStore(NewTimeoutKeyStore(&MemKeyStore{}, conf)) {
+		t.Fatal("Timeout KeyStore on an ExpiringKeyStore is not an ExpiringKeyStore")
+	}
+	if cryptoKeyStore(NewTimeoutKeyStore(&memCryptoKeyStore{MemKeyStore: &MemKeyStore{}}, conf)) == nil {
+		t.Fatal("Timeout KeyStore on a CryptoKeyStore is not a CryptoKeyStore")
+	}
+	if _, ok := NewTimeoutKeyStore(struct{ CryptoKeyStore }{&memCryptoKeyStore{MemKeyStore: &MemKeyStore{}}}, conf).(WatchableKeyStore); ok {
+		t.Fatal("Timeout KeyStore on a CryptoKeyStore without Watch is watchable")
+	}
+}
+
+func TestTimeoutKeyStoreWatchableCrypto(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewTimeoutKeyStore(&memCryptoKeyStore{MemKeyStore: &MemKeyStore{}}, &KeyStoreTimeoutConfig{Operation: time.Second})
+	if cryptoKeyStore(store) == nil {
+		t.Fatal("Timeout KeyStore on a watchable CryptoKeyStore is not a CryptoKeyStore")
+	}
+	if _, ok := baseKeyStore(store).(*memCryptoKeyStore); !ok {
+		t.Fatalf("Failed to unwrap timeout KeyStore: got '%T'", baseKeyStore(store))
+	}
+	watchable, ok := store.(WatchableKeyStore)
+	if !ok {
+		t.Fatal("Timeout KeyStore on a watchable CryptoKeyStore is not watchable")
+	}
+
+	events, err := watchable.Watch(ctx, "my-")
+	if err != nil {
+		t.Fatalf("Failed to watch KeyStore: %v", err)
+	}
+	if err = store.(CryptoKeyStore).CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Name != "my-key" {
+			t.Fatalf("Invalid event: got '%s' - want '%s'", event.Name, "my-key")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not receive an event")
+	}
+}
+
+// blockingGetKeyStore is a KeyStore whose Get operations
+// block until their context is done.
+type blockingGetKeyStore struct{ *MemKeyStore }
+
+func (blockingGetKeyStore) Get(ctx context.Context, _ string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// flakyKeyStore is a KeyStore whose first Get operations
+// fail since the KeyStore is unreachable.
+type flakyKeyStore struct {
+	*MemKeyStore
+	Failures int64
+	Calls    atomic.Int64
+}
+
+func (s *flakyKeyStore) Get(ctx context.Context, name string) ([]byte, error) {
+	if s.Calls.Add(1) <= s.Failures {
+		return nil, &keystore.ErrUnreachable{Err: errors.New("connection refused")}
+	}
+	return s.MemKeyStore.Get(ctx, name)
+}
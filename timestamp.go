@@ -0,0 +1,131 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kes/internal/crypto"
+)
+
+// timestampVersion is the version of the timestamp token format.
+const timestampVersion = 1
+
+// timestamp is a HandlerFunc that issues signed timestamps over
+// digests. It creates a JSON encoded api.TimestampToken containing
+// the digest, the current time and a random serial number and signs
+// the SHA-256 hash of the token with the latest version of the key.
+// Hence, any timestamp can be verified with the Verify API or the
+// key's public key.
+//
+// Timestamps are audited such that the audit log contains all
+// serial numbers issued by the server.
+func (s *Server) timestamp(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.TimestampRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	hash, ok := parseHMACHash(body.Hash)
+	if !ok {
+		resp.Failf(http.StatusNotAcceptable, "hash function '%s' is not supported", body.Hash)
+		return
+	}
+	if size := digestSize(hash); len(body.Digest) != size {
+		resp.Failf(http.StatusBadRequest, "invalid digest size '%d' for '%s': must be %d bytes", len(body.Digest), hash, size)
+		return
+	}
+	if len(body.Nonce) > 64 {
+		resp.Fail(http.StatusBadRequest, "nonce must not be longer than 64 bytes")
+		return
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	latest := key.Latest()
+	if !latest.Key.Type().IsSigning() {
+		resp.Failr(crypto.ErrNoSigning)
+		return
+	}
+
+	serial := make([]byte, 16)
+	if _, err = rand.Read(serial); err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to generate serial number")
+		return
+	}
+	token, err := json.Marshal(api.TimestampToken{
+		Version:   timestampVersion,
+		Key:       req.Resource,
+		Algorithm: latest.Key.Type().String(),
+		Hash:      hash.String(),
+		Digest:    body.Digest,
+		Time:      time.Now().UTC(),
+		Serial:    serial,
+		Nonce:     body.Nonce,
+	})
+	if err != nil {
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to create timestamp")
+		return
+	}
+
+	sum := sha256.Sum256(token)
+	signature, err := latest.Key.Sign(sum[:])
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to sign timestamp")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("timestamp '%x' issued with key '%s'", serial, req.Resource),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.TimestampResponse{
+		Token:     token,
+		Signature: signature,
+	})
+}
+
+// digestSize returns the size of digests computed
+// by the hash function.
+func digestSize(hash crypto.Hash) int {
+	switch hash {
+	case crypto.SHA512:
+		return sha512.Size
+	default:
+		return sha256.Size
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Reasons of failed TLS handshakes, used as metric labels.
+const (
+	tlsReasonUnknownCA    = "unknown_ca"         // The client certificate is not issued by a trusted CA
+	tlsReasonExpiredCert  = "expired_cert"       // The client certificate has expired or is not yet valid
+	tlsReasonInvalidCert  = "invalid_cert"       // The client certificate is invalid for another reason
+	tlsReasonNoClientCert = "no_client_cert"     // The client has not sent a certificate
+	tlsReasonRejected     = "rejected_by_client" // The client rejected the server certificate, e.g. due to a bad SNI
+	tlsReasonProtocol     = "protocol"           // No common TLS version or cipher suite, or not a TLS connection
+	tlsReasonTimeout      = "timeout"            // The handshake did not complete in time
+	tlsReasonClosed       = "connection_closed"  // The client closed the connection during the handshake
+	tlsReasonOther        = "other"
+)
+
+// connState is a http.Server ConnState hook that tracks the
+// number of open connections and records failed TLS handshakes.
+func (s *Server) connState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.state.Load().Metrics.CountConnection(true)
+	case http.StateHijacked:
+		s.state.Load().Metrics.CountConnection(false)
+	case http.StateClosed:
+		s.state.Load().Metrics.CountConnection(false)
+
+		// The http.Server performs the TLS handshake before reading
+		// any request. A closed connection without a complete handshake
+		// has failed the handshake. Calling Handshake again returns the
+		// handshake error without any network I/O.
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok || tlsConn.ConnectionState().HandshakeComplete {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+		if err := tlsConn.HandshakeContext(ctx); err != nil && !errors.Is(err, net.ErrClosed) {
+			s.logTLSHandshakeError(ctx, tlsConn, err)
+		}
+	}
+}
+
+// logTLSHandshakeError counts and logs a failed TLS handshake,
+// including the remote address, the requested server name (SNI)
+// and, if available, the client certificate. Handshakes failing
+// due to invalid client certificates are logged as warnings
+// since they usually indicate a misconfigured client, like a
+// MinIO server using a certificate not trusted by KES.
+func (s *Server) logTLSHandshakeError(ctx context.Context, conn *tls.Conn, err error) {
+	state := s.state.Load()
+	reason := tlsHandshakeReason(err)
+	state.Metrics.CountTLSHandshakeError(reason)
+
+	attrs := []any{
+		slog.String("remote", conn.RemoteAddr().String()),
+		slog.String("reason", reason),
+	}
+	if sni := conn.ConnectionState().ServerName; sni != "" {
+		attrs = append(attrs, slog.String("sni", sni))
+	}
+
+	level := slog.LevelDebug
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) && len(certErr.UnverifiedCertificates) > 0 {
+		cert := certErr.UnverifiedCertificates[0]
+		attrs = append(attrs,
+			slog.String("subject", cert.Subject.String()),
+			slog.String("issuer", cert.Issuer.String()),
+			slog.Time("not_after", cert.NotAfter),
+		)
+		level = slog.LevelWarn
+	} else if reason == tlsReasonNoClientCert || reason == tlsReasonRejected {
+		level = slog.LevelWarn
+	}
+	state.Log.Log(ctx, level, fmt.Sprintf("kes: TLS handshake failed: %v", err), attrs...)
+}
+
+// tlsHandshakeReason returns the reason of the failed TLS handshake.
+func tlsHandshakeReason(err error) string {
+	var (
+		certErr     *tls.CertificateVerificationError
+		unknownCA   x509.UnknownAuthorityError
+		invalidCert x509.CertificateInvalidError
+		recordErr   tls.RecordHeaderError
+		opErr       *net.OpError
+		netErr      net.Error
+	)
+	switch {
+	case errors.As(err, &certErr):
+		switch {
+		case errors.As(certErr.Err, &unknownCA):
+			return tlsReasonUnknownCA
+		case errors.As(certErr.Err, &invalidCert) && invalidCert.Reason == x509.Expired:
+			return tlsReasonExpiredCert
+		default:
+			return tlsReasonInvalidCert
+		}
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		return tlsReasonRejected
+	case errors.As(err, &recordErr):
+		return tlsReasonProtocol
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return tlsReasonTimeout
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return tlsReasonClosed
+	}
+
+	// The crypto/tls package does not export errors for the
+	// following handshake failures.
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "didn't provide a certificate"):
+		return tlsReasonNoClientCert
+	case strings.Contains(msg, "unsupported versions"), strings.Contains(msg, "no cipher suite"), strings.Contains(msg, "no mutually supported"):
+		return tlsReasonProtocol
+	default:
+		return tlsReasonOther
+	}
+}
+
+// tlsHandshakeLogFilter is a slog.Handler that drops the TLS
+// handshake errors logged by the http.Server since the connState
+// hook logs them with more details.
+type tlsHandshakeLogFilter struct {
+	slog.Handler
+}
+
+func (h tlsHandshakeLogFilter) Handle(ctx context.Context, r slog.Record) error {
+	if strings.HasPrefix(r.Message, "http: TLS handshake error") {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
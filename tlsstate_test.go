@@ -0,0 +1,79 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestTLSHandshakeMetrics(t *testing.T) {
+	ctx := testContext(t)
+
+	ca := newTestCA(t)
+	srv, url := startServer(ctx, ca.ServerConfig(nil))
+
+	// A client certificate issued by an unknown CA
+	cert, _ := newTestCA(t).Issue(t, 1, "")
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(defaultServerCertificate().Leaf)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      rootCAs,
+				Certificates: []tls.Certificate{cert},
+			},
+		},
+	}
+	if resp, err := client.Get(url + "/version"); err == nil {
+		resp.Body.Close()
+		t.Fatal("TLS handshake succeeded with client certificate issued by an unknown CA")
+	}
+
+	const Metric = `kes_tls_handshake_errors{reason="unknown_ca"} 1`
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var buf bytes.Buffer
+		if err := srv.state.Load().Metrics.EncodeTo(expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeTextPlain))); err != nil {
+			t.Fatalf("Failed to encode metrics: %v", err)
+		}
+		if strings.Contains(buf.String(), Metric) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Metrics do not contain '%s':\n%s", Metric, buf.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var tlsHandshakeReasonTests = []struct {
+	Err    error
+	Reason string
+}{
+	{Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, Reason: tlsReasonUnknownCA},
+	{Err: &tls.CertificateVerificationError{Err: x509.CertificateInvalidError{Reason: x509.Expired}}, Reason: tlsReasonExpiredCert},
+	{Err: &tls.CertificateVerificationError{Err: x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign}}, Reason: tlsReasonInvalidCert},
+	{Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, Reason: tlsReasonProtocol},
+	{Err: context.DeadlineExceeded, Reason: tlsReasonTimeout},
+	{Err: errors.New("tls: client didn't provide a certificate"), Reason: tlsReasonNoClientCert},
+	{Err: errors.New("tls: unknown error"), Reason: tlsReasonOther},
+}
+
+func TestTLSHandshakeReason(t *testing.T) {
+	for i, test := range tlsHandshakeReasonTests {
+		if reason := tlsHandshakeReason(test.Err); reason != test.Reason {
+			t.Fatalf("Test %d: got '%s' - want '%s'", i, reason, test.Reason)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+// The token API is a session-based API for PKCS#11 client modules
+// that expose KES as a network token (HSM). A client opens a session
+// for a set of keys, like C_OpenSession and C_Login, and uses it to
+// sign digests and unwrap keys until it closes the session.
+//
+// Sessions are strict. A session can only be used by the identity
+// that opened it, within the same enclave and only with the keys it
+// has been opened for. It expires once it has not been used for
+// tokenSessionTimeout. Each request is still authorized by the
+// caller's policy.
+const (
+	// tokenSessionTimeout is the duration after which an
+	// unused token session expires.
+	tokenSessionTimeout = 5 * time.Minute
+
+	// tokenMaxSessions is the max. number of open token
+	// sessions per identity.
+	tokenMaxSessions = 64
+
+	// tokenMaxSessionKeys is the max. number of keys
+	// per token session.
+	tokenMaxSessionKeys = 64
+)
+
+// tokenSessions is a set of open token sessions.
+type tokenSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*tokenSession // Sessions by ID
+}
+
+// A tokenSession is a session of a PKCS#11 client module.
+type tokenSession struct {
+	Identity  kes.Identity
+	Enclave   string
+	Keys      []string
+	ExpiresAt time.Time
+}
+
+// Open opens a new session for the request's identity and enclave
+// that can be used with the given keys. It returns the session ID.
+func (t *tokenSessions) Open(req *api.Request, keys []string) (string, time.Time, error) {
+	var id [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", time.Time{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sessions == nil {
+		t.sessions = map[string]*tokenSession{}
+	}
+	now := time.Now()
+	open := 0
+	for sessionID, session := range t.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(t.sessions, sessionID)
+			continue
+		}
+		if session.Identity == req.Identity {
+			open++
+		}
+	}
+	if open >= tokenMaxSessions {
+		return "", time.Time{}, api.NewError(http.StatusTooManyRequests, "too many open sessions")
+	}
+
+	session := &tokenSession{
+		Identity:  req.Identity,
+		Enclave:   req.Enclave,
+		Keys:      slices.Clone(keys),
+		ExpiresAt: now.Add(tokenSessionTimeout),
+	}
+	sessionID := hex.EncodeToString(id[:])
+	t.sessions[sessionID] = session
+	return sessionID, session.ExpiresAt, nil
+}
+
+// Close closes the session. It returns an error if no such
+// session is open for the request's identity.
+func (t *tokenSessions) Close(req *api.Request, sessionID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.lookup(req, sessionID); err != nil {
+		return err
+	}
+	delete(t.sessions, sessionID)
+	return nil
+}
+
+// Use checks whether the session can be used by the request
+// to access the given key and extends the session's lifetime.
+func (t *tokenSessions) Use(req *api.Request, sessionID, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, err := t.lookup(req, sessionID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(session.Keys, key) {
+		return api.NewError(http.StatusForbidden, fmt.Sprintf("session has not been opened for key '%s'", key))
+	}
+	session.ExpiresAt = time.Now().Add(tokenSessionTimeout)
+	return nil
+}
+
+// lookup returns the session if it is open for the request's
+// identity and enclave. It must be called while holding the lock.
+func (t *tokenSessions) lookup(req *api.Request, sessionID string) (*tokenSession, error) {
+	session, ok := t.sessions[sessionID]
+	if !ok || session.Identity != req.Identity || session.Enclave != req.Enclave {
+		return nil, api.NewError(http.StatusForbidden, "invalid session")
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(t.sessions, sessionID)
+		return nil, api.NewError(http.StatusForbidden, "session expired")
+	}
+	return session, nil
+}
+
+func (s *Server) openTokenSession(resp *api.Response, req *api.Request) {
+	var body api.OpenTokenSessionRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(body.Keys) == 0 {
+		resp.Fail(http.StatusBadRequest, "no keys specified")
+		return
+	}
+	if len(body.Keys) > tokenMaxSessionKeys {
+		resp.Failf(http.StatusBadRequest, "too many keys: a session supports at most %d keys", tokenMaxSessionKeys)
+		return
+	}
+	for _, name := range body.Keys {
+		if !validName(name) {
+			resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", name)
+			return
+		}
+	}
+
+	sessionID, expiresAt, err := s.sessions.Open(req, body.Keys)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to open session")
+		return
+	}
+
+	const StatusOK = http.StatusOK
+	s.state.Load().Audit.Log(
+		fmt.Sprintf("token session opened for %d keys", len(body.Keys)),
+		StatusOK,
+		req,
+	)
+	api.ReplyWith(resp, StatusOK, api.OpenTokenSessionResponse{
+		Session:   sessionID,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *Server) closeTokenSession(resp *api.Response, req *api.Request) {
+	var body api.CloseTokenSessionRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := s.sessions.Close(req, body.Session); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to close session")
+		return
+	}
+	resp.Reply(http.StatusOK)
+}
+
+func (s *Server) tokenSign(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.TokenSignRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := s.sessions.Use(req, body.Session, req.Resource); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to use session")
+		return
+	}
+
+	key, err := s.state.Load().enclave(req).Keys.Get(req.Context(), req.Resource)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadGateway, "failed to read key")
+		return
+	}
+	signature, err := key.Latest().Key.Sign(body.Digest)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to sign digest")
+		return
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.SignResponse{
+		Signature: signature,
+	})
+}
+
+func (s *Server) tokenUnwrap(resp *api.Response, req *api.Request) {
+	if !validName(req.Resource) {
+		resp.Failf(http.StatusBadRequest, "key name '%s' is empty, too long or contains invalid characters", req.Resource)
+		return
+	}
+
+	var body api.TokenUnwrapRequest
+	if err := api.ReadBody(req, &body); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := s.sessions.Use(req, body.Session, req.Resource); err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to use session")
+		return
+	}
+
+	state := s.state.Load()
+	keys := state.enclave(req).Keys
+	plaintext, cached, err := keys.Unwrap(req.Context(), req.Resource, body.Ciphertext, body.Context)
+	if err != nil {
+		if err, ok := api.IsError(err); ok {
+			resp.Failr(err)
+			return
+		}
+
+		s.fail(resp, req, err, http.StatusInternalServerError, "failed to unwrap ciphertext")
+		return
+	}
+	if keys.deks != nil {
+		state.Metrics.CountDEKCache(cached)
+	}
+
+	api.ReplyWith(resp, http.StatusOK, api.DecryptKeyResponse{
+		Plaintext: plaintext,
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net"
+	"os"
+
+	"github.com/minio/kms-go/kes"
+)
+
+// peerUIDKey is the context key of the user ID of a local
+// process connected via the server's Unix domain socket.
+type peerUIDKey struct{}
+
+// unixConnContext returns a context containing the user ID
+// of the process on the other end of c if c is a Unix domain
+// socket connection. It is used as http.Server.ConnContext.
+//
+// If the user ID cannot be obtained, it returns ctx as is.
+// Requests sent via such a connection are rejected since
+// they have neither a user ID nor a TLS client certificate.
+func unixConnContext(ctx context.Context, c net.Conn) context.Context {
+	conn, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	uid, err := peerUID(conn)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerUIDKey{}, uid)
+}
+
+// listenUnix listens on the Unix domain socket at the given
+// path. It replaces an existing socket but fails if any other
+// file exists at the path.
+func listenUnix(path string) (net.Listener, error) {
+	if errPeerUIDNotSupported != nil {
+		return nil, errPeerUIDNotSupported
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("kes: unix socket path '%s' exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// unixIdentities returns a copy of the identities of the
+// Unix domain socket config, if not nil.
+func unixIdentities(conf *UnixSocketConfig) map[uint32]kes.Identity {
+	if conf == nil {
+		return nil
+	}
+	return maps.Clone(conf.Identities)
+}
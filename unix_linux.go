@@ -0,0 +1,36 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// errPeerUIDNotSupported is nil since peer credentials
+// are supported on Linux.
+var errPeerUIDNotSupported error
+
+// peerUID returns the user ID of the process on the other
+// end of the connection using SO_PEERCRED.
+func peerUID(conn *net.UnixConn) (uint32, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var cred *unix.Ucred
+	cErr := raw.Control(func(fd uintptr) {
+		cred, err = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if cErr != nil {
+		return 0, cErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cred.Uid, nil
+}
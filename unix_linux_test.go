@@ -0,0 +1,72 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/api"
+	"github.com/minio/kms-go/kes"
+)
+
+func TestUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	ctx := testContext(t)
+	uid := uint32(os.Getuid())
+	for i, test := range []struct {
+		Identities map[uint32]kes.Identity
+		Status     int
+	}{
+		{Identities: map[uint32]kes.Identity{uid: defaultIdentity}, Status: http.StatusOK},
+		{Identities: map[uint32]kes.Identity{uid + 1: defaultIdentity}, Status: http.StatusForbidden},
+		{Identities: map[uint32]kes.Identity{uid: "my-app"}, Status: http.StatusForbidden},
+	} {
+		path := filepath.Join(t.TempDir(), "kes.sock")
+		srv, _ := startServer(ctx, &Config{
+			UnixSocket: &UnixSocketConfig{
+				Path:       path,
+				Identities: test.Identities,
+			},
+		})
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		}
+
+		var resp *http.Response
+		for {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://kes"+api.PathKeyCreate+"my-key", nil)
+			if err != nil {
+				t.Fatalf("Test %d: failed to create request: %v", i, err)
+			}
+			if resp, err = client.Do(req); err == nil {
+				break
+			}
+			select { // The server may not listen on the socket yet
+			case <-ctx.Done():
+				t.Fatalf("Test %d: failed to send request: %v", i, err)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		if resp.StatusCode != test.Status {
+			t.Fatalf("Test %d: invalid status: got '%d' - want '%d'", i, resp.StatusCode, test.Status)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package kes
+
+import (
+	"errors"
+	"net"
+)
+
+// errPeerUIDNotSupported is returned when listening on a
+// Unix domain socket since peer credentials are only
+// supported on Linux.
+var errPeerUIDNotSupported = errors.New("kes: unix sockets are only supported on linux")
+
+func peerUID(*net.UnixConn) (uint32, error) { return 0, errPeerUIDNotSupported }
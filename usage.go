@@ -0,0 +1,115 @@
+// Copyright 2024 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/kes/internal/cache"
+	"github.com/minio/kms-go/kes"
+)
+
+// usageFlushInterval is the interval in which a keyCache
+// persists key usage at a MetadataKeyStore.
+const usageFlushInterval = 1 * time.Minute
+
+// keyUsage counts how often keys are used and when they have
+// been used last. It keeps the usage recorded since the last
+// flush in memory.
+//
+// Usage is persisted, as part of the key's EntryMetadata, only
+// if the KeyStore implements MetadataKeyStore. Otherwise, the
+// usage is tracked since the KES server has been started.
+type keyUsage struct {
+	keys sync.Map // Key name -> *usageCounter
+}
+
+// usageCounter is the usage of a key not yet persisted.
+type usageCounter struct {
+	Uses     atomic.Uint64
+	LastUsed atomic.Int64 // Unix time in nanoseconds
+}
+
+// Add records one usage of the key with the given name.
+func (u *keyUsage) Add(name string, now time.Time) {
+	v, ok := u.keys.Load(name)
+	if !ok {
+		v, _ = u.keys.LoadOrStore(name, &usageCounter{})
+	}
+	counter := v.(*usageCounter)
+	counter.Uses.Add(1)
+
+	unixNano := now.UnixNano()
+	for {
+		last := counter.LastUsed.Load()
+		if last >= unixNano || counter.LastUsed.CompareAndSwap(last, unixNano) {
+			break
+		}
+	}
+}
+
+// Apply adds the usage not yet persisted of the key with the
+// given name to m.
+func (u *keyUsage) Apply(name string, m *EntryMetadata) {
+	v, ok := u.keys.Load(name)
+	if !ok {
+		return
+	}
+	counter := v.(*usageCounter)
+	m.Uses += counter.Uses.Load()
+	if lastUsed := time.Unix(0, counter.LastUsed.Load()); lastUsed.After(m.LastUsedAt) {
+		m.LastUsedAt = lastUsed.UTC()
+	}
+}
+
+// Delete removes the usage of the key with the given name.
+func (u *keyUsage) Delete(name string) { u.keys.Delete(name) }
+
+// Flush adds the usage recorded since the last flush to the
+// EntryMetadata stored at the MetadataKeyStore.
+//
+// If no metadata is stored for a key, Flush calls base to obtain
+// it. Usage of keys without any metadata is kept in memory until
+// the next flush. Flush holds the lock of a key while updating
+// its metadata.
+func (u *keyUsage) Flush(ctx context.Context, store MetadataKeyStore, locks *cache.Barrier[string], base func(name string) (EntryMetadata, bool)) {
+	u.keys.Range(func(k, v any) bool {
+		name, counter := k.(string), v.(*usageCounter)
+		uses := counter.Uses.Swap(0)
+		if uses == 0 {
+			return true
+		}
+
+		locks.Lock(name)
+		defer locks.Unlock(name)
+
+		m, err := store.Metadata(ctx, name)
+		if errors.Is(err, kes.ErrKeyNotFound) {
+			u.keys.Delete(name)
+			return true
+		}
+		if err == nil && m.IsEmpty() {
+			var ok bool
+			if m, ok = base(name); !ok {
+				err = errors.New("kes: no key metadata")
+			}
+		}
+		if err == nil {
+			m.Uses += uses
+			if lastUsed := time.Unix(0, counter.LastUsed.Load()); lastUsed.After(m.LastUsedAt) {
+				m.LastUsedAt = lastUsed.UTC()
+			}
+			err = store.SetMetadata(ctx, name, m)
+		}
+		if err != nil {
+			counter.Uses.Add(uses) // Retry on the next flush
+		}
+		return ctx.Err() == nil
+	})
+}